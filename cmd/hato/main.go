@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"hato-bot-go/lib/app"
+)
+
+// main 引数・環境変数HATO_MODE・設定ファイルのmodeで選択した実行モードで実行
+// 例: hato misskey / hato --mode=serve --port 8080 / hato cli amesh 東京
+func main() {
+	app.Main("", os.Args[1:])
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"hato-bot-go/lib/app"
+	"hato-bot-go/lib/mixi2"
+)
+
+// init mixi2モードを登録する
+func init() {
+	app.Register(app.ModeMixi2, mixi2.Run)
+}
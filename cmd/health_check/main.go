@@ -2,36 +2,24 @@ package main
 
 import (
 	"context"
-	"io"
 	"log"
-	"net/http"
+	"os"
 
-	"github.com/cockroachdb/errors"
-
-	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/healthcheck"
 )
 
+// main HTTPサーバーの/statusを確認し、結果に応じた終了コードで終了する
+// 接続できない場合と異常な応答の場合で終了コードを分け、オーケストレーターが原因を区別できるようにする
 func main() {
-	// localhost:8080/statusにHTTPリクエストを送信
-	req, err := http.NewRequestWithContext(
-		context.Background(),
-		http.MethodGet,
-		"http://localhost:8080/status",
-		nil,
-	)
+	params, err := healthcheck.ParseFlags(os.Args[1:], os.Getenv)
 	if err != nil {
-		panic(errors.Wrap(err, "Failed to http.NewRequestWithContext"))
+		log.Printf("Health check failed: %v", err)
+		os.Exit(healthcheck.ExitCode(err))
 	}
 
-	resp, err := httpclient.ExecuteHTTPRequest(http.DefaultClient, req)
-	if err != nil {
-		panic(errors.Wrap(err, "Failed to httpclient.ExecuteHTTPRequest"))
+	if err := healthcheck.Check(context.Background(), params); err != nil {
+		log.Printf("Health check failed: %v", err)
+		os.Exit(healthcheck.ExitCode(err))
 	}
-	defer func(body io.ReadCloser) {
-		if closeErr := body.Close(); closeErr != nil {
-			panic(errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-
 	log.Println("Health check passed")
 }
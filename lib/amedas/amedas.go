@@ -0,0 +1,312 @@
+package amedas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/jma/area"
+)
+
+// エラー定数
+var (
+	ErrNoStationFound    = errors.New("no amedas station found near the location")
+	ErrInvalidLatestTime = errors.New("invalid amedas latest time")
+)
+
+// 気象庁アメダスのURL
+const (
+	latestTimeURL  = "https://www.jma.go.jp/bosai/amedas/data/latest_time.txt"
+	mapDataURLBase = "https://www.jma.go.jp/bosai/amedas/data/map/"
+)
+
+// maxStationDistanceKm 観測所を探す範囲（これより遠い観測所しかない場合はErrNoStationFound）
+const maxStationDistanceKm = 100.0
+
+// missingValue 欠測の場合に表示する文字列
+const missingValue = "---"
+
+// jst 観測時刻の表示に使うタイムゾーン
+var jst = time.FixedZone("JST", 9*60*60)
+
+// defaultClient クライアント未指定時に使うHTTPクライアント
+// 外部サービスが不調な場合はサーキットブレーカーで即座に失敗させる
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
+	Timeout:   30 * time.Second,
+}
+
+// windDirections 風向（1〜16、16は北）の16方位名
+var windDirections = map[i18n.Locale][]string{
+	i18n.LocaleJa: {
+		"北北東", "北東", "東北東", "東", "東南東", "南東", "南南東", "南",
+		"南南西", "南西", "西南西", "西", "西北西", "北西", "北北西", "北",
+	},
+	i18n.LocaleEn: {
+		"NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S",
+		"SSW", "SW", "WSW", "W", "WNW", "NW", "NNW", "N",
+	},
+}
+
+// calmWind 風向が静穏（0）の場合の表示
+var calmWind = map[i18n.Locale]string{
+	i18n.LocaleJa: "静穏",
+	i18n.LocaleEn: "Calm",
+}
+
+// Station アメダス観測所
+type Station struct {
+	Code   string  // 観測所番号
+	Name   string  // 観測所名（漢字）
+	EnName string  // 観測所名（英語）
+	Lat    float64 // 緯度
+	Lng    float64 // 経度
+}
+
+// Observation アメダスの観測値
+// 観測していない要素や欠測の場合はnil
+type Observation struct {
+	Station         Station   // 観測所
+	DistanceKm      float64   // 指定した位置から観測所までの距離（キロメートル）
+	ObservedAt      time.Time // 観測時刻
+	Temperature     *float64  // 気温（℃）
+	Humidity        *float64  // 湿度（%）
+	WindDirection   *int      // 風向（0は静穏、1〜16は北北東から時計回り）
+	WindSpeed       *float64  // 風速（m/s）
+	Precipitation1h *float64  // 前1時間降水量（mm）
+}
+
+// GetObservationWithClientParams 観測値取得のリクエスト構造体
+type GetObservationWithClientParams struct {
+	Client   *http.Client    // HTTPクライアント
+	Location *amesh.Location // 位置情報
+	Resolver *area.Resolver  // 観測所を探すResolver（nilの場合はClientで観測所一覧を取得し、キャッシュしない）
+}
+
+// ParseAmedasCommandResult amedasコマンドの解析結果を表す構造体
+type ParseAmedasCommandResult struct {
+	Place    string
+	IsAmedas bool
+}
+
+// observationValue 観測値と品質情報の組（[値, 品質]）
+// 品質が0（正常）または1（準正常）以外、または値がnullの場合は欠測として扱う
+type observationValue []*float64
+
+// value 有効な観測値を返す（欠測の場合はnil）
+func (v observationValue) value() *float64 {
+	if len(v) < 2 || v[0] == nil || v[1] == nil || 1 < *v[1] {
+		return nil
+	}
+	return v[0]
+}
+
+// observationEntry 全観測所の観測値（map/{time}.json）の要素
+type observationEntry struct {
+	Temp            observationValue `json:"temp"`
+	Humidity        observationValue `json:"humidity"`
+	WindDirection   observationValue `json:"windDirection"`
+	Wind            observationValue `json:"wind"`
+	Precipitation1h observationValue `json:"precipitation1h"`
+}
+
+// ParseAmedasCommand amedasコマンドを解析
+func ParseAmedasCommand(text string) ParseAmedasCommandResult {
+	parsed := lib.ParseCommand(text, "amedas")
+	if !parsed.Matched {
+		return ParseAmedasCommandResult{
+			Place:    "",
+			IsAmedas: false,
+		}
+	}
+
+	place := parsed.Args
+	if place == "" {
+		place = "東京" // デフォルトの場所
+	}
+	return ParseAmedasCommandResult{
+		Place:    place,
+		IsAmedas: true,
+	}
+}
+
+// GetObservationWithClient HTTPクライアントを指定して最寄りのアメダス観測所の最新の観測値を取得する
+// 気温を観測している観測所のうち、maxStationDistanceKm以内で最も近いものを選ぶ
+func GetObservationWithClient(ctx context.Context, params *GetObservationWithClientParams) (*Observation, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	resolver := params.Resolver
+	if resolver == nil {
+		resolver = area.NewResolver(&area.ResolverSetting{Client: params.Client})
+	}
+	candidates, err := resolver.StationsNear(ctx, &area.StationsNearParams{
+		Lat:           params.Location.Lat,
+		Lng:           params.Location.Lng,
+		MaxDistanceKm: maxStationDistanceKm,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to StationsNear")
+	}
+
+	observedAt, err := fetchLatestTime(ctx, params.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetchLatestTime")
+	}
+
+	observations, err := fetchMapData(ctx, params.Client, observedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetchMapData")
+	}
+
+	// 距離順の観測所のうち気温の観測値がある最初の観測所を選ぶ
+	for _, candidate := range candidates {
+		entry, ok := observations[candidate.Station.Code]
+		if !ok || entry.Temp.value() == nil {
+			continue
+		}
+
+		observation := &Observation{
+			Station: Station{
+				Code:   candidate.Station.Code,
+				Name:   candidate.Station.Name,
+				EnName: candidate.Station.EnName,
+				Lat:    candidate.Station.Lat,
+				Lng:    candidate.Station.Lng,
+			},
+			DistanceKm:      candidate.DistanceKm,
+			ObservedAt:      observedAt,
+			Temperature:     entry.Temp.value(),
+			Humidity:        entry.Humidity.value(),
+			WindSpeed:       entry.Wind.value(),
+			Precipitation1h: entry.Precipitation1h.value(),
+		}
+		if direction := entry.WindDirection.value(); direction != nil {
+			observation.WindDirection = new(int(*direction))
+		}
+		return observation, nil
+	}
+
+	return nil, errors.Wrapf(
+		ErrNoStationFound,
+		"place: %s (%.4f, %.4f)",
+		params.Location.PlaceName,
+		params.Location.Lat,
+		params.Location.Lng,
+	)
+}
+
+// GetObservation 最寄りのアメダス観測所の最新の観測値を取得する
+func GetObservation(ctx context.Context, location *amesh.Location) (*Observation, error) {
+	return GetObservationWithClient(ctx, &GetObservationWithClientParams{
+		Client:   defaultClient,
+		Location: location,
+		Resolver: area.Default,
+	})
+}
+
+// FillTemplateData 観測値を返信テンプレートの変数に設定する
+// 観測所名・風向はdata.Localeの言語で設定する
+func (o *Observation) FillTemplateData(data *i18n.TemplateData) {
+	data.Station = o.Station.Name
+	if data.Locale == i18n.LocaleEn && o.Station.EnName != "" {
+		data.Station = o.Station.EnName
+	}
+	data.ObservedAt = o.ObservedAt.In(jst).Format("2006/01/02 15:04")
+	data.Temperature = formatValue(o.Temperature)
+	data.Humidity = formatValue(o.Humidity)
+	data.WindDirection = windDirectionName(o.WindDirection, data.Locale)
+	data.WindSpeed = formatValue(o.WindSpeed)
+	data.Precipitation = formatValue(o.Precipitation1h)
+}
+
+// CommandErrorKey amedasコマンド処理のエラーに応じた返信メッセージのキーを返す
+func CommandErrorKey(err error) i18n.Key {
+	if errors.Is(err, httpclient.ErrCircuitOpen) {
+		return i18n.KeyErrorUpstreamUnavailable
+	}
+	if errors.Is(err, ErrNoStationFound) {
+		return i18n.KeyErrorNoAmedasStation
+	}
+	return i18n.KeyErrorAmedasCommand
+}
+
+// fetchLatestTime 最新の観測時刻を取得する
+func fetchLatestTime(ctx context.Context, client *http.Client) (time.Time, error) {
+	body, err := fetchBody(ctx, client, latestTimeURL)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Failed to fetchBody")
+	}
+
+	observedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(string(body)))
+	if err != nil {
+		return time.Time{}, errors.Wrap(errors.Join(ErrInvalidLatestTime, err), "Failed to time.Parse")
+	}
+	return observedAt, nil
+}
+
+// fetchMapData 指定した時刻の全観測所の観測値を取得する
+func fetchMapData(ctx context.Context, client *http.Client, observedAt time.Time) (map[string]observationEntry, error) {
+	body, err := fetchBody(ctx, client, fmt.Sprintf("%s%s.json", mapDataURLBase, observedAt.In(jst).Format("20060102150405")))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetchBody")
+	}
+
+	var observations map[string]observationEntry
+	if err := json.Unmarshal(body, &observations); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return observations, nil
+}
+
+// fetchBody GETリクエストを実行してレスポンスボディを読み込む
+func fetchBody(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+
+	body, err := httpclient.ExecuteAndReadBody(client, req, httpclient.DefaultMaxResponseBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
+	}
+	return body, nil
+}
+
+// formatValue 観測値を小数点以下1桁の文字列にする（欠測の場合は---）
+func formatValue(value *float64) string {
+	if value == nil {
+		return missingValue
+	}
+	return fmt.Sprintf("%.1f", *value)
+}
+
+// windDirectionName 風向を16方位名にする（欠測の場合は---）
+func windDirectionName(direction *int, locale i18n.Locale) string {
+	names, ok := windDirections[locale]
+	if !ok {
+		names = windDirections[i18n.DefaultLocale]
+	}
+
+	switch {
+	case direction == nil || *direction < 0 || len(names) < *direction:
+		return missingValue
+	case *direction == 0:
+		if calm, ok := calmWind[locale]; ok {
+			return calm
+		}
+		return calmWind[i18n.DefaultLocale]
+	default:
+		return names[*direction-1]
+	}
+}
@@ -0,0 +1,267 @@
+package amedas_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amedas"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+const (
+	stationTableResponse = `{
+		"44131": {"type": "D", "kjName": "大手町雨量", "enName": "Otemachi Rain", "lat": [35, 41.4], "lon": [139, 45.0]},
+		"44132": {"type": "A", "kjName": "東京", "enName": "Tokyo", "lat": [35, 40.0], "lon": [139, 45.0]},
+		"11001": {"type": "C", "kjName": "宗谷岬", "enName": "Cape Soya", "lat": [45, 31.2], "lon": [141, 56.1]}
+	}`
+	mapDataResponse = `{
+		"44131": {"precipitation1h": [0.5, 0]},
+		"44132": {"temp": [12.3, 0], "humidity": [55, 0], "windDirection": [16, 0], "wind": [3.2, 0], "precipitation1h": [0.0, 0]},
+		"11001": {"temp": [-1.5, 0], "humidity": [null, 5], "windDirection": [0, 0], "wind": [0.0, 0]}
+	}`
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func newAmedasTransport(latestTime string, stationTableStatus int) *httpclient.MockTransport {
+	return httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "amedastable.json", Responses: []httpclient.MockResponse{{StatusCode: stationTableStatus, Body: stationTableResponse}}},
+			{Pattern: "latest_time.txt", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: latestTime}}},
+			{Pattern: "/map/20260102150000.json", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: mapDataResponse}}},
+		},
+	})
+}
+
+func TestGetObservationWithClient(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name               string
+		location           *amesh.Location
+		latestTime         string
+		stationTableStatus int
+		expected           *amedas.Observation
+		expectError        error
+	}{
+		{
+			name:               "気温を観測している最寄りの観測所",
+			location:           &amesh.Location{Lat: 35.69, Lng: 139.75, PlaceName: "大手町"},
+			latestTime:         "2026-01-02T15:00:00+09:00\n",
+			stationTableStatus: http.StatusOK,
+			expected: &amedas.Observation{
+				Station:         amedas.Station{Code: "44132", Name: "東京", EnName: "Tokyo", Lat: 35 + 40.0/60, Lng: 139.75},
+				ObservedAt:      time.Date(2026, 1, 2, 15, 0, 0, 0, jst),
+				Temperature:     float64Ptr(12.3),
+				Humidity:        float64Ptr(55),
+				WindDirection:   intPtr(16),
+				WindSpeed:       float64Ptr(3.2),
+				Precipitation1h: float64Ptr(0),
+			},
+		},
+		{
+			name:               "欠測の値はnil",
+			location:           &amesh.Location{Lat: 45.5, Lng: 141.9, PlaceName: "宗谷岬"},
+			latestTime:         "2026-01-02T15:00:00+09:00",
+			stationTableStatus: http.StatusOK,
+			expected: &amedas.Observation{
+				Station:       amedas.Station{Code: "11001", Name: "宗谷岬", EnName: "Cape Soya", Lat: 45 + 31.2/60, Lng: 141 + 56.1/60},
+				ObservedAt:    time.Date(2026, 1, 2, 15, 0, 0, 0, jst),
+				Temperature:   float64Ptr(-1.5),
+				WindDirection: intPtr(0),
+				WindSpeed:     float64Ptr(0),
+			},
+		},
+		{
+			name:               "近くに観測所がない",
+			location:           &amesh.Location{Lat: 52.9, Lng: 172.9, PlaceName: "Attu"},
+			latestTime:         "2026-01-02T15:00:00+09:00",
+			stationTableStatus: http.StatusOK,
+			expectError:        amedas.ErrNoStationFound,
+		},
+		{
+			name:               "観測時刻が不正",
+			location:           &amesh.Location{Lat: 35.69, Lng: 139.75, PlaceName: "大手町"},
+			latestTime:         "invalid",
+			stationTableStatus: http.StatusOK,
+			expectError:        amedas.ErrInvalidLatestTime,
+		},
+		{
+			name:               "観測所一覧の取得に失敗した場合は埋め込みの観測所一覧を使う",
+			location:           &amesh.Location{Lat: 35.69, Lng: 139.75, PlaceName: "大手町"},
+			latestTime:         "2026-01-02T15:00:00+09:00",
+			stationTableStatus: http.StatusInternalServerError,
+			expected: &amedas.Observation{
+				Station:         amedas.Station{Code: "44132", Name: "東京", EnName: "Tokyo", Lat: 35 + 41.5/60, Lng: 139.75},
+				ObservedAt:      time.Date(2026, 1, 2, 15, 0, 0, 0, jst),
+				Temperature:     float64Ptr(12.3),
+				Humidity:        float64Ptr(55),
+				WindDirection:   intPtr(16),
+				WindSpeed:       float64Ptr(3.2),
+				Precipitation1h: float64Ptr(0),
+			},
+		},
+		{
+			name:        "位置情報がnil",
+			location:    nil,
+			expectError: lib.ErrParamsNil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := newAmedasTransport(tt.latestTime, tt.stationTableStatus)
+
+			result, err := amedas.GetObservationWithClient(t.Context(), &amedas.GetObservationWithClientParams{
+				Client:   transport.Client(),
+				Location: tt.location,
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("GetObservationWithClient() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			if !result.ObservedAt.Equal(tt.expected.ObservedAt) {
+				t.Errorf("ObservedAt = %v, want %v", result.ObservedAt, tt.expected.ObservedAt)
+			}
+			if result.DistanceKm < 0 || 100 < result.DistanceKm {
+				t.Errorf("DistanceKm = %f, want within 100km", result.DistanceKm)
+			}
+			result.ObservedAt, result.DistanceKm = tt.expected.ObservedAt, tt.expected.DistanceKm
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("GetObservationWithClient() diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestFillTemplateData(t *testing.T) {
+	observation := &amedas.Observation{
+		Station:         amedas.Station{Code: "44132", Name: "東京", EnName: "Tokyo"},
+		ObservedAt:      time.Date(2026, 1, 2, 6, 0, 0, 0, time.UTC),
+		Temperature:     float64Ptr(12.3),
+		WindDirection:   intPtr(15),
+		WindSpeed:       float64Ptr(3.2),
+		Precipitation1h: float64Ptr(0),
+	}
+
+	tests := []struct {
+		name     string
+		locale   i18n.Locale
+		expected string
+	}{
+		{
+			name:   "日本語",
+			locale: i18n.LocaleJa,
+			expected: "🌡 大手町 に最も近いアメダス 東京 の 2026/01/02 15:00 の観測値だっぽ\n" +
+				"気温: 12.3℃\n湿度: ---%\n風: 北北西 3.2m/s\n降水量（前1時間）: 0.0mm",
+		},
+		{
+			name:   "英語",
+			locale: i18n.LocaleEn,
+			expected: "🌡 Nearest AMeDAS station to 大手町: Tokyo (as of 2026/01/02 15:00)\n" +
+				"Temperature: 12.3°C\nHumidity: ---%\nWind: NNW 3.2m/s\nPrecipitation (1h): 0.0mm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data := &i18n.TemplateData{Locale: tt.locale, PlaceName: "大手町"}
+			observation.FillTemplateData(data)
+
+			var templates *i18n.Templates
+			if result := templates.Render(i18n.KeyAmedasSuccess, data); result != tt.expected {
+				t.Errorf("Render() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAmedasCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected amedas.ParseAmedasCommandResult
+	}{
+		{
+			name:     "シンプルなamedasコマンド",
+			input:    "amedas 東京",
+			expected: amedas.ParseAmedasCommandResult{Place: "東京", IsAmedas: true},
+		},
+		{
+			name:     "場所無しのamedasコマンドは東京がデフォルト",
+			input:    "@bot amedas",
+			expected: amedas.ParseAmedasCommandResult{Place: "東京", IsAmedas: true},
+		},
+		{
+			name:     "ハッシュタグのamedasコマンド",
+			input:    "#amedas 新宿 駅",
+			expected: amedas.ParseAmedasCommandResult{Place: "新宿 駅", IsAmedas: true},
+		},
+		{
+			name:     "ameshコマンド",
+			input:    "amesh 東京",
+			expected: amedas.ParseAmedasCommandResult{Place: "", IsAmedas: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := amedas.ParseAmedasCommand(tt.input)
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseAmedasCommand(%q) diff: %s", tt.input, diff)
+			}
+		})
+	}
+}
+
+func TestCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{
+			name:     "通常のエラー",
+			err:      errors.New("something wrong"),
+			expected: i18n.KeyErrorAmedasCommand,
+		},
+		{
+			name:     "近くに観測所がない",
+			err:      errors.Wrap(amedas.ErrNoStationFound, "Failed to GetObservation"),
+			expected: i18n.KeyErrorNoAmedasStation,
+		},
+		{
+			name:     "サーキットブレーカーが開いている",
+			err:      errors.Wrap(httpclient.ErrCircuitOpen, "Failed to Do"),
+			expected: i18n.KeyErrorUpstreamUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := amedas.CommandErrorKey(tt.err); result != tt.expected {
+				t.Errorf("CommandErrorKey() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
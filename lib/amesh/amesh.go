@@ -7,22 +7,24 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
-	"image/png"
-	"io"
-	"log"
 	"math"
+	"math/rand/v2"
 	"net/http"
-	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/cockroachdb/errors"
 	"golang.org/x/exp/constraints"
 
 	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock"
 	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
 )
 
 // エラー定数
@@ -30,28 +32,84 @@ var (
 	ErrNoResultsFound           = errors.New("no results found for place")
 	ErrInvalidCoordinatesFormat = errors.New("invalid coordinates format")
 	ErrJSONUnmarshal            = errors.New("failed to json.Unmarshal")
+	ErrNoRadarData              = errors.New("no radar timestamp available")
 )
 
-// CreateAmeshImageParams レーダー画像作成のリクエスト構造体
-type CreateAmeshImageParams struct {
-	Client      *http.Client // HTTPクライアント
-	Lat         float64      // 緯度
-	Lng         float64      // 経度
-	Zoom        int          // ズームレベル
-	AroundTiles int          // 周囲のタイル数
-}
+// NoRadarDataMode レーダーのタイムスタンプが取得できなかった場合の動作
+type NoRadarDataMode int
+
+const (
+	// NoRadarDataBanner ベースマップのみを描画し「レーダーデータ取得失敗」のバナーを重ねる
+	NoRadarDataBanner NoRadarDataMode = iota
+	// NoRadarDataFail ErrNoRadarDataを返して描画しない
+	NoRadarDataFail
+)
+
+// NoRadarDataMessage レーダーデータが取得できなかったことを利用者に伝えるメッセージ
+const NoRadarDataMessage = "レーダーデータ取得失敗"
+
+// noRadarDataBannerText レーダーデータが取得できなかった場合に画像上部に表示する文言
+// 埋め込みフォントは英数字のみ対応のため英語で表記する
+const noRadarDataBannerText = "NO RADAR DATA"
 
-// CreateImageBufferWithClientParams amesh画像リーダー作成のリクエスト構造体
-type CreateImageBufferWithClientParams struct {
-	Client   *http.Client // HTTPクライアント
-	Location *Location    // 位置情報
+// circleRadiiKm ameshの標準のレイヤー構成で描画する距離円の半径（キロメートル、内側から順）
+var circleRadiiKm = []float64{10, 20, 30, 40, 50}
+
+// defaultClient クライアント未指定時に使うHTTPクライアント
+// 外部サービスが不調な場合はサーキットブレーカーで即座に失敗させる
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
 }
 
+// defaultTimestampCache クライアント未指定時に使うtargetTimesのキャッシュ
+// 短時間に続けてコマンドが来た場合にタイムスタンプの取得を省略する
+var defaultTimestampCache = httpclient.NewResponseCache(&httpclient.ResponseCacheSetting{
+	Name: "targettimes",
+	TTL:  30 * time.Second,
+})
+
+// CreateAmeshImageParams レーダー画像作成のリクエスト構造体
+type CreateAmeshImageParams struct {
+	Client         httpclient.Doer              // HTTPクライアント
+	Lat            float64                      // 緯度
+	Lng            float64                      // 経度
+	Zoom           int                          // ズームレベル
+	AroundTiles    int                          // 周囲のタイル数
+	TimestampCache *httpclient.ResponseCache    // targetTimesのキャッシュ（nilの場合はキャッシュしない）
+	NoRadarData    NoRadarDataMode              // レーダーのタイムスタンプが取得できなかった場合の動作
+	StaleThreshold time.Duration                // 雨雲レーダーのデータが古いとみなす経過時間（0の場合は確認しない）
+	Clock          clock.Clock                  // 雨雲レーダーの経過時間の確認に使う時計（nilの場合はclock.Real）
+	Overlays       []OverlayName                // ベースマップに重ねるレイヤー（nilの場合はDefaultOverlays）
+	OverlayStyles  map[OverlayName]OverlayStyle // オーバーレイのレイヤーごとの描画方法（nilの場合は各レイヤーの既定値）
+	MapStyle       MapStyle                     // ベースマップのスタイル（空の場合はMapStyleLight）
+	Layers         []Layer                      // 描画するレイヤー（nilの場合はDefaultLayersで作成する）
+}
+
+// LocationImageParams 1か所の地点の画像を作成する関数（CreateImageReaderWithClientなど）のパラメータ
+type LocationImageParams struct {
+	Client         httpclient.Doer              // HTTPクライアント
+	Location       *Location                    // 位置情報
+	TimestampCache *httpclient.ResponseCache    // targetTimesのキャッシュ（nilの場合はキャッシュしない）
+	Overlays       []OverlayName                // ベースマップに重ねるレイヤー（nilの場合はDefaultOverlays）
+	OverlayStyles  map[OverlayName]OverlayStyle // オーバーレイのレイヤーごとの描画方法（nilの場合は各レイヤーの既定値）
+	MapStyle       MapStyle                     // ベースマップのスタイル（空の場合はMapStyleLight）
+	Zoom           int                          // ズームレベル（0の場合は位置情報に合わせて選択する）
+	StaleThreshold time.Duration                // 雨雲レーダーのデータが古いとみなす経過時間（0の場合は確認しない）
+	Clock          clock.Clock                  // 雨雲レーダーの経過時間の確認に使う時計（nilの場合はclock.Real）
+}
+
+// CreateImageBufferWithClientParams LocationImageParamsの以前の名前
+//
+// Deprecated: LocationImageParamsを使う
+type CreateImageBufferWithClientParams = LocationImageParams
+
 // Location 位置情報の構造体
 type Location struct {
-	Lat       float64 // 緯度
-	Lng       float64 // 経度
-	PlaceName string  // 地名
+	Lat          float64      // 緯度
+	Lng          float64      // 経度
+	PlaceName    string       // 地名
+	BoundingBox  *BoundingBox // 地名が表す範囲（不明な場合はnil）
+	AddressLevel AddressLevel // 住所のマッチングレベル（不明な場合は0）
 }
 
 // GeocodeRequest ジオコーディングのリクエスト構造体
@@ -60,16 +118,27 @@ type GeocodeRequest struct {
 	APIKey string // APIキー
 }
 
-// ParseLocationWithClientParams 位置解析のリクエスト構造体
-type ParseLocationWithClientParams struct {
-	Client         *http.Client // HTTPクライアント
+// ParseLocationParams 地名を解析する関数（ParseLocationWithClient・ParseLocationsWithClient）のパラメータ
+type ParseLocationParams struct {
+	Client         httpclient.Doer // HTTPクライアント（Geocoderを指定した場合は省略できる）
 	GeocodeRequest GeocodeRequest
+	Geocoder       Geocoder // 地名を探すジオコーダ（nilの場合はAPIキーがあればYahoo!ジオコーダ）
 }
 
+// ParseLocationWithClientParams ParseLocationParamsの以前の名前
+//
+// Deprecated: ParseLocationParamsを使う
+type ParseLocationWithClientParams = ParseLocationParams
+
 // ParseAmeshCommandResult ameshコマンドの解析結果を表す構造体
 type ParseAmeshCommandResult struct {
-	Place   string
-	IsAmesh bool
+	Place           string
+	IsAmesh         bool
+	Layer           string        // layer=で指定されたレイヤー名（ParseOverlaysで解析する、未指定の場合は空）
+	Forecast        bool          // forecastが指定された場合は予測のパネルを並べる
+	History         bool          // historyが指定された場合は過去の雨雲レーダーを並べる
+	HistoryDuration time.Duration // historyの後の3hや90mで指定された過去の雨雲レーダーを並べる期間（省略した場合は0）
+	Candidates      []string      // 文中にameshを含む文章から探した地名の候補（可能性の高い順、ameshで始まる場合はnil）
 }
 
 // lightningPoint 落雷データを表す構造体
@@ -79,12 +148,6 @@ type lightningPoint struct {
 	Type int     `json:"type"`
 }
 
-type drawLightningMarkerParams struct {
-	Img                    *image.RGBA
-	Lightning              lightningPoint
-	CreateAmeshImageParams *CreateAmeshImageParams
-}
-
 type drawLineParams struct {
 	Img *image.RGBA
 	X1  int
@@ -94,17 +157,11 @@ type drawLineParams struct {
 	Col color.RGBA
 }
 
-type drawDistanceCircleParams struct {
-	Img                    *image.RGBA
-	CreateAmeshImageParams *CreateAmeshImageParams
-	RadiusKm               float64
-	Col                    color.RGBA
-}
-
 // calcCirclePointParams 円上の点を計算するためのパラメータ
 type calcCirclePointParams struct {
-	Params *drawDistanceCircleParams
-	Angle  float64 // 角度（ラジアン）
+	Viewport *Viewport
+	RadiusKm float64 // 円の半径（キロメートル）
+	Angle    float64 // 角度（ラジアン）
 }
 
 // calcCirclePointResult 円上の点の計算結果
@@ -114,11 +171,12 @@ type calcCirclePointResult struct {
 }
 
 // calcCirclePoint 地球の曲率を考慮して円上の点を計算する
-func calcCirclePoint(params *calcCirclePointParams) *calcCirclePointResult {
+// 線分ごとに呼ばれるため、ヒープに確保しないよう値で返す
+func calcCirclePoint(params *calcCirclePointParams) calcCirclePointResult {
 	earthRadius := 6371.0 // 地球半径（キロメートル）
-	return &calcCirclePointResult{
-		Lat: params.Params.CreateAmeshImageParams.Lat + (params.Params.RadiusKm/earthRadius)*math.Cos(params.Angle)*180/math.Pi,
-		Lng: params.Params.CreateAmeshImageParams.Lng + (params.Params.RadiusKm/earthRadius)*math.Sin(params.Angle)*180/math.Pi/math.Cos(deg2rad(params.Params.CreateAmeshImageParams.Lat)),
+	return calcCirclePointResult{
+		Lat: params.Viewport.Lat + (params.RadiusKm/earthRadius)*math.Cos(params.Angle)*180/math.Pi,
+		Lng: params.Viewport.Lng + (params.RadiusKm/earthRadius)*math.Sin(params.Angle)*180/math.Pi/math.Cos(deg2rad(params.Viewport.Lat)),
 	}
 }
 
@@ -128,283 +186,469 @@ type httpRequestResult struct {
 	IsEmpty bool
 }
 
-// timeJSONElement targetTimes JSON要素の構造体
-type timeJSONElement struct {
-	BaseTime  string   `json:"basetime"`
-	ValidTime string   `json:"validtime"`
-	Elements  []string `json:"elements"`
+// TimestampCacheStats デフォルトのtargetTimesキャッシュの利用状況を返す
+func TimestampCacheStats() httpclient.CacheStats {
+	return defaultTimestampCache.Stats()
 }
 
 // CreateAmeshImage ameshレーダー画像を作成する
-func CreateAmeshImage(ctx context.Context, params *CreateAmeshImageParams) (*image.RGBA, error) {
+// params.Layersが指定されていない場合はDefaultLayersのレイヤーを描画する
+// 一部のタイルが取得できなかった場合も画像を返し、取得できなかったタイル数を結果に含める
+func CreateAmeshImage(ctx context.Context, params *CreateAmeshImageParams) (*AmeshResult, error) {
 	if params == nil || params.Client == nil {
 		return nil, lib.ErrParamsNil
 	}
-	// 最新のタイムスタンプを取得
-	timestamps := getLatestTimestamps(ctx, params.Client)
-
-	hrpnsTimestamp := timestamps["hrpns_nd"]
-	lidenTimestamp := timestamps["liden"]
+	if err := validateMapParams(params); err != nil {
+		return nil, errors.Wrap(err, "Failed to validateMapParams")
+	}
 
-	// 落雷データを取得
-	lightningData, err := getLightningData(ctx, params.Client, lidenTimestamp)
-	if err != nil {
-		log.Printf("落雷データの取得に失敗: %v", err)
-		lightningData = nil
-	}
-
-	// ピクセル座標を計算
-	centerX, centerY := getWebMercatorPixel(params)
-	centerTileX, centerTileY := int(centerX/256), int(centerY/256)
-
-	// ベース画像を作成
-	imageSize := (2*params.AroundTiles + 1) * 256
-	img := image.NewRGBA(image.Rect(0, 0, imageSize, imageSize))
-
-	// 白い背景で塗りつぶし
-	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 255, G: 255, B: 255, A: 255}), image.Point{}, draw.Src)
-
-	// タイルをダウンロードして合成
-	for dy := -params.AroundTiles; dy <= params.AroundTiles; dy++ {
-		for dx := -params.AroundTiles; dx <= params.AroundTiles; dx++ {
-			tileX := centerTileX + dx
-			tileY := centerTileY + dy
-
-			// ベースマップタイル（OpenStreetMap）をダウンロード
-			baseURL := fmt.Sprintf("https://tile.openstreetmap.org/%d/%d/%d.png", params.Zoom, tileX, tileY)
-
-			baseTile, err := downloadTile(ctx, params.Client, baseURL)
-			if err != nil {
-				log.Printf("Failed to downloadTile: %v", err)
-				continue
-			}
-
-			// ベースタイルを描画
-			destRect := image.Rect(
-				(dx+params.AroundTiles)*256,
-				(dy+params.AroundTiles)*256,
-				(dx+params.AroundTiles+1)*256,
-				(dy+params.AroundTiles+1)*256,
-			)
-			draw.Draw(img, destRect, baseTile, image.Point{}, draw.Over)
-
-			// レーダータイルをダウンロードしてオーバーレイ
-			radarURL := fmt.Sprintf(
-				"https://www.jma.go.jp/bosai/jmatile/data/nowc/%s/none/%s/surf/hrpns/%d/%d/%d.png",
-				hrpnsTimestamp,
-				hrpnsTimestamp,
-				params.Zoom,
-				tileX,
-				tileY,
-			)
-			radarTile, err := downloadTile(ctx, params.Client, radarURL)
-			if err != nil {
-				log.Printf("Failed to downloadTile: %v", err)
-				continue
-			}
-
-			// レーダータイルを透明度付きで描画
-			draw.DrawMask(
-				img,
-				destRect,
-				radarTile,
-				image.Point{},
-				image.NewUniform(color.RGBA{R: 255, G: 255, B: 255, A: 128}),
-				image.Point{},
-				draw.Over,
-			)
+	layers := params.Layers
+	if layers == nil {
+		var err error
+		layers, err = DefaultLayers(ctx, params)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to DefaultLayers")
 		}
 	}
 
-	// 距離円を描画
-	for d := 10; d <= 50; d += 10 {
-		drawDistanceCircle(
-			&drawDistanceCircleParams{
-				Img:                    img,
-				CreateAmeshImageParams: params,
-				RadiusKm:               float64(d),
-				Col:                    color.RGBA{R: 100, G: 100, B: 100, A: 255},
-			})
+	viewport := &Viewport{
+		Lat:         params.Lat,
+		Lng:         params.Lng,
+		Zoom:        params.Zoom,
+		AroundTiles: params.AroundTiles,
 	}
+	rendered := RenderLayers(ctx, viewport, layers)
 
-	// 落雷マーカーを描画
-	for _, lightning := range lightningData {
-		drawLightningMarker(&drawLightningMarkerParams{
-			Img:                    img,
-			Lightning:              lightning,
-			CreateAmeshImageParams: params,
-		})
+	result := &AmeshResult{
+		Image:         rendered.Image,
+		AmeshMetadata: rendered.metadata(layers),
 	}
-
-	return img, nil
+	result.BoundingBox = viewport.BoundingBox()
+	return result, nil
 }
 
-// CreateImageBufferWithClient HTTPクライアントを指定してamesh画像をメモリ上に作成してbytes.Bufferを返す
-func CreateImageBufferWithClient(ctx context.Context, params *CreateImageBufferWithClientParams) (*bytes.Buffer, error) {
-	if params == nil || params.Client == nil || params.Location == nil {
+// DefaultLayers ameshの標準のレイヤー構成を作成する
+// ベースマップ・オーバーレイ・距離円・落雷マーカーの順に重ね、レーダーデータがない場合や古い場合はバナーを追加する
+func DefaultLayers(ctx context.Context, params *CreateAmeshImageParams) ([]Layer, error) {
+	if params == nil || params.Client == nil {
 		return nil, lib.ErrParamsNil
 	}
-	img, err := CreateAmeshImage(ctx, &CreateAmeshImageParams{
-		Client:      params.Client,
-		Lat:         params.Location.Lat,
-		Lng:         params.Location.Lng,
-		Zoom:        10,
-		AroundTiles: 2,
+	return defaultLayers(ctx, params, getLatestTimestampsWithLog(ctx, params))
+}
+
+// getLatestTimestampsWithLog 最新のタイムスタンプを取得し、取得に失敗したURLをログに出力する
+func getLatestTimestampsWithLog(ctx context.Context, params *CreateAmeshImageParams) *LatestTimestampsResult {
+	// Clientはnilでないことを確認済みのため、エラーは返らない
+	timestamps, _ := LatestTimestampsWithClient(ctx, params.timestampsParams())
+	for _, failed := range timestamps.FailedSources {
+		requestid.Logf(ctx, "Failed to fetchTimeData: %s: %v", failed.URL, failed.Err)
+	}
+	return timestamps
+}
+
+// defaultLayers 取得済みのタイムスタンプからameshの標準のレイヤー構成を作成する
+func defaultLayers(ctx context.Context, params *CreateAmeshImageParams, timestamps *LatestTimestampsResult) ([]Layer, error) {
+	hrpnsTimestamp := formatJMATimestamp(timestamps.BaseTime(ElementRadar))
+	lidenTimestamp := formatJMATimestamp(timestamps.BaseTime(ElementLightning))
+
+	// レーダーのタイムスタンプがなければ存在しないタイルを取得しに行かない
+	noRadarData := slices.Contains(params.overlays(), OverlayRadar) && hrpnsTimestamp == ""
+	if noRadarData {
+		if params.NoRadarData == NoRadarDataFail {
+			return nil, ErrNoRadarData
+		}
+		requestid.Logf(ctx, "Rendering without radar: %v", ErrNoRadarData)
+	}
+
+	layers := []Layer{&BaseMapLayer{Client: params.Client, Dark: params.darkMap()}}
+	layers = append(layers, newOverlayLayers(ctx, params, hrpnsTimestamp)...)
+	layers = append(layers, &CircleLayer{
+		RadiiKm: circleRadiiKm,
+		Color:   color.RGBA{R: 100, G: 100, B: 100, A: 255},
 	})
+	if lidenTimestamp != "" {
+		layers = append(layers, &LightningLayer{Client: params.Client, Timestamp: lidenTimestamp})
+	}
+
+	// レーダーデータがない場合や古い場合はその旨を画像上に明示する
+	if noRadarData {
+		layers = append(layers, &BannerLayer{Text: noRadarDataBannerText})
+	}
+	if findRadarLayer(layers) != nil {
+		if banner := staleBannerLayer(ctx, params, hrpnsTimestamp); banner != nil {
+			layers = append(layers, banner)
+		}
+	}
+	return layers, nil
+}
+
+// CreateImageBufferWithClient HTTPクライアントを指定してamesh画像をメモリ上に作成してbytes.Bufferを返す
+func CreateImageBufferWithClient(ctx context.Context, params *LocationImageParams) (*bytes.Buffer, error) {
+	result, err := createLocationImage(ctx, params)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to CreateAmeshImage")
+		return nil, errors.Wrap(err, "Failed to createLocationImage")
 	}
+	// エンコード後は画像を使わないため次のリクエストで使い回す
+	defer putCanvas(result.Image)
 
 	// バイトバッファに画像をエンコード
-	buf := &bytes.Buffer{}
-	if err := png.Encode(buf, img); err != nil {
-		return nil, errors.Wrap(err, "Failed to png.Encode")
+	buf, err := encodePNG(result.Image, result.PNGText())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to encodePNG")
 	}
 
 	return buf, nil
 }
 
-// CreateImageReader amesh画像をメモリ上に作成してio.Readerを返す
-func CreateImageReader(ctx context.Context, location *Location) (io.Reader, error) {
-	return CreateImageBuffer(ctx, location)
+// CreateImageReaderWithClient HTTPクライアントを指定してamesh画像を作成し、PNG形式にエンコードしながら読み出すImageReaderを返す
+// エンコード済みの画像全体をメモリ上に保持しないため、アップロードなどにそのまま流し込める
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateImageReaderWithClient(ctx context.Context, params *LocationImageParams) (*ImageReader, error) {
+	result, err := createLocationImage(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to createLocationImage")
+	}
+	return result.Reader(), nil
+}
+
+// createLocationImage 位置情報に合わせたズームレベルでamesh画像を作成する
+func createLocationImage(ctx context.Context, params *LocationImageParams) (*AmeshResult, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+	result, err := CreateAmeshImage(ctx, locationImageParams(params))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to CreateAmeshImage")
+	}
+	result.Locations = []Location{*params.Location}
+	logTileStats(ctx, result.Tiles)
+	return result, nil
+}
+
+// locationImageParams 位置情報に合わせたズームレベルで画像を作成するパラメータを返す
+func locationImageParams(params *LocationImageParams) *CreateAmeshImageParams {
+	// ズームレベルの指定がなければ地名の範囲に合わせて選ぶ
+	view := SelectMapView(params.Location)
+	if params.Zoom != 0 {
+		view = MapView{Zoom: params.Zoom, AroundTiles: aroundTilesFor(params.Zoom)}
+	}
+	return &CreateAmeshImageParams{
+		Client:         params.Client,
+		Lat:            params.Location.Lat,
+		Lng:            params.Location.Lng,
+		Zoom:           view.Zoom,
+		AroundTiles:    view.AroundTiles,
+		TimestampCache: params.TimestampCache,
+		StaleThreshold: params.StaleThreshold,
+		OverlayStyles:  params.OverlayStyles,
+		MapStyle:       params.MapStyle,
+		Clock:          params.Clock,
+		Overlays:       params.Overlays,
+	}
+}
+
+// logTileStats 取得できなかったタイルがある場合にログに出力する
+func logTileStats(ctx context.Context, stats TileStats) {
+	if stats.Failed == 0 {
+		return
+	}
+	requestid.Logf(ctx, "Rendered partially: %d of %d tiles failed (%d radar tiles without data)\n",
+		stats.Failed, stats.Fetched+stats.Failed, stats.NoData)
+}
+
+// CreateImageReader amesh画像を作成し、PNG形式にエンコードしながら読み出すImageReaderを返す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateImageReader(ctx context.Context, location *Location) (*ImageReader, error) {
+	return CreateImageReaderWithClient(ctx, &LocationImageParams{
+		Client:         defaultClient,
+		Location:       location,
+		TimestampCache: defaultTimestampCache,
+		StaleThreshold: getStaleThreshold(),
+		OverlayStyles:  getOverlayStyles(),
+		MapStyle:       getMapStyle(),
+	})
 }
 
 // CreateImageBuffer amesh画像をメモリ上に作成してbytes.Bufferを返す
 func CreateImageBuffer(ctx context.Context, location *Location) (*bytes.Buffer, error) {
-	return CreateImageBufferWithClient(ctx, &CreateImageBufferWithClientParams{
-		Client:   http.DefaultClient,
-		Location: location,
+	return CreateImageBufferWithOverlays(ctx, location, nil)
+}
+
+// CreateImageBufferWithOverlays 重ねるレイヤーを指定してamesh画像をメモリ上に作成してbytes.Bufferを返す
+func CreateImageBufferWithOverlays(ctx context.Context, location *Location, overlays []OverlayName) (*bytes.Buffer, error) {
+	return CreateImageBufferWithClient(ctx, &LocationImageParams{
+		Client:         defaultClient,
+		Location:       location,
+		TimestampCache: defaultTimestampCache,
+		Overlays:       overlays,
+		StaleThreshold: getStaleThreshold(),
+		OverlayStyles:  getOverlayStyles(),
+		MapStyle:       getMapStyle(),
 	})
 }
 
 // ParseLocationWithClient HTTPクライアントを指定して地名文字列から位置を解析し、Location構造体とエラーを返す
-func ParseLocationWithClient(ctx context.Context, req *ParseLocationWithClientParams) (*Location, error) {
-	if req == nil || req.Client == nil {
+func ParseLocationWithClient(ctx context.Context, req *ParseLocationParams) (*Location, error) {
+	if req == nil || (req.Client == nil && req.Geocoder == nil) {
 		return nil, lib.ErrParamsNil
 	}
 	// 座標が直接提供されているかチェック
 	location, err := parseCoordinates(req.GeocodeRequest.Place)
+	if err != nil && !errors.Is(err, errNotCoordinatePair) {
+		// 座標の形式だが範囲外などの場合は地名として扱わない
+		return nil, errors.Wrap(err, "Failed to parseCoordinates")
+	}
 	if err != nil {
 		// 地名をジオコーディング
 		var err2 error
-		location, err2 = geocodePlace(ctx, req)
+		location, err2 = resolvePlace(ctx, req)
 		if err2 != nil {
-			return nil, errors.Wrap(errors.Join(err, err2), "Failed to geocodePlace")
+			return nil, errors.Wrap(errors.Join(err, err2), "Failed to resolvePlace")
 		}
 	}
 
 	return location, nil
 }
 
+// resolvePlace 地名から位置情報を取得する
+// ジオコーダの結果を優先し、ジオコーダがない場合やジオコーダが使えない場合は埋め込みの地名の一覧から探す
+// 一覧にもない場合はジオコーダのエラーを返す
+func resolvePlace(ctx context.Context, req *ParseLocationParams) (*Location, error) {
+	geocoder := req.Geocoder
+	if geocoder == nil && req.GeocodeRequest.APIKey != "" {
+		geocoder = &YahooGeocoder{Client: req.Client, APIKey: req.GeocodeRequest.APIKey}
+	}
+	if geocoder == nil {
+		location, err := lookupGazetteer(req.GeocodeRequest.Place)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to lookupGazetteer")
+		}
+		return location, nil
+	}
+
+	place := req.GeocodeRequest.Place
+	if place == "" {
+		place = "東京"
+	}
+	location, err := geocoder.Geocode(ctx, place)
+	if err == nil {
+		return location, nil
+	}
+	fallback, fallbackErr := lookupGazetteer(req.GeocodeRequest.Place)
+	if fallbackErr != nil {
+		return nil, errors.Wrap(err, "Failed to Geocode")
+	}
+	requestid.Logf(ctx, "Geocoder failed, using embedded gazetteer for %q: %v", req.GeocodeRequest.Place, err)
+	return fallback, nil
+}
+
 // ParseLocation 地名文字列から位置を解析し、Location構造体とエラーを返す
+// SetDefaultGeocoderでジオコーダが設定されている場合はAPIキーより優先する
 func ParseLocation(ctx context.Context, place, apiKey string) (*Location, error) {
-	return ParseLocationWithClient(ctx, &ParseLocationWithClientParams{
-		Client: http.DefaultClient,
+	return ParseLocationWithClient(ctx, &ParseLocationParams{
+		Client: defaultClient,
 		GeocodeRequest: GeocodeRequest{
 			Place:  place,
 			APIKey: apiKey,
 		},
+		Geocoder: getDefaultGeocoder(),
 	})
 }
 
-// ParseLocationWithLog 地名文字列から位置を解析してログに出力する
-func ParseLocationWithLog(ctx context.Context, place, apiKey string) (*Location, error) {
-	location, err := ParseLocation(ctx, place, apiKey)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to ParseLocation")
-	}
+// MaxFileNamePlaceBytes ファイル名に含める地名の最大バイト数
+// 接頭辞・タイムスタンプ・接尾辞を含めてもファイル名の上限（多くのファイルシステムで255バイト）に収まる長さ
+const MaxFileNamePlaceBytes = 100
 
-	log.Printf("Generating amesh image for %s (%.4f, %.4f)\n", location.PlaceName, location.Lat, location.Lng)
-	return location, nil
+// fileNameReplacer ファイル名に使えない文字（パスの区切りとWindowsで使えない記号）を置き換える
+var fileNameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_",
+)
+
+// fileNameTimeLayout ファイル名に含める日本時間の時刻の書式（例: 2026-10-16_1205JST）
+const fileNameTimeLayout = "2006-01-02_1504MST"
+
+// GenerateFileNameParams GenerateFileNameのパラメータ
+type GenerateFileNameParams struct {
+	Locations []*Location   // 画像の地点（比較画像の場合は複数）
+	DataTime  time.Time     // 画像のデータの時刻（雨雲レーダーのbasetime、ゼロ値の場合は現在時刻）
+	Clock     clock.Clock   // 現在時刻を返す時計（nilの場合はclock.Real）
+	Suffix    func() uint32 // 接尾辞の乱数を返す関数（nilの場合はrand.Uint32）
+	Prefix    string        // ファイル名の接頭辞（空の場合はamesh）
 }
 
-// GenerateFileName 位置情報からamesh画像のファイル名を生成する
-func GenerateFileName(location *Location) string {
+// GenerateFileName 位置情報からamesh画像のファイル名を生成する（例: amesh_東京_2026-10-16_1205JST_0123abcd.png）
+// 時刻は画像のデータの時刻を日本時間で表し、地名はファイル名に使えない文字を置き換えてMaxFileNamePlaceBytesまでに切り詰める
+// 同じ時刻の同じ地名の画像と重ならないよう乱数の接尾辞を付ける
+func GenerateFileName(params *GenerateFileNameParams) string {
+	names := make([]string, 0, len(params.Locations))
+	for _, location := range params.Locations {
+		names = append(names, location.PlaceName)
+	}
+
+	dataTime := params.DataTime
+	if dataTime.IsZero() {
+		dataTime = clock.Or(params.Clock).Now()
+	}
+	suffix := params.Suffix
+	if suffix == nil {
+		suffix = rand.Uint32
+	}
+	prefix := params.Prefix
+	if prefix == "" {
+		prefix = "amesh"
+	}
+
 	return fmt.Sprintf(
-		"amesh_%s_%d.png",
-		strings.ReplaceAll(location.PlaceName, " ", "_"),
-		time.Now().Unix(),
+		"%s_%s_%s_%08x.png",
+		prefix,
+		sanitizeFileNamePart(strings.Join(names, "_"), MaxFileNamePlaceBytes),
+		dataTime.In(jst).Format(fileNameTimeLayout),
+		suffix(),
 	)
 }
 
-// ParseAmeshCommand ameshコマンドを解析
-func ParseAmeshCommand(text string) ParseAmeshCommandResult {
-	// メンションを除去
-	text = strings.TrimSpace(text)
-
-	// @username を削除
-	words := strings.Fields(text)
-	var cleanWords []string
-	for _, word := range words {
-		if !strings.HasPrefix(word, "@") {
-			cleanWords = append(cleanWords, word)
+// sanitizeFileNamePart 文字列をファイル名の一部に使える形にする
+// 空白・制御文字・ファイル名に使えない文字は_に置き換え、UTF-8の文字の途中で切らないようmaxBytesまでに切り詰める
+func sanitizeFileNamePart(s string, maxBytes int) string {
+	s = fileNameReplacer.Replace(strings.ToValidUTF8(s, "_"))
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return '_'
 		}
+		return r
+	}, s)
+	for maxBytes < len(s) {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+// CommandErrorKey コマンド処理のエラーに応じた返信メッセージのキーを返す
+// 雨雲レーダーや地名検索に使う外部サービスのサーキットブレーカーが開いている場合は、停止中の外部サービスを伝える
+// ブレーカーが閉じると次のコマンドから通常どおり処理する
+func CommandErrorKey(err error) i18n.Key {
+	var circuitErr *httpclient.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		switch circuitErr.Upstream {
+		case httpclient.UpstreamJMA, httpclient.UpstreamYahooGeocoder, httpclient.UpstreamNominatim:
+			return i18n.KeyErrorUpstreamOutage
+		}
+	}
+	if errors.Is(err, httpclient.ErrCircuitOpen) {
+		return i18n.KeyErrorUpstreamUnavailable
+	}
+	if errors.Is(err, ErrNoRadarData) {
+		return i18n.KeyErrorNoRadarData
+	}
+	if errors.Is(err, ErrUnknownOverlay) {
+		return i18n.KeyErrorUnknownLayer
+	}
+	if errors.Is(err, ErrTooManyPlaces) {
+		return i18n.KeyErrorTooManyPlaces
+	}
+	if errors.Is(err, ErrNoForecastData) {
+		return i18n.KeyErrorNoForecastData
 	}
-	text = strings.Join(cleanWords, " ")
+	if errors.Is(err, ErrForecastComparison) {
+		return i18n.KeyErrorForecastComparison
+	}
+	if errors.Is(err, ErrNoHistoryData) {
+		return i18n.KeyErrorNoHistoryData
+	}
+	if errors.Is(err, ErrHistoryComparison) {
+		return i18n.KeyErrorHistoryComparison
+	}
+	if errors.Is(err, ErrInvalidHistoryDuration) {
+		return i18n.KeyErrorHistoryDuration
+	}
+	return i18n.KeyErrorCommand
+}
 
-	// ameshコマンドかチェック
-	if place, ok := strings.CutPrefix(text, "amesh "); ok {
-		return ParseAmeshCommandResult{
-			Place:   strings.TrimSpace(place),
-			IsAmesh: true,
+// IsInputError 利用者の入力が原因のエラーかを返す
+// 外部サービスの障害と区別し、連続した失敗を数える対象から除くために使う
+func IsInputError(err error) bool {
+	for _, target := range []error{
+		ErrNoResultsFound,
+		ErrInvalidCoordinatesFormat,
+		ErrCoordinateOutOfRange,
+		ErrInvalidZoom,
+		ErrUnknownOverlay,
+		ErrTooManyPlaces,
+		ErrForecastComparison,
+		ErrHistoryComparison,
+		ErrInvalidHistoryDuration,
+	} {
+		if errors.Is(err, target) {
+			return true
 		}
 	}
+	return false
+}
 
-	if text == "amesh" {
+// ParseAmeshCommand ameshコマンドを解析
+// 「amesh 地名」の形式でない場合は、「今日の渋谷は雨かな？ amesh」のように文中にameshを含む文章から地名の候補を探す
+// 解析の前にlib.NormalizeMessageで表記ゆれとメンション・MFMの装飾を取り除く
+func ParseAmeshCommand(text string) ParseAmeshCommandResult {
+	// スマートフォンのIMEで入力した全角の英数字やMFMの装飾を含むメッセージもコマンドとして扱う
+	text = lib.NormalizeMessage(text)
+	parsed := lib.ParseCommand(text, "amesh")
+	if !parsed.Matched {
+		if result, ok := parseFreeForm(text); ok {
+			return result
+		}
 		return ParseAmeshCommandResult{
-			Place:   "東京", // デフォルトの場所
-			IsAmesh: true,
+			Place:   "",
+			IsAmesh: false,
 		}
 	}
 
-	return ParseAmeshCommandResult{
-		Place:   "",
-		IsAmesh: false,
-	}
-}
-
-// parseCoordinates 文字列から座標を直接解析する
-func parseCoordinates(place string) (*Location, error) {
-	parts := strings.Fields(place)
-	if len(parts) != 2 {
-		return nil, errors.New("not a coordinate pair")
+	// layer=で始まる単語はレイヤーの指定、forecastは予測の指定、historyとその期間（3hや90mなど）は過去の雨雲レーダーの指定として地名から除く
+	words := strings.Fields(parsed.Args)
+	history := slices.ContainsFunc(words, func(word string) bool { return strings.EqualFold(word, historyWord) })
+	var historyDuration time.Duration
+	var placeWords []string
+	layer := ""
+	forecast := false
+	for _, word := range words {
+		if value, ok := strings.CutPrefix(word, "layer="); ok {
+			layer = value
+			continue
+		}
+		if strings.EqualFold(word, forecastWord) {
+			forecast = true
+			continue
+		}
+		if strings.EqualFold(word, historyWord) {
+			continue
+		}
+		if duration, err := time.ParseDuration(word); err == nil && history {
+			historyDuration = duration
+			continue
+		}
+		placeWords = append(placeWords, word)
 	}
 
-	parsedLat, err := parseFloat64(parts[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to parseFloat64")
+	place := strings.Join(placeWords, " ")
+	if place == "" {
+		place = "東京" // デフォルトの場所
 	}
-
-	parsedLng, err := parseFloat64(parts[1])
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to parseFloat64")
+	return ParseAmeshCommandResult{
+		Place:           place,
+		IsAmesh:         true,
+		Layer:           layer,
+		Forecast:        forecast,
+		History:         history,
+		HistoryDuration: historyDuration,
 	}
-
-	return &Location{
-		Lat:       parsedLat,
-		Lng:       parsedLng,
-		PlaceName: fmt.Sprintf("%.2f,%.2f", parsedLat, parsedLng),
-	}, nil
 }
 
 // executeAndReadResponse HTTPリクエストを実行してレスポンスボディを読み込む
-func executeAndReadResponse(client *http.Client, req *http.Request) (body []byte, err error) {
-	resp, err := httpclient.ExecuteHTTPRequest(client, req)
+func executeAndReadResponse(client httpclient.Doer, req *http.Request) ([]byte, error) {
+	body, err := httpclient.ExecuteAndReadBody(client, req, httpclient.DefaultMaxResponseBytes)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteHTTPRequest")
+		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
 	}
-
-	defer func(readCloser io.ReadCloser) {
-		if closeErr := readCloser.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-
-	body, err = handleHTTPResponse(resp)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to handleHTTPResponse")
-	}
-
 	return body, nil
 }
 
@@ -415,7 +659,11 @@ func parseGeocodeResponse(body []byte, place string) (*Location, error) {
 			Name     string `json:"Name"`
 			Geometry struct {
 				Coordinates string `json:"Coordinates"`
+				BoundingBox string `json:"BoundingBox"`
 			} `json:"Geometry"`
+			Property struct {
+				AddressMatchingLevel AddressLevel `json:"AddressMatchingLevel"`
+			} `json:"Property"`
 		} `json:"Feature"`
 	}
 
@@ -444,38 +692,14 @@ func parseGeocodeResponse(body []byte, place string) (*Location, error) {
 	}
 
 	return &Location{
-		Lat:       lat,
-		Lng:       lng,
-		PlaceName: feature.Name,
+		Lat:          lat,
+		Lng:          lng,
+		PlaceName:    feature.Name,
+		BoundingBox:  parseBoundingBox(feature.Geometry.BoundingBox),
+		AddressLevel: feature.Property.AddressMatchingLevel,
 	}, nil
 }
 
-// geocodePlace 地名をジオコーディングして位置情報を取得する
-func geocodePlace(ctx context.Context, req *ParseLocationWithClientParams) (*Location, error) {
-	place := req.GeocodeRequest.Place
-	if place == "" {
-		place = "東京"
-	}
-
-	requestURL := fmt.Sprintf(
-		"https://map.yahooapis.jp/geocode/V1/geoCoder?appid=%s&query=%s&output=json",
-		req.GeocodeRequest.APIKey,
-		url.QueryEscape(place),
-	)
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
-	}
-
-	body, err := executeAndReadResponse(req.Client, httpReq)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to executeAndReadResponse")
-	}
-
-	return parseGeocodeResponse(body, place)
-}
-
 // deg2rad 度数をラジアンに変換する
 func deg2rad(degrees float64) float64 {
 	return degrees * math.Pi / 180
@@ -485,8 +709,9 @@ func deg2rad(degrees float64) float64 {
 // - 地理座標（度数）をピクセル座標に変換
 // - ズームレベルに応じたスケール調整
 // - 地図タイルの標準的な座標系を使用
+// - ズームレベルはCreateAmeshImageで検証済みのため、範囲外の場合は(0, 0)を返す
 func getWebMercatorPixel(params *CreateAmeshImageParams) (float64, float64) {
-	if params.Zoom < 0 || 30 < params.Zoom {
+	if params.Zoom < minZoom || maxZoom < params.Zoom {
 		return 0, 0
 	}
 
@@ -498,41 +723,6 @@ func getWebMercatorPixel(params *CreateAmeshImageParams) (float64, float64) {
 	// jscpd:ignore-end
 }
 
-// drawLightningMarker 画像上に落雷マーカーを描画する
-// 円形塗りつぶしアルゴリズム使用
-func drawLightningMarker(params *drawLightningMarkerParams) {
-	// ピクセル座標に変換
-	x, y := getWebMercatorPixel(&CreateAmeshImageParams{
-		Lat:  params.Lightning.Lat,
-		Lng:  params.Lightning.Lng,
-		Zoom: params.CreateAmeshImageParams.Zoom,
-	})
-	centerX, centerY := getWebMercatorPixel(params.CreateAmeshImageParams)
-
-	// 画像座標に変換
-	imageSize := (2*params.CreateAmeshImageParams.AroundTiles + 1) * 256
-	imgX := int(x - centerX + float64(imageSize/2))
-	imgY := int(y - centerY + float64(imageSize/2))
-
-	// 落雷記号を描画（シンプルな円）
-	radius := 7
-	lightningColor := color.RGBA{G: 255, B: 255, A: 255}
-
-	// ピタゴラスの定理による円内判定
-	for dy := -radius; dy <= radius; dy++ {
-		for dx := -radius; dx <= radius; dx++ {
-			if radius*radius < dx*dx+dy*dy {
-				continue
-			}
-			x := imgX + dx
-			y := imgY + dy
-			if 0 <= x && 0 <= y && x < params.Img.Bounds().Dx() && y < params.Img.Bounds().Dy() {
-				params.Img.Set(x, y, lightningColor)
-			}
-		}
-	}
-}
-
 // abs 絶対値を返す
 func abs[T constraints.Signed | constraints.Float](x T) T {
 	if x < 0 {
@@ -562,7 +752,8 @@ func drawLine(params *drawLineParams) {
 
 	for {
 		if 0 <= x && 0 <= y && x < params.Img.Bounds().Dx() && y < params.Img.Bounds().Dy() {
-			params.Img.Set(x, y, params.Col)
+			// Setはcolor.Colorへの変換でピクセルごとにヒープを確保するためSetRGBAを使う
+			params.Img.SetRGBA(x, y, params.Col)
 		}
 
 		if x == params.X2 && y == params.Y2 {
@@ -581,87 +772,8 @@ func drawLine(params *drawLineParams) {
 	}
 }
 
-// drawDistanceCircle 画像上に距離円を描画する
-// 64個の線分で円を近似し、地球の曲率を考慮した地理的距離円を描画
-func drawDistanceCircle(params *drawDistanceCircleParams) {
-	// 線分で円を近似
-	numSegments := 64
-
-	for i := range numSegments {
-		angle1 := float64(i) * 2 * math.Pi / float64(numSegments)
-		angle2 := float64(i+1) * 2 * math.Pi / float64(numSegments)
-
-		// 円上の点を計算（地球の曲率を考慮）
-		point1 := calcCirclePoint(&calcCirclePointParams{
-			Params: params,
-			Angle:  angle1,
-		})
-		point2 := calcCirclePoint(&calcCirclePointParams{
-			Params: params,
-			Angle:  angle2,
-		})
-
-		// ピクセル座標に変換
-		x1, y1 := getWebMercatorPixel(&CreateAmeshImageParams{
-			Lat:  point1.Lat,
-			Lng:  point1.Lng,
-			Zoom: params.CreateAmeshImageParams.Zoom,
-		})
-		x2, y2 := getWebMercatorPixel(&CreateAmeshImageParams{
-			Lat:  point2.Lat,
-			Lng:  point2.Lng,
-			Zoom: params.CreateAmeshImageParams.Zoom,
-		})
-
-		// 画像座標に変換
-		centerX, centerY := getWebMercatorPixel(params.CreateAmeshImageParams)
-		imageSize := (2*params.CreateAmeshImageParams.AroundTiles + 1) * 256
-
-		imgX1 := int(x1 - centerX + float64(imageSize/2))
-		imgY1 := int(y1 - centerY + float64(imageSize/2))
-		imgX2 := int(x2 - centerX + float64(imageSize/2))
-		imgY2 := int(y2 - centerY + float64(imageSize/2))
-
-		// 線分を描画
-		drawLine(&drawLineParams{
-			Img: params.Img,
-			X1:  imgX1,
-			Y1:  imgY1,
-			X2:  imgX2,
-			Y2:  imgY2,
-			Col: params.Col,
-		})
-	}
-}
-
-// downloadTile マップタイルをダウンロードする
-func downloadTile(ctx context.Context, client *http.Client, tileURL string) (img image.Image, err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tileURL, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
-	}
-
-	// jscpd:ignore-start
-	resp, err := httpclient.ExecuteHTTPRequest(client, req)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to ExecuteHTTPRequest")
-	}
-	defer func(body io.ReadCloser) {
-		if closeErr := body.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-	// jscpd:ignore-end
-
-	img, _, err = image.Decode(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to image.Decode")
-	}
-	return img, nil
-}
-
 // makeHTTPRequest HTTPリクエストを送信し、非200ステータスコードの場合は空を返す
-func makeHTTPRequest(ctx context.Context, client *http.Client, url string) (*httpRequestResult, error) {
+func makeHTTPRequest(ctx context.Context, client httpclient.Doer, url string) (*httpRequestResult, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
@@ -680,7 +792,7 @@ func makeHTTPRequest(ctx context.Context, client *http.Client, url string) (*htt
 }
 
 // getLightningData 落雷データを取得する
-func getLightningData(ctx context.Context, client *http.Client, timestamp string) ([]lightningPoint, error) {
+func getLightningData(ctx context.Context, client httpclient.Doer, timestamp string) ([]lightningPoint, error) {
 	apiURL := fmt.Sprintf(
 		"https://www.jma.go.jp/bosai/jmatile/data/nowc/%s/none/%s/surf/liden/data.geojson",
 		timestamp,
@@ -724,74 +836,6 @@ func getLightningData(ctx context.Context, client *http.Client, timestamp string
 	return lightningPoints, nil
 }
 
-// fetchTimeData タイムデータを取得する
-func fetchTimeData(ctx context.Context, client *http.Client, apiURL string) ([]timeJSONElement, error) {
-	body, err := makeHTTPRequest(ctx, client, apiURL)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to makeHTTPRequest")
-	}
-	if body.Body == nil {
-		return nil, errors.New("Body is nil")
-	}
-
-	var timeData []timeJSONElement
-	if err := json.Unmarshal(body.Body, &timeData); err != nil {
-		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
-	}
-
-	return timeData, nil
-}
-
-// getLatestTimestamps 最新のタイムスタンプを取得する
-func getLatestTimestamps(ctx context.Context, client *http.Client) map[string]string {
-	urls := []string{
-		"https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N1.json",
-		"https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N2.json",
-		"https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N3.json",
-	}
-
-	var allTimeData []timeJSONElement
-
-	for _, apiURL := range urls {
-		timeData, err := fetchTimeData(ctx, client, apiURL)
-		if err != nil {
-			log.Printf("Failed to fetchTimeData: %v", err)
-			continue
-		}
-		allTimeData = append(allTimeData, timeData...)
-	}
-
-	// 各要素の最新タイムスタンプを検索
-	result := make(map[string]string)
-	for _, td := range allTimeData {
-		if td.BaseTime != td.ValidTime {
-			continue
-		}
-		for _, element := range td.Elements {
-			if result[element] < td.BaseTime {
-				result[element] = td.BaseTime
-			}
-		}
-	}
-
-	return result
-}
-
-// handleHTTPResponse HTTPレスポンスの共通処理を行う
-func handleHTTPResponse(resp *http.Response) (body []byte, err error) {
-	defer func(body io.ReadCloser) {
-		if closeErr := body.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to io.ReadAll")
-	}
-	return body, nil
-}
-
 // parseFloat64 文字列をfloat64に変換
 func parseFloat64(s string) (float64, error) {
 	return strconv.ParseFloat(s, 64)
@@ -6,16 +6,22 @@ import (
 	"image/color"
 	"image/png"
 	"io"
+	"math"
 	"net/http"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cockroachdb/errors"
 	"github.com/google/go-cmp/cmp"
 
 	"hato-bot-go/lib"
 	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock/clocktest"
 	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
 )
 
 // httpMockConfig モックHTTPクライアントの設定
@@ -251,7 +257,7 @@ func TestCreateAmeshImage(t *testing.T) {
 				return
 			}
 
-			bounds := result.Bounds()
+			bounds := result.Image.Bounds()
 			if bounds.Dx() != tt.expectedImageSize || bounds.Dy() != tt.expectedImageSize {
 				t.Errorf("CreateAmeshImage() image size = %dx%d, want %dx%d",
 					bounds.Dx(), bounds.Dy(), tt.expectedImageSize, tt.expectedImageSize)
@@ -262,7 +268,7 @@ func TestCreateAmeshImage(t *testing.T) {
 				return
 			}
 
-			centerColor := result.RGBAAt(bounds.Dx()/2, bounds.Dy()/2)
+			centerColor := result.Image.RGBAAt(bounds.Dx()/2, bounds.Dy()/2)
 
 			if centerColor.R != 255 || centerColor.G != 255 || centerColor.B != 255 || centerColor.A != 255 {
 				t.Errorf("Expected white center pixel but got R=%d, G=%d, B=%d, A=%d",
@@ -272,6 +278,165 @@ func TestCreateAmeshImage(t *testing.T) {
 	}
 }
 
+// TestCreateAmeshImageNoRadarData レーダーのタイムスタンプが取得できない場合の動作をテストする
+func TestCreateAmeshImageNoRadarData(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		mode         amesh.NoRadarDataMode
+		expectError  error
+		expectBanner bool
+	}{
+		{
+			name:         "バナーを描画してベースマップのみを返す",
+			mode:         amesh.NoRadarDataBanner,
+			expectError:  nil,
+			expectBanner: true,
+		},
+		{
+			name:         "ErrNoRadarDataを返す",
+			mode:         amesh.NoRadarDataFail,
+			expectError:  amesh.ErrNoRadarData,
+			expectBanner: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      transport.Client(),
+				Lat:         35.6895,
+				Lng:         139.6917,
+				Zoom:        10,
+				AroundTiles: 1,
+				NoRadarData: tt.mode,
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("CreateAmeshImage() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			if len(transport.RequestsTo("/surf/")) != 0 {
+				t.Errorf("radar or lightning data requested without timestamp: %v", transport.RequestsTo("/surf/"))
+			}
+
+			if !tt.expectBanner {
+				return
+			}
+			if corner := result.Image.RGBAAt(0, 0); corner != (color.RGBA{R: 200, A: 255}) {
+				t.Errorf("banner pixel = %v, want red", corner)
+			}
+			if center := result.Image.RGBAAt(384, 384); center != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+				t.Errorf("center pixel = %v, want white", center)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageAntimeridian 日付変更線付近のタイルが折り返して取得されることをテストする
+func TestCreateAmeshImageAntimeridian(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		lng           float64
+		expectedTiles []string
+	}{
+		{
+			// アッツ島（東経172.9度）
+			name:          "東側",
+			lng:           172.9,
+			expectedTiles: []string{"/5/30/", "/5/31/", "/5/0/"},
+		},
+		{
+			name:          "西側",
+			lng:           -179.9,
+			expectedTiles: []string{"/5/31/", "/5/0/", "/5/1/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			if _, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      transport.Client(),
+				Lat:         52.9,
+				Lng:         tt.lng,
+				Zoom:        5,
+				AroundTiles: 1,
+			}); err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+
+			for _, tile := range tt.expectedTiles {
+				if len(transport.RequestsTo(tile)) == 0 {
+					t.Errorf("tile %s was not requested", tile)
+				}
+			}
+			if requests := transport.RequestsTo("/-"); len(requests) != 0 {
+				t.Errorf("negative tile index requested: %v", requests)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageInvalidParams 描画できない座標やズームレベルでエラーを返すことをテストする
+func TestCreateAmeshImageInvalidParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		lat         float64
+		lng         float64
+		zoom        int
+		expectError error
+	}{
+		{name: "不正なズームレベル", lat: 35.6895, lng: 139.6917, zoom: 31, expectError: amesh.ErrInvalidZoom},
+		{name: "極付近の緯度", lat: 86, lng: 139.6917, zoom: 10, expectError: amesh.ErrCoordinateOutOfRange},
+		{name: "範囲外の経度", lat: 35.6895, lng: -181, zoom: 10, expectError: amesh.ErrCoordinateOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{})
+
+			_, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      transport.Client(),
+				Lat:         tt.lat,
+				Lng:         tt.lng,
+				Zoom:        tt.zoom,
+				AroundTiles: 1,
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Errorf("CreateAmeshImage() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if len(transport.Requests()) != 0 {
+				t.Errorf("unexpected requests: %d", len(transport.Requests()))
+			}
+		})
+	}
+}
+
 // TestCreateImageBufferWithClient CreateImageBufferWithClient関数をテストする
 func TestCreateImageBufferWithClient(t *testing.T) {
 	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
@@ -293,12 +458,12 @@ func TestCreateImageBufferWithClient(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		params      *amesh.CreateImageBufferWithClientParams
+		params      *amesh.LocationImageParams
 		expectError error
 	}{
 		{
 			name: "成功したbytes.Buffer作成",
-			params: &amesh.CreateImageBufferWithClientParams{
+			params: &amesh.LocationImageParams{
 				Client: client,
 				Location: &amesh.Location{
 					Lat:       35.6895,
@@ -315,7 +480,7 @@ func TestCreateImageBufferWithClient(t *testing.T) {
 		},
 		{
 			name: "nilクライアント",
-			params: &amesh.CreateImageBufferWithClientParams{
+			params: &amesh.LocationImageParams{
 				Client: nil,
 				Location: &amesh.Location{
 					Lat:       35.6895,
@@ -328,7 +493,7 @@ func TestCreateImageBufferWithClient(t *testing.T) {
 		// jscpd:ignore-start
 		{
 			name: "nilロケーション",
-			params: &amesh.CreateImageBufferWithClientParams{
+			params: &amesh.LocationImageParams{
 				Client:   client,
 				Location: nil,
 			},
@@ -370,17 +535,85 @@ func TestCreateImageBufferWithClient(t *testing.T) {
 	// jscpd:ignore-end
 }
 
+// TestCreateImageReaderWithClient エンコードしながら読み出すio.ReadCloserをテストする
+func TestCreateImageReaderWithClient(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := createConfigurableMockHTTPClient(httpMockConfig{
+		TimestampsResponse: `[{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["hrpns_nd", "liden"]}]`,
+		LightningResponse:  `{"features": []}`,
+		DummyTileBytes:     dummyTileBytes,
+	})
+	location := &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京"}
+
+	tests := []struct {
+		name        string
+		params      *amesh.LocationImageParams
+		readAll     bool
+		expectError error
+	}{
+		{
+			name:    "最後まで読み出すとPNGとしてデコードできる",
+			params:  &amesh.LocationImageParams{Client: client, Location: location},
+			readAll: true,
+		},
+		{
+			name:   "読み出す前にCloseしてもエンコードが終了する",
+			params: &amesh.LocationImageParams{Client: client, Location: location},
+		},
+		{
+			name:        "nilリクエスト",
+			params:      nil,
+			expectError: lib.ErrParamsNil,
+		},
+		{
+			name:        "nilロケーション",
+			params:      &amesh.LocationImageParams{Client: client},
+			expectError: lib.ErrParamsNil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reader, err := amesh.CreateImageReaderWithClient(t.Context(), tt.params)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("CreateImageReaderWithClient() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+
+			if tt.readAll {
+				img, err := png.Decode(reader)
+				if err != nil {
+					t.Fatalf("png.Decode() error = %v", err)
+				}
+				if got := img.Bounds().Dx(); got != 1280 {
+					t.Errorf("width = %d, want 1280", got)
+				}
+			}
+			if err := reader.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
 // TestParseLocationWithClient ParseLocationWithClient関数をモックHTTPクライアントでテストする
 func TestParseLocationWithClient(t *testing.T) {
 	tests := []struct {
 		name        string
-		params      *amesh.ParseLocationWithClientParams
+		params      *amesh.ParseLocationParams
 		expectError error
 		expected    *amesh.Location
 	}{
 		{
 			name: "成功したジオコーディング",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -403,9 +636,40 @@ func TestParseLocationWithClient(t *testing.T) {
 				PlaceName: "東京都",
 			},
 		},
+		{
+			name: "範囲とマッチングレベル付きのジオコーディング",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
+				"Feature": [
+					{
+						"Name": "北海道",
+						"Geometry": {
+							"Coordinates": "141.34681,43.06451",
+							"BoundingBox": "139.33,41.35 148.89,45.56"
+						},
+						"Property": {
+							"AddressMatchingLevel": "1"
+						}
+					}
+				]
+			}`),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place:  "北海道",
+					APIKey: "test_key",
+				},
+			},
+			expectError: nil,
+			expected: &amesh.Location{
+				Lat:          43.06451,
+				Lng:          141.34681,
+				PlaceName:    "北海道",
+				BoundingBox:  &amesh.BoundingBox{MinLat: 41.35, MinLng: 139.33, MaxLat: 45.56, MaxLng: 148.89},
+				AddressLevel: 1,
+			},
+		},
 		{
 			name: "座標文字列の解析",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -428,9 +692,35 @@ func TestParseLocationWithClient(t *testing.T) {
 				PlaceName: "35.69,139.69",
 			},
 		},
+		{
+			name: "カンマ区切りの座標文字列",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusBadRequest, ""),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place:  "geo:35.6895,139.6917",
+					APIKey: "dummy_key",
+				},
+			},
+			expected: &amesh.Location{
+				Lat:       35.6895,
+				Lng:       139.6917,
+				PlaceName: "35.69,139.69",
+			},
+		},
+		{
+			name: "範囲外の座標はジオコーディングしない",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusBadRequest, ""),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place:  "95.0,139.6917",
+					APIKey: "dummy_key",
+				},
+			},
+			expectError: amesh.ErrCoordinateOutOfRange,
+		},
 		{
 			name: "空の場所は東京がデフォルト",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -455,7 +745,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "座標文字列（整数）",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -479,7 +769,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "無効な座標文字列（1つの数値のみ）",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -503,7 +793,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "無効な座標文字列",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusBadRequest, `{"Error": "Invalid place"}`),
 				GeocodeRequest: amesh.GeocodeRequest{
 					Place:  "invalid coordinates",
@@ -514,7 +804,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "無効な座標フォーマット",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -526,7 +816,7 @@ func TestParseLocationWithClient(t *testing.T) {
 				]
 			}`),
 				GeocodeRequest: amesh.GeocodeRequest{
-					Place:  "東京",
+					Place:  "存在しない地名",
 					APIKey: "test_key",
 				},
 			},
@@ -534,10 +824,10 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "APIがエラーステータスを返す",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusBadRequest, `{"Error": "Invalid API key"}`),
 				GeocodeRequest: amesh.GeocodeRequest{
-					Place:  "東京",
+					Place:  "存在しない地名",
 					APIKey: "invalid_key",
 				},
 			},
@@ -545,7 +835,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "結果が見つからない",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{"Feature": []}`),
 				GeocodeRequest: amesh.GeocodeRequest{
 					Place:  "nonexistent place",
@@ -556,10 +846,10 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "不正なJSON",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{"Feature": [invalid json}`),
 				GeocodeRequest: amesh.GeocodeRequest{
-					Place:  "東京",
+					Place:  "存在しない地名",
 					APIKey: "test_key",
 				},
 			},
@@ -567,7 +857,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		},
 		{
 			name: "座標数が足りない場合",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
 				"Feature": [
 					{
@@ -579,12 +869,77 @@ func TestParseLocationWithClient(t *testing.T) {
 				]
 			}`),
 				GeocodeRequest: amesh.GeocodeRequest{
-					Place:  "東京",
+					Place:  "存在しない地名",
 					APIKey: "test_key",
 				},
 			},
 			expectError: amesh.ErrInvalidCoordinatesFormat,
 		},
+		{
+			name: "APIがエラーの場合は埋め込みの地名の一覧を使う",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusInternalServerError, ""),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place:  "大阪",
+					APIKey: "test_key",
+				},
+			},
+			expected: &amesh.Location{
+				Lat:          34.6863,
+				Lng:          135.52,
+				PlaceName:    "大阪府",
+				AddressLevel: 1,
+			},
+		},
+		{
+			name: "ジオコーディングの結果を埋め込みの地名の一覧より優先する",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusOK, `{
+				"Feature": [
+					{
+						"Name": "大阪府大阪市",
+						"Geometry": {
+							"Coordinates": "135.5023,34.6937"
+						}
+					}
+				]
+			}`),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place:  "大阪",
+					APIKey: "test_key",
+				},
+			},
+			expected: &amesh.Location{
+				Lat:       34.6937,
+				Lng:       135.5023,
+				PlaceName: "大阪府大阪市",
+			},
+		},
+		{
+			name: "APIキーがない場合は埋め込みの地名の一覧を使う",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusBadRequest, ""),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place: "札幌駅",
+				},
+			},
+			expected: &amesh.Location{
+				Lat:          43.0687,
+				Lng:          141.3508,
+				PlaceName:    "札幌駅",
+				AddressLevel: 4,
+			},
+		},
+		{
+			name: "APIキーがなく埋め込みの地名の一覧にもない",
+			params: &amesh.ParseLocationParams{
+				Client: httpclient.NewMockHTTPClient(http.StatusBadRequest, ""),
+				GeocodeRequest: amesh.GeocodeRequest{
+					Place: "存在しない地名",
+				},
+			},
+			expectError: amesh.ErrNoResultsFound,
+		},
 		{
 			name:        "nilリクエスト",
 			params:      nil,
@@ -593,7 +948,7 @@ func TestParseLocationWithClient(t *testing.T) {
 		// jscpd:ignore-start
 		{
 			name: "nilクライアント",
-			params: &amesh.ParseLocationWithClientParams{
+			params: &amesh.ParseLocationParams{
 				Client: nil,
 				GeocodeRequest: amesh.GeocodeRequest{
 					Place:  "東京",
@@ -620,51 +975,102 @@ func TestParseLocationWithClient(t *testing.T) {
 	// jscpd:ignore-end
 }
 
+// FuzzParseLocationWithClient 座標の文字列がパニックせず、解析できた座標が範囲内であることを確認する
+func FuzzParseLocationWithClient(f *testing.F) {
+	for _, seed := range []string{
+		"35.6895 139.6917",
+		"35.6895,139.6917",
+		"geo:35.6895,139.6917;u=35",
+		"35°41'22\"N 139°41'30\"E",
+		"139.69E 35.68N",
+		"-90,-180",
+		"91 0",
+		"1e308 1e308",
+		"35.6895\u200b139.6917",
+		"°'\"NSEW",
+	} {
+		f.Add(seed)
+	}
+
+	client := httpclient.NewMockHTTPClient(http.StatusOK, `{"Feature": []}`)
+	f.Fuzz(func(t *testing.T, place string) {
+		location, err := amesh.ParseLocationWithClient(t.Context(), &amesh.ParseLocationParams{
+			Client:         client,
+			GeocodeRequest: amesh.GeocodeRequest{Place: place, APIKey: "test_key"},
+		})
+		if err != nil {
+			if location != nil {
+				t.Errorf("ParseLocationWithClient(%q) = %+v with error %v", place, location, err)
+			}
+			return
+		}
+
+		if math.IsNaN(location.Lat) || math.IsNaN(location.Lng) ||
+			location.Lat < -90 || 90 < location.Lat || location.Lng < -180 || 180 < location.Lng {
+			t.Errorf("ParseLocationWithClient(%q) = %+v, out of range", place, location)
+		}
+	})
+}
+
+// fileNamePattern GenerateFileNameが生成するファイル名の形式（地名・日本時間の時刻・乱数の接尾辞）
+var fileNamePattern = regexp.MustCompile(`^amesh_(.*)_\d{4}-\d{2}-\d{2}_\d{4}JST_[0-9a-f]{8}\.png$`)
+
 // TestGenerateFileName GenerateFileName関数をテストする
 func TestGenerateFileName(t *testing.T) {
 	tests := []struct {
-		name     string
-		location *amesh.Location
+		name          string
+		placeName     string
+		expectedPlace string
 	}{
 		{
-			name: "基本的なファイル名生成",
-			location: &amesh.Location{
-				Lat:       35.6895,
-				Lng:       139.6917,
-				PlaceName: "東京",
-			},
+			name:          "基本的なファイル名生成",
+			placeName:     "東京",
+			expectedPlace: "東京",
 		},
 		{
-			name: "座標",
-			location: &amesh.Location{
-				Lat:       35.6895,
-				Lng:       139.6917,
-				PlaceName: "35.6895,139.6917",
-			},
+			name:          "座標",
+			placeName:     "35.6895,139.6917",
+			expectedPlace: "35.6895,139.6917",
 		},
 		{
-			name: "空の地名",
-			location: &amesh.Location{
-				Lat:       35.6895,
-				Lng:       139.6917,
-				PlaceName: "",
-			},
+			name:          "空の地名",
+			placeName:     "",
+			expectedPlace: "",
 		},
 		{
-			name: "特殊文字を含む地名",
-			location: &amesh.Location{
-				Lat:       35.6895,
-				Lng:       139.6917,
-				PlaceName: "東京/新宿区",
-			},
+			name:          "空白はアンダースコアに置き換える",
+			placeName:     "新宿 駅\u3000東口",
+			expectedPlace: "新宿_駅_東口",
 		},
 		{
-			name: "非常に長い地名",
-			location: &amesh.Location{
-				Lat:       35.6895,
-				Lng:       139.6917,
-				PlaceName: strings.Repeat("長い地名", 100),
-			},
+			name:          "パスの区切りを置き換える",
+			placeName:     "東京/新宿区\\..\\etc",
+			expectedPlace: "東京_新宿区_.._etc",
+		},
+		{
+			name:          "ファイル名に使えない記号を置き換える",
+			placeName:     `35°40'34"N:139°39'1"E*?<>|`,
+			expectedPlace: "35°40'34_N_139°39'1_E_____",
+		},
+		{
+			name:          "制御文字を置き換える",
+			placeName:     "東京\n\t\x00\x7f大阪",
+			expectedPlace: "東京____大阪",
+		},
+		{
+			name:          "不正なUTF-8を置き換える",
+			placeName:     "東京\xff\xfe大阪",
+			expectedPlace: "東京_大阪",
+		},
+		{
+			name:          "非常に長い地名は文字の途中で切らずに切り詰める",
+			placeName:     strings.Repeat("長い地名", 100),
+			expectedPlace: strings.Repeat("長い地名", 8) + "長",
+		},
+		{
+			name:          "ASCIIの長い地名",
+			placeName:     strings.Repeat("a", 400),
+			expectedPlace: strings.Repeat("a", amesh.MaxFileNamePlaceBytes),
 		},
 	}
 
@@ -672,41 +1078,93 @@ func TestGenerateFileName(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			result := amesh.GenerateFileName(tt.location)
+			result := amesh.GenerateFileName(&amesh.GenerateFileNameParams{
+				Locations: []*amesh.Location{{Lat: 35.6895, Lng: 139.6917, PlaceName: tt.placeName}},
+			})
 
-			// ファイル名の基本フォーマットをチェック
-			if !strings.HasPrefix(result, "amesh_") {
-				t.Errorf("GenerateFileName() result = %v, expected to start with 'amesh_'", result)
+			match := fileNamePattern.FindStringSubmatch(result)
+			if match == nil {
+				t.Fatalf("GenerateFileName() = %q, want format amesh_<place>_<timestamp>_<suffix>.png", result)
 			}
-			if !strings.HasSuffix(result, ".png") {
-				t.Errorf("GenerateFileName() result = %v, expected to end with '.png'", result)
+			if match[1] != tt.expectedPlace {
+				t.Errorf("GenerateFileName() place = %q, want %q", match[1], tt.expectedPlace)
 			}
-
-			// 地名がファイル名に含まれているかチェック（スペースはアンダースコアに変換）
-			expectedPlaceName := strings.ReplaceAll(tt.location.PlaceName, " ", "_")
-			if !strings.Contains(result, expectedPlaceName) {
-				t.Errorf(
-					"GenerateFileName() result = %v, expected to contain place name %v",
-					result,
-					expectedPlaceName,
-				)
+			if !utf8.ValidString(result) {
+				t.Errorf("GenerateFileName() = %q, want valid UTF-8", result)
 			}
-
-			// タイムスタンプが含まれているかチェック（数字が含まれていることを確認）
-			hasNumber := false
-			for _, char := range result {
-				if char >= '0' && char <= '9' {
-					hasNumber = true
-					break
-				}
+			if 255 < len(result) {
+				t.Errorf("GenerateFileName() length = %d, want <= 255", len(result))
 			}
-			if !hasNumber {
-				t.Errorf("GenerateFileName() result = %v, expected to contain timestamp numbers", result)
+		})
+	}
+}
+
+// TestGenerateFileNameTime データの時刻と時計と乱数を指定するとファイル名が決まることをテストする
+func TestGenerateFileNameTime(t *testing.T) {
+	now := clocktest.NewFake(time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC))
+	suffix := func() uint32 { return 0xabcd }
+	locations := []*amesh.Location{{PlaceName: "東京"}, {PlaceName: "大阪"}}
+
+	tests := []struct {
+		name     string
+		params   *amesh.GenerateFileNameParams
+		expected string
+	}{
+		{
+			name:     "雨雲レーダーのbasetimeを日本時間で使う",
+			params:   &amesh.GenerateFileNameParams{Locations: locations[:1], DataTime: time.Date(2026, 10, 16, 3, 5, 0, 0, time.UTC), Clock: now, Suffix: suffix},
+			expected: "amesh_東京_2026-10-16_1205JST_0000abcd.png",
+		},
+		{
+			name:     "日付をまたぐ日本時間",
+			params:   &amesh.GenerateFileNameParams{Locations: locations[:1], DataTime: time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC), Clock: now, Suffix: suffix},
+			expected: "amesh_東京_2026-10-17_0500JST_0000abcd.png",
+		},
+		{
+			name:     "データの時刻がない場合は時計の時刻",
+			params:   &amesh.GenerateFileNameParams{Locations: locations[:1], Clock: now, Suffix: suffix},
+			expected: "amesh_東京_2026-10-17_0030JST_0000abcd.png",
+		},
+		{
+			name:     "比較画像は地名を並べる",
+			params:   &amesh.GenerateFileNameParams{Locations: locations, Clock: now, Suffix: suffix},
+			expected: "amesh_東京_大阪_2026-10-17_0030JST_0000abcd.png",
+		},
+		{
+			name:     "接頭辞の指定",
+			params:   &amesh.GenerateFileNameParams{Locations: locations[:1], Clock: now, Suffix: suffix, Prefix: "map"},
+			expected: "map_東京_2026-10-17_0030JST_0000abcd.png",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := amesh.GenerateFileName(tt.params); got != tt.expected {
+				t.Errorf("GenerateFileName() = %q, want %q", got, tt.expected)
 			}
 		})
 	}
 }
 
+// TestGenerateFileNameUnique 同じ時刻の同じ地名でも異なるファイル名を生成することをテストする
+func TestGenerateFileNameUnique(t *testing.T) {
+	t.Parallel()
+
+	params := &amesh.GenerateFileNameParams{
+		Locations: []*amesh.Location{{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京"}},
+		DataTime:  time.Date(2026, 10, 16, 3, 5, 0, 0, time.UTC),
+	}
+	seen := make(map[string]bool)
+	for range 100 {
+		name := amesh.GenerateFileName(params)
+		if seen[name] {
+			t.Fatalf("GenerateFileName() = %q, generated twice", name)
+		}
+		seen[name] = true
+	}
+}
+
 func TestParseAmeshCommand(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -733,6 +1191,11 @@ func TestParseAmeshCommand(t *testing.T) {
 			input:    "@bot @user amesh 名古屋",
 			expected: amesh.ParseAmeshCommandResult{Place: "名古屋", IsAmesh: true},
 		},
+		{
+			name:     "ハッシュタグのameshコマンド",
+			input:    "#amesh 札幌",
+			expected: amesh.ParseAmeshCommandResult{Place: "札幌", IsAmesh: true},
+		},
 		{
 			name:     "余分な空白付きameshコマンド",
 			input:    "  amesh   福岡  ",
@@ -743,6 +1206,182 @@ func TestParseAmeshCommand(t *testing.T) {
 			input:    "amesh 新宿 駅",
 			expected: amesh.ParseAmeshCommandResult{Place: "新宿 駅", IsAmesh: true},
 		},
+		{
+			name:     "ゼロ幅スペースで区切られたメンションとコマンド",
+			input:    "@bot\u200bamesh\ufeff東京",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true},
+		},
+		{
+			name:     "全角スペースで区切られたコマンド",
+			input:    "amesh\u3000大阪",
+			expected: amesh.ParseAmeshCommandResult{Place: "大阪", IsAmesh: true},
+		},
+		{
+			name:     "ゼロ幅接合子を含む絵文字は地名に残す",
+			input:    "amesh 👨\u200d👩\u200d👧",
+			expected: amesh.ParseAmeshCommandResult{Place: "👨\u200d👩\u200d👧", IsAmesh: true},
+		},
+		{
+			name:     "レイヤー指定付きameshコマンド",
+			input:    "amesh 東京 layer=flood",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true, Layer: "flood"},
+		},
+		{
+			name:     "場所無しでレイヤー指定のみ",
+			input:    "@bot amesh layer=radar,flood",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true, Layer: "radar,flood"},
+		},
+		{
+			name:     "予測の指定付きameshコマンド",
+			input:    "amesh 新宿 駅 Forecast",
+			expected: amesh.ParseAmeshCommandResult{Place: "新宿 駅", IsAmesh: true, Forecast: true},
+		},
+		{
+			name:     "過去の雨雲レーダーの期間の指定付きameshコマンド",
+			input:    "amesh history 東京 3h",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true, History: true, HistoryDuration: 3 * time.Hour},
+		},
+		{
+			name:     "過去の雨雲レーダーの期間の省略",
+			input:    "amesh 大阪 History",
+			expected: amesh.ParseAmeshCommandResult{Place: "大阪", IsAmesh: true, History: true},
+		},
+		{
+			name:     "historyがなければ期間のような単語も地名にする",
+			input:    "amesh 3h",
+			expected: amesh.ParseAmeshCommandResult{Place: "3h", IsAmesh: true},
+		},
+		{
+			name:  "文中のameshは地名の候補を探す",
+			input: "@bot 今日の渋谷は雨かな？ amesh",
+			expected: amesh.ParseAmeshCommandResult{
+				Place: "渋谷", IsAmesh: true, Candidates: []string{"渋谷"},
+			},
+		},
+		{
+			name:  "文中のハッシュタグのameshとレイヤー指定",
+			input: "横浜市は洪水大丈夫？ #amesh layer=flood",
+			expected: amesh.ParseAmeshCommandResult{
+				Place: "横浜市", IsAmesh: true, Layer: "flood", Candidates: []string{"横浜市"},
+			},
+		},
+		{
+			name:     "文中のameshで地名がない場合は東京",
+			input:    "雨かな？ amesh！",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true},
+		},
+		{
+			name:     "全角英字のameshコマンド",
+			input:    "ａｍｅｓｈ　東京",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true},
+		},
+		{
+			name:     "全角のハッシュタグとメンション",
+			input:    "＠ｂｏｔ　＃ａｍｅｓｈ　札幌",
+			expected: amesh.ParseAmeshCommandResult{Place: "札幌", IsAmesh: true},
+		},
+		{
+			name:     "半角カタカナの地名は全角にそろえる",
+			input:    "amesh ﾆｾｺ",
+			expected: amesh.ParseAmeshCommandResult{Place: "ニセコ", IsAmesh: true},
+		},
+		{
+			name:     "全角数字の住所は半角にそろえる",
+			input:    "amesh 銀座４丁目",
+			expected: amesh.ParseAmeshCommandResult{Place: "銀座4丁目", IsAmesh: true},
+		},
+		{
+			name:     "改行で区切られたメンションとコマンド",
+			input:    "@bot\namesh\n大阪",
+			expected: amesh.ParseAmeshCommandResult{Place: "大阪", IsAmesh: true},
+		},
+		{
+			name:     "CRLFで区切られたコマンド",
+			input:    "@bot\r\namesh 京都\r\n",
+			expected: amesh.ParseAmeshCommandResult{Place: "京都", IsAmesh: true},
+		},
+		{
+			name:     "リモートのメンション",
+			input:    "@bot@example.com amesh 仙台",
+			expected: amesh.ParseAmeshCommandResult{Place: "仙台", IsAmesh: true},
+		},
+		{
+			name:     "サブドメインとハイフンを含むホストのメンション",
+			input:    "@hato_bot@misskey.example-host.jp amesh 那覇",
+			expected: amesh.ParseAmeshCommandResult{Place: "那覇", IsAmesh: true},
+		},
+		{
+			name:     "区切りの記号が続くメンション",
+			input:    "@bot: amesh 神戸",
+			expected: amesh.ParseAmeshCommandResult{Place: "神戸", IsAmesh: true},
+		},
+		{
+			name:     "読点が続くメンション",
+			input:    "@bot、amesh 広島",
+			expected: amesh.ParseAmeshCommandResult{Place: "広島", IsAmesh: true},
+		},
+		{
+			name:     "全角スペースの直後のリモートのメンション",
+			input:    "@bot@example.com\u3000@alice@misskey.io\u3000amesh 金沢",
+			expected: amesh.ParseAmeshCommandResult{Place: "金沢", IsAmesh: true},
+		},
+		{
+			name:     "メールアドレスはメンションとして扱わない",
+			input:    "amesh info@example.com",
+			expected: amesh.ParseAmeshCommandResult{Place: "info@example.com", IsAmesh: true},
+		},
+		{
+			name:     "太字のameshコマンド",
+			input:    "@bot **amesh** 東京",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true},
+		},
+		{
+			name:     "打ち消し線と斜体の装飾",
+			input:    "~~amesh~~ <i>横浜</i>",
+			expected: amesh.ParseAmeshCommandResult{Place: "横浜", IsAmesh: true},
+		},
+		{
+			name:     "小文字と中央寄せのタグ",
+			input:    "<center>amesh</center><small>福岡</small>",
+			expected: amesh.ParseAmeshCommandResult{Place: "福岡", IsAmesh: true},
+		},
+		{
+			name:     "MFMの関数",
+			input:    "@bot $[x2 amesh 名古屋]",
+			expected: amesh.ParseAmeshCommandResult{Place: "名古屋", IsAmesh: true},
+		},
+		{
+			name:     "引数付きの入れ子のMFMの関数",
+			input:    "$[fg.color=f00 $[tada amesh] $[x2 静岡]]",
+			expected: amesh.ParseAmeshCommandResult{Place: "静岡", IsAmesh: true},
+		},
+		{
+			name:     "MFMの関数の外の角括弧は残す",
+			input:    "amesh [東京]",
+			expected: amesh.ParseAmeshCommandResult{Place: "[東京]", IsAmesh: true},
+		},
+		{
+			name:  "装飾された文中のamesh",
+			input: "@bot@example.com 今日の**渋谷**は雨かな？\n$[shake amesh]",
+			expected: amesh.ParseAmeshCommandResult{
+				Place: "渋谷", IsAmesh: true, Candidates: []string{"渋谷"},
+			},
+		},
+		{
+			name:     "全角英字のレイヤー指定",
+			input:    "amesh 東京 ｌａｙｅｒ＝ｆｌｏｏｄ",
+			expected: amesh.ParseAmeshCommandResult{Place: "東京", IsAmesh: true, Layer: "flood"},
+		},
+		{
+			name:     "装飾されたameshに似た単語",
+			input:    "**ameshi** 東京",
+			expected: amesh.ParseAmeshCommandResult{Place: "", IsAmesh: false},
+		},
+		{
+			name:     "リモートのメンションのみ",
+			input:    "@bot@example.com\n@user@misskey.io",
+			expected: amesh.ParseAmeshCommandResult{Place: "", IsAmesh: false},
+		},
 		{
 			name:     "ameshコマンドではないテキスト",
 			input:    "hello world",
@@ -781,6 +1420,128 @@ func TestParseAmeshCommand(t *testing.T) {
 	}
 }
 
+// FuzzParseAmeshCommand 不正な文字列でパニックしたり、amesh以外のコマンドをameshとして扱わないことを確認する
+func FuzzParseAmeshCommand(f *testing.F) {
+	for _, seed := range []string{
+		"amesh 東京",
+		"@bot amesh 新宿 駅 layer=flood",
+		"#amesh layer=",
+		"amesh forecast layer=radar",
+		"@bot\u200bamesh\ufeff東京",
+		"amesh\u3000🌧️ 35°41'22\"N 139°41'30\"E",
+		"ameshi",
+		"@@ # amesh",
+		"\xff\xfeamesh",
+		"＠ｂｏｔ@example.com\n$[x2 **ａｍｅｓｈ** ﾄｳｷｮｳ]",
+		"$[$[x2 ]amesh]]",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		result := amesh.ParseAmeshCommand(text)
+		if !result.IsAmesh {
+			if result.Place != "" || result.Layer != "" {
+				t.Errorf("ParseAmeshCommand(%q) = %+v, want empty result", text, result)
+			}
+			return
+		}
+
+		if !strings.Contains(lib.NormalizeMessage(text), "amesh") {
+			t.Errorf("ParseAmeshCommand(%q) matched without amesh", text)
+		}
+		if result.Place == "" || result.Place != strings.TrimSpace(result.Place) {
+			t.Errorf("ParseAmeshCommand(%q) place = %q", text, result.Place)
+		}
+		for word := range strings.FieldsSeq(result.Place) {
+			if strings.HasPrefix(word, "layer=") || strings.HasPrefix(word, "@") {
+				t.Errorf("ParseAmeshCommand(%q) place contains %q", text, word)
+			}
+		}
+		if strings.ContainsRune(result.Place, '\u200b') {
+			t.Errorf("ParseAmeshCommand(%q) place contains zero width space", text)
+		}
+	})
+}
+
+func TestCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{
+			name:     "通常のエラー",
+			err:      errors.New("something wrong"),
+			expected: i18n.KeyErrorCommand,
+		},
+		{
+			name:     "レーダーデータが取得できない",
+			err:      errors.Wrap(amesh.ErrNoRadarData, "Failed to CreateAmeshImage"),
+			expected: i18n.KeyErrorNoRadarData,
+		},
+		{
+			name:     "存在しないレイヤー",
+			err:      errors.Wrap(amesh.ErrUnknownOverlay, "Failed to amesh.ParseOverlays"),
+			expected: i18n.KeyErrorUnknownLayer,
+		},
+		{
+			name:     "比較する地点が多すぎる",
+			err:      errors.Wrap(amesh.ErrTooManyPlaces, "Failed to ParseLocationsWithClient"),
+			expected: i18n.KeyErrorTooManyPlaces,
+		},
+		{
+			name:     "雨雲レーダーの予測が取得できない",
+			err:      errors.Wrap(amesh.ErrNoForecastData, "Failed to CreateForecastImage"),
+			expected: i18n.KeyErrorNoForecastData,
+		},
+		{
+			name:     "複数の地点の予測",
+			err:      errors.Wrap(amesh.ErrForecastComparison, "Failed to Execute"),
+			expected: i18n.KeyErrorForecastComparison,
+		},
+		{
+			name:     "過去の雨雲レーダーが取得できない",
+			err:      errors.Wrap(amesh.ErrNoHistoryData, "Failed to CreateHistoryImage"),
+			expected: i18n.KeyErrorNoHistoryData,
+		},
+		{
+			name:     "複数の地点の過去の雨雲レーダー",
+			err:      errors.Wrap(amesh.ErrHistoryComparison, "Failed to Execute"),
+			expected: i18n.KeyErrorHistoryComparison,
+		},
+		{
+			name:     "範囲外の過去の雨雲レーダーの期間",
+			err:      errors.Wrap(amesh.ErrInvalidHistoryDuration, "Failed to CreateHistoryImage"),
+			expected: i18n.KeyErrorHistoryDuration,
+		},
+		{
+			name:     "サーキットブレーカーが開いている",
+			err:      errors.Wrap(&httpclient.CircuitOpenError{Upstream: httpclient.UpstreamOSM}, "Failed to Do"),
+			expected: i18n.KeyErrorUpstreamUnavailable,
+		},
+		{
+			name:     "気象庁のサーキットブレーカーが開いている",
+			err:      errors.Wrap(&httpclient.CircuitOpenError{Upstream: httpclient.UpstreamJMA}, "Failed to Do"),
+			expected: i18n.KeyErrorUpstreamOutage,
+		},
+		{
+			name:     "ジオコーダのサーキットブレーカーが開いている",
+			err:      errors.Wrap(&httpclient.CircuitOpenError{Upstream: httpclient.UpstreamYahooGeocoder}, "Failed to Do"),
+			expected: i18n.KeyErrorUpstreamOutage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := amesh.CommandErrorKey(tt.err); result != tt.expected {
+				t.Errorf("CommandErrorKey() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
 // createDummyPNGBytes ダミーのPNG画像バイトを作成する
 func createDummyPNGBytes(width, height int, c color.Color) ([]byte, error) {
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
@@ -0,0 +1,346 @@
+package ameshtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// タイルの種類（Tile.Layer）
+const (
+	LayerBaseMap = "osm"   // OpenStreetMapのベースマップ
+	LayerRadar   = "hrpns" // 気象庁の雨雲レーダー
+	LayerFlood   = "flood" // 気象庁の洪水キキクル
+	LayerSnow    = "snowd" // 気象庁の解析積雪深
+)
+
+// DefaultBaseTime Scenario.BaseTimeを指定しない場合のtargetTimesのbasetime
+var DefaultBaseTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// StaleAge Scenario.StaleTimestampsを指定した場合に、targetTimesが返すbasetimeの古さ
+const StaleAge = time.Hour
+
+// originalHostHeader 書き換える前のリクエスト先のホストを伝えるヘッダー
+const originalHostHeader = "X-Ameshtest-Host"
+
+// jmaTimestampLayout 気象庁のtargetTimesのbasetimeの書式（UTC）
+const jmaTimestampLayout = "20060102150405"
+
+var (
+	osmTilePath = regexp.MustCompile(`^/(\d+)/(\d+)/(\d+)\.png$`)
+	jmaTilePath = regexp.MustCompile(`^/bosai/jmatile/data/(\w+)/(\d{14})/none/\d{14}/surf/(\w+)/(\d+)/(\d+)/(\d+)\.png$`)
+	lidenPath   = regexp.MustCompile(`^/bosai/jmatile/data/nowc/(\d{14})/none/\d{14}/surf/liden/data\.geojson$`)
+)
+
+// Tile 偽のサーバーが返すタイルの種類と座標
+type Tile struct {
+	Layer string // タイルの種類（LayerBaseMapなど）
+	Z     int    // ズームレベル
+	X     int    // X座標
+	Y     int    // Y座標
+}
+
+// Lightning 偽のサーバーが返す落雷地点
+type Lightning struct {
+	Lat  float64 // 緯度
+	Lng  float64 // 経度
+	Type int     // 種類（1: 対地雷、2: 雲放電）
+}
+
+// Scenario 偽のサーバーの動作
+// ゼロ値の場合は全てのタイルとタイムスタンプを遅延なく返す
+type Scenario struct {
+	BaseTime        time.Time            // targetTimesのbasetime（ゼロ値の場合はDefaultBaseTime）
+	Lightning       []Lightning          // 落雷地点
+	MissingTiles    func(tile Tile) bool // trueを返したタイルは404を返す（nilの場合は全て返す）
+	SlowTiles       time.Duration        // タイルを返すまでの遅延（リクエストがキャンセルされた場合は中断する）
+	StaleTimestamps bool                 // targetTimesはStaleAgeだけ古いbasetimeを返し、そのbasetimeのレーダーと落雷は404を返す
+	NoTargetTimes   bool                 // targetTimesは500を返す
+	Forecast        bool                 // 降水ナウキャストのtargetTimes_N1はbasetimeから60分先まで5分ごとの雨雲レーダーの予測も返す
+	History         bool                 // 降水ナウキャストのtargetTimes_N1はbasetimeからHistoryRange前まで5分ごとの雨雲レーダーの解析値も返す
+}
+
+// ForecastStep Scenario.Forecastを指定した場合の予測の間隔
+const ForecastStep = 5 * time.Minute
+
+// ForecastRange Scenario.Forecastを指定した場合に予測する時間の長さ
+const ForecastRange = time.Hour
+
+// HistoryStep Scenario.Historyを指定した場合の過去の解析値の間隔
+const HistoryStep = 5 * time.Minute
+
+// HistoryRange Scenario.Historyを指定した場合に過去の解析値を返す時間の長さ
+const HistoryRange = 3 * time.Hour
+
+// Server ameshの画像作成で使う気象庁とOpenStreetMapを模したhttptestのサーバー
+// Clientで作成したHTTPクライアントは本物のURLへのリクエストをこのサーバーに送るため、ameshのURLを変えずに使える
+type Server struct {
+	*httptest.Server
+
+	scenario Scenario
+	baseTile []byte
+	radar    []byte
+	overlay  []byte
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// NewServer 偽のサーバーを起動し、テストの終了時に停止する
+func NewServer(t testing.TB, scenario *Scenario) *Server {
+	t.Helper()
+	s := &Server{
+		baseTile: encodeTile(t, color.RGBA{R: 242, G: 239, B: 233, A: 255}),
+		radar:    encodeTile(t, color.RGBA{R: 0, G: 65, B: 255, A: 255}),
+		overlay:  encodeTile(t, color.RGBA{}),
+	}
+	if scenario != nil {
+		s.scenario = *scenario
+	}
+	if s.scenario.BaseTime.IsZero() {
+		s.scenario.BaseTime = DefaultBaseTime
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// encodeTile 1色で塗りつぶした256x256のPNG画像を作成する
+func encodeTile(t testing.TB, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// BaseTime targetTimesが返すbasetimeの文字列を返す
+func (s *Server) BaseTime() string {
+	baseTime := s.scenario.BaseTime
+	if s.scenario.StaleTimestamps {
+		baseTime = baseTime.Add(-StaleAge)
+	}
+	return baseTime.UTC().Format(jmaTimestampLayout)
+}
+
+// Client 全てのリクエストをこのサーバーに送るHTTPクライアントを作成する
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Transport: &rewriteTransport{target: target, base: s.Server.Client().Transport}}
+}
+
+// Requests 受け取ったリクエストの書き換える前のURLを受け取った順に返す
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// RequestsTo URLにpatternを含むリクエストを返す
+func (s *Server) RequestsTo(pattern string) []string {
+	var matched []string
+	for _, requestURL := range s.Requests() {
+		if strings.Contains(requestURL, pattern) {
+			matched = append(matched, requestURL)
+		}
+	}
+	return matched
+}
+
+// serveHTTP 書き換える前のホストとパスに応じてタイルやタイムスタンプを返す
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Header.Get(originalHostHeader)
+	s.mu.Lock()
+	s.requests = append(s.requests, "https://"+host+r.URL.RequestURI())
+	s.mu.Unlock()
+
+	switch host {
+	case "tile.openstreetmap.org":
+		if m := osmTilePath.FindStringSubmatch(r.URL.Path); m != nil {
+			s.serveTile(w, r, tileOf(LayerBaseMap, m[1:]), s.baseTile)
+			return
+		}
+	case "www.jma.go.jp":
+		s.serveJMA(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveJMA 気象庁のtargetTimes、タイル、落雷のGeoJSONを返す
+func (s *Server) serveJMA(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	baseTime := s.BaseTime()
+	current := s.scenario.BaseTime.UTC().Format(jmaTimestampLayout)
+
+	if strings.HasSuffix(path, ".json") && strings.Contains(path, "targetTimes") {
+		if s.scenario.NoTargetTimes {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		elements := []string{"hrpns_nd", "liden"}
+		switch {
+		case strings.Contains(path, "/risk/"):
+			elements = []string{LayerFlood}
+		case strings.Contains(path, "/snow/"):
+			elements = []string{LayerSnow}
+		}
+		entries := []map[string]any{{"basetime": baseTime, "validtime": baseTime, "elements": elements}}
+		if s.scenario.Forecast && strings.Contains(path, "targetTimes_N1") {
+			entries = append(entries, s.forecastEntries()...)
+		}
+		if s.scenario.History && strings.Contains(path, "targetTimes_N1") {
+			entries = append(entries, s.historyEntries()...)
+		}
+		writeJSON(w, entries)
+		return
+	}
+	if m := lidenPath.FindStringSubmatch(path); m != nil {
+		if m[1] != current {
+			http.NotFound(w, r)
+			return
+		}
+		features := make([]map[string]any, 0, len(s.scenario.Lightning))
+		for _, lightning := range s.scenario.Lightning {
+			features = append(features, map[string]any{
+				"type":       "Feature",
+				"geometry":   map[string]any{"type": "Point", "coordinates": []float64{lightning.Lng, lightning.Lat}},
+				"properties": map[string]any{"type": lightning.Type},
+			})
+		}
+		writeJSON(w, map[string]any{"type": "FeatureCollection", "features": features})
+		return
+	}
+	if m := jmaTilePath.FindStringSubmatch(path); m != nil {
+		category, timestamp, element := m[1], m[2], m[3]
+		if category == "nowc" && timestamp != current && !s.isHistory(timestamp) {
+			// 古いbasetimeのレーダーは気象庁のサーバーから削除されている
+			http.NotFound(w, r)
+			return
+		}
+		body := s.overlay
+		if element == LayerRadar {
+			body = s.radar
+		}
+		s.serveTile(w, r, tileOf(element, m[4:]), body)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// forecastEntries basetimeからForecastRangeまでForecastStepごとの雨雲レーダーの予測のtargetTimesの要素を返す
+func (s *Server) forecastEntries() []map[string]any {
+	baseTime := s.BaseTime()
+	base, _ := time.Parse(jmaTimestampLayout, baseTime)
+	var entries []map[string]any
+	for offset := ForecastStep; offset <= ForecastRange; offset += ForecastStep {
+		entries = append(entries, map[string]any{
+			"basetime":  baseTime,
+			"validtime": base.Add(offset).Format(jmaTimestampLayout),
+			"elements":  []string{LayerRadar},
+		})
+	}
+	return entries
+}
+
+// historyEntries basetimeからHistoryRange前までHistoryStepごとの雨雲レーダーの解析値のtargetTimesの要素を返す
+func (s *Server) historyEntries() []map[string]any {
+	base, _ := time.Parse(jmaTimestampLayout, s.BaseTime())
+	var entries []map[string]any
+	for offset := HistoryStep; offset <= HistoryRange; offset += HistoryStep {
+		pastTime := base.Add(-offset).Format(jmaTimestampLayout)
+		entries = append(entries, map[string]any{
+			"basetime":  pastTime,
+			"validtime": pastTime,
+			"elements":  []string{"hrpns_nd"},
+		})
+	}
+	return entries
+}
+
+// isHistory Scenario.Historyを指定した場合に、basetimeがtargetTimesで返す過去の解析値のものか
+func (s *Server) isHistory(timestamp string) bool {
+	if !s.scenario.History {
+		return false
+	}
+	pastTime, err := time.Parse(jmaTimestampLayout, timestamp)
+	if err != nil {
+		return false
+	}
+	age := s.scenario.BaseTime.Sub(pastTime)
+	return 0 < age && age <= HistoryRange && age%HistoryStep == 0
+}
+
+// serveTile シナリオに応じてタイルを遅らせたり404を返したりする
+func (s *Server) serveTile(w http.ResponseWriter, r *http.Request, tile Tile, body []byte) {
+	if 0 < s.scenario.SlowTiles {
+		timer := time.NewTimer(s.scenario.SlowTiles)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if s.scenario.MissingTiles != nil && s.scenario.MissingTiles(tile) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(body)
+}
+
+// tileOf 正規表現で取り出したズームレベルと座標からTileを作成する
+func tileOf(layer string, zxy []string) Tile {
+	z, _ := strconv.Atoi(zxy[0])
+	x, _ := strconv.Atoi(zxy[1])
+	y, _ := strconv.Atoi(zxy[2])
+	return Tile{Layer: layer, Z: z, X: x, Y: y}
+}
+
+// writeJSON 値をJSONで書き込む
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, fmt.Sprintf("json: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// rewriteTransport リクエスト先をhttptestのサーバーに書き換えるhttp.RoundTripper
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+// RoundTrip 書き換える前のホストをヘッダーに残し、リクエスト先をサーバーに書き換えて送信する
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rewritten := req.Clone(req.Context())
+	rewritten.Header.Set(originalHostHeader, req.URL.Host)
+	rewritten.URL.Scheme = t.target.Scheme
+	rewritten.URL.Host = t.target.Host
+	rewritten.Host = t.target.Host
+	resp, err := t.base.RoundTrip(rewritten)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to RoundTrip")
+	}
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package ameshtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		scenario          *ameshtest.Scenario
+		timeout           time.Duration
+		expectedRadarTime time.Time
+		expectedTiles     amesh.TileStats
+		expectedLightning int
+	}{
+		{
+			name:              "全てのタイルとタイムスタンプを返す",
+			scenario:          &ameshtest.Scenario{Lightning: []ameshtest.Lightning{{Lat: 35.68, Lng: 139.76, Type: 1}, {Lat: 43.06, Lng: 141.35, Type: 1}}},
+			expectedRadarTime: ameshtest.DefaultBaseTime,
+			expectedTiles:     amesh.TileStats{Fetched: 2},
+			expectedLightning: 1,
+		},
+		{
+			name: "ベースマップのタイルがない",
+			scenario: &ameshtest.Scenario{MissingTiles: func(tile ameshtest.Tile) bool {
+				return tile.Layer == ameshtest.LayerBaseMap
+			}},
+			expectedRadarTime: ameshtest.DefaultBaseTime,
+			expectedTiles:     amesh.TileStats{Fetched: 1, Failed: 1},
+		},
+		{
+			name:              "古いタイムスタンプのレーダーは削除されている",
+			scenario:          &ameshtest.Scenario{StaleTimestamps: true},
+			expectedRadarTime: ameshtest.DefaultBaseTime.Add(-ameshtest.StaleAge),
+			expectedTiles:     amesh.TileStats{Fetched: 1, Failed: 1, NoData: 1},
+		},
+		{
+			name:              "タイルが遅い場合は制限時間で打ち切る",
+			scenario:          &ameshtest.Scenario{SlowTiles: time.Minute},
+			timeout:           100 * time.Millisecond,
+			expectedRadarTime: ameshtest.DefaultBaseTime,
+			expectedTiles:     amesh.TileStats{Failed: 2, NoData: 1},
+		},
+		{
+			name:          "targetTimesを取得できない",
+			scenario:      &ameshtest.Scenario{NoTargetTimes: true},
+			expectedTiles: amesh.TileStats{Fetched: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, tt.scenario)
+
+			ctx := t.Context()
+			if 0 < tt.timeout {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			result, err := amesh.CreateAmeshImage(ctx, &amesh.CreateAmeshImageParams{
+				Client: server.Client(),
+				Lat:    35.6895,
+				Lng:    139.6917,
+				Zoom:   10,
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+
+			if !result.RadarTime.Equal(tt.expectedRadarTime) {
+				t.Errorf("RadarTime = %v, want %v", result.RadarTime, tt.expectedRadarTime)
+			}
+			if diff := cmp.Diff(tt.expectedTiles, result.Tiles); diff != "" {
+				t.Errorf("Tiles mismatch (-want +got):\n%s", diff)
+			}
+			if result.LightningCount != tt.expectedLightning {
+				t.Errorf("LightningCount = %d, want %d", result.LightningCount, tt.expectedLightning)
+			}
+			if len(server.RequestsTo("targetTimes")) == 0 {
+				t.Errorf("no targetTimes requests: %v", server.Requests())
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package amesh
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/config"
+)
+
+var (
+	// ErrUnknownBlendMode 存在しない合成方法が指定されたことを表すエラー
+	ErrUnknownBlendMode = errors.New("unknown blend mode")
+	// ErrInvalidOpacity 不透明度が範囲外であることを表すエラー
+	ErrInvalidOpacity = errors.New("invalid opacity")
+)
+
+// BlendMode オーバーレイのタイルをベースマップに重ねる合成方法
+type BlendMode string
+
+const (
+	BlendNormal   BlendMode = "normal"   // 一様な不透明度で重ねる
+	BlendMultiply BlendMode = "multiply" // ベースマップの色と乗算して重ね、地図の道路や地名を透かして見せる
+	BlendPriority BlendMode = "priority" // 鮮やかな色（強い雨など）ほど不透明に重ね、暗いベースマップでも強い雨の色を残す
+)
+
+// blendModes 指定できる合成方法の一覧
+var blendModes = []BlendMode{BlendNormal, BlendMultiply, BlendPriority}
+
+// OverlayStyle オーバーレイのレイヤーの描画方法
+type OverlayStyle struct {
+	Alpha uint8     // 不透明度（0の場合はレイヤーの既定値）
+	Blend BlendMode // 合成方法（空の場合はBlendNormal）
+}
+
+// ParseBlendMode 合成方法の名前を解析する
+// 空文字列の場合はBlendNormalを返す
+func ParseBlendMode(s string) (BlendMode, error) {
+	if strings.TrimSpace(s) == "" {
+		return BlendNormal, nil
+	}
+	for _, mode := range blendModes {
+		if strings.EqualFold(strings.TrimSpace(s), string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", errors.Wrapf(ErrUnknownBlendMode, "blend: %s", s)
+}
+
+// NewOverlayStylesFromConfig 設定ファイルのレイヤーごとの描画の設定からOverlayStyleを作成する
+// 不透明度は0より大きく1以下の割合で指定し、0の場合はレイヤーの既定値を使う
+func NewOverlayStylesFromConfig(settings map[string]config.AmeshOverlay) (map[OverlayName]OverlayStyle, error) {
+	styles := make(map[OverlayName]OverlayStyle, len(settings))
+	for name, setting := range settings {
+		overlay := OverlayName(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(overlayNames, overlay) {
+			return nil, errors.Wrapf(ErrUnknownOverlay, "layer: %s", name)
+		}
+		if setting.Opacity < 0 || 1 < setting.Opacity {
+			return nil, errors.Wrapf(ErrInvalidOpacity, "%s: %v", name, setting.Opacity)
+		}
+		blend, err := ParseBlendMode(setting.Blend)
+		if err != nil {
+			return nil, errors.Wrapf(err, "layer: %s", name)
+		}
+		styles[overlay] = OverlayStyle{
+			Alpha: uint8(setting.Opacity*255 + 0.5),
+			Blend: blend,
+		}
+	}
+	return styles, nil
+}
+
+// overlayStyles クライアント未指定の関数で使うレイヤーごとの描画方法（SetOverlayStylesで設定する）
+var overlayStyles atomic.Pointer[map[OverlayName]OverlayStyle]
+
+// SetOverlayStyles CreateImageReaderなどクライアント未指定の関数で使う、オーバーレイのレイヤーごとの描画方法を設定する
+// nilを設定した場合は各レイヤーの既定値で描画する
+func SetOverlayStyles(styles map[OverlayName]OverlayStyle) {
+	overlayStyles.Store(&styles)
+}
+
+// getOverlayStyles SetOverlayStylesで設定した描画方法を返す（未設定の場合はnil）
+func getOverlayStyles() map[OverlayName]OverlayStyle {
+	if styles := overlayStyles.Load(); styles != nil {
+		return *styles
+	}
+	return nil
+}
+
+// blendTile タイル画像をblendの合成方法とalphaの不透明度でキャンバスに重ねる
+// キャンバスはベースマップを描画済みの不透明な画像であることを前提とする
+func blendTile(canvas *image.RGBA, rect image.Rectangle, tile image.Image, alpha uint8, blend BlendMode) {
+	offset := tile.Bounds().Min.Sub(rect.Min)
+	area := rect.Intersect(canvas.Bounds())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			src := color.NRGBAModel.Convert(tile.At(x+offset.X, y+offset.Y)).(color.NRGBA)
+			if src.A == 0 {
+				continue
+			}
+			canvas.SetRGBA(x, y, blendPixel(canvas.RGBAAt(x, y), src, alpha, blend))
+		}
+	}
+}
+
+// blendPixel 1画素を合成する
+func blendPixel(dst color.RGBA, src color.NRGBA, alpha uint8, blend BlendMode) color.RGBA {
+	target := [3]uint32{uint32(src.R), uint32(src.G), uint32(src.B)}
+	switch blend {
+	case BlendMultiply:
+		target = [3]uint32{
+			uint32(dst.R) * uint32(src.R) / 255,
+			uint32(dst.G) * uint32(src.G) / 255,
+			uint32(dst.B) * uint32(src.B) / 255,
+		}
+	case BlendPriority:
+		// 彩度（RGBの最大と最小の差）が高いほど不透明度を1に近づける
+		chroma := uint32(max(src.R, src.G, src.B) - min(src.R, src.G, src.B))
+		alpha = uint8(uint32(alpha) + (255-uint32(alpha))*chroma/255)
+	}
+
+	coverage := uint32(src.A) * uint32(alpha) / 255
+	mix := func(d uint8, t uint32) uint8 {
+		return uint8((uint32(d)*(255-coverage) + t*coverage) / 255)
+	}
+	return color.RGBA{
+		R: mix(dst.R, target[0]),
+		G: mix(dst.G, target[1]),
+		B: mix(dst.B, target[2]),
+		A: dst.A,
+	}
+}
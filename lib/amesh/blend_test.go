@@ -0,0 +1,148 @@
+package amesh_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/config"
+)
+
+func TestParseBlendMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      amesh.BlendMode
+		expectedError error
+	}{
+		{name: "空の場合はnormal", input: "", expected: amesh.BlendNormal},
+		{name: "multiply", input: "multiply", expected: amesh.BlendMultiply},
+		{name: "大文字と空白", input: " Priority ", expected: amesh.BlendPriority},
+		{name: "存在しない合成方法", input: "screen", expectedError: amesh.ErrUnknownBlendMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := amesh.ParseBlendMode(tt.input)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseBlendMode(%q) error = %v, want %v", tt.input, err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseBlendMode(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewOverlayStylesFromConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      map[string]config.AmeshOverlay
+		expected      map[amesh.OverlayName]amesh.OverlayStyle
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			settings: nil,
+			expected: map[amesh.OverlayName]amesh.OverlayStyle{},
+		},
+		{
+			name: "レイヤーごとの不透明度と合成方法",
+			settings: map[string]config.AmeshOverlay{
+				"radar": {Opacity: 0.8, Blend: "priority"},
+				"Flood": {Blend: "multiply"},
+			},
+			expected: map[amesh.OverlayName]amesh.OverlayStyle{
+				amesh.OverlayRadar: {Alpha: 204, Blend: amesh.BlendPriority},
+				amesh.OverlayFlood: {Alpha: 0, Blend: amesh.BlendMultiply},
+			},
+		},
+		{
+			name:          "存在しないレイヤー",
+			settings:      map[string]config.AmeshOverlay{"wind": {Opacity: 0.5}},
+			expectedError: amesh.ErrUnknownOverlay,
+		},
+		{
+			name:          "範囲外の不透明度",
+			settings:      map[string]config.AmeshOverlay{"radar": {Opacity: 1.5}},
+			expectedError: amesh.ErrInvalidOpacity,
+		},
+		{
+			name:          "存在しない合成方法",
+			settings:      map[string]config.AmeshOverlay{"snow": {Blend: "screen"}},
+			expectedError: amesh.ErrUnknownBlendMode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := amesh.NewOverlayStylesFromConfig(tt.settings)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("NewOverlayStylesFromConfig() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("NewOverlayStylesFromConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageOverlayStyle 雨雲レーダーの不透明度と合成方法を指定して描画することをテストする
+// ameshtestのベースマップは(242,239,233)、雨雲レーダーは(0,65,255)の一様な色のタイル
+func TestCreateAmeshImageOverlayStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		style    amesh.OverlayStyle
+		expected color.RGBA
+	}{
+		{
+			name:     "不透明",
+			style:    amesh.OverlayStyle{Alpha: 255},
+			expected: color.RGBA{R: 0, G: 65, B: 255, A: 255},
+		},
+		{
+			name:     "乗算",
+			style:    amesh.OverlayStyle{Blend: amesh.BlendMultiply},
+			expected: color.RGBA{R: 120, G: 149, B: 233, A: 255},
+		},
+		{
+			name:     "不透明な乗算",
+			style:    amesh.OverlayStyle{Alpha: 255, Blend: amesh.BlendMultiply},
+			expected: color.RGBA{R: 0, G: 60, B: 233, A: 255},
+		},
+		{
+			// 彩度の高い強い雨の色は既定の不透明度でもそのまま描画する
+			name:     "彩度による優先",
+			style:    amesh.OverlayStyle{Blend: amesh.BlendPriority},
+			expected: color.RGBA{R: 0, G: 65, B: 255, A: 255},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, nil)
+
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:        server.Client(),
+				Lat:           35.6895,
+				Lng:           139.6917,
+				Zoom:          10,
+				AroundTiles:   1,
+				OverlayStyles: map[amesh.OverlayName]amesh.OverlayStyle{amesh.OverlayRadar: tt.style},
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+			if got := result.Image.RGBAAt(700, 100); got != tt.expected {
+				t.Errorf("radar pixel = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
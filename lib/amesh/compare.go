@@ -0,0 +1,284 @@
+package amesh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/requestid"
+)
+
+// ErrTooManyPlaces 比較画像に並べる地点が多すぎることを表すエラー
+var ErrTooManyPlaces = errors.New("too many places to compare")
+
+// 比較画像の定数
+const (
+	maxComparePlaces      = 4  // 比較画像に並べる地点の最大数
+	comparisonZoom        = 10 // 各パネルのズームレベル（地点間で縮尺を揃える）
+	comparisonAroundTiles = 1  // 各パネルの周囲のタイル数
+	comparisonPanelGap    = 8  // パネル間の余白（ピクセル）
+)
+
+// CreateComparisonImageParams 比較画像作成のリクエスト構造体
+type CreateComparisonImageParams struct {
+	Client         httpclient.Doer              // HTTPクライアント
+	Locations      []*Location                  // 並べる地点（左から順に描画する）
+	TimestampCache *httpclient.ResponseCache    // targetTimesのキャッシュ（nilの場合はキャッシュしない）
+	NoRadarData    NoRadarDataMode              // レーダーのタイムスタンプが取得できなかった場合の動作
+	Overlays       []OverlayName                // ベースマップに重ねるレイヤー（nilの場合はDefaultOverlays）
+	OverlayStyles  map[OverlayName]OverlayStyle // オーバーレイのレイヤーごとの描画方法（nilの場合は各レイヤーの既定値）
+	MapStyle       MapStyle                     // ベースマップのスタイル（空の場合はMapStyleLight）
+	StaleThreshold time.Duration                // 雨雲レーダーのデータが古いとみなす経過時間（0の場合は確認しない）
+	Clock          clock.Clock                  // 雨雲レーダーの経過時間の確認に使う時計（nilの場合はclock.Real）
+}
+
+// CreateComparisonImage 複数地点のレーダー画像を横に並べた比較画像を作成する
+// タイムスタンプは全パネルで共有し、各パネルの左下に番号と座標のラベルを描画する
+// タイルの取得結果と落雷地点数は全パネル分を合算し、描画範囲は全パネルを含む範囲を返す
+func CreateComparisonImage(ctx context.Context, params *CreateComparisonImageParams) (*AmeshResult, error) {
+	if params == nil || params.Client == nil || len(params.Locations) == 0 || slices.Contains(params.Locations, nil) {
+		return nil, lib.ErrParamsNil
+	}
+	if maxComparePlaces < len(params.Locations) {
+		return nil, errors.Wrapf(ErrTooManyPlaces, "places: %d", len(params.Locations))
+	}
+
+	panels := make([]*CreateAmeshImageParams, 0, len(params.Locations))
+	for _, location := range params.Locations {
+		panel := &CreateAmeshImageParams{
+			Client:         params.Client,
+			Lat:            location.Lat,
+			Lng:            location.Lng,
+			Zoom:           comparisonZoom,
+			AroundTiles:    comparisonAroundTiles,
+			TimestampCache: params.TimestampCache,
+			NoRadarData:    params.NoRadarData,
+			StaleThreshold: params.StaleThreshold,
+			OverlayStyles:  params.OverlayStyles,
+			MapStyle:       params.MapStyle,
+			Clock:          params.Clock,
+			Overlays:       params.Overlays,
+		}
+		if err := validateMapParams(panel); err != nil {
+			return nil, errors.Wrap(err, "Failed to validateMapParams")
+		}
+		panels = append(panels, panel)
+	}
+
+	// レイヤーは描画範囲に依存しないため、タイムスタンプの取得は最初のパネルの1回だけにする
+	layers, err := DefaultLayers(ctx, panels[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to DefaultLayers")
+	}
+
+	viewports := make([]*Viewport, 0, len(panels))
+	panelLayers := make([][]Layer, 0, len(panels))
+	for i, panel := range panels {
+		viewports = append(viewports, &Viewport{
+			Lat:         panel.Lat,
+			Lng:         panel.Lng,
+			Zoom:        panel.Zoom,
+			AroundTiles: panel.AroundTiles,
+		})
+		panelLayers = append(panelLayers, append(slices.Clone(layers), &LabelLayer{
+			Text: fmt.Sprintf("%d %.2f,%.2f", i+1, panel.Lat, panel.Lng),
+		}))
+	}
+	rendered, bbox := renderPanels(ctx, viewports, panelLayers)
+
+	result := &AmeshResult{
+		Image:         rendered.Image,
+		AmeshMetadata: rendered.metadata(layers),
+	}
+	result.BoundingBox = bbox
+	for _, location := range params.Locations {
+		result.Locations = append(result.Locations, *location)
+	}
+	return result, nil
+}
+
+// renderPanels パネルごとのレイヤーを描画し、パネル間に余白を空けて左から横に並べる
+// 全パネルは同じ大きさで描画し、タイルの取得結果と描画範囲内の地点数は全パネル分を合算する
+// 全パネルの描画範囲を含む範囲を合わせて返す
+func renderPanels(ctx context.Context, viewports []*Viewport, panelLayers [][]Layer) (*RenderResult, BoundingBox) {
+	return renderGrid(ctx, viewports, panelLayers, len(viewports))
+}
+
+// renderGrid パネルごとのレイヤーを描画し、パネル間に余白を空けて1行にcolumns枚ずつ左上から並べる
+// 最後の行が埋まらない場合は右側を背景色のままにする（それ以外はrenderPanelsと同じ）
+func renderGrid(ctx context.Context, viewports []*Viewport, panelLayers [][]Layer, columns int) (*RenderResult, BoundingBox) {
+	panelSize := viewports[0].Size()
+	columns = min(columns, len(viewports))
+	rows := (len(viewports) + columns - 1) / columns
+	width := columns*panelSize + (columns-1)*comparisonPanelGap
+	height := rows*panelSize + (rows-1)*comparisonPanelGap
+	rendered := &RenderResult{Image: getCanvas(width, height)}
+	draw.Draw(rendered.Image, rendered.Image.Bounds(), image.NewUniform(color.RGBA{R: 64, G: 64, B: 64, A: 255}), image.Point{}, draw.Src)
+
+	// 全パネルの描画範囲を含む範囲
+	var bbox *BoundingBox
+
+	for i, viewport := range viewports {
+		panelResult := RenderLayers(ctx, viewport, panelLayers[i])
+
+		x := i % columns * (panelSize + comparisonPanelGap)
+		y := i / columns * (panelSize + comparisonPanelGap)
+		draw.Draw(rendered.Image, image.Rect(x, y, x+panelSize, y+panelSize), panelResult.Image, image.Point{}, draw.Src)
+		putCanvas(panelResult.Image)
+		rendered.Tiles.add(panelResult.Tiles)
+		rendered.Points += panelResult.Points
+		bbox = viewport.BoundingBox().union(bbox)
+	}
+	return rendered, *bbox
+}
+
+// CreateImageBufferForLocations 地点が1つの場合は通常の画像、複数の場合は比較画像をメモリ上に作成してbytes.Bufferを返す
+func CreateImageBufferForLocations(ctx context.Context, locations []*Location, overlays []OverlayName) (*bytes.Buffer, error) {
+	result, err := createLocationsImage(ctx, defaultLocationsParams(locations, overlays))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to createLocationsImage")
+	}
+	defer putCanvas(result.Image)
+
+	buf, err := encodePNG(result.Image, result.PNGText())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to encodePNG")
+	}
+
+	return buf, nil
+}
+
+// CreateImageReaderForLocations 地点が1つの場合は通常の画像、複数の場合は比較画像を作成し、
+// PNG形式にエンコードしながら読み出すImageReaderを返す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateImageReaderForLocations(ctx context.Context, locations []*Location, overlays []OverlayName) (*ImageReader, error) {
+	return CreateImageReaderForLocationsWithClient(ctx, defaultLocationsParams(locations, overlays))
+}
+
+// CreateImageReaderForLocationsWithClient HTTPクライアントを指定してCreateImageReaderForLocationsと同じ画像を作成する
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateImageReaderForLocationsWithClient(ctx context.Context, params *CreateComparisonImageParams) (*ImageReader, error) {
+	result, err := createLocationsImage(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to createLocationsImage")
+	}
+	return result.Reader(), nil
+}
+
+// defaultLocationsParams 既定のHTTPクライアントとtargetTimesのキャッシュで画像を作成するパラメータを返す
+func defaultLocationsParams(locations []*Location, overlays []OverlayName) *CreateComparisonImageParams {
+	return &CreateComparisonImageParams{
+		Client:         defaultClient,
+		Locations:      locations,
+		TimestampCache: defaultTimestampCache,
+		Overlays:       overlays,
+		StaleThreshold: getStaleThreshold(),
+		OverlayStyles:  getOverlayStyles(),
+		MapStyle:       getMapStyle(),
+	}
+}
+
+// createLocationsImage 地点が1つの場合は通常の画像、複数の場合は比較画像を作成する
+func createLocationsImage(ctx context.Context, params *CreateComparisonImageParams) (*AmeshResult, error) {
+	if params == nil || params.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+	if len(params.Locations) == 1 {
+		return createLocationImage(ctx, &LocationImageParams{
+			Client:         params.Client,
+			Location:       params.Locations[0],
+			TimestampCache: params.TimestampCache,
+			Overlays:       params.Overlays,
+			StaleThreshold: params.StaleThreshold,
+			OverlayStyles:  params.OverlayStyles,
+			MapStyle:       params.MapStyle,
+			Clock:          params.Clock,
+		})
+	}
+
+	result, err := CreateComparisonImage(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to CreateComparisonImage")
+	}
+	logTileStats(ctx, result.Tiles)
+	return result, nil
+}
+
+// ParseLocationsWithClient HTTPクライアントを指定して地名文字列から1つ以上の位置を解析する
+// 文字列全体で位置が見つかればその1か所を返し、見つからない場合は空白区切りの各単語を別の地点として解析する
+// （「新宿 駅」は1か所、「東京 大阪」は2か所として扱う）
+func ParseLocationsWithClient(ctx context.Context, req *ParseLocationParams) ([]*Location, error) {
+	if req == nil || (req.Client == nil && req.Geocoder == nil) {
+		return nil, lib.ErrParamsNil
+	}
+
+	location, err := ParseLocationWithClient(ctx, req)
+	if err == nil {
+		return []*Location{location}, nil
+	}
+
+	words := strings.Fields(req.GeocodeRequest.Place)
+	if !errors.Is(err, ErrNoResultsFound) || len(words) < 2 {
+		return nil, errors.Wrap(err, "Failed to ParseLocationWithClient")
+	}
+	if maxComparePlaces < len(words) {
+		return nil, errors.Wrapf(ErrTooManyPlaces, "places: %d", len(words))
+	}
+
+	locations := make([]*Location, 0, len(words))
+	for _, word := range words {
+		location, err := ParseLocationWithClient(ctx, &ParseLocationParams{
+			Client: req.Client,
+			GeocodeRequest: GeocodeRequest{
+				Place:  word,
+				APIKey: req.GeocodeRequest.APIKey,
+			},
+			Geocoder: req.Geocoder,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to ParseLocationWithClient")
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// ParseLocationsWithLog 地名文字列から1つ以上の位置を解析してログに出力する
+func ParseLocationsWithLog(ctx context.Context, place, apiKey string) ([]*Location, error) {
+	locations, err := ParseLocationsWithClient(ctx, &ParseLocationParams{
+		Client: defaultClient,
+		GeocodeRequest: GeocodeRequest{
+			Place:  place,
+			APIKey: apiKey,
+		},
+		Geocoder: getDefaultGeocoder(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ParseLocationsWithClient")
+	}
+
+	for _, location := range locations {
+		requestid.Logf(ctx, "Generating amesh image for %s (%.4f, %.4f)\n", location.PlaceName, location.Lat, location.Lng)
+	}
+	return locations, nil
+}
+
+// ComparisonPlaceName 比較画像のパネル番号を付けた地名の一覧を返す（例: 1.東京都 / 2.大阪府）
+func ComparisonPlaceName(locations []*Location) string {
+	names := make([]string, 0, len(locations))
+	for i, location := range locations {
+		names = append(names, fmt.Sprintf("%d.%s", i+1, location.PlaceName))
+	}
+	return strings.Join(names, " / ")
+}
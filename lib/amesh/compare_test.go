@@ -0,0 +1,224 @@
+package amesh_test
+
+import (
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+// geocodeRoute 地名に対するジオコーダの応答を定義する
+// 座標が空の場合は結果なしを返す
+func geocodeRoute(place, name, coordinates string) httpclient.MockRoute {
+	body := `{"Feature": []}`
+	if coordinates != "" {
+		body = fmt.Sprintf(`{"Feature": [{"Name": %q, "Geometry": {"Coordinates": %q}}]}`, name, coordinates)
+	}
+	return httpclient.MockRoute{
+		Pattern:   "query=" + url.QueryEscape(place) + "&",
+		Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: body}},
+	}
+}
+
+func TestParseLocationsWithClient(t *testing.T) {
+	routes := []httpclient.MockRoute{
+		geocodeRoute("新宿 駅", "新宿駅", "139.7005,35.6896"),
+		geocodeRoute("東京 大阪", "", ""),
+		geocodeRoute("東京 どこか", "", ""),
+		geocodeRoute("東京", "東京都", "139.6917,35.6895"),
+		geocodeRoute("大阪", "大阪府", "135.5200,34.6864"),
+		geocodeRoute("どこか", "", ""),
+	}
+
+	tests := []struct {
+		name        string
+		place       string
+		expected    []*amesh.Location
+		expectError error
+	}{
+		{
+			name:     "空白を含む1か所の地名",
+			place:    "新宿 駅",
+			expected: []*amesh.Location{{Lat: 35.6896, Lng: 139.7005, PlaceName: "新宿駅"}},
+		},
+		{
+			name:  "複数の地名",
+			place: "東京 大阪",
+			expected: []*amesh.Location{
+				{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"},
+				{Lat: 34.6864, Lng: 135.52, PlaceName: "大阪府"},
+			},
+		},
+		{
+			name:     "空白区切りの座標は1か所",
+			place:    "35.6895 139.6917",
+			expected: []*amesh.Location{{Lat: 35.6895, Lng: 139.6917, PlaceName: "35.69,139.69"}},
+		},
+		{
+			name:        "見つからない地名を含む",
+			place:       "東京 どこか",
+			expectError: amesh.ErrNoResultsFound,
+		},
+		{
+			name:        "地点が多すぎる",
+			place:       "a b c d e",
+			expectError: amesh.ErrTooManyPlaces,
+		},
+		{
+			name:        "1語の地名が見つからない",
+			place:       "どこか",
+			expectError: amesh.ErrNoResultsFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes:   routes,
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"Feature": []}`},
+			})
+
+			result, err := amesh.ParseLocationsWithClient(t.Context(), &amesh.ParseLocationParams{
+				Client:         transport.Client(),
+				GeocodeRequest: amesh.GeocodeRequest{Place: tt.place, APIKey: "test_key"},
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ParseLocationsWithClient() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseLocationsWithClient(%q) diff: %s", tt.place, diff)
+			}
+		})
+	}
+}
+
+func TestCreateComparisonImage(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokyo := &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"}
+	osaka := &amesh.Location{Lat: 34.6864, Lng: 135.52, PlaceName: "大阪府"}
+
+	tests := []struct {
+		name          string
+		locations     []*amesh.Location
+		expectedWidth int
+		expectError   error
+	}{
+		{
+			name:          "2か所を横に並べる",
+			locations:     []*amesh.Location{tokyo, osaka},
+			expectedWidth: 768*2 + 8,
+		},
+		{
+			name:          "1か所でも描画できる",
+			locations:     []*amesh.Location{tokyo},
+			expectedWidth: 768,
+		},
+		{
+			name:        "地点が多すぎる",
+			locations:   []*amesh.Location{tokyo, osaka, tokyo, osaka, tokyo},
+			expectError: amesh.ErrTooManyPlaces,
+		},
+		{
+			name:        "地点がない",
+			locations:   nil,
+			expectError: lib.ErrParamsNil,
+		},
+		{
+			name:        "範囲外の座標",
+			locations:   []*amesh.Location{tokyo, {Lat: 91, Lng: 0}},
+			expectError: amesh.ErrCoordinateOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+						{"basetime": "20240101120500", "validtime": "20240101120500", "elements": ["hrpns_nd"]}
+					]`}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			result, err := amesh.CreateComparisonImage(t.Context(), &amesh.CreateComparisonImageParams{
+				Client:    transport.Client(),
+				Locations: tt.locations,
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("CreateComparisonImage() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+
+			if bounds := result.Image.Bounds(); bounds.Dx() != tt.expectedWidth || bounds.Dy() != 768 {
+				t.Errorf("image size = %v, want %dx768", bounds.Size(), tt.expectedWidth)
+			}
+
+			// 描画範囲は全パネルの中心を含む
+			for _, location := range tt.locations {
+				if bbox := result.BoundingBox; !(bbox.MinLat < location.Lat && location.Lat < bbox.MaxLat && bbox.MinLng < location.Lng && location.Lng < bbox.MaxLng) {
+					t.Errorf("BoundingBox = %+v does not contain %s", bbox, location.PlaceName)
+				}
+			}
+
+			// タイムスタンプはパネルの数によらず1回だけ取得する
+			for _, targetTimes := range []string{"targetTimes_N1", "targetTimes_N2", "targetTimes_N3"} {
+				if got := len(transport.RequestsTo(targetTimes)); got != 1 {
+					t.Errorf("%s requested %d times, want 1", targetTimes, got)
+				}
+			}
+
+			// 各パネルの左下にラベルを描画する
+			for i := range tt.locations {
+				x := i * (768 + 8)
+				if label := result.Image.RGBAAt(x+1, 767); label == (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+					t.Errorf("panel %d label pixel = %v, want dark", i+1, label)
+				}
+			}
+		})
+	}
+}
+
+func TestComparisonPlaceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		locations []*amesh.Location
+		expected  string
+	}{
+		{
+			name:      "1か所",
+			locations: []*amesh.Location{{PlaceName: "東京都"}},
+			expected:  "1.東京都",
+		},
+		{
+			name:      "複数",
+			locations: []*amesh.Location{{PlaceName: "東京都"}, {PlaceName: "大阪府"}},
+			expected:  "1.東京都 / 2.大阪府",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := amesh.ComparisonPlaceName(tt.locations); result != tt.expected {
+				t.Errorf("ComparisonPlaceName() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package amesh
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrCoordinateOutOfRange 緯度・経度が範囲外であることを表すエラー
+var ErrCoordinateOutOfRange = errors.New("coordinate out of range")
+
+// errNotCoordinatePair 文字列が座標の組ではないことを表すエラー（地名としてジオコーディングする）
+var errNotCoordinatePair = errors.New("not a coordinate pair")
+
+// ErrInvalidZoom ズームレベルが範囲外であることを表すエラー
+var ErrInvalidZoom = errors.New("invalid zoom level")
+
+// 緯度・経度の範囲
+const (
+	maxLatitude  = 90.0
+	maxLongitude = 180.0
+	// maxMercatorLatitude Webメルカトル投影で描画できる緯度の上限（atan(sinh(π))）
+	maxMercatorLatitude = 85.05112878
+)
+
+// ズームレベルの範囲
+const (
+	minZoom = 0
+	maxZoom = 30
+)
+
+// dmsPattern 度分秒（35°41'22"N など）または方位付きの十進数（35.68N など）のパターン
+var dmsPattern = regexp.MustCompile(
+	`^([+-]?\d+(?:\.\d+)?)(?:°(?:(\d+(?:\.\d+)?)['′])?(?:(\d+(?:\.\d+)?)(?:"|″|''))?)?([NSEWnsew])?$`,
+)
+
+// 度分秒の途中の空白のパターン（35° 41' 22" N → 35°41'22"N に正規化する）
+var (
+	dmsPartSpacePattern       = regexp.MustCompile(`([°'′])\s+(\d+(?:\.\d+)?(?:['′"″]|''))`)
+	dmsHemisphereSpacePattern = regexp.MustCompile(`([\d°'′"″])\s+([NSEWnsew])\b`)
+)
+
+// coordinateValue 緯度または経度の値
+type coordinateValue struct {
+	Value      float64 // 度単位の値（南緯・西経は負）
+	Hemisphere byte    // 方位（N/S/E/W、指定がない場合は0）
+}
+
+// isLongitude 方位が経度を表すか
+func (v *coordinateValue) isLongitude() bool {
+	return v.Hemisphere == 'E' || v.Hemisphere == 'W'
+}
+
+// parseCoordinateValue 十進数または度分秒の文字列を解析する
+func parseCoordinateValue(s string) (*coordinateValue, error) {
+	matches := dmsPattern.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, errors.Wrapf(ErrInvalidCoordinatesFormat, "value: %s", s)
+	}
+
+	degrees, err := parseFloat64(matches[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseFloat64")
+	}
+
+	// 分・秒を度に換算
+	for i, divisor := range []float64{60, 3600} {
+		if matches[2+i] == "" {
+			continue
+		}
+		part, err := parseFloat64(matches[2+i])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to parseFloat64")
+		}
+		if 60 <= part {
+			return nil, errors.Wrapf(ErrInvalidCoordinatesFormat, "value: %s", s)
+		}
+		degrees += part / divisor
+	}
+
+	value := &coordinateValue{Value: degrees}
+	if matches[4] != "" {
+		value.Hemisphere = strings.ToUpper(matches[4])[0]
+		if strings.HasPrefix(matches[1], "-") {
+			return nil, errors.Wrapf(ErrInvalidCoordinatesFormat, "value: %s", s)
+		}
+		if value.Hemisphere == 'S' || value.Hemisphere == 'W' {
+			value.Value = -value.Value
+		}
+	}
+	return value, nil
+}
+
+// splitCoordinatePair 座標の文字列を緯度と経度の2つに分割する
+// 「35.6 139.7」「35.6,139.7」「geo:35.6,139.7」「35°41'N 139°41'E」に対応する
+func splitCoordinatePair(place string) []string {
+	text := strings.TrimSpace(place)
+
+	// Geo URI（RFC 5870）は高度（3つ目の値）と;以降のパラメータを無視する
+	if len(text) >= 4 && strings.EqualFold(text[:4], "geo:") {
+		text, _, _ = strings.Cut(text[4:], ";")
+		parts := strings.Split(text, ",")
+		if len(parts) == 3 {
+			parts = parts[:2]
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts
+	}
+
+	// 分と秒の2回分の空白を詰める
+	for range 2 {
+		text = dmsPartSpacePattern.ReplaceAllString(text, "$1$2")
+	}
+	text = dmsHemisphereSpacePattern.ReplaceAllString(text, "$1$2")
+	if strings.Contains(text, ",") {
+		parts := strings.Split(text, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts
+	}
+	return strings.Fields(text)
+}
+
+// parseCoordinates 文字列から座標を直接解析する
+// 座標の形式でない場合はerrNotCoordinatePair、範囲外の場合はErrCoordinateOutOfRangeを返す
+func parseCoordinates(place string) (*Location, error) {
+	parts := splitCoordinatePair(place)
+	if len(parts) != 2 {
+		return nil, errNotCoordinatePair
+	}
+
+	first, err := parseCoordinateValue(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(errors.Join(errNotCoordinatePair, err), "Failed to parseCoordinateValue")
+	}
+	second, err := parseCoordinateValue(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(errors.Join(errNotCoordinatePair, err), "Failed to parseCoordinateValue")
+	}
+
+	// 方位が経度・緯度の順に指定された場合は入れ替える
+	lat, lng := first, second
+	if first.isLongitude() && second.Hemisphere != 0 && !second.isLongitude() {
+		lat, lng = second, first
+	}
+	if lat.isLongitude() || (lng.Hemisphere != 0 && !lng.isLongitude()) {
+		return nil, errors.Wrapf(ErrInvalidCoordinatesFormat, "place: %s", place)
+	}
+
+	if lat.Value < -maxLatitude || maxLatitude < lat.Value {
+		return nil, errors.Wrapf(ErrCoordinateOutOfRange, "latitude: %f", lat.Value)
+	}
+	if lng.Value < -maxLongitude || maxLongitude < lng.Value {
+		return nil, errors.Wrapf(ErrCoordinateOutOfRange, "longitude: %f", lng.Value)
+	}
+
+	return &Location{
+		Lat:       lat.Value,
+		Lng:       lng.Value,
+		PlaceName: fmt.Sprintf("%.2f,%.2f", lat.Value, lng.Value),
+	}, nil
+}
+
+// validateMapParams 地図の中心座標とズームレベルが描画できる範囲か検証する
+func validateMapParams(params *CreateAmeshImageParams) error {
+	if params.Zoom < minZoom || maxZoom < params.Zoom {
+		return errors.Wrapf(ErrInvalidZoom, "zoom: %d", params.Zoom)
+	}
+	if math.IsNaN(params.Lat) || params.Lat < -maxMercatorLatitude || maxMercatorLatitude < params.Lat {
+		return errors.Wrapf(ErrCoordinateOutOfRange, "latitude: %f", params.Lat)
+	}
+	if math.IsNaN(params.Lng) || params.Lng < -maxLongitude || maxLongitude < params.Lng {
+		return errors.Wrapf(ErrCoordinateOutOfRange, "longitude: %f", params.Lng)
+	}
+	return nil
+}
+
+// tileCount ズームレベルでの1辺あたりのタイル数
+func tileCount(zoom int) int {
+	return 1 << uint(zoom)
+}
+
+// wrapTileX 経度方向のタイル番号を0〜タイル数-1に折り返す
+// 日付変更線をまたぐ場合に反対側のタイルを取得する
+func wrapTileX(tileX, zoom int) int {
+	n := tileCount(zoom)
+	return ((tileX % n) + n) % n
+}
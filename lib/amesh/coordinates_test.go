@@ -0,0 +1,193 @@
+package amesh
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+)
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		name        string
+		place       string
+		expected    *Location
+		expectError error
+	}{
+		{
+			name:     "空白区切り",
+			place:    "35.6895 139.6917",
+			expected: &Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "35.69,139.69"},
+		},
+		{
+			name:     "カンマ区切り",
+			place:    "35.6895,139.6917",
+			expected: &Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "35.69,139.69"},
+		},
+		{
+			name:     "カンマと空白区切り",
+			place:    "-33.8688, 151.2093",
+			expected: &Location{Lat: -33.8688, Lng: 151.2093, PlaceName: "-33.87,151.21"},
+		},
+		{
+			name:     "Geo URI",
+			place:    "geo:35.6895,139.6917",
+			expected: &Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "35.69,139.69"},
+		},
+		{
+			name:     "高度とパラメータ付きのGeo URI",
+			place:    "GEO:35.6895,139.6917,40;u=10",
+			expected: &Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "35.69,139.69"},
+		},
+		{
+			name:     "度分",
+			place:    "35°41'N 139°41'E",
+			expected: &Location{Lat: 35 + 41.0/60, Lng: 139 + 41.0/60, PlaceName: "35.68,139.68"},
+		},
+		{
+			name:     "度分秒と空白",
+			place:    `35° 41' 22.2" N, 139° 41' 30" E`,
+			expected: &Location{Lat: 35 + 41.0/60 + 22.2/3600, Lng: 139 + 41.0/60 + 30.0/3600, PlaceName: "35.69,139.69"},
+		},
+		{
+			name:     "南緯・西経",
+			place:    "22°54′S 43°10′W",
+			expected: &Location{Lat: -(22 + 54.0/60), Lng: -(43 + 10.0/60), PlaceName: "-22.90,-43.17"},
+		},
+		{
+			name:     "経度・緯度の順の方位付き",
+			place:    "139.7E 35.7N",
+			expected: &Location{Lat: 35.7, Lng: 139.7, PlaceName: "35.70,139.70"},
+		},
+		{
+			name:        "緯度が範囲外",
+			place:       "91,139.7",
+			expectError: ErrCoordinateOutOfRange,
+		},
+		{
+			name:        "経度が範囲外",
+			place:       "geo:35.6,181",
+			expectError: ErrCoordinateOutOfRange,
+		},
+		{
+			name:        "分が60以上",
+			place:       "35°61'N 139°41'E",
+			expectError: errNotCoordinatePair,
+		},
+		{
+			name:        "方位が両方とも緯度",
+			place:       "35N 139N",
+			expectError: ErrInvalidCoordinatesFormat,
+		},
+		{
+			name:        "地名",
+			place:       "東京 タワー",
+			expectError: errNotCoordinatePair,
+		},
+		{
+			name:        "数値が1つのみ",
+			place:       "35.6",
+			expectError: errNotCoordinatePair,
+		},
+		{
+			name:        "NaNは座標として扱わない",
+			place:       "NaN NaN",
+			expectError: errNotCoordinatePair,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := parseCoordinates(tt.place)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("parseCoordinates(%q) error = %v, expectError = %v", tt.place, err, tt.expectError)
+			}
+			if tt.expected == nil {
+				if result != nil {
+					t.Errorf("parseCoordinates(%q) = %+v, want nil", tt.place, result)
+				}
+				return
+			}
+			if math.Abs(result.Lat-tt.expected.Lat) > 1e-9 ||
+				math.Abs(result.Lng-tt.expected.Lng) > 1e-9 ||
+				result.PlaceName != tt.expected.PlaceName {
+				t.Errorf("parseCoordinates(%q) = %+v, want %+v", tt.place, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateMapParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      *CreateAmeshImageParams
+		expectError error
+	}{
+		{
+			name:   "東京",
+			params: &CreateAmeshImageParams{Lat: 35.6895, Lng: 139.6917, Zoom: 10},
+		},
+		{
+			name:   "Webメルカトルの上限",
+			params: &CreateAmeshImageParams{Lat: -maxMercatorLatitude, Lng: -180, Zoom: 0},
+		},
+		{
+			name:        "ズームレベルが負",
+			params:      &CreateAmeshImageParams{Lat: 35.6895, Lng: 139.6917, Zoom: -1},
+			expectError: ErrInvalidZoom,
+		},
+		{
+			name:        "ズームレベルが大きすぎる",
+			params:      &CreateAmeshImageParams{Lat: 35.6895, Lng: 139.6917, Zoom: 31},
+			expectError: ErrInvalidZoom,
+		},
+		{
+			name:        "Webメルカトルで描画できない緯度",
+			params:      &CreateAmeshImageParams{Lat: 89, Lng: 139.6917, Zoom: 10},
+			expectError: ErrCoordinateOutOfRange,
+		},
+		{
+			name:        "経度が範囲外",
+			params:      &CreateAmeshImageParams{Lat: 35.6895, Lng: 190, Zoom: 10},
+			expectError: ErrCoordinateOutOfRange,
+		},
+		{
+			name:        "NaN",
+			params:      &CreateAmeshImageParams{Lat: math.NaN(), Lng: 139.6917, Zoom: 10},
+			expectError: ErrCoordinateOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := validateMapParams(tt.params); !errors.Is(err, tt.expectError) {
+				t.Errorf("validateMapParams() error = %v, expectError = %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestWrapTileX(t *testing.T) {
+	tests := []struct {
+		name     string
+		tileX    int
+		zoom     int
+		expected int
+	}{
+		{name: "範囲内", tileX: 5, zoom: 4, expected: 5},
+		{name: "西側にはみ出す", tileX: -1, zoom: 4, expected: 15},
+		{name: "東側にはみ出す", tileX: 16, zoom: 4, expected: 0},
+		{name: "ズームレベル0", tileX: -1, zoom: 0, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := wrapTileX(tt.tileX, tt.zoom); got != tt.expected {
+				t.Errorf("wrapTileX(%d, %d) = %d, want %d", tt.tileX, tt.zoom, got, tt.expected)
+			}
+		})
+	}
+}
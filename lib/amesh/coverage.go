@@ -0,0 +1,110 @@
+package amesh
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCoverageLimit ListCoverageで距離円の区間ごとに返す市区町村の数の既定値
+const DefaultCoverageLimit = 5
+
+// municipalityLevel 地名の一覧で市区町村を表すマッチングレベル
+const municipalityLevel AddressLevel = 2
+
+// coverage ameshコマンドの返信に距離円の中の主な市区町村を添えるか（SetCoverageで設定する）
+var coverage atomic.Bool
+
+// CoverageBand 隣り合う2つの距離円の間（最も内側は中心から1つ目の円まで）にある主な市区町村
+type CoverageBand struct {
+	InnerKm float64  // 内側の円の半径（キロメートル、最も内側の区間は0）
+	OuterKm float64  // 外側の円の半径（キロメートル）
+	Places  []string // 中心から近い順の市区町村名
+	More    int      // 上限を超えたため省略した市区町村の数
+}
+
+// municipalities 距離円の中を調べる主な市区町村（埋め込みの地名の一覧の市と東京23区、役所の所在地）
+// 政令指定都市の区は市と重複するため含めない
+var municipalities = sync.OnceValue(func() []Location {
+	records, err := parseGazetteerRecords(gazetteerCSV)
+	if err != nil {
+		log.Printf("Failed to parseGazetteerRecords: %v", err)
+		return nil
+	}
+
+	var found []Location
+	for _, record := range records {
+		if record.location.AddressLevel != municipalityLevel {
+			continue
+		}
+		city := strings.HasSuffix(record.name, "市")
+		specialWard := strings.HasSuffix(record.name, "区") && !strings.HasSuffix(record.parent, "市")
+		if city || specialWard {
+			location := record.location
+			location.PlaceName = record.name
+			found = append(found, location)
+		}
+	}
+	return found
+})
+
+// ListCoverage ameshの画像に描画する距離円の区間ごとに、区間の中にある主な市区町村を中心から近い順に返す
+// 市区町村は埋め込みの地名の一覧の役所の所在地で判定し、外部サービスにはアクセスしない
+// 区間ごとにlimit件（0以下の場合はDefaultCoverageLimit）まで返し、市区町村がない区間は含めない
+func ListCoverage(location *Location, limit int) []CoverageBand {
+	if location == nil {
+		return nil
+	}
+	if limit <= 0 {
+		limit = DefaultCoverageLimit
+	}
+
+	type candidate struct {
+		name       string
+		distanceKm float64
+	}
+	var candidates []candidate
+	for _, municipality := range municipalities() {
+		distanceKm := MeasureDistance(location, &municipality).DistanceKm
+		if distanceKm <= circleRadiiKm[len(circleRadiiKm)-1] {
+			candidates = append(candidates, candidate{name: municipality.PlaceName, distanceKm: distanceKm})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Compare(a.distanceKm, b.distanceKm)
+	})
+
+	var bands []CoverageBand
+	innerKm := 0.0
+	for _, outerKm := range circleRadiiKm {
+		band := CoverageBand{InnerKm: innerKm, OuterKm: outerKm}
+		for _, c := range candidates {
+			if outerKm < c.distanceKm || (0 < innerKm && c.distanceKm <= innerKm) {
+				continue
+			}
+			if len(band.Places) < limit {
+				band.Places = append(band.Places, c.name)
+			} else {
+				band.More++
+			}
+		}
+		if 0 < len(band.Places) {
+			bands = append(bands, band)
+		}
+		innerKm = outerKm
+	}
+	return bands
+}
+
+// SetCoverage ameshコマンドの1か所の地点の返信に、距離円の中の主な市区町村を添えるかを設定する
+func SetCoverage(enabled bool) {
+	coverage.Store(enabled)
+}
+
+// Coverage SetCoverageで設定した、ameshコマンドの返信に距離円の中の主な市区町村を添えるかを返す
+func Coverage() bool {
+	return coverage.Load()
+}
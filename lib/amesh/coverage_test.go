@@ -0,0 +1,69 @@
+package amesh_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+)
+
+func TestListCoverage(t *testing.T) {
+	tests := []struct {
+		name     string
+		location *amesh.Location
+		limit    int
+		expected []amesh.CoverageBand
+	}{
+		{
+			name:     "那覇市役所を中心に区間ごとに近い順に並べる",
+			location: &amesh.Location{Lat: 26.2124, Lng: 127.6809},
+			limit:    3,
+			expected: []amesh.CoverageBand{
+				{InnerKm: 0, OuterKm: 10, Places: []string{"那覇市", "浦添市", "豊見城市"}, More: 1},
+				{InnerKm: 10, OuterKm: 20, Places: []string{"南城市", "宜野湾市", "沖縄市"}},
+				{InnerKm: 20, OuterKm: 30, Places: []string{"うるま市"}},
+			},
+		},
+		{
+			name:     "上限を省略すると既定の件数",
+			location: &amesh.Location{Lat: 26.2124, Lng: 127.6809},
+			expected: []amesh.CoverageBand{
+				{InnerKm: 0, OuterKm: 10, Places: []string{"那覇市", "浦添市", "豊見城市", "糸満市"}},
+				{InnerKm: 10, OuterKm: 20, Places: []string{"南城市", "宜野湾市", "沖縄市"}},
+				{InnerKm: 20, OuterKm: 30, Places: []string{"うるま市"}},
+			},
+		},
+		{
+			name:     "政令指定都市の区は含めず東京23区は含める",
+			location: &amesh.Location{Lat: 35.6812, Lng: 139.7671},
+			limit:    2,
+			expected: []amesh.CoverageBand{
+				{InnerKm: 0, OuterKm: 10, Places: []string{"中央区", "千代田区"}, More: 15},
+				{InnerKm: 10, OuterKm: 20, Places: []string{"葛飾区", "足立区"}, More: 19},
+				{InnerKm: 20, OuterKm: 30, Places: []string{"朝霞市", "調布市"}, More: 24},
+				{InnerKm: 30, OuterKm: 40, Places: []string{"八千代市", "我孫子市"}, More: 27},
+				{InnerKm: 40, OuterKm: 50, Places: []string{"福生市", "桶川市"}, More: 27},
+			},
+		},
+		{
+			name:     "距離円の中に市区町村がない",
+			location: &amesh.Location{Lat: 30, Lng: 140},
+			expected: nil,
+		},
+		{
+			name:     "地点がない",
+			location: nil,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, amesh.ListCoverage(tt.location, tt.limit)); diff != "" {
+				t.Errorf("ListCoverage() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package amesh
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+// ErrDistanceUsage distanceコマンドで2つの地名が「/」で区切られていないことを表すエラー
+var ErrDistanceUsage = errors.New("distance command requires two places separated by /")
+
+// 距離の地図画像の定数
+const (
+	distanceMinZoom     = 3  // 2地点が離れている場合に使う最小のズームレベル（日本全体が収まる程度）
+	distancePadding     = 32 // 画像の端と地点のマーカーの間に空ける余白（ピクセル）
+	distanceSegments    = 64 // 2地点を結ぶ大円を近似する線分の数
+	distanceLineOffsets = 1  // 線を太くするために上下左右にずらして重ねるピクセル数
+)
+
+// 距離の地図画像の色
+var (
+	distanceFromColor = color.RGBA{R: 230, A: 255}
+	distanceToColor   = color.RGBA{G: 90, B: 230, A: 255}
+	distanceLineColor = color.RGBA{R: 230, G: 0, B: 120, A: 255}
+)
+
+// compassDirections 方位角（北から時計回りに22.5度ごと）の16方位名
+var compassDirections = map[i18n.Locale][]string{
+	i18n.LocaleJa: {
+		"北", "北北東", "北東", "東北東", "東", "東南東", "南東", "南南東",
+		"南", "南南西", "南西", "西南西", "西", "西北西", "北西", "北北西",
+	},
+	i18n.LocaleEn: {
+		"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+		"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+	},
+}
+
+// ParseDistanceCommandResult distanceコマンドの解析結果
+// 地名が「/」で区切られていない場合はFromとToが空になる
+type ParseDistanceCommandResult struct {
+	From       string
+	To         string
+	IsDistance bool
+}
+
+// Distance 2地点間の大円距離と方位角
+type Distance struct {
+	From       Location // 出発地点
+	To         Location // 到着地点
+	DistanceKm float64  // 大円距離（キロメートル）
+	Bearing    float64  // 出発地点から見た到着地点の方位角（北を0度とする時計回りの度、0以上360未満）
+}
+
+// DistanceImageParams 2地点を結んだ地図画像を作成する関数（CreateDistanceImageReaderWithClient）のパラメータ
+type DistanceImageParams struct {
+	Client   httpclient.Doer // HTTPクライアント
+	From     *Location       // 出発地点
+	To       *Location       // 到着地点
+	MapStyle MapStyle        // ベースマップのスタイル（空の場合はMapStyleLight）
+	Clock    clock.Clock     // MapStyleAutoの昼夜の判定に使う時計（nilの場合はclock.Real）
+}
+
+// ParseDistanceCommand distanceコマンドを解析
+// 解析の前にlib.NormalizeMessageで表記ゆれとメンション・MFMの装飾を取り除く（全角の「／」も区切りとして扱う）
+func ParseDistanceCommand(text string) ParseDistanceCommandResult {
+	parsed := lib.ParseCommand(lib.NormalizeMessage(text), "distance")
+	if !parsed.Matched {
+		return ParseDistanceCommandResult{
+			From:       "",
+			To:         "",
+			IsDistance: false,
+		}
+	}
+
+	from, to, found := strings.Cut(parsed.Args, "/")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if !found || from == "" || to == "" {
+		return ParseDistanceCommandResult{
+			From:       "",
+			To:         "",
+			IsDistance: true,
+		}
+	}
+	return ParseDistanceCommandResult{
+		From:       from,
+		To:         to,
+		IsDistance: true,
+	}
+}
+
+// DistanceCommandErrorKey distanceコマンド処理のエラーに応じた返信メッセージのキーを返す
+// 外部サービスの障害と地名の解析のエラーはameshコマンドと同じメッセージにする
+func DistanceCommandErrorKey(err error) i18n.Key {
+	if errors.Is(err, ErrDistanceUsage) {
+		return i18n.KeyErrorDistanceUsage
+	}
+	if key := CommandErrorKey(err); key != i18n.KeyErrorCommand {
+		return key
+	}
+	return i18n.KeyErrorDistanceCommand
+}
+
+// MeasureDistance 2地点間の大円距離（ハーバサイン公式）と出発地点から見た方位角を計算する
+func MeasureDistance(from, to *Location) Distance {
+	earthRadius := 6371.0 // 地球半径（キロメートル）
+	lat1, lat2 := deg2rad(from.Lat), deg2rad(to.Lat)
+	dLat := lat2 - lat1
+	dLng := deg2rad(to.Lng - from.Lng)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	bearing := math.Atan2(
+		math.Sin(dLng)*math.Cos(lat2),
+		math.Cos(lat1)*math.Sin(lat2)-math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng),
+	) * 180 / math.Pi
+
+	return Distance{
+		From:       *from,
+		To:         *to,
+		DistanceKm: 2 * earthRadius * math.Asin(math.Sqrt(a)),
+		Bearing:    math.Mod(bearing+360, 360),
+	}
+}
+
+// DistanceText 距離を表示する文字列を返す（10km未満は小数点以下2桁、それ以上は1桁）
+func (d *Distance) DistanceText() string {
+	if d.DistanceKm < 10 {
+		return fmt.Sprintf("%.2f", d.DistanceKm)
+	}
+	return fmt.Sprintf("%.1f", d.DistanceKm)
+}
+
+// BearingText 方位角を整数の度で表示する文字列を返す
+func (d *Distance) BearingText() string {
+	return fmt.Sprintf("%.0f", math.Mod(math.Round(d.Bearing), 360))
+}
+
+// Direction 方位角を言語に合わせた16方位名で返す
+func (d *Distance) Direction(locale i18n.Locale) string {
+	names, ok := compassDirections[locale]
+	if !ok {
+		names = compassDirections[i18n.DefaultLocale]
+	}
+	return names[int(math.Round(d.Bearing/22.5))%len(names)]
+}
+
+// DistanceLayers 2地点を結んだ地図画像のレイヤー構成を作成する
+// ベースマップ・大円の線・2地点のマーカー・距離のラベル・縮尺の順に重ね、気象庁のデータは取得しない
+func DistanceLayers(params *CreateAmeshImageParams, distance *Distance) []Layer {
+	return []Layer{
+		&BaseMapLayer{Client: params.Client, Dark: params.darkMap()},
+		&GreatCircleLayer{From: distance.From, To: distance.To, Color: distanceLineColor},
+		&MarkerLayer{Markers: []Marker{
+			{Lat: distance.From.Lat, Lng: distance.From.Lng, Radius: mapMarkerBorderRadius, Color: mapMarkerBorderColor},
+			{Lat: distance.From.Lat, Lng: distance.From.Lng, Radius: mapMarkerRadius, Color: distanceFromColor},
+			{Lat: distance.To.Lat, Lng: distance.To.Lng, Radius: mapMarkerBorderRadius, Color: mapMarkerBorderColor},
+			{Lat: distance.To.Lat, Lng: distance.To.Lng, Radius: mapMarkerRadius, Color: distanceToColor},
+		}},
+		&LabelLayer{Text: distance.DistanceText() + " km"},
+		&ScaleBarLayer{},
+	}
+}
+
+// CreateDistanceImageReader 既定のHTTPクライアントでCreateDistanceImageReaderWithClientを呼び出す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateDistanceImageReader(ctx context.Context, from, to *Location) (*ImageReader, error) {
+	return CreateDistanceImageReaderWithClient(ctx, &DistanceImageParams{
+		Client:   defaultClient,
+		From:     from,
+		To:       to,
+		MapStyle: getMapStyle(),
+	})
+}
+
+// CreateDistanceImageReaderWithClient HTTPクライアントを指定して2地点のマーカーと2地点を結ぶ大円の線を描画した地図画像を作成し、
+// PNG形式にエンコードしながら読み出すImageReaderを返す
+// 地図は2地点の中間を中心に、両方の地点が収まる最大のズームレベルで描画する
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateDistanceImageReaderWithClient(ctx context.Context, params *DistanceImageParams) (*ImageReader, error) {
+	if params == nil || params.Client == nil || params.From == nil || params.To == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	distance := MeasureDistance(params.From, params.To)
+	viewport := distanceViewport(params.From, params.To)
+	imageParams := &CreateAmeshImageParams{
+		Client:      params.Client,
+		Lat:         viewport.Lat,
+		Lng:         viewport.Lng,
+		Zoom:        viewport.Zoom,
+		AroundTiles: viewport.AroundTiles,
+		MapStyle:    params.MapStyle,
+		Clock:       params.Clock,
+	}
+	imageParams.Layers = DistanceLayers(imageParams, &distance)
+	result, err := CreateAmeshImage(ctx, imageParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to CreateAmeshImage")
+	}
+	result.Locations = []Location{*params.From, *params.To}
+	logTileStats(ctx, result.Tiles)
+	return result.Reader(), nil
+}
+
+// distanceViewport 2地点の中間を中心に、両方の地点が余白を空けて収まる最大のズームレベルの描画範囲を返す
+// distanceMinZoomでも収まらない場合はdistanceMinZoomを使う
+func distanceViewport(from, to *Location) *Viewport {
+	// ズームレベル0のピクセル座標の中点を中心にする（Webメルカトル図法の地図上の直線の中点）
+	fromX, fromY := getWebMercatorPixel(&CreateAmeshImageParams{Lat: from.Lat, Lng: from.Lng, Zoom: 0})
+	toX, toY := getWebMercatorPixel(&CreateAmeshImageParams{Lat: to.Lat, Lng: to.Lng, Zoom: 0})
+	centerLat, centerLng := pixelToLat((fromY+toY)/2, 256), pixelToLng((fromX+toX)/2, 256)
+
+	for zoom := maxAutoZoom; distanceMinZoom < zoom; zoom-- {
+		viewport := &Viewport{Lat: centerLat, Lng: centerLng, Zoom: zoom, AroundTiles: aroundTilesFor(zoom)}
+		inner := image.Rect(0, 0, viewport.Size(), viewport.Size()).Inset(distancePadding)
+		if viewport.ImagePoint(from.Lat, from.Lng).In(inner) && viewport.ImagePoint(to.Lat, to.Lng).In(inner) {
+			return viewport
+		}
+	}
+	return &Viewport{Lat: centerLat, Lng: centerLng, Zoom: distanceMinZoom, AroundTiles: aroundTilesFor(distanceMinZoom)}
+}
+
+// GreatCircleLayer 2地点を結ぶ大円（最短経路）の線を描画するレイヤー
+type GreatCircleLayer struct {
+	From  Location   // 出発地点
+	To    Location   // 到着地点
+	Color color.RGBA // 線の色
+}
+
+// Draw 大円をdistanceSegments個の線分で近似し、上下左右にずらして重ねた太い線で描画する
+func (l *GreatCircleLayer) Draw(_ context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	points := make([]image.Point, 0, distanceSegments+1)
+	for i := range distanceSegments + 1 {
+		lat, lng := greatCirclePoint(&l.From, &l.To, float64(i)/distanceSegments)
+		points = append(points, viewport.ImagePoint(lat, lng))
+	}
+
+	for offset := -distanceLineOffsets; offset <= distanceLineOffsets; offset++ {
+		for i := range len(points) - 1 {
+			for _, shift := range []image.Point{{X: offset}, {Y: offset}} {
+				drawLine(&drawLineParams{
+					Img: canvas,
+					X1:  points[i].X + shift.X,
+					Y1:  points[i].Y + shift.Y,
+					X2:  points[i+1].X + shift.X,
+					Y2:  points[i+1].Y + shift.Y,
+					Col: l.Color,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// greatCirclePoint 2地点を結ぶ大円上で、出発地点からの割合fの位置の緯度・経度を返す
+// 2地点が同じ場合は出発地点を返す
+func greatCirclePoint(from, to *Location, f float64) (float64, float64) {
+	lat1, lng1 := deg2rad(from.Lat), deg2rad(from.Lng)
+	lat2, lng2 := deg2rad(to.Lat), deg2rad(to.Lng)
+	angle := MeasureDistance(from, to).DistanceKm / 6371.0 // 2地点の中心角（ラジアン）
+	if angle == 0 {
+		return from.Lat, from.Lng
+	}
+
+	a := math.Sin((1-f)*angle) / math.Sin(angle)
+	b := math.Sin(f*angle) / math.Sin(angle)
+	x := a*math.Cos(lat1)*math.Cos(lng1) + b*math.Cos(lat2)*math.Cos(lng2)
+	y := a*math.Cos(lat1)*math.Sin(lng1) + b*math.Cos(lat2)*math.Sin(lng2)
+	z := a*math.Sin(lat1) + b*math.Sin(lat2)
+	return math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi, math.Atan2(y, x) * 180 / math.Pi
+}
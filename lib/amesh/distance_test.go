@@ -0,0 +1,208 @@
+package amesh_test
+
+import (
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestParseDistanceCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected amesh.ParseDistanceCommandResult
+	}{
+		{
+			name:     "シンプルなdistanceコマンド",
+			input:    "distance 東京 / 大阪",
+			expected: amesh.ParseDistanceCommandResult{From: "東京", To: "大阪", IsDistance: true},
+		},
+		{
+			name:     "全角の区切り",
+			input:    "@bot distance 札幌駅／那覇",
+			expected: amesh.ParseDistanceCommandResult{From: "札幌駅", To: "那覇", IsDistance: true},
+		},
+		{
+			name:     "区切りがない",
+			input:    "distance 東京 大阪",
+			expected: amesh.ParseDistanceCommandResult{From: "", To: "", IsDistance: true},
+		},
+		{
+			name:     "片方の地名がない",
+			input:    "distance 東京 /",
+			expected: amesh.ParseDistanceCommandResult{From: "", To: "", IsDistance: true},
+		},
+		{
+			name:     "mapコマンド",
+			input:    "map 東京",
+			expected: amesh.ParseDistanceCommandResult{From: "", To: "", IsDistance: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, amesh.ParseDistanceCommand(tt.input)); diff != "" {
+				t.Errorf("ParseDistanceCommand(%q) mismatch (-want +got):\n%s", tt.input, diff)
+			}
+		})
+	}
+}
+
+func TestDistanceCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{
+			name:     "通常のエラー",
+			err:      errors.New("something wrong"),
+			expected: i18n.KeyErrorDistanceCommand,
+		},
+		{
+			name:     "書式が正しくない",
+			err:      errors.Wrap(amesh.ErrDistanceUsage, "text: distance"),
+			expected: i18n.KeyErrorDistanceUsage,
+		},
+		{
+			name:     "サーキットブレーカーが開いている",
+			err:      errors.Wrap(httpclient.ErrCircuitOpen, "Failed to Do"),
+			expected: i18n.KeyErrorUpstreamUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := amesh.DistanceCommandErrorKey(tt.err); got != tt.expected {
+				t.Errorf("DistanceCommandErrorKey() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMeasureDistance(t *testing.T) {
+	tests := []struct {
+		name              string
+		from, to          amesh.Location
+		expectedKm        float64
+		expectedBearing   float64
+		expectedText      string
+		expectedDirection map[i18n.Locale]string
+	}{
+		{
+			name:              "東京から大阪",
+			from:              amesh.Location{Lat: 35.6812, Lng: 139.7671},
+			to:                amesh.Location{Lat: 34.7025, Lng: 135.4959},
+			expectedKm:        403.1,
+			expectedBearing:   255.6,
+			expectedText:      "403.1",
+			expectedDirection: map[i18n.Locale]string{i18n.LocaleJa: "西南西", i18n.LocaleEn: "WSW"},
+		},
+		{
+			name:              "真北",
+			from:              amesh.Location{Lat: 35, Lng: 135},
+			to:                amesh.Location{Lat: 35.05, Lng: 135},
+			expectedKm:        5.56,
+			expectedBearing:   0,
+			expectedText:      "5.56",
+			expectedDirection: map[i18n.Locale]string{i18n.LocaleJa: "北", i18n.LocaleEn: "N"},
+		},
+		{
+			name:              "北北西は北に丸めない",
+			from:              amesh.Location{Lat: 35, Lng: 135},
+			to:                amesh.Location{Lat: 35.1, Lng: 134.95},
+			expectedKm:        12.0,
+			expectedBearing:   337,
+			expectedText:      "12.0",
+			expectedDirection: map[i18n.Locale]string{i18n.LocaleJa: "北北西", i18n.LocaleEn: "NNW"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := amesh.MeasureDistance(&tt.from, &tt.to)
+			if 0.5 < math.Abs(got.DistanceKm-tt.expectedKm) {
+				t.Errorf("DistanceKm = %v, want about %v", got.DistanceKm, tt.expectedKm)
+			}
+			if 1 < math.Abs(got.Bearing-tt.expectedBearing) {
+				t.Errorf("Bearing = %v, want about %v", got.Bearing, tt.expectedBearing)
+			}
+			if text := got.DistanceText(); text != tt.expectedText {
+				t.Errorf("DistanceText() = %q, want %q", text, tt.expectedText)
+			}
+			for locale, expected := range tt.expectedDirection {
+				if direction := got.Direction(locale); direction != expected {
+					t.Errorf("Direction(%s) = %q, want %q", locale, direction, expected)
+				}
+			}
+		})
+	}
+}
+
+// TestCreateDistanceImageReaderWithClient 気象庁のデータを取得せず、ベースマップに2地点のマーカーと結ぶ線を描画することをテストする
+func TestCreateDistanceImageReaderWithClient(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+
+	imageReader, err := amesh.CreateDistanceImageReaderWithClient(t.Context(), &amesh.DistanceImageParams{
+		Client: tiles.Client(),
+		From:   &amesh.Location{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"},
+		To:     &amesh.Location{Lat: 35.4437, Lng: 139.6380, PlaceName: "横浜"},
+	})
+	if err != nil {
+		t.Fatalf("CreateDistanceImageReaderWithClient() error = %v", err)
+	}
+	defer func() { _ = imageReader.Close() }()
+	img, err := png.Decode(imageReader)
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+
+	if got := tiles.RequestsTo("jma.go.jp"); len(got) != 0 {
+		t.Errorf("JMA requests = %v, want none", got)
+	}
+	if diff := cmp.Diff([]string{amesh.ProviderOpenStreetMap}, imageReader.Providers); diff != "" {
+		t.Errorf("Providers mismatch (-want +got):\n%s", diff)
+	}
+
+	// 2地点のマーカーと結ぶ線の色がそれぞれ描画されている
+	counts := map[color.RGBA]int{}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)]++
+		}
+	}
+	colors := []struct {
+		name  string
+		color color.RGBA
+	}{
+		{name: "出発地のマーカー", color: color.RGBA{R: 230, A: 255}},
+		{name: "到着地のマーカー", color: color.RGBA{G: 90, B: 230, A: 255}},
+		{name: "2地点を結ぶ線", color: color.RGBA{R: 230, B: 120, A: 255}},
+	}
+	for _, c := range colors {
+		if counts[c.color] == 0 {
+			t.Errorf("%s %v is not drawn", c.name, c.color)
+		}
+	}
+}
+
+func TestCreateDistanceImageReaderWithClientParamsNil(t *testing.T) {
+	if _, err := amesh.CreateDistanceImageReaderWithClient(t.Context(), &amesh.DistanceImageParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("CreateDistanceImageReaderWithClient() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
@@ -0,0 +1,22 @@
+// Package amesh 地名の解析と雨雲レーダー画像の作成
+//
+// 他のGoプログラムから使える安定したAPIは次のとおり。
+//
+//   - 地名の解析: ParseLocationWithClient・ParseLocationsWithClient（ParseLocationParams）、Geocoder・NewGeocoderFromConfig
+//   - 1か所の地点の画像: CreateImageReaderWithClient・CreateForecastImageReaderWithClient・CreateMapImageReaderWithClient（LocationImageParams）
+//   - 過去の雨雲レーダーを並べた画像: CreateHistoryImageReaderWithClient（HistoryImageParams）
+//   - 複数の地点の比較画像: CreateImageReaderForLocationsWithClient（CreateComparisonImageParams）
+//   - 地点の降水強度の時系列: GetRainfallWithClient（GetRainfallWithClientParams）
+//   - 距離円の区間ごとの主な市区町村: ListCoverage
+//   - 2地点を結んだ地図画像: CreateDistanceImageReaderWithClient（DistanceImageParams）、距離と方位角: MeasureDistance
+//   - 描画するレイヤーを指定した画像: CreateAmeshImage（CreateAmeshImageParams）
+//   - 画像以外の形式: CreateGeoJSON・CreateSVG
+//   - 画像に埋め込んだ情報の読み出し: ReadPNGText
+//
+// HTTPクライアントを引数に取らない関数（CreateImageReader・ParseLocationなど）はパッケージの既定のクライアントと、
+// SetDefaultGeocoder・SetOverlayStyles・SetMapStyle・SetStaleThresholdで設定した値を使う。
+//
+// 関数の引数にする構造体は「関数名+Params」（複数の関数で共有する場合は「対象+Params」）、
+// 結果は「関数名+Result」と名付ける。
+// 名前を変えた型は型エイリアスとして以前の名前を残し、Deprecatedと記載する。
+package amesh
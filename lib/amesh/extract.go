@@ -0,0 +1,140 @@
+package amesh
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPlaceCandidates 文章から探した地名の候補のうち、ジオコーダで確かめる数の上限
+const MaxPlaceCandidates = 3
+
+// placeStopWords 地名の候補から除く、天気の話題でよく使う漢字・カタカナの語
+var placeStopWords = []string{
+	"今日", "明日", "明後日", "昨日", "今夜", "今朝", "今晩", "今週", "来週", "週末", "毎日",
+	"午前", "午後", "夕方", "夜中", "深夜", "朝方", "時間", "今後", "最近", "一日",
+	"天気", "天候", "予報", "天気予報", "雨雲", "大雨", "小雨", "雷雨", "降水", "降雪", "積雪", "台風", "洪水",
+	"晴天", "曇天", "気温", "気圧", "湿度", "雨具", "外出", "散歩", "通勤", "通学", "帰宅", "仕事", "出張", "旅行",
+	"レーダー", "アメッシュ", "ゲリラ", "ゲリラ豪雨", "豪雨", "大丈夫", "心配", "様子", "状況", "場所", "近所", "地元", "現地",
+}
+
+// sortedPlaceStopWords 長い順に並べたplaceStopWords（「天気予報」を「天気」より先に取り除く）
+var sortedPlaceStopWords = func() []string {
+	words := slices.Clone(placeStopWords)
+	slices.SortStableFunc(words, func(a, b string) int {
+		return utf8.RuneCountInString(b) - utf8.RuneCountInString(a)
+	})
+	return words
+}()
+
+// placeParticles 地名の直後に続く助詞など（これ以外のひらがなが続く漢字は「降る」のような用言の一部とみなす）
+var placeParticles = []string{
+	"は", "が", "を", "に", "で", "と", "の", "へ", "も", "や", "か", "だ", "ね", "よ",
+	"じゃ", "なら", "から", "まで", "より",
+}
+
+// isPlaceRune 地名の候補に含める文字か（漢字・カタカナと、地名でよく使う記号）
+func isPlaceRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Katakana, r) || strings.ContainsRune("ー々ヶヵ", r)
+}
+
+// isFreeFormKeyword 単語が文中のameshコマンドの指定か（前後の記号は無視する）
+func isFreeFormKeyword(word string) bool {
+	word = strings.TrimFunc(word, func(r rune) bool { return unicode.IsPunct(r) && r != '#' })
+	return strings.TrimPrefix(word, "#") == "amesh"
+}
+
+// parseFreeForm 「今日の渋谷は雨かな？ amesh」のように文中にameshを含む文章を解析する
+// ameshを含まない場合はfalseを返す
+func parseFreeForm(text string) (ParseAmeshCommandResult, bool) {
+	var sentence []string
+	layer, found := "", false
+	for _, word := range strings.Fields(text) {
+		switch {
+		case strings.HasPrefix(word, "@"):
+		case isFreeFormKeyword(word):
+			found = true
+		default:
+			if value, ok := strings.CutPrefix(word, "layer="); ok {
+				layer = value
+				continue
+			}
+			sentence = append(sentence, word)
+		}
+	}
+	if !found {
+		return ParseAmeshCommandResult{}, false
+	}
+
+	candidates := PlaceCandidates(strings.Join(sentence, " "))
+	place := "東京" // 地名が見つからない場合はameshコマンドと同じく東京
+	if 0 < len(candidates) {
+		place = candidates[0]
+	}
+	return ParseAmeshCommandResult{Place: place, IsAmesh: true, Layer: layer, Candidates: candidates}, true
+}
+
+// PlaceCandidates 話し言葉の文章から地名らしい語を可能性の高い順に返す
+// 埋め込みの地名の一覧にある地名を最優先にし、続けて漢字・カタカナの連なり（ひらがなや記号で区切る）を
+// 現れた順に返す（1文字の語は除く）
+func PlaceCandidates(text string) []string {
+	var candidates []string
+	if place := ExtractPlace(text); place != "" {
+		candidates = append(candidates, place)
+	}
+
+	for _, word := range placeWords(text) {
+		if utf8.RuneCountInString(word) < 2 || slices.Contains(candidates, word) {
+			continue
+		}
+		candidates = append(candidates, word)
+	}
+	return candidates
+}
+
+// placeWords 文章から漢字・カタカナの連なりを取り出し、天気の話題でよく使う語を取り除いて返す
+// 助詞以外のひらがなが続く連なり（「雪降ってる」の「雪降」など）は用言の一部として除く
+func placeWords(text string) []string {
+	var words []string
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !isPlaceRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isPlaceRune(runes[end]) {
+			end++
+		}
+		if !isInflected(string(runes[end:])) {
+			words = append(words, removeStopWords(string(runes[start:end]))...)
+		}
+		start = end
+	}
+	return words
+}
+
+// isInflected 漢字・カタカナの連なりに続く文字列が、助詞ではないひらがなで始まるか
+func isInflected(rest string) bool {
+	r, _ := utf8.DecodeRuneInString(rest)
+	if !unicode.Is(unicode.Hiragana, r) {
+		return false
+	}
+	// 「ニセコって雪」の「って」は助詞、「降ってる」の「って」は用言の活用
+	if after, ok := strings.CutPrefix(rest, "って"); ok {
+		r, _ = utf8.DecodeRuneInString(after)
+		return unicode.Is(unicode.Hiragana, r)
+	}
+	return !slices.ContainsFunc(placeParticles, func(particle string) bool {
+		return strings.HasPrefix(rest, particle)
+	})
+}
+
+// removeStopWords 語から天気の話題でよく使う語を取り除き、残った部分を返す（「東京天気」は「東京」にする）
+func removeStopWords(word string) []string {
+	for _, stopWord := range sortedPlaceStopWords {
+		word = strings.ReplaceAll(word, stopWord, " ")
+	}
+	return strings.Fields(word)
+}
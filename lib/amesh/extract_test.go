@@ -0,0 +1,47 @@
+package amesh_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+)
+
+func TestPlaceCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "助詞で区切った漢字の語",
+			text:     "今日の渋谷は雨かな？",
+			expected: []string{"渋谷"},
+		},
+		{
+			name:     "埋め込みの地名の一覧にある地名を優先",
+			text:     "明日の六本木と大阪市北区の天気",
+			expected: []string{"大阪市北区", "六本木"},
+		},
+		{
+			name:     "カタカナの地名",
+			text:     "ニセコって雪降ってる？",
+			expected: []string{"ニセコ"},
+		},
+		{
+			name:     "天気の話題でよく使う語と1文字の語は除く",
+			text:     "午後から大雨で傘いるかな",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, amesh.PlaceCandidates(tt.text)); diff != "" {
+				t.Errorf("PlaceCandidates(%q) diff: %s", tt.text, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package amesh
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/requestid"
+)
+
+var (
+	// ErrNoForecastData 雨雲レーダーの予測のタイムスタンプが取得できないことを表すエラー
+	ErrNoForecastData = errors.New("no forecast data available")
+	// ErrForecastComparison 複数の地点の予測を並べようとしたことを表すエラー
+	ErrForecastComparison = errors.New("forecast supports only one place")
+)
+
+// ForecastOffsets 予測のパネルに描画する、雨雲レーダーの基準時刻からの経過時間
+// 気象庁の降水ナウキャストは60分先までを5分ごとに予測する
+var ForecastOffsets = []time.Duration{30 * time.Minute, 60 * time.Minute}
+
+// 予測の画像の定数
+const (
+	forecastWord        = "forecast" // ameshコマンドで予測のパネルを並べる指定
+	forecastAroundTiles = 1          // 各パネルの周囲のタイル数（パネルを横に並べるため通常の画像より狭くする）
+)
+
+// 予測の画像のバナーの背景色
+var (
+	observedBannerColor = color.RGBA{R: 64, G: 64, B: 64, A: 255}
+	forecastBannerColor = color.RGBA{R: 96, G: 48, B: 160, A: 255}
+)
+
+// CreateForecastImageReader 既定のHTTPクライアントとtargetTimesのキャッシュでCreateForecastImageReaderWithClientを呼び出す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateForecastImageReader(ctx context.Context, location *Location, overlays []OverlayName) (*ImageReader, error) {
+	return CreateForecastImageReaderWithClient(ctx, &LocationImageParams{
+		Client:         defaultClient,
+		Location:       location,
+		TimestampCache: defaultTimestampCache,
+		Overlays:       overlays,
+		StaleThreshold: getStaleThreshold(),
+		OverlayStyles:  getOverlayStyles(),
+		MapStyle:       getMapStyle(),
+	})
+}
+
+// CreateForecastImageReaderWithClient HTTPクライアントを指定して予測のパネルを並べた画像を作成し、PNG形式にエンコードしながら読み出すImageReaderを返す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateForecastImageReaderWithClient(ctx context.Context, params *LocationImageParams) (*ImageReader, error) {
+	result, err := CreateForecastImage(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to CreateForecastImage")
+	}
+	logTileStats(ctx, result.Tiles)
+	return result.Reader(), nil
+}
+
+// CreateForecastImage 現在の雨雲レーダーの右に、ForecastOffsetsだけ先の予測の雨雲レーダーを並べた画像を作成する
+// 各パネルの上部に観測（OBSERVED）か予測（FORECAST）かと対象時刻を日本時間で表示し、落雷は観測のパネルにのみ描画する
+// 予測は現在の雨雲レーダーと同じbasetimeのものを使い、取得できない場合はErrNoForecastDataを返す
+func CreateForecastImage(ctx context.Context, params *LocationImageParams) (*AmeshResult, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+	imageParams := locationImageParams(params)
+	imageParams.AroundTiles = forecastAroundTiles
+	// 予測のない画像を予測として返さないよう、雨雲レーダーがない場合は描画しない
+	imageParams.NoRadarData = NoRadarDataFail
+	if err := validateMapParams(imageParams); err != nil {
+		return nil, errors.Wrap(err, "Failed to validateMapParams")
+	}
+
+	timestamps := getLatestTimestampsWithLog(ctx, imageParams)
+	layers, err := defaultLayers(ctx, imageParams, timestamps)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to defaultLayers")
+	}
+	radar := findRadarLayer(layers)
+	if radar == nil {
+		return nil, errors.Wrap(ErrNoForecastData, "radar overlay is not selected")
+	}
+	radarTime := parseJMATimestamp(radar.Timestamp)
+	forecastTimes := selectForecastTimes(timestamps.ValidTimes(ElementRadarForecast, radarTime), radarTime)
+	if len(forecastTimes) == 0 {
+		return nil, errors.Wrapf(ErrNoForecastData, "basetime: %s", radar.Timestamp)
+	}
+	logSkippedForecasts(ctx, forecastTimes)
+
+	viewport := &Viewport{
+		Lat:         imageParams.Lat,
+		Lng:         imageParams.Lng,
+		Zoom:        imageParams.Zoom,
+		AroundTiles: imageParams.AroundTiles,
+	}
+	viewports := []*Viewport{viewport}
+	panelLayers := [][]Layer{append(slices.Clone(layers), observedBanner(layers, radarTime))}
+	for _, validTime := range forecastTimes {
+		viewports = append(viewports, viewport)
+		panelLayers = append(panelLayers, append(forecastLayers(layers, ForecastTime{BaseTime: radarTime, ValidTime: validTime}), &BannerLayer{
+			Text:  fmt.Sprintf("FORECAST +%d MIN %s", int(validTime.Sub(radarTime).Minutes()), validTime.In(jst).Format("15:04 MST")),
+			Color: forecastBannerColor,
+		}))
+	}
+	rendered, bbox := renderPanels(ctx, viewports, panelLayers)
+
+	result := &AmeshResult{
+		Image:         rendered.Image,
+		AmeshMetadata: rendered.metadata(layers),
+	}
+	result.BoundingBox = bbox
+	result.ForecastTimes = forecastTimes
+	result.Locations = []Location{*params.Location}
+	return result, nil
+}
+
+// findRadarLayer レイヤー構成から雨雲レーダーのレイヤーを探す
+// 雨雲レーダーを描画しない場合はnilを返す
+func findRadarLayer(layers []Layer) *RadarLayer {
+	for _, layer := range layers {
+		if radar, ok := layer.(*RadarLayer); ok {
+			return radar
+		}
+	}
+	return nil
+}
+
+// selectForecastTimes baseTimeを基準時刻とする予測の対象時刻のうち、ForecastOffsetsだけ先のものを経過時間の順に返す
+// 対象時刻の予測がないものは飛ばす
+func selectForecastTimes(validTimes []time.Time, baseTime time.Time) []time.Time {
+	if baseTime.IsZero() {
+		return nil
+	}
+
+	var selected []time.Time
+	for _, offset := range ForecastOffsets {
+		validTime := baseTime.Add(offset)
+		if slices.ContainsFunc(validTimes, validTime.Equal) {
+			selected = append(selected, validTime)
+		}
+	}
+	return selected
+}
+
+// observedBanner 観測のパネルのバナーを返す
+// 雨雲レーダーのデータが古い場合は、パネルのバナーで隠れないよう古いことを表す文言と背景色にする
+func observedBanner(layers []Layer, radarTime time.Time) *BannerLayer {
+	text := "OBSERVED " + radarTime.In(jst).Format("15:04 MST")
+	if slices.ContainsFunc(layers, isStaleBannerLayer) {
+		return &BannerLayer{Text: "STALE " + text, Color: staleBannerColor}
+	}
+	return &BannerLayer{Text: text, Color: observedBannerColor}
+}
+
+// isStaleBannerLayer レイヤーが雨雲レーダーのデータが古いことを表すバナーか
+func isStaleBannerLayer(layer Layer) bool {
+	_, ok := layer.(*StaleBannerLayer)
+	return ok
+}
+
+// forecastLayers 観測のレイヤー構成から予測のパネルのレイヤー構成を作成する
+// 雨雲レーダーは予測の対象時刻のタイルに置き換え、観測値しかない落雷と観測のパネル用のバナーは描画しない
+func forecastLayers(layers []Layer, forecast ForecastTime) []Layer {
+	result := make([]Layer, 0, len(layers))
+	for _, layer := range layers {
+		switch l := layer.(type) {
+		case *RadarLayer:
+			radar := *l
+			radar.Timestamp = formatJMATimestamp(forecast.BaseTime)
+			radar.ValidTime = formatJMATimestamp(forecast.ValidTime)
+			result = append(result, &radar)
+		case *LightningLayer, *StaleBannerLayer:
+			continue
+		default:
+			result = append(result, layer)
+		}
+	}
+	return result
+}
+
+// logSkippedForecasts 対象時刻の予測がなく描画しなかったパネルの数をログに出力する
+func logSkippedForecasts(ctx context.Context, selected []time.Time) {
+	if skipped := len(ForecastOffsets) - len(selected); 0 < skipped {
+		requestid.Logf(ctx, "Skipped %d forecast panels without forecast data", skipped)
+	}
+}
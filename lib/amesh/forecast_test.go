@@ -0,0 +1,113 @@
+package amesh_test
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+)
+
+func TestCreateForecastImage(t *testing.T) {
+	tests := []struct {
+		name                  string
+		scenario              *ameshtest.Scenario
+		overlays              []amesh.OverlayName
+		expectedForecastTimes []time.Time
+		expectError           error
+	}{
+		{
+			name: "観測と30分後・60分後の予測を並べる",
+			scenario: &ameshtest.Scenario{
+				Forecast:  true,
+				Lightning: []ameshtest.Lightning{{Lat: 35.68, Lng: 139.76, Type: 1}},
+			},
+			expectedForecastTimes: []time.Time{
+				ameshtest.DefaultBaseTime.Add(30 * time.Minute),
+				ameshtest.DefaultBaseTime.Add(60 * time.Minute),
+			},
+		},
+		{
+			name:        "予測がない",
+			scenario:    &ameshtest.Scenario{},
+			expectError: amesh.ErrNoForecastData,
+		},
+		{
+			name:        "雨雲レーダーを重ねない",
+			scenario:    &ameshtest.Scenario{Forecast: true},
+			overlays:    []amesh.OverlayName{amesh.OverlayFlood},
+			expectError: amesh.ErrNoForecastData,
+		},
+		{
+			name:        "雨雲レーダーのタイムスタンプが取得できない",
+			scenario:    &ameshtest.Scenario{Forecast: true, NoTargetTimes: true},
+			expectError: amesh.ErrNoRadarData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, tt.scenario)
+
+			result, err := amesh.CreateForecastImage(t.Context(), &amesh.LocationImageParams{
+				Client:   server.Client(),
+				Location: &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"},
+				Overlays: tt.overlays,
+				Zoom:     10,
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("CreateForecastImage() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+
+			if bounds := result.Image.Bounds(); bounds.Dx() != 768*3+8*2 || bounds.Dy() != 768 {
+				t.Errorf("image size = %v, want %dx768", bounds.Size(), 768*3+8*2)
+			}
+			if diff := cmp.Diff(tt.expectedForecastTimes, result.ForecastTimes); diff != "" {
+				t.Errorf("ForecastTimes mismatch (-want +got):\n%s", diff)
+			}
+			if !result.RadarTime.Equal(ameshtest.DefaultBaseTime) {
+				t.Errorf("RadarTime = %v, want %v", result.RadarTime, ameshtest.DefaultBaseTime)
+			}
+			// 落雷は観測のパネルにのみ描画する
+			if result.LightningCount != 1 {
+				t.Errorf("LightningCount = %d, want 1", result.LightningCount)
+			}
+
+			// 予測のパネルは予測の対象時刻の雨雲レーダーのタイルを取得する
+			for _, forecastTime := range tt.expectedForecastTimes {
+				validTime := forecastTime.Format("20060102150405")
+				if got := server.RequestsTo("/none/" + validTime + "/surf/hrpns/"); len(got) == 0 {
+					t.Errorf("no radar tile requested for validtime %s", validTime)
+				}
+			}
+
+			// 各パネルの上部に観測か予測かを表すバナーを描画する
+			expectedBanners := []color.RGBA{
+				{R: 64, G: 64, B: 64, A: 255},
+				{R: 96, G: 48, B: 160, A: 255},
+				{R: 96, G: 48, B: 160, A: 255},
+			}
+			for i, expected := range expectedBanners {
+				x := i * (768 + 8)
+				if banner := result.Image.RGBAAt(x+1, 1); banner != expected {
+					t.Errorf("panel %d banner pixel = %v, want %v", i+1, banner, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateForecastImageParamsNil(t *testing.T) {
+	if _, err := amesh.CreateForecastImage(t.Context(), &amesh.LocationImageParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("CreateForecastImage() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
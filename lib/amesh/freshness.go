@@ -0,0 +1,81 @@
+package amesh
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"sync/atomic"
+	"time"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/requestid"
+)
+
+// DefaultStaleThreshold 雨雲レーダーの基準時刻がこれより古い場合にデータが古いとみなす経過時間
+// 気象庁のナウキャストは5分ごとに更新されるため、数回分の更新が止まっていれば障害とみなす
+const DefaultStaleThreshold = 20 * time.Minute
+
+// staleBannerColor 雨雲レーダーのデータが古いことを表すバナーの背景色
+var staleBannerColor = color.RGBA{R: 210, G: 120, B: 0, A: 255}
+
+// staleThreshold クライアント未指定の関数で使う、データが古いとみなす経過時間（SetStaleThresholdで設定する）
+var staleThreshold atomic.Int64
+
+// SetStaleThreshold CreateImageReaderなどクライアント未指定の関数で、雨雲レーダーのデータが古いとみなす経過時間を設定する
+// 0以下を設定した場合はDefaultStaleThresholdを使う
+func SetStaleThreshold(threshold time.Duration) {
+	staleThreshold.Store(int64(max(threshold, 0)))
+}
+
+// getStaleThreshold SetStaleThresholdで設定した経過時間を返す（未設定の場合はDefaultStaleThreshold）
+func getStaleThreshold() time.Duration {
+	if threshold := time.Duration(staleThreshold.Load()); 0 < threshold {
+		return threshold
+	}
+	return DefaultStaleThreshold
+}
+
+// StaleBannerLayer 雨雲レーダーのデータが古いことを画像上部に表示するバナー
+// 気象庁の障害などで更新が止まった雨雲レーダーを現在の様子と誤解させないために重ねる
+type StaleBannerLayer struct {
+	RadarTime time.Time // 描画した雨雲レーダーの基準時刻
+}
+
+// banner 表示するバナーを返す
+func (l *StaleBannerLayer) banner() *BannerLayer {
+	return &BannerLayer{
+		Text:  "STALE RADAR DATA " + l.RadarTime.In(jst).Format("15:04 MST"),
+		Color: staleBannerColor,
+	}
+}
+
+// Draw バナーを描画する
+func (l *StaleBannerLayer) Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	return l.banner().Draw(ctx, canvas, viewport)
+}
+
+// WriteSVG バナーをSVGの要素として書き出す
+func (l *StaleBannerLayer) WriteSVG(ctx context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error) {
+	return l.banner().WriteSVG(ctx, buf, viewport)
+}
+
+// isStale 雨雲レーダーの基準時刻が現在時刻からthresholdより古いか
+// thresholdが0以下の場合は確認しない
+func isStale(radarTime time.Time, threshold time.Duration, c clock.Clock) bool {
+	if threshold <= 0 || radarTime.IsZero() {
+		return false
+	}
+	return threshold < clock.Or(c).Now().Sub(radarTime)
+}
+
+// staleBannerLayer 雨雲レーダーのデータが古い場合にバナーのレイヤーを返す
+// 古くない場合や確認しない場合はnilを返す
+func staleBannerLayer(ctx context.Context, params *CreateAmeshImageParams, radarTimestamp string) Layer {
+	radarTime := parseJMATimestamp(radarTimestamp)
+	if !isStale(radarTime, params.StaleThreshold, params.Clock) {
+		return nil
+	}
+	requestid.Logf(ctx, "Radar data is stale: basetime %s is older than %s", radarTimestamp, params.StaleThreshold)
+	return &StaleBannerLayer{RadarTime: radarTime}
+}
@@ -0,0 +1,109 @@
+package amesh_test
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/clock/clocktest"
+)
+
+// TestCreateAmeshImageStale 雨雲レーダーの基準時刻が古い場合にバナーを描画し、結果に記録することをテストする
+func TestCreateAmeshImageStale(t *testing.T) {
+	staleBanner := color.RGBA{R: 210, G: 120, B: 0, A: 255}
+	tests := []struct {
+		name          string
+		overlays      []amesh.OverlayName
+		threshold     time.Duration
+		elapsed       time.Duration
+		expectedStale bool
+	}{
+		{
+			name:          "しきい値より古い",
+			threshold:     20 * time.Minute,
+			elapsed:       30 * time.Minute,
+			expectedStale: true,
+		},
+		{
+			name:          "しきい値ちょうど",
+			threshold:     20 * time.Minute,
+			elapsed:       20 * time.Minute,
+			expectedStale: false,
+		},
+		{
+			name:          "しきい値が0の場合は確認しない",
+			threshold:     0,
+			elapsed:       24 * time.Hour,
+			expectedStale: false,
+		},
+		{
+			name:          "雨雲レーダーを重ねない",
+			overlays:      []amesh.OverlayName{amesh.OverlayFlood},
+			threshold:     20 * time.Minute,
+			elapsed:       30 * time.Minute,
+			expectedStale: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, nil)
+
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:         server.Client(),
+				Lat:            35.6895,
+				Lng:            139.6917,
+				Zoom:           10,
+				AroundTiles:    1,
+				Overlays:       tt.overlays,
+				StaleThreshold: tt.threshold,
+				Clock:          clocktest.NewFake(ameshtest.DefaultBaseTime.Add(tt.elapsed)),
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+
+			if result.Stale != tt.expectedStale {
+				t.Errorf("Stale = %v, want %v", result.Stale, tt.expectedStale)
+			}
+			if banner := result.Image.RGBAAt(0, 0) == staleBanner; banner != tt.expectedStale {
+				t.Errorf("stale banner drawn = %v, want %v", banner, tt.expectedStale)
+			}
+		})
+	}
+}
+
+// TestCreateForecastImageStale 予測のパネルを並べた画像では、観測のパネルのバナーで古いことを表示することをテストする
+func TestCreateForecastImageStale(t *testing.T) {
+	t.Parallel()
+	server := ameshtest.NewServer(t, &ameshtest.Scenario{Forecast: true})
+
+	result, err := amesh.CreateForecastImage(t.Context(), &amesh.LocationImageParams{
+		Client:         server.Client(),
+		Location:       &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"},
+		Zoom:           10,
+		StaleThreshold: 20 * time.Minute,
+		Clock:          clocktest.NewFake(ameshtest.DefaultBaseTime.Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("CreateForecastImage() error = %v", err)
+	}
+
+	if !result.Stale {
+		t.Error("Stale = false, want true")
+	}
+	expectedBanners := []color.RGBA{
+		{R: 210, G: 120, B: 0, A: 255},
+		{R: 96, G: 48, B: 160, A: 255},
+		{R: 96, G: 48, B: 160, A: 255},
+	}
+	for i, expected := range expectedBanners {
+		x := i * (768 + 8)
+		if banner := result.Image.RGBAAt(x+1, 1); banner != expected {
+			t.Errorf("panel %d banner pixel = %v, want %v", i+1, banner, expected)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package amesh
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+)
+
+// gazetteerCSV ジオコーダを使えない場合に使う主な地名の一覧
+// 都道府県・市区町村・政令指定都市の区・主な駅と空港・名所の代表地点（役所・駅の所在地）を収録する
+//
+//go:embed data/gazetteer.csv
+var gazetteerCSV []byte
+
+// gazetteerSuffixes 地名が見つからない場合に補って探す接尾辞（先に一致したものを使う）
+var gazetteerSuffixes = []string{"都", "道", "府", "県", "市", "区", "町", "村", "駅"}
+
+// gazetteer 地名から位置情報への索引
+// 区・市は「大阪市北区」のように上位の地名を付けた名前と、区・市だけの名前の両方で引ける
+// 区・市だけの名前が重複する場合は一覧の先に書かれたもの（東京23区など）を使う
+var gazetteer = sync.OnceValue(func() map[string]*Location {
+	index, err := parseGazetteer(gazetteerCSV)
+	if err != nil {
+		log.Printf("Failed to parseGazetteer: %v", err)
+		return map[string]*Location{}
+	}
+	return index
+})
+
+// gazetteerRecord 地名の一覧の1行
+type gazetteerRecord struct {
+	name     string   // 地名
+	parent   string   // 上位の地名（都道府県・政令指定都市、ない場合は空）
+	location Location // 位置情報（PlaceNameは上位の地名を付けた名前）
+}
+
+// parseGazetteerRecords 地名の一覧（name,parent,lat,lng,levelのCSV）を見出しの行を除いて解析する
+func parseGazetteerRecords(data []byte) ([]gazetteerRecord, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to csv.ReadAll")
+	}
+
+	parsed := make([]gazetteerRecord, 0, len(records))
+	for _, record := range records[min(1, len(records)):] {
+		if len(record) < 5 {
+			return nil, errors.Wrapf(ErrInvalidCoordinatesFormat, "record: %v", record)
+		}
+		name, parent := record[0], record[1]
+		lat, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.ParseFloat")
+		}
+		lng, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.ParseFloat")
+		}
+		level, err := strconv.Atoi(record[4])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.Atoi")
+		}
+		parsed = append(parsed, gazetteerRecord{
+			name:     name,
+			parent:   parent,
+			location: Location{Lat: lat, Lng: lng, PlaceName: parent + name, AddressLevel: AddressLevel(level)},
+		})
+	}
+	return parsed, nil
+}
+
+// parseGazetteer 地名の一覧（name,parent,lat,lng,levelのCSV）を解析して索引を作成する
+func parseGazetteer(data []byte) (map[string]*Location, error) {
+	records, err := parseGazetteerRecords(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseGazetteerRecords")
+	}
+
+	index := make(map[string]*Location, 2*len(records))
+	for _, record := range records {
+		location := &record.location
+		index[location.PlaceName] = location
+		if _, ok := index[record.name]; !ok {
+			index[record.name] = location
+		}
+	}
+	return index, nil
+}
+
+// lookupGazetteer 埋め込みの地名の一覧から地名を探す
+// 「大阪」のように都道府県・市区町村・駅を省略した名前でも探せる
+func lookupGazetteer(place string) (*Location, error) {
+	place = strings.TrimSpace(place)
+	if place == "" {
+		place = "東京"
+	}
+
+	index := gazetteer()
+	candidates := []string{place}
+	for _, suffix := range gazetteerSuffixes {
+		candidates = append(candidates, place+suffix)
+	}
+	for _, candidate := range candidates {
+		if location, ok := index[candidate]; ok {
+			found := *location
+			return &found, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNoResultsFound, "%s", place)
+}
+
+// minExtractedPlaceLength 文章から探す地名の最小の文字数（「東」のような短い名前の誤検出を防ぐ）
+const minExtractedPlaceLength = 2
+
+// gazetteerNames 文章から探す地名の一覧（長い順）
+var gazetteerNames = sync.OnceValue(func() []string {
+	var names []string
+	for name := range gazetteer() {
+		if minExtractedPlaceLength <= utf8.RuneCountInString(name) {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if diff := utf8.RuneCountInString(b) - utf8.RuneCountInString(a); diff != 0 {
+			return diff
+		}
+		return strings.Compare(a, b)
+	})
+	return names
+})
+
+// ExtractPlace 文章に含まれる地名を埋め込みの地名の一覧から探す
+// 複数の地名がある場合は最も長い地名（同じ長さの場合は先に現れる地名）を返し、見つからない場合は空文字列を返す
+func ExtractPlace(text string) string {
+	found, position := "", -1
+	for _, name := range gazetteerNames() {
+		if found != "" && utf8.RuneCountInString(name) < utf8.RuneCountInString(found) {
+			break
+		}
+		if index := strings.Index(text, name); 0 <= index && (found == "" || index < position) {
+			found, position = name, index
+		}
+	}
+	return found
+}
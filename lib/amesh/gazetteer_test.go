@@ -0,0 +1,185 @@
+package amesh
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGazetteer(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expected    map[string]*Location
+		expectError bool
+	}{
+		{
+			name: "上位の地名付きの名前と名前だけの両方で引ける",
+			data: "name,parent,lat,lng,level\n" +
+				"大阪府,,34.6863,135.5200,1\n" +
+				"北区,東京都,35.7528,139.7337,2\n" +
+				"北区,大阪市,34.7055,135.5100,2\n",
+			expected: map[string]*Location{
+				"大阪府":   {Lat: 34.6863, Lng: 135.52, PlaceName: "大阪府", AddressLevel: 1},
+				"東京都北区": {Lat: 35.7528, Lng: 139.7337, PlaceName: "東京都北区", AddressLevel: 2},
+				"北区":    {Lat: 35.7528, Lng: 139.7337, PlaceName: "東京都北区", AddressLevel: 2},
+				"大阪市北区": {Lat: 34.7055, Lng: 135.51, PlaceName: "大阪市北区", AddressLevel: 2},
+			},
+		},
+		{
+			name:     "ヘッダーのみ",
+			data:     "name,parent,lat,lng,level\n",
+			expected: map[string]*Location{},
+		},
+		{
+			name:        "列が足りない",
+			data:        "name,parent,lat\n東京都,,35.6895\n",
+			expectError: true,
+		},
+		{
+			name:        "緯度が数値でない",
+			data:        "name,parent,lat,lng,level\n東京都,,north,139.6917,1\n",
+			expectError: true,
+		},
+		{
+			name:        "レベルが整数でない",
+			data:        "name,parent,lat,lng,level\n東京都,,35.6895,139.6917,high\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := parseGazetteer([]byte(tt.data))
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseGazetteer() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("parseGazetteer() diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLookupGazetteer(t *testing.T) {
+	tests := []struct {
+		name        string
+		place       string
+		expected    string
+		expectError error
+	}{
+		{
+			name:     "都道府県の接尾辞を補う",
+			place:    "大阪",
+			expected: "大阪府",
+		},
+		{
+			name:     "市の接尾辞を補う",
+			place:    "札幌",
+			expected: "北海道札幌市",
+		},
+		{
+			name:     "駅の接尾辞を補う",
+			place:    "新大阪",
+			expected: "新大阪駅",
+		},
+		{
+			name:     "区だけの名前は一覧の先に書かれたものを使う",
+			place:    "北区",
+			expected: "東京都北区",
+		},
+		{
+			name:     "上位の地名付きの区",
+			place:    "大阪市北区",
+			expected: "大阪市北区",
+		},
+		{
+			name:     "前後の空白を無視する",
+			place:    " 那覇空港 ",
+			expected: "那覇空港",
+		},
+		{
+			name:     "空の場所は東京",
+			place:    "",
+			expected: "東京都",
+		},
+		{
+			name:        "一覧にない地名",
+			place:       "存在しない地名",
+			expectError: ErrNoResultsFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := lookupGazetteer(tt.place)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("lookupGazetteer(%q) error = %v, expectError = %v", tt.place, err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+			if result.PlaceName != tt.expected {
+				t.Errorf("lookupGazetteer(%q) = %q, want %q", tt.place, result.PlaceName, tt.expected)
+			}
+		})
+	}
+}
+
+// TestEmbeddedGazetteer 埋め込みの地名の一覧が解析でき、座標が日本の範囲内であることを確認する
+func TestEmbeddedGazetteer(t *testing.T) {
+	t.Parallel()
+	index, err := parseGazetteer(gazetteerCSV)
+	if err != nil {
+		t.Fatalf("parseGazetteer() error = %v", err)
+	}
+	if len(index) < 2000 {
+		t.Errorf("len(index) = %d, want at least 2000", len(index))
+	}
+	for name, location := range index {
+		if location.Lat < 20 || 46 < location.Lat || location.Lng < 122 || 154 < location.Lng {
+			t.Errorf("%s = %+v, out of Japan", name, location)
+		}
+	}
+}
+
+func TestExtractPlace(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "文章中の地名",
+			text:     "千葉県で震度3の地震がありました",
+			expected: "千葉県",
+		},
+		{
+			name:     "長い地名を優先",
+			text:     "大阪市北区は雨っぽ",
+			expected: "大阪市北区",
+		},
+		{
+			name:     "同じ長さの場合は先に現れる地名",
+			text:     "横浜市と川崎市",
+			expected: "横浜市",
+		},
+		{
+			name:     "地名がない",
+			text:     "こんにちは",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := ExtractPlace(tt.text); result != tt.expected {
+				t.Errorf("ExtractPlace() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package amesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/httpclient"
+)
+
+var (
+	// ErrUnknownGeocoder 存在しないジオコーダの種類を指定したことを表すエラー
+	ErrUnknownGeocoder = errors.New("unknown geocoder provider")
+	// ErrGeocoderAPIKeyRequired ジオコーダのAPIキーが設定されていないことを表すエラー
+	ErrGeocoderAPIKeyRequired = errors.New("geocoder api key is required")
+	// ErrGeocoderContactRequired Nominatimの利用規約で必要なUser-Agentと連絡先が設定されていないことを表すエラー
+	ErrGeocoderContactRequired = errors.New("geocoder user agent and contact email are required")
+)
+
+// ジオコーダの種類
+const (
+	GeocoderYahoo     = "yahoo"     // Yahoo!ジオコーダAPI
+	GeocoderNominatim = "nominatim" // Nominatim（OpenStreetMap）
+)
+
+// Nominatimの既定値
+// 公開サーバーの利用規約（https://operations.osmfoundation.org/policies/nominatim/）に従い、
+// リクエストは1秒に1回までとし、同じ地名の結果はキャッシュして繰り返し問い合わせない
+const (
+	nominatimURL             = "https://nominatim.openstreetmap.org/search"
+	nominatimInterval        = time.Second
+	nominatimCacheTTL        = 24 * time.Hour
+	nominatimCacheMaxEntries = 1024
+)
+
+// Geocoder 地名から位置情報を探すジオコーダ
+// 地名が見つからない場合はErrNoResultsFoundを返す
+type Geocoder interface {
+	Geocode(ctx context.Context, place string) (*Location, error)
+}
+
+// defaultGeocoder ParseLocationなどクライアント未指定の関数で使うジオコーダ（SetDefaultGeocoderで設定する）
+var defaultGeocoder atomic.Pointer[Geocoder]
+
+// SetDefaultGeocoder ParseLocationなどクライアント未指定の関数で使うジオコーダを設定する
+// nilを設定した場合はAPIキーがあればYahoo!ジオコーダ、なければ埋め込みの地名の一覧を使う
+func SetDefaultGeocoder(geocoder Geocoder) {
+	if geocoder == nil {
+		defaultGeocoder.Store(nil)
+		return
+	}
+	defaultGeocoder.Store(&geocoder)
+}
+
+// getDefaultGeocoder SetDefaultGeocoderで設定したジオコーダを返す（未設定の場合はnil）
+func getDefaultGeocoder() Geocoder {
+	if geocoder := defaultGeocoder.Load(); geocoder != nil {
+		return *geocoder
+	}
+	return nil
+}
+
+// NewGeocoderFromConfig 設定ファイルのジオコーダの設定からGeocoderを作成する（clientがnilの場合は共有のクライアントを使う）
+// ジオコーダが設定されていない場合はnilを返す
+func NewGeocoderFromConfig(setting *config.Geocoder, client httpclient.Doer) (Geocoder, error) {
+	if setting == nil {
+		return nil, nil
+	}
+	if client == nil {
+		client = defaultClient
+	}
+
+	switch strings.ToLower(setting.Provider) {
+	case GeocoderYahoo:
+		if setting.APIKey == "" {
+			return nil, errors.Wrapf(ErrGeocoderAPIKeyRequired, "provider: %s", setting.Provider)
+		}
+		return &YahooGeocoder{Client: client, APIKey: setting.APIKey}, nil
+	case GeocoderNominatim:
+		geocoder, err := NewNominatimGeocoder(&NominatimSetting{
+			URL:       setting.URL,
+			UserAgent: setting.UserAgent,
+			Email:     setting.Email,
+			Client:    client,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to NewNominatimGeocoder")
+		}
+		return geocoder, nil
+	default:
+		return nil, errors.Wrapf(ErrUnknownGeocoder, "provider: %s", setting.Provider)
+	}
+}
+
+// YahooGeocoder Yahoo!ジオコーダAPIで地名を探す
+type YahooGeocoder struct {
+	Client httpclient.Doer // HTTPクライアント
+	APIKey string          // APIキー
+}
+
+// Geocode 地名をジオコーディングして位置情報を取得する
+func (g *YahooGeocoder) Geocode(ctx context.Context, place string) (*Location, error) {
+	requestURL := fmt.Sprintf(
+		"https://map.yahooapis.jp/geocode/V1/geoCoder?appid=%s&query=%s&output=json",
+		g.APIKey,
+		url.QueryEscape(place),
+	)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+
+	body, err := executeAndReadResponse(g.Client, httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to executeAndReadResponse")
+	}
+
+	return parseGeocodeResponse(body, place)
+}
+
+// NominatimSetting Nominatimのジオコーダの設定
+type NominatimSetting struct {
+	URL       string          // 検索APIのURL（空の場合は公開サーバー）
+	UserAgent string          // User-Agentに使うアプリケーション名（必須）
+	Email     string          // 連絡先のメールアドレス（必須、User-Agentとemailパラメータに含める）
+	Client    httpclient.Doer // HTTPクライアント（nilの場合は共有のクライアント）
+	Interval  time.Duration   // リクエストの最短の間隔（0の場合は1秒）
+	CacheTTL  time.Duration   // 結果をキャッシュする期間（0の場合は24時間）
+	Clock     clock.Clock     // リクエストの間隔とキャッシュの期限に使う時計（nilの場合はclock.Real）
+}
+
+// nominatimCacheEntry キャッシュしたNominatimの検索結果（地名が見つからなかった場合はlocationがnil）
+type nominatimCacheEntry struct {
+	location *Location
+	storedAt time.Time
+}
+
+// NominatimGeocoder Nominatimの利用規約に従って地名を探すジオコーダ
+// 複数のゴルーチンから呼び出しても、リクエストの間隔はIntervalより短くならない
+type NominatimGeocoder struct {
+	setting NominatimSetting
+
+	throttleMu sync.Mutex
+	next       time.Time // 次のリクエストを送信できる時刻
+
+	cacheMu sync.Mutex
+	cache   map[string]*nominatimCacheEntry
+}
+
+// NewNominatimGeocoder 新しいNominatimGeocoderを作成する
+// User-Agentと連絡先のメールアドレスがない場合はErrGeocoderContactRequiredを返す
+func NewNominatimGeocoder(setting *NominatimSetting) (*NominatimGeocoder, error) {
+	s := NominatimSetting{}
+	if setting != nil {
+		s = *setting
+	}
+	if strings.TrimSpace(s.UserAgent) == "" || strings.TrimSpace(s.Email) == "" {
+		return nil, ErrGeocoderContactRequired
+	}
+	if s.URL == "" {
+		s.URL = nominatimURL
+	}
+	if s.Client == nil {
+		s.Client = defaultClient
+	}
+	if s.Interval <= 0 {
+		s.Interval = nominatimInterval
+	}
+	if s.CacheTTL <= 0 {
+		s.CacheTTL = nominatimCacheTTL
+	}
+	s.Clock = clock.Or(s.Clock)
+	return &NominatimGeocoder{
+		setting: s,
+		cache:   make(map[string]*nominatimCacheEntry),
+	}, nil
+}
+
+// Geocode 地名を検索して位置情報を取得する
+// キャッシュにある地名は問い合わせずに返し、見つからなかった地名もキャッシュする
+func (g *NominatimGeocoder) Geocode(ctx context.Context, place string) (*Location, error) {
+	key := strings.TrimSpace(place)
+	if entry, ok := g.cached(key); ok {
+		if entry.location == nil {
+			return nil, errors.Wrapf(ErrNoResultsFound, "%s", place)
+		}
+		found := *entry.location
+		return &found, nil
+	}
+
+	location, err := g.search(ctx, key)
+	if err != nil && !errors.Is(err, ErrNoResultsFound) {
+		return nil, errors.Wrap(err, "Failed to search")
+	}
+	g.store(key, location)
+	if err != nil {
+		return nil, err
+	}
+	found := *location
+	return &found, nil
+}
+
+// search 検索APIに問い合わせる
+func (g *NominatimGeocoder) search(ctx context.Context, place string) (*Location, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, errors.Wrap(err, "Failed to wait")
+	}
+
+	query := url.Values{
+		"q":               {place},
+		"format":          {"jsonv2"},
+		"limit":           {"1"},
+		"countrycodes":    {"jp"},
+		"accept-language": {"ja"},
+		"email":           {g.setting.Email},
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, g.setting.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	httpReq.Header.Set("User-Agent", fmt.Sprintf("%s (%s)", g.setting.UserAgent, g.setting.Email))
+
+	body, err := executeAndReadResponse(g.setting.Client, httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to executeAndReadResponse")
+	}
+
+	return parseNominatimResponse(body, place)
+}
+
+// wait 前回のリクエストからIntervalが経つまで待つ
+// 待っている間にコンテキストがキャンセルされた場合はそのエラーを返す
+func (g *NominatimGeocoder) wait(ctx context.Context) error {
+	g.throttleMu.Lock()
+	now := g.setting.Clock.Now()
+	start := g.next
+	if start.Before(now) {
+		start = now
+	}
+	g.next = start.Add(g.setting.Interval)
+	g.throttleMu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	if err := g.setting.Clock.Sleep(ctx, delay); err != nil {
+		return errors.Wrap(err, "Failed to wait for throttle")
+	}
+	return nil
+}
+
+// cached キャッシュからTTL内の検索結果を取得する
+func (g *NominatimGeocoder) cached(key string) (*nominatimCacheEntry, bool) {
+	g.cacheMu.Lock()
+	defer g.cacheMu.Unlock()
+
+	entry, ok := g.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if g.setting.CacheTTL < g.setting.Clock.Now().Sub(entry.storedAt) {
+		delete(g.cache, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// store 検索結果をキャッシュする
+// 上限に達した場合は期限切れの結果を捨て、それでも空きがない場合は最も古い結果を捨てる
+func (g *NominatimGeocoder) store(key string, location *Location) {
+	g.cacheMu.Lock()
+	defer g.cacheMu.Unlock()
+
+	now := g.setting.Clock.Now()
+	if nominatimCacheMaxEntries <= len(g.cache) {
+		oldestKey := ""
+		var oldest time.Time
+		for k, entry := range g.cache {
+			if g.setting.CacheTTL < now.Sub(entry.storedAt) {
+				delete(g.cache, k)
+				continue
+			}
+			if oldestKey == "" || entry.storedAt.Before(oldest) {
+				oldestKey, oldest = k, entry.storedAt
+			}
+		}
+		if nominatimCacheMaxEntries <= len(g.cache) {
+			delete(g.cache, oldestKey)
+		}
+	}
+	g.cache[key] = &nominatimCacheEntry{location: location, storedAt: now}
+}
+
+// nominatimPlaceRankLevels Nominatimのplace_rankの上限ごとのマッチングレベル
+// 8: 都道府県、16: 市区町村、20: 町・大字、22: 丁目、26: 番地・道路、それより細かいものは号
+var nominatimPlaceRankLevels = []struct {
+	maxRank int
+	level   AddressLevel
+}{
+	{8, 1},
+	{16, 2},
+	{20, 3},
+	{22, 4},
+	{26, 5},
+	{30, 6},
+}
+
+// nominatimAddressLevel place_rankをYahoo!ジオコーダのマッチングレベルに換算する（不明な場合は0）
+func nominatimAddressLevel(placeRank int) AddressLevel {
+	if placeRank <= 0 {
+		return 0
+	}
+	for _, rank := range nominatimPlaceRankLevels {
+		if placeRank <= rank.maxRank {
+			return rank.level
+		}
+	}
+	return 0
+}
+
+// parseNominatimResponse Nominatimの検索APIのレスポンス（jsonv2形式）を解析する
+func parseNominatimResponse(body []byte, place string) (*Location, error) {
+	var results []struct {
+		Lat         string   `json:"lat"`
+		Lon         string   `json:"lon"`
+		DisplayName string   `json:"display_name"`
+		Name        string   `json:"name"`
+		BoundingBox []string `json:"boundingbox"`
+		PlaceRank   int      `json:"place_rank"`
+	}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, errors.Wrap(ErrJSONUnmarshal, err.Error())
+	}
+	if len(results) == 0 {
+		return nil, errors.Wrapf(ErrNoResultsFound, "%s", place)
+	}
+
+	result := results[0]
+	lat, err := strconv.ParseFloat(result.Lat, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to strconv.ParseFloat")
+	}
+	lng, err := strconv.ParseFloat(result.Lon, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to strconv.ParseFloat")
+	}
+
+	placeName := result.Name
+	if placeName == "" {
+		placeName = result.DisplayName
+	}
+	return &Location{
+		Lat:          lat,
+		Lng:          lng,
+		PlaceName:    placeName,
+		BoundingBox:  parseNominatimBoundingBox(result.BoundingBox),
+		AddressLevel: nominatimAddressLevel(result.PlaceRank),
+	}, nil
+}
+
+// parseNominatimBoundingBox Nominatimの範囲（[南端, 北端, 西端, 東端]の文字列）を解析する
+// 解析できない場合はnilを返す
+func parseNominatimBoundingBox(values []string) *BoundingBox {
+	if len(values) != 4 {
+		return nil
+	}
+	corners := make([]float64, 0, len(values))
+	for _, value := range values {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil
+		}
+		corners = append(corners, f)
+	}
+	return &BoundingBox{
+		MinLat: min(corners[0], corners[1]),
+		MaxLat: max(corners[0], corners[1]),
+		MinLng: min(corners[2], corners[3]),
+		MaxLng: max(corners[2], corners[3]),
+	}
+}
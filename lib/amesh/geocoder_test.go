@@ -0,0 +1,359 @@
+package amesh_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/httpclient"
+)
+
+// nominatimTokyo Nominatimの検索APIのレスポンス（jsonv2形式）の例
+const nominatimTokyo = `[{
+	"lat": "35.6821936",
+	"lon": "139.762221",
+	"name": "東京都",
+	"display_name": "東京都, 日本",
+	"boundingbox": ["20.2145811", "35.8984245", "135.8536855", "154.2055410"],
+	"place_rank": 8
+}]`
+
+// fakeGeocoder 固定の結果を返し、受け取った地名を記録するジオコーダ
+type fakeGeocoder struct {
+	location *amesh.Location
+	err      error
+	places   []string
+}
+
+func (f *fakeGeocoder) Geocode(_ context.Context, place string) (*amesh.Location, error) {
+	f.places = append(f.places, place)
+	return f.location, f.err
+}
+
+func TestNewGeocoderFromConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		setting       *config.Geocoder
+		expectNil     bool
+		expectedError error
+	}{
+		{
+			name:      "設定なし",
+			setting:   nil,
+			expectNil: true,
+		},
+		{
+			name:    "Yahoo!ジオコーダ",
+			setting: &config.Geocoder{Provider: "yahoo", APIKey: "key"},
+		},
+		{
+			name:    "Nominatim（大文字小文字を区別しない）",
+			setting: &config.Geocoder{Provider: "Nominatim", UserAgent: "hato-bot-go", Email: "admin@example.com"},
+		},
+		{
+			name:          "YahooのAPIキーなし",
+			setting:       &config.Geocoder{Provider: "yahoo"},
+			expectedError: amesh.ErrGeocoderAPIKeyRequired,
+		},
+		{
+			name:          "Nominatimの連絡先なし",
+			setting:       &config.Geocoder{Provider: "nominatim", UserAgent: "hato-bot-go"},
+			expectedError: amesh.ErrGeocoderContactRequired,
+		},
+		{
+			name:          "NominatimのUser-Agentなし",
+			setting:       &config.Geocoder{Provider: "nominatim", Email: "admin@example.com"},
+			expectedError: amesh.ErrGeocoderContactRequired,
+		},
+		{
+			name:          "存在しない種類",
+			setting:       &config.Geocoder{Provider: "unknown"},
+			expectedError: amesh.ErrUnknownGeocoder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			geocoder, err := amesh.NewGeocoderFromConfig(tt.setting, nil)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("NewGeocoderFromConfig() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if (geocoder == nil) != tt.expectNil {
+				t.Errorf("NewGeocoderFromConfig() = %v, expectNil = %v", geocoder, tt.expectNil)
+			}
+		})
+	}
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      httpclient.MockResponse
+		expected      *amesh.Location
+		expectedError error
+	}{
+		{
+			name:     "範囲とマッチングレベル付きの結果",
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: nominatimTokyo},
+			expected: &amesh.Location{
+				Lat:          35.6821936,
+				Lng:          139.762221,
+				PlaceName:    "東京都",
+				BoundingBox:  &amesh.BoundingBox{MinLat: 20.2145811, MaxLat: 35.8984245, MinLng: 135.8536855, MaxLng: 154.205541},
+				AddressLevel: 1,
+			},
+		},
+		{
+			name: "名前がない場合は表示名を使い、範囲が不正な場合は範囲なし",
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `[{"lat": "34.7024", "lon": "135.4959", "display_name": "大阪駅, 大阪市", "boundingbox": ["x"], "place_rank": 30}]`,
+			},
+			expected: &amesh.Location{Lat: 34.7024, Lng: 135.4959, PlaceName: "大阪駅, 大阪市", AddressLevel: 6},
+		},
+		{
+			name:          "結果なし",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: `[]`},
+			expectedError: amesh.ErrNoResultsFound,
+		},
+		{
+			name:          "不正なJSON",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{`},
+			expectedError: amesh.ErrJSONUnmarshal,
+		},
+		{
+			name:          "サーバーエラー",
+			response:      httpclient.MockResponse{StatusCode: http.StatusInternalServerError},
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Fallback: tt.response})
+			geocoder, err := amesh.NewNominatimGeocoder(&amesh.NominatimSetting{
+				URL:       "https://nominatim.example.com/search",
+				UserAgent: "hato-bot-go",
+				Email:     "admin@example.com",
+				Client:    transport.Client(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := geocoder.Geocode(t.Context(), "東京")
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Geocode() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("Geocode() diff: %s", diff)
+			}
+
+			requests := transport.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+			if userAgent := requests[0].Header.Get("User-Agent"); userAgent != "hato-bot-go (admin@example.com)" {
+				t.Errorf("User-Agent = %q, want %q", userAgent, "hato-bot-go (admin@example.com)")
+			}
+			requestURL, err := url.Parse(requests[0].URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := requestURL.Query()
+			if query.Get("q") != "東京" || query.Get("email") != "admin@example.com" || query.Get("format") != "jsonv2" {
+				t.Errorf("query = %v", query)
+			}
+		})
+	}
+}
+
+// TestNominatimGeocoderCache 同じ地名は見つからなかった場合も含めて問い合わせ直さないことを確認する
+func TestNominatimGeocoderCache(t *testing.T) {
+	t.Parallel()
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "q=%E5%AD%98%E5%9C%A8", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[]`}}},
+		},
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: nominatimTokyo},
+	})
+	geocoder, err := amesh.NewNominatimGeocoder(&amesh.NominatimSetting{
+		UserAgent: "hato-bot-go",
+		Email:     "admin@example.com",
+		Client:    transport.Client(),
+		Interval:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, place := range []string{"東京", " 東京 ", "存在しない地名", "存在しない地名"} {
+		_, err := geocoder.Geocode(t.Context(), place)
+		if err != nil && !errors.Is(err, amesh.ErrNoResultsFound) {
+			t.Fatalf("Geocode(%q) error = %v", place, err)
+		}
+	}
+	if requests := transport.Requests(); len(requests) != 2 {
+		t.Errorf("requests = %d, want 2", len(requests))
+	}
+}
+
+// TestNominatimGeocoderThrottle 続けて問い合わせた場合はリクエストの間隔を空けることを確認する
+func TestNominatimGeocoderThrottle(t *testing.T) {
+	t.Parallel()
+	const interval = time.Second
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: nominatimTokyo},
+	})
+	fake := clocktest.NewFake(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	geocoder, err := amesh.NewNominatimGeocoder(&amesh.NominatimSetting{
+		UserAgent: "hato-bot-go",
+		Email:     "admin@example.com",
+		Client:    transport.Client(),
+		Interval:  interval,
+		Clock:     fake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 最初の問い合わせは待たない
+	if _, err := geocoder.Geocode(t.Context(), "東京"); err != nil {
+		t.Fatalf("Geocode() error = %v", err)
+	}
+
+	// 次の問い合わせはIntervalが経つまで待つ
+	done := make(chan error, 1)
+	go func() {
+		_, err := geocoder.Geocode(t.Context(), "大阪")
+		done <- err
+	}()
+	fake.BlockUntil(1)
+	if requests := transport.Requests(); len(requests) != 1 {
+		t.Errorf("requests before the interval = %d, want 1", len(requests))
+	}
+	fake.Advance(interval)
+	if err := <-done; err != nil {
+		t.Fatalf("Geocode() error = %v", err)
+	}
+
+	// 待っている間にキャンセルされた場合は問い合わせない
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := geocoder.Geocode(ctx, "札幌"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Geocode() error = %v, want %v", err, context.Canceled)
+	}
+	if requests := transport.Requests(); len(requests) != 2 {
+		t.Errorf("requests = %d, want 2", len(requests))
+	}
+}
+
+// TestNominatimGeocoderCacheTTL キャッシュの期限を過ぎた地名は問い合わせ直すことを確認する
+func TestNominatimGeocoderCacheTTL(t *testing.T) {
+	t.Parallel()
+	const ttl = time.Hour
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: nominatimTokyo},
+	})
+	fake := clocktest.NewFake(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	geocoder, err := amesh.NewNominatimGeocoder(&amesh.NominatimSetting{
+		UserAgent: "hato-bot-go",
+		Email:     "admin@example.com",
+		Client:    transport.Client(),
+		CacheTTL:  ttl,
+		Clock:     fake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		advance          time.Duration
+		expectedRequests int
+	}{
+		{advance: 0, expectedRequests: 1},
+		{advance: ttl, expectedRequests: 1},
+		{advance: time.Second, expectedRequests: 2},
+	} {
+		fake.Advance(step.advance)
+		if _, err := geocoder.Geocode(t.Context(), "東京"); err != nil {
+			t.Fatalf("Geocode() error = %v", err)
+		}
+		if requests := transport.Requests(); len(requests) != step.expectedRequests {
+			t.Errorf("requests after %v = %d, want %d", step.advance, len(requests), step.expectedRequests)
+		}
+	}
+}
+
+// TestParseLocationWithClientGeocoder 指定したジオコーダをAPIキーより優先し、失敗した場合は埋め込みの地名の一覧を使うことを確認する
+func TestParseLocationWithClientGeocoder(t *testing.T) {
+	tests := []struct {
+		name          string
+		geocoder      *fakeGeocoder
+		place         string
+		expected      string
+		expectedError error
+	}{
+		{
+			name:     "ジオコーダの結果",
+			geocoder: &fakeGeocoder{location: &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京駅"}},
+			place:    "東京駅",
+			expected: "東京駅",
+		},
+		{
+			name:     "ジオコーダが失敗した場合は地名の一覧",
+			geocoder: &fakeGeocoder{err: httpclient.ErrHTTPRequestError},
+			place:    "大阪",
+			expected: "大阪府",
+		},
+		{
+			name:          "一覧にもない場合はジオコーダのエラー",
+			geocoder:      &fakeGeocoder{err: httpclient.ErrHTTPRequestError},
+			place:         "存在しない地名",
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+		{
+			name:     "空の地名は東京として探す",
+			geocoder: &fakeGeocoder{location: &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京都"}},
+			place:    "",
+			expected: "東京都",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(nil)
+			location, err := amesh.ParseLocationWithClient(t.Context(), &amesh.ParseLocationParams{
+				Client:         transport.Client(),
+				GeocodeRequest: amesh.GeocodeRequest{Place: tt.place, APIKey: "test_key"},
+				Geocoder:       tt.geocoder,
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseLocationWithClient() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError == nil && location.PlaceName != tt.expected {
+				t.Errorf("PlaceName = %q, want %q", location.PlaceName, tt.expected)
+			}
+			if requests := transport.Requests(); len(requests) != 0 {
+				t.Errorf("requests = %d, want 0 (Yahoo!ジオコーダを使わない)", len(requests))
+			}
+			if len(tt.geocoder.places) != 1 || (tt.place != "" && tt.geocoder.places[0] != tt.place) {
+				t.Errorf("places = %v", tt.geocoder.places)
+			}
+		})
+	}
+}
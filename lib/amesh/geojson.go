@@ -0,0 +1,240 @@
+package amesh
+
+import (
+	"context"
+	"image"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/requestid"
+)
+
+// GeoJSONの地物の種類（propertiesのkind）
+const (
+	FeatureKindCenter         = "center"          // 画像の中心の地点
+	FeatureKindBounds         = "bounds"          // 画像に描画したタイルの範囲
+	FeatureKindDistanceCircle = "distance_circle" // 中心からの距離円
+	FeatureKindLightning      = "lightning"       // 落雷地点
+	FeatureKindMarker         = "marker"          // マーカー
+)
+
+// FeatureLayer GeoJSONの地物として書き出せるレイヤー
+// CreateGeoJSONはタイルを描画せずにFeaturesを呼び出し、画像と同じ計算結果を地物にする
+type FeatureLayer interface {
+	Layer
+	Features(ctx context.Context, params *FeaturesParams) ([]Feature, error)
+}
+
+// FeaturesParams レイヤーを地物にするためのパラメータ
+type FeaturesParams struct {
+	Viewport *Viewport // 描画範囲（範囲外の地点は含めない）
+	Now      time.Time // 落雷からの経過時間の基準の時刻
+}
+
+// FeatureCollection GeoJSON（RFC 7946）のFeatureCollection
+type FeatureCollection struct {
+	Type     string        `json:"type"`
+	BBox     []float64     `json:"bbox,omitempty"` // 西端の経度・南端の緯度・東端の経度・北端の緯度
+	Features []Feature     `json:"features"`
+	Metadata AmeshMetadata `json:"-"` // 作成に使ったデータの情報（GeoJSONには含めない）
+}
+
+// Feature GeoJSONの地物
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry GeoJSONのジオメトリ
+// 座標は経度・緯度の順に並べる
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// CreateGeoJSONParams GeoJSONを作成するためのパラメータ
+type CreateGeoJSONParams struct {
+	LocationImageParams
+	Clock clock.Clock // 落雷からの経過時間の基準の時計（nilの場合はclock.Real）
+}
+
+// CreateGeoJSONForLocation 既定のHTTPクライアントとtargetTimesのキャッシュでCreateGeoJSONを呼び出す
+func CreateGeoJSONForLocation(ctx context.Context, location *Location, overlays []OverlayName) (*FeatureCollection, error) {
+	return CreateGeoJSON(ctx, &CreateGeoJSONParams{
+		LocationImageParams: LocationImageParams{
+			Client:         defaultClient,
+			Location:       location,
+			TimestampCache: defaultTimestampCache,
+			Overlays:       overlays,
+		},
+	})
+}
+
+// CreateGeoJSON amesh画像の作成に使う中心の地点・距離円・落雷地点・タイルの範囲をGeoJSONのFeatureCollectionにする
+// タイル画像は取得せず、画像と同じズームレベルと描画範囲で計算する
+// 日付変更線をまたぐ場合、経度は-180〜180度の範囲外になる
+func CreateGeoJSON(ctx context.Context, params *CreateGeoJSONParams) (*FeatureCollection, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+	imageParams := locationImageParams(&params.LocationImageParams)
+	if err := validateMapParams(imageParams); err != nil {
+		return nil, errors.Wrap(err, "Failed to validateMapParams")
+	}
+	layers, err := DefaultLayers(ctx, imageParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to DefaultLayers")
+	}
+
+	viewport := &Viewport{
+		Lat:         imageParams.Lat,
+		Lng:         imageParams.Lng,
+		Zoom:        imageParams.Zoom,
+		AroundTiles: imageParams.AroundTiles,
+	}
+	rendered := &RenderResult{}
+	metadata := rendered.metadata(layers)
+	metadata.BoundingBox = viewport.BoundingBox()
+	metadata.Locations = []Location{*params.Location}
+
+	collection := &FeatureCollection{
+		Type: "FeatureCollection",
+		BBox: []float64{metadata.BoundingBox.MinLng, metadata.BoundingBox.MinLat, metadata.BoundingBox.MaxLng, metadata.BoundingBox.MaxLat},
+		Features: []Feature{
+			centerFeature(params.Location, &metadata),
+			boundsFeature(metadata.BoundingBox, viewport.Zoom),
+		},
+	}
+
+	featuresParams := &FeaturesParams{Viewport: viewport, Now: clock.Or(params.Clock).Now()}
+	for _, layer := range layers {
+		featureLayer, ok := layer.(FeatureLayer)
+		if !ok {
+			continue
+		}
+		// 描画と同じく、地物にできなかったレイヤーはログに出力して飛ばす
+		features, err := featureLayer.Features(ctx, featuresParams)
+		if err != nil {
+			requestid.Logf(ctx, "Failed to convert layer %T to features: %v", layer, err)
+			continue
+		}
+		collection.Features = append(collection.Features, features...)
+		if _, ok := layer.(*LightningLayer); ok {
+			metadata.LightningCount += len(features)
+		}
+	}
+
+	collection.Metadata = metadata
+	return collection, nil
+}
+
+// centerFeature 画像の中心の地点の地物を作成する
+func centerFeature(location *Location, metadata *AmeshMetadata) Feature {
+	properties := map[string]any{"kind": FeatureKindCenter}
+	if location.PlaceName != "" {
+		properties["place_name"] = location.PlaceName
+	}
+	if !metadata.RadarTime.IsZero() {
+		properties["radar_time"] = metadata.RadarTime.UTC().Format(time.RFC3339)
+	}
+	if 0 < len(metadata.Providers) {
+		properties["providers"] = metadata.Providers
+	}
+	return pointFeature(location.Lat, location.Lng, properties)
+}
+
+// boundsFeature 画像に描画したタイルの範囲の地物を作成する
+func boundsFeature(bbox BoundingBox, zoom int) Feature {
+	return Feature{
+		Type: "Feature",
+		Geometry: Geometry{
+			Type: "Polygon",
+			Coordinates: [][][2]float64{{
+				{bbox.MinLng, bbox.MinLat},
+				{bbox.MaxLng, bbox.MinLat},
+				{bbox.MaxLng, bbox.MaxLat},
+				{bbox.MinLng, bbox.MaxLat},
+				{bbox.MinLng, bbox.MinLat},
+			}},
+		},
+		Properties: map[string]any{"kind": FeatureKindBounds, "zoom": zoom},
+	}
+}
+
+// pointFeature 地点の地物を作成する
+func pointFeature(lat, lng float64, properties map[string]any) Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   Geometry{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: properties,
+	}
+}
+
+// Features 距離円を64角形のポリゴンの地物にする
+func (l *CircleLayer) Features(_ context.Context, params *FeaturesParams) ([]Feature, error) {
+	features := make([]Feature, 0, len(l.RadiiKm))
+	for _, radiusKm := range l.RadiiKm {
+		ring := make([][2]float64, 0, circleSegments+1)
+		for _, point := range circleLatLngs(params.Viewport, radiusKm) {
+			ring = append(ring, [2]float64{point.Lng, point.Lat})
+		}
+		features = append(features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: map[string]any{"kind": FeatureKindDistanceCircle, "radius_km": radiusKm},
+		})
+	}
+	return features, nil
+}
+
+// Features 描画範囲内のマーカーを地点の地物にする
+func (l *MarkerLayer) Features(_ context.Context, params *FeaturesParams) ([]Feature, error) {
+	var features []Feature
+	for _, marker := range l.Markers {
+		if !inViewport(params.Viewport, marker.Lat, marker.Lng) {
+			continue
+		}
+		features = append(features, pointFeature(marker.Lat, marker.Lng, map[string]any{
+			"kind":          FeatureKindMarker,
+			"radius_pixels": marker.Radius,
+		}))
+	}
+	return features, nil
+}
+
+// Features 落雷データを取得して描画範囲内の落雷地点を地点の地物にする
+// 気象庁の落雷データの種類（type）と、観測時刻（basetime）からの経過時間を含める
+func (l *LightningLayer) Features(ctx context.Context, params *FeaturesParams) ([]Feature, error) {
+	lightningData, err := getLightningData(ctx, l.Client, l.Timestamp)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to getLightningData")
+	}
+
+	observed := parseJMATimestamp(l.Timestamp)
+	var features []Feature
+	for _, lightning := range lightningData {
+		if !inViewport(params.Viewport, lightning.Lat, lightning.Lng) {
+			continue
+		}
+		properties := map[string]any{
+			"kind":           FeatureKindLightning,
+			"lightning_type": lightning.Type,
+		}
+		if !observed.IsZero() {
+			properties["time"] = observed.Format(time.RFC3339)
+			properties["age_seconds"] = int(max(params.Now.Sub(observed), 0).Seconds())
+		}
+		features = append(features, pointFeature(lightning.Lat, lightning.Lng, properties))
+	}
+	return features, nil
+}
+
+// inViewport 地点が描画範囲内にあるかを返す
+// 画像に描画した場合に中心が画像内に入る地点を描画範囲内とする
+func inViewport(viewport *Viewport, lat, lng float64) bool {
+	return viewport.ImagePoint(lat, lng).In(image.Rect(0, 0, viewport.Size(), viewport.Size()))
+}
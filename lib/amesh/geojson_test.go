@@ -0,0 +1,164 @@
+package amesh_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestCreateGeoJSON(t *testing.T) {
+	tests := []struct {
+		name               string
+		timestamps         string
+		expectedKinds      []string
+		expectedLightning  []map[string]any
+		expectedRadarTime  time.Time
+		expectedProperties map[string]any // 中心の地点のproperties
+	}{
+		{
+			name: "雨雲レーダーと落雷",
+			timestamps: `[
+				{"basetime": "20240101030500", "validtime": "20240101030500", "elements": ["hrpns_nd", "liden"]}
+			]`,
+			expectedKinds: []string{
+				amesh.FeatureKindCenter, amesh.FeatureKindBounds,
+				amesh.FeatureKindDistanceCircle, amesh.FeatureKindDistanceCircle, amesh.FeatureKindDistanceCircle,
+				amesh.FeatureKindDistanceCircle, amesh.FeatureKindDistanceCircle,
+				amesh.FeatureKindLightning,
+			},
+			// 描画範囲外の落雷地点は含めない
+			expectedLightning: []map[string]any{
+				{"kind": "lightning", "lightning_type": float64(2), "time": "2024-01-01T03:05:00Z", "age_seconds": float64(300)},
+			},
+			expectedRadarTime: time.Date(2024, 1, 1, 3, 5, 0, 0, time.UTC),
+			expectedProperties: map[string]any{
+				"kind":       "center",
+				"place_name": "東京",
+				"radar_time": "2024-01-01T03:05:00Z",
+				"providers":  []any{amesh.ProviderOpenStreetMap, amesh.ProviderJMA},
+			},
+		},
+		{
+			name:       "レーダーデータなし",
+			timestamps: `[]`,
+			expectedKinds: []string{
+				amesh.FeatureKindCenter, amesh.FeatureKindBounds,
+				amesh.FeatureKindDistanceCircle, amesh.FeatureKindDistanceCircle, amesh.FeatureKindDistanceCircle,
+				amesh.FeatureKindDistanceCircle, amesh.FeatureKindDistanceCircle,
+			},
+			expectedProperties: map[string]any{
+				"kind":       "center",
+				"place_name": "東京",
+				"providers":  []any{amesh.ProviderOpenStreetMap},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: tt.timestamps}}},
+					{Pattern: "liden/data.geojson", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"features": [
+						{"geometry": {"coordinates": [139.7, 35.7]}, "properties": {"type": 2}},
+						{"geometry": {"coordinates": [130.4, 33.6]}, "properties": {"type": 1}}
+					]}`}}},
+				},
+			})
+
+			collection, err := amesh.CreateGeoJSON(t.Context(), &amesh.CreateGeoJSONParams{
+				LocationImageParams: amesh.LocationImageParams{
+					Client:   transport.Client(),
+					Location: &amesh.Location{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"},
+					Zoom:     10,
+				},
+				Clock: clocktest.NewFake(time.Date(2024, 1, 1, 3, 10, 0, 0, time.UTC)),
+			})
+			if err != nil {
+				t.Fatalf("CreateGeoJSON() error = %v", err)
+			}
+			// タイル画像は取得しない
+			if got := len(transport.RequestsTo(".png")); got != 0 {
+				t.Errorf("tile requests = %d, want 0", got)
+			}
+
+			// GeoJSONとして書き出した内容を検証する
+			data, err := json.Marshal(collection)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			var decoded struct {
+				Type     string    `json:"type"`
+				BBox     []float64 `json:"bbox"`
+				Features []struct {
+					Geometry struct {
+						Type        string          `json:"type"`
+						Coordinates json.RawMessage `json:"coordinates"`
+					} `json:"geometry"`
+					Properties map[string]any `json:"properties"`
+				} `json:"features"`
+			}
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if decoded.Type != "FeatureCollection" {
+				t.Errorf("type = %q, want FeatureCollection", decoded.Type)
+			}
+			if len(decoded.BBox) != 4 || !(decoded.BBox[0] < 139.7671 && 139.7671 < decoded.BBox[2]) {
+				t.Errorf("bbox = %v does not contain the center", decoded.BBox)
+			}
+
+			var kinds []string
+			var lightning []map[string]any
+			for _, feature := range decoded.Features {
+				kind, _ := feature.Properties["kind"].(string)
+				kinds = append(kinds, kind)
+				switch kind {
+				case amesh.FeatureKindLightning:
+					lightning = append(lightning, feature.Properties)
+				case amesh.FeatureKindDistanceCircle:
+					// ポリゴンの始点と終点は同じ点
+					var rings [][][2]float64
+					if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil {
+						t.Fatalf("json.Unmarshal() error = %v", err)
+					}
+					if ring := rings[0]; ring[0] != ring[len(ring)-1] {
+						t.Errorf("ring is not closed: %v != %v", ring[0], ring[len(ring)-1])
+					}
+				}
+			}
+			if diff := cmp.Diff(tt.expectedKinds, kinds); diff != "" {
+				t.Errorf("kinds mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedLightning, lightning); diff != "" {
+				t.Errorf("lightning mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedProperties, decoded.Features[0].Properties); diff != "" {
+				t.Errorf("center properties mismatch (-want +got):\n%s", diff)
+			}
+
+			if !collection.Metadata.RadarTime.Equal(tt.expectedRadarTime) {
+				t.Errorf("RadarTime = %v, want %v", collection.Metadata.RadarTime, tt.expectedRadarTime)
+			}
+			if got := collection.Metadata.LightningCount; got != len(tt.expectedLightning) {
+				t.Errorf("LightningCount = %d, want %d", got, len(tt.expectedLightning))
+			}
+		})
+	}
+}
+
+func TestCreateGeoJSONParamsNil(t *testing.T) {
+	if _, err := amesh.CreateGeoJSON(t.Context(), &amesh.CreateGeoJSONParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("CreateGeoJSON() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
@@ -0,0 +1,165 @@
+package amesh
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image/color"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/requestid"
+)
+
+var (
+	// ErrNoHistoryData 過去の雨雲レーダーのタイムスタンプが取得できないことを表すエラー
+	ErrNoHistoryData = errors.New("no history data available")
+	// ErrHistoryComparison 複数の地点の過去の雨雲レーダーを並べようとしたことを表すエラー
+	ErrHistoryComparison = errors.New("history supports only one place")
+	// ErrInvalidHistoryDuration 過去の雨雲レーダーを並べる期間が範囲外であることを表すエラー
+	ErrInvalidHistoryDuration = errors.New("invalid history duration")
+)
+
+// 過去の雨雲レーダーを並べる期間
+// 気象庁の降水ナウキャストのtargetTimesは3時間前までの解析値を5分ごとに返す
+const (
+	DefaultHistoryDuration = 3 * time.Hour    // 期間を指定しない場合の期間
+	MinHistoryDuration     = 30 * time.Minute // 指定できる最短の期間（パネルの間隔が解析値の間隔を下回らない長さ）
+	MaxHistoryDuration     = 3 * time.Hour    // 指定できる最長の期間
+)
+
+// 過去の雨雲レーダーの画像の定数
+const (
+	historyWord        = "history"       // ameshコマンドで過去の雨雲レーダーを並べる指定
+	historyFrames      = 6               // 並べるパネルの数（最新の解析値を含む）
+	historyColumns     = 3               // 1行に並べるパネルの数
+	historyAroundTiles = 1               // 各パネルの周囲のタイル数（パネルを並べるため通常の画像より狭くする）
+	historyInterval    = 5 * time.Minute // 雨雲レーダーの解析値の間隔
+)
+
+// historyBannerColor 過去のパネルのバナーの背景色
+var historyBannerColor = color.RGBA{R: 32, G: 96, B: 128, A: 255}
+
+// HistoryImageParams 過去の雨雲レーダーを並べた画像作成のリクエスト構造体
+type HistoryImageParams struct {
+	LocationImageParams
+	Duration time.Duration // 並べる期間（0の場合はDefaultHistoryDuration）
+}
+
+// CreateHistoryImageReader 既定のHTTPクライアントとtargetTimesのキャッシュでCreateHistoryImageReaderWithClientを呼び出す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateHistoryImageReader(ctx context.Context, location *Location, overlays []OverlayName, duration time.Duration) (*ImageReader, error) {
+	return CreateHistoryImageReaderWithClient(ctx, &HistoryImageParams{
+		LocationImageParams: LocationImageParams{
+			Client:         defaultClient,
+			Location:       location,
+			TimestampCache: defaultTimestampCache,
+			Overlays:       overlays,
+			StaleThreshold: getStaleThreshold(),
+			OverlayStyles:  getOverlayStyles(),
+			MapStyle:       getMapStyle(),
+		},
+		Duration: duration,
+	})
+}
+
+// CreateHistoryImageReaderWithClient HTTPクライアントを指定して過去の雨雲レーダーを並べた画像を作成し、PNG形式にエンコードしながら読み出すImageReaderを返す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateHistoryImageReaderWithClient(ctx context.Context, params *HistoryImageParams) (*ImageReader, error) {
+	result, err := CreateHistoryImage(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to CreateHistoryImage")
+	}
+	logTileStats(ctx, result.Tiles)
+	return result.Reader(), nil
+}
+
+// CreateHistoryImage 期間内の雨雲レーダーを等間隔に選び、古い順に左上から1行にhistoryColumns枚ずつ並べた画像を作成する
+// 各パネルの上部に何分前（PAST）か最新の観測（OBSERVED）かと時刻を日本時間で表示し、落雷は最新のパネルにのみ描画する
+// 期間が範囲外の場合はErrInvalidHistoryDuration、最新の解析値以外に並べる解析値がない場合はErrNoHistoryDataを返す
+func CreateHistoryImage(ctx context.Context, params *HistoryImageParams) (*AmeshResult, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+	duration := cmp.Or(params.Duration, DefaultHistoryDuration)
+	if duration < MinHistoryDuration || MaxHistoryDuration < duration {
+		return nil, errors.Wrapf(ErrInvalidHistoryDuration, "duration: %s", duration)
+	}
+	imageParams := locationImageParams(&params.LocationImageParams)
+	imageParams.AroundTiles = historyAroundTiles
+	// 過去の様子のない画像を返さないよう、雨雲レーダーがない場合は描画しない
+	imageParams.NoRadarData = NoRadarDataFail
+	if err := validateMapParams(imageParams); err != nil {
+		return nil, errors.Wrap(err, "Failed to validateMapParams")
+	}
+
+	timestamps := getLatestTimestampsWithLog(ctx, imageParams)
+	layers, err := defaultLayers(ctx, imageParams, timestamps)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to defaultLayers")
+	}
+	radar := findRadarLayer(layers)
+	if radar == nil {
+		return nil, errors.Wrap(ErrNoHistoryData, "radar overlay is not selected")
+	}
+	radarTime := parseJMATimestamp(radar.Timestamp)
+	historyTimes := selectHistoryTimes(timestamps.ObservedTimes(ElementRadar), radarTime, duration)
+	if len(historyTimes) < 2 {
+		return nil, errors.Wrapf(ErrNoHistoryData, "basetime: %s", radar.Timestamp)
+	}
+	if skipped := historyFrames - len(historyTimes); 0 < skipped {
+		requestid.Logf(ctx, "Skipped %d history panels without radar data", skipped)
+	}
+
+	viewport := &Viewport{
+		Lat:         imageParams.Lat,
+		Lng:         imageParams.Lng,
+		Zoom:        imageParams.Zoom,
+		AroundTiles: imageParams.AroundTiles,
+	}
+	viewports := make([]*Viewport, 0, len(historyTimes))
+	panelLayers := make([][]Layer, 0, len(historyTimes))
+	for _, historyTime := range historyTimes {
+		viewports = append(viewports, viewport)
+		if historyTime.Equal(radarTime) {
+			panelLayers = append(panelLayers, append(slices.Clone(layers), observedBanner(layers, radarTime)))
+			continue
+		}
+		// 過去の解析値は基準時刻と対象時刻が同じタイルとして描画する
+		panelLayers = append(panelLayers, append(forecastLayers(layers, ForecastTime{BaseTime: historyTime, ValidTime: historyTime}), &BannerLayer{
+			Text:  fmt.Sprintf("PAST -%d MIN %s", int(radarTime.Sub(historyTime).Minutes()), historyTime.In(jst).Format("15:04 MST")),
+			Color: historyBannerColor,
+		}))
+	}
+	rendered, bbox := renderGrid(ctx, viewports, panelLayers, historyColumns)
+
+	result := &AmeshResult{
+		Image:         rendered.Image,
+		AmeshMetadata: rendered.metadata(layers),
+	}
+	result.BoundingBox = bbox
+	result.HistoryTimes = historyTimes
+	result.Locations = []Location{*params.Location}
+	return result, nil
+}
+
+// selectHistoryTimes latestまでのduration内の解析値の時刻からhistoryFrames個を等間隔に選び、古い順に返す
+// 間隔は解析値の間隔に切り捨て、解析値がない時刻は飛ばす（latestは常に含める）
+func selectHistoryTimes(observedTimes []time.Time, latest time.Time, duration time.Duration) []time.Time {
+	if latest.IsZero() {
+		return nil
+	}
+
+	step := (duration / historyFrames).Truncate(historyInterval)
+	var selected []time.Time
+	for i := historyFrames - 1; 0 < i; i-- {
+		historyTime := latest.Add(-time.Duration(i) * step)
+		if slices.ContainsFunc(observedTimes, historyTime.Equal) {
+			selected = append(selected, historyTime)
+		}
+	}
+	return append(selected, latest)
+}
@@ -0,0 +1,127 @@
+package amesh_test
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+)
+
+// pastTimes DefaultBaseTimeまでstepごとに並べたframes個の時刻を古い順に返す
+func pastTimes(step time.Duration, frames int) []time.Time {
+	times := make([]time.Time, 0, frames)
+	for i := frames - 1; 0 <= i; i-- {
+		times = append(times, ameshtest.DefaultBaseTime.Add(-time.Duration(i)*step))
+	}
+	return times
+}
+
+func TestCreateHistoryImage(t *testing.T) {
+	tests := []struct {
+		name                 string
+		scenario             *ameshtest.Scenario
+		duration             time.Duration
+		expectedHistoryTimes []time.Time
+		expectError          error
+	}{
+		{
+			name: "期間を省略した場合は3時間を30分ごとに並べる",
+			scenario: &ameshtest.Scenario{
+				History:   true,
+				Lightning: []ameshtest.Lightning{{Lat: 35.68, Lng: 139.76, Type: 1}},
+			},
+			expectedHistoryTimes: pastTimes(30*time.Minute, 6),
+		},
+		{
+			name:                 "90分を15分ごとに並べる",
+			scenario:             &ameshtest.Scenario{History: true},
+			duration:             90 * time.Minute,
+			expectedHistoryTimes: pastTimes(15*time.Minute, 6),
+		},
+		{
+			name:        "過去の解析値がない",
+			scenario:    &ameshtest.Scenario{},
+			expectError: amesh.ErrNoHistoryData,
+		},
+		{
+			name:        "さかのぼれる期間より長い",
+			scenario:    &ameshtest.Scenario{History: true},
+			duration:    5 * time.Hour,
+			expectError: amesh.ErrInvalidHistoryDuration,
+		},
+		{
+			name:        "雨雲レーダーのタイムスタンプが取得できない",
+			scenario:    &ameshtest.Scenario{History: true, NoTargetTimes: true},
+			expectError: amesh.ErrNoRadarData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, tt.scenario)
+
+			result, err := amesh.CreateHistoryImage(t.Context(), &amesh.HistoryImageParams{
+				LocationImageParams: amesh.LocationImageParams{
+					Client:   server.Client(),
+					Location: &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"},
+					Zoom:     10,
+				},
+				Duration: tt.duration,
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("CreateHistoryImage() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+
+			// 3列2行に並べる
+			if bounds := result.Image.Bounds(); bounds.Dx() != 768*3+8*2 || bounds.Dy() != 768*2+8 {
+				t.Errorf("image size = %v, want %dx%d", bounds.Size(), 768*3+8*2, 768*2+8)
+			}
+			if diff := cmp.Diff(tt.expectedHistoryTimes, result.HistoryTimes); diff != "" {
+				t.Errorf("HistoryTimes mismatch (-want +got):\n%s", diff)
+			}
+			if !result.RadarTime.Equal(ameshtest.DefaultBaseTime) {
+				t.Errorf("RadarTime = %v, want %v", result.RadarTime, ameshtest.DefaultBaseTime)
+			}
+			// 落雷は最新のパネルにのみ描画する
+			if expected := len(tt.scenario.Lightning); result.LightningCount != expected {
+				t.Errorf("LightningCount = %d, want %d", result.LightningCount, expected)
+			}
+
+			// 過去のパネルは過去の解析値の雨雲レーダーのタイルを取得する
+			for _, historyTime := range tt.expectedHistoryTimes {
+				timestamp := historyTime.Format("20060102150405")
+				if got := server.RequestsTo("/nowc/" + timestamp + "/none/" + timestamp + "/surf/hrpns/"); len(got) == 0 {
+					t.Errorf("no radar tile requested for basetime %s", timestamp)
+				}
+			}
+
+			// 各パネルの上部に過去か最新の観測かを表すバナーを描画する
+			for i := range tt.expectedHistoryTimes {
+				expected := color.RGBA{R: 32, G: 96, B: 128, A: 255}
+				if i == len(tt.expectedHistoryTimes)-1 {
+					expected = color.RGBA{R: 64, G: 64, B: 64, A: 255}
+				}
+				x, y := i%3*(768+8), i/3*(768+8)
+				if banner := result.Image.RGBAAt(x+1, y+1); banner != expected {
+					t.Errorf("panel %d banner pixel = %v, want %v", i+1, banner, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateHistoryImageParamsNil(t *testing.T) {
+	if _, err := amesh.CreateHistoryImage(t.Context(), &amesh.HistoryImageParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("CreateHistoryImage() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
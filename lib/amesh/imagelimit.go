@@ -0,0 +1,213 @@
+package amesh
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/config"
+)
+
+var (
+	// ErrInvalidImageLimit 画像の大きさの上限の設定値が不正であることを表すエラー
+	ErrInvalidImageLimit = errors.New("invalid image limit")
+	// ErrImageTooLarge 縮小しても画像を上限のバイト数に収められないことを表すエラー
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+// minLimitedDimension バイト数の上限に収めるために縮小する画像の幅と高さの下限
+// これより小さくしないと収まらない場合はErrImageTooLargeを返す
+const minLimitedDimension = 128
+
+// limitScaleStep バイト数の上限に収まらない場合に一度に縮小する割合
+const limitScaleStep = 0.8
+
+// limitEncoder 上限に収めるために再エンコードするPNGエンコーダ（大きさを優先して最も圧縮する）
+var limitEncoder = &png.Encoder{CompressionLevel: png.BestCompression}
+
+// ImageLimit プラットフォームにアップロードする画像の大きさの上限
+type ImageLimit struct {
+	MaxDimension int // 幅と高さの上限（ピクセル、0の場合は制限しない）
+	MaxBytes     int // PNG形式にエンコードしたバイト数の上限（0の場合は制限しない）
+	// ThumbnailDimension 返信に添付するサムネイルの幅と高さの上限（ピクセル、0の場合はサムネイルにしない）
+	// LimitPNGでは使わず、対応するプラットフォームが元の大きさの画像と別にアップロードするサムネイルの作成に使う
+	ThumbnailDimension int
+}
+
+// NewImageLimitsFromConfig 設定ファイルのプラットフォームごとの画像の大きさの上限からImageLimitを作成する
+func NewImageLimitsFromConfig(settings map[string]config.ImageLimit) (map[string]*ImageLimit, error) {
+	limits := make(map[string]*ImageLimit, len(settings))
+	for platform, setting := range settings {
+		if setting.MaxDimension < 0 || (0 < setting.MaxDimension && setting.MaxDimension < minLimitedDimension) {
+			return nil, errors.Wrapf(ErrInvalidImageLimit, "%s: max_dimension: %d", platform, setting.MaxDimension)
+		}
+		if setting.MaxBytes < 0 {
+			return nil, errors.Wrapf(ErrInvalidImageLimit, "%s: max_bytes: %d", platform, setting.MaxBytes)
+		}
+		if setting.ThumbnailDimension < 0 || (0 < setting.ThumbnailDimension && setting.ThumbnailDimension < minLimitedDimension) {
+			return nil, errors.Wrapf(ErrInvalidImageLimit, "%s: thumbnail_dimension: %d", platform, setting.ThumbnailDimension)
+		}
+		limits[platform] = &ImageLimit{
+			MaxDimension:       setting.MaxDimension,
+			MaxBytes:           setting.MaxBytes,
+			ThumbnailDimension: setting.ThumbnailDimension,
+		}
+	}
+	return limits, nil
+}
+
+// LimitPNG PNG画像を上限の大きさに収めて返す
+// 幅か高さが上限を超える場合は面積平均で縮小し、バイト数が上限を超える場合は256色に減色して再エンコードし、
+// それでも収まらない場合はさらに縮小する。上限に収まっている画像はそのまま返し、テキストチャンクは引き継ぐ
+func LimitPNG(r io.Reader, limit *ImageLimit) (*bytes.Buffer, error) {
+	data := &bytes.Buffer{}
+	if _, err := data.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(err, "Failed to ReadFrom")
+	}
+	if limit == nil || (limit.MaxDimension == 0 && limit.MaxBytes == 0) {
+		return data, nil
+	}
+
+	imageConfig, err := png.DecodeConfig(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidPNG, "Failed to png.DecodeConfig: %v", err)
+	}
+	width, height := fitDimension(imageConfig.Width, imageConfig.Height, limit.MaxDimension)
+	if width == imageConfig.Width && height == imageConfig.Height && (limit.MaxBytes == 0 || data.Len() <= limit.MaxBytes) {
+		return data, nil
+	}
+
+	texts, err := ReadPNGText(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ReadPNGText")
+	}
+	img, err := png.Decode(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidPNG, "Failed to png.Decode: %v", err)
+	}
+
+	for {
+		resized := resizeImage(img, width, height)
+		encoded, err := encodeLimitedPNG(resized, texts)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to encodeLimitedPNG")
+		}
+		if limit.MaxBytes == 0 || encoded.Len() <= limit.MaxBytes {
+			return encoded, nil
+		}
+		// 画質を下げて256色に減色する
+		encoded, err = encodeLimitedPNG(quantizeImage(resized), texts)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to encodeLimitedPNG")
+		}
+		if encoded.Len() <= limit.MaxBytes {
+			return encoded, nil
+		}
+
+		width, height = int(float64(width)*limitScaleStep), int(float64(height)*limitScaleStep)
+		if max(width, height) < minLimitedDimension {
+			return nil, errors.Wrapf(ErrImageTooLarge, "%d bytes exceeds %d bytes", encoded.Len(), limit.MaxBytes)
+		}
+	}
+}
+
+// fitDimension 縦横比を保って幅と高さを上限に収めた大きさを返す
+// 上限が0または上限に収まっている場合はそのまま返す
+func fitDimension(width, height, maxDimension int) (int, int) {
+	if maxDimension == 0 || max(width, height) <= maxDimension {
+		return width, height
+	}
+	scale := float64(maxDimension) / float64(max(width, height))
+	return max(int(math.Round(float64(width)*scale)), 1), max(int(math.Round(float64(height)*scale)), 1)
+}
+
+// encodeLimitedPNG テキストチャンクを埋め込んでPNG形式にエンコードする
+func encodeLimitedPNG(img image.Image, texts []PNGText) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	if err := limitEncoder.Encode(newPNGTextWriter(buf, texts), img); err != nil {
+		return nil, errors.Wrap(err, "Failed to limitEncoder.Encode")
+	}
+	return buf, nil
+}
+
+// quantizeImage 誤差拡散で256色に減色する
+func quantizeImage(img *image.RGBA) *image.Paletted {
+	paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, img.Bounds().Min)
+	return paletted
+}
+
+// resizeImage 面積平均で画像を縮小する
+// 縮小後の画素は元の画像で重なる範囲の画素を面積の割合で平均するため、細い線や文字も消えにくい
+func resizeImage(img image.Image, width, height int) *image.RGBA {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = image.NewRGBA(img.Bounds())
+		draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	bounds := src.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return src
+	}
+
+	// 横方向に縮小してから縦方向に縮小する（RGBAは乗算済みのアルファのため、そのまま平均できる）
+	columns := areaWeights(bounds.Dx(), width)
+	rows := areaWeights(bounds.Dy(), height)
+	horizontal := make([]float64, width*bounds.Dy()*4)
+	for y := range bounds.Dy() {
+		for x, weights := range columns {
+			sum := horizontal[(y*width+x)*4 : (y*width+x)*4+4]
+			for _, w := range weights {
+				i := src.PixOffset(bounds.Min.X+w.index, bounds.Min.Y+y)
+				for c := range 4 {
+					sum[c] += float64(src.Pix[i+c]) * w.weight
+				}
+			}
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, weights := range rows {
+		for x := range width {
+			var sum [4]float64
+			for _, w := range weights {
+				for c := range 4 {
+					sum[c] += horizontal[(w.index*width+x)*4+c] * w.weight
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for c := range 4 {
+				dst.Pix[i+c] = uint8(min(max(sum[c]+0.5, 0), 255))
+			}
+		}
+	}
+	return dst
+}
+
+// areaWeight 縮小後の画素に対する元の画素の重み
+type areaWeight struct {
+	index  int     // 元の画素の位置
+	weight float64 // 重なる長さの割合（縮小後の画素ごとに合計1）
+}
+
+// areaWeights 長さsrcLengthをdstLengthに縮小する場合の、縮小後の画素ごとの元の画素の重みを返す
+func areaWeights(srcLength, dstLength int) [][]areaWeight {
+	scale := float64(srcLength) / float64(dstLength)
+	weights := make([][]areaWeight, dstLength)
+	for i := range weights {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < min(int(math.Ceil(end)), srcLength); j++ {
+			overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if 0 < overlap {
+				weights[i] = append(weights[i], areaWeight{index: j, weight: overlap / scale})
+			}
+		}
+	}
+	return weights
+}
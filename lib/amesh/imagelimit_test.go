@@ -0,0 +1,184 @@
+package amesh_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/config"
+)
+
+func TestNewImageLimitsFromConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      map[string]config.ImageLimit
+		expected      map[string]*amesh.ImageLimit
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			settings: nil,
+			expected: map[string]*amesh.ImageLimit{},
+		},
+		{
+			name: "プラットフォームごとの上限",
+			settings: map[string]config.ImageLimit{
+				"misskey": {MaxDimension: 1024, ThumbnailDimension: 512},
+				"mixi2":   {MaxDimension: 768, MaxBytes: 1 << 20},
+			},
+			expected: map[string]*amesh.ImageLimit{
+				"misskey": {MaxDimension: 1024, ThumbnailDimension: 512},
+				"mixi2":   {MaxDimension: 768, MaxBytes: 1 << 20},
+			},
+		},
+		{
+			name:          "小さすぎる幅と高さ",
+			settings:      map[string]config.ImageLimit{"misskey": {MaxDimension: 64}},
+			expectedError: amesh.ErrInvalidImageLimit,
+		},
+		{
+			name:          "小さすぎるサムネイル",
+			settings:      map[string]config.ImageLimit{"misskey": {ThumbnailDimension: 64}},
+			expectedError: amesh.ErrInvalidImageLimit,
+		},
+		{
+			name:          "負のバイト数",
+			settings:      map[string]config.ImageLimit{"misskey": {MaxBytes: -1}},
+			expectedError: amesh.ErrInvalidImageLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := amesh.NewImageLimitsFromConfig(tt.settings)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("NewImageLimitsFromConfig() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("NewImageLimitsFromConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestLimitPNGDimension 幅と高さの上限を超える画像を縮小し、埋め込んだテキストを引き継ぐことをテストする
+func TestLimitPNGDimension(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        *amesh.ImageLimit
+		expectedSize int
+	}{
+		{name: "上限なし", limit: nil, expectedSize: 768},
+		{name: "上限に収まる", limit: &amesh.ImageLimit{MaxDimension: 1280}, expectedSize: 768},
+		{name: "上限を超える", limit: &amesh.ImageLimit{MaxDimension: 384}, expectedSize: 384},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, nil)
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      server.Client(),
+				Lat:         35.6895,
+				Lng:         139.6917,
+				Zoom:        10,
+				AroundTiles: 1,
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+			radar := result.Image.RGBAAt(700, 100)
+			reader := result.Reader()
+			defer func() { _ = reader.Close() }()
+
+			limited, err := amesh.LimitPNG(reader, tt.limit)
+			if err != nil {
+				t.Fatalf("LimitPNG() error = %v", err)
+			}
+			data := limited.Bytes()
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("png.Decode() error = %v", err)
+			}
+			if size := img.Bounds().Size(); size != image.Pt(tt.expectedSize, tt.expectedSize) {
+				t.Errorf("size = %v, want %dx%d", size, tt.expectedSize, tt.expectedSize)
+			}
+			// 一様な色の範囲は縮小しても同じ色になる
+			scale := 768 / tt.expectedSize
+			if got := color.RGBAModel.Convert(img.At(700/scale, 100/scale)); got != radar {
+				t.Errorf("radar pixel = %v, want %v", got, radar)
+			}
+			texts, err := amesh.ReadPNGText(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ReadPNGText() error = %v", err)
+			}
+			if diff := cmp.Diff(result.PNGText(), texts); diff != "" {
+				t.Errorf("PNG text mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestLimitPNGBytes バイト数の上限を超える画像を減色・縮小して収めることをテストする
+func TestLimitPNGBytes(t *testing.T) {
+	// 圧縮の効かない乱数の画像（約1MB）
+	noise := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(random.UintN(256))
+		if i%4 == 3 {
+			noise.Pix[i] = 255
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, noise); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	original := buf.Bytes()
+
+	tests := []struct {
+		name          string
+		maxBytes      int
+		expectedError error
+	}{
+		{name: "上限に収まる", maxBytes: len(original)},
+		{name: "減色と縮小で収める", maxBytes: 200_000},
+		{name: "収められない", maxBytes: 100, expectedError: amesh.ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			limited, err := amesh.LimitPNG(bytes.NewReader(original), &amesh.ImageLimit{MaxBytes: tt.maxBytes})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("LimitPNG() error = %v, want %v", err, tt.expectedError)
+			}
+			if err != nil {
+				return
+			}
+			if tt.maxBytes < limited.Len() {
+				t.Errorf("len = %d, want <= %d", limited.Len(), tt.maxBytes)
+			}
+			if _, err := png.Decode(limited); err != nil {
+				t.Errorf("png.Decode() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLimitPNGInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := amesh.LimitPNG(bytes.NewReader([]byte("not a png")), &amesh.ImageLimit{MaxDimension: 256})
+	if !errors.Is(err, amesh.ErrInvalidPNG) {
+		t.Errorf("LimitPNG() error = %v, want %v", err, amesh.ErrInvalidPNG)
+	}
+}
@@ -0,0 +1,571 @@
+package amesh
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/font"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/requestid"
+)
+
+// バナーとラベルの定数
+const (
+	bannerHeight = 40 // バナーの高さ（ピクセル）
+	labelPadding = 8  // ラベルの文言の周囲の余白（ピクセル）
+	textScale    = 3  // バナーとラベルの文字の倍率
+)
+
+// バナーとラベルの背景色
+var (
+	bannerColor = color.RGBA{R: 200, A: 255}
+	labelColor  = color.RGBA{A: 180}
+)
+
+// Layer 画像に重ねて描画するレイヤー
+// RenderLayersは登録された順にDrawを呼び出し、後のレイヤーほど上に描画される
+type Layer interface {
+	Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error
+}
+
+// TileLayer タイル画像を取得して描画するレイヤー
+// RenderLayersはDrawの代わりにDrawTilesを呼び出し、タイルの取得結果を集計する
+type TileLayer interface {
+	Layer
+	DrawTiles(ctx context.Context, canvas *image.RGBA, viewport *Viewport) TileStats
+}
+
+// PointLayer 地点データを取得して描画するレイヤー
+// RenderLayersはDrawの代わりにDrawPointsを呼び出し、描画範囲内の地点数を集計する
+type PointLayer interface {
+	Layer
+	DrawPoints(ctx context.Context, canvas *image.RGBA, viewport *Viewport) (int, error)
+}
+
+// TileStats タイル画像の取得結果
+type TileStats struct {
+	Fetched int // 取得できたタイル数
+	Failed  int // 取得できなかったタイル数
+	NoData  int // 取得できずにデータなしの模様を描画したタイル数
+}
+
+// add 取得結果を合算する
+func (s *TileStats) add(other TileStats) {
+	s.Fetched += other.Fetched
+	s.Failed += other.Failed
+	s.NoData += other.NoData
+}
+
+// RenderResult レイヤーの描画結果
+type RenderResult struct {
+	Image  *image.RGBA // 描画した画像
+	Tiles  TileStats   // 全レイヤーのタイルの取得結果
+	Points int         // 全レイヤーの描画範囲内の地点数（落雷地点など）
+}
+
+// Viewport 描画する範囲
+// 中心座標の周囲AroundTiles枚分のタイルを1辺(2*AroundTiles+1)*256ピクセルの画像に描画する
+type Viewport struct {
+	Lat         float64 // 中心の緯度
+	Lng         float64 // 中心の経度
+	Zoom        int     // ズームレベル
+	AroundTiles int     // 中心のタイルの周囲に描画するタイル数
+}
+
+// viewportTile 描画範囲に含まれるタイル
+type viewportTile struct {
+	X    int             // タイル番号X（日付変更線で折り返し済み）
+	Y    int             // タイル番号Y
+	Rect image.Rectangle // 画像上の描画先
+}
+
+// Size 画像の1辺のピクセル数
+func (v *Viewport) Size() int {
+	return (2*v.AroundTiles + 1) * 256
+}
+
+// ImagePoint 地理座標を画像上の座標に変換する
+func (v *Viewport) ImagePoint(lat, lng float64) image.Point {
+	x, y := getWebMercatorPixel(&CreateAmeshImageParams{Lat: lat, Lng: lng, Zoom: v.Zoom})
+	centerX, centerY := getWebMercatorPixel(&CreateAmeshImageParams{Lat: v.Lat, Lng: v.Lng, Zoom: v.Zoom})
+	return image.Point{
+		X: int(x - centerX + float64(v.Size()/2)),
+		Y: int(y - centerY + float64(v.Size()/2)),
+	}
+}
+
+// tiles 描画範囲に含まれるタイルを返す
+// 経度方向は日付変更線で折り返し、緯度方向の範囲外のタイルは含めない
+func (v *Viewport) tiles() []viewportTile {
+	centerX, centerY := getWebMercatorPixel(&CreateAmeshImageParams{Lat: v.Lat, Lng: v.Lng, Zoom: v.Zoom})
+	centerTileX, centerTileY := int(math.Floor(centerX/256)), int(math.Floor(centerY/256))
+
+	var tiles []viewportTile
+	for dy := -v.AroundTiles; dy <= v.AroundTiles; dy++ {
+		for dx := -v.AroundTiles; dx <= v.AroundTiles; dx++ {
+			tileY := centerTileY + dy
+			if tileY < 0 || tileCount(v.Zoom) <= tileY {
+				continue
+			}
+			tiles = append(tiles, viewportTile{
+				X: wrapTileX(centerTileX+dx, v.Zoom),
+				Y: tileY,
+				Rect: image.Rect(
+					(dx+v.AroundTiles)*256,
+					(dy+v.AroundTiles)*256,
+					(dx+v.AroundTiles+1)*256,
+					(dy+v.AroundTiles+1)*256,
+				),
+			})
+		}
+	}
+	return tiles
+}
+
+// RenderLayers 白い背景の上にレイヤーを順に描画する
+// 描画に失敗したレイヤーはログに出力して飛ばし、残りのレイヤーの描画を続ける
+// タイルは1枚ずつ描画するため、一部のタイルが取得できなくても取得できた部分は描画される
+func RenderLayers(ctx context.Context, viewport *Viewport, layers []Layer) *RenderResult {
+	result := &RenderResult{Image: getCanvas(viewport.Size(), viewport.Size())}
+	draw.Draw(result.Image, result.Image.Bounds(), image.NewUniform(color.RGBA{R: 255, G: 255, B: 255, A: 255}), image.Point{}, draw.Src)
+
+	for _, layer := range layers {
+		if tileLayer, ok := layer.(TileLayer); ok {
+			result.Tiles.add(tileLayer.DrawTiles(ctx, result.Image, viewport))
+			continue
+		}
+		if pointLayer, ok := layer.(PointLayer); ok {
+			points, err := pointLayer.DrawPoints(ctx, result.Image, viewport)
+			if err != nil {
+				requestid.Logf(ctx, "Failed to draw layer %T: %v", layer, err)
+			}
+			result.Points += points
+			continue
+		}
+		if err := layer.Draw(ctx, result.Image, viewport); err != nil {
+			requestid.Logf(ctx, "Failed to draw layer %T: %v", layer, err)
+		}
+	}
+	return result
+}
+
+// osmTileURL OpenStreetMapのタイル画像のURLを返す
+func osmTileURL(zoom, x, y int) string {
+	return fmt.Sprintf("https://tile.openstreetmap.org/%d/%d/%d.png", zoom, x, y)
+}
+
+// jmaTileURL 気象庁のタイル画像のURLを返す関数を作成する
+// categoryはnowcやrisk、snow、elementはhrpnsやflood、snowdなどのタイルの種類
+// 解析値はbaseTimeとvalidTimeが同じで、予測はvalidTimeに予測の対象時刻を指定する
+func jmaTileURL(category, baseTime, validTime, element string) func(zoom, x, y int) string {
+	return func(zoom, x, y int) string {
+		return fmt.Sprintf(
+			"https://www.jma.go.jp/bosai/jmatile/data/%s/%s/none/%s/surf/%s/%d/%d/%d.png",
+			category, baseTime, validTime, element, zoom, x, y,
+		)
+	}
+}
+
+// drawTilesParams タイル画像を描画するためのパラメータ
+type drawTilesParams struct {
+	Client        httpclient.Doer
+	TileURL       func(zoom, x, y int) string // タイル画像のURL
+	Alpha         uint8                       // 不透明度（255の場合はそのまま描画する）
+	Blend         BlendMode                   // 合成方法（空の場合はBlendNormal）
+	NoDataPattern bool                        // 取得できなかったタイルにデータなしの模様を描画するか
+}
+
+// drawTiles 描画範囲のタイルをダウンロードして描画する
+// ダウンロードできなかったタイルはログに出力して飛ばし、NoDataPatternが指定されている場合は斜線を描画する
+func drawTiles(ctx context.Context, canvas *image.RGBA, viewport *Viewport, params *drawTilesParams) TileStats {
+	var stats TileStats
+	for _, tile := range viewport.tiles() {
+		tileImg, err := downloadTile(ctx, params.Client, params.TileURL(viewport.Zoom, tile.X, tile.Y))
+		if err != nil {
+			requestid.Logf(ctx, "Failed to downloadTile: %v", err)
+			stats.Failed++
+			if params.NoDataPattern {
+				drawNoDataPattern(canvas, tile.Rect)
+				stats.NoData++
+			}
+			continue
+		}
+		stats.Fetched++
+
+		if params.Blend != "" && params.Blend != BlendNormal {
+			blendTile(canvas, tile.Rect, tileImg, params.Alpha, params.Blend)
+			continue
+		}
+		if params.Alpha == 255 {
+			draw.Draw(canvas, tile.Rect, tileImg, image.Point{}, draw.Over)
+			continue
+		}
+		draw.DrawMask(
+			canvas,
+			tile.Rect,
+			tileImg,
+			image.Point{},
+			image.NewUniform(color.RGBA{R: 255, G: 255, B: 255, A: params.Alpha}),
+			image.Point{},
+			draw.Over,
+		)
+	}
+	return stats
+}
+
+// drawNoDataPattern データを取得できなかった範囲に灰色の斜線を描画する
+// 雨が降っていない範囲と区別できるように、ベースマップが透けて見える程度の間隔で描画する
+func drawNoDataPattern(canvas *image.RGBA, rect image.Rectangle) {
+	const (
+		stripeInterval = 16 // 斜線の間隔（ピクセル）
+		stripeWidth    = 3  // 斜線の太さ（ピクセル）
+	)
+
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	rect = rect.Intersect(canvas.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if (x+y)%stripeInterval < stripeWidth {
+				canvas.SetRGBA(x, y, gray)
+			}
+		}
+	}
+}
+
+// BaseMapLayer OpenStreetMapのベースマップ
+type BaseMapLayer struct {
+	Client httpclient.Doer
+	Dark   bool // 明るさを反転した暗いスタイルで描画するか
+}
+
+// Provider データの提供元を返す
+func (l *BaseMapLayer) Provider() string {
+	return ProviderOpenStreetMap
+}
+
+// Draw ベースマップのタイルを描画する
+func (l *BaseMapLayer) Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	l.DrawTiles(ctx, canvas, viewport)
+	return nil
+}
+
+// DrawTiles ベースマップのタイルを描画する
+// 暗いスタイルの場合は、取得できなかったタイルの背景も含めて描画範囲全体の明るさを反転する
+func (l *BaseMapLayer) DrawTiles(ctx context.Context, canvas *image.RGBA, viewport *Viewport) TileStats {
+	stats := drawTiles(ctx, canvas, viewport, &drawTilesParams{
+		Client:  l.Client,
+		TileURL: osmTileURL,
+		Alpha:   255,
+	})
+	if l.Dark {
+		darkenImage(canvas, canvas.Bounds())
+	}
+	return stats
+}
+
+// RadarLayer 気象庁ナウキャストの雨雲レーダー
+type RadarLayer struct {
+	Client    httpclient.Doer
+	Timestamp string    // targetTimesのbasetime
+	ValidTime string    // 予測の対象時刻（targetTimesのvalidtime、空の場合はTimestampの解析値）
+	Alpha     uint8     // 不透明度（0の場合は128）
+	Blend     BlendMode // 合成方法（空の場合はBlendNormal）
+}
+
+// Provider データの提供元を返す
+func (l *RadarLayer) Provider() string {
+	return ProviderJMA
+}
+
+// Draw 雨雲レーダーのタイルを半透明で重ねる
+func (l *RadarLayer) Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	l.DrawTiles(ctx, canvas, viewport)
+	return nil
+}
+
+// DrawTiles 雨雲レーダーのタイルを半透明で重ねる
+// 取得できなかったタイルには雨が降っていない範囲と区別するための斜線を描画する
+func (l *RadarLayer) DrawTiles(ctx context.Context, canvas *image.RGBA, viewport *Viewport) TileStats {
+	return drawTiles(ctx, canvas, viewport, &drawTilesParams{
+		Client:        l.Client,
+		TileURL:       jmaTileURL("nowc", l.Timestamp, cmp.Or(l.ValidTime, l.Timestamp), "hrpns"),
+		Alpha:         cmp.Or(l.Alpha, 128),
+		Blend:         l.Blend,
+		NoDataPattern: true,
+	})
+}
+
+// FloodLayer 気象庁の洪水キキクル（洪水警報の危険度分布）
+type FloodLayer struct {
+	Client    httpclient.Doer
+	Timestamp string    // キキクルのtargetTimesのbasetime
+	Alpha     uint8     // 不透明度（0の場合は160）
+	Blend     BlendMode // 合成方法（空の場合はBlendNormal）
+}
+
+// Provider データの提供元を返す
+func (l *FloodLayer) Provider() string {
+	return ProviderJMA
+}
+
+// Draw 洪水キキクルのタイルを半透明で重ねる
+func (l *FloodLayer) Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	l.DrawTiles(ctx, canvas, viewport)
+	return nil
+}
+
+// DrawTiles 洪水キキクルのタイルを半透明で重ねる
+func (l *FloodLayer) DrawTiles(ctx context.Context, canvas *image.RGBA, viewport *Viewport) TileStats {
+	return drawTiles(ctx, canvas, viewport, &drawTilesParams{
+		Client:  l.Client,
+		TileURL: jmaTileURL("risk", l.Timestamp, l.Timestamp, "flood"),
+		Alpha:   cmp.Or(l.Alpha, 160),
+		Blend:   l.Blend,
+	})
+}
+
+// SnowLayer 気象庁の現在の積雪の深さ（解析積雪深）
+type SnowLayer struct {
+	Client    httpclient.Doer
+	Timestamp string    // 積雪のtargetTimesのbasetime
+	Alpha     uint8     // 不透明度（0の場合は160）
+	Blend     BlendMode // 合成方法（空の場合はBlendNormal）
+}
+
+// Provider データの提供元を返す
+func (l *SnowLayer) Provider() string {
+	return ProviderJMA
+}
+
+// Draw 積雪の深さのタイルを半透明で重ねる
+func (l *SnowLayer) Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	l.DrawTiles(ctx, canvas, viewport)
+	return nil
+}
+
+// DrawTiles 積雪の深さのタイルを半透明で重ねる
+func (l *SnowLayer) DrawTiles(ctx context.Context, canvas *image.RGBA, viewport *Viewport) TileStats {
+	return drawTiles(ctx, canvas, viewport, &drawTilesParams{
+		Client:  l.Client,
+		TileURL: jmaTileURL("snow", l.Timestamp, l.Timestamp, "snowd"),
+		Alpha:   cmp.Or(l.Alpha, 160),
+		Blend:   l.Blend,
+	})
+}
+
+// Marker 地図上に描画する円形のマーカー
+type Marker struct {
+	Lat    float64    // 緯度
+	Lng    float64    // 経度
+	Radius int        // 半径（ピクセル）
+	Color  color.RGBA // 塗りつぶしの色
+}
+
+// MarkerLayer 円形のマーカーを描画するレイヤー
+type MarkerLayer struct {
+	Markers []Marker
+}
+
+// Draw マーカーを描画する
+func (l *MarkerLayer) Draw(_ context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	l.drawMarkers(canvas, viewport)
+	return nil
+}
+
+// drawMarkers マーカーを描画して中心が画像内にあるマーカーの数を返す
+// ピタゴラスの定理による円内判定で塗りつぶす
+func (l *MarkerLayer) drawMarkers(canvas *image.RGBA, viewport *Viewport) int {
+	bounds := canvas.Bounds()
+	visible := 0
+	for _, marker := range l.Markers {
+		center := viewport.ImagePoint(marker.Lat, marker.Lng)
+		if center.In(bounds) {
+			visible++
+		}
+		for dy := -marker.Radius; dy <= marker.Radius; dy++ {
+			for dx := -marker.Radius; dx <= marker.Radius; dx++ {
+				if marker.Radius*marker.Radius < dx*dx+dy*dy {
+					continue
+				}
+				p := image.Point{X: center.X + dx, Y: center.Y + dy}
+				if p.In(bounds) {
+					canvas.SetRGBA(p.X, p.Y, marker.Color)
+				}
+			}
+		}
+	}
+	return visible
+}
+
+// LightningLayer 気象庁ナウキャストの落雷マーカー
+type LightningLayer struct {
+	Client    httpclient.Doer
+	Timestamp string // targetTimesのlidenのbasetime
+}
+
+// Provider データの提供元を返す
+func (l *LightningLayer) Provider() string {
+	return ProviderJMA
+}
+
+// Draw 落雷データを取得して落雷地点にシアンの円を描画する
+func (l *LightningLayer) Draw(ctx context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	if _, err := l.DrawPoints(ctx, canvas, viewport); err != nil {
+		return errors.Wrap(err, "Failed to DrawPoints")
+	}
+	return nil
+}
+
+// DrawPoints 落雷データを取得して落雷地点にシアンの円を描画し、描画範囲内の落雷地点数を返す
+func (l *LightningLayer) DrawPoints(ctx context.Context, canvas *image.RGBA, viewport *Viewport) (int, error) {
+	lightningData, err := getLightningData(ctx, l.Client, l.Timestamp)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to getLightningData")
+	}
+
+	return (&MarkerLayer{Markers: lightningMarkers(lightningData)}).drawMarkers(canvas, viewport), nil
+}
+
+// lightningMarkers 落雷地点をシアンの円のマーカーにする
+func lightningMarkers(lightningData []lightningPoint) []Marker {
+	markers := make([]Marker, 0, len(lightningData))
+	for _, lightning := range lightningData {
+		markers = append(markers, Marker{
+			Lat:    lightning.Lat,
+			Lng:    lightning.Lng,
+			Radius: 7,
+			Color:  color.RGBA{G: 255, B: 255, A: 255},
+		})
+	}
+	return markers
+}
+
+// CircleLayer 中心からの距離円を描画するレイヤー
+type CircleLayer struct {
+	RadiiKm []float64  // 円の半径（キロメートル）
+	Color   color.RGBA // 線の色
+}
+
+// Draw 距離円を描画する
+// 64個の線分で円を近似し、地球の曲率を考慮した地理的距離円を描画
+func (l *CircleLayer) Draw(_ context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	for _, radiusKm := range l.RadiiKm {
+		points := circlePoints(viewport, radiusKm)
+		for i := range len(points) - 1 {
+			drawLine(&drawLineParams{
+				Img: canvas,
+				X1:  points[i].X,
+				Y1:  points[i].Y,
+				X2:  points[i+1].X,
+				Y2:  points[i+1].Y,
+				Col: l.Color,
+			})
+		}
+	}
+	return nil
+}
+
+// circleSegments 距離円を近似する線分の数
+const circleSegments = 64
+
+// circlePoints 距離円を近似する線分の端点を画像上の座標で返す
+// 始点と終点は同じ点になる
+func circlePoints(viewport *Viewport, radiusKm float64) []image.Point {
+	points := make([]image.Point, 0, circleSegments+1)
+	for _, point := range circleLatLngs(viewport, radiusKm) {
+		points = append(points, viewport.ImagePoint(point.Lat, point.Lng))
+	}
+	return points
+}
+
+// circleLatLngs 距離円を近似する線分の端点を地理座標で返す
+// 始点と終点は同じ点になる
+func circleLatLngs(viewport *Viewport, radiusKm float64) []calcCirclePointResult {
+	points := make([]calcCirclePointResult, 0, circleSegments+1)
+	for i := range circleSegments + 1 {
+		// 円上の点を計算（地球の曲率を考慮）
+		points = append(points, calcCirclePoint(&calcCirclePointParams{
+			Viewport: viewport,
+			RadiusKm: radiusKm,
+			Angle:    float64(i%circleSegments) * 2 * math.Pi / circleSegments,
+		}))
+	}
+	return points
+}
+
+// BannerLayer 画像上部に文言を表示するバナー
+// 埋め込みフォントは英数字のみ対応のため、文言は英語で表記する
+type BannerLayer struct {
+	Text  string
+	Color color.RGBA // 背景色（ゼロ値の場合は注意を促す赤）
+}
+
+// Draw バナーを描画する
+func (l *BannerLayer) Draw(_ context.Context, canvas *image.RGBA, _ *Viewport) error {
+	banner := bannerRect(canvas.Bounds())
+	draw.Draw(canvas, banner, image.NewUniform(l.color()), image.Point{}, draw.Src)
+
+	size := font.MeasureText(l.Text, textScale)
+	font.DrawText(&font.DrawTextParams{
+		Img:   canvas,
+		X:     banner.Min.X + (banner.Dx()-size.X)/2,
+		Y:     banner.Min.Y + (banner.Dy()-size.Y)/2,
+		Text:  l.Text,
+		Color: color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		Scale: textScale,
+	})
+	return nil
+}
+
+// color バナーの背景色を返す
+func (l *BannerLayer) color() color.RGBA {
+	if l.Color == (color.RGBA{}) {
+		return bannerColor
+	}
+	return l.Color
+}
+
+// bannerRect 画像上部のバナーの範囲を返す
+func bannerRect(bounds image.Rectangle) image.Rectangle {
+	return image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, min(bounds.Min.Y+bannerHeight, bounds.Max.Y))
+}
+
+// LabelLayer 画像左下に文言を表示するラベル
+// 埋め込みフォントは英数字のみ対応のため、文言は英数字で表記する
+type LabelLayer struct {
+	Text string
+}
+
+// Draw ラベルを描画する
+func (l *LabelLayer) Draw(_ context.Context, canvas *image.RGBA, _ *Viewport) error {
+	label := labelRect(canvas.Bounds(), l.Text)
+	draw.Draw(canvas, label, image.NewUniform(labelColor), image.Point{}, draw.Over)
+
+	font.DrawText(&font.DrawTextParams{
+		Img:   canvas,
+		X:     label.Min.X + labelPadding,
+		Y:     label.Min.Y + labelPadding,
+		Text:  l.Text,
+		Color: color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		Scale: textScale,
+	})
+	return nil
+}
+
+// labelRect 画像左下のラベルの範囲を返す
+func labelRect(bounds image.Rectangle, text string) image.Rectangle {
+	size := font.MeasureText(text, textScale)
+	return image.Rect(
+		bounds.Min.X,
+		max(bounds.Max.Y-size.Y-2*labelPadding, bounds.Min.Y),
+		min(bounds.Min.X+size.X+2*labelPadding, bounds.Max.X),
+		bounds.Max.Y,
+	)
+}
@@ -0,0 +1,181 @@
+package amesh_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+// failingLayer 常に描画に失敗するレイヤー
+type failingLayer struct{}
+
+func (failingLayer) Draw(context.Context, *image.RGBA, *amesh.Viewport) error {
+	return errors.New("draw failed")
+}
+
+func TestViewportImagePoint(t *testing.T) {
+	viewport := &amesh.Viewport{Lat: 35.6812, Lng: 139.7671, Zoom: 10, AroundTiles: 1}
+
+	tests := []struct {
+		name     string
+		lat      float64
+		lng      float64
+		expected func(p image.Point) bool
+	}{
+		{
+			name:     "中心座標は画像の中心",
+			lat:      35.6812,
+			lng:      139.7671,
+			expected: func(p image.Point) bool { return p == image.Point{X: 384, Y: 384} },
+		},
+		{
+			name:     "東側の座標は中心より右",
+			lat:      35.6812,
+			lng:      139.9,
+			expected: func(p image.Point) bool { return 384 < p.X && p.Y == 384 },
+		},
+		{
+			name:     "北側の座標は中心より上",
+			lat:      35.8,
+			lng:      139.7671,
+			expected: func(p image.Point) bool { return p.X == 384 && p.Y < 384 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if p := viewport.ImagePoint(tt.lat, tt.lng); !tt.expected(p) {
+				t.Errorf("ImagePoint(%f, %f) = %v", tt.lat, tt.lng, p)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageLayers 指定したレイヤーだけを描画することをテストする
+func TestCreateAmeshImageLayers(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	tests := []struct {
+		name          string
+		layers        []amesh.Layer
+		expectedColor color.RGBA
+	}{
+		{
+			name:          "レイヤーなしは白い背景のみ",
+			layers:        []amesh.Layer{},
+			expectedColor: white,
+		},
+		{
+			name: "中心にマーカーを描画",
+			layers: []amesh.Layer{
+				&amesh.MarkerLayer{Markers: []amesh.Marker{{Lat: 35.6812, Lng: 139.7671, Radius: 3, Color: red}}},
+			},
+			expectedColor: red,
+		},
+		{
+			name: "失敗したレイヤーを飛ばして残りを描画",
+			layers: []amesh.Layer{
+				failingLayer{},
+				&amesh.MarkerLayer{Markers: []amesh.Marker{{Lat: 35.6812, Lng: 139.7671, Radius: 3, Color: red}}},
+			},
+			expectedColor: red,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{})
+
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      transport.Client(),
+				Lat:         35.6812,
+				Lng:         139.7671,
+				Zoom:        10,
+				AroundTiles: 1,
+				Layers:      tt.layers,
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+
+			// レイヤーを指定した場合はタイムスタンプやタイルを取得しない
+			if requests := transport.Requests(); len(requests) != 0 {
+				t.Errorf("unexpected requests: %d", len(requests))
+			}
+			if got := result.Image.RGBAAt(384, 384); got != tt.expectedColor {
+				t.Errorf("center color = %v, want %v", got, tt.expectedColor)
+			}
+		})
+	}
+}
+
+// TestRenderLayersPartialTiles 一部のタイルが取得できなくても残りを描画し、取得結果を集計することをテストする
+func TestRenderLayersPartialTiles(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	tests := []struct {
+		name          string
+		failPattern   string // 取得に失敗させるタイルのURL
+		expectedStats amesh.TileStats
+		expectedColor color.RGBA
+	}{
+		{
+			name:          "すべてのタイルを取得",
+			failPattern:   "no-such-tile",
+			expectedStats: amesh.TileStats{Fetched: 18},
+			expectedColor: white,
+		},
+		{
+			name:          "中心のレーダータイルが失敗すると斜線を描画",
+			failPattern:   "/hrpns/10/909/403.png",
+			expectedStats: amesh.TileStats{Fetched: 17, Failed: 1, NoData: 1},
+			expectedColor: gray,
+		},
+		{
+			name:          "中心のベースマップが失敗してもレーダーは描画",
+			failPattern:   "tile.openstreetmap.org/10/909/403.png",
+			expectedStats: amesh.TileStats{Fetched: 17, Failed: 1},
+			expectedColor: white,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: tt.failPattern, Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			result := amesh.RenderLayers(t.Context(), &amesh.Viewport{Lat: 35.6812, Lng: 139.7671, Zoom: 10, AroundTiles: 1}, []amesh.Layer{
+				&amesh.BaseMapLayer{Client: transport.Client()},
+				&amesh.RadarLayer{Client: transport.Client(), Timestamp: "20240101120000"},
+			})
+
+			if result.Tiles != tt.expectedStats {
+				t.Errorf("Tiles = %+v, want %+v", result.Tiles, tt.expectedStats)
+			}
+			// 斜線は(x+y)が16の倍数の位置から描画される
+			if got := result.Image.RGBAAt(384, 384); got != tt.expectedColor {
+				t.Errorf("center color = %v, want %v", got, tt.expectedColor)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package amesh
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/font"
+	"hato-bot-go/lib/i18n"
+)
+
+// 地図画像の定数
+const (
+	mapMarkerRadius       = 10 // 地点のマーカーの半径（ピクセル）
+	mapMarkerBorderRadius = 13 // 地点のマーカーの縁取りの半径（ピクセル）
+	scaleBarThickness     = 4  // 縮尺の線の太さ（ピクセル）
+	scaleBarTextScale     = 2  // 縮尺の文字の倍率
+)
+
+// 地図画像の色
+var (
+	mapMarkerColor       = color.RGBA{R: 230, A: 255}
+	mapMarkerBorderColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	scaleBarColor        = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// ParseMapCommandResult mapコマンドの解析結果
+type ParseMapCommandResult struct {
+	Place string
+	IsMap bool
+}
+
+// ParseMapCommand mapコマンドを解析
+// 解析の前にlib.NormalizeMessageで表記ゆれとメンション・MFMの装飾を取り除く
+func ParseMapCommand(text string) ParseMapCommandResult {
+	parsed := lib.ParseCommand(lib.NormalizeMessage(text), "map")
+	if !parsed.Matched {
+		return ParseMapCommandResult{
+			Place: "",
+			IsMap: false,
+		}
+	}
+
+	place := parsed.Args
+	if place == "" {
+		place = "東京" // デフォルトの場所
+	}
+	return ParseMapCommandResult{
+		Place: place,
+		IsMap: true,
+	}
+}
+
+// MapCommandErrorKey mapコマンド処理のエラーに応じた返信メッセージのキーを返す
+// 外部サービスの障害はameshコマンドと同じメッセージにする
+func MapCommandErrorKey(err error) i18n.Key {
+	if key := CommandErrorKey(err); key != i18n.KeyErrorCommand {
+		return key
+	}
+	return i18n.KeyErrorMapCommand
+}
+
+// MapLayers 雨雲レーダーを重ねない地図画像のレイヤー構成を作成する
+// ベースマップ・地点のマーカー・縮尺の順に重ね、気象庁のデータは取得しない
+func MapLayers(params *CreateAmeshImageParams) []Layer {
+	return []Layer{
+		&BaseMapLayer{Client: params.Client, Dark: params.darkMap()},
+		&MarkerLayer{Markers: []Marker{
+			{Lat: params.Lat, Lng: params.Lng, Radius: mapMarkerBorderRadius, Color: mapMarkerBorderColor},
+			{Lat: params.Lat, Lng: params.Lng, Radius: mapMarkerRadius, Color: mapMarkerColor},
+		}},
+		&ScaleBarLayer{},
+	}
+}
+
+// CreateMapImageReader 既定のHTTPクライアントでCreateMapImageReaderWithClientを呼び出す
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateMapImageReader(ctx context.Context, location *Location) (*ImageReader, error) {
+	return CreateMapImageReaderWithClient(ctx, &LocationImageParams{
+		Client:   defaultClient,
+		Location: location,
+		MapStyle: getMapStyle(),
+	})
+}
+
+// CreateMapImageReaderWithClient HTTPクライアントを指定して地点のマーカーと縮尺だけを描画した地図画像を作成し、PNG形式にエンコードしながら読み出すImageReaderを返す
+// ズームレベルはameshコマンドと同じく地名の範囲に合わせて選び、OverlaysとTimestampCacheは使わない
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateMapImageReaderWithClient(ctx context.Context, params *LocationImageParams) (*ImageReader, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+	imageParams := locationImageParams(params)
+	imageParams.Layers = MapLayers(imageParams)
+	result, err := CreateAmeshImage(ctx, imageParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to CreateAmeshImage")
+	}
+	result.Locations = []Location{*params.Location}
+	logTileStats(ctx, result.Tiles)
+	return result.Reader(), nil
+}
+
+// ScaleBarLayer 画像右下に距離の目安の縮尺を表示するレイヤー
+// 画像の中心の緯度での縮尺で、画像の幅の1/4以下のきりのよい距離（1・2・5×10のべき乗）の長さの線を描画する
+type ScaleBarLayer struct{}
+
+// Draw 縮尺を描画する
+func (l *ScaleBarLayer) Draw(_ context.Context, canvas *image.RGBA, viewport *Viewport) error {
+	kmPerPixel := scaleKmPerPixel(viewport)
+	distanceKm := roundScaleDistance(float64(viewport.Size()/4) * kmPerPixel)
+	length := int(math.Round(distanceKm / kmPerPixel))
+	text := formatScaleDistance(distanceKm)
+
+	// 文言の下に線を引き、ラベルと同じ半透明の背景で囲む
+	textSize := font.MeasureText(text, scaleBarTextScale)
+	bounds := canvas.Bounds()
+	box := image.Rect(
+		bounds.Max.X-max(length, textSize.X)-2*labelPadding,
+		bounds.Max.Y-textSize.Y-scaleBarThickness-3*labelPadding,
+		bounds.Max.X,
+		bounds.Max.Y,
+	)
+	draw.Draw(canvas, box, image.NewUniform(labelColor), image.Point{}, draw.Over)
+
+	font.DrawText(&font.DrawTextParams{
+		Img:   canvas,
+		X:     box.Max.X - labelPadding - textSize.X,
+		Y:     box.Min.Y + labelPadding,
+		Text:  text,
+		Color: scaleBarColor,
+		Scale: scaleBarTextScale,
+	})
+	bar := image.Rect(box.Max.X-labelPadding-length, box.Max.Y-labelPadding-scaleBarThickness, box.Max.X-labelPadding, box.Max.Y-labelPadding)
+	draw.Draw(canvas, bar, image.NewUniform(scaleBarColor), image.Point{}, draw.Src)
+	return nil
+}
+
+// scaleKmPerPixel 描画範囲の中心の緯度での1ピクセルあたりの距離（キロメートル）を返す
+// Webメルカトル図法では緯度が高いほど1ピクセルあたりの距離が短くなる
+func scaleKmPerPixel(viewport *Viewport) float64 {
+	earthRadius := 6371.0 // 地球半径（キロメートル）
+	return 2 * math.Pi * earthRadius * math.Cos(deg2rad(viewport.Lat)) / (256 * math.Exp2(float64(viewport.Zoom)))
+}
+
+// roundScaleDistance 距離以下で最も長いきりのよい距離（1・2・5×10のべき乗キロメートル）を返す
+func roundScaleDistance(km float64) float64 {
+	base := math.Pow(10, math.Floor(math.Log10(km)))
+	for _, step := range []float64{5, 2} {
+		if step*base <= km {
+			return step * base
+		}
+	}
+	return base
+}
+
+// formatScaleDistance 縮尺の距離を表示する文言を返す（例: 20 km、500 m）
+// 埋め込みフォントは英小文字を大文字で描画する
+func formatScaleDistance(km float64) string {
+	if km < 1 {
+		return fmt.Sprintf("%g m", math.Round(km*1000))
+	}
+	return fmt.Sprintf("%g km", km)
+}
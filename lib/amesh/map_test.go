@@ -0,0 +1,136 @@
+package amesh_test
+
+import (
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestParseMapCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected amesh.ParseMapCommandResult
+	}{
+		{
+			name:     "シンプルなmapコマンド",
+			input:    "map 大阪",
+			expected: amesh.ParseMapCommandResult{Place: "大阪", IsMap: true},
+		},
+		{
+			name:     "場所無しのmapコマンドは東京がデフォルト",
+			input:    "@bot map",
+			expected: amesh.ParseMapCommandResult{Place: "東京", IsMap: true},
+		},
+		{
+			name:     "全角英字のmapコマンド",
+			input:    "ｍａｐ　新宿 駅",
+			expected: amesh.ParseMapCommandResult{Place: "新宿 駅", IsMap: true},
+		},
+		{
+			name:     "ameshコマンド",
+			input:    "amesh 東京",
+			expected: amesh.ParseMapCommandResult{Place: "", IsMap: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, amesh.ParseMapCommand(tt.input)); diff != "" {
+				t.Errorf("ParseMapCommand(%q) mismatch (-want +got):\n%s", tt.input, diff)
+			}
+		})
+	}
+}
+
+func TestMapCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{
+			name:     "通常のエラー",
+			err:      errors.New("something wrong"),
+			expected: i18n.KeyErrorMapCommand,
+		},
+		{
+			name:     "サーキットブレーカーが開いている",
+			err:      errors.Wrap(httpclient.ErrCircuitOpen, "Failed to Do"),
+			expected: i18n.KeyErrorUpstreamUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := amesh.MapCommandErrorKey(tt.err); got != tt.expected {
+				t.Errorf("MapCommandErrorKey() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCreateMapImageReaderWithClient 気象庁のデータを取得せず、ベースマップにマーカーと縮尺を描画することをテストする
+func TestCreateMapImageReaderWithClient(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+
+	imageReader, err := amesh.CreateMapImageReaderWithClient(t.Context(), &amesh.LocationImageParams{
+		Client:   tiles.Client(),
+		Location: &amesh.Location{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"},
+		Zoom:     10,
+	})
+	if err != nil {
+		t.Fatalf("CreateMapImageReaderWithClient() error = %v", err)
+	}
+	defer func() { _ = imageReader.Close() }()
+	img, err := png.Decode(imageReader)
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+
+	if got := tiles.RequestsTo("jma.go.jp"); len(got) != 0 {
+		t.Errorf("JMA requests = %v, want none", got)
+	}
+	if diff := cmp.Diff([]string{amesh.ProviderOpenStreetMap}, imageReader.Providers); diff != "" {
+		t.Errorf("Providers mismatch (-want +got):\n%s", diff)
+	}
+	if !imageReader.RadarTime.IsZero() {
+		t.Errorf("RadarTime = %v, want zero", imageReader.RadarTime)
+	}
+
+	// ズームレベル10の東京では1ピクセルが約0.124kmのため、縮尺は20km（約161ピクセル）になる
+	size := img.Bounds().Dx()
+	pixels := []struct {
+		name     string
+		x, y     int
+		expected color.Color
+	}{
+		{name: "中心のマーカー", x: size / 2, y: size / 2, expected: color.RGBA{R: 230, A: 255}},
+		{name: "マーカーの縁取り", x: size/2 + 12, y: size / 2, expected: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{name: "縮尺の線", x: size - 9 - 150, y: size - 10, expected: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{name: "縮尺の外のベースマップ", x: size - 9 - 180, y: size - 10, expected: color.RGBA{R: 242, G: 239, B: 233, A: 255}},
+	}
+	for _, p := range pixels {
+		if diff := cmp.Diff(p.expected, color.RGBAModel.Convert(img.At(p.x, p.y))); diff != "" {
+			t.Errorf("%s (%d, %d) mismatch (-want +got):\n%s", p.name, p.x, p.y, diff)
+		}
+	}
+}
+
+func TestCreateMapImageReaderWithClientParamsNil(t *testing.T) {
+	if _, err := amesh.CreateMapImageReaderWithClient(t.Context(), &amesh.LocationImageParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("CreateMapImageReaderWithClient() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
@@ -0,0 +1,149 @@
+package amesh
+
+import (
+	"image"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+)
+
+// ErrUnknownMapStyle 存在しないベースマップのスタイルが指定されたことを表すエラー
+var ErrUnknownMapStyle = errors.New("unknown map style")
+
+// MapStyle ベースマップのスタイル
+type MapStyle string
+
+const (
+	MapStyleLight MapStyle = "light" // OpenStreetMapのタイルをそのまま描画する
+	MapStyleDark  MapStyle = "dark"  // OpenStreetMapのタイルの明るさを反転し、暗い背景に明るい地名や道路で描画する
+	MapStyleAuto  MapStyle = "auto"  // 地点の日の入りから日の出まではdark、それ以外はlightで描画する
+)
+
+// mapStyles 指定できるベースマップのスタイルの一覧
+var mapStyles = []MapStyle{MapStyleLight, MapStyleDark, MapStyleAuto}
+
+// ParseMapStyle ベースマップのスタイルの名前を解析する
+// 空文字列の場合はMapStyleLightを返す
+func ParseMapStyle(s string) (MapStyle, error) {
+	if strings.TrimSpace(s) == "" {
+		return MapStyleLight, nil
+	}
+	for _, style := range mapStyles {
+		if strings.EqualFold(strings.TrimSpace(s), string(style)) {
+			return style, nil
+		}
+	}
+	return "", errors.Wrapf(ErrUnknownMapStyle, "map style: %s", s)
+}
+
+// mapStyle クライアント未指定の関数で使うベースマップのスタイル（SetMapStyleで設定する）
+var mapStyle atomic.Value
+
+// SetMapStyle CreateImageReaderなどクライアント未指定の関数で使うベースマップのスタイルを設定する
+func SetMapStyle(style MapStyle) {
+	mapStyle.Store(style)
+}
+
+// getMapStyle SetMapStyleで設定したスタイルを返す（未設定の場合は空文字列でMapStyleLightとして扱う）
+func getMapStyle() MapStyle {
+	style, _ := mapStyle.Load().(MapStyle)
+	return style
+}
+
+// darkMap ベースマップを暗いスタイルで描画するか
+// MapStyleAutoの場合は時計の現在時刻に地点が夜かで決める
+func (params *CreateAmeshImageParams) darkMap() bool {
+	switch params.MapStyle {
+	case MapStyleDark:
+		return true
+	case MapStyleAuto:
+		return IsNight(clock.Or(params.Clock).Now(), params.Lat, params.Lng)
+	default:
+		return false
+	}
+}
+
+// darkenImage 範囲の色の明るさを反転して暗いスタイルにする
+// 色相と彩度は保つため、水域や公園の色を見分けられ、暗い地名の文字は明るい文字として読める
+func darkenImage(canvas *image.RGBA, rect image.Rectangle) {
+	rect = rect.Intersect(canvas.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := canvas.PixOffset(x, y)
+			pix := canvas.Pix[i : i+3 : i+3]
+			r, g, b := int(pix[0]), int(pix[1]), int(pix[2])
+			luma := (299*r + 587*g + 114*b) / 1000
+			// 反転後の明るさは眩しくならないよう最大でも約7割に抑える
+			shift := (255-luma)*7/10 - luma
+			pix[0] = uint8(min(max(r+shift, 0), 255))
+			pix[1] = uint8(min(max(g+shift, 0), 255))
+			pix[2] = uint8(min(max(b+shift, 0), 255))
+		}
+	}
+}
+
+// IsNight 時刻tに地点が日の入りから日の出までの間か
+// 日の出・日の入りはNOAAの簡易式で求め、白夜の場合はfalse、極夜の場合はtrueを返す
+func IsNight(t time.Time, lat, lng float64) bool {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	// 経度によって日の出・日の入りがUTCの日付をまたぐため、前後の日も確認する
+	for _, days := range []int{-1, 0, 1} {
+		date := midnight.AddDate(0, 0, days)
+		sunrise, sunset, polar := sunriseSunset(date, lat, lng)
+		switch polar {
+		case polarDay:
+			return false
+		case polarNight:
+			return true
+		}
+		if !t.Before(sunrise) && t.Before(sunset) {
+			return false
+		}
+	}
+	return true
+}
+
+// polarState 白夜・極夜の状態
+type polarState int
+
+const (
+	polarNone  polarState = iota // 日の出と日の入りがある
+	polarDay                     // 白夜（一日中日が沈まない）
+	polarNight                   // 極夜（一日中日が昇らない）
+)
+
+// sunriseSunset UTCの日付dateの地点の日の出と日の入りの時刻を返す
+// 白夜・極夜の場合は時刻の代わりにpolarDay・polarNightを返す
+func sunriseSunset(date time.Time, lat, lng float64) (time.Time, time.Time, polarState) {
+	// 太陽の位置を求める年の中の角度（ラジアン、正午の値で近似する）
+	gamma := 2 * math.Pi / 365 * float64(date.YearDay()-1)
+	// 均時差（分）
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(gamma) - 0.032077*math.Sin(gamma) -
+		0.014615*math.Cos(2*gamma) - 0.040849*math.Sin(2*gamma))
+	// 太陽の赤緯（ラジアン）
+	decl := 0.006918 - 0.399912*math.Cos(gamma) + 0.070257*math.Sin(gamma) -
+		0.006758*math.Cos(2*gamma) + 0.000907*math.Sin(2*gamma) -
+		0.002697*math.Cos(3*gamma) + 0.00148*math.Sin(3*gamma)
+
+	// 大気差と太陽の視半径を考慮した天頂角90.833度での時角
+	latRad := lat * math.Pi / 180
+	cosHourAngle := math.Cos(90.833*math.Pi/180)/(math.Cos(latRad)*math.Cos(decl)) - math.Tan(latRad)*math.Tan(decl)
+	switch {
+	case cosHourAngle < -1:
+		return time.Time{}, time.Time{}, polarDay
+	case 1 < cosHourAngle:
+		return time.Time{}, time.Time{}, polarNight
+	}
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+
+	minutes := func(m float64) time.Duration { return time.Duration(m * float64(time.Minute)) }
+	sunrise := date.Add(minutes(720 - 4*(lng+hourAngle) - eqTime))
+	sunset := date.Add(minutes(720 - 4*(lng-hourAngle) - eqTime))
+	return sunrise, sunset, polarNone
+}
@@ -0,0 +1,112 @@
+package amesh_test
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/clock/clocktest"
+)
+
+func TestParseMapStyle(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      amesh.MapStyle
+		expectedError error
+	}{
+		{name: "空の場合はlight", input: "", expected: amesh.MapStyleLight},
+		{name: "dark", input: "dark", expected: amesh.MapStyleDark},
+		{name: "大文字", input: "AUTO", expected: amesh.MapStyleAuto},
+		{name: "存在しないスタイル", input: "sepia", expectedError: amesh.ErrUnknownMapStyle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := amesh.ParseMapStyle(tt.input)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseMapStyle(%q) error = %v, want %v", tt.input, err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseMapStyle(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsNight(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	tests := []struct {
+		name     string
+		time     time.Time
+		lat, lng float64
+		expected bool
+	}{
+		{name: "東京の冬の正午", time: time.Date(2024, 1, 1, 12, 0, 0, 0, jst), lat: 35.68, lng: 139.77, expected: false},
+		{name: "東京の冬の17時（日の入り後）", time: time.Date(2024, 1, 1, 17, 0, 0, 0, jst), lat: 35.68, lng: 139.77, expected: true},
+		{name: "東京の冬の6時（日の出前）", time: time.Date(2024, 1, 1, 6, 0, 0, 0, jst), lat: 35.68, lng: 139.77, expected: true},
+		{name: "東京の夏の18時（日の入り前）", time: time.Date(2024, 6, 21, 18, 0, 0, 0, jst), lat: 35.68, lng: 139.77, expected: false},
+		{name: "東京の夏の5時（日の出後）", time: time.Date(2024, 6, 21, 5, 0, 0, 0, jst), lat: 35.68, lng: 139.77, expected: false},
+		{name: "那覇の冬の17時半（日の入り前）", time: time.Date(2024, 1, 1, 17, 30, 0, 0, jst), lat: 26.21, lng: 127.68, expected: false},
+		{name: "北極圏の冬の正午（極夜）", time: time.Date(2024, 12, 21, 11, 0, 0, 0, time.UTC), lat: 78.22, lng: 15.65, expected: true},
+		{name: "北極圏の夏の深夜（白夜）", time: time.Date(2024, 6, 21, 23, 0, 0, 0, time.UTC), lat: 78.22, lng: 15.65, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := amesh.IsNight(tt.time, tt.lat, tt.lng); got != tt.expected {
+				t.Errorf("IsNight(%v, %v, %v) = %v, want %v", tt.time, tt.lat, tt.lng, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageMapStyle ベースマップのスタイルに合わせて描画することをテストする
+// ameshtestのベースマップは(242,239,233)の一様な色のタイルで、暗いスタイルでは明るさを反転する
+func TestCreateAmeshImageMapStyle(t *testing.T) {
+	light := color.RGBA{R: 242, G: 239, B: 233, A: 255}
+	dark := color.RGBA{R: 14, G: 11, B: 5, A: 255}
+	tests := []struct {
+		name     string
+		style    amesh.MapStyle
+		now      time.Time
+		expected color.RGBA
+	}{
+		{name: "指定なし", style: "", expected: light},
+		{name: "light", style: amesh.MapStyleLight, expected: light},
+		{name: "dark", style: amesh.MapStyleDark, expected: dark},
+		{name: "autoで東京の21時", style: amesh.MapStyleAuto, now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), expected: dark},
+		{name: "autoで東京の12時", style: amesh.MapStyleAuto, now: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), expected: light},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, nil)
+
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      server.Client(),
+				Lat:         35.6895,
+				Lng:         139.6917,
+				Zoom:        10,
+				AroundTiles: 1,
+				// 透明なタイルを重ね、ベースマップの色を確認する
+				Overlays: []amesh.OverlayName{amesh.OverlayFlood},
+				MapStyle: tt.style,
+				Clock:    clocktest.NewFake(tt.now),
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+			if got := result.Image.RGBAAt(700, 100); got != tt.expected {
+				t.Errorf("base map pixel = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package amesh
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/requestid"
+)
+
+// ErrUnknownOverlay 存在しないオーバーレイレイヤーが指定されたことを表すエラー
+var ErrUnknownOverlay = errors.New("unknown overlay layer")
+
+// tileSource 気象庁のタイムスタンプ付きタイルの取得元
+type tileSource struct {
+	TargetTimesURL string // タイムスタンプ一覧のURL
+	Element        string // targetTimesのelementsに含まれる要素名
+}
+
+// floodSource 気象庁キキクル（危険度分布）の洪水のタイル
+var floodSource = &tileSource{
+	TargetTimesURL: "https://www.jma.go.jp/bosai/jmatile/data/risk/targetTimes.json",
+	Element:        "flood",
+}
+
+// snowSource 気象庁の現在の積雪の深さ（解析積雪深）のタイル
+var snowSource = &tileSource{
+	TargetTimesURL: "https://www.jma.go.jp/bosai/jmatile/data/snow/targetTimes.json",
+	Element:        "snowd",
+}
+
+// OverlayName ベースマップに重ねるレイヤーの名前
+type OverlayName string
+
+const (
+	OverlayRadar OverlayName = "radar" // 雨雲レーダー（ナウキャスト）
+	OverlayFlood OverlayName = "flood" // 洪水キキクル（洪水警報の危険度分布）
+	OverlaySnow  OverlayName = "snow"  // 現在の積雪の深さ（解析積雪深）
+)
+
+// overlayNames 指定できるオーバーレイレイヤーの一覧
+var overlayNames = []OverlayName{OverlayRadar, OverlayFlood, OverlaySnow}
+
+// DefaultOverlays オーバーレイレイヤーが指定されていない場合に重ねるレイヤー
+var DefaultOverlays = []OverlayName{OverlayRadar}
+
+// overlays 重ねるレイヤー名を返す（指定されていない場合はDefaultOverlays）
+func (params *CreateAmeshImageParams) overlays() []OverlayName {
+	if params.Overlays == nil {
+		return DefaultOverlays
+	}
+	return params.Overlays
+}
+
+// ParseOverlays カンマ区切りのレイヤー名（radar,floodなど）を解析する
+// 空文字列の場合はDefaultOverlaysを返す
+func ParseOverlays(s string) ([]OverlayName, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultOverlays, nil
+	}
+
+	var overlays []OverlayName
+	for name := range strings.SplitSeq(s, ",") {
+		overlay := OverlayName(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(overlayNames, overlay) {
+			return nil, errors.Wrapf(ErrUnknownOverlay, "layer: %s", name)
+		}
+		if !slices.Contains(overlays, overlay) {
+			overlays = append(overlays, overlay)
+		}
+	}
+	return overlays, nil
+}
+
+// getLatestSourceTimestamp タイルの取得元の最新の基準時刻を取得する
+// 予報ではなく解析値（基準時刻と対象時刻が同じもの）のみを対象とする
+func getLatestSourceTimestamp(ctx context.Context, params *CreateAmeshImageParams, source *tileSource) (string, error) {
+	timeData, err := fetchTimeData(ctx, params.timestampsParams(), source.TargetTimesURL)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to fetchTimeData")
+	}
+
+	timestamps := &LatestTimestampsResult{Elements: make(map[string]ElementTimestamps)}
+	timestamps.add(timeData)
+	return formatJMATimestamp(timestamps.BaseTime(source.Element)), nil
+}
+
+// newOverlayLayers 指定されたレイヤー名からベースマップに重ねるレイヤーを作成する
+// タイムスタンプが取得できないレイヤーは描画しない
+func newOverlayLayers(ctx context.Context, params *CreateAmeshImageParams, radarTimestamp string) []Layer {
+	var layers []Layer
+	for _, overlay := range params.overlays() {
+		style := params.OverlayStyles[overlay]
+		switch overlay {
+		case OverlayRadar:
+			if radarTimestamp != "" {
+				layers = append(layers, &RadarLayer{Client: params.Client, Timestamp: radarTimestamp, Alpha: style.Alpha, Blend: style.Blend})
+			}
+		case OverlayFlood:
+			timestamp, err := getLatestSourceTimestamp(ctx, params, floodSource)
+			if err != nil || timestamp == "" {
+				requestid.Logf(ctx, "Skipping flood overlay: timestamp unavailable: %v", err)
+				continue
+			}
+			layers = append(layers, &FloodLayer{Client: params.Client, Timestamp: timestamp, Alpha: style.Alpha, Blend: style.Blend})
+		case OverlaySnow:
+			timestamp, err := getLatestSourceTimestamp(ctx, params, snowSource)
+			if err != nil || timestamp == "" {
+				requestid.Logf(ctx, "Skipping snow overlay: timestamp unavailable: %v", err)
+				continue
+			}
+			layers = append(layers, &SnowLayer{Client: params.Client, Timestamp: timestamp, Alpha: style.Alpha, Blend: style.Blend})
+		}
+	}
+	return layers
+}
@@ -0,0 +1,155 @@
+package amesh_test
+
+import (
+	"image/color"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestParseOverlays(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []amesh.OverlayName
+		expectError error
+	}{
+		{
+			name:     "未指定はデフォルト",
+			input:    "",
+			expected: amesh.DefaultOverlays,
+		},
+		{
+			name:     "洪水キキクルのみ",
+			input:    "flood",
+			expected: []amesh.OverlayName{amesh.OverlayFlood},
+		},
+		{
+			name:     "複数指定と重複・大文字",
+			input:    "radar, FLOOD,radar",
+			expected: []amesh.OverlayName{amesh.OverlayRadar, amesh.OverlayFlood},
+		},
+		{
+			name:     "積雪の深さ",
+			input:    "snow",
+			expected: []amesh.OverlayName{amesh.OverlaySnow},
+		},
+		{
+			name:        "存在しないレイヤー",
+			input:       "radar,typhoon",
+			expectError: amesh.ErrUnknownOverlay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := amesh.ParseOverlays(tt.input)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ParseOverlays() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseOverlays(%q) diff: %s", tt.input, diff)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageOverlays 指定したレイヤーのタイルだけを取得することをテストする
+func TestCreateAmeshImageOverlays(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		overlays         []amesh.OverlayName
+		riskTargetTimes  string
+		expectRadarTiles bool
+		expectFloodTiles bool
+		expectSnowTiles  bool
+	}{
+		{
+			name:             "デフォルトはレーダーのみ",
+			overlays:         nil,
+			riskTargetTimes:  `[{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["flood"]}]`,
+			expectRadarTiles: true,
+			expectFloodTiles: false,
+		},
+		{
+			name:             "洪水キキクルのみ",
+			overlays:         []amesh.OverlayName{amesh.OverlayFlood},
+			riskTargetTimes:  `[{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["flood", "land"]}]`,
+			expectRadarTiles: false,
+			expectFloodTiles: true,
+		},
+		{
+			name:             "レーダーと洪水キキクル",
+			overlays:         []amesh.OverlayName{amesh.OverlayRadar, amesh.OverlayFlood},
+			riskTargetTimes:  `[{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["flood"]}]`,
+			expectRadarTiles: true,
+			expectFloodTiles: true,
+		},
+		{
+			name:             "洪水キキクルのタイムスタンプがなければ重ねない",
+			overlays:         []amesh.OverlayName{amesh.OverlayFlood},
+			riskTargetTimes:  `[{"basetime": "20240101120000", "validtime": "20240101123000", "elements": ["flood"]}]`,
+			expectRadarTiles: false,
+			expectFloodTiles: false,
+		},
+		{
+			name:             "積雪の深さのみ",
+			overlays:         []amesh.OverlayName{amesh.OverlaySnow},
+			riskTargetTimes:  `[{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["flood"]}]`,
+			expectRadarTiles: false,
+			expectFloodTiles: false,
+			expectSnowTiles:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "risk/targetTimes.json", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: tt.riskTargetTimes}}},
+					{Pattern: "snow/targetTimes.json", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+						{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["snowd", "snowf01h"]},
+						{"basetime": "20240101110000", "validtime": "20240101110000", "elements": ["snowd"]}
+					]`}}},
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+						{"basetime": "20240101120500", "validtime": "20240101120500", "elements": ["hrpns_nd"]}
+					]`}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			if _, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      transport.Client(),
+				Lat:         35.6895,
+				Lng:         139.6917,
+				Zoom:        10,
+				AroundTiles: 1,
+				Overlays:    tt.overlays,
+			}); err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+
+			if got := 0 < len(transport.RequestsTo("/nowc/20240101120500/none/20240101120500/surf/hrpns/10/")); got != tt.expectRadarTiles {
+				t.Errorf("radar tiles requested = %v, want %v", got, tt.expectRadarTiles)
+			}
+			if got := 0 < len(transport.RequestsTo("/risk/20240101120000/none/20240101120000/surf/flood/10/")); got != tt.expectFloodTiles {
+				t.Errorf("flood tiles requested = %v, want %v", got, tt.expectFloodTiles)
+			}
+			if got := 0 < len(transport.RequestsTo("/snow/20240101120000/none/20240101120000/surf/snowd/10/")); got != tt.expectSnowTiles {
+				t.Errorf("snow tiles requested = %v, want %v", got, tt.expectSnowTiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package amesh
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+// 画像に埋め込むテキストのキーワード
+const (
+	PNGTextTitle       = "Title"       // 地名（複数の場合は「 / 」区切り）
+	PNGTextLocation    = "Location"    // 地点の座標（「緯度,経度」を「 / 」区切り）
+	PNGTextRadarTime   = "Radar Time"  // 雨雲レーダーのbasetime（RFC 3339形式のUTC）
+	PNGTextSoftware    = "Software"    // 画像を作成したソフトウェアとバージョン
+	PNGTextAttribution = "Attribution" // データの提供元（「, 」区切り）
+)
+
+// pngSignature PNGファイルの先頭のシグネチャ
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngIHDRLength IHDRチャンク全体の長さ（長さ・種類・データ13バイト・CRC）
+const pngIHDRLength = 4 + 4 + 13 + 4
+
+// maxPNGTextChunkLength 読み込むテキストチャンクの最大の長さ
+// 壊れたファイルや悪意のあるファイルで大きなメモリを確保しないようにする
+const maxPNGTextChunkLength = 1 << 20
+
+// ErrInvalidPNG PNGファイルとして解析できないことを表すエラー
+var ErrInvalidPNG = errors.New("invalid PNG")
+
+// PNGText PNGのテキストチャンクに埋め込むキーワードと値
+type PNGText struct {
+	Keyword string // キーワード（1〜79バイトのASCII）
+	Text    string // 値
+}
+
+// PNGText 画像の作成に使ったデータの情報をPNGのテキストチャンクに埋め込む形式で返す
+// 値が空の項目は含めない
+func (m *AmeshMetadata) PNGText() []PNGText {
+	names := make([]string, 0, len(m.Locations))
+	coordinates := make([]string, 0, len(m.Locations))
+	for _, location := range m.Locations {
+		if location.PlaceName != "" {
+			names = append(names, location.PlaceName)
+		}
+		coordinates = append(coordinates, fmt.Sprintf("%.6f,%.6f", location.Lat, location.Lng))
+	}
+
+	texts := []PNGText{
+		{Keyword: PNGTextTitle, Text: strings.Join(names, " / ")},
+		{Keyword: PNGTextLocation, Text: strings.Join(coordinates, " / ")},
+	}
+	if !m.RadarTime.IsZero() {
+		texts = append(texts, PNGText{Keyword: PNGTextRadarTime, Text: m.RadarTime.UTC().Format(time.RFC3339)})
+	}
+	texts = append(texts,
+		PNGText{Keyword: PNGTextSoftware, Text: httpclient.AppName + "/" + lib.BuildVersion()},
+		PNGText{Keyword: PNGTextAttribution, Text: strings.Join(m.Providers, ", ")},
+	)
+
+	result := texts[:0]
+	for _, text := range texts {
+		if text.Text != "" {
+			result = append(result, text)
+		}
+	}
+	return result
+}
+
+// pngTextWriter IHDRチャンクの直後にテキストチャンクを挿入しながら書き込むio.Writer
+// image/pngのエンコーダはシグネチャとIHDRを先頭に書き込むため、その長さを数えて挿入する
+type pngTextWriter struct {
+	w       io.Writer
+	chunks  []byte // 挿入するテキストチャンク（挿入後はnil）
+	written int    // 挿入前に書き込んだバイト数
+}
+
+// newPNGTextWriter テキストチャンクを挿入するio.Writerを返す
+// 埋め込むテキストがない場合はwをそのまま返す
+func newPNGTextWriter(w io.Writer, texts []PNGText) io.Writer {
+	if len(texts) == 0 {
+		return w
+	}
+	chunks := &bytes.Buffer{}
+	for _, text := range texts {
+		writePNGTextChunk(chunks, text)
+	}
+	return &pngTextWriter{w: w, chunks: chunks.Bytes()}
+}
+
+// Write IHDRチャンクまで書き込んだ時点でテキストチャンクを挿入する
+func (w *pngTextWriter) Write(p []byte) (int, error) {
+	if w.chunks == nil {
+		n, err := w.w.Write(p)
+		return n, errors.Wrap(err, "Failed to Write")
+	}
+
+	head := min(len(pngSignature)+pngIHDRLength-w.written, len(p))
+	n, err := w.w.Write(p[:head])
+	w.written += n
+	if err != nil {
+		return n, errors.Wrap(err, "Failed to Write")
+	}
+	if w.written < len(pngSignature)+pngIHDRLength {
+		return n, nil
+	}
+
+	if _, err := w.w.Write(w.chunks); err != nil {
+		return n, errors.Wrap(err, "Failed to Write")
+	}
+	w.chunks = nil
+
+	rest, err := w.w.Write(p[head:])
+	return n + rest, errors.Wrap(err, "Failed to Write")
+}
+
+// writePNGTextChunk テキストチャンクを書き込む
+// Latin-1で表せる値はtEXtチャンク、地名などLatin-1で表せない値はUTF-8のiTXtチャンクにする
+func writePNGTextChunk(buf *bytes.Buffer, text PNGText) {
+	data := &bytes.Buffer{}
+	data.WriteString(text.Keyword)
+	data.WriteByte(0)
+
+	chunkType := "tEXt"
+	if latin1, ok := toLatin1(text.Text); ok {
+		data.Write(latin1)
+	} else {
+		// 圧縮フラグ・圧縮方式・言語タグ（空）・翻訳したキーワード（空）の後にUTF-8の値を続ける
+		chunkType = "iTXt"
+		data.Write([]byte{0, 0, 0, 0})
+		data.WriteString(text.Text)
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(data.Len()))
+	buf.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	_, _ = io.WriteString(crc, chunkType)
+	_, _ = crc.Write(data.Bytes())
+	buf.WriteString(chunkType)
+	buf.Write(data.Bytes())
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
+
+// toLatin1 文字列をLatin-1に変換する
+// Latin-1で表せない文字を含む場合はfalseを返す
+func toLatin1(s string) ([]byte, bool) {
+	result := make([]byte, 0, len(s))
+	for _, r := range s {
+		if 0xff < r {
+			return nil, false
+		}
+		result = append(result, byte(r))
+	}
+	return result, true
+}
+
+// fromLatin1 Latin-1のバイト列をUTF-8の文字列に変換する
+func fromLatin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// ReadPNGText PNGファイルに埋め込まれたテキストチャンク（tEXt・iTXt・zTXt）を出現順に返す
+// 画像データは読み飛ばし、IENDチャンクまで読み込む
+func ReadPNGText(r io.Reader) ([]PNGText, error) {
+	br := bufio.NewReader(r)
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(br, signature); err != nil || string(signature) != pngSignature {
+		return nil, errors.Wrap(ErrInvalidPNG, "invalid signature")
+	}
+
+	var texts []PNGText
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return nil, errors.Wrap(ErrInvalidPNG, "missing IEND chunk")
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		chunkType := string(header[4:])
+
+		switch chunkType {
+		case "IEND":
+			return texts, nil
+		case "tEXt", "iTXt", "zTXt":
+			if maxPNGTextChunkLength < length {
+				return nil, errors.Wrapf(ErrInvalidPNG, "%s chunk too large: %d bytes", chunkType, length)
+			}
+			data := make([]byte, length+4)
+			if _, err := io.ReadFull(br, data); err != nil {
+				return nil, errors.Wrapf(ErrInvalidPNG, "truncated %s chunk", chunkType)
+			}
+			crc := crc32.NewIEEE()
+			_, _ = io.WriteString(crc, chunkType)
+			_, _ = crc.Write(data[:length])
+			if crc.Sum32() != binary.BigEndian.Uint32(data[length:]) {
+				return nil, errors.Wrapf(ErrInvalidPNG, "CRC mismatch in %s chunk", chunkType)
+			}
+			text, err := parsePNGTextChunk(chunkType, data[:length])
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to parsePNGTextChunk")
+			}
+			texts = append(texts, text)
+		default:
+			if _, err := br.Discard(int(length) + 4); err != nil {
+				return nil, errors.Wrapf(ErrInvalidPNG, "truncated %s chunk", chunkType)
+			}
+		}
+	}
+}
+
+// parsePNGTextChunk テキストチャンクのデータからキーワードと値を取り出す
+func parsePNGTextChunk(chunkType string, data []byte) (PNGText, error) {
+	keyword, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok || len(keyword) == 0 {
+		return PNGText{}, errors.Wrapf(ErrInvalidPNG, "missing keyword in %s chunk", chunkType)
+	}
+
+	switch chunkType {
+	case "tEXt":
+		return PNGText{Keyword: fromLatin1(keyword), Text: fromLatin1(rest)}, nil
+	case "zTXt":
+		if len(rest) < 1 {
+			return PNGText{}, errors.Wrap(ErrInvalidPNG, "missing compression method in zTXt chunk")
+		}
+		text, err := inflate(rest[1:])
+		if err != nil {
+			return PNGText{}, errors.Wrap(err, "Failed to inflate")
+		}
+		return PNGText{Keyword: fromLatin1(keyword), Text: fromLatin1(text)}, nil
+	}
+
+	// iTXt: 圧縮フラグ・圧縮方式・言語タグ・翻訳したキーワードの後に値が続く
+	if len(rest) < 2 {
+		return PNGText{}, errors.Wrap(ErrInvalidPNG, "missing compression flag in iTXt chunk")
+	}
+	compressed := rest[0] == 1
+	_, rest, ok = bytes.Cut(rest[2:], []byte{0})
+	if !ok {
+		return PNGText{}, errors.Wrap(ErrInvalidPNG, "missing language tag in iTXt chunk")
+	}
+	_, text, ok := bytes.Cut(rest, []byte{0})
+	if !ok {
+		return PNGText{}, errors.Wrap(ErrInvalidPNG, "missing translated keyword in iTXt chunk")
+	}
+	if compressed {
+		inflated, err := inflate(text)
+		if err != nil {
+			return PNGText{}, errors.Wrap(err, "Failed to inflate")
+		}
+		text = inflated
+	}
+	return PNGText{Keyword: fromLatin1(keyword), Text: string(text)}, nil
+}
+
+// inflate zlib形式で圧縮された値を展開する
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to zlib.NewReader")
+	}
+	defer func() { _ = reader.Close() }()
+
+	text, err := io.ReadAll(io.LimitReader(reader, maxPNGTextChunkLength))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to io.ReadAll")
+	}
+	return text, nil
+}
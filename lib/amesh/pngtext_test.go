@@ -0,0 +1,149 @@
+package amesh_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+)
+
+// software 画像に埋め込むソフトウェアとバージョン
+var software = amesh.PNGText{Keyword: amesh.PNGTextSoftware, Text: "hato-bot-go/" + lib.BuildVersion()}
+
+func TestAmeshMetadataPNGText(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *amesh.AmeshMetadata
+		expected []amesh.PNGText
+	}{
+		{
+			name: "地名・座標・時刻・出典",
+			metadata: &amesh.AmeshMetadata{
+				RadarTime: time.Date(2026, 10, 16, 3, 5, 0, 0, time.UTC),
+				Providers: []string{amesh.ProviderOpenStreetMap, amesh.ProviderJMA},
+				Locations: []amesh.Location{{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"}},
+			},
+			expected: []amesh.PNGText{
+				{Keyword: amesh.PNGTextTitle, Text: "東京"},
+				{Keyword: amesh.PNGTextLocation, Text: "35.681200,139.767100"},
+				{Keyword: amesh.PNGTextRadarTime, Text: "2026-10-16T03:05:00Z"},
+				software,
+				{Keyword: amesh.PNGTextAttribution, Text: "OpenStreetMap, 気象庁"},
+			},
+		},
+		{
+			name: "複数の地点",
+			metadata: &amesh.AmeshMetadata{
+				Locations: []amesh.Location{
+					{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"},
+					{Lat: -33.8688, Lng: 151.2093},
+				},
+			},
+			expected: []amesh.PNGText{
+				{Keyword: amesh.PNGTextTitle, Text: "東京"},
+				{Keyword: amesh.PNGTextLocation, Text: "35.681200,139.767100 / -33.868800,151.209300"},
+				software,
+			},
+		},
+		{
+			name:     "情報なし",
+			metadata: &amesh.AmeshMetadata{},
+			expected: []amesh.PNGText{software},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, tt.metadata.PNGText()); diff != "" {
+				t.Errorf("PNGText() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestAmeshResultReaderPNGText エンコードした画像に情報が埋め込まれ、画像としても読み込めることをテストする
+func TestAmeshResultReaderPNGText(t *testing.T) {
+	t.Parallel()
+	result := &amesh.AmeshResult{
+		Image: image.NewRGBA(image.Rect(0, 0, 16, 16)),
+		AmeshMetadata: amesh.AmeshMetadata{
+			RadarTime: time.Date(2026, 10, 16, 3, 5, 0, 0, time.UTC),
+			Providers: []string{amesh.ProviderOpenStreetMap, amesh.ProviderJMA},
+			Locations: []amesh.Location{{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"}},
+		},
+	}
+	expected := result.PNGText()
+
+	reader := result.Reader()
+	encoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("io.ReadAll() error = %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	texts, err := amesh.ReadPNGText(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("ReadPNGText() error = %v", err)
+	}
+	if diff := cmp.Diff(expected, texts); diff != "" {
+		t.Errorf("ReadPNGText() mismatch (-want +got):\n%s", diff)
+	}
+
+	img, err := png.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if got := img.Bounds(); got != image.Rect(0, 0, 16, 16) {
+		t.Errorf("Bounds() = %v, want %v", got, image.Rect(0, 0, 16, 16))
+	}
+}
+
+func TestReadPNGText(t *testing.T) {
+	plain, err := createDummyPNGBytes(4, 4, image.White.C)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IHDRの直後にtEXtチャンク「Comment: hato」を挿入した画像
+	withText := append(append(append([]byte{}, plain[:33]...),
+		0, 0, 0, 12, 't', 'E', 'X', 't', 'C', 'o', 'm', 'm', 'e', 'n', 't', 0, 'h', 'a', 't', 'o', 0x8f, 0x83, 0x34, 0x39,
+	), plain[33:]...)
+	corrupted := bytes.Clone(withText)
+	corrupted[33+8+8] = 'X'
+
+	tests := []struct {
+		name          string
+		data          []byte
+		expected      []amesh.PNGText
+		expectedError error
+	}{
+		{name: "テキストチャンクなし", data: plain},
+		{name: "tEXtチャンク", data: withText, expected: []amesh.PNGText{{Keyword: "Comment", Text: "hato"}}},
+		{name: "PNGでない", data: []byte("GIF89a"), expectedError: amesh.ErrInvalidPNG},
+		{name: "途中で切れている", data: plain[:len(plain)-12], expectedError: amesh.ErrInvalidPNG},
+		{name: "CRCが一致しない", data: corrupted, expectedError: amesh.ErrInvalidPNG},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			texts, err := amesh.ReadPNGText(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ReadPNGText() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, texts); diff != "" {
+				t.Errorf("ReadPNGText() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package amesh
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// canvasPool 描画に使う画像の使い回し
+// リクエストごとに1280x1280の画像を確保するとGCの負荷が高くなるため、エンコード後の画像を再利用する
+var canvasPool sync.Pool
+
+// tileBufferPool タイル画像のレスポンスを読み込むバッファの使い回し
+var tileBufferPool = sync.Pool{
+	New: func() any {
+		return &bytes.Buffer{}
+	},
+}
+
+// pngBufferPool PNGエンコーダの内部バッファの使い回し
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+// Get PNGエンコーダの内部バッファを取得する
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	buf, _ := p.pool.Get().(*png.EncoderBuffer)
+	return buf
+}
+
+// Put PNGエンコーダの内部バッファを戻す
+func (p *pngBufferPool) Put(buf *png.EncoderBuffer) {
+	p.pool.Put(buf)
+}
+
+// pngEncoder 内部バッファを使い回すPNGエンコーダ
+var pngEncoder = &png.Encoder{BufferPool: &pngBufferPool{}}
+
+// getCanvas 指定した大きさの画像を取得する
+// 使い回した画像は前回の内容が残っているため、呼び出し側で塗りつぶすこと
+func getCanvas(width, height int) *image.RGBA {
+	size := width * height * 4
+	if img, ok := canvasPool.Get().(*image.RGBA); ok && size <= cap(img.Pix) {
+		img.Pix = img.Pix[:size]
+		img.Stride = width * 4
+		img.Rect = image.Rect(0, 0, width, height)
+		return img
+	}
+	return image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+// putCanvas 使い終わった画像を戻す
+// 戻した画像はその後参照しないこと
+func putCanvas(img *image.RGBA) {
+	if img != nil {
+		canvasPool.Put(img)
+	}
+}
+
+// encodePNG 画像をPNG形式でエンコードしてbytes.Bufferを返す
+// textsはIHDRチャンクの直後にテキストチャンクとして埋め込む
+func encodePNG(img image.Image, texts []PNGText) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	if err := pngEncoder.Encode(newPNGTextWriter(buf, texts), img); err != nil {
+		return nil, errors.Wrap(err, "Failed to pngEncoder.Encode")
+	}
+	return buf, nil
+}
+
+// encodePNGStream 画像をPNG形式でエンコードしながら読み出すio.ReadCloserを返す
+// エンコードは別のgoroutineで行い、終了後に画像をプールに戻すため、呼び出し側は画像を参照しないこと
+// 読み出し側をCloseするとエンコードを中断する
+func encodePNGStream(img *image.RGBA, texts []PNGText) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer putCanvas(img)
+		if err := pngEncoder.Encode(newPNGTextWriter(pw, texts), img); err != nil {
+			pw.CloseWithError(errors.Wrap(err, "Failed to pngEncoder.Encode"))
+			return
+		}
+		if err := pw.Close(); err != nil {
+			log.Printf("Failed to Close: %v", err)
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,103 @@
+package amesh
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"testing"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// newBenchmarkTransport 全てのタイルに同じPNG画像を返すモック
+func newBenchmarkTransport(tb testing.TB) *httpclient.MockTransport {
+	tb.Helper()
+
+	tile := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(tile, tile.Bounds(), image.NewUniform(color.RGBA{R: 100, G: 150, B: 200, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, tile); err != nil {
+		tb.Fatal(err)
+	}
+
+	return httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+				{"basetime": "20240101120500", "validtime": "20240101120500", "elements": ["hrpns_nd"]}
+			]`}}},
+			{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: buf.String()}}},
+		},
+	})
+}
+
+func BenchmarkCreateImageBufferWithClient(b *testing.B) {
+	client := newBenchmarkTransport(b).Client()
+	location := &Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		buf, err := CreateImageBufferWithClient(b.Context(), &LocationImageParams{
+			Client:   client,
+			Location: location,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGetCanvas(t *testing.T) {
+	tests := []struct {
+		name   string
+		reused *image.RGBA // 事前にプールへ戻す画像
+		width  int
+		height int
+	}{
+		{
+			name:   "プールが空の場合は新しく確保",
+			width:  768,
+			height: 768,
+		},
+		{
+			name:   "大きい画像を小さい画像として使い回す",
+			reused: image.NewRGBA(image.Rect(0, 0, 1280, 1280)),
+			width:  768,
+			height: 512,
+		},
+		{
+			name:   "小さい画像は使い回さない",
+			reused: image.NewRGBA(image.Rect(0, 0, 256, 256)),
+			width:  1280,
+			height: 1280,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// canvasPoolはパッケージ全体で共有するため並列に実行しない
+			putCanvas(tt.reused)
+
+			img := getCanvas(tt.width, tt.height)
+			if img.Bounds() != image.Rect(0, 0, tt.width, tt.height) {
+				t.Errorf("Bounds() = %v, want %dx%d", img.Bounds(), tt.width, tt.height)
+			}
+			if img.Stride != tt.width*4 || len(img.Pix) != tt.width*tt.height*4 {
+				t.Errorf("Stride = %d, len(Pix) = %d", img.Stride, len(img.Pix))
+			}
+
+			// 右下のピクセルまで書き込めること
+			img.SetRGBA(tt.width-1, tt.height-1, color.RGBA{R: 1, A: 255})
+			if got := img.RGBAAt(tt.width-1, tt.height-1); got != (color.RGBA{R: 1, A: 255}) {
+				t.Errorf("RGBAAt() = %v", got)
+			}
+			putCanvas(img)
+		})
+	}
+}
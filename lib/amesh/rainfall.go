@@ -0,0 +1,237 @@
+package amesh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sync/errgroup"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/chart"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+// 降水強度の時系列の定数
+const (
+	rainfallZoom  = 10        // 降水強度を読み取るタイルのズームレベル（降水ナウキャストのタイルの最大）
+	rainfallRange = time.Hour // 予測を読み取る範囲（基準時刻からの経過時間）
+)
+
+// RainLevel 降水ナウキャストの降水強度の階級
+type RainLevel struct {
+	MinIntensity float64    // 階級の下限の降水強度（mm/h）
+	Color        color.RGBA // タイルの色
+}
+
+// RainLevels 気象庁の降水ナウキャストの凡例の階級（弱い順）
+// RainfallSample.Levelは添字+1で、0は降水がないことを表す
+var RainLevels = []RainLevel{
+	{MinIntensity: 0, Color: color.RGBA{R: 242, G: 242, B: 255, A: 255}},
+	{MinIntensity: 1, Color: color.RGBA{R: 160, G: 210, B: 255, A: 255}},
+	{MinIntensity: 5, Color: color.RGBA{R: 33, G: 140, B: 255, A: 255}},
+	{MinIntensity: 10, Color: color.RGBA{R: 0, G: 65, B: 255, A: 255}},
+	{MinIntensity: 20, Color: color.RGBA{R: 250, G: 245, B: 0, A: 255}},
+	{MinIntensity: 30, Color: color.RGBA{R: 255, G: 153, B: 0, A: 255}},
+	{MinIntensity: 50, Color: color.RGBA{R: 255, G: 40, B: 0, A: 255}},
+	{MinIntensity: 80, Color: color.RGBA{R: 180, G: 0, B: 104, A: 255}},
+}
+
+// ParseRainCommandResult rainコマンドの解析結果を表す構造体
+type ParseRainCommandResult struct {
+	Place  string
+	IsRain bool
+}
+
+// RainfallSample 地点のある時刻の降水強度
+type RainfallSample struct {
+	Time     time.Time // 対象時刻
+	Forecast bool      // 予測か（falseの場合は基準時刻の解析値）
+	Level    int       // 降水強度の階級（RainLevelsの添字+1、0は降水なし）
+}
+
+// GetRainfallWithClientParams 降水強度の時系列取得のリクエスト構造体
+type GetRainfallWithClientParams struct {
+	Client         httpclient.Doer           // HTTPクライアント
+	Location       *Location                 // 位置情報
+	TimestampCache *httpclient.ResponseCache // targetTimesのキャッシュ（nilの場合はキャッシュしない）
+}
+
+// Rainfall 地点の降水強度の時系列
+type Rainfall struct {
+	Location Location         // 地点
+	Samples  []RainfallSample // 基準時刻の解析値と1時間先までの予測（時刻順）
+}
+
+// ParseRainCommand rainコマンドを解析
+// 解析の前にlib.NormalizeMessageで表記ゆれとメンション・MFMの装飾を取り除く
+func ParseRainCommand(text string) ParseRainCommandResult {
+	parsed := lib.ParseCommand(lib.NormalizeMessage(text), "rain")
+	if !parsed.Matched {
+		return ParseRainCommandResult{
+			Place:  "",
+			IsRain: false,
+		}
+	}
+
+	place := parsed.Args
+	if place == "" {
+		place = "東京" // デフォルトの場所
+	}
+	return ParseRainCommandResult{
+		Place:  place,
+		IsRain: true,
+	}
+}
+
+// RainCommandErrorKey rainコマンド処理のエラーに応じた返信メッセージのキーを返す
+// 外部サービスの障害と雨雲レーダーのデータが取得できない場合はameshコマンドと同じメッセージにする
+func RainCommandErrorKey(err error) i18n.Key {
+	if key := CommandErrorKey(err); key != i18n.KeyErrorCommand {
+		return key
+	}
+	return i18n.KeyErrorRainCommand
+}
+
+// GetRainfall 既定のHTTPクライアントとtargetTimesのキャッシュでGetRainfallWithClientを呼び出す
+func GetRainfall(ctx context.Context, location *Location) (*Rainfall, error) {
+	return GetRainfallWithClient(ctx, &GetRainfallWithClientParams{
+		Client:         defaultClient,
+		Location:       location,
+		TimestampCache: defaultTimestampCache,
+	})
+}
+
+// GetRainfallWithClient HTTPクライアントを指定して、地点の最新の雨雲レーダーと1時間先までの予測の降水強度を取得する
+// 地点を含む雨雲レーダーのタイルを対象時刻ごとに並行して取得し、地点のピクセルの色から降水強度の階級を読み取る
+// 雨雲レーダーのタイムスタンプが取得できない場合はErrNoRadarData、予測がない場合はErrNoForecastDataを返す
+func GetRainfallWithClient(ctx context.Context, params *GetRainfallWithClientParams) (*Rainfall, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	timestamps, err := LatestTimestampsWithClient(ctx, &LatestTimestampsParams{
+		Client:         params.Client,
+		TimestampCache: params.TimestampCache,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to LatestTimestampsWithClient")
+	}
+	baseTime := timestamps.BaseTime(ElementRadar)
+	if baseTime.IsZero() {
+		return nil, ErrNoRadarData
+	}
+
+	samples := []RainfallSample{{Time: baseTime}}
+	for _, validTime := range timestamps.ValidTimes(ElementRadarForecast, baseTime) {
+		if validTime.Sub(baseTime) <= rainfallRange {
+			samples = append(samples, RainfallSample{Time: validTime, Forecast: true})
+		}
+	}
+	if len(samples) == 1 {
+		return nil, errors.Wrapf(ErrNoForecastData, "basetime: %s", formatJMATimestamp(baseTime))
+	}
+
+	// 地点のピクセルを含むタイルと、タイル内の位置
+	x, y := getWebMercatorPixel(&CreateAmeshImageParams{Lat: params.Location.Lat, Lng: params.Location.Lng, Zoom: rainfallZoom})
+	tileX, tileY := int(x)/256, int(y)/256
+	pixel := image.Point{X: int(x) % 256, Y: int(y) % 256}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range samples {
+		g.Go(func() error {
+			tileURL := jmaTileURL("nowc", formatJMATimestamp(baseTime), formatJMATimestamp(samples[i].Time), "hrpns")(rainfallZoom, tileX, tileY)
+			tile, err := downloadTile(gctx, params.Client, tileURL)
+			if err != nil {
+				return errors.Wrap(err, "Failed to downloadTile")
+			}
+			bounds := tile.Bounds()
+			samples[i].Level = rainLevelOf(tile.At(bounds.Min.X+pixel.X*bounds.Dx()/256, bounds.Min.Y+pixel.Y*bounds.Dy()/256))
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch radar tiles")
+	}
+
+	return &Rainfall{
+		Location: *params.Location,
+		Samples:  samples,
+	}, nil
+}
+
+// rainLevelOf タイルの色に最も近い凡例の色の階級を返す
+// 半分以上透明な色は降水なし（0）とする
+func rainLevelOf(c color.Color) int {
+	r, g, b, a := c.RGBA()
+	if a < 0x8000 {
+		return 0
+	}
+
+	level := 0
+	bestDistance := -1
+	for i, rainLevel := range RainLevels {
+		dr := int(r>>8) - int(rainLevel.Color.R)
+		dg := int(g>>8) - int(rainLevel.Color.G)
+		db := int(b>>8) - int(rainLevel.Color.B)
+		if distance := dr*dr + dg*dg + db*db; bestDistance < 0 || distance < bestDistance {
+			level, bestDistance = i+1, distance
+		}
+	}
+	return level
+}
+
+// StartTime 最初に降水がある時刻を返す
+// 1時間先まで降水がない場合はfalseを返す
+func (r *Rainfall) StartTime() (time.Time, bool) {
+	for _, sample := range r.Samples {
+		if 0 < sample.Level {
+			return sample.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Chart 降水強度の階級を棒の高さと凡例の色で表した棒グラフを描画する
+// 棒の下には基準時刻からの経過時間（分）を表示する
+func (r *Rainfall) Chart() *image.RGBA {
+	bars := make([]chart.Bar, 0, len(r.Samples))
+	for _, sample := range r.Samples {
+		label := "NOW"
+		if sample.Forecast {
+			label = fmt.Sprintf("+%d", int(sample.Time.Sub(r.Samples[0].Time).Minutes()))
+		}
+		bar := chart.Bar{Label: label, Value: float64(sample.Level)}
+		if 0 < sample.Level {
+			bar.Color = RainLevels[sample.Level-1].Color
+		}
+		bars = append(bars, bar)
+	}
+
+	first, last := r.Samples[0].Time.In(jst), r.Samples[len(r.Samples)-1].Time.In(jst)
+	return (&chart.BarChart{
+		Title: fmt.Sprintf("RAIN %s-%s", first.Format("15:04"), last.Format("15:04 MST")),
+		Bars:  bars,
+		Max:   float64(len(RainLevels)),
+	}).Render()
+}
+
+// ChartPNG 降水強度の棒グラフをPNG形式にエンコードして返す
+// 地名と座標、雨雲レーダーの基準時刻をPNGのテキストチャンクに埋め込む
+func (r *Rainfall) ChartPNG() (*bytes.Buffer, error) {
+	metadata := AmeshMetadata{
+		RadarTime: r.Samples[0].Time,
+		Providers: []string{ProviderJMA},
+		Locations: []Location{r.Location},
+	}
+	buf, err := encodePNG(r.Chart(), metadata.PNGText())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to encodePNG")
+	}
+	return buf, nil
+}
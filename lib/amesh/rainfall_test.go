@@ -0,0 +1,182 @@
+package amesh_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestParseRainCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected amesh.ParseRainCommandResult
+	}{
+		{name: "地名あり", text: "rain 大阪", expected: amesh.ParseRainCommandResult{Place: "大阪", IsRain: true}},
+		{name: "地名なし", text: "@hato rain", expected: amesh.ParseRainCommandResult{Place: "東京", IsRain: true}},
+		{name: "ハッシュタグ", text: "#rain 札幌", expected: amesh.ParseRainCommandResult{Place: "札幌", IsRain: true}},
+		{name: "別のコマンド", text: "amesh 東京", expected: amesh.ParseRainCommandResult{}},
+		{name: "コマンドでない", text: "rainbow 東京", expected: amesh.ParseRainCommandResult{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, amesh.ParseRainCommand(tt.text)); diff != "" {
+				t.Errorf("ParseRainCommand(%q) mismatch (-want +got):\n%s", tt.text, diff)
+			}
+		})
+	}
+}
+
+func TestRainCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{name: "予測がない", err: errors.Wrap(amesh.ErrNoForecastData, "Failed to GetRainfall"), expected: i18n.KeyErrorNoForecastData},
+		{name: "その他のエラー", err: errors.New("unknown"), expected: i18n.KeyErrorRainCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := amesh.RainCommandErrorKey(tt.err); got != tt.expected {
+				t.Errorf("RainCommandErrorKey() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetRainfall(t *testing.T) {
+	tests := []struct {
+		name        string
+		scenario    *ameshtest.Scenario
+		expectError error
+	}{
+		{
+			name:     "基準時刻と1時間先までの予測を取得する",
+			scenario: &ameshtest.Scenario{Forecast: true},
+		},
+		{
+			name:        "予測がない",
+			scenario:    &ameshtest.Scenario{},
+			expectError: amesh.ErrNoForecastData,
+		},
+		{
+			name:        "雨雲レーダーのタイムスタンプが取得できない",
+			scenario:    &ameshtest.Scenario{Forecast: true, NoTargetTimes: true},
+			expectError: amesh.ErrNoRadarData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := ameshtest.NewServer(t, tt.scenario)
+
+			rainfall, err := amesh.GetRainfallWithClient(t.Context(), &amesh.GetRainfallWithClientParams{
+				Client:   server.Client(),
+				Location: &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"},
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("GetRainfallWithClient() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+
+			// テストサーバーの雨雲レーダーのタイルは10mm/h以上20mm/h未満の色で塗りつぶされている
+			expected := []amesh.RainfallSample{{Time: ameshtest.DefaultBaseTime, Level: 4}}
+			for offset := ameshtest.ForecastStep; offset <= ameshtest.ForecastRange; offset += ameshtest.ForecastStep {
+				expected = append(expected, amesh.RainfallSample{Time: ameshtest.DefaultBaseTime.Add(offset), Forecast: true, Level: 4})
+			}
+			if diff := cmp.Diff(expected, rainfall.Samples); diff != "" {
+				t.Errorf("Samples mismatch (-want +got):\n%s", diff)
+			}
+			if startTime, ok := rainfall.StartTime(); !ok || !startTime.Equal(ameshtest.DefaultBaseTime) {
+				t.Errorf("StartTime() = %v, %v, want %v, true", startTime, ok, ameshtest.DefaultBaseTime)
+			}
+		})
+	}
+}
+
+func TestGetRainfallParamsNil(t *testing.T) {
+	if _, err := amesh.GetRainfallWithClient(t.Context(), &amesh.GetRainfallWithClientParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("GetRainfallWithClient() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
+
+func TestRainfallStartTime(t *testing.T) {
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		samples    []amesh.RainfallSample
+		expected   time.Time
+		expectedOK bool
+	}{
+		{
+			name: "途中から降り始める",
+			samples: []amesh.RainfallSample{
+				{Time: base},
+				{Time: base.Add(5 * time.Minute), Forecast: true},
+				{Time: base.Add(10 * time.Minute), Forecast: true, Level: 2},
+			},
+			expected:   base.Add(10 * time.Minute),
+			expectedOK: true,
+		},
+		{
+			name: "降らない",
+			samples: []amesh.RainfallSample{
+				{Time: base},
+				{Time: base.Add(5 * time.Minute), Forecast: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := (&amesh.Rainfall{Samples: tt.samples}).StartTime()
+			if !got.Equal(tt.expected) || ok != tt.expectedOK {
+				t.Errorf("StartTime() = %v, %v, want %v, %v", got, ok, tt.expected, tt.expectedOK)
+			}
+		})
+	}
+}
+
+func TestRainfallChartPNG(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	rainfall := &amesh.Rainfall{
+		Location: amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"},
+		Samples: []amesh.RainfallSample{
+			{Time: base},
+			{Time: base.Add(5 * time.Minute), Forecast: true, Level: 8},
+		},
+	}
+
+	buf, err := rainfall.ChartPNG()
+	if err != nil {
+		t.Fatalf("ChartPNG() error = %v", err)
+	}
+	img, _, err := image.Decode(buf)
+	if err != nil {
+		t.Fatalf("image.Decode() error = %v", err)
+	}
+	// 最も強い階級の棒は凡例の色で描画領域の上端まで描画する
+	bounds := img.Bounds()
+	r, g, b, _ := img.At(bounds.Dx()*3/4, bounds.Dy()/2).RGBA()
+	if expected := amesh.RainLevels[7].Color; uint8(r>>8) != expected.R || uint8(g>>8) != expected.G || uint8(b>>8) != expected.B {
+		t.Errorf("bar pixel = (%d, %d, %d), want %v", r>>8, g>>8, b>>8, expected)
+	}
+}
@@ -0,0 +1,167 @@
+package amesh
+
+import (
+	"image"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// jmaTimestampLayout 気象庁のtargetTimesのbasetimeの書式（UTC）
+const jmaTimestampLayout = "20060102150405"
+
+// データの提供元
+const (
+	ProviderOpenStreetMap = "OpenStreetMap" // ベースマップ
+	ProviderJMA           = "気象庁"           // レーダー・落雷・キキクル・積雪
+)
+
+// jst 返信に表示する時刻のタイムゾーン
+var jst = time.FixedZone("JST", 9*60*60)
+
+// AttributedLayer 外部のデータを描画するレイヤー
+// CreateAmeshImageはProviderの値を重複を除いてAmeshMetadata.Providersに集める
+type AttributedLayer interface {
+	Layer
+	Provider() string
+}
+
+// AmeshMetadata amesh画像の作成に使ったデータの情報
+type AmeshMetadata struct {
+	RadarTime      time.Time   // 使用した雨雲レーダーのbasetime（雨雲レーダーを描画していない場合はゼロ値）
+	LightningCount int         // 描画範囲内の落雷地点数
+	Tiles          TileStats   // タイルの取得結果
+	Providers      []string    // 使用したデータの提供元（描画順）
+	BoundingBox    BoundingBox // 描画した範囲
+	Locations      []Location  // 描画した地点（座標から直接作成した場合は空）
+	ForecastTimes  []time.Time // 予測のパネルの対象時刻（予測を並べていない場合はnil）
+	HistoryTimes   []time.Time // 過去の雨雲レーダーのパネルの時刻（古い順、過去の雨雲レーダーを並べていない場合はnil）
+	Stale          bool        // 雨雲レーダーのデータが古いか（StaleBannerLayerを描画した場合はtrue）
+}
+
+// RadarTimeText 雨雲レーダーの時刻を日本時間の「15:04 JST」の形式で返す
+// 雨雲レーダーを描画していない場合は空文字列を返す
+func (m *AmeshMetadata) RadarTimeText() string {
+	if m.RadarTime.IsZero() {
+		return ""
+	}
+	return m.RadarTime.In(jst).Format("15:04 MST")
+}
+
+// ForecastTimeTexts 予測のパネルの対象時刻を日本時間の「15:04 JST」の形式で返す
+// 予測を並べていない場合はnilを返す
+func (m *AmeshMetadata) ForecastTimeTexts() []string {
+	var texts []string
+	for _, forecastTime := range m.ForecastTimes {
+		texts = append(texts, forecastTime.In(jst).Format("15:04 MST"))
+	}
+	return texts
+}
+
+// HistoryTimeTexts 過去の雨雲レーダーのパネルの時刻を日本時間の「15:04 JST」の形式で古い順に返す
+// 過去の雨雲レーダーを並べていない場合はnilを返す
+func (m *AmeshMetadata) HistoryTimeTexts() []string {
+	var texts []string
+	for _, historyTime := range m.HistoryTimes {
+		texts = append(texts, historyTime.In(jst).Format("15:04 MST"))
+	}
+	return texts
+}
+
+// RadarDateTimeText 雨雲レーダーの日時を日本時間の「2006-01-02 15:04 JST」の形式で返す
+// 画像の説明文のように後から見返す文字列に使う（雨雲レーダーを描画していない場合は空文字列を返す）
+func (m *AmeshMetadata) RadarDateTimeText() string {
+	if m.RadarTime.IsZero() {
+		return ""
+	}
+	return m.RadarTime.In(jst).Format("2006-01-02 15:04 MST")
+}
+
+// AmeshResult amesh画像と作成に使ったデータの情報
+type AmeshResult struct {
+	Image *image.RGBA // 描画した画像
+	AmeshMetadata
+}
+
+// ImageReader PNG形式にエンコードしながら読み出すamesh画像と作成に使ったデータの情報
+// 読み終わる前に破棄する場合は必ずCloseすること
+type ImageReader struct {
+	io.ReadCloser
+	AmeshMetadata
+}
+
+// Reader 画像をPNG形式にエンコードしながら読み出すImageReaderを返す
+// 作成に使ったデータの情報はPNGのテキストチャンクとして埋め込む
+// 画像はエンコード後にプールに戻すため、呼び出し後はImageを参照しないこと
+func (r *AmeshResult) Reader() *ImageReader {
+	return &ImageReader{
+		ReadCloser:    encodePNGStream(r.Image, r.PNGText()),
+		AmeshMetadata: r.AmeshMetadata,
+	}
+}
+
+// metadata 描画したレイヤーと描画結果から画像の作成に使ったデータの情報を作成する
+// 描画範囲は呼び出し側で設定する
+func (r *RenderResult) metadata(layers []Layer) AmeshMetadata {
+	metadata := AmeshMetadata{
+		LightningCount: r.Points,
+		Tiles:          r.Tiles,
+	}
+	for _, layer := range layers {
+		if radar, ok := layer.(*RadarLayer); ok {
+			metadata.RadarTime = parseJMATimestamp(radar.Timestamp)
+		}
+		if isStaleBannerLayer(layer) {
+			metadata.Stale = true
+		}
+		if attributed, ok := layer.(AttributedLayer); ok && !slices.Contains(metadata.Providers, attributed.Provider()) {
+			metadata.Providers = append(metadata.Providers, attributed.Provider())
+		}
+	}
+	return metadata
+}
+
+// parseJMATimestamp 気象庁のbasetimeを解析する
+// 解析できない場合はゼロ値を返す
+func parseJMATimestamp(timestamp string) time.Time {
+	t, err := time.Parse(jmaTimestampLayout, timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// formatJMATimestamp 時刻をタイルのURLに使う気象庁のbasetimeの形式にする
+// ゼロ値の場合は空文字列を返す
+func formatJMATimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(jmaTimestampLayout)
+}
+
+// BoundingBox 描画範囲の四隅の座標を返す
+// 日付変更線をまたぐ場合、経度は-180〜180度の範囲外になる
+func (v *Viewport) BoundingBox() BoundingBox {
+	centerX, centerY := getWebMercatorPixel(&CreateAmeshImageParams{Lat: v.Lat, Lng: v.Lng, Zoom: v.Zoom})
+	half := float64(v.Size() / 2)
+	worldSize := 256 * float64(int(1)<<uint(v.Zoom))
+
+	return BoundingBox{
+		MinLat: pixelToLat(centerY+half, worldSize),
+		MinLng: pixelToLng(centerX-half, worldSize),
+		MaxLat: pixelToLat(centerY-half, worldSize),
+		MaxLng: pixelToLng(centerX+half, worldSize),
+	}
+}
+
+// pixelToLng Webメルカトル投影のピクセル座標Xを経度に変換する
+func pixelToLng(x, worldSize float64) float64 {
+	return x/worldSize*360 - 180
+}
+
+// pixelToLat Webメルカトル投影のピクセル座標Yを緯度に変換する
+func pixelToLat(y, worldSize float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/worldSize))) * 180 / math.Pi
+}
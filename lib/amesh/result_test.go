@@ -0,0 +1,195 @@
+package amesh_test
+
+import (
+	"image/color"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestViewportBoundingBox(t *testing.T) {
+	tests := []struct {
+		name     string
+		viewport *amesh.Viewport
+	}{
+		{
+			name:     "東京のズームレベル10",
+			viewport: &amesh.Viewport{Lat: 35.6812, Lng: 139.7671, Zoom: 10, AroundTiles: 2},
+		},
+		{
+			name:     "日付変更線付近",
+			viewport: &amesh.Viewport{Lat: 51.0, Lng: 179.9, Zoom: 8, AroundTiles: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			bbox := tt.viewport.BoundingBox()
+
+			if !(bbox.MinLat < tt.viewport.Lat && tt.viewport.Lat < bbox.MaxLat) {
+				t.Errorf("latitude %f is not within %+v", tt.viewport.Lat, bbox)
+			}
+			if !(bbox.MinLng < tt.viewport.Lng && tt.viewport.Lng < bbox.MaxLng) {
+				t.Errorf("longitude %f is not within %+v", tt.viewport.Lng, bbox)
+			}
+
+			// 四隅は画像の角に変換される
+			size := tt.viewport.Size()
+			sw := tt.viewport.ImagePoint(bbox.MinLat, bbox.MinLng)
+			ne := tt.viewport.ImagePoint(bbox.MaxLat, bbox.MaxLng)
+			if abs(sw.X) > 1 || abs(sw.Y-size) > 1 || abs(ne.X-size) > 1 || abs(ne.Y) > 1 {
+				t.Errorf("corners = %v, %v, want (0, %d), (%d, 0)", sw, ne, size, size)
+			}
+		})
+	}
+}
+
+func TestAmeshMetadataRadarTimeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *amesh.AmeshMetadata
+		expected string
+	}{
+		{
+			name:     "UTCのbasetimeを日本時間で表示",
+			metadata: &amesh.AmeshMetadata{RadarTime: time.Date(2024, 1, 1, 3, 5, 0, 0, time.UTC)},
+			expected: "12:05 JST",
+		},
+		{
+			name:     "雨雲レーダーなし",
+			metadata: &amesh.AmeshMetadata{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.metadata.RadarTimeText(); got != tt.expected {
+				t.Errorf("RadarTimeText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAmeshMetadataRadarDateTimeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *amesh.AmeshMetadata
+		expected string
+	}{
+		{
+			name:     "UTCのbasetimeを日本時間の日時で表示",
+			metadata: &amesh.AmeshMetadata{RadarTime: time.Date(2024, 1, 1, 3, 5, 0, 0, time.UTC)},
+			expected: "2024-01-01 12:05 JST",
+		},
+		{
+			name:     "日本時間では翌日",
+			metadata: &amesh.AmeshMetadata{RadarTime: time.Date(2024, 12, 31, 15, 0, 0, 0, time.UTC)},
+			expected: "2025-01-01 00:00 JST",
+		},
+		{
+			name:     "雨雲レーダーなし",
+			metadata: &amesh.AmeshMetadata{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.metadata.RadarDateTimeText(); got != tt.expected {
+				t.Errorf("RadarDateTimeText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCreateAmeshImageMetadata 画像の作成に使ったデータの情報を返すことをテストする
+func TestCreateAmeshImageMetadata(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		timestamps        string
+		expectedRadarTime time.Time
+		expectedLightning int
+		expectedProviders []string
+	}{
+		{
+			name: "雨雲レーダーと落雷",
+			timestamps: `[
+				{"basetime": "20240101030500", "validtime": "20240101030500", "elements": ["hrpns_nd", "liden"]}
+			]`,
+			expectedRadarTime: time.Date(2024, 1, 1, 3, 5, 0, 0, time.UTC),
+			// 描画範囲外の落雷地点は数えない
+			expectedLightning: 1,
+			expectedProviders: []string{amesh.ProviderOpenStreetMap, amesh.ProviderJMA},
+		},
+		{
+			name:              "レーダーデータなし",
+			timestamps:        `[]`,
+			expectedProviders: []string{amesh.ProviderOpenStreetMap},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: tt.timestamps}}},
+					{Pattern: "liden/data.geojson", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"features": [
+						{"geometry": {"coordinates": [139.7, 35.7]}, "properties": {"type": 1}},
+						{"geometry": {"coordinates": [130.4, 33.6]}, "properties": {"type": 1}}
+					]}`}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			result, err := amesh.CreateAmeshImage(t.Context(), &amesh.CreateAmeshImageParams{
+				Client:      transport.Client(),
+				Lat:         35.6812,
+				Lng:         139.7671,
+				Zoom:        10,
+				AroundTiles: 1,
+			})
+			if err != nil {
+				t.Fatalf("CreateAmeshImage() error = %v", err)
+			}
+
+			if !result.RadarTime.Equal(tt.expectedRadarTime) {
+				t.Errorf("RadarTime = %v, want %v", result.RadarTime, tt.expectedRadarTime)
+			}
+			if result.LightningCount != tt.expectedLightning {
+				t.Errorf("LightningCount = %d, want %d", result.LightningCount, tt.expectedLightning)
+			}
+			if diff := cmp.Diff(tt.expectedProviders, result.Providers); diff != "" {
+				t.Errorf("Providers mismatch (-want +got):\n%s", diff)
+			}
+			if result.Tiles.Fetched+result.Tiles.Failed == 0 {
+				t.Errorf("Tiles = %+v, want some tiles", result.Tiles)
+			}
+			if bbox := result.BoundingBox; !(bbox.MinLat < 35.6812 && 35.6812 < bbox.MaxLat) {
+				t.Errorf("BoundingBox = %+v does not contain the center", bbox)
+			}
+		})
+	}
+}
+
+// abs 整数の絶対値を返す
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,193 @@
+package amesh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+// 自己診断の手順の名前
+const (
+	SelfTestGeocode     = "geocode"     // 地名の検索
+	SelfTestTargetTimes = "targettimes" // 気象庁のtargetTimesの取得
+	SelfTestTile        = "tile"        // タイル画像1枚のダウンロード
+	SelfTestRender      = "render"      // 小さなamesh画像の作成
+)
+
+// selfTestPlace 自己診断で探す地名
+const selfTestPlace = "東京"
+
+// selfTestZoom 自己診断で使うズームレベル
+const selfTestZoom = 10
+
+// selfTestFallback 地名の検索に失敗した場合に残りの手順で使う位置（東京駅）
+var selfTestFallback = Location{Lat: 35.681236, Lng: 139.767125, PlaceName: selfTestPlace}
+
+// SelfTestParams 自己診断のリクエスト構造体
+type SelfTestParams struct {
+	Client   httpclient.Doer // HTTPクライアント
+	Geocoder Geocoder        // 地名を探すジオコーダ（nilの場合はAPIキーがあればYahoo!ジオコーダ）
+	APIKey   string          // Yahoo!ジオコーダのAPIキー
+}
+
+// SelfTestStep 自己診断の手順ごとの結果
+type SelfTestStep struct {
+	Name    string        // 手順の名前
+	Detail  string        // 成功した場合の結果の説明
+	Err     error         // 失敗した場合のエラー（成功した場合はnil）
+	Elapsed time.Duration // 手順にかかった時間
+}
+
+// SelfTestResult 自己診断の結果
+type SelfTestResult struct {
+	Steps []SelfTestStep // 実行した順の手順の結果
+	Image []byte         // 作成したPNG形式の画像（作成に失敗した場合はnil）
+}
+
+// Failed 失敗した手順があるかを返す
+func (r *SelfTestResult) Failed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SelfTest amesh画像の作成に使う外部サービスを実際に呼び出し、手順ごとの成否を返す
+// 地名の検索に失敗しても東京駅の位置で残りの手順を続け、どこで失敗しているかを一度に確認できるようにする
+func SelfTest(ctx context.Context, params *SelfTestParams) (*SelfTestResult, error) {
+	if params == nil || params.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	result := &SelfTestResult{}
+	location := &selfTestFallback
+	result.run(SelfTestGeocode, func() (string, error) {
+		found, err := ProbeGeocoder(ctx, params)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to ProbeGeocoder")
+		}
+		location = found
+		return fmt.Sprintf("%s -> %s (%.4f, %.4f)", selfTestPlace, found.PlaceName, found.Lat, found.Lng), nil
+	})
+	result.run(SelfTestTargetTimes, func() (string, error) {
+		radarTime, err := ProbeTargetTimes(ctx, params.Client)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to ProbeTargetTimes")
+		}
+		return "radar " + radarTime.In(jst).Format("2006-01-02 15:04 MST"), nil
+	})
+	result.run(SelfTestTile, func() (string, error) {
+		viewport := &Viewport{Lat: location.Lat, Lng: location.Lng, Zoom: selfTestZoom}
+		tile := viewport.tiles()[0]
+		img, err := downloadTile(ctx, params.Client, osmTileURL(selfTestZoom, tile.X, tile.Y))
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to downloadTile")
+		}
+		return fmt.Sprintf("%d/%d/%d %dx%d", selfTestZoom, tile.X, tile.Y, img.Bounds().Dx(), img.Bounds().Dy()), nil
+	})
+	result.run(SelfTestRender, func() (string, error) {
+		rendered, err := CreateAmeshImage(ctx, &CreateAmeshImageParams{
+			Client: params.Client,
+			Lat:    location.Lat,
+			Lng:    location.Lng,
+			Zoom:   selfTestZoom,
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to CreateAmeshImage")
+		}
+		defer putCanvas(rendered.Image)
+
+		buf, err := encodePNG(rendered.Image, nil)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to encodePNG")
+		}
+		result.Image = buf.Bytes()
+		return fmt.Sprintf("%dx%d %d bytes, %d tiles fetched, %d failed (%s)",
+			rendered.Image.Bounds().Dx(), rendered.Image.Bounds().Dy(), buf.Len(),
+			rendered.Tiles.Fetched, rendered.Tiles.Failed, strings.Join(rendered.Providers, ", ")), nil
+	})
+	return result, nil
+}
+
+// ProbeGeocoder 自己診断の地名をジオコーダで検索する
+// 起動時などに地名の検索が使えるかを確認するために使う
+func ProbeGeocoder(ctx context.Context, params *SelfTestParams) (*Location, error) {
+	if params == nil || params.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+	found, err := ParseLocationWithClient(ctx, &ParseLocationParams{
+		Client:         params.Client,
+		GeocodeRequest: GeocodeRequest{Place: selfTestPlace, APIKey: params.APIKey},
+		Geocoder:       params.Geocoder,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ParseLocationWithClient")
+	}
+	return found, nil
+}
+
+// ProbeTargetTimes 気象庁のtargetTimesを全て取得し、最新のレーダーの時刻を返す
+// 1つでも取得に失敗した場合はエラーを返す
+func ProbeTargetTimes(ctx context.Context, client httpclient.Doer) (time.Time, error) {
+	if client == nil {
+		return time.Time{}, lib.ErrParamsNil
+	}
+	timestamps, err := LatestTimestampsWithClient(ctx, &LatestTimestampsParams{Client: client})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Failed to LatestTimestampsWithClient")
+	}
+	if 0 < len(timestamps.FailedSources) {
+		failed := timestamps.FailedSources[0]
+		return time.Time{}, errors.Wrapf(failed.Err, "%d of %d failed: %s", len(timestamps.FailedSources), len(targetTimesURLs), failed.URL)
+	}
+	radarTime := timestamps.BaseTime(ElementRadar)
+	if radarTime.IsZero() {
+		return time.Time{}, ErrNoRadarData
+	}
+	return radarTime, nil
+}
+
+// run 手順を実行して結果を記録する
+func (r *SelfTestResult) run(name string, step func() (string, error)) {
+	start := time.Now()
+	detail, err := step()
+	r.Steps = append(r.Steps, SelfTestStep{
+		Name:    name,
+		Detail:  detail,
+		Err:     err,
+		Elapsed: time.Since(start),
+	})
+}
+
+// RunSelfTest ParseLocationなどクライアント未指定の関数と同じHTTPクライアントとジオコーダで自己診断を行う
+func RunSelfTest(ctx context.Context, apiKey string) (*SelfTestResult, error) {
+	return SelfTest(ctx, &SelfTestParams{
+		Client:   defaultClient,
+		Geocoder: getDefaultGeocoder(),
+		APIKey:   apiKey,
+	})
+}
+
+// RunProbeGeocoder ParseLocationなどクライアント未指定の関数と同じHTTPクライアントとジオコーダで地名の検索を確認する
+func RunProbeGeocoder(ctx context.Context, apiKey string) error {
+	if _, err := ProbeGeocoder(ctx, &SelfTestParams{Client: defaultClient, Geocoder: getDefaultGeocoder(), APIKey: apiKey}); err != nil {
+		return errors.Wrap(err, "Failed to ProbeGeocoder")
+	}
+	return nil
+}
+
+// RunProbeTargetTimes amesh画像の作成と同じHTTPクライアントで気象庁のtargetTimesの取得を確認する
+func RunProbeTargetTimes(ctx context.Context) error {
+	if _, err := ProbeTargetTimes(ctx, defaultClient); err != nil {
+		return errors.Wrap(err, "Failed to ProbeTargetTimes")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package amesh_test
+
+import (
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestSelfTest(t *testing.T) {
+	tile, err := createDummyPNGBytes(256, 256, color.White)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileResponse := httpclient.MockResponse{StatusCode: http.StatusOK, Body: string(tile), Header: http.Header{"Content-Type": {"image/png"}}}
+	targetTimes := `[{"basetime":"20260101000000","validtime":"20260101000000","elements":["hrpns_nd"]}]`
+
+	tests := []struct {
+		name           string
+		geocoder       *fakeGeocoder
+		targetTimes    httpclient.MockResponse
+		expectedFailed []string
+		expectedPlace  string
+	}{
+		{
+			name:          "全ての手順が成功",
+			geocoder:      &fakeGeocoder{location: &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京都"}},
+			targetTimes:   httpclient.MockResponse{StatusCode: http.StatusOK, Body: targetTimes},
+			expectedPlace: "東京都",
+		},
+		{
+			name:           "地名の検索とtargetTimesが失敗しても残りの手順を続ける",
+			geocoder:       &fakeGeocoder{err: httpclient.ErrHTTPRequestError},
+			targetTimes:    httpclient.MockResponse{StatusCode: http.StatusInternalServerError},
+			expectedFailed: []string{amesh.SelfTestTargetTimes},
+			expectedPlace:  "東京都",
+		},
+		{
+			name:           "レーダーのタイムスタンプがない",
+			geocoder:       &fakeGeocoder{location: &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京都"}},
+			targetTimes:    httpclient.MockResponse{StatusCode: http.StatusOK, Body: `[]`},
+			expectedFailed: []string{amesh.SelfTestTargetTimes},
+			expectedPlace:  "東京都",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{tt.targetTimes}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{tileResponse}},
+				},
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusNotFound},
+			})
+
+			result, err := amesh.SelfTest(t.Context(), &amesh.SelfTestParams{
+				Client:   transport.Client(),
+				Geocoder: tt.geocoder,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names, failed []string
+			for _, step := range result.Steps {
+				names = append(names, step.Name)
+				if step.Err != nil {
+					failed = append(failed, step.Name)
+				}
+			}
+			expectedNames := []string{amesh.SelfTestGeocode, amesh.SelfTestTargetTimes, amesh.SelfTestTile, amesh.SelfTestRender}
+			if diff := cmp.Diff(expectedNames, names); diff != "" {
+				t.Errorf("steps mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedFailed, failed); diff != "" {
+				t.Errorf("failed steps mismatch (-want +got):\n%s", diff)
+			}
+			if result.Failed() != (0 < len(tt.expectedFailed)) {
+				t.Errorf("Failed() = %v", result.Failed())
+			}
+			if !strings.Contains(result.Steps[0].Detail, tt.expectedPlace) {
+				t.Errorf("geocode detail = %q, want %q", result.Steps[0].Detail, tt.expectedPlace)
+			}
+			if len(result.Image) == 0 {
+				t.Error("Image is empty")
+			}
+		})
+	}
+}
+
+func TestSelfTestParamsNil(t *testing.T) {
+	t.Parallel()
+	if _, err := amesh.SelfTest(t.Context(), &amesh.SelfTestParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("SelfTest() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
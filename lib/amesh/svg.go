@@ -0,0 +1,228 @@
+package amesh
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/font"
+	"hato-bot-go/lib/requestid"
+)
+
+// svgFontFamily SVGの文言に使うフォント
+// ラスター画像の埋め込みフォントと異なり、日本語も表示できる
+const svgFontFamily = "sans-serif"
+
+// VectorLayer SVGの要素として書き出せるレイヤー
+// CreateSVGはDrawの代わりにWriteSVGを呼び出し、ベクターでないレイヤーを描画したラスター画像の上に重ねる
+type VectorLayer interface {
+	Layer
+	// WriteSVG レイヤーをSVGのg要素として書き出し、描画範囲内の地点数（地点を描画しないレイヤーは0）を返す
+	WriteSVG(ctx context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error)
+}
+
+// SVGResult ベクターでないレイヤーを描画したラスター画像と、その上に重ねるベクターのレイヤー
+type SVGResult struct {
+	AmeshResult        // ベクターでないレイヤー（ベースマップ・雨雲レーダーなど）のみを描画したラスター画像
+	size        int    // 画像の1辺のピクセル数
+	layers      []byte // ベクターのレイヤー（距離円・マーカー・ラベル・落雷地点）のg要素
+}
+
+// SVG ラスター画像を参照するimage要素の上にベクターのレイヤーを重ねたSVGを返す
+// rasterHrefはラスター画像の保存先（SVGからの相対パスやURL、空の場合はラスター画像を参照しない）
+func (r *SVGResult) SVG(rasterHref string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		r.size, r.size, r.size, r.size)
+	if rasterHref != "" {
+		href := escapeXML(rasterHref)
+		// 古いビューアー向けにxlink:hrefも付ける
+		fmt.Fprintf(buf, `<image href="%s" xlink:href="%s" x="0" y="0" width="%d" height="%d"/>`+"\n", href, href, r.size, r.size)
+	}
+	buf.Write(r.layers)
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// CreateSVGForLocation 既定のHTTPクライアントとtargetTimesのキャッシュでCreateSVGを呼び出す
+func CreateSVGForLocation(ctx context.Context, location *Location, overlays []OverlayName) (*SVGResult, error) {
+	return CreateSVG(ctx, &LocationImageParams{
+		Client:         defaultClient,
+		Location:       location,
+		TimestampCache: defaultTimestampCache,
+		Overlays:       overlays,
+		StaleThreshold: getStaleThreshold(),
+		OverlayStyles:  getOverlayStyles(),
+		MapStyle:       getMapStyle(),
+	})
+}
+
+// CreateSVG amesh画像のうちベクターのレイヤーをSVGで書き出し、残りのレイヤーをラスター画像に描画する
+// ラスター画像を強く再圧縮するプラットフォームへの投稿や、距離円やラベルの見た目の変更に使う
+// SVGの各レイヤーはclass属性（amesh-circles・amesh-markers・amesh-lightning・amesh-banner・amesh-label）で区別できる
+func CreateSVG(ctx context.Context, params *LocationImageParams) (*SVGResult, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+	imageParams := locationImageParams(params)
+	if err := validateMapParams(imageParams); err != nil {
+		return nil, errors.Wrap(err, "Failed to validateMapParams")
+	}
+	layers, err := DefaultLayers(ctx, imageParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to DefaultLayers")
+	}
+
+	var rasterLayers []Layer
+	var vectorLayers []VectorLayer
+	for _, layer := range layers {
+		if vectorLayer, ok := layer.(VectorLayer); ok {
+			vectorLayers = append(vectorLayers, vectorLayer)
+			continue
+		}
+		rasterLayers = append(rasterLayers, layer)
+	}
+
+	viewport := &Viewport{
+		Lat:         imageParams.Lat,
+		Lng:         imageParams.Lng,
+		Zoom:        imageParams.Zoom,
+		AroundTiles: imageParams.AroundTiles,
+	}
+	rendered := RenderLayers(ctx, viewport, rasterLayers)
+	vector, points := writeSVGLayers(ctx, viewport, vectorLayers)
+	rendered.Points += points
+
+	result := &SVGResult{
+		AmeshResult: AmeshResult{
+			Image:         rendered.Image,
+			AmeshMetadata: rendered.metadata(layers),
+		},
+		size:   viewport.Size(),
+		layers: vector,
+	}
+	result.BoundingBox = viewport.BoundingBox()
+	result.Locations = []Location{*params.Location}
+	logTileStats(ctx, result.Tiles)
+	return result, nil
+}
+
+// writeSVGLayers レイヤーを順にSVGのg要素として書き出し、描画範囲内の地点数の合計を返す
+// 書き出しに失敗したレイヤーはRenderLayersと同じくログに出力して飛ばす
+func writeSVGLayers(ctx context.Context, viewport *Viewport, layers []VectorLayer) ([]byte, int) {
+	buf := &bytes.Buffer{}
+	total := 0
+	for _, layer := range layers {
+		layerBuf := &bytes.Buffer{}
+		points, err := layer.WriteSVG(ctx, layerBuf, viewport)
+		if err != nil {
+			requestid.Logf(ctx, "Failed to write layer %T as SVG: %v", layer, err)
+			continue
+		}
+		buf.Write(layerBuf.Bytes())
+		total += points
+	}
+	return buf.Bytes(), total
+}
+
+// WriteSVG マーカーをcircle要素として書き出し、中心が画像内にあるマーカーの数を返す
+func (l *MarkerLayer) WriteSVG(_ context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error) {
+	return l.writeSVG(buf, viewport, "amesh-markers"), nil
+}
+
+// writeSVG マーカーを指定したclass属性のg要素として書き出し、中心が画像内にあるマーカーの数を返す
+func (l *MarkerLayer) writeSVG(buf *bytes.Buffer, viewport *Viewport, class string) int {
+	bounds := image.Rect(0, 0, viewport.Size(), viewport.Size())
+	visible := 0
+	fmt.Fprintf(buf, `<g class="%s">`+"\n", class)
+	for _, marker := range l.Markers {
+		center := viewport.ImagePoint(marker.Lat, marker.Lng)
+		if center.In(bounds) {
+			visible++
+		}
+		fmt.Fprintf(buf, `<circle cx="%d" cy="%d" r="%d" %s/>`+"\n", center.X, center.Y, marker.Radius, svgPaint("fill", marker.Color))
+	}
+	buf.WriteString("</g>\n")
+	return visible
+}
+
+// WriteSVG 落雷データを取得して落雷地点をcircle要素として書き出し、描画範囲内の落雷地点数を返す
+func (l *LightningLayer) WriteSVG(ctx context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error) {
+	lightningData, err := getLightningData(ctx, l.Client, l.Timestamp)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to getLightningData")
+	}
+	return (&MarkerLayer{Markers: lightningMarkers(lightningData)}).writeSVG(buf, viewport, "amesh-lightning"), nil
+}
+
+// WriteSVG 距離円をpolygon要素として書き出す
+func (l *CircleLayer) WriteSVG(_ context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error) {
+	fmt.Fprintf(buf, `<g class="amesh-circles" fill="none" %s>`+"\n", svgPaint("stroke", l.Color))
+	for _, radiusKm := range l.RadiiKm {
+		points := circlePoints(viewport, radiusKm)
+		coordinates := make([]string, 0, len(points)-1)
+		// 始点と終点は同じ点のため、閉じた図形のpolygon要素では終点を省く
+		for _, p := range points[:len(points)-1] {
+			coordinates = append(coordinates, fmt.Sprintf("%d,%d", p.X, p.Y))
+		}
+		fmt.Fprintf(buf, `<polygon data-radius-km="%g" points="%s"/>`+"\n", radiusKm, strings.Join(coordinates, " "))
+	}
+	buf.WriteString("</g>\n")
+	return 0, nil
+}
+
+// WriteSVG バナーを画像上部のrect要素とtext要素として書き出す
+func (l *BannerLayer) WriteSVG(_ context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error) {
+	banner := bannerRect(image.Rect(0, 0, viewport.Size(), viewport.Size()))
+	buf.WriteString(`<g class="amesh-banner">` + "\n")
+	writeSVGRect(buf, banner, l.color())
+	fmt.Fprintf(buf, `<text x="%d" y="%d" font-family="%s" font-size="%d" text-anchor="middle" dominant-baseline="central" fill="#ffffff">%s</text>`+"\n",
+		(banner.Min.X+banner.Max.X)/2, (banner.Min.Y+banner.Max.Y)/2, svgFontFamily, svgFontSize(), escapeXML(l.Text))
+	buf.WriteString("</g>\n")
+	return 0, nil
+}
+
+// WriteSVG ラベルを画像左下のrect要素とtext要素として書き出す
+func (l *LabelLayer) WriteSVG(_ context.Context, buf *bytes.Buffer, viewport *Viewport) (int, error) {
+	label := labelRect(image.Rect(0, 0, viewport.Size(), viewport.Size()), l.Text)
+	buf.WriteString(`<g class="amesh-label">` + "\n")
+	writeSVGRect(buf, label, labelColor)
+	fmt.Fprintf(buf, `<text x="%d" y="%d" font-family="%s" font-size="%d" dominant-baseline="central" fill="#ffffff">%s</text>`+"\n",
+		label.Min.X+labelPadding, (label.Min.Y+label.Max.Y)/2, svgFontFamily, svgFontSize(), escapeXML(l.Text))
+	buf.WriteString("</g>\n")
+	return 0, nil
+}
+
+// writeSVGRect 塗りつぶした長方形をrect要素として書き出す
+func writeSVGRect(buf *bytes.Buffer, rect image.Rectangle, c color.RGBA) {
+	fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" %s/>`+"\n", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), svgPaint("fill", c))
+}
+
+// svgFontSize ラスター画像の埋め込みフォントと同じ高さになるSVGの文字の大きさを返す
+func svgFontSize() int {
+	return font.MeasureText("0", textScale).Y
+}
+
+// svgPaint 色を塗りの属性（fillまたはstroke）と不透明度の属性として返す
+func svgPaint(attr string, c color.RGBA) string {
+	paint := fmt.Sprintf(`%s="#%02x%02x%02x"`, attr, c.R, c.G, c.B)
+	if c.A != 0xff {
+		paint += fmt.Sprintf(` %s-opacity="%.3g"`, attr, float64(c.A)/0xff)
+	}
+	return paint
+}
+
+// escapeXML 文字列をXMLの属性値・文字データとして書き出せるようにエスケープする
+func escapeXML(s string) string {
+	var buf strings.Builder
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,166 @@
+package amesh_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+// svgSummary SVGの検証に使う要素
+type svgSummary struct {
+	Width   string   // svg要素のwidth属性
+	Href    string   // image要素のhref属性
+	Classes []string // g要素のclass属性（出現順）
+	Circles int      // 落雷地点のcircle要素の数
+	Texts   []string // text要素の文字データ
+}
+
+// summarizeSVG SVGをXMLとして解析して検証に使う要素を取り出す
+func summarizeSVG(t *testing.T, svg []byte) *svgSummary {
+	t.Helper()
+	summary := &svgSummary{}
+	decoder := xml.NewDecoder(bytes.NewReader(svg))
+	group := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return summary
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, svg)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			attrs := map[string]string{}
+			for _, attr := range element.Attr {
+				if attr.Name.Space == "" {
+					attrs[attr.Name.Local] = attr.Value
+				}
+			}
+			switch element.Name.Local {
+			case "svg":
+				summary.Width = attrs["width"]
+			case "image":
+				summary.Href = attrs["href"]
+			case "g":
+				group = attrs["class"]
+				summary.Classes = append(summary.Classes, group)
+			case "circle":
+				if group == "amesh-lightning" {
+					summary.Circles++
+				}
+			case "text":
+				var text string
+				if err := decoder.DecodeElement(&text, &element); err != nil {
+					t.Fatalf("invalid text element: %v", err)
+				}
+				summary.Texts = append(summary.Texts, text)
+			}
+		}
+	}
+}
+
+func TestCreateSVG(t *testing.T) {
+	dummyTileBytes, err := createDummyPNGBytes(256, 256, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		timestamps        string
+		rasterHref        string
+		expected          *svgSummary
+		expectedLightning int
+	}{
+		{
+			name: "雨雲レーダーと落雷",
+			timestamps: `[
+				{"basetime": "20240101030500", "validtime": "20240101030500", "elements": ["hrpns_nd", "liden"]}
+			]`,
+			rasterHref: "amesh_東京.png",
+			expected: &svgSummary{
+				Width:   "1280",
+				Href:    "amesh_東京.png",
+				Classes: []string{"amesh-circles", "amesh-lightning"},
+				// 描画範囲外の落雷地点もSVGには含め、表示範囲で切り取る
+				Circles: 2,
+			},
+			expectedLightning: 1,
+		},
+		{
+			name:       "レーダーデータなし",
+			timestamps: `[]`,
+			rasterHref: "a&b.png",
+			expected: &svgSummary{
+				Width:   "1280",
+				Href:    "a&b.png",
+				Classes: []string{"amesh-circles", "amesh-banner"},
+				Texts:   []string{"NO RADAR DATA"},
+			},
+		},
+		{
+			name:       "ラスター画像を参照しない",
+			timestamps: `[]`,
+			expected: &svgSummary{
+				Width:   "1280",
+				Classes: []string{"amesh-circles", "amesh-banner"},
+				Texts:   []string{"NO RADAR DATA"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: tt.timestamps}}},
+					{Pattern: "liden/data.geojson", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"features": [
+						{"geometry": {"coordinates": [139.7, 35.7]}, "properties": {"type": 1}},
+						{"geometry": {"coordinates": [130.4, 33.6]}, "properties": {"type": 1}}
+					]}`}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(dummyTileBytes)}}},
+				},
+			})
+
+			result, err := amesh.CreateSVG(t.Context(), &amesh.LocationImageParams{
+				Client:   transport.Client(),
+				Location: &amesh.Location{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"},
+				Zoom:     10,
+			})
+			if err != nil {
+				t.Fatalf("CreateSVG() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.expected, summarizeSVG(t, result.SVG(tt.rasterHref))); diff != "" {
+				t.Errorf("SVG() mismatch (-want +got):\n%s", diff)
+			}
+			if result.LightningCount != tt.expectedLightning {
+				t.Errorf("LightningCount = %d, want %d", result.LightningCount, tt.expectedLightning)
+			}
+			if got := result.Image.Bounds().Dx(); got != 1280 {
+				t.Errorf("raster width = %d, want 1280", got)
+			}
+			if diff := cmp.Diff([]amesh.Location{{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京"}}, result.Locations); diff != "" {
+				t.Errorf("Locations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCreateSVGParamsNil(t *testing.T) {
+	if _, err := amesh.CreateSVG(t.Context(), &amesh.LocationImageParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("CreateSVG() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
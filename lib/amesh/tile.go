@@ -0,0 +1,85 @@
+package amesh
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"mime"
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// ErrInvalidTile タイルサーバーがタイル画像として扱えないレスポンスを返したことを表すエラー
+var ErrInvalidTile = errors.New("invalid tile image")
+
+// タイル画像の制限
+// 不調な（または乗っ取られた）タイルサーバーが巨大な画像を返しても、メモリを使い果たさないようにする
+const (
+	maxTileBytes     int64 = 2 << 20 // レスポンスボディの上限（2MiB）
+	maxTileDimension       = 1024    // デコードする画像の幅・高さの上限（通常のタイルは256x256）
+	tileContentType        = "image/png"
+)
+
+// downloadTile マップタイルをダウンロードする
+// PNG以外のレスポンスや、上限を超える大きさのレスポンス・画像はErrInvalidTileとしてデコードしない
+func downloadTile(ctx context.Context, client httpclient.Doer, tileURL string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tileURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+
+	resp, err := httpclient.ExecuteHTTPRequest(client, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ExecuteHTTPRequest")
+	}
+	header := resp.Header
+
+	// レスポンスは使い回すバッファに読み込んでからデコードする
+	buf := tileBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer tileBufferPool.Put(buf)
+	if _, err := httpclient.CopyResponseBody(buf, resp, maxTileBytes); err != nil {
+		if errors.Is(err, httpclient.ErrResponseTooLarge) {
+			return nil, errors.Wrap(ErrInvalidTile, err.Error())
+		}
+		return nil, errors.Wrap(err, "Failed to httpclient.CopyResponseBody")
+	}
+
+	if err := validateTile(header, buf.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "Failed to validateTile")
+	}
+
+	img, _, err := image.Decode(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to image.Decode")
+	}
+	return img, nil
+}
+
+// validateTile タイル画像のContent-Typeと画像の大きさを確認する
+// Content-Typeがない場合は内容から判定し、画像の大きさはヘッダーだけを読んで確認する（デコード爆弾の対策）
+func validateTile(header http.Header, body []byte) error {
+	contentType := http.DetectContentType(body)
+	if value := header.Get("Content-Type"); value != "" {
+		contentType = value
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != tileContentType {
+		return errors.Wrapf(ErrInvalidTile, "Content-Type: %s", contentType)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(ErrInvalidTile, err.Error())
+	}
+	if format != "png" {
+		return errors.Wrapf(ErrInvalidTile, "format: %s", format)
+	}
+	if config.Width <= 0 || config.Height <= 0 || maxTileDimension < config.Width || maxTileDimension < config.Height {
+		return errors.Wrapf(ErrInvalidTile, "size: %dx%d", config.Width, config.Height)
+	}
+	return nil
+}
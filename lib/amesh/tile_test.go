@@ -0,0 +1,85 @@
+package amesh
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// encodeTestPNG 指定の大きさのPNG画像を作成する
+func encodeTestPNG(t *testing.T, width, height int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDownloadTile(t *testing.T) {
+	tile := encodeTestPNG(t, 256, 256)
+	tests := []struct {
+		name          string
+		response      httpclient.MockResponse
+		expectedError error
+	}{
+		{
+			name:     "PNGのタイル",
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: tile, Header: http.Header{"Content-Type": {"image/png"}}},
+		},
+		{
+			name:     "Content-Typeのパラメータは無視する",
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: tile, Header: http.Header{"Content-Type": {"image/png; charset=binary"}}},
+		},
+		{
+			name:     "Content-Typeがない場合は内容から判定する",
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: tile},
+		},
+		{
+			name:          "画像以外のContent-Type",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: tile, Header: http.Header{"Content-Type": {"text/html"}}},
+			expectedError: ErrInvalidTile,
+		},
+		{
+			name:          "Content-Typeがなく内容も画像でない",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: "<html></html>"},
+			expectedError: ErrInvalidTile,
+		},
+		{
+			name:          "上限を超える大きさのレスポンス",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: tile + strings.Repeat("x", int(maxTileBytes))},
+			expectedError: ErrInvalidTile,
+		},
+		{
+			name:          "上限を超える大きさの画像",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: encodeTestPNG(t, maxTileDimension+1, 1)},
+			expectedError: ErrInvalidTile,
+		},
+		{
+			name:          "PNGとして壊れている",
+			response:      httpclient.MockResponse{StatusCode: http.StatusOK, Body: tile[:32], Header: http.Header{"Content-Type": {"image/png"}}},
+			expectedError: ErrInvalidTile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Fallback: tt.response})
+			img, err := downloadTile(t.Context(), transport.Client(), "https://tile.example.com/1/1/1.png")
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("downloadTile() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError == nil && img.Bounds().Dx() != 256 {
+				t.Errorf("downloadTile() bounds = %v", img.Bounds())
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package amesh
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sync/errgroup"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/metrics"
+)
+
+// targetTimesURLs 気象庁ナウキャストのタイムスタンプ一覧のURL
+var targetTimesURLs = []string{
+	"https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N1.json",
+	"https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N2.json",
+	"https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N3.json",
+}
+
+// targetTimesの要素名
+const (
+	ElementRadar         = "hrpns_nd" // 雨雲レーダーの解析値
+	ElementRadarForecast = "hrpns"    // 雨雲レーダーの予測
+	ElementLightning     = "liden"    // 落雷
+)
+
+// timeJSONElement targetTimes JSON要素の構造体
+type timeJSONElement struct {
+	BaseTime  string   `json:"basetime"`
+	ValidTime string   `json:"validtime"`
+	Elements  []string `json:"elements"`
+}
+
+// TimestampSourceError 取得に失敗したtargetTimesのURLとエラー
+type TimestampSourceError struct {
+	URL string // 取得に失敗したURL
+	Err error  // 失敗の原因
+}
+
+// ForecastTime 予測のタイルの基準時刻と対象時刻
+type ForecastTime struct {
+	BaseTime  time.Time // 予測の基準時刻（targetTimesのbasetime）
+	ValidTime time.Time // 予測の対象時刻（targetTimesのvalidtime、basetimeより後）
+}
+
+// ElementTimestamps 要素ごとのタイムスタンプ
+type ElementTimestamps struct {
+	BaseTime     time.Time      // 最新の解析値（basetimeとvalidtimeが同じもの）のbasetime（解析値がない場合はゼロ値）
+	Observations []time.Time    // 過去のものを含む全ての解析値のbasetime（targetTimesの順）
+	Forecasts    []ForecastTime // 予測（validtimeがbasetimeより後のもの、targetTimesの順）
+}
+
+// LatestTimestampsParams 最新のタイムスタンプ取得のリクエスト構造体
+type LatestTimestampsParams struct {
+	Client         httpclient.Doer           // HTTPクライアント
+	TimestampCache *httpclient.ResponseCache // targetTimesのキャッシュ（nilの場合はキャッシュしない）
+}
+
+// LatestTimestampsResult 最新タイムスタンプの取得結果
+type LatestTimestampsResult struct {
+	Elements      map[string]ElementTimestamps // 要素名（ElementRadarなど）ごとのタイムスタンプ
+	FailedSources []TimestampSourceError       // 取得に失敗したURL（取得できた分の結果はElementsに含まれる）
+}
+
+// BaseTime 要素の最新の解析値のbasetimeを返す（解析値がない場合はゼロ値）
+func (r *LatestTimestampsResult) BaseTime(element string) time.Time {
+	return r.Elements[element].BaseTime
+}
+
+// ValidTimes 要素の予測のうち、基準時刻がbaseTimeのものの対象時刻を昇順で返す
+// 複数のtargetTimesに同じ予測が含まれる場合も1つにまとめる
+func (r *LatestTimestampsResult) ValidTimes(element string, baseTime time.Time) []time.Time {
+	var validTimes []time.Time
+	for _, forecast := range r.Elements[element].Forecasts {
+		if forecast.BaseTime.Equal(baseTime) {
+			validTimes = append(validTimes, forecast.ValidTime)
+		}
+	}
+	slices.SortFunc(validTimes, time.Time.Compare)
+	return slices.CompactFunc(validTimes, time.Time.Equal)
+}
+
+// ObservedTimes 要素の過去のものを含む全ての解析値のbasetimeを昇順で返す
+// 複数のtargetTimesに同じ解析値が含まれる場合も1つにまとめる
+func (r *LatestTimestampsResult) ObservedTimes(element string) []time.Time {
+	observedTimes := slices.Clone(r.Elements[element].Observations)
+	slices.SortFunc(observedTimes, time.Time.Compare)
+	return slices.CompactFunc(observedTimes, time.Time.Equal)
+}
+
+// add targetTimesの内容を結果に加える
+// 基準時刻と対象時刻が同じ解析値は全てObservationsに集めて要素ごとに最新のbasetimeを選び、対象時刻が後の予測は全てForecastsに集める
+func (r *LatestTimestampsResult) add(timeData []timeJSONElement) {
+	for _, td := range timeData {
+		baseTime := parseJMATimestamp(td.BaseTime)
+		validTime := parseJMATimestamp(td.ValidTime)
+		if baseTime.IsZero() || validTime.IsZero() || validTime.Before(baseTime) {
+			continue
+		}
+		for _, element := range td.Elements {
+			timestamps := r.Elements[element]
+			if baseTime.Before(validTime) {
+				timestamps.Forecasts = append(timestamps.Forecasts, ForecastTime{BaseTime: baseTime, ValidTime: validTime})
+			} else {
+				timestamps.Observations = append(timestamps.Observations, baseTime)
+				if timestamps.BaseTime.Before(baseTime) {
+					timestamps.BaseTime = baseTime
+				}
+			}
+			r.Elements[element] = timestamps
+		}
+	}
+}
+
+// timestampsParams 画像作成のパラメータからタイムスタンプ取得のパラメータを作成する
+func (params *CreateAmeshImageParams) timestampsParams() *LatestTimestampsParams {
+	return &LatestTimestampsParams{
+		Client:         params.Client,
+		TimestampCache: params.TimestampCache,
+	}
+}
+
+// fetchTimeData タイムデータを取得する
+func fetchTimeData(ctx context.Context, params *LatestTimestampsParams, apiURL string) ([]timeJSONElement, error) {
+	body, err := params.TimestampCache.Get(ctx, params.Client, apiURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to TimestampCache.Get")
+	}
+
+	var timeData []timeJSONElement
+	if err := json.Unmarshal(body, &timeData); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+
+	return timeData, nil
+}
+
+// LatestTimestamps 既定のHTTPクライアントとtargetTimesのキャッシュでLatestTimestampsWithClientを呼び出す
+func LatestTimestamps(ctx context.Context) (*LatestTimestampsResult, error) {
+	return LatestTimestampsWithClient(ctx, &LatestTimestampsParams{
+		Client:         defaultClient,
+		TimestampCache: defaultTimestampCache,
+	})
+}
+
+// LatestTimestampsWithClient HTTPクライアントを指定して気象庁ナウキャストの最新のタイムスタンプを取得する
+// 各URLは並行して取得し、一部または全てが失敗しても取得できた分の結果を返す（失敗したURLはFailedSourcesに含まれる）
+func LatestTimestampsWithClient(ctx context.Context, params *LatestTimestampsParams) (*LatestTimestampsResult, error) {
+	if params == nil || params.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	timeDataList := make([][]timeJSONElement, len(targetTimesURLs))
+	errs := make([]error, len(targetTimesURLs))
+
+	var g errgroup.Group
+	for i, apiURL := range targetTimesURLs {
+		g.Go(func() error {
+			// 失敗は他のURLの取得を止めないよう、エラーとして返さずに記録する
+			timeDataList[i], errs[i] = fetchTimeData(ctx, params, apiURL)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	result := &LatestTimestampsResult{Elements: make(map[string]ElementTimestamps)}
+	for i, apiURL := range targetTimesURLs {
+		if errs[i] != nil {
+			metrics.Default.Counter("amesh.targettimes.failures").Inc()
+			result.FailedSources = append(result.FailedSources, TimestampSourceError{
+				URL: apiURL,
+				Err: errs[i],
+			})
+			continue
+		}
+		result.add(timeDataList[i])
+	}
+
+	return result, nil
+}
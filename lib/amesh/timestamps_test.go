@@ -0,0 +1,232 @@
+package amesh
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestLatestTimestampsWithClient(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		routes           []httpclient.MockRoute
+		expectElements   map[string]ElementTimestamps
+		expectFailedURLs []string
+	}{
+		{
+			name: "すべて成功し要素ごとに最新のbasetimeを選ぶ",
+			routes: []httpclient.MockRoute{
+				{Pattern: "targetTimes_N1", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+					{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["hrpns_nd", "liden"]},
+					{"basetime": "20240101120000", "validtime": "20240101121000", "elements": ["hrpns"]}
+				]`}}},
+				{Pattern: "targetTimes_N2", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+					{"basetime": "20240101120500", "validtime": "20240101120500", "elements": ["hrpns_nd"]}
+				]`}}},
+				{Pattern: "targetTimes_N3", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[]`}}},
+			},
+			expectElements: map[string]ElementTimestamps{
+				ElementRadar:     {BaseTime: base.Add(5 * time.Minute), Observations: []time.Time{base, base.Add(5 * time.Minute)}},
+				ElementLightning: {BaseTime: base, Observations: []time.Time{base}},
+				// 予測は解析値の最新のbasetimeに含めない
+				ElementRadarForecast: {Forecasts: []ForecastTime{{BaseTime: base, ValidTime: base.Add(10 * time.Minute)}}},
+			},
+			expectFailedURLs: nil,
+		},
+		{
+			name: "解析できない時刻と基準時刻より前の対象時刻は無視する",
+			routes: []httpclient.MockRoute{
+				{Pattern: "targetTimes_N1", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+					{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["hrpns_nd"]},
+					{"basetime": "invalid", "validtime": "invalid", "elements": ["hrpns_nd"]},
+					{"basetime": "20240101121000", "validtime": "20240101120000", "elements": ["hrpns_nd"]}
+				]`}}},
+				{Pattern: "targetTimes_N2", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[]`}}},
+				{Pattern: "targetTimes_N3", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[]`}}},
+			},
+			expectElements:   map[string]ElementTimestamps{ElementRadar: {BaseTime: base, Observations: []time.Time{base}}},
+			expectFailedURLs: nil,
+		},
+		{
+			name: "一部の失敗は取得できた分の結果と失敗したURLを返す",
+			routes: []httpclient.MockRoute{
+				{Pattern: "targetTimes_N1", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+					{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["hrpns_nd"]}
+				]`}}},
+				{Pattern: "targetTimes_N2", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError}}},
+				{Pattern: "targetTimes_N3", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `invalid json`}}},
+			},
+			expectElements:   map[string]ElementTimestamps{ElementRadar: {BaseTime: base, Observations: []time.Time{base}}},
+			expectFailedURLs: []string{targetTimesURLs[1], targetTimesURLs[2]},
+		},
+		{
+			name:             "すべて失敗",
+			routes:           nil,
+			expectElements:   map[string]ElementTimestamps{},
+			expectFailedURLs: targetTimesURLs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Routes: tt.routes})
+			result, err := LatestTimestampsWithClient(t.Context(), &LatestTimestampsParams{Client: transport.Client()})
+			if err != nil {
+				t.Fatalf("LatestTimestampsWithClient() error = %v", err)
+			}
+
+			if diff := cmp.Diff(result.Elements, tt.expectElements); diff != "" {
+				t.Errorf("LatestTimestampsWithClient() Elements diff: %s", diff)
+			}
+
+			var failedURLs []string
+			for _, failed := range result.FailedSources {
+				if failed.Err == nil {
+					t.Errorf("FailedSources %s has nil Err", failed.URL)
+				}
+				failedURLs = append(failedURLs, failed.URL)
+			}
+			if diff := cmp.Diff(failedURLs, tt.expectFailedURLs); diff != "" {
+				t.Errorf("LatestTimestampsWithClient() FailedSources diff: %s", diff)
+			}
+
+			if called := len(transport.RequestsTo("targetTimes")); called != len(targetTimesURLs) {
+				t.Errorf("targetTimes requested %d times, want %d", called, len(targetTimesURLs))
+			}
+		})
+	}
+}
+
+// TestLatestTimestampsWithClientCache キャッシュを指定した場合、TTL内はtargetTimesを取得し直さないことをテストする
+func TestLatestTimestampsWithClientCache(t *testing.T) {
+	t.Parallel()
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Routes: []httpclient.MockRoute{
+		{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+			{"basetime": "20240101120000", "validtime": "20240101120000", "elements": ["hrpns_nd"]}
+		]`}}},
+	}})
+	params := &LatestTimestampsParams{
+		Client:         transport.Client(),
+		TimestampCache: httpclient.NewResponseCache(&httpclient.ResponseCacheSetting{TTL: time.Minute}),
+	}
+
+	for range 2 {
+		result, err := LatestTimestampsWithClient(t.Context(), params)
+		if err != nil {
+			t.Fatalf("LatestTimestampsWithClient() error = %v", err)
+		}
+		if expected := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC); !result.BaseTime(ElementRadar).Equal(expected) {
+			t.Errorf("BaseTime(%s) = %v, want %v", ElementRadar, result.BaseTime(ElementRadar), expected)
+		}
+	}
+	if called := len(transport.RequestsTo("targetTimes")); called != len(targetTimesURLs) {
+		t.Errorf("targetTimes requested %d times, want %d", called, len(targetTimesURLs))
+	}
+}
+
+func TestLatestTimestampsWithClientParamsNil(t *testing.T) {
+	if _, err := LatestTimestampsWithClient(t.Context(), &LatestTimestampsParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("LatestTimestampsWithClient() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
+
+func TestLatestTimestampsResultValidTimes(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	result := &LatestTimestampsResult{Elements: map[string]ElementTimestamps{
+		ElementRadarForecast: {Forecasts: []ForecastTime{
+			{BaseTime: base, ValidTime: base.Add(10 * time.Minute)},
+			{BaseTime: base, ValidTime: base.Add(5 * time.Minute)},
+			{BaseTime: base.Add(-5 * time.Minute), ValidTime: base.Add(5 * time.Minute)},
+			// 別のtargetTimesに含まれる同じ予測
+			{BaseTime: base, ValidTime: base.Add(10 * time.Minute)},
+		}},
+	}}
+
+	tests := []struct {
+		name     string
+		element  string
+		baseTime time.Time
+		expected []time.Time
+	}{
+		{
+			name:     "基準時刻が同じ予測を昇順に重複なく返す",
+			element:  ElementRadarForecast,
+			baseTime: base,
+			expected: []time.Time{base.Add(5 * time.Minute), base.Add(10 * time.Minute)},
+		},
+		{
+			name:     "古い基準時刻の予測",
+			element:  ElementRadarForecast,
+			baseTime: base.Add(-5 * time.Minute),
+			expected: []time.Time{base.Add(5 * time.Minute)},
+		},
+		{
+			name:     "予測のない基準時刻",
+			element:  ElementRadarForecast,
+			baseTime: base.Add(5 * time.Minute),
+			expected: nil,
+		},
+		{
+			name:     "存在しない要素",
+			element:  ElementLightning,
+			baseTime: base,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, result.ValidTimes(tt.element, tt.baseTime)); diff != "" {
+				t.Errorf("ValidTimes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLatestTimestampsResultObservedTimes(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	result := &LatestTimestampsResult{Elements: map[string]ElementTimestamps{
+		ElementRadar: {BaseTime: base, Observations: []time.Time{
+			base,
+			base.Add(-10 * time.Minute),
+			base.Add(-5 * time.Minute),
+			// 別のtargetTimesに含まれる同じ解析値
+			base,
+		}},
+	}}
+
+	tests := []struct {
+		name     string
+		element  string
+		expected []time.Time
+	}{
+		{
+			name:     "解析値を昇順に重複なく返す",
+			element:  ElementRadar,
+			expected: []time.Time{base.Add(-10 * time.Minute), base.Add(-5 * time.Minute), base},
+		},
+		{
+			name:     "存在しない要素",
+			element:  ElementLightning,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, result.ObservedTimes(tt.element)); diff != "" {
+				t.Errorf("ObservedTimes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
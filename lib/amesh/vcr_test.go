@@ -0,0 +1,80 @@
+package amesh_test
+
+import (
+	"context"
+	"image/png"
+	"os"
+	"slices"
+	"testing"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+)
+
+// vcrFixture 東京駅周辺のamesh画像を作成したときの外部サービスのレスポンスの記録
+// HATO_VCR_RECORD=1 YAHOO_API_TOKEN=... go test ./lib/amesh/ -run TestAmeshPipelineVCR で記録し直す
+const vcrFixture = "testdata/vcr/amesh_tokyo.json"
+
+// TestAmeshPipelineVCR 記録したYahoo!ジオコーダ・気象庁・OpenStreetMapのレスポンスで、地名の検索から画像の作成までを通して確認する
+func TestAmeshPipelineVCR(t *testing.T) {
+	t.Parallel()
+
+	vcr, err := httpclient.NewVCRTransport(&httpclient.VCRSetting{Path: vcrFixture})
+	if err != nil {
+		t.Fatalf("NewVCRTransport() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := vcr.Save(); err != nil {
+			t.Errorf("Save() error = %v", err)
+		}
+	})
+	client := vcr.Client()
+
+	// 再生時のAPIキーは記録から取り除かれているため任意の値でよい
+	apiKey := os.Getenv("YAHOO_API_TOKEN")
+	if apiKey == "" {
+		apiKey = "dummy"
+	}
+	ctx := context.Background()
+	location, err := amesh.ParseLocationWithClient(ctx, &amesh.ParseLocationParams{
+		Client:         client,
+		GeocodeRequest: amesh.GeocodeRequest{Place: "東京駅", APIKey: apiKey},
+	})
+	if err != nil {
+		t.Fatalf("ParseLocationWithClient() error = %v", err)
+	}
+	if location.PlaceName == "" || location.Lat < 35 || 36 < location.Lat || location.Lng < 139 || 140 < location.Lng {
+		t.Errorf("ParseLocationWithClient() = %+v, want a location in Tokyo", location)
+	}
+
+	result, err := amesh.CreateAmeshImage(ctx, &amesh.CreateAmeshImageParams{
+		Client: client,
+		Lat:    location.Lat,
+		Lng:    location.Lng,
+		Zoom:   10,
+	})
+	if err != nil {
+		t.Fatalf("CreateAmeshImage() error = %v", err)
+	}
+	if result.RadarTime.IsZero() {
+		t.Error("RadarTime is zero, want the recorded radar basetime")
+	}
+	if result.Tiles.Failed != 0 || result.Tiles.Fetched == 0 {
+		t.Errorf("Tiles = %+v, want all tiles fetched", result.Tiles)
+	}
+	for _, provider := range []string{amesh.ProviderOpenStreetMap, amesh.ProviderJMA} {
+		if !slices.Contains(result.Providers, provider) {
+			t.Errorf("Providers = %v, want %s", result.Providers, provider)
+		}
+	}
+
+	reader := result.Reader()
+	defer reader.Close()
+	config, err := png.DecodeConfig(reader)
+	if err != nil {
+		t.Fatalf("png.DecodeConfig() error = %v", err)
+	}
+	if config.Width != 256 || config.Height != 256 {
+		t.Errorf("png size = %dx%d, want 256x256", config.Width, config.Height)
+	}
+}
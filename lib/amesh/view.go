@@ -0,0 +1,153 @@
+package amesh
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// 地図の表示範囲の自動選択に使う定数
+const (
+	defaultViewZoom        = 10 // 範囲がわからない場合のズームレベル
+	defaultViewAroundTiles = 2  // 範囲がわからない場合の周囲のタイル数
+	minAutoZoom            = 5  // 自動選択で使う最小のズームレベル（日本全体程度）
+	maxAutoZoom            = 15 // 自動選択で使う最大のズームレベル（駅周辺程度）
+	closeUpZoom            = 13 // このズームレベル以上では周囲のタイル数を減らす
+)
+
+// addressLevelZooms Yahoo!ジオコーダのAddressMatchingLevelごとのズームレベル
+// 1: 都道府県、2: 市区町村、3: 町・大字、4: 丁目、5: 番地、6: 号
+var addressLevelZooms = map[AddressLevel]int{
+	1: 7,
+	2: 10,
+	3: 12,
+	4: 13,
+	5: 14,
+	6: 15,
+}
+
+// AddressLevel Yahoo!ジオコーダの住所のマッチングレベル（0の場合は不明）
+type AddressLevel int
+
+// UnmarshalJSON 文字列と数値のどちらの形式のマッチングレベルも受け付ける
+func (l *AddressLevel) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*l = 0
+		return nil
+	}
+
+	level, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.Wrap(err, "Failed to strconv.Atoi")
+	}
+	*l = AddressLevel(level)
+	return nil
+}
+
+// BoundingBox 地名が表す範囲
+type BoundingBox struct {
+	MinLat float64 // 南端の緯度
+	MinLng float64 // 西端の経度
+	MaxLat float64 // 北端の緯度
+	MaxLng float64 // 東端の経度
+}
+
+// union 2つの範囲を含む範囲を返す（otherがnilの場合は自身を返す）
+func (b BoundingBox) union(other *BoundingBox) *BoundingBox {
+	if other == nil {
+		return &b
+	}
+	return &BoundingBox{
+		MinLat: min(b.MinLat, other.MinLat),
+		MinLng: min(b.MinLng, other.MinLng),
+		MaxLat: max(b.MaxLat, other.MaxLat),
+		MaxLng: max(b.MaxLng, other.MaxLng),
+	}
+}
+
+// MapView 地図の表示範囲
+type MapView struct {
+	Zoom        int // ズームレベル
+	AroundTiles int // 周囲のタイル数
+}
+
+// parseBoundingBox Yahoo!ジオコーダのBoundingBox（"経度,緯度 経度,緯度"）を解析する
+// 解析できない場合はnilを返す
+func parseBoundingBox(s string) *BoundingBox {
+	corners := strings.Fields(s)
+	if len(corners) != 2 {
+		return nil
+	}
+
+	var values []float64
+	for _, corner := range corners {
+		for v := range strings.SplitSeq(corner, ",") {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil
+			}
+			values = append(values, f)
+		}
+	}
+	if len(values) != 4 {
+		return nil
+	}
+
+	return &BoundingBox{
+		MinLng: min(values[0], values[2]),
+		MinLat: min(values[1], values[3]),
+		MaxLng: max(values[0], values[2]),
+		MaxLat: max(values[1], values[3]),
+	}
+}
+
+// SelectMapView 位置情報に合わせて地図の表示範囲を選択する
+// 範囲がわかる場合は中心から範囲全体が収まる最大のズームレベル、
+// マッチングレベルのみわかる場合はその粒度に応じたズームレベルを選び、
+// どちらもわからない場合（座標で指定された場合など）は従来のズームレベル10を使う
+func SelectMapView(location *Location) MapView {
+	if location == nil {
+		return MapView{Zoom: defaultViewZoom, AroundTiles: defaultViewAroundTiles}
+	}
+
+	zoom := fitBoundingBoxZoom(location)
+	if zoom == 0 {
+		zoom = addressLevelZooms[location.AddressLevel]
+	}
+	if zoom == 0 {
+		return MapView{Zoom: defaultViewZoom, AroundTiles: defaultViewAroundTiles}
+	}
+
+	return MapView{Zoom: zoom, AroundTiles: aroundTilesFor(zoom)}
+}
+
+// aroundTilesFor ズームレベルに応じた周囲のタイル数を返す
+// 拡大した地図では周囲のタイルを減らしてタイルの取得数を抑える
+func aroundTilesFor(zoom int) int {
+	if closeUpZoom <= zoom {
+		return 1
+	}
+	return defaultViewAroundTiles
+}
+
+// fitBoundingBoxZoom 中心から範囲全体が画像に収まる最大のズームレベルを返す
+// 範囲がない場合や点の場合は0を返す
+func fitBoundingBoxZoom(location *Location) int {
+	bbox := location.BoundingBox
+	if bbox == nil || bbox.MinLat == bbox.MaxLat || bbox.MinLng == bbox.MaxLng {
+		return 0
+	}
+
+	for zoom := maxAutoZoom; minAutoZoom < zoom; zoom-- {
+		viewport := &Viewport{Lat: location.Lat, Lng: location.Lng, Zoom: zoom, AroundTiles: aroundTilesFor(zoom)}
+		bounds := viewport.Size()
+		sw := viewport.ImagePoint(bbox.MinLat, bbox.MinLng)
+		ne := viewport.ImagePoint(bbox.MaxLat, bbox.MaxLng)
+		if 0 <= sw.X && sw.Y <= bounds && 0 <= ne.Y && ne.X <= bounds {
+			return zoom
+		}
+	}
+	return minAutoZoom
+}
@@ -0,0 +1,84 @@
+package amesh_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+)
+
+func TestSelectMapView(t *testing.T) {
+	tests := []struct {
+		name     string
+		location *amesh.Location
+		expected amesh.MapView
+	}{
+		{
+			name:     "位置情報がnil",
+			location: nil,
+			expected: amesh.MapView{Zoom: 10, AroundTiles: 2},
+		},
+		{
+			name:     "座標で指定された場合は従来のズームレベル",
+			location: &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "35.69,139.69"},
+			expected: amesh.MapView{Zoom: 10, AroundTiles: 2},
+		},
+		{
+			name: "都道府県は全体が収まるズームレベル",
+			location: &amesh.Location{
+				Lat:          43.06451,
+				Lng:          141.34681,
+				PlaceName:    "北海道",
+				BoundingBox:  &amesh.BoundingBox{MinLat: 41.35, MinLng: 139.33, MaxLat: 45.56, MaxLng: 148.89},
+				AddressLevel: 1,
+			},
+			expected: amesh.MapView{Zoom: 6, AroundTiles: 2},
+		},
+		{
+			name: "駅は拡大したズームレベル",
+			location: &amesh.Location{
+				Lat:         35.658,
+				Lng:         139.7016,
+				PlaceName:   "渋谷駅",
+				BoundingBox: &amesh.BoundingBox{MinLat: 35.6565, MinLng: 139.6995, MaxLat: 35.6595, MaxLng: 139.7035},
+			},
+			expected: amesh.MapView{Zoom: 15, AroundTiles: 1},
+		},
+		{
+			name: "範囲が大きすぎる場合は最小のズームレベル",
+			location: &amesh.Location{
+				Lat:         35.6895,
+				Lng:         139.6917,
+				PlaceName:   "世界",
+				BoundingBox: &amesh.BoundingBox{MinLat: -60, MinLng: -179, MaxLat: 70, MaxLng: 179},
+			},
+			expected: amesh.MapView{Zoom: 5, AroundTiles: 2},
+		},
+		{
+			name:     "範囲がなければマッチングレベルから選択",
+			location: &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都新宿区西新宿二丁目", AddressLevel: 4},
+			expected: amesh.MapView{Zoom: 13, AroundTiles: 1},
+		},
+		{
+			name: "点の範囲はマッチングレベルから選択",
+			location: &amesh.Location{
+				Lat:          35.6895,
+				Lng:          139.6917,
+				PlaceName:    "新宿区",
+				BoundingBox:  &amesh.BoundingBox{MinLat: 35.6895, MinLng: 139.6917, MaxLat: 35.6895, MaxLng: 139.6917},
+				AddressLevel: 2,
+			},
+			expected: amesh.MapView{Zoom: 10, AroundTiles: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(amesh.SelectMapView(tt.location), tt.expected); diff != "" {
+				t.Errorf("SelectMapView() diff: %s", diff)
+			}
+		})
+	}
+}
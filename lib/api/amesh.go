@@ -0,0 +1,198 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/metrics"
+)
+
+// 指定できるズームレベルの範囲（OpenStreetMapのタイルがある範囲）
+const (
+	minZoom = 1
+	maxZoom = 18
+)
+
+// formatで指定できる形式
+const (
+	formatPNG     = "png"     // amesh画像（デフォルト）
+	formatGeoJSON = "geojson" // 画像の作成に使う地点・距離円・落雷地点・タイルの範囲のGeoJSON
+)
+
+// AmeshHandlerParams amesh画像を返すHTTPハンドラーの設定
+type AmeshHandlerParams struct {
+	Client         httpclient.Doer           // ジオコーディングとタイルの取得に使うHTTPクライアント
+	YahooAPIToken  string                    // ジオコーディング用Yahoo Maps APIのトークン
+	Geocoder       amesh.Geocoder            // 地名を探すジオコーダ（nilの場合はYahooAPITokenがあればYahoo!ジオコーダ）
+	APIKey         string                    // リクエストに必要なAPIキー（空の場合は認証しない）
+	TimestampCache *httpclient.ResponseCache // targetTimesのキャッシュ（nilの場合はキャッシュしない）
+}
+
+// ameshHandler GET /amesh?place=...&zoom=...&layer=...&format=... にPNG画像またはGeoJSONを返すHTTPハンドラー
+type ameshHandler struct {
+	params *AmeshHandlerParams
+}
+
+// NewAmeshHandler amesh画像を返すHTTPハンドラーを作成する
+// APIキーを設定した場合は、X-API-KeyヘッダーまたはAuthorization: Bearerヘッダーで同じ値を指定したリクエストのみ受け付ける
+func NewAmeshHandler(params *AmeshHandlerParams) http.Handler {
+	return &ameshHandler{params: params}
+}
+
+// ServeHTTP 地名または座標からamesh画像を作成し、PNG形式で返す
+func (h *ameshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics.Default.Counter("api.amesh.requests").Inc()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	place := strings.TrimSpace(query.Get("place"))
+	if place == "" {
+		http.Error(w, "place is required", http.StatusBadRequest)
+		return
+	}
+
+	zoom := 0
+	if s := query.Get("zoom"); s != "" {
+		var err error
+		zoom, err = strconv.Atoi(s)
+		if err != nil || zoom < minZoom || maxZoom < zoom {
+			http.Error(w, fmt.Sprintf("zoom must be an integer between %d and %d", minZoom, maxZoom), http.StatusBadRequest)
+			return
+		}
+	}
+
+	overlays, err := amesh.ParseOverlays(query.Get("layer"))
+	if err != nil {
+		http.Error(w, "unknown layer", http.StatusBadRequest)
+		return
+	}
+
+	format := query.Get("format")
+	if format != "" && format != formatPNG && format != formatGeoJSON {
+		http.Error(w, "format must be png or geojson", http.StatusBadRequest)
+		return
+	}
+
+	location, err := amesh.ParseLocationWithClient(r.Context(), &amesh.ParseLocationParams{
+		Client: h.params.Client,
+		GeocodeRequest: amesh.GeocodeRequest{
+			Place:  place,
+			APIKey: h.params.YahooAPIToken,
+		},
+		Geocoder: h.params.Geocoder,
+	})
+	if err != nil {
+		writeImageError(w, errors.Wrap(err, "Failed to amesh.ParseLocationWithClient"))
+		return
+	}
+
+	imageParams := &amesh.LocationImageParams{
+		Client:         h.params.Client,
+		Location:       location,
+		TimestampCache: h.params.TimestampCache,
+		Overlays:       overlays,
+		Zoom:           zoom,
+	}
+	if format == formatGeoJSON {
+		h.serveGeoJSON(w, r, imageParams)
+		return
+	}
+
+	reader, err := amesh.CreateImageReaderWithClient(r.Context(), imageParams)
+	if err != nil {
+		writeImageError(w, errors.Wrap(err, "Failed to amesh.CreateImageReaderWithClient"))
+		return
+	}
+	defer func(reader *amesh.ImageReader) {
+		if closeErr := reader.Close(); closeErr != nil {
+			log.Printf("Failed to Close: %v", closeErr)
+		}
+	}(reader)
+
+	w.Header().Set("Content-Type", "image/png")
+	if !reader.RadarTime.IsZero() {
+		w.Header().Set("X-Amesh-Radar-Time", reader.RadarTime.Format(time.RFC3339))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// ヘッダー送信後はステータスを変更できないため、書き込みの失敗はログにのみ出力する
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("Failed to io.Copy: %v", err)
+	}
+}
+
+// serveGeoJSON 画像の代わりに中心の地点・距離円・落雷地点・タイルの範囲をGeoJSONで返す
+func (h *ameshHandler) serveGeoJSON(w http.ResponseWriter, r *http.Request, params *amesh.LocationImageParams) {
+	collection, err := amesh.CreateGeoJSON(r.Context(), &amesh.CreateGeoJSONParams{LocationImageParams: *params})
+	if err != nil {
+		writeImageError(w, errors.Wrap(err, "Failed to amesh.CreateGeoJSON"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	if !collection.Metadata.RadarTime.IsZero() {
+		w.Header().Set("X-Amesh-Radar-Time", collection.Metadata.RadarTime.Format(time.RFC3339))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(collection); err != nil {
+		log.Printf("Failed to json.Encode: %v", err)
+	}
+}
+
+// authorized リクエストのAPIキーが設定と一致するかを返す
+func (h *ameshHandler) authorized(r *http.Request) bool {
+	if h.params.APIKey == "" {
+		return true
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(h.params.APIKey)) == 1
+}
+
+// writeImageError 画像の作成に失敗した原因に応じたステータスを返す
+func writeImageError(w http.ResponseWriter, err error) {
+	log.Printf("Failed to create amesh image: %v", err)
+	metrics.Default.Counter("api.amesh.errors").Inc()
+
+	switch {
+	case errors.Is(err, amesh.ErrNoResultsFound):
+		http.Error(w, "place not found", http.StatusNotFound)
+	case errors.Is(err, amesh.ErrCoordinateOutOfRange):
+		http.Error(w, "coordinate out of range", http.StatusBadRequest)
+	case errors.Is(err, httpclient.ErrCircuitOpen), errors.Is(err, httpclient.ErrHTTPRequestError):
+		http.Error(w, "upstream service unavailable", http.StatusBadGateway)
+	default:
+		http.Error(w, "failed to create image", http.StatusInternalServerError)
+	}
+}
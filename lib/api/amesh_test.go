@@ -0,0 +1,215 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/api"
+	"hato-bot-go/lib/httpclient"
+)
+
+// newTileBytes 白い256x256のタイル画像を作成する
+func newTileBytes(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAmeshHandler(t *testing.T) {
+	tileBytes := newTileBytes(t)
+
+	tests := []struct {
+		name             string
+		method           string
+		target           string
+		apiKey           string
+		header           map[string]string
+		expectedStatus   int
+		expectPNG        bool
+		expectGeoJSON    bool
+		expectRadarTime  string
+		expectedRequests int // ジオコーダへのリクエスト数
+	}{
+		{
+			name:            "座標を指定",
+			method:          http.MethodGet,
+			target:          "/amesh?place=35.6895,139.6917&zoom=10",
+			expectedStatus:  http.StatusOK,
+			expectPNG:       true,
+			expectRadarTime: "2024-01-01T12:05:00Z",
+		},
+		{
+			name:             "地名を指定",
+			method:           http.MethodGet,
+			target:           "/amesh?place=%E6%9D%B1%E4%BA%AC",
+			expectedStatus:   http.StatusOK,
+			expectPNG:        true,
+			expectRadarTime:  "2024-01-01T12:05:00Z",
+			expectedRequests: 1,
+		},
+		{
+			name:             "見つからない地名",
+			method:           http.MethodGet,
+			target:           "/amesh?place=nowhere",
+			expectedStatus:   http.StatusNotFound,
+			expectedRequests: 1,
+		},
+		{
+			name:           "地名なし",
+			method:         http.MethodGet,
+			target:         "/amesh",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "範囲外のズームレベル",
+			method:         http.MethodGet,
+			target:         "/amesh?place=35.6895,139.6917&zoom=25",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "存在しないレイヤー",
+			method:         http.MethodGet,
+			target:         "/amesh?place=35.6895,139.6917&layer=unknown",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:            "PNGを指定",
+			method:          http.MethodGet,
+			target:          "/amesh?place=35.6895,139.6917&format=png",
+			expectedStatus:  http.StatusOK,
+			expectPNG:       true,
+			expectRadarTime: "2024-01-01T12:05:00Z",
+		},
+		{
+			name:            "GeoJSONを指定",
+			method:          http.MethodGet,
+			target:          "/amesh?place=35.6895,139.6917&zoom=10&format=geojson",
+			expectedStatus:  http.StatusOK,
+			expectGeoJSON:   true,
+			expectRadarTime: "2024-01-01T12:05:00Z",
+		},
+		{
+			name:           "存在しない形式",
+			method:         http.MethodGet,
+			target:         "/amesh?place=35.6895,139.6917&format=svg",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "GET以外のメソッド",
+			method:         http.MethodPost,
+			target:         "/amesh?place=35.6895,139.6917",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "APIキーなし",
+			method:         http.MethodGet,
+			target:         "/amesh?place=35.6895,139.6917",
+			apiKey:         "secret",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "APIキーが違う",
+			method:         http.MethodGet,
+			target:         "/amesh?place=35.6895,139.6917",
+			apiKey:         "secret",
+			header:         map[string]string{"X-API-Key": "wrong"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:            "X-API-KeyヘッダーのAPIキー",
+			method:          http.MethodGet,
+			target:          "/amesh?place=35.6895,139.6917",
+			apiKey:          "secret",
+			header:          map[string]string{"X-API-Key": "secret"},
+			expectedStatus:  http.StatusOK,
+			expectPNG:       true,
+			expectRadarTime: "2024-01-01T12:05:00Z",
+		},
+		{
+			name:            "BearerトークンのAPIキー",
+			method:          http.MethodGet,
+			target:          "/amesh?place=35.6895,139.6917",
+			apiKey:          "secret",
+			header:          map[string]string{"Authorization": "Bearer secret"},
+			expectedStatus:  http.StatusOK,
+			expectPNG:       true,
+			expectRadarTime: "2024-01-01T12:05:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "geoCoder?appid=token&query=nowhere", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"Feature": []}`}}},
+					{Pattern: "geoCoder", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"Feature": [
+						{"Name": "東京都", "Geometry": {"Coordinates": "139.6917,35.6895"}}
+					]}`}}},
+					{Pattern: "targetTimes", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `[
+						{"basetime": "20240101120500", "validtime": "20240101120500", "elements": ["hrpns_nd"]}
+					]`}}},
+					{Pattern: ".png", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: string(tileBytes)}}},
+				},
+			})
+			handler := api.NewAmeshHandler(&api.AmeshHandlerParams{
+				Client:        transport.Client(),
+				YahooAPIToken: "token",
+				APIKey:        tt.apiKey,
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), tt.method, tt.target, nil)
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.expectedStatus, rec.Body.String())
+			}
+			if got := len(transport.RequestsTo("geoCoder")); got != tt.expectedRequests {
+				t.Errorf("geocoder requests = %d, want %d", got, tt.expectedRequests)
+			}
+			if got := rec.Header().Get("X-Amesh-Radar-Time"); got != tt.expectRadarTime {
+				t.Errorf("X-Amesh-Radar-Time = %q, want %q", got, tt.expectRadarTime)
+			}
+			if tt.expectGeoJSON {
+				if got := rec.Header().Get("Content-Type"); got != "application/geo+json" {
+					t.Errorf("Content-Type = %q, want application/geo+json", got)
+				}
+				var collection amesh.FeatureCollection
+				if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+					t.Fatalf("json.Unmarshal() error = %v", err)
+				}
+				if collection.Type != "FeatureCollection" || len(collection.Features) == 0 {
+					t.Errorf("collection = %+v, want a FeatureCollection with features", collection)
+				}
+			}
+			if !tt.expectPNG {
+				return
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != "image/png" {
+				t.Errorf("Content-Type = %q, want image/png", got)
+			}
+			if _, err := png.Decode(rec.Body); err != nil {
+				t.Errorf("png.Decode() error = %v", err)
+			}
+		})
+	}
+}
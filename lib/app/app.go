@@ -0,0 +1,488 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/elevation"
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/metrics"
+	"hato-bot-go/lib/notify"
+	"hato-bot-go/lib/state"
+	"hato-bot-go/lib/translate"
+)
+
+// Mode 実行モード
+type Mode string
+
+const (
+	ModeMisskey Mode = "misskey" // Misskeyボット
+	ModeMixi2   Mode = "mixi2"   // mixi2ボット
+	ModeCLI     Mode = "cli"     // コマンドラインで画像を作成
+	ModeServe   Mode = "serve"   // 画像APIサーバー
+	ModeDoctor  Mode = "doctor"  // 外部サービスを実際に呼び出す自己診断
+	ModeState   Mode = "state"   // 状態のディレクトリの使用量の確認と削除
+)
+
+// ModeEnv 実行モードを指定する環境変数
+const ModeEnv = "HATO_MODE"
+
+var (
+	// ErrUnknownMode 存在しない実行モードを指定したことを表すエラー
+	ErrUnknownMode = errors.New("unknown mode")
+	// ErrModeNotSelected 実行モードが指定されていないことを表すエラー
+	ErrModeNotSelected = errors.New("mode is not selected")
+)
+
+// featureEarthquake 地震情報の自動投稿を表す機能の名前
+const featureEarthquake = "earthquake"
+
+// featureNames 設定ファイルのfeaturesで有効・無効を切り替えられる機能の名前（コマンド名と地震情報の自動投稿）
+var featureNames = []string{"amesh", "amedas", "map", "rain", "elevation", "distance", "wiki", "translate", "convert", "stats", "selftest", "reload", "help", featureEarthquake}
+
+// Common 全モードで共通の初期化結果
+type Common struct {
+	Config    *config.Config     // 設定ファイルの内容
+	Templates *i18n.Templates    // 返信テンプレート
+	Notifier  *notify.Dispatcher // 設定ファイルのWebhookへの通知（未設定の場合はnil）
+	State     *state.Dir         // 履歴やTLS証明書などの状態を保存するディレクトリ
+
+	Aliases          map[string]string            // コマンドの別名からコマンド名への対応
+	CommandTimeouts  map[string]time.Duration     // コマンド名ごとの処理の制限時間
+	RateLimiter      *bot.RateLimiter             // 送信者ごとのコマンドの実行回数の制限（未設定の場合は制限しない、設定ファイルを読み込み直すと変わる）
+	History          *history.Store               // コマンドの処理の履歴（未設定の場合はnil）
+	Translator       translate.Translator         // translateコマンドの翻訳サービス（未設定の場合はnil）
+	Geocoder         amesh.Geocoder               // 地名を探すジオコーダ（未設定の場合はnil）
+	HTTPServer       *lib.HTTPServerSetting       // HTTPサーバーのTLSとリバースプロキシの設定（未設定の場合はnil）
+	DependencyGate   *bot.GateSetting             // 依存する外部サービスによる受付の制御（確認とコマンドは実行モードで設定する、無効の場合はnil）
+	LoadShedding     *bot.ShedderSetting          // 処理を待つメッセージの上限と同時に処理する数（無効の場合はnil）
+	MisskeyInstances []config.MisskeyInstance     // Misskeyボットで接続するインスタンス（名前を補ったもの、未設定の場合は空）
+	ImageLimits      map[string]*amesh.ImageLimit // プラットフォーム名ごとの返信に添付する画像の大きさの上限（未設定のプラットフォームはnil）
+	DisabledFeatures []string                     // 設定ファイルのfeaturesで無効にした機能の名前
+	Reloader         *ConfigReloader              // SIGHUPやadmin reloadコマンドで設定ファイルを読み込み直す処理
+}
+
+// Runner 実行モードのメイン処理
+// ctxはSIGINT・SIGTERMを受け取るとキャンセルされる
+type Runner func(ctx context.Context, common *Common, args []string) error
+
+// modeSetting 実行モードごとの設定
+type modeSetting struct {
+	Runner       Runner // メイン処理
+	StatusServer bool   // /status・/metricsのHTTPサーバーを起動するか
+	Checks       bool   // 起動時に設定と外部サービスを検査するか
+}
+
+// modes 実行モードの一覧
+// mixi2はSDKへの依存を持ち込まないよう、利用するバイナリでRegisterする
+var modes = map[Mode]*modeSetting{
+	ModeMisskey: {Runner: RunMisskey, StatusServer: true, Checks: true},
+	ModeCLI:     {Runner: RunCLI},
+	ModeServe:   {Runner: RunServe, Checks: true},
+	ModeDoctor:  {Runner: RunDoctor},
+	ModeState:   {Runner: RunState},
+}
+
+// Register 実行モードのメイン処理を登録する
+// ボットとして常駐するモードは/status・/metricsのHTTPサーバーも起動し、起動時に設定を検査する
+func Register(mode Mode, runner Runner) {
+	modes[mode] = &modeSetting{Runner: runner, StatusServer: true, Checks: true}
+}
+
+// Modes 登録されている実行モードを名前順に返す
+func Modes() []Mode {
+	result := make([]Mode, 0, len(modes))
+	for mode := range modes {
+		result = append(result, mode)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Init 全モードで共通の初期化を行う
+// 設定ファイルを読み込み、返信テンプレートと通知の送信先とコマンドの制限を解析する
+func Init() (*Common, error) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to config.LoadFromEnv")
+	}
+	templates, err := i18n.ParseTemplates(cfg.Templates)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to i18n.ParseTemplates")
+	}
+	notifier, err := notify.NewDispatcherFromConfig(cfg.Notifiers, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to notify.NewDispatcherFromConfig")
+	}
+	aliases, err := cfg.ParseAliases()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseAliases")
+	}
+	commandTimeouts, err := cfg.ParseCommandTimeouts()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseCommandTimeouts")
+	}
+	httpclient.SetContact(cfg.Contact)
+	if cfg.Contact == "" {
+		log.Println("contact is not set in the config file: outgoing requests are sent without contact info in the User-Agent")
+	}
+	httpTimeouts, err := cfg.ParseHTTPTimeouts()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseHTTPTimeouts")
+	}
+	if err := httpclient.SetTimeouts(httpTimeouts); err != nil {
+		return nil, errors.Wrap(err, "Failed to httpclient.SetTimeouts")
+	}
+	quotaLimits := make(map[string]httpclient.QuotaLimit, len(cfg.APIQuotas))
+	for upstream, quota := range cfg.APIQuotas {
+		quotaLimits[upstream] = httpclient.QuotaLimit{Hourly: quota.Hourly, Daily: quota.Daily}
+	}
+	if err := httpclient.SetQuotaLimits(quotaLimits); err != nil {
+		return nil, errors.Wrap(err, "Failed to httpclient.SetQuotaLimits")
+	}
+	staleThreshold, err := cfg.ParseAmeshStaleThreshold()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseAmeshStaleThreshold")
+	}
+	overlayStyles, err := amesh.NewOverlayStylesFromConfig(cfg.AmeshOverlays)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.NewOverlayStylesFromConfig")
+	}
+	mapStyle, err := amesh.ParseMapStyle(cfg.AmeshMapStyle)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseMapStyle")
+	}
+	// コマンドなどクライアント未指定の画像の作成で使う
+	amesh.SetStaleThreshold(staleThreshold)
+	amesh.SetOverlayStyles(overlayStyles)
+	amesh.SetMapStyle(mapStyle)
+	elevation.SetAmeshCaption(cfg.AmeshElevation)
+	amesh.SetCoverage(cfg.AmeshCoverage)
+	imageLimits, err := amesh.NewImageLimitsFromConfig(cfg.ImageLimits)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.NewImageLimitsFromConfig")
+	}
+	rateLimitWindow, err := cfg.RateLimit.ParseWindow()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.RateLimit.ParseWindow")
+	}
+	// 設定ファイルを読み込み直して制限を追加できるよう、未設定の場合も制限しないRateLimiterを作成する
+	rateLimiter := bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: rateLimitCount(cfg.RateLimit), Window: rateLimitWindow})
+	logLevel, err := cfg.ParseLogLevel()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseLogLevel")
+	}
+	slog.SetLogLoggerLevel(logLevel)
+	stateDir := state.Resolve(&state.ResolveParams{Configured: cfg.StateDir})
+	historyStore, err := newHistoryStore(cfg.History, stateDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to newHistoryStore")
+	}
+	translator, err := translate.NewTranslatorFromConfig(cfg.Translate, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to translate.NewTranslatorFromConfig")
+	}
+	geocoder, err := amesh.NewGeocoderFromConfig(cfg.Geocoder, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.NewGeocoderFromConfig")
+	}
+	// コマンドなどクライアント未指定の地名の解析でも設定したジオコーダを使う
+	amesh.SetDefaultGeocoder(geocoder)
+	httpServer, err := newHTTPServerSetting(cfg.HTTPServer, stateDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to newHTTPServerSetting")
+	}
+	dependencyGate, err := newGateSetting(cfg.DependencyGate)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to newGateSetting")
+	}
+	loadShedding, err := newShedderSetting(cfg.LoadShedding)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to newShedderSetting")
+	}
+	instances, err := cfg.ParseMisskeyInstances()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseMisskeyInstances")
+	}
+	disabledFeatures, err := cfg.ParseFeatures(featureNames)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseFeatures")
+	}
+	common := &Common{
+		Config:           cfg,
+		Templates:        templates,
+		Notifier:         notifier,
+		State:            stateDir,
+		Aliases:          aliases,
+		CommandTimeouts:  commandTimeouts,
+		RateLimiter:      rateLimiter,
+		History:          historyStore,
+		Translator:       translator,
+		Geocoder:         geocoder,
+		HTTPServer:       httpServer,
+		DependencyGate:   dependencyGate,
+		LoadShedding:     loadShedding,
+		MisskeyInstances: instances,
+		ImageLimits:      imageLimits,
+		DisabledFeatures: disabledFeatures,
+	}
+	common.Reloader = NewConfigReloader(common, nil)
+	return common, nil
+}
+
+// rateLimitCount 期間内に実行できる回数を返す（未設定の場合は0）
+func rateLimitCount(cfg *config.RateLimit) int {
+	if cfg == nil {
+		return 0
+	}
+	return cfg.Count
+}
+
+// newHistoryStore 設定ファイルの履歴の設定からStoreを作成する
+// 未設定の場合はnilを返し（nilのStoreは記録しない）、パスが空の場合は状態のディレクトリに保存する
+func newHistoryStore(cfg *config.History, stateDir *state.Dir) (*history.Store, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Secret == "" {
+		return nil, errors.Wrap(config.ErrInvalidHistory, "secret is empty")
+	}
+	retention, err := cfg.ParseRetention()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseRetention")
+	}
+	path := cfg.Path
+	if path == "" {
+		dir, err := stateDir.Ensure(state.History)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to stateDir.Ensure")
+		}
+		path = filepath.Join(dir, "history.jsonl")
+	}
+	store, err := history.NewStore(&history.StoreSetting{
+		Path:      path,
+		Retention: retention,
+		Secret:    []byte(cfg.Secret),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to history.NewStore")
+	}
+	return store, nil
+}
+
+// newHTTPServerSetting 設定ファイルのHTTPサーバーの設定を検査してHTTPServerSettingを作成する
+// 未設定の場合はnilを返し（TLSなしで待ち受ける）、証明書の保存先が空の場合は状態のディレクトリに保存する
+func newHTTPServerSetting(cfg *config.HTTPServer, stateDir *state.Dir) (*lib.HTTPServerSetting, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.Validate")
+	}
+	trustedProxies, err := cfg.ParseTrustedProxies()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseTrustedProxies")
+	}
+	autocertCacheDir := cfg.AutocertCacheDir
+	if autocertCacheDir == "" {
+		autocertCacheDir = stateDir.Path(state.Autocert)
+	}
+	return &lib.HTTPServerSetting{
+		CertFile:         cfg.CertFile,
+		KeyFile:          cfg.KeyFile,
+		AutocertDomains:  cfg.AutocertDomains,
+		AutocertCacheDir: autocertCacheDir,
+		TrustedProxies:   trustedProxies,
+	}, nil
+}
+
+// newGateSetting 設定ファイルの受付の制御の設定からGateSettingを作成する
+// 未設定の場合は既定値で有効にし、無効にした場合はnilを返す
+func newGateSetting(cfg *config.DependencyGate) (*bot.GateSetting, error) {
+	if cfg == nil {
+		return &bot.GateSetting{}, nil
+	}
+	if cfg.Disabled {
+		return nil, nil
+	}
+	probeInterval, err := cfg.ParseProbeInterval()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseProbeInterval")
+	}
+	return &bot.GateSetting{
+		FailureThreshold: cfg.FailureThreshold,
+		ProbeInterval:    probeInterval,
+		MaxBacklog:       cfg.MaxBacklog,
+	}, nil
+}
+
+// newShedderSetting 設定ファイルの混雑時の設定からShedderSettingを作成する
+// 未設定の場合は既定値の設定を返し、無効の場合はnilを返す（nilのShedderはその場で処理する）
+func newShedderSetting(cfg *config.LoadShedding) (*bot.ShedderSetting, error) {
+	if cfg == nil {
+		return &bot.ShedderSetting{}, nil
+	}
+	if cfg.Disabled {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.Validate")
+	}
+	return &bot.ShedderSetting{MaxDepth: cfg.MaxQueueDepth, Workers: cfg.Workers}, nil
+}
+
+// SelectModeParams 実行モード選択のリクエスト構造体
+type SelectModeParams struct {
+	Args   []string // コマンドライン引数（プログラム名を除く）
+	Env    string   // 環境変数HATO_MODEの値
+	Config string   // 設定ファイルのmodeの値
+}
+
+// SelectModeResult 実行モード選択の結果
+type SelectModeResult struct {
+	Mode Mode     // 実行モード
+	Args []string // 実行モードに渡す残りの引数
+}
+
+// SelectMode コマンドライン引数・環境変数・設定ファイルの順に実行モードを選択する
+// コマンドライン引数は「--mode 名前」「--mode=名前」または先頭のサブコマンドで指定する
+func SelectMode(params *SelectModeParams) (*SelectModeResult, error) {
+	if params == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	args := params.Args
+	var name string
+	switch {
+	case 0 < len(args) && (args[0] == "--mode" || args[0] == "-mode"):
+		if len(args) < 2 {
+			return nil, errors.Wrap(ErrModeNotSelected, "--mode requires a value")
+		}
+		name, args = args[1], args[2:]
+	case 0 < len(args) && (strings.HasPrefix(args[0], "--mode=") || strings.HasPrefix(args[0], "-mode=")):
+		_, name, _ = strings.Cut(args[0], "=")
+		args = args[1:]
+	case 0 < len(args) && modes[Mode(args[0])] != nil:
+		name, args = args[0], args[1:]
+	case params.Env != "":
+		name = params.Env
+	case params.Config != "":
+		name = params.Config
+	default:
+		return nil, ErrModeNotSelected
+	}
+
+	mode := Mode(strings.ToLower(strings.TrimSpace(name)))
+	if modes[mode] == nil {
+		return nil, errors.Wrapf(ErrUnknownMode, "mode: %s", name)
+	}
+	return &SelectModeResult{Mode: mode, Args: args}, nil
+}
+
+// Run 共通の初期化結果を使って実行モードのメイン処理を実行する
+func Run(ctx context.Context, common *Common, selected *SelectModeResult) error {
+	if common == nil || selected == nil {
+		return lib.ErrParamsNil
+	}
+	setting := modes[selected.Mode]
+	if setting == nil {
+		return errors.Wrapf(ErrUnknownMode, "mode: %s", selected.Mode)
+	}
+
+	metrics.Default.Counter(fmt.Sprintf("app.%s.starts", selected.Mode)).Inc()
+	if setting.StatusServer {
+		// HTTPサーバーを別ゴルーチンで開始
+		go lib.StartStatusHTTPServer(common.History, common.HTTPServer)
+		// 常駐するモードではSIGHUPで設定ファイルを読み込み直す
+		go common.Reloader.Run(ctx)
+	}
+
+	if err := setting.Runner(ctx, common, selected.Args); err != nil {
+		return errors.Wrapf(err, "Failed to run %s mode", selected.Mode)
+	}
+	return nil
+}
+
+// Main 引数と環境変数から実行モードを選択して実行する
+// modeを指定した場合は引数によらずそのモードで実行する（各ボット専用のバイナリ用）
+// --checkを指定した場合は起動時の検査の結果を表示して終了する（失敗した項目があれば終了コード1）
+func Main(mode Mode, args []string) {
+	check, args := ParseCheckFlag(args)
+	common, err := Init()
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+
+	selected := &SelectModeResult{Mode: mode, Args: args}
+	if mode == "" {
+		selected, err = SelectMode(&SelectModeParams{
+			Args:   args,
+			Env:    os.Getenv(ModeEnv),
+			Config: common.Config.Mode,
+		})
+		if err != nil {
+			log.Fatalf("Failed to select mode: %v (available modes: %v)", err, Modes())
+		}
+	}
+
+	// グレースフルシャットダウン設定
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if check {
+		if err := runChecks(ctx, common, selected.Mode, os.Stdout); err != nil {
+			stop()
+			log.Fatal(err)
+		}
+		return
+	}
+	if modes[selected.Mode].Checks {
+		if err := runChecks(ctx, common, selected.Mode, log.Writer()); err != nil {
+			stop()
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("hato-bot-go %s starting in %s mode", lib.BuildVersion(), selected.Mode)
+	err = Run(ctx, common, selected)
+	if closeErr := common.History.Close(); closeErr != nil {
+		log.Printf("Failed to Close: %v", closeErr)
+	}
+	if err != nil {
+		stop()
+		log.Fatal(err)
+	}
+}
+
+// runChecks 起動時の検査を実行して結果の表を書き込み、失敗した項目があればエラーを返す
+func runChecks(ctx context.Context, common *Common, mode Mode, w io.Writer) error {
+	results, err := RunChecks(ctx, &CheckParams{Mode: mode, Common: common})
+	if err != nil {
+		return errors.Wrap(err, "Failed to RunChecks")
+	}
+	if err := WriteCheckTable(w, results); err != nil {
+		return errors.Wrap(err, "Failed to WriteCheckTable")
+	}
+	if err := CheckError(results); err != nil {
+		return errors.Wrap(err, "Failed to CheckError")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/app"
+)
+
+func TestSelectMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        *app.SelectModeParams
+		expected      *app.SelectModeResult
+		expectedError error
+	}{
+		{
+			name:     "サブコマンド",
+			params:   &app.SelectModeParams{Args: []string{"cli", "amesh", "東京"}},
+			expected: &app.SelectModeResult{Mode: app.ModeCLI, Args: []string{"amesh", "東京"}},
+		},
+		{
+			name:     "--modeフラグ",
+			params:   &app.SelectModeParams{Args: []string{"--mode", "serve", "--port", "9000"}},
+			expected: &app.SelectModeResult{Mode: app.ModeServe, Args: []string{"--port", "9000"}},
+		},
+		{
+			name:     "--mode=フラグ",
+			params:   &app.SelectModeParams{Args: []string{"--mode=Misskey"}},
+			expected: &app.SelectModeResult{Mode: app.ModeMisskey, Args: []string{}},
+		},
+		{
+			name:     "-modeフラグ",
+			params:   &app.SelectModeParams{Args: []string{"-mode", "cli", "amedas", "大阪"}},
+			expected: &app.SelectModeResult{Mode: app.ModeCLI, Args: []string{"amedas", "大阪"}},
+		},
+		{
+			name:     "引数は環境変数より優先",
+			params:   &app.SelectModeParams{Args: []string{"serve"}, Env: "misskey", Config: "cli"},
+			expected: &app.SelectModeResult{Mode: app.ModeServe, Args: []string{}},
+		},
+		{
+			name:     "環境変数は設定ファイルより優先",
+			params:   &app.SelectModeParams{Args: []string{"--port", "9000"}, Env: "serve", Config: "misskey"},
+			expected: &app.SelectModeResult{Mode: app.ModeServe, Args: []string{"--port", "9000"}},
+		},
+		{
+			name:     "設定ファイル",
+			params:   &app.SelectModeParams{Config: "misskey"},
+			expected: &app.SelectModeResult{Mode: app.ModeMisskey},
+		},
+		{
+			name:          "存在しないモード",
+			params:        &app.SelectModeParams{Args: []string{"--mode", "slack"}},
+			expectedError: app.ErrUnknownMode,
+		},
+		{
+			name:          "存在しないモードの環境変数",
+			params:        &app.SelectModeParams{Env: "unknown"},
+			expectedError: app.ErrUnknownMode,
+		},
+		{
+			name:          "--modeの値なし",
+			params:        &app.SelectModeParams{Args: []string{"--mode"}},
+			expectedError: app.ErrModeNotSelected,
+		},
+		{
+			name:          "指定なし",
+			params:        &app.SelectModeParams{},
+			expectedError: app.ErrModeNotSelected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := app.SelectMode(tt.params)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("SelectMode() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("SelectMode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
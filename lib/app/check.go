@@ -0,0 +1,217 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+// ErrCheckFailed 起動時の検査に失敗した項目があることを表すエラー
+var ErrCheckFailed = errors.New("startup check failed")
+
+// CheckStatus 起動時の検査の結果
+type CheckStatus string
+
+const (
+	CheckOK   CheckStatus = "ok"   // 設定済みで動作する
+	CheckWarn CheckStatus = "warn" // 動作するが機能が制限される
+	CheckFail CheckStatus = "fail" // 起動できない
+	CheckSkip CheckStatus = "skip" // 未設定のため使わない
+)
+
+// CheckResult 起動時の検査の項目ごとの結果
+type CheckResult struct {
+	Name   string      // 検査の項目
+	Status CheckStatus // 結果
+	Detail string      // 結果の説明
+}
+
+// checkPlace ジオコーダの試験に使う地名
+const checkPlace = "東京"
+
+// requiredEnv 実行モードごとに必須の環境変数
+var requiredEnv = map[Mode][]string{
+	ModeMisskey: {"MISSKEY_DOMAIN", "MISSKEY_API_TOKEN"},
+	ModeMixi2:   {"MIXI2_STREAM_ADDRESS", "MIXI2_CLIENT_ID", "MIXI2_CLIENT_SECRET", "MIXI2_TOKEN_URL", "MIXI2_API_ADDRESS"},
+}
+
+// CheckParams 起動時の検査のリクエスト構造体
+type CheckParams struct {
+	Mode   Mode                    // 検査する実行モード
+	Common *Common                 // 共通の初期化結果
+	Getenv func(key string) string // 環境変数の取得（nilの場合はos.Getenv）
+	Client httpclient.Doer         // 外部サービスへの問い合わせに使うHTTPクライアント（nilの場合はDefaultTransport）
+}
+
+// RunChecks 必須の設定を確認し、MisskeyのAPIトークンとジオコーダを実際に呼び出して検査する
+// ジオコーダは失敗しても埋め込みの地名の一覧で動作するため、失敗はCheckWarnとして扱う
+func RunChecks(ctx context.Context, params *CheckParams) ([]CheckResult, error) {
+	if params == nil || params.Common == nil || params.Common.Config == nil {
+		return nil, lib.ErrParamsNil
+	}
+	getenv := params.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	client := params.Client
+	if client == nil {
+		client = &http.Client{Transport: httpclient.DefaultTransport}
+	}
+	cfg := params.Common.Config
+
+	var results []CheckResult
+	switch instances := params.Common.MisskeyInstances; {
+	case params.Mode == ModeMisskey && 0 < len(instances):
+		// 設定ファイルのmisskey_instancesがある場合は環境変数ではなくインスタンスごとのトークンを使う
+		results = append(results, CheckResult{Name: "env", Status: CheckOK, Detail: "misskey_instances"})
+		for i := range instances {
+			name := "misskey:" + instances[i].Name
+			token := instances[i].ResolveToken(getenv)
+			if token == "" {
+				// トークンを読み込めないインスタンスには接続できない
+				results = append(results, CheckResult{Name: name, Status: CheckFail, Detail: "not set: " + instances[i].TokenEnv})
+				continue
+			}
+			results = append(results, checkMisskey(ctx, name, instances[i].Domain, token, client))
+		}
+	case params.Mode == ModeMisskey:
+		results = append(results, checkEnv(params.Mode, getenv), checkMisskey(ctx, "misskey", getenv("MISSKEY_DOMAIN"), getenv("MISSKEY_API_TOKEN"), client))
+	default:
+		results = append(results, checkEnv(params.Mode, getenv))
+	}
+	results = append(results,
+		checkGeocoder(ctx, params.Common.Geocoder, getenv("YAHOO_API_TOKEN"), client),
+		configured("translate", cfg.Translate != nil, "translate command is disabled"),
+		configured("notifiers", 0 < len(cfg.Notifiers), "no webhooks"),
+		configured("history", cfg.History != nil, "command history is not saved"),
+		configured("rate_limit", cfg.RateLimit != nil, "no rate limit"),
+	)
+	if cfg.Contact == "" {
+		results = append(results, CheckResult{Name: "contact", Status: CheckWarn, Detail: "User-Agent has no contact info"})
+	} else {
+		results = append(results, CheckResult{Name: "contact", Status: CheckOK, Detail: cfg.Contact})
+	}
+	return results, nil
+}
+
+// checkEnv 実行モードに必須の環境変数が設定されているかを検査する
+func checkEnv(mode Mode, getenv func(string) string) CheckResult {
+	keys := requiredEnv[mode]
+	if len(keys) == 0 {
+		return CheckResult{Name: "env", Status: CheckSkip, Detail: fmt.Sprintf("no required variables in %s mode", mode)}
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if 0 < len(missing) {
+		return CheckResult{Name: "env", Status: CheckFail, Detail: "not set: " + strings.Join(missing, ", ")}
+	}
+	return CheckResult{Name: "env", Status: CheckOK, Detail: strings.Join(keys, ", ")}
+}
+
+// checkMisskey MisskeyのAPIトークンでアカウント情報を取得できるかを検査する
+// nameは検査の項目名（複数のインスタンスに接続する場合はインスタンスごとに分ける）
+func checkMisskey(ctx context.Context, name, domain, token string, client httpclient.Doer) CheckResult {
+	domain = strings.NewReplacer("\n", "", "\r", "").Replace(domain)
+	if domain == "" || token == "" {
+		return CheckResult{Name: name, Status: CheckSkip, Detail: "domain or API token is not set"}
+	}
+
+	misskeyBot, err := misskey.NewBot(domain, token, misskey.WithHTTPClient(client))
+	if err != nil {
+		return CheckResult{Name: name, Status: CheckFail, Detail: err.Error()}
+	}
+	account, err := misskeyBot.FetchAccount(ctx)
+	if err != nil {
+		return CheckResult{Name: name, Status: CheckFail, Detail: fmt.Sprintf("token rejected by %s: %v", domain, err)}
+	}
+	detail := fmt.Sprintf("@%s@%s", account.Username, domain)
+	if !account.IsBot {
+		// ボットとして登録されていないアカウントはタイムラインで他のボットと区別されない
+		return CheckResult{Name: name, Status: CheckWarn, Detail: detail + " is not marked as a bot"}
+	}
+	return CheckResult{Name: name, Status: CheckOK, Detail: detail}
+}
+
+// checkGeocoder ジオコーダで試験用の地名を探せるかを検査する
+// 設定ファイルのジオコーダがない場合はYahoo!ジオコーダを、APIキーもない場合は埋め込みの地名の一覧を使う
+func checkGeocoder(ctx context.Context, geocoder amesh.Geocoder, yahooAPIToken string, client httpclient.Doer) CheckResult {
+	if geocoder == nil {
+		if yahooAPIToken == "" {
+			return CheckResult{Name: "geocoder", Status: CheckWarn, Detail: "embedded gazetteer only"}
+		}
+		geocoder = &amesh.YahooGeocoder{Client: client, APIKey: yahooAPIToken}
+	}
+
+	location, err := geocoder.Geocode(ctx, checkPlace)
+	if err != nil {
+		return CheckResult{Name: "geocoder", Status: CheckWarn, Detail: fmt.Sprintf("falls back to embedded gazetteer: %v", err)}
+	}
+	return CheckResult{Name: "geocoder", Status: CheckOK, Detail: fmt.Sprintf("%s -> %s (%.4f, %.4f)", checkPlace, location.PlaceName, location.Lat, location.Lng)}
+}
+
+// configured 設定ファイルの項目が設定されているかを結果にする
+func configured(name string, ok bool, skipDetail string) CheckResult {
+	if !ok {
+		return CheckResult{Name: name, Status: CheckSkip, Detail: skipDetail}
+	}
+	return CheckResult{Name: name, Status: CheckOK, Detail: "configured"}
+}
+
+// CheckError 検査に失敗した項目があればErrCheckFailedを返す
+func CheckError(results []CheckResult) error {
+	var failed []string
+	for _, result := range results {
+		if result.Status == CheckFail {
+			failed = append(failed, result.Name)
+		}
+	}
+	if 0 < len(failed) {
+		return errors.Wrapf(ErrCheckFailed, "failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// WriteCheckTable 検査の結果を表形式で書き込む
+func WriteCheckTable(w io.Writer, results []CheckResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL"); err != nil {
+		return errors.Wrap(err, "Failed to fmt.Fprintln")
+	}
+	for _, result := range results {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, result.Status, result.Detail); err != nil {
+			return errors.Wrap(err, "Failed to fmt.Fprintf")
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err, "Failed to Flush")
+	}
+	return nil
+}
+
+// ParseCheckFlag 引数から--check（-check）を取り除き、指定されていたかを返す
+func ParseCheckFlag(args []string) (bool, []string) {
+	index := slices.IndexFunc(args, func(arg string) bool {
+		return arg == "--check" || arg == "-check"
+	})
+	if index < 0 {
+		return false, args
+	}
+	return true, slices.Delete(slices.Clone(args), index, index+1)
+}
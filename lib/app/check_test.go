@@ -0,0 +1,227 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/app"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/httpclient"
+)
+
+// fakeGeocoder 固定の結果を返すジオコーダ
+type fakeGeocoder struct {
+	location *amesh.Location
+	err      error
+}
+
+func (f *fakeGeocoder) Geocode(_ context.Context, _ string) (*amesh.Location, error) {
+	return f.location, f.err
+}
+
+func TestRunChecks(t *testing.T) {
+	misskeyEnv := map[string]string{"MISSKEY_DOMAIN": "misskey.example.com", "MISSKEY_API_TOKEN": "token"}
+	tests := []struct {
+		name          string
+		mode          app.Mode
+		env           map[string]string
+		config        *config.Config
+		instances     []config.MisskeyInstance
+		geocoder      amesh.Geocoder
+		response      httpclient.MockResponse
+		expected      map[string]app.CheckStatus
+		expectedError error
+	}{
+		{
+			name:     "Misskeyのトークンとジオコーダが使える",
+			mode:     app.ModeMisskey,
+			env:      misskeyEnv,
+			config:   &config.Config{Contact: "https://example.com", Translate: &config.Translate{}},
+			geocoder: &fakeGeocoder{location: &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京都"}},
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"id":"9abc","username":"hato","isBot":true}`},
+			expected: map[string]app.CheckStatus{
+				"env":        app.CheckOK,
+				"misskey":    app.CheckOK,
+				"geocoder":   app.CheckOK,
+				"translate":  app.CheckOK,
+				"notifiers":  app.CheckSkip,
+				"history":    app.CheckSkip,
+				"rate_limit": app.CheckSkip,
+				"contact":    app.CheckOK,
+			},
+		},
+		{
+			name:     "無効なトークンは失敗し、ジオコーダの失敗は警告",
+			mode:     app.ModeMisskey,
+			env:      misskeyEnv,
+			config:   &config.Config{},
+			geocoder: &fakeGeocoder{err: httpclient.ErrHTTPRequestError},
+			response: httpclient.MockResponse{StatusCode: http.StatusUnauthorized, Body: `{"error":{}}`},
+			expected: map[string]app.CheckStatus{
+				"env":        app.CheckOK,
+				"misskey":    app.CheckFail,
+				"geocoder":   app.CheckWarn,
+				"translate":  app.CheckSkip,
+				"notifiers":  app.CheckSkip,
+				"history":    app.CheckSkip,
+				"rate_limit": app.CheckSkip,
+				"contact":    app.CheckWarn,
+			},
+			expectedError: app.ErrCheckFailed,
+		},
+		{
+			name:   "設定ファイルの複数のインスタンスをそれぞれ検査する",
+			mode:   app.ModeMisskey,
+			env:    map[string]string{"YAHOO_API_TOKEN": "key", "SECOND_TOKEN": "token2"},
+			config: &config.Config{Contact: "admin@example.com"},
+			instances: []config.MisskeyInstance{
+				{Name: "first", Domain: "misskey.example.com", Token: "token1"},
+				{Name: "second", Domain: "misskey.example.net", TokenEnv: "SECOND_TOKEN"},
+				{Name: "third", Domain: "misskey.example.org", TokenEnv: "THIRD_TOKEN"},
+			},
+			geocoder: &fakeGeocoder{location: &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京都"}},
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"id":"9abc","username":"hato","isBot":true}`},
+			expected: map[string]app.CheckStatus{
+				"env":            app.CheckOK,
+				"misskey:first":  app.CheckOK,
+				"misskey:second": app.CheckOK,
+				"misskey:third":  app.CheckFail,
+				"geocoder":       app.CheckOK,
+				"translate":      app.CheckSkip,
+				"notifiers":      app.CheckSkip,
+				"history":        app.CheckSkip,
+				"rate_limit":     app.CheckSkip,
+				"contact":        app.CheckOK,
+			},
+			expectedError: app.ErrCheckFailed,
+		},
+		{
+			name:   "必須の環境変数がない",
+			mode:   app.ModeMixi2,
+			env:    map[string]string{"MIXI2_CLIENT_ID": "id"},
+			config: &config.Config{Contact: "admin@example.com"},
+			expected: map[string]app.CheckStatus{
+				"env":        app.CheckFail,
+				"geocoder":   app.CheckWarn,
+				"translate":  app.CheckSkip,
+				"notifiers":  app.CheckSkip,
+				"history":    app.CheckSkip,
+				"rate_limit": app.CheckSkip,
+				"contact":    app.CheckOK,
+			},
+			expectedError: app.ErrCheckFailed,
+		},
+		{
+			name:     "YAHOO_API_TOKENがあればYahoo!ジオコーダを試す",
+			mode:     app.ModeServe,
+			env:      map[string]string{"YAHOO_API_TOKEN": "key"},
+			config:   &config.Config{Contact: "admin@example.com"},
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"Feature":[{"Name":"東京都","Geometry":{"Coordinates":"139.76,35.68"}}]}`},
+			expected: map[string]app.CheckStatus{
+				"env":        app.CheckSkip,
+				"geocoder":   app.CheckOK,
+				"translate":  app.CheckSkip,
+				"notifiers":  app.CheckSkip,
+				"history":    app.CheckSkip,
+				"rate_limit": app.CheckSkip,
+				"contact":    app.CheckOK,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Fallback: tt.response})
+			results, err := app.RunChecks(t.Context(), &app.CheckParams{
+				Mode:   tt.mode,
+				Common: &app.Common{Config: tt.config, Geocoder: tt.geocoder, MisskeyInstances: tt.instances},
+				Getenv: func(key string) string { return tt.env[key] },
+				Client: transport.Client(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			statuses := make(map[string]app.CheckStatus, len(results))
+			for _, result := range results {
+				statuses[result.Name] = result.Status
+			}
+			if diff := cmp.Diff(tt.expected, statuses); diff != "" {
+				t.Errorf("RunChecks() mismatch (-want +got):\n%s", diff)
+			}
+			if err := app.CheckError(results); !errors.Is(err, tt.expectedError) {
+				t.Errorf("CheckError() = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestWriteCheckTable(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	err := app.WriteCheckTable(&buf, []app.CheckResult{
+		{Name: "env", Status: app.CheckOK, Detail: "MISSKEY_DOMAIN"},
+		{Name: "geocoder", Status: app.CheckWarn, Detail: "embedded gazetteer only"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"CHECK     STATUS  DETAIL",
+		"env       ok      MISSKEY_DOMAIN",
+		"geocoder  warn    embedded gazetteer only",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("WriteCheckTable() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseCheckFlag(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		expected     bool
+		expectedArgs []string
+	}{
+		{
+			name:         "--checkを取り除く",
+			args:         []string{"misskey", "--check"},
+			expected:     true,
+			expectedArgs: []string{"misskey"},
+		},
+		{
+			name:         "-check",
+			args:         []string{"-check", "--mode", "serve"},
+			expected:     true,
+			expectedArgs: []string{"--mode", "serve"},
+		},
+		{
+			name:         "指定なし",
+			args:         []string{"serve", "--port", "9000"},
+			expectedArgs: []string{"serve", "--port", "9000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			check, args := app.ParseCheckFlag(tt.args)
+			if check != tt.expected {
+				t.Errorf("ParseCheckFlag() check = %v, want %v", check, tt.expected)
+			}
+			if diff := cmp.Diff(tt.expectedArgs, args); diff != "" {
+				t.Errorf("ParseCheckFlag() args mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
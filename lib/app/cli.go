@@ -0,0 +1,471 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amedas"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/translate"
+)
+
+// ErrInvalidArguments コマンドライン引数が不足していることを表すエラー
+var ErrInvalidArguments = errors.New("invalid arguments")
+
+// ErrExportComparison 複数の地点の比較画像をSVG・GeoJSONで書き出そうとしたことを表すエラー
+var ErrExportComparison = errors.New("SVG and GeoJSON export support only one place")
+
+// ErrExportForecast 予測のパネルを並べた画像をSVG・GeoJSONで書き出そうとしたことを表すエラー
+var ErrExportForecast = errors.New("SVG and GeoJSON export do not support forecast")
+
+// ErrExportHistory 過去の雨雲レーダーを並べた画像をSVG・GeoJSONで書き出そうとしたことを表すエラー
+var ErrExportHistory = errors.New("SVG and GeoJSON export do not support history")
+
+// printUsage CLIモードの使い方を出力する
+func printUsage() {
+	fmt.Println("Usage: go run main.go <command> <params>")
+	fmt.Println("Commands:")
+	fmt.Println("	amesh: Displays amesh, which is rain cloud information")
+	fmt.Println("	       Usage: go run main.go amesh <place name>")
+	fmt.Println("	       Usage: go run main.go amesh <latitude>,<longitude>")
+	fmt.Println("	       Usage: go run main.go amesh geo:<latitude>,<longitude>")
+	fmt.Println("	       Usage: go run main.go amesh 35°41'N 139°41'E")
+	fmt.Println("	       Usage: go run main.go amesh <place name> layer=flood|snow")
+	fmt.Println("	       Usage: go run main.go amesh <place name> <place name>...")
+	fmt.Println("	       Usage: go run main.go amesh <place name> forecast")
+	fmt.Println("	       Usage: go run main.go amesh history <place name> [30m-3h]")
+	fmt.Println("	       Usage: go run main.go amesh --svg <place name>")
+	fmt.Println("	       Usage: go run main.go amesh --geojson <place name>")
+	fmt.Println("	       Usage: go run main.go amesh --info <saved image>")
+	fmt.Println("	amedas: Displays the latest AMeDAS observation at the nearest station")
+	fmt.Println("	        Usage: go run main.go amedas <place name>")
+	fmt.Println("	        Usage: go run main.go amedas <latitude>,<longitude>")
+	fmt.Println("	map: Displays a map of the place with a marker and a scale bar, without rain clouds")
+	fmt.Println("	     Usage: go run main.go map <place name>")
+	fmt.Println("	     Usage: go run main.go map <latitude>,<longitude>")
+	fmt.Println("	translate: Translates text with the translation service in the config file")
+	fmt.Println("	           Usage: go run main.go translate <text>")
+	fmt.Println("	serve: Runs an HTTP server that returns amesh images")
+	fmt.Println("	       Usage: go run main.go serve [--port 8080] [--api-key <key>]")
+	fmt.Println("	       GET /amesh?place=<place name>&zoom=<zoom>&layer=<layer>&format=png|geojson")
+	fmt.Println("Note: without YAHOO_API_TOKEN, only major place names in the embedded gazetteer are available")
+}
+
+// printDiagnostics amesh画像の作成に使ったデータの情報を出力する
+func printDiagnostics(metadata *amesh.AmeshMetadata) {
+	radarTime := metadata.RadarTimeText()
+	if radarTime == "" {
+		radarTime = "-"
+	}
+	fmt.Printf("Radar time: %s\n", radarTime)
+	if metadata.Stale {
+		fmt.Println("Warning: radar data is stale (JMA data may not be updated)")
+	}
+	if forecastTimes := metadata.ForecastTimeTexts(); 0 < len(forecastTimes) {
+		fmt.Printf("Forecast: %s\n", strings.Join(forecastTimes, ", "))
+	}
+	if historyTimes := metadata.HistoryTimeTexts(); 0 < len(historyTimes) {
+		fmt.Printf("History: %s\n", strings.Join(historyTimes, ", "))
+	}
+	fmt.Printf("Lightning: %d\n", metadata.LightningCount)
+	fmt.Printf("Tiles: %d fetched, %d failed (%d without radar data)\n",
+		metadata.Tiles.Fetched, metadata.Tiles.Failed, metadata.Tiles.NoData)
+	fmt.Printf("Providers: %s\n", strings.Join(metadata.Providers, ", "))
+	fmt.Printf("Bounding box: %.4f,%.4f %.4f,%.4f\n",
+		metadata.BoundingBox.MinLat, metadata.BoundingBox.MinLng, metadata.BoundingBox.MaxLat, metadata.BoundingBox.MaxLng)
+}
+
+// RunCLI スタンドアロンモードで実行する
+// argsの先頭はサブコマンド（amesh・amedas・map・translate・serve）
+func RunCLI(ctx context.Context, common *Common, args []string) error {
+	if len(args) < 1 {
+		printUsage()
+		return ErrInvalidArguments
+	}
+
+	command := args[0]
+	switch command {
+	case "amesh":
+		if err := runAmesh(ctx, args[1:]); err != nil {
+			return errors.Wrap(err, "Failed to runAmesh")
+		}
+	case "amedas":
+		if err := runAmedas(ctx, common, args[1:]); err != nil {
+			return errors.Wrap(err, "Failed to runAmedas")
+		}
+	case "map":
+		if err := runMap(ctx, args[1:]); err != nil {
+			return errors.Wrap(err, "Failed to runMap")
+		}
+	case "translate":
+		if err := runTranslate(ctx, common, args[1:]); err != nil {
+			return errors.Wrap(err, "Failed to runTranslate")
+		}
+	case "serve":
+		if err := RunServe(ctx, common, args[1:]); err != nil {
+			return errors.Wrap(err, "Failed to RunServe")
+		}
+	default:
+		return errors.Errorf("Unknown command: %s", command)
+	}
+	return nil
+}
+
+// runAmesh amesh画像を作成してカレントディレクトリに保存する
+func runAmesh(ctx context.Context, args []string) (err error) {
+	if len(args) < 1 {
+		fmt.Println("amesh: Displays amesh, which is rain cloud information")
+		fmt.Println("Usage: go run main.go amesh <place name>")
+		fmt.Println("Usage: go run main.go amesh <latitude>,<longitude>")
+		fmt.Println("Usage: go run main.go amesh geo:<latitude>,<longitude>")
+		fmt.Println("Usage: go run main.go amesh 35°41'N 139°41'E")
+		fmt.Println("Usage: go run main.go amesh <place name> layer=flood|snow")
+		fmt.Println("Usage: go run main.go amesh <place name> <place name>...")
+		fmt.Println("Usage: go run main.go amesh <place name> forecast")
+		fmt.Println("Usage: go run main.go amesh history <place name> [30m-3h]")
+		fmt.Println("Usage: go run main.go amesh --svg <place name>")
+		fmt.Println("Usage: go run main.go amesh --geojson <place name>")
+		fmt.Println("Usage: go run main.go amesh --info <saved image>")
+		fmt.Println("Note: without YAHOO_API_TOKEN, only major place names in the embedded gazetteer are available")
+		return ErrInvalidArguments
+	}
+	if args[0] == "--info" {
+		if err := printImageInfo(args[1:]); err != nil {
+			return errors.Wrap(err, "Failed to printImageInfo")
+		}
+		return nil
+	}
+	// --svg・--geojsonの場合はPNG画像の代わりにその形式で保存する
+	format := ""
+	if args[0] == "--svg" || args[0] == "--geojson" {
+		format = strings.TrimPrefix(args[0], "--")
+		args = args[1:]
+	}
+
+	// 空白を含む座標（35.6 139.7や度分秒）は複数の引数になるため結合し、layer=の指定を取り出す
+	parseResult := amesh.ParseAmeshCommand("amesh " + strings.Join(args, " "))
+	apiKey := os.Getenv("YAHOO_API_TOKEN")
+
+	overlays, err := amesh.ParseOverlays(parseResult.Layer)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.ParseOverlays")
+	}
+
+	// 位置を解析（複数の地名が指定された場合は比較画像にする）
+	locations, err := amesh.ParseLocationsWithLog(ctx, parseResult.Place, apiKey)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.ParseLocationsWithLog")
+	}
+	if parseResult.History && format != "" {
+		return errors.Wrapf(ErrExportHistory, "format: %s", format)
+	}
+	if parseResult.Forecast && format != "" {
+		return errors.Wrapf(ErrExportForecast, "format: %s", format)
+	}
+	switch format {
+	case "svg":
+		if err := saveAmeshSVG(ctx, locations, overlays); err != nil {
+			return errors.Wrap(err, "Failed to saveAmeshSVG")
+		}
+		return nil
+	case "geojson":
+		if err := saveAmeshGeoJSON(ctx, locations, overlays); err != nil {
+			return errors.Wrap(err, "Failed to saveAmeshGeoJSON")
+		}
+		return nil
+	}
+
+	// amesh画像を作成し、エンコードしながら読み出す
+	imageReader, err := createImageReader(ctx, locations, overlays, &parseResult)
+	if err != nil {
+		return errors.Wrap(err, "Failed to createImageReader")
+	}
+	defer func(imageReader *amesh.ImageReader) {
+		if closeErr := imageReader.Close(); closeErr != nil {
+			log.Printf("Failed to Close: %v", closeErr)
+		}
+	}(imageReader)
+
+	// ファイル名を生成
+	fileName := amesh.GenerateFileName(&amesh.GenerateFileNameParams{Locations: locations, DataTime: imageReader.RadarTime})
+	cleanedFilePath := filepath.Clean(filepath.Join(".", fileName))
+
+	// ファイルに保存
+	file, err := os.Create(cleanedFilePath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Create")
+	}
+	defer func(file *os.File) {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "Failed to Close")
+		}
+	}(file)
+
+	if _, err := io.Copy(file, imageReader); err != nil {
+		return errors.Wrap(err, "Failed to io.Copy")
+	}
+
+	fmt.Printf("Amesh image saved to %s\n", cleanedFilePath)
+	printDiagnostics(&imageReader.AmeshMetadata)
+	return nil
+}
+
+// createImageReader amesh画像を作成する
+// historyかforecastが指定された場合は1か所の地点の過去の雨雲レーダーか予測のパネルを並べた画像、地点が複数の場合は比較画像にする
+func createImageReader(ctx context.Context, locations []*amesh.Location, overlays []amesh.OverlayName, parseResult *amesh.ParseAmeshCommandResult) (*amesh.ImageReader, error) {
+	if parseResult.History {
+		if len(locations) != 1 {
+			return nil, errors.Wrapf(amesh.ErrHistoryComparison, "places: %d", len(locations))
+		}
+		imageReader, err := amesh.CreateHistoryImageReader(ctx, locations[0], overlays, parseResult.HistoryDuration)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateHistoryImageReader")
+		}
+		return imageReader, nil
+	}
+	if !parseResult.Forecast {
+		imageReader, err := amesh.CreateImageReaderForLocations(ctx, locations, overlays)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateImageReaderForLocations")
+		}
+		return imageReader, nil
+	}
+	if len(locations) != 1 {
+		return nil, errors.Wrapf(amesh.ErrForecastComparison, "places: %d", len(locations))
+	}
+	imageReader, err := amesh.CreateForecastImageReader(ctx, locations[0], overlays)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateForecastImageReader")
+	}
+	return imageReader, nil
+}
+
+// saveAmeshSVG ベースマップと雨雲レーダーのPNG画像と、それを参照して距離円などを重ねたSVGをカレントディレクトリに保存する
+func saveAmeshSVG(ctx context.Context, locations []*amesh.Location, overlays []amesh.OverlayName) error {
+	if len(locations) != 1 {
+		return errors.Wrapf(ErrExportComparison, "places: %d", len(locations))
+	}
+
+	result, err := amesh.CreateSVGForLocation(ctx, locations[0], overlays)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.CreateSVGForLocation")
+	}
+	// SVGからは同じディレクトリのPNG画像を相対パスで参照する
+	fileName := amesh.GenerateFileName(&amesh.GenerateFileNameParams{Locations: locations, DataTime: result.RadarTime})
+
+	imageReader := result.Reader()
+	defer func(imageReader *amesh.ImageReader) {
+		if closeErr := imageReader.Close(); closeErr != nil {
+			log.Printf("Failed to Close: %v", closeErr)
+		}
+	}(imageReader)
+
+	rasterPath := filepath.Clean(filepath.Join(".", fileName))
+	if err := saveFile(rasterPath, imageReader); err != nil {
+		return errors.Wrap(err, "Failed to saveFile")
+	}
+	svgPath := strings.TrimSuffix(rasterPath, filepath.Ext(rasterPath)) + ".svg"
+	if err := saveFile(svgPath, bytes.NewReader(result.SVG(fileName))); err != nil {
+		return errors.Wrap(err, "Failed to saveFile")
+	}
+
+	fmt.Printf("Amesh raster image saved to %s\n", rasterPath)
+	fmt.Printf("Amesh SVG saved to %s\n", svgPath)
+	printDiagnostics(&result.AmeshMetadata)
+	return nil
+}
+
+// saveAmeshGeoJSON 画像の作成に使う中心の地点・距離円・落雷地点・タイルの範囲をGeoJSONでカレントディレクトリに保存する
+func saveAmeshGeoJSON(ctx context.Context, locations []*amesh.Location, overlays []amesh.OverlayName) error {
+	if len(locations) != 1 {
+		return errors.Wrapf(ErrExportComparison, "places: %d", len(locations))
+	}
+
+	collection, err := amesh.CreateGeoJSONForLocation(ctx, locations[0], overlays)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.CreateGeoJSONForLocation")
+	}
+	data, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to json.MarshalIndent")
+	}
+
+	fileName := amesh.GenerateFileName(&amesh.GenerateFileNameParams{Locations: locations, DataTime: collection.Metadata.RadarTime})
+	path := filepath.Clean(filepath.Join(".", strings.TrimSuffix(fileName, filepath.Ext(fileName))+".geojson"))
+	if err := saveFile(path, bytes.NewReader(data)); err != nil {
+		return errors.Wrap(err, "Failed to saveFile")
+	}
+
+	fmt.Printf("Amesh GeoJSON saved to %s\n", path)
+	fmt.Printf("Features: %d\n", len(collection.Features))
+	printDiagnostics(&collection.Metadata)
+	return nil
+}
+
+// saveFile 読み出した内容をファイルに保存する
+func saveFile(path string, reader io.Reader) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Create")
+	}
+	defer func(file *os.File) {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "Failed to Close")
+		}
+	}(file)
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return errors.Wrap(err, "Failed to io.Copy")
+	}
+	return nil
+}
+
+// printImageInfo 保存したamesh画像に埋め込まれた地名・座標・時刻・出典などの情報を出力する
+// 負の緯度経度を引数に取れるよう、--infoはフラグとして解析せず先頭の引数のみ確認する
+func printImageInfo(args []string) (err error) {
+	if len(args) != 1 {
+		fmt.Println("Usage: go run main.go amesh --info <saved image>")
+		return ErrInvalidArguments
+	}
+
+	file, err := os.Open(filepath.Clean(args[0]))
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Open")
+	}
+	defer func(file *os.File) {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "Failed to Close")
+		}
+	}(file)
+
+	texts, err := amesh.ReadPNGText(file)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.ReadPNGText")
+	}
+	if len(texts) == 0 {
+		fmt.Println("No metadata")
+		return nil
+	}
+	for _, text := range texts {
+		fmt.Printf("%s: %s\n", text.Keyword, text.Text)
+	}
+	return nil
+}
+
+// runAmedas 最寄りの観測所の最新の観測値を出力する
+func runAmedas(ctx context.Context, common *Common, args []string) error {
+	if len(args) < 1 {
+		fmt.Println("amedas: Displays the latest AMeDAS observation at the nearest station")
+		fmt.Println("Usage: go run main.go amedas <place name>")
+		fmt.Println("Usage: go run main.go amedas <latitude>,<longitude>")
+		fmt.Println("Note: without YAHOO_API_TOKEN, only major place names in the embedded gazetteer are available")
+		return ErrInvalidArguments
+	}
+
+	place := strings.Join(args, " ")
+	apiKey := os.Getenv("YAHOO_API_TOKEN")
+
+	location, err := amesh.ParseLocation(ctx, place, apiKey)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.ParseLocation")
+	}
+
+	// 最寄りの観測所の観測値を取得
+	observation, err := amedas.GetObservation(ctx, location)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amedas.GetObservation")
+	}
+
+	data := &i18n.TemplateData{
+		Locale:    i18n.DefaultLocale,
+		PlaceName: location.PlaceName,
+		Lat:       location.Lat,
+		Lng:       location.Lng,
+	}
+	observation.FillTemplateData(data)
+
+	// 設定ファイルでテンプレートを指定しない場合はメッセージカタログの文言になる
+	fmt.Println(common.Templates.Render(i18n.KeyAmedasSuccess, data))
+	return nil
+}
+
+// runMap 地点のマーカーと縮尺を描画した地図画像を作成してカレントディレクトリに保存する
+func runMap(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		fmt.Println("map: Displays a map of the place with a marker and a scale bar, without rain clouds")
+		fmt.Println("Usage: go run main.go map <place name>")
+		fmt.Println("Usage: go run main.go map <latitude>,<longitude>")
+		fmt.Println("Note: without YAHOO_API_TOKEN, only major place names in the embedded gazetteer are available")
+		return ErrInvalidArguments
+	}
+
+	place := strings.Join(args, " ")
+	apiKey := os.Getenv("YAHOO_API_TOKEN")
+
+	location, err := amesh.ParseLocation(ctx, place, apiKey)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.ParseLocation")
+	}
+
+	// 地図画像を作成し、エンコードしながら読み出す
+	imageReader, err := amesh.CreateMapImageReader(ctx, location)
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.CreateMapImageReader")
+	}
+	defer func(imageReader *amesh.ImageReader) {
+		if closeErr := imageReader.Close(); closeErr != nil {
+			log.Printf("Failed to Close: %v", closeErr)
+		}
+	}(imageReader)
+
+	fileName := amesh.GenerateFileName(&amesh.GenerateFileNameParams{Locations: []*amesh.Location{location}, Prefix: "map"})
+	path := filepath.Clean(filepath.Join(".", fileName))
+	if err := saveFile(path, imageReader); err != nil {
+		return errors.Wrap(err, "Failed to saveFile")
+	}
+
+	fmt.Printf("Map image saved to %s\n", path)
+	printDiagnostics(&imageReader.AmeshMetadata)
+	return nil
+}
+
+// runTranslate 文章を日本語（日本語の場合は英語）に翻訳して表示する
+func runTranslate(ctx context.Context, common *Common, args []string) error {
+	if len(args) < 1 {
+		fmt.Println("translate: Translates text with the translation service in the config file")
+		fmt.Println("Usage: go run main.go translate <text>")
+		fmt.Println("Note: translate must be set in the config file")
+		return ErrInvalidArguments
+	}
+	if common.Translator == nil {
+		return errors.Errorf("Please set translate in the config file")
+	}
+
+	result, err := translate.Auto(ctx, &translate.AutoParams{
+		Translator: common.Translator,
+		Text:       strings.Join(args, " "),
+		Target:     string(i18n.DefaultLocale),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to translate.Auto")
+	}
+
+	data := &i18n.TemplateData{
+		Locale:         i18n.DefaultLocale,
+		Translation:    result.Text,
+		SourceLanguage: result.Source,
+		TargetLanguage: result.Target,
+	}
+	fmt.Println(common.Templates.Render(i18n.KeyTranslateSuccess, data))
+	return nil
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+)
+
+// RunDoctor 地名の検索・targetTimesの取得・タイルのダウンロード・画像の作成を実際に行い、手順ごとの成否を表示する
+// 失敗した手順がある場合はErrCheckFailedを返す（終了コード1）
+func RunDoctor(ctx context.Context, _ *Common, args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	output := flags.String("output", "", "save the rendered image to this path")
+	if err := flags.Parse(args); err != nil {
+		return errors.Wrap(err, "Failed to flags.Parse")
+	}
+
+	// Initで設定ファイルのジオコーダをameshの既定のジオコーダに設定済み
+	result, err := amesh.RunSelfTest(ctx, os.Getenv("YAHOO_API_TOKEN"))
+	if err != nil {
+		return errors.Wrap(err, "Failed to amesh.RunSelfTest")
+	}
+	if err := WriteCheckTable(os.Stdout, selfTestResults(result.Steps)); err != nil {
+		return errors.Wrap(err, "Failed to WriteCheckTable")
+	}
+
+	if *output != "" && result.Image != nil {
+		cleanedFilePath := filepath.Clean(*output)
+		if err := os.WriteFile(cleanedFilePath, result.Image, 0o600); err != nil {
+			return errors.Wrap(err, "Failed to os.WriteFile")
+		}
+		fmt.Printf("Rendered image saved to %s\n", cleanedFilePath)
+	}
+
+	if result.Failed() {
+		return errors.Wrap(ErrCheckFailed, "self-test failed")
+	}
+	return nil
+}
+
+// selfTestResults 自己診断の手順の結果を検査の結果の表の形式にする
+func selfTestResults(steps []amesh.SelfTestStep) []CheckResult {
+	results := make([]CheckResult, 0, len(steps))
+	for _, step := range steps {
+		result := CheckResult{Name: step.Name, Status: CheckOK, Detail: step.Detail}
+		if step.Err != nil {
+			result.Status, result.Detail = CheckFail, step.Err.Error()
+		}
+		result.Detail += fmt.Sprintf(" (%s)", step.Elapsed.Round(time.Millisecond))
+		results = append(results, result)
+	}
+	return results
+}
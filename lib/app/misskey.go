@@ -0,0 +1,972 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/convert"
+	"hato-bot-go/lib/earthquake"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/jobqueue"
+	"hato-bot-go/lib/misskey"
+	"hato-bot-go/lib/notify"
+	"hato-bot-go/lib/report"
+	"hato-bot-go/lib/state"
+)
+
+// replyPolicyFromEnv 指定した接頭辞の環境変数から返信方針を取得する
+// 接頭辞に続けてMODE（reply/quote）、VISIBILITY（public/home/followers/specified）、
+// VISIBILITY_RULES（公開範囲の規則、misskey.ParseVisibilityRulesの書式）、LOCAL_ONLY（true/false）、
+// CW（replace/mirror/weather）、CW_TEXT（replaceで付けるCWの文言）を設定する
+func replyPolicyFromEnv(prefix string) (*misskey.ReplyPolicy, error) {
+	policy := misskey.ReplyPolicy{
+		Mode:       misskey.ReplyMode(os.Getenv(prefix + "MODE")),
+		Visibility: os.Getenv(prefix + "VISIBILITY"),
+		CW:         misskey.CWMode(os.Getenv(prefix + "CW")),
+		CWText:     os.Getenv(prefix + "CW_TEXT"),
+	}
+
+	if localOnly := os.Getenv(prefix + "LOCAL_ONLY"); localOnly != "" {
+		parsed, err := strconv.ParseBool(localOnly)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.ParseBool")
+		}
+		policy.LocalOnly = parsed
+	}
+
+	rules, err := misskey.ParseVisibilityRules(os.Getenv(prefix + "VISIBILITY_RULES"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to misskey.ParseVisibilityRules")
+	}
+	policy.VisibilityRules = rules
+
+	if err := policy.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Failed to Validate")
+	}
+	return &policy, nil
+}
+
+// loopGuardSettingFromEnv 環境変数から返信のループを防ぐ設定を取得する
+// MISSKEY_REPLY_TO_SELF・MISSKEY_REPLY_TO_BOTS・MISSKEY_REPLY_TO_RENOTES（true/false）、
+// MISSKEY_MAX_REPLIES_PER_THREAD（会話スレッドへの返信の上限、負の場合は上限なし）、MISSKEY_THREAD_WINDOW（返信数を数える期間）を設定する
+func loopGuardSettingFromEnv() (*misskey.LoopGuardSetting, error) {
+	setting := &misskey.LoopGuardSetting{}
+	for key, target := range map[string]*bool{
+		"MISSKEY_REPLY_TO_SELF":    &setting.ReplyToSelf,
+		"MISSKEY_REPLY_TO_BOTS":    &setting.ReplyToBots,
+		"MISSKEY_REPLY_TO_RENOTES": &setting.ReplyToRenotes,
+	} {
+		if v := os.Getenv(key); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to strconv.ParseBool")
+			}
+			*target = parsed
+		}
+	}
+
+	if v := os.Getenv("MISSKEY_MAX_REPLIES_PER_THREAD"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.Atoi")
+		}
+		setting.MaxRepliesPerThread = parsed
+	}
+	if v := os.Getenv("MISSKEY_THREAD_WINDOW"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to time.ParseDuration")
+		}
+		if parsed <= 0 {
+			return nil, errors.Newf("MISSKEY_THREAD_WINDOW must be positive: %s", v)
+		}
+		setting.ThreadWindow = parsed
+	}
+	return setting, nil
+}
+
+// misskeyEnv 環境変数から取得する、全てのインスタンスで共通のMisskeyボットの設定
+type misskeyEnv struct {
+	yahooAPIToken     string
+	replyPolicy       *misskey.ReplyPolicy
+	ameshReplyPolicy  *misskey.ReplyPolicy
+	amedasReplyPolicy *misskey.ReplyPolicy
+	enableChat        bool
+	timelineChannels  []misskey.TimelineChannel
+	reactionTriggers  []misskey.ReactionTrigger
+	userLocales       map[string]i18n.Locale
+	transport         misskey.Transport
+	pollInterval      time.Duration
+	guardSetting      *misskey.LoopGuardSetting
+}
+
+// misskeyEnvFromEnv 環境変数からインスタンスで共通の設定を取得する
+func misskeyEnvFromEnv() (*misskeyEnv, error) {
+	env := &misskeyEnv{yahooAPIToken: os.Getenv("YAHOO_API_TOKEN")}
+
+	// 返信方針を取得
+	var err error
+	env.replyPolicy, err = replyPolicyFromEnv("MISSKEY_REPLY_")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to replyPolicyFromEnv")
+	}
+	env.ameshReplyPolicy, err = replyPolicyFromEnv("MISSKEY_AMESH_REPLY_")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to replyPolicyFromEnv")
+	}
+	env.amedasReplyPolicy, err = replyPolicyFromEnv("MISSKEY_AMEDAS_REPLY_")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to replyPolicyFromEnv")
+	}
+
+	// チャット（ダイレクトメッセージ）でのコマンド受付を有効にするか
+	if v := os.Getenv("MISSKEY_ENABLE_CHAT"); v != "" {
+		env.enableChat, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.ParseBool")
+		}
+	}
+
+	// メンションなしでも応答するタイムラインチャンネルを取得
+	env.timelineChannels, err = misskey.ParseTimelineChannels(os.Getenv("MISSKEY_TIMELINE_CHANNELS"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to misskey.ParseTimelineChannels")
+	}
+
+	// ボットのノートへのリアクションで実行するコマンドを取得
+	env.reactionTriggers, err = misskey.ParseReactionTriggers(os.Getenv("MISSKEY_REACTION_TRIGGERS"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to misskey.ParseReactionTriggers")
+	}
+
+	// 返信メッセージの言語を取得
+	env.userLocales, err = misskey.ParseUserLocales(os.Getenv("MISSKEY_USER_LOCALES"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to misskey.ParseUserLocales")
+	}
+
+	// イベントの受信方法を取得（WebSocketが使えない環境ではポーリングを選ぶ）
+	env.transport, err = misskey.ParseTransport(os.Getenv("MISSKEY_TRANSPORT"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to misskey.ParseTransport")
+	}
+	env.pollInterval = misskey.DefaultPollInterval
+	if v := os.Getenv("MISSKEY_POLL_INTERVAL"); v != "" {
+		env.pollInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to time.ParseDuration")
+		}
+		if env.pollInterval <= 0 {
+			return nil, errors.Newf("MISSKEY_POLL_INTERVAL must be positive: %s", v)
+		}
+	}
+
+	// 返信のループを防ぐ設定を取得
+	env.guardSetting, err = loopGuardSettingFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to loopGuardSettingFromEnv")
+	}
+	return env, nil
+}
+
+// misskeyInstances 接続するインスタンスを返す
+// 設定ファイルのmisskey_instancesがない場合は環境変数MISSKEY_DOMAIN・MISSKEY_API_TOKENのアカウントのみに接続する
+func misskeyInstances(configured []config.MisskeyInstance) ([]config.MisskeyInstance, error) {
+	if 0 < len(configured) {
+		return configured, nil
+	}
+	domain := os.Getenv("MISSKEY_DOMAIN")
+	token := os.Getenv("MISSKEY_API_TOKEN")
+	if domain == "" || token == "" {
+		return nil, errors.New("MISSKEY_DOMAIN and MISSKEY_API_TOKEN environment variables must be set")
+	}
+	return []config.MisskeyInstance{{Domain: domain, Token: token}}, nil
+}
+
+// RunMisskey Misskeyボットとして実行する
+// 設定ファイルのmisskey_instancesに複数のインスタンスがある場合は全てに接続し、画像の作成やキャッシュを共有する
+func RunMisskey(ctx context.Context, common *Common, _ []string) error {
+	instances, err := misskeyInstances(common.MisskeyInstances)
+	if err != nil {
+		return errors.Wrap(err, "Failed to misskeyInstances")
+	}
+	env, err := misskeyEnvFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Failed to misskeyEnvFromEnv")
+	}
+
+	// Yahoo APIキーも設定ファイルのジオコーダもない場合は埋め込みの地名の一覧だけで地名を探す
+	if env.yahooAPIToken == "" && common.Geocoder == nil {
+		log.Println("YAHOO_API_TOKEN is not set: place names are resolved from the embedded gazetteer only")
+	}
+
+	// エラー報告を設定（全てのトークンは送信内容から除去する）
+	secrets := []string{env.yahooAPIToken}
+	for i := range instances {
+		secrets = append(secrets, instances[i].ResolveToken(os.Getenv))
+	}
+	reporter, err := report.NewReporterFromEnv(secrets...)
+	if err != nil {
+		return errors.Wrap(err, "Failed to report.NewReporterFromEnv")
+	}
+
+	// 処理を待つメッセージの上限と同時に処理する数は全てのインスタンスで共有する
+	shedder := newShedder(common.LoadShedding)
+	go shedder.Run(ctx)
+
+	if len(instances) == 1 {
+		return runMisskeyInstance(ctx, &misskeyInstanceParams{
+			common:   common,
+			env:      env,
+			instance: &instances[0],
+			reporter: reporter,
+			shedder:  shedder,
+		})
+	}
+
+	// インスタンスごとに接続し、停止したインスタンスがあっても他のインスタンスは続ける
+	var wg sync.WaitGroup
+	errs := make([]error, len(instances))
+	for i := range instances {
+		wg.Go(func() {
+			err := runMisskeyInstance(ctx, &misskeyInstanceParams{
+				common:   common,
+				env:      env,
+				instance: &instances[i],
+				reporter: reporter,
+				shedder:  shedder,
+			})
+			if err != nil {
+				log.Printf("Misskey instance %s stopped: %v", instances[i].Name, err) //nolint:gosec //G706
+				errs[i] = errors.Wrapf(err, "instance: %s", instances[i].Name)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// misskeyInstanceParams runMisskeyInstanceのパラメータ
+type misskeyInstanceParams struct {
+	common   *Common
+	env      *misskeyEnv
+	instance *config.MisskeyInstance
+	reporter *report.Reporter
+	shedder  *bot.Shedder
+}
+
+// runMisskeyInstance 1つのインスタンスのアカウントでボットを初期化し、終了のシグナルを受け取るまで返信を続ける
+func runMisskeyInstance(ctx context.Context, params *misskeyInstanceParams) error {
+	common, env, instance := params.common, params.env, params.instance
+	domain := strings.NewReplacer("\n", "", "\r", "").Replace(instance.Domain)
+	token := instance.ResolveToken(os.Getenv)
+	if token == "" {
+		return errors.Wrapf(config.ErrInvalidMisskeyInstance, "%s: %s is not set", instance.Name, instance.TokenEnv)
+	}
+	requestInterval, err := instance.ParseRequestInterval()
+	if err != nil {
+		return errors.Wrap(err, "Failed to instance.ParseRequestInterval")
+	}
+
+	// インスタンスごとのタイムラインチャンネルを取得（未設定の場合は環境変数の値）
+	timelineChannels := env.timelineChannels
+	if instance.TimelineChannels != "" {
+		timelineChannels, err = misskey.ParseTimelineChannels(instance.TimelineChannels)
+		if err != nil {
+			return errors.Wrap(err, "Failed to misskey.ParseTimelineChannels")
+		}
+	}
+
+	// ボットを初期化（複数のインスタンスに接続する場合はログにインスタンス名を付ける）
+	opts := []misskey.Option{misskey.WithRateLimit(requestInterval)}
+	if instance.Name != "" {
+		opts = append(opts, misskey.WithLogger(slog.Default().With("instance", instance.Name)))
+	}
+	misskeyBot, err := misskey.NewBot(domain, token, opts...)
+	if err != nil {
+		return errors.Wrap(err, "Failed to misskey.NewBot")
+	}
+	misskeyBot.BotSetting.ReplyPolicy = *env.replyPolicy
+	misskeyBot.BotSetting.CommandReplyPolicies = map[string]misskey.ReplyPolicy{
+		"amesh":  *env.ameshReplyPolicy,
+		"amedas": *env.amedasReplyPolicy,
+	}
+	misskeyBot.BotSetting.TimelineChannels = timelineChannels
+	misskeyBot.BotSetting.ReactionTriggers = env.reactionTriggers
+	misskeyBot.BotSetting.Locale = i18n.ParseLocale(os.Getenv("MISSKEY_LOCALE"))
+	misskeyBot.BotSetting.UserLocales = env.userLocales
+	misskeyBot.BotSetting.Templates = common.Templates
+
+	// 地震情報の自動投稿（設定ファイルにearthquakeがあり、featuresで無効にしていない場合のみ）
+	if common.Config.Earthquake != nil && !slices.Contains(common.DisabledFeatures, featureEarthquake) {
+		subscriber, err := newEarthquakeSubscriber(&earthquakeParams{
+			bot:       misskeyBot,
+			setting:   common.Config.Earthquake,
+			templates: common.Templates,
+			reporter:  params.reporter,
+			notifier:  common.Notifier,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Failed to newEarthquakeSubscriber")
+		}
+		go func() {
+			if err := subscriber.Run(ctx); err != nil {
+				log.Printf("Failed to subscribe earthquake information: %v", err)
+			}
+		}()
+	}
+
+	// ドライブの古いファイルの削除（設定ファイルにdrive_pruneがある場合のみ）
+	if common.Config.DrivePrune != nil {
+		pruner, err := newDrivePruner(misskeyBot, common.Config.DrivePrune)
+		if err != nil {
+			return errors.Wrap(err, "Failed to newDrivePruner")
+		}
+		go func() {
+			if err := pruner.Run(ctx); err != nil {
+				log.Printf("Failed to prune drive files: %v", err)
+			}
+		}()
+	}
+
+	// 自分のノートに応答しないよう、ボット自身のユーザーIDを取得する
+	guardSetting := *env.guardSetting
+	if !guardSetting.ReplyToSelf {
+		account, err := misskeyBot.FetchAccount(ctx)
+		if err != nil {
+			log.Printf("Failed to fetch the bot account, notes from the bot itself are not detected: %v", err)
+		} else {
+			guardSetting.SelfID = account.ID
+		}
+	}
+
+	// インスタンスのバージョンから使える機能を判定し、対応していない機能は代わりの動作にする
+	if _, err := misskeyBot.DetectCapabilities(ctx); err != nil {
+		log.Printf("Failed to detect instance capabilities, assuming all features are supported: %v", err)
+	}
+	enableChat := env.enableChat
+	if enableChat && !misskeyBot.Capabilities().Chat {
+		log.Printf("Chat is not supported by %s: chat messages are not handled", domain) //nolint:gosec //G706
+		enableChat = false
+	}
+
+	// 処理中と成功のリアクションを設定し、カスタム絵文字がインスタンスにない場合はUnicodeの絵文字にする
+	platform := misskey.NewPlatform(misskeyBot)
+	for key, reaction := range map[string]bot.Reaction{
+		"MISSKEY_PROCESSING_REACTION": bot.ReactionProcessing,
+		"MISSKEY_SUCCESS_REACTION":    bot.ReactionSuccess,
+		"MISSKEY_BUSY_REACTION":       bot.ReactionBusy,
+	} {
+		if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+			platform.Reactions[reaction] = v
+		}
+	}
+	if err := platform.VerifyReactions(ctx); err != nil {
+		log.Printf("Failed to verify custom emoji reactions, using Unicode emoji instead: %v", err)
+	}
+	// 大きな画像はサムネイルを添付し、元の大きさの画像へのリンクを添える（image_limitsのthumbnail_dimensionを設定した場合のみ）
+	if limit := common.ImageLimits["misskey"]; limit != nil {
+		platform.ThumbnailDimension = limit.ThumbnailDimension
+	}
+
+	// ジオコーダと気象庁の確認が成功するまで受付を始めず、画像の作成が連続して失敗したら受付を止める
+	gate := newDependencyGate(common.DependencyGate, env.yahooAPIToken)
+
+	// インスタンスごとの実行回数の制限（未設定の場合は全体の制限を共有する）
+	rateLimiter := common.RateLimiter
+	if instance.RateLimit != nil {
+		window, err := instance.RateLimit.ParseWindow()
+		if err != nil {
+			return errors.Wrap(err, "Failed to instance.RateLimit.ParseWindow")
+		}
+		rateLimiter = bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: instance.RateLimit.Count, Window: window})
+		common.Reloader.OnReload(func(cfg *config.Config) {
+			reloadInstanceRateLimit(rateLimiter, cfg, instance.Name)
+		})
+	}
+
+	// インスタンスで受け付けるコマンド
+	commands, err := allowCommands(append(bot.DefaultCommands(env.yahooAPIToken, common.Translator), &convert.Command{}), instance.Commands)
+	if err != nil {
+		return errors.Wrapf(err, "instance: %s", instance.Name)
+	}
+
+	// コマンドを実行して返信するエンジン
+	engine := bot.NewEngine(&bot.EngineSetting{
+		Platform:      platform,
+		Commands:      commands,
+		Templates:     common.Templates,
+		Reporter:      params.reporter,
+		Timeouts:      common.CommandTimeouts,
+		RateLimiter:   rateLimiter,
+		History:       common.History,
+		Admins:        common.Config.Admins,
+		Reloader:      common.Reloader,
+		Disabled:      common.DisabledFeatures,
+		YahooAPIToken: env.yahooAPIToken,
+		Middlewares:   []bot.Middleware{gate.Middleware()},
+		Aliases:       common.Aliases,
+		ImageLimit:    common.ImageLimits["misskey"],
+	})
+
+	// 受け付けたコマンドを処理の前に保存し、前回の実行で処理しなかったコマンドを処理する
+	queue, err := newJobQueue(common.Config.JobQueue, common.State, instance.Name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to newJobQueue")
+	}
+	defer func() {
+		if err := queue.Close(); err != nil {
+			log.Printf("Failed to Close: %v", err)
+		}
+	}()
+
+	// 終了のシグナルを受け取るまでイベントを受信して返信する
+	return RunMisskeyLoop(ctx, &MisskeyLoopParams{
+		Bot:          misskeyBot,
+		Engine:       engine,
+		Gate:         gate,
+		Guard:        misskey.NewLoopGuard(&guardSetting),
+		Reporter:     params.reporter,
+		Queue:        queue,
+		Shedder:      params.shedder,
+		EnableChat:   enableChat,
+		Transport:    env.transport,
+		PollInterval: env.pollInterval,
+	})
+}
+
+// reloadInstanceRateLimit 読み込み直した設定ファイルのインスタンスの実行回数の制限を反映する
+// インスタンスのrate_limitを削除した場合は全体のrate_limitと同じ制限にする（回数はインスタンスごとに数える）
+func reloadInstanceRateLimit(limiter *bot.RateLimiter, cfg *config.Config, name string) {
+	instances, err := cfg.ParseMisskeyInstances()
+	if err != nil {
+		log.Printf("Failed to cfg.ParseMisskeyInstances: %v", err)
+		return
+	}
+	rateLimit := cfg.RateLimit
+	if index := slices.IndexFunc(instances, func(instance config.MisskeyInstance) bool { return instance.Name == name }); 0 <= index && instances[index].RateLimit != nil {
+		rateLimit = instances[index].RateLimit
+	}
+	window, err := rateLimit.ParseWindow()
+	if err != nil {
+		log.Printf("Failed to rateLimit.ParseWindow: %v", err)
+		return
+	}
+	limiter.SetLimit(rateLimitCount(rateLimit), window)
+}
+
+// errUnknownCommand 受け付けるコマンドに存在しないコマンド名を指定したことを表すエラー
+var errUnknownCommand = errors.New("unknown command")
+
+// allowCommands 受け付けるコマンド名に含まれるコマンドのみを返す（namesが空の場合は全て返す）
+func allowCommands(commands []bot.Command, names []string) ([]bot.Command, error) {
+	if len(names) == 0 {
+		return commands, nil
+	}
+	allowed := make([]bot.Command, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(commands, func(command bot.Command) bool { return command.Name() == name })
+		if index < 0 {
+			return nil, errors.Wrapf(errUnknownCommand, "command: %s", name)
+		}
+		allowed = append(allowed, commands[index])
+	}
+	return allowed, nil
+}
+
+// newShedder 処理を待つメッセージの上限を設けるShedderを作成する
+// 無効の場合はnilを返す（nilのShedderはその場で処理する）
+func newShedder(setting *bot.ShedderSetting) *bot.Shedder {
+	if setting == nil {
+		return nil
+	}
+	return bot.NewShedder(setting)
+}
+
+// newJobQueue 設定ファイルのジョブキューの設定からStoreを開く
+// 未設定の場合はnilを返し（nilのStoreは保存しない）、パスが空の場合は状態のディレクトリに保存する
+// 複数のインスタンスに接続する場合はインスタンスごとにファイルを分け、ファイル名の拡張子の前にインスタンス名を付ける
+func newJobQueue(cfg *config.JobQueue, stateDir *state.Dir, instance string) (*jobqueue.Store, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.MaxAttempts < 0 {
+		return nil, errors.Wrapf(config.ErrInvalidJobQueue, "max_attempts: %d", cfg.MaxAttempts)
+	}
+	retention, err := cfg.ParseRetention()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseRetention")
+	}
+	path := cfg.Path
+	if path == "" {
+		dir, err := stateDir.Ensure(state.Queue)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to stateDir.Ensure")
+		}
+		path = filepath.Join(dir, "jobs.jsonl")
+	}
+	if instance != "" {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "-" + instance + ext
+	}
+	store, err := jobqueue.Open(&jobqueue.StoreSetting{
+		Path:        path,
+		MaxAttempts: cfg.MaxAttempts,
+		Retention:   retention,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to jobqueue.Open")
+	}
+	return store, nil
+}
+
+// ジョブキューに保存するジョブの種類
+const (
+	jobKindNote = "note" // メンションのノート
+	jobKindChat = "chat" // チャットメッセージ
+)
+
+// errUnknownJobKind 復元できない種類のジョブであることを表すエラー
+var errUnknownJobKind = errors.New("unknown job kind")
+
+// restoreJobMessage ジョブキューに保存したノートまたはチャットメッセージを受信メッセージに戻す
+// チャットメッセージを受け付けない場合はチャットメッセージのジョブを復元しない
+func restoreJobMessage(job *jobqueue.Job, enableChat bool) (*bot.IncomingMessage, error) {
+	switch {
+	case job.Kind == jobKindNote:
+		var note misskey.Note
+		if err := json.Unmarshal(job.Payload, &note); err != nil {
+			return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+		}
+		return note.IncomingMessage(), nil
+	case job.Kind == jobKindChat && enableChat:
+		var message misskey.ChatMessage
+		if err := json.Unmarshal(job.Payload, &message); err != nil {
+			return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+		}
+		return message.IncomingMessage(), nil
+	default:
+		return nil, errors.Wrapf(errUnknownJobKind, "id: %s, kind: %s", job.ID, job.Kind)
+	}
+}
+
+// newDependencyGate ジオコーダと気象庁のtargetTimesを確認し、ameshコマンドの失敗を数えるGateを作成する
+// 無効の場合はnilを返す（nilのGateは常に受け付ける）
+func newDependencyGate(setting *bot.GateSetting, yahooAPIToken string) *bot.Gate {
+	if setting == nil {
+		return nil
+	}
+	gateSetting := *setting
+	gateSetting.Probes = []bot.Probe{
+		{Name: "geocoder", Check: func(ctx context.Context) error {
+			return amesh.RunProbeGeocoder(ctx, yahooAPIToken)
+		}},
+		{Name: "jma", Check: amesh.RunProbeTargetTimes},
+	}
+	gateSetting.Commands = []string{"amesh"}
+	gateSetting.IgnoreError = amesh.IsInputError
+	return bot.NewGate(&gateSetting)
+}
+
+// MisskeyLoopParams RunMisskeyLoopのパラメータ
+type MisskeyLoopParams struct {
+	Bot          *misskey.Bot       // イベントを受信して返信するボット
+	Engine       *bot.Engine        // 受信したメッセージのコマンドを実行するエンジン
+	Gate         *bot.Gate          // 依存する外部サービスによる受付の制御（nilの場合は常に受け付ける、EngineのMiddlewaresにも設定する）
+	Guard        *misskey.LoopGuard // 返信のループを防ぐノートの絞り込み（nilの場合は全てのノートに応答する）
+	Reporter     *report.Reporter   // 再接続やポーリングの失敗の報告先（nilの場合は報告しない）
+	Queue        *jobqueue.Store    // メンションとチャットメッセージのコマンドを処理の前に保存するキュー（nilの場合は保存しない）
+	Shedder      *bot.Shedder       // 処理を待つメッセージの上限（nilの場合は受信した順にその場で処理する、Runは呼び出し元で実行する）
+	EnableChat   bool               // チャットメッセージのコマンドを受け付けるか
+	Transport    misskey.Transport  // イベントの受信方法（空の場合はストリーミング）
+	PollInterval time.Duration      // ポーリングの間隔（TransportPollingの場合のみ使う、0の場合はmisskey.DefaultPollInterval）
+	Clock        clock.Clock        // 再接続までの待ち時間とポーリングの間隔に使う時計（nilの場合はclock.Real）
+}
+
+// RunMisskeyLoop Misskeyのイベントを受信してコマンドを実行し、終了のシグナルを受け取るまで返信を続ける
+// ストリーミングの場合は接続が切れるたびに再接続する
+func RunMisskeyLoop(ctx context.Context, params *MisskeyLoopParams) error {
+	if params == nil || params.Bot == nil || params.Engine == nil {
+		return lib.ErrParamsNil
+	}
+	misskeyBot, engine, reporter := params.Bot, params.Engine, params.Reporter
+	clk := clock.Or(params.Clock)
+	domain := misskeyBot.BotSetting.Domain
+
+	// 依存する外部サービスが使えるようになるまでイベントを受信しない
+	if err := params.Gate.WaitReady(ctx); err != nil {
+		log.Println("stopped")
+		return nil
+	}
+	go params.Gate.Run(ctx)
+
+	// 処理を待つキューに入れる
+	// 混雑している場合は処理せず、コマンドには混雑のリアクションを付ける
+	dispatch := func(message *bot.IncomingMessage, handle func(ctx context.Context)) bool {
+		if params.Shedder.Submit(ctx, handle) {
+			return true
+		}
+		log.Printf("Shed message %s: %d messages waiting", message.ID, params.Shedder.Depth()) //nolint:gosec //G706
+		if err := engine.Shed(ctx, message); err != nil {
+			log.Printf("Failed to Shed: %v", err)
+		}
+		return false
+	}
+
+	handle := func(message *bot.IncomingMessage) {
+		dispatch(message, func(ctx context.Context) {
+			params.Gate.Submit(ctx, func(ctx context.Context) {
+				if err := engine.Handle(ctx, message); err != nil {
+					log.Printf("Failed to send error message: %v", err)
+				}
+			})
+		})
+	}
+
+	// ジョブキューのジョブの状態を更新しながら処理する
+	// 受付を止めている間に保留または破棄したジョブと、処理を待つ間に終了したジョブはpendingのまま残り、次の起動時に処理する
+	process := func(ctx context.Context, id string, message *bot.IncomingMessage) {
+		params.Gate.Submit(ctx, func(ctx context.Context) {
+			if err := params.Queue.Start(id); err != nil {
+				log.Printf("Failed to Start: %v", err)
+			}
+			if err := engine.Handle(ctx, message); err != nil {
+				log.Printf("Failed to send error message: %v", err)
+				if err := params.Queue.Fail(id, err); err != nil {
+					log.Printf("Failed to Fail: %v", err)
+				}
+				return
+			}
+			if err := params.Queue.Complete(id); err != nil {
+				log.Printf("Failed to Complete: %v", err)
+			}
+		})
+	}
+
+	// ジョブキューに保存してから処理する
+	// 再接続などで同じIDのメッセージを再び受信した場合は処理しない（保存に失敗した場合はコマンドを失わないよう処理する）
+	// 混雑のため処理しなかったジョブは次の起動時にも処理しない
+	enqueue := func(id, kind string, payload any, message *bot.IncomingMessage) {
+		added, err := params.Queue.Enqueue(id, kind, payload)
+		if err != nil {
+			log.Printf("Failed to Enqueue: %v", err)
+		} else if !added {
+			log.Printf("Skipped duplicate job %s", id) //nolint:gosec //G706
+			return
+		}
+		if !dispatch(message, func(ctx context.Context) { process(ctx, id, message) }) {
+			if err := params.Queue.Discard(id, bot.ErrOverloaded); err != nil {
+				log.Printf("Failed to Discard: %v", err)
+			}
+		}
+	}
+
+	// 前回の実行で処理しなかったジョブを、イベントの受信を始める前に受け取った順に処理する
+	for _, job := range params.Queue.Pending() {
+		message, err := restoreJobMessage(&job, params.EnableChat)
+		if err != nil {
+			// 復元できないジョブも試行回数を数え、上限に達したらfailedにする
+			log.Printf("Failed to restoreJobMessage: %v", err)
+			if err := errors.Join(params.Queue.Start(job.ID), params.Queue.Fail(job.ID, err)); err != nil {
+				log.Printf("Failed to Fail: %v", err)
+			}
+			continue
+		}
+		log.Printf("Resuming job %s", job.ID) //nolint:gosec //G706
+		process(ctx, job.ID, message)
+	}
+
+	// ボット自身やほかのボットのノート、リノート、返信の多すぎる会話スレッドには応答しない
+	guarded := func(note *misskey.Note) bool {
+		if reason := params.Guard.Check(note); reason != misskey.SkipNone {
+			log.Printf("Skipped note %s: %s", note.ID, reason) //nolint:gosec //G706
+			return false
+		}
+		return true
+	}
+
+	handlers := &misskey.EventHandlers{
+		OnMention: func(note *misskey.Note) {
+			if guarded(note) {
+				enqueue(note.ID, jobKindNote, note, note.IncomingMessage())
+			}
+		},
+		// タイムラインのノートは許可されたコマンドのみ処理
+		OnTimelineNote: func(note *misskey.Note, channel *misskey.TimelineChannel) {
+			if !guarded(note) {
+				return
+			}
+			message := note.IncomingMessage()
+			message.Allows = channel.Allows
+			handle(message)
+		},
+		// リアクションの付いたノートの地名でコマンドを実行し、リアクションを付けたユーザーに返信する
+		OnReaction: func(event *misskey.ReactionEvent, trigger *misskey.ReactionTrigger) {
+			note, message, ok := reactionMessage(event, trigger)
+			if ok && guarded(note) {
+				handle(message)
+			}
+		},
+	}
+	if params.EnableChat {
+		// チャットメッセージハンドラー
+		handlers.OnChatMessage = func(message *misskey.ChatMessage) {
+			enqueue(message.ID, jobKindChat, message, message.IncomingMessage())
+		}
+	}
+
+	if params.Transport == misskey.TransportPolling {
+		interval := params.PollInterval
+		if interval <= 0 {
+			interval = misskey.DefaultPollInterval
+		}
+		return pollMisskey(ctx, &pollMisskeyParams{
+			bot:      misskeyBot,
+			handlers: handlers,
+			reporter: reporter,
+			interval: interval,
+			clock:    clk,
+		})
+	}
+
+	// WebSocket接続を確立
+	if err := misskeyBot.Connect(); err != nil {
+		return errors.Wrap(err, "Failed to Connect")
+	}
+
+	log.Printf("hato-bot-go started on %s", domain) //nolint:gosec //G706
+
+	// 再接続が連続して失敗した場合に報告する
+	reconnectFailures := &report.FailureCounter{Threshold: 3}
+
+	// WebSocketメッセージを監視（終了のシグナルを受け取るまで再接続を続ける）
+	for ctx.Err() == nil {
+		if err := misskeyBot.ListenEvents(ctx, handlers); err != nil {
+			log.Printf("WebSocket connection lost: %v", err)
+			log.Println("Attempting to reconnect...")
+
+			// 再接続を試行
+			if clk.Sleep(ctx, 5*time.Second) != nil {
+				break
+			}
+			if err = misskeyBot.Connect(); err != nil {
+				log.Printf("Failed to reconnect: %v", err)
+				if reconnectFailures.Fail() {
+					reporter.Report(context.Background(), &report.Event{
+						Level:   report.LevelFatal,
+						Message: fmt.Sprintf("Failed to reconnect to Misskey %d times in a row", reconnectFailures.Count()),
+						Err:     err,
+						Tags:    map[string]string{"platform": "misskey"},
+					})
+				}
+				_ = clk.Sleep(ctx, 10*time.Second)
+				continue
+			}
+			reconnectFailures.Reset()
+		}
+	}
+
+	log.Println("stopped")
+	return nil
+}
+
+// reactionMessage リアクションの付いたノートの地名でコマンドを実行するメッセージを作成する
+// 返信はリアクションを付けたユーザーに宛てるため、ノートの投稿者をそのユーザーに置き換えたノートも返す
+// ノートの本文に地名がない場合はfalseを返す
+func reactionMessage(event *misskey.ReactionEvent, trigger *misskey.ReactionTrigger) (*misskey.Note, *bot.IncomingMessage, bool) {
+	place := amesh.ExtractPlace(event.Note.Text)
+	if place == "" {
+		log.Printf("Skipped reaction on %s: no place name in the note", event.Note.ID) //nolint:gosec //G706
+		return nil, nil, false
+	}
+
+	note := *event.Note
+	note.User = event.User
+	message := note.IncomingMessage()
+	message.Text = trigger.Command + " " + place
+	message.ReplyText = ""
+	message.Allows = func(command string) bool { return command == trigger.Command }
+	return &note, message, true
+}
+
+// newDrivePruner 設定ファイルのドライブの古いファイルの削除の設定からDrivePrunerを作成する
+func newDrivePruner(misskeyBot *misskey.Bot, cfg *config.DrivePrune) (*misskey.DrivePruner, error) {
+	retention, err := cfg.ParseRetention()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseRetention")
+	}
+	interval, err := cfg.ParseInterval()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to cfg.ParseInterval")
+	}
+	return misskey.NewDrivePruner(misskeyBot, &misskey.DrivePrunerSetting{
+		Retention:  retention,
+		UsageLimit: cfg.UsageLimit,
+		Interval:   interval,
+	}), nil
+}
+
+// earthquakeParams newEarthquakeSubscriberのパラメータ
+type earthquakeParams struct {
+	bot       *misskey.Bot
+	setting   *config.Earthquake
+	templates *i18n.Templates
+	reporter  *report.Reporter
+	notifier  *notify.Dispatcher // 地震情報を転送する通知の送信先（nilの場合は転送しない）
+}
+
+// newEarthquakeSubscriber 最大震度が設定値以上の地震をノートに投稿するSubscriberを作成する
+func newEarthquakeSubscriber(params *earthquakeParams) (*earthquake.Subscriber, error) {
+	minScale, err := earthquake.ParseScale(params.setting.MinIntensity)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to earthquake.ParseScale")
+	}
+	if err := (misskey.ReplyPolicy{Visibility: params.setting.Visibility}).Validate(); err != nil {
+		return nil, errors.Wrap(err, "Failed to Validate")
+	}
+
+	return earthquake.NewSubscriber(&earthquake.SubscriberSetting{
+		URL:      params.setting.URL,
+		MinScale: minScale,
+		Handler: func(ctx context.Context, quake *earthquake.Quake) error {
+			if err := postEarthquake(ctx, params, quake); err != nil {
+				params.reporter.Report(ctx, &report.Event{
+					Message: "Failed to post earthquake information",
+					Err:     err,
+					Tags:    map[string]string{"platform": "misskey"},
+				})
+				return errors.Wrap(err, "Failed to postEarthquake")
+			}
+			return nil
+		},
+	}), nil
+}
+
+// postEarthquake 地震情報をノートに投稿し、通知の送信先にも転送する
+// 地図の作成やアップロードに失敗した場合は地図なしで投稿し、投稿に失敗した場合はアップロードした地図を削除する
+func postEarthquake(ctx context.Context, params *earthquakeParams, quake *earthquake.Quake) error {
+	data := &i18n.TemplateData{Locale: params.bot.BotSetting.Locale}
+	quake.FillTemplateData(data)
+	text := params.templates.Render(i18n.KeyEarthquakeAlert, data)
+
+	var mapImage []byte
+	var fileIDs []string
+	if params.setting.Map && quake.HasHypocenter() {
+		var err error
+		mapImage, err = createEarthquakeMap(ctx, quake)
+		if err != nil {
+			log.Printf("Failed to create earthquake map: %v", err)
+		} else if file, err := params.bot.UploadFile(ctx, bytes.NewReader(mapImage), quake.FileName()); err != nil {
+			log.Printf("Failed to upload earthquake map: %v", err)
+		} else {
+			fileIDs = []string{file.ID}
+		}
+	}
+
+	if err := params.bot.PostNote(ctx, &misskey.PostNoteParams{
+		Text:       text,
+		FileIDs:    fileIDs,
+		Visibility: params.setting.Visibility,
+		LocalOnly:  params.setting.LocalOnly,
+	}); err != nil {
+		// 投稿に使えなかった地図はドライブに残さない（タイムアウトによる失敗でも削除できるようctxのキャンセルは引き継がない）
+		for _, fileID := range fileIDs {
+			if deleteErr := params.bot.DeleteFile(context.WithoutCancel(ctx), fileID); deleteErr != nil {
+				log.Printf("Failed to delete uploaded file %s: %v", fileID, deleteErr)
+			}
+		}
+		return errors.Wrap(err, "Failed to PostNote")
+	}
+	log.Printf("Posted earthquake information: %s %s", quake.ID(), quake.Epicenter)
+
+	// 通知の送信先への転送に失敗してもノートの投稿は成功として扱う
+	if err := params.notifier.Notify(ctx, &notify.Notification{
+		Text:     text,
+		Image:    mapImage,
+		FileName: quake.FileName(),
+	}); err != nil {
+		log.Printf("Failed to notify earthquake information: %v", err)
+	}
+	return nil
+}
+
+// createEarthquakeMap 震源の地図を作成し、PNG形式の画像を返す
+// アップロードと通知の送信先への転送で同じ画像を使うためメモリ上に読み込む
+func createEarthquakeMap(ctx context.Context, quake *earthquake.Quake) ([]byte, error) {
+	reader, err := earthquake.CreateMapReader(ctx, quake)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to earthquake.CreateMapReader")
+	}
+	defer func() { _ = reader.Close() }()
+
+	image, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to io.ReadAll")
+	}
+	return image, nil
+}
+
+// pollMisskeyParams pollMisskeyのパラメータ
+type pollMisskeyParams struct {
+	bot      *misskey.Bot
+	handlers *misskey.EventHandlers
+	reporter *report.Reporter
+	interval time.Duration
+	clock    clock.Clock
+}
+
+// pollMisskey 通知のポーリングでイベントを受信する（終了のシグナルを受け取るまで取得を続ける）
+func pollMisskey(ctx context.Context, params *pollMisskeyParams) error {
+	if params.handlers.OnChatMessage != nil || 0 < len(params.bot.BotSetting.TimelineChannels) {
+		log.Println("Chat messages and timeline channels are not supported in polling mode")
+	}
+	log.Printf("hato-bot-go started on %s (polling)", params.bot.BotSetting.Domain) //nolint:gosec //G706
+
+	// 通知の取得が連続して失敗した場合に報告する
+	pollFailures := &report.FailureCounter{Threshold: 3}
+
+	ticker := params.clock.NewTicker(params.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := params.bot.PollNotifications(ctx, params.handlers); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to poll notifications: %v", err)
+			if pollFailures.Fail() {
+				params.reporter.Report(context.Background(), &report.Event{
+					Level:   report.LevelFatal,
+					Message: fmt.Sprintf("Failed to poll Misskey notifications %d times in a row", pollFailures.Count()),
+					Err:     err,
+					Tags:    map[string]string{"platform": "misskey"},
+				})
+			}
+		} else {
+			pollFailures.Reset()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("stopped")
+			return nil
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/app"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/jobqueue"
+	"hato-bot-go/lib/misskey"
+	"hato-bot-go/lib/misskey/misskeytest"
+)
+
+// TestRunMisskeyLoop メンションを受信してからamesh画像をアップロードして返信するまでを偽のサーバーで通して確認する
+func TestRunMisskeyLoop(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+	server := misskeytest.NewServer(t, "token")
+	misskeyBot := server.NewBot()
+
+	engine := bot.NewEngine(&bot.EngineSetting{
+		Platform: misskey.NewPlatform(misskeyBot),
+		Commands: []bot.Command{&bot.AmeshCommand{Client: tiles.Client()}},
+	})
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.RunMisskeyLoop(ctx, &app.MisskeyLoopParams{Bot: misskeyBot, Engine: engine})
+	}()
+
+	mention := &misskey.Note{ID: "mention1", Text: "@hato amesh 東京", Visibility: "public"}
+	mention.User.ID = "user1"
+	mention.User.Username = "alice"
+	server.Mention(t, mention)
+
+	notes := server.WaitNotes(t, 1)
+	files := server.Files()
+	if len(files) != 1 {
+		t.Fatalf("Files() = %d files, want 1", len(files))
+	}
+	if _, err := png.Decode(bytes.NewReader(files[0].Data)); err != nil {
+		t.Errorf("uploaded file is not a PNG: %v", err)
+	}
+	if diff := cmp.Diff([]string{files[0].ID}, notes[0].FileIDs); diff != "" {
+		t.Errorf("FileIDs mismatch (-want +got):\n%s", diff)
+	}
+	if notes[0].ReplyID != mention.ID || !strings.Contains(notes[0].Text, "東京") {
+		t.Errorf("reply = %+v, want a reply to %s about 東京", notes[0], mention.ID)
+	}
+	if diff := cmp.Diff([]misskeytest.Reaction{{NoteID: mention.ID, Reaction: string(bot.ReactionProcessing)}}, server.Reactions()); diff != "" {
+		t.Errorf("Reactions() mismatch (-want +got):\n%s", diff)
+	}
+	if len(tiles.RequestsTo("targetTimes")) == 0 {
+		t.Errorf("no targetTimes requests: %v", tiles.Requests())
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunMisskeyLoop() error = %v", err)
+	}
+}
+
+// TestRunMisskeyLoopReaction ボットのノートへのリアクションで、ノートの地名のamesh画像を返信することを確認する
+func TestRunMisskeyLoopReaction(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+	server := misskeytest.NewServer(t, "token")
+	misskeyBot := server.NewBot()
+	misskeyBot.BotSetting.ReactionTriggers = []misskey.ReactionTrigger{{Reaction: ":amesh:", Command: "amesh"}}
+
+	engine := bot.NewEngine(&bot.EngineSetting{
+		Platform: misskey.NewPlatform(misskeyBot),
+		Commands: []bot.Command{&bot.AmeshCommand{Client: tiles.Client()}},
+	})
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.RunMisskeyLoop(ctx, &app.MisskeyLoopParams{Bot: misskeyBot, Engine: engine})
+	}()
+
+	note := &misskey.Note{ID: "note1", Text: "大阪府で震度3の地震がありました", Visibility: "home"}
+	note.User.ID = "bot"
+	server.Publish(t, "main", "notification", map[string]any{
+		"id":       "notification1",
+		"type":     "reaction",
+		"reaction": ":amesh@.:",
+		"note":     note,
+		"user":     map[string]any{"id": "user1", "username": "alice"},
+	})
+
+	notes := server.WaitNotes(t, 1)
+	if notes[0].ReplyID != note.ID || !strings.Contains(notes[0].Text, "大阪") {
+		t.Errorf("reply = %+v, want a reply to %s about 大阪", notes[0], note.ID)
+	}
+	if len(notes[0].FileIDs) != 1 {
+		t.Errorf("FileIDs = %v, want 1 file", notes[0].FileIDs)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunMisskeyLoop() error = %v", err)
+	}
+}
+
+// TestRunMisskeyLoopJobQueue 前回の実行で処理しなかったメンションを起動時に処理し、同じノートを再び受信しても処理しないことを確認する
+func TestRunMisskeyLoopJobQueue(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+	server := misskeytest.NewServer(t, "token")
+	misskeyBot := server.NewBot()
+
+	// 処理の途中で停止した前回の実行を再現する
+	path := filepath.Join(t.TempDir(), "jobs.jsonl")
+	queued := &misskey.Note{ID: "queued1", Text: "@hato amesh 東京", Visibility: "public"}
+	queued.User.ID = "user1"
+	previous, err := jobqueue.Open(&jobqueue.StoreSetting{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := previous.Enqueue(queued.ID, "note", queued); err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(previous.Start(queued.ID), previous.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := jobqueue.Open(&jobqueue.StoreSetting{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := bot.NewEngine(&bot.EngineSetting{
+		Platform: misskey.NewPlatform(misskeyBot),
+		Commands: []bot.Command{&bot.AmeshCommand{Client: tiles.Client()}},
+	})
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.RunMisskeyLoop(ctx, &app.MisskeyLoopParams{Bot: misskeyBot, Engine: engine, Queue: queue})
+	}()
+
+	mention := &misskey.Note{ID: "mention2", Text: "@hato amesh 大阪", Visibility: "public"}
+	mention.User.ID = "user2"
+	server.Mention(t, queued)
+	server.Mention(t, mention)
+
+	notes := server.WaitNotes(t, 2)
+	replies := []string{notes[0].ReplyID, notes[1].ReplyID}
+	if diff := cmp.Diff([]string{queued.ID, mention.ID}, replies); diff != "" {
+		t.Errorf("ReplyID mismatch (-want +got):\n%s", diff)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunMisskeyLoop() error = %v", err)
+	}
+	for _, id := range []string{queued.ID, mention.ID} {
+		if job, ok := queue.Get(id); !ok || job.Status != jobqueue.StatusDone {
+			t.Errorf("Get(%s) = %+v, %v, want done", id, job, ok)
+		}
+	}
+	if err := queue.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestRunMisskeyLoopReconnect ストリーミングの接続が切れた場合は待ち時間の後に再接続することを確認する
+func TestRunMisskeyLoopReconnect(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+	server := misskeytest.NewServer(t, "token")
+	misskeyBot := server.NewBot()
+	fake := clocktest.NewFake(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+
+	engine := bot.NewEngine(&bot.EngineSetting{
+		Platform: misskey.NewPlatform(misskeyBot),
+		Commands: []bot.Command{&bot.AmeshCommand{Client: tiles.Client()}},
+	})
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.RunMisskeyLoop(ctx, &app.MisskeyLoopParams{Bot: misskeyBot, Engine: engine, Clock: fake})
+	}()
+
+	first := &misskey.Note{ID: "mention1", Text: "@hato amesh 東京", Visibility: "public"}
+	first.User.ID = "user1"
+	server.Mention(t, first)
+	server.WaitNotes(t, 1)
+
+	// 接続が切れたら待ち時間が経つまで再接続しない
+	server.CloseStreams()
+	fake.BlockUntil(1)
+	fake.Advance(5 * time.Second)
+
+	second := &misskey.Note{ID: "mention2", Text: "@hato amesh 大阪", Visibility: "public"}
+	second.User.ID = "user1"
+	server.Mention(t, second)
+	notes := server.WaitNotes(t, 2)
+	if notes[1].ReplyID != second.ID {
+		t.Errorf("reply = %+v, want a reply to %s", notes[1], second.ID)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunMisskeyLoop() error = %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/metrics"
+)
+
+// ErrReloadUnavailable 設定ファイルを読み込み直せない実行環境であることを表すエラー
+var ErrReloadUnavailable = errors.New("config reload is unavailable")
+
+// ConfigReloader 設定ファイルを読み込み直し、再起動せずに反映する
+// 返信テンプレート・実行回数の制限・ログの出力レベルと、OnReloadで登録した実行モードごとの設定を反映し、
+// WebSocketなどの接続は切らない
+// 読み込みや検査に失敗した場合は何も反映せず、前の設定のまま動く
+type ConfigReloader struct {
+	common *Common
+	load   func() (*config.Config, error)
+	mu     sync.Mutex
+	hooks  []func(cfg *config.Config)
+}
+
+// NewConfigReloader 新しいConfigReloaderを作成する
+// loadがnilの場合は環境変数HATO_BOT_CONFIGの設定ファイルを読み込む
+func NewConfigReloader(common *Common, load func() (*config.Config, error)) *ConfigReloader {
+	if load == nil {
+		load = config.LoadFromEnv
+	}
+	return &ConfigReloader{common: common, load: load}
+}
+
+// OnReload 設定ファイルを読み込み直したときに呼び出す処理を登録する
+// hookには検査済みの設定を渡す
+func (r *ConfigReloader) OnReload(hook func(cfg *config.Config)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// Reload 設定ファイルを読み込み直し、全ての設定を検査してから反映する
+func (r *ConfigReloader) Reload() error {
+	if r == nil || r.common == nil {
+		return ErrReloadUnavailable
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.load()
+	if err != nil {
+		return errors.Wrap(err, "Failed to load")
+	}
+	templates, err := i18n.ParseTemplates(cfg.Templates)
+	if err != nil {
+		return errors.Wrap(err, "Failed to i18n.ParseTemplates")
+	}
+	rateLimitWindow, err := cfg.RateLimit.ParseWindow()
+	if err != nil {
+		return errors.Wrap(err, "Failed to cfg.RateLimit.ParseWindow")
+	}
+	logLevel, err := cfg.ParseLogLevel()
+	if err != nil {
+		return errors.Wrap(err, "Failed to cfg.ParseLogLevel")
+	}
+	if _, err := cfg.ParseMisskeyInstances(); err != nil {
+		return errors.Wrap(err, "Failed to cfg.ParseMisskeyInstances")
+	}
+
+	r.common.Templates.Replace(templates)
+	r.common.RateLimiter.SetLimit(rateLimitCount(cfg.RateLimit), rateLimitWindow)
+	slog.SetLogLoggerLevel(logLevel)
+	for _, hook := range r.hooks {
+		hook(cfg)
+	}
+
+	metrics.Default.Counter("app.reloads").Inc()
+	log.Printf("Config reloaded (log level: %s)", logLevel)
+	return nil
+}
+
+// Run SIGHUPを受け取るたびに設定ファイルを読み込み直す
+// ctxが終了するまで戻らない
+func (r *ConfigReloader) Run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := r.Reload(); err != nil {
+				log.Printf("Failed to reload the config file, keeping the previous settings: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/app"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestConfigReloaderReload(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           *config.Config
+		expectedTemplate string
+		expectedAllowed  []bool
+		expectedHooks    int
+		expectedError    error
+	}{
+		{
+			name: "返信テンプレートと実行回数の制限を反映",
+			config: &config.Config{
+				Templates: map[string]string{"reply.cw": "after"},
+				RateLimit: &config.RateLimit{Count: 1, Window: "1m"},
+			},
+			expectedTemplate: "after",
+			expectedAllowed:  []bool{true, false},
+			expectedHooks:    1,
+		},
+		{
+			name:             "rate_limitを削除した場合は制限しない",
+			config:           &config.Config{},
+			expectedTemplate: "隠すっぽ！",
+			expectedAllowed:  []bool{true, true},
+			expectedHooks:    1,
+		},
+		{
+			name:             "不正な設定は反映しない",
+			config:           &config.Config{Templates: map[string]string{"reply.cw": "after"}, LogLevel: "verbose"},
+			expectedTemplate: "before",
+			expectedAllowed:  []bool{true, true, false},
+			expectedError:    config.ErrInvalidLogLevel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			templates, err := i18n.ParseTemplates(map[string]string{"reply.cw": "before"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			common := &app.Common{
+				Templates:   templates,
+				RateLimiter: bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: 2, Window: time.Minute}),
+			}
+			reloader := app.NewConfigReloader(common, func() (*config.Config, error) { return tt.config, nil })
+			hooks := 0
+			reloader.OnReload(func(_ *config.Config) { hooks++ })
+
+			if err := reloader.Reload(); !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Reload() error = %v, want %v", err, tt.expectedError)
+			}
+			if result := common.Templates.Render(i18n.KeyReplyCW, nil); result != tt.expectedTemplate {
+				t.Errorf("Render() = %q, want %q", result, tt.expectedTemplate)
+			}
+			for i, expected := range tt.expectedAllowed {
+				if allowed := common.RateLimiter.Allow("u1"); allowed != expected {
+					t.Errorf("Allow() #%d = %v, want %v", i, allowed, expected)
+				}
+			}
+			if hooks != tt.expectedHooks {
+				t.Errorf("hooks = %d, want %d", hooks, tt.expectedHooks)
+			}
+		})
+	}
+}
+
+// TestConfigReloaderNil 設定ファイルを読み込み直せない場合にエラーを返すことをテストする
+func TestConfigReloaderNil(t *testing.T) {
+	t.Parallel()
+	var reloader *app.ConfigReloader
+	reloader.OnReload(func(_ *config.Config) {})
+	if err := reloader.Reload(); !errors.Is(err, app.ErrReloadUnavailable) {
+		t.Errorf("Reload() error = %v, want %v", err, app.ErrReloadUnavailable)
+	}
+}
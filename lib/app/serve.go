@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/api"
+	"hato-bot-go/lib/httpclient"
+)
+
+// RunServe amesh画像を返すHTTPサーバーを実行する
+// /status・/metricsのエンドポイントもボットと同様に提供する
+func RunServe(ctx context.Context, common *Common, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := flags.String("port", "8080", "listen port")
+	apiKey := flags.String("api-key", os.Getenv("HATO_API_KEY"), "API key required in the X-API-Key header (no authentication if empty)")
+	if err := flags.Parse(args); err != nil {
+		return errors.Wrap(err, "Failed to flags.Parse")
+	}
+
+	yahooAPIToken := os.Getenv("YAHOO_API_TOKEN")
+	if yahooAPIToken == "" && common.Geocoder == nil {
+		log.Println("YAHOO_API_TOKEN is not set: place names are resolved from the embedded gazetteer only")
+	}
+
+	mux := http.NewServeMux()
+	lib.RegisterStatusHandlers(mux)
+	mux.Handle("/amesh", api.NewAmeshHandler(&api.AmeshHandlerParams{
+		Client: &http.Client{
+			Transport: httpclient.DefaultTransport,
+		},
+		YahooAPIToken: yahooAPIToken,
+		Geocoder:      common.Geocoder,
+		APIKey:        *apiKey,
+		TimestampCache: httpclient.NewResponseCache(&httpclient.ResponseCacheSetting{
+			Name: "targettimes",
+			TTL:  30 * time.Second,
+		}),
+	}))
+
+	var trustedProxies []netip.Prefix
+	if common.HTTPServer != nil {
+		trustedProxies = common.HTTPServer.TrustedProxies
+	}
+	server := lib.NewHTTPServer(*port, lib.LogRequests(mux, trustedProxies))
+	// タイルの取得とエンコードに時間がかかるため、書き込みのタイムアウトを延ばす
+	server.WriteTimeout = 60 * time.Second
+
+	// 終了のシグナルを受け取ったらグレースフルシャットダウンする
+	go func() {
+		<-ctx.Done()
+		log.Println("shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to Shutdown: %v", err)
+		}
+	}()
+
+	if *apiKey == "" {
+		log.Println("API key is not set: /amesh accepts requests without authentication")
+	}
+	log.Printf("Starting HTTP server on port %s (TLS: %t)", *port, common.HTTPServer.TLS())
+	if err := lib.ListenAndServe(server, common.HTTPServer); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "Failed to lib.ListenAndServe")
+	}
+	return nil
+}
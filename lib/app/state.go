@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/state"
+)
+
+// ErrUnknownStateCommand stateモードの存在しない操作を指定したことを表すエラー
+var ErrUnknownStateCommand = errors.New("unknown state command")
+
+// RunState 状態のディレクトリのサブディレクトリごとの使用量を表示する
+// 「purge 名前...」を指定した場合はサブディレクトリを削除する（名前を省略した場合は全て削除する）
+func RunState(_ context.Context, common *Common, args []string) error {
+	dir := common.State
+	if dir == nil {
+		dir = state.Resolve(nil)
+	}
+
+	switch {
+	case len(args) == 0 || args[0] == "usage":
+		usages, err := dir.Usage()
+		if err != nil {
+			return errors.Wrap(err, "Failed to dir.Usage")
+		}
+		return writeStateUsage(os.Stdout, "State directory: "+dir.Root, usages)
+	case args[0] == "purge":
+		purged, err := dir.Purge(args[1:]...)
+		if err != nil {
+			return errors.Wrap(err, "Failed to dir.Purge")
+		}
+		return writeStateUsage(os.Stdout, "Purged from "+dir.Root, purged)
+	default:
+		return errors.Wrapf(ErrUnknownStateCommand, "command: %s (available: usage, purge)", args[0])
+	}
+}
+
+// writeStateUsage 見出しとサブディレクトリごとの使用量を表で書き込む
+func writeStateUsage(w io.Writer, heading string, usages []state.Usage) error {
+	if _, err := fmt.Fprintln(w, heading); err != nil {
+		return errors.Wrap(err, "Failed to fmt.Fprintln")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "NAME\tFILES\tBYTES"); err != nil {
+		return errors.Wrap(err, "Failed to fmt.Fprintln")
+	}
+	for _, usage := range usages {
+		if _, err := fmt.Fprintf(tw, "%s\t%d\t%d\n", usage.Name, usage.Files, usage.Bytes); err != nil {
+			return errors.Wrap(err, "Failed to fmt.Fprintf")
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err, "Failed to Flush")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"maps"
+	"strings"
+
+	"golang.org/x/text/width"
+
+	"hato-bot-go/lib"
+)
+
+// NormalizeCommandWord 全角・半角と大文字・小文字の違いをなくしたコマンド名を返す
+// 全角の英数字は半角に、半角のカタカナは全角にそろえる（「ＡＭＥＳＨ」は「amesh」になる）
+func NormalizeCommandWord(word string) string {
+	return strings.ToLower(width.Fold.String(word))
+}
+
+// newAliases 別名の一覧を正規化した別名からコマンド名への索引にする
+// 受け付けるコマンドにない名前への別名は無視する（設定したコマンドが無効な実行モードでも同じ設定ファイルを使えるようにする）
+func newAliases(aliases map[string]string, commands []Command) map[string]string {
+	names := make(map[string]string, len(commands))
+	for _, command := range commands {
+		names[NormalizeCommandWord(command.Name())] = command.Name()
+	}
+	resolved := maps.Clone(names)
+	for alias, target := range aliases {
+		if name, ok := names[NormalizeCommandWord(target)]; ok {
+			resolved[NormalizeCommandWord(alias)] = name
+		}
+	}
+	return resolved
+}
+
+// resolveAlias 本文の先頭の単語が別名や表記の異なるコマンド名の場合は、コマンド名に置き換えた本文を返す
+// 置き換えない場合は本文をそのまま返す
+func (e *Engine) resolveAlias(text string) string {
+	word, args, ok := lib.SplitCommand(lib.NormalizeMessage(text))
+	if !ok {
+		return text
+	}
+	name, ok := e.aliases[NormalizeCommandWord(word)]
+	if !ok || name == word {
+		return text
+	}
+	return strings.TrimSpace(name + " " + args)
+}
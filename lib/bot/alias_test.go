@@ -0,0 +1,80 @@
+package bot_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/requestid"
+)
+
+func TestNormalizeCommandWord(t *testing.T) {
+	tests := []struct {
+		name     string
+		word     string
+		expected string
+	}{
+		{name: "全角の英字", word: "ＡＭＥＳＨ", expected: "amesh"},
+		{name: "大文字", word: "Amesh", expected: "amesh"},
+		{name: "半角のカタカナ", word: "ｱﾒｯｼｭ", expected: "アメッシュ"},
+		{name: "ひらがなはそのまま", word: "あめっしゅ", expected: "あめっしゅ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := bot.NormalizeCommandWord(tt.word); got != tt.expected {
+				t.Errorf("NormalizeCommandWord(%q) = %q, want %q", tt.word, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEngineHandleAlias(t *testing.T) {
+	aliases := map[string]string{
+		"あめっしゅ": "echo",
+		"雨雲":    "echo",
+		"ｴｺｰ":   "ECHO",
+		"地震":    "eq", // 受け付けるコマンドにない別名は無視する
+	}
+
+	tests := []struct {
+		name            string
+		text            string
+		expectedReplies []string
+	}{
+		{name: "別名", text: "雨雲 東京", expectedReplies: []string{"echo 東京 alice"}},
+		{name: "メンションとハッシュタグ付きの別名", text: "@hato #あめっしゅ 東京", expectedReplies: []string{"echo 東京 alice"}},
+		{name: "全角のコマンド名", text: "ＥＣＨＯ hello", expectedReplies: []string{"echo hello alice"}},
+		{name: "全角で書いた半角カタカナの別名", text: "エコー hello", expectedReplies: []string{"echo hello alice"}},
+		{name: "引数のない別名", text: "雨雲", expectedReplies: []string{"echo alice"}},
+		{name: "コマンドにない名前への別名", text: "地震"},
+		{name: "別名で始まらない文章", text: "今日は雨雲が多い"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			platform := &recordingPlatform{}
+			engine := bot.NewEngine(&bot.EngineSetting{
+				Platform: platform,
+				Commands: []bot.Command{&echoCommand{}},
+				Aliases:  aliases,
+			})
+
+			ctx := requestid.NewContext(t.Context(), testRequestID)
+			if err := engine.Handle(ctx, &bot.IncomingMessage{ID: "1", Text: tt.text}); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+
+			var replies []string
+			for _, reply := range platform.replies {
+				replies = append(replies, reply.Text)
+			}
+			if diff := cmp.Diff(tt.expectedReplies, replies); diff != "" {
+				t.Errorf("replies mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amedas"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// AmedasCommand 最寄りのアメダス観測所の観測値を返信するamedasコマンド
+type AmedasCommand struct {
+	YahooAPIToken string // ジオコーディング用Yahoo APIトークン（空の場合は埋め込みの地名の一覧だけで探す）
+}
+
+// Name コマンド名
+func (c *AmedasCommand) Name() string {
+	return "amedas"
+}
+
+// Match 本文がamedasコマンドかを返す
+func (c *AmedasCommand) Match(text string) bool {
+	return amedas.ParseAmedasCommand(text).IsAmedas
+}
+
+// Place 本文から履歴に記録する地名を返す
+func (c *AmedasCommand) Place(text string) string {
+	return amedas.ParseAmedasCommand(text).Place
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *AmedasCommand) ErrorKey(err error) i18n.Key {
+	return amedas.CommandErrorKey(err)
+}
+
+// Execute 地名の最寄りの観測所の観測値を取得し、返信を作成する
+func (c *AmedasCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	// 位置を解析
+	location, err := amesh.ParseLocation(ctx, amedas.ParseAmedasCommand(req.Message.Text).Place, c.YahooAPIToken)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseLocation")
+	}
+
+	// 最寄りの観測所の観測値を取得
+	observation, err := amedas.GetObservation(ctx, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amedas.GetObservation")
+	}
+
+	templateData := req.TemplateData
+	templateData.PlaceName = location.PlaceName
+	templateData.Lat = location.Lat
+	templateData.Lng = location.Lng
+	observation.FillTemplateData(templateData)
+
+	requestid.Logf(ctx, "Successfully fetched amedas observation for %s (%s)", location.PlaceName, observation.Station.Name)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyAmedasSuccess, templateData),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package bot_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestAmedasCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato amedas 東京", expected: true},
+		{name: "別のコマンド", text: "amesh 東京", expected: false},
+		{name: "コマンドでない", text: "こんにちは", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.AmedasCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestAmedasCommandExecute 外部APIにアクセスする前に失敗する場合をテストする
+func TestAmedasCommandExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		req           *bot.Request
+		expectedError error
+	}{
+		{
+			name:          "nilリクエスト",
+			token:         "token",
+			req:           nil,
+			expectedError: lib.ErrParamsNil,
+		},
+		{
+			name:          "テンプレートの変数なし",
+			token:         "token",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "amedas 東京"}},
+			expectedError: lib.ErrParamsNil,
+		},
+		{
+			name:          "Yahoo APIトークンがない場合は埋め込みの地名の一覧から探す",
+			token:         "",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "amedas 存在しない地名"}, TemplateData: &i18n.TemplateData{}},
+			expectedError: amesh.ErrNoResultsFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.AmedasCommand{YahooAPIToken: tt.token}
+			if _, err := command.Execute(t.Context(), tt.req); !errors.Is(err, tt.expectedError) {
+				t.Errorf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/elevation"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// AmeshImageRenderer ameshコマンドの画像を作成するレンダラー
+type AmeshImageRenderer interface {
+	// RenderLocations 1か所以上の地点の雨雲レーダー画像を作成する（複数の地点の場合は比較画像）
+	RenderLocations(ctx context.Context, locations []*amesh.Location, overlays []amesh.OverlayName) (*amesh.ImageReader, error)
+	// RenderForecast 1か所の地点の予測のパネルを並べた画像を作成する
+	RenderForecast(ctx context.Context, location *amesh.Location, overlays []amesh.OverlayName) (*amesh.ImageReader, error)
+	// RenderHistory 1か所の地点のdurationの間の過去の雨雲レーダーを並べた画像を作成する（0の場合はamesh.DefaultHistoryDuration）
+	RenderHistory(ctx context.Context, location *amesh.Location, overlays []amesh.OverlayName, duration time.Duration) (*amesh.ImageReader, error)
+}
+
+// AmeshCommand 雨雲レーダー画像を返信するameshコマンド
+type AmeshCommand struct {
+	YahooAPIToken  string             // ジオコーディング用Yahoo APIトークン（空の場合は埋め込みの地名の一覧だけで探す）
+	Client         httpclient.Doer    // HTTPクライアント（nilの場合はameshパッケージの既定のクライアントとジオコーダ）
+	Clock          clock.Clock        // 雨雲レーダーを描画していない画像のファイル名と、雨雲レーダーの経過時間の確認に使う時計（nilの場合はclock.Real）
+	StaleThreshold time.Duration      // 雨雲レーダーのデータが古いとみなす経過時間（0の場合は確認しない、Clientがnilの場合はamesh.SetStaleThresholdの設定を使う）
+	Geocoder       amesh.Geocoder     // 地名を探すジオコーダ（nilの場合はYahooAPITokenとClientに合わせたジオコーダ）
+	Renderer       AmeshImageRenderer // 画像を作成するレンダラー（nilの場合はClient・Clock・StaleThresholdでameshパッケージを使って作成する）
+	Elevation      bool               // 1か所の地点の画像の説明文に地点の標高を添えるか（Clientがnilの場合はelevation.SetAmeshCaptionの設定を使う）
+	Coverage       bool               // 1か所の地点の返信に距離円の区間ごとの主な市区町村を添えるか（Clientがnilの場合はamesh.SetCoverageの設定を使う）
+}
+
+// Name コマンド名
+func (c *AmeshCommand) Name() string {
+	return "amesh"
+}
+
+// Match 本文がameshコマンドかを返す
+func (c *AmeshCommand) Match(text string) bool {
+	return amesh.ParseAmeshCommand(text).IsAmesh
+}
+
+// Place 本文から履歴に記録する地名を返す
+func (c *AmeshCommand) Place(text string) string {
+	return amesh.ParseAmeshCommand(text).Place
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *AmeshCommand) ErrorKey(err error) i18n.Key {
+	return amesh.CommandErrorKey(err)
+}
+
+// Execute 地名の雨雲レーダー画像を作成し、画像を添付した返信を作成する
+// 複数の地名が指定された場合は比較画像、forecastが指定された場合は予測のパネルを並べた画像、
+// historyが指定された場合は過去の雨雲レーダーを並べた画像にする（forecastとhistoryの両方を指定した場合はhistoryを優先する）
+func (c *AmeshCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	parseResult := amesh.ParseAmeshCommand(req.Message.Text)
+	overlays, err := amesh.ParseOverlays(parseResult.Layer)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseOverlays")
+	}
+
+	// 位置を解析（複数の地名が指定された場合は比較画像にする）
+	locations, err := c.parseCandidateLocations(ctx, &parseResult)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseCandidateLocations")
+	}
+	if parseResult.History && 1 < len(locations) {
+		return nil, errors.Wrapf(amesh.ErrHistoryComparison, "places: %d", len(locations))
+	}
+	if parseResult.Forecast && 1 < len(locations) {
+		return nil, errors.Wrapf(amesh.ErrForecastComparison, "places: %d", len(locations))
+	}
+
+	// 画像を作成し、エンコードしながら読み出す
+	imageReader, err := c.createImageReader(ctx, locations, overlays, &parseResult)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to createImageReader")
+	}
+
+	templateData := req.TemplateData
+	textKey, descriptionKey := i18n.KeyAmeshSuccess, i18n.KeyAmeshImageDescription
+	templateData.PlaceName = locations[0].PlaceName
+	templateData.Lat = locations[0].Lat
+	templateData.Lng = locations[0].Lng
+	switch {
+	case parseResult.History:
+		textKey, descriptionKey = i18n.KeyAmeshHistorySuccess, i18n.KeyAmeshHistoryDescription
+	case parseResult.Forecast:
+		textKey, descriptionKey = i18n.KeyAmeshForecastSuccess, i18n.KeyAmeshForecastDescription
+	case 1 < len(locations):
+		textKey, descriptionKey = i18n.KeyAmeshCompareSuccess, i18n.KeyAmeshCompareDescription
+		templateData.PlaceName = amesh.ComparisonPlaceName(locations)
+	}
+	templateData.RadarTime = imageReader.RadarTimeText()
+	text := req.Templates.Render(textKey, templateData)
+	// 雨雲レーダーを描画した場合はその時刻を添え、古い場合は今の様子と誤解しないよう注意する
+	if templateData.RadarTime != "" {
+		text += "\n" + req.Templates.Render(i18n.KeyAmeshRadarTime, templateData)
+	}
+	if imageReader.Stale {
+		text += "\n" + req.Templates.Render(i18n.KeyAmeshStaleWarning, templateData)
+	}
+	if len(locations) == 1 && c.coverageEnabled() {
+		text += coverageText(req, locations[0])
+	}
+
+	// 画像の説明文は後から見返せるよう雨雲レーダーの日付も添える
+	description := req.Templates.Render(descriptionKey, templateData)
+	if radarDateTime := imageReader.RadarDateTimeText(); radarDateTime != "" {
+		captionData := *templateData
+		captionData.RadarTime = radarDateTime
+		description += "\n" + req.Templates.Render(i18n.KeyAmeshRadarTime, &captionData)
+	}
+	if len(locations) == 1 && c.elevationEnabled() {
+		description += c.elevationCaption(ctx, req, locations[0])
+	}
+
+	requestid.Logf(ctx, "Successfully created amesh image for %s", templateData.PlaceName)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    text,
+		Attachments: []*Attachment{{
+			Reader: imageReader,
+			FileName: amesh.GenerateFileName(&amesh.GenerateFileNameParams{
+				Locations: locations,
+				DataTime:  imageReader.RadarTime,
+				Clock:     c.Clock,
+			}),
+			Description: description,
+		}},
+	}, nil
+}
+
+// elevationEnabled 画像の説明文に標高を添えるかを返す
+func (c *AmeshCommand) elevationEnabled() bool {
+	if c.Client == nil {
+		return elevation.AmeshCaption()
+	}
+	return c.Elevation
+}
+
+// elevationCaption 画像の説明文に添える地点の標高の行を返す
+// 標高は補足のため、取得できない場合は雨雲レーダー画像の返信を失敗させずに空文字列を返す
+func (c *AmeshCommand) elevationCaption(ctx context.Context, req *Request, location *amesh.Location) string {
+	result, err := getElevation(ctx, c.Client, location)
+	if err != nil {
+		requestid.Logf(ctx, "Failed to get elevation for %s: %v", location.PlaceName, err)
+		return ""
+	}
+	captionData := *req.TemplateData
+	captionData.Elevation = result.Text()
+	return "\n" + req.Templates.Render(i18n.KeyAmeshElevation, &captionData)
+}
+
+// coverageEnabled 返信に距離円の中の主な市区町村を添えるかを返す
+func (c *AmeshCommand) coverageEnabled() bool {
+	if c.Client == nil {
+		return amesh.Coverage()
+	}
+	return c.Coverage
+}
+
+// coverageText 返信に添える距離円の区間ごとの主な市区町村の行を返す（市区町村がない場合は空文字列）
+func coverageText(req *Request, location *amesh.Location) string {
+	var text string
+	for _, band := range amesh.ListCoverage(location, amesh.DefaultCoverageLimit) {
+		lineData := *req.TemplateData
+		lineData.CoverageInner = strconv.FormatFloat(band.InnerKm, 'f', -1, 64)
+		lineData.CoverageOuter = strconv.FormatFloat(band.OuterKm, 'f', -1, 64)
+		lineData.CoveragePlaces = strings.Join(band.Places, "、")
+		if 0 < band.More {
+			lineData.CoverageMore = strconv.Itoa(band.More)
+			lineData.CoveragePlaces += " " + req.Templates.Render(i18n.KeyAmeshCoverageMore, &lineData)
+		}
+		text += "\n" + req.Templates.Render(i18n.KeyAmeshCoverage, &lineData)
+	}
+	return text
+}
+
+// parseCandidateLocations 地名を解析する
+// 文章から探した地名の候補がある場合は、見つかる候補があるまで上限の数だけ順に試す
+func (c *AmeshCommand) parseCandidateLocations(ctx context.Context, parseResult *amesh.ParseAmeshCommandResult) ([]*amesh.Location, error) {
+	candidates := parseResult.Candidates
+	if len(candidates) == 0 {
+		return c.parseLocations(ctx, parseResult.Place)
+	}
+
+	var lastErr error
+	for _, candidate := range candidates[:min(len(candidates), amesh.MaxPlaceCandidates)] {
+		locations, err := c.parseLocations(ctx, candidate)
+		if err == nil {
+			return locations, nil
+		}
+		if !errors.Is(err, amesh.ErrNoResultsFound) {
+			return nil, err
+		}
+		requestid.Logf(ctx, "No location found for candidate %s", candidate)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// parseLocations 設定に合わせたジオコーダで地名を解析する
+func (c *AmeshCommand) parseLocations(ctx context.Context, place string) ([]*amesh.Location, error) {
+	if c.Client == nil && c.Geocoder == nil {
+		locations, err := amesh.ParseLocationsWithLog(ctx, place, c.YahooAPIToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.ParseLocationsWithLog")
+		}
+		return locations, nil
+	}
+	locations, err := amesh.ParseLocationsWithClient(ctx, &amesh.ParseLocationParams{
+		Client:         c.Client,
+		GeocodeRequest: amesh.GeocodeRequest{Place: place, APIKey: c.YahooAPIToken},
+		Geocoder:       c.Geocoder,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseLocationsWithClient")
+	}
+	return locations, nil
+}
+
+// createImageReader 設定に合わせたレンダラーで画像を作成する
+// historyかforecastが指定された場合は1か所の地点の過去の雨雲レーダーか予測のパネルを並べた画像を作成する
+func (c *AmeshCommand) createImageReader(ctx context.Context, locations []*amesh.Location, overlays []amesh.OverlayName, parseResult *amesh.ParseAmeshCommandResult) (*amesh.ImageReader, error) {
+	renderer := c.Renderer
+	if renderer == nil {
+		renderer = &ameshRenderer{Client: c.Client, Clock: c.Clock, StaleThreshold: c.StaleThreshold}
+	}
+	if parseResult.History {
+		imageReader, err := renderer.RenderHistory(ctx, locations[0], overlays, parseResult.HistoryDuration)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to RenderHistory")
+		}
+		return imageReader, nil
+	}
+	if parseResult.Forecast {
+		imageReader, err := renderer.RenderForecast(ctx, locations[0], overlays)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to RenderForecast")
+		}
+		return imageReader, nil
+	}
+	imageReader, err := renderer.RenderLocations(ctx, locations, overlays)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to RenderLocations")
+	}
+	return imageReader, nil
+}
+
+// ameshRenderer ameshパッケージで画像を作成する既定のAmeshImageRenderer
+type ameshRenderer struct {
+	Client         httpclient.Doer // HTTPクライアント（nilの場合はameshパッケージの既定のクライアントと設定）
+	Clock          clock.Clock     // 雨雲レーダーの経過時間の確認に使う時計
+	StaleThreshold time.Duration   // 雨雲レーダーのデータが古いとみなす経過時間
+}
+
+// RenderLocations 設定に合わせたクライアントで1か所以上の地点の画像を作成する
+func (r *ameshRenderer) RenderLocations(ctx context.Context, locations []*amesh.Location, overlays []amesh.OverlayName) (*amesh.ImageReader, error) {
+	if r.Client == nil {
+		imageReader, err := amesh.CreateImageReaderForLocations(ctx, locations, overlays)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateImageReaderForLocations")
+		}
+		return imageReader, nil
+	}
+	imageReader, err := amesh.CreateImageReaderForLocationsWithClient(ctx, &amesh.CreateComparisonImageParams{
+		Client:         r.Client,
+		Locations:      locations,
+		Overlays:       overlays,
+		StaleThreshold: r.StaleThreshold,
+		Clock:          r.Clock,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateImageReaderForLocationsWithClient")
+	}
+	return imageReader, nil
+}
+
+// RenderForecast 設定に合わせたクライアントで予測のパネルを並べた画像を作成する
+func (r *ameshRenderer) RenderForecast(ctx context.Context, location *amesh.Location, overlays []amesh.OverlayName) (*amesh.ImageReader, error) {
+	if r.Client == nil {
+		imageReader, err := amesh.CreateForecastImageReader(ctx, location, overlays)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateForecastImageReader")
+		}
+		return imageReader, nil
+	}
+	imageReader, err := amesh.CreateForecastImageReaderWithClient(ctx, &amesh.LocationImageParams{
+		Client:         r.Client,
+		Location:       location,
+		Overlays:       overlays,
+		StaleThreshold: r.StaleThreshold,
+		Clock:          r.Clock,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateForecastImageReaderWithClient")
+	}
+	return imageReader, nil
+}
+
+// RenderHistory 設定に合わせたクライアントで過去の雨雲レーダーを並べた画像を作成する
+func (r *ameshRenderer) RenderHistory(ctx context.Context, location *amesh.Location, overlays []amesh.OverlayName, duration time.Duration) (*amesh.ImageReader, error) {
+	if r.Client == nil {
+		imageReader, err := amesh.CreateHistoryImageReader(ctx, location, overlays, duration)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateHistoryImageReader")
+		}
+		return imageReader, nil
+	}
+	imageReader, err := amesh.CreateHistoryImageReaderWithClient(ctx, &amesh.HistoryImageParams{
+		LocationImageParams: amesh.LocationImageParams{
+			Client:         r.Client,
+			Location:       location,
+			Overlays:       overlays,
+			StaleThreshold: r.StaleThreshold,
+			Clock:          r.Clock,
+		},
+		Duration: duration,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateHistoryImageReaderWithClient")
+	}
+	return imageReader, nil
+}
@@ -0,0 +1,545 @@
+package bot_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestAmeshCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato amesh 東京", expected: true},
+		{name: "地名なし", text: "amesh", expected: true},
+		{name: "文中のamesh", text: "今日の渋谷は雨かな？ amesh", expected: true},
+		{name: "別のコマンド", text: "amedas 東京", expected: false},
+		{name: "コマンドでない", text: "こんにちは", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.AmeshCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestAmeshCommandExecute 外部APIにアクセスする前に失敗する場合をテストする
+func TestAmeshCommandExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		req           *bot.Request
+		expectedError error
+	}{
+		{
+			name:          "nilリクエスト",
+			token:         "token",
+			req:           nil,
+			expectedError: lib.ErrParamsNil,
+		},
+		{
+			name:          "存在しないレイヤー",
+			token:         "token",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "amesh 東京 layer=unknown"}, TemplateData: &i18n.TemplateData{}},
+			expectedError: amesh.ErrUnknownOverlay,
+		},
+		{
+			name:          "Yahoo APIトークンがない場合は埋め込みの地名の一覧から探す",
+			token:         "",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "amesh 存在しない地名"}, TemplateData: &i18n.TemplateData{}},
+			expectedError: amesh.ErrNoResultsFound,
+		},
+		{
+			name:          "Yahoo APIトークンがない場合の比較で一覧にない地名がある",
+			token:         "",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "amesh 大阪 存在しない地名"}, TemplateData: &i18n.TemplateData{}},
+			expectedError: amesh.ErrNoResultsFound,
+		},
+		{
+			name:          "文中のameshで地名の候補がどれも見つからない",
+			token:         "",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "存在しない地名は雨かな amesh"}, TemplateData: &i18n.TemplateData{}},
+			expectedError: amesh.ErrNoResultsFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.AmeshCommand{YahooAPIToken: tt.token}
+			if _, err := command.Execute(t.Context(), tt.req); !errors.Is(err, tt.expectedError) {
+				t.Errorf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+// TestAmeshCommandExecuteFreeForm 文中のameshで、見つからない候補を飛ばして地名の画像を作成することを確認する
+func TestAmeshCommandExecuteFreeForm(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+	command := &bot.AmeshCommand{Client: tiles.Client()}
+
+	reply, err := command.Execute(t.Context(), &bot.Request{
+		Message:      &bot.IncomingMessage{Text: "@hato 存在しない地名と渋谷は雨かな？ amesh"},
+		TemplateData: &i18n.TemplateData{},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !strings.Contains(reply.Text, "渋谷") {
+		t.Errorf("Execute() text = %q, want a reply about 渋谷", reply.Text)
+	}
+}
+
+// TestAmeshCommandExecuteRadarTime ファイル名と画像の説明文に雨雲レーダーの日本時間の日時を使うことを確認する
+func TestAmeshCommandExecuteRadarTime(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, &ameshtest.Scenario{BaseTime: time.Date(2024, 1, 1, 15, 5, 0, 0, time.UTC)})
+	command := &bot.AmeshCommand{
+		Client: tiles.Client(),
+		Clock:  clocktest.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	reply, err := command.Execute(t.Context(), &bot.Request{
+		Message:      &bot.IncomingMessage{Text: "amesh 東京"},
+		TemplateData: &i18n.TemplateData{},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	attachment := reply.Attachments[0]
+	defer func() { _ = attachment.Reader.Close() }()
+	if !strings.Contains(attachment.FileName, "_2024-01-02_0005JST_") {
+		t.Errorf("FileName = %q, want the radar time in JST", attachment.FileName)
+	}
+	if !strings.Contains(attachment.Description, "2024-01-02 00:05 JST") {
+		t.Errorf("Description = %q, want the radar date and time in JST", attachment.Description)
+	}
+	if !strings.Contains(reply.Text, "00:05 JST") {
+		t.Errorf("Text = %q, want the radar time in JST", reply.Text)
+	}
+}
+
+// TestAmeshCommandExecuteForecast forecastの指定で予測のパネルを並べた画像を作成し、複数の地点では失敗することを確認する
+func TestAmeshCommandExecuteForecast(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		expectedText  string
+		expectedError error
+	}{
+		{
+			name:         "1か所の予測",
+			text:         "amesh 東京 forecast",
+			expectedText: "1時間先までの予測",
+		},
+		{
+			name:          "複数の地点の予測",
+			text:          "amesh 東京 大阪 forecast",
+			expectedError: amesh.ErrForecastComparison,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tiles := ameshtest.NewServer(t, &ameshtest.Scenario{Forecast: true})
+			command := &bot.AmeshCommand{Client: tiles.Client()}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: tt.text},
+				TemplateData: &i18n.TemplateData{},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			defer func() { _ = reply.Attachments[0].Reader.Close() }()
+			if !strings.Contains(reply.Text, tt.expectedText) {
+				t.Errorf("Execute() text = %q, want %q", reply.Text, tt.expectedText)
+			}
+		})
+	}
+}
+
+// TestAmeshCommandExecuteHistory historyの指定で過去の雨雲レーダーを並べた画像を作成し、複数の地点や範囲外の期間では失敗することを確認する
+func TestAmeshCommandExecuteHistory(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		expectedText  string
+		expectedError error
+	}{
+		{
+			name:         "1か所の過去の雨雲レーダー",
+			text:         "amesh history 東京 3h",
+			expectedText: "これまでの雨雲レーダー",
+		},
+		{
+			name:          "複数の地点の過去の雨雲レーダー",
+			text:          "amesh history 東京 大阪",
+			expectedError: amesh.ErrHistoryComparison,
+		},
+		{
+			name:          "さかのぼれない期間",
+			text:          "amesh history 東京 6h",
+			expectedError: amesh.ErrInvalidHistoryDuration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tiles := ameshtest.NewServer(t, &ameshtest.Scenario{History: true})
+			command := &bot.AmeshCommand{Client: tiles.Client()}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: tt.text},
+				TemplateData: &i18n.TemplateData{},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			defer func() { _ = reply.Attachments[0].Reader.Close() }()
+			if !strings.Contains(reply.Text, tt.expectedText) {
+				t.Errorf("Execute() text = %q, want %q", reply.Text, tt.expectedText)
+			}
+		})
+	}
+}
+
+// TestAmeshCommandExecuteStale 雨雲レーダーのデータが古い場合にだけ返信に注意を添えることを確認する
+func TestAmeshCommandExecuteStale(t *testing.T) {
+	tests := []struct {
+		name          string
+		now           time.Time
+		expectedStale bool
+	}{
+		{
+			name:          "しきい値より古い",
+			now:           ameshtest.DefaultBaseTime.Add(30 * time.Minute),
+			expectedStale: true,
+		},
+		{
+			name:          "しきい値以内",
+			now:           ameshtest.DefaultBaseTime.Add(10 * time.Minute),
+			expectedStale: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tiles := ameshtest.NewServer(t, nil)
+			command := &bot.AmeshCommand{
+				Client:         tiles.Client(),
+				Clock:          clocktest.NewFake(tt.now),
+				StaleThreshold: 20 * time.Minute,
+			}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "amesh 東京"},
+				TemplateData: &i18n.TemplateData{},
+			})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			defer func() { _ = reply.Attachments[0].Reader.Close() }()
+			if stale := strings.Contains(reply.Text, "古い雨雲レーダー"); stale != tt.expectedStale {
+				t.Errorf("Text = %q, want stale warning = %v", reply.Text, tt.expectedStale)
+			}
+		})
+	}
+}
+
+// fakeGeocoder 地名ごとに決めた位置を返すジオコーダ
+type fakeGeocoder map[string]*amesh.Location
+
+func (g fakeGeocoder) Geocode(_ context.Context, place string) (*amesh.Location, error) {
+	location, ok := g[place]
+	if !ok {
+		return nil, errors.Wrapf(amesh.ErrNoResultsFound, "place: %s", place)
+	}
+	return location, nil
+}
+
+// fakeRenderer 受け取った地点を記録し、固定の内容の画像を返すレンダラー
+type fakeRenderer struct {
+	radarTime time.Time
+	mu        sync.Mutex
+	locations [][]*amesh.Location
+	forecasts []*amesh.Location
+	histories []time.Duration
+}
+
+func (r *fakeRenderer) RenderLocations(_ context.Context, locations []*amesh.Location, _ []amesh.OverlayName) (*amesh.ImageReader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locations = append(r.locations, locations)
+	return r.reader(), nil
+}
+
+func (r *fakeRenderer) RenderForecast(_ context.Context, location *amesh.Location, _ []amesh.OverlayName) (*amesh.ImageReader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.forecasts = append(r.forecasts, location)
+	return r.reader(), nil
+}
+
+func (r *fakeRenderer) RenderHistory(_ context.Context, _ *amesh.Location, _ []amesh.OverlayName, duration time.Duration) (*amesh.ImageReader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histories = append(r.histories, duration)
+	return r.reader(), nil
+}
+
+func (r *fakeRenderer) reader() *amesh.ImageReader {
+	return &amesh.ImageReader{
+		ReadCloser:    io.NopCloser(strings.NewReader("png")),
+		AmeshMetadata: amesh.AmeshMetadata{RadarTime: r.radarTime},
+	}
+}
+
+// TestAmeshCommandExecuteInjected 注入したジオコーダとレンダラーで地名の解析から返信の作成までを確認する
+func TestAmeshCommandExecuteInjected(t *testing.T) {
+	tokyo := &amesh.Location{Lat: 35.6895, Lng: 139.6917, PlaceName: "東京都"}
+	osaka := &amesh.Location{Lat: 34.6864, Lng: 135.52, PlaceName: "大阪府"}
+	geocoder := fakeGeocoder{"とうきょう": tokyo, "おおさか": osaka}
+
+	tests := []struct {
+		name              string
+		text              string
+		expectedText      string
+		expectedLocations [][]*amesh.Location
+		expectedForecasts []*amesh.Location
+		expectedHistories []time.Duration
+		expectedError     error
+	}{
+		{
+			name:              "1か所",
+			text:              "amesh とうきょう",
+			expectedText:      "東京都",
+			expectedLocations: [][]*amesh.Location{{tokyo}},
+		},
+		{
+			name:              "複数の地点の比較",
+			text:              "amesh とうきょう おおさか",
+			expectedText:      "1.東京都 / 2.大阪府",
+			expectedLocations: [][]*amesh.Location{{tokyo, osaka}},
+		},
+		{
+			name:              "予測",
+			text:              "amesh おおさか forecast",
+			expectedText:      "1時間先までの予測",
+			expectedForecasts: []*amesh.Location{osaka},
+		},
+		{
+			name:              "過去の雨雲レーダー",
+			text:              "amesh おおさか history 90m",
+			expectedText:      "これまでの雨雲レーダー",
+			expectedHistories: []time.Duration{90 * time.Minute},
+		},
+		{
+			name:          "ジオコーダで見つからない地名",
+			text:          "amesh どこにもない",
+			expectedError: amesh.ErrNoResultsFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			renderer := &fakeRenderer{radarTime: ameshtest.DefaultBaseTime}
+			command := &bot.AmeshCommand{Geocoder: geocoder, Renderer: renderer}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: tt.text},
+				TemplateData: &i18n.TemplateData{},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expectedLocations, renderer.locations); diff != "" {
+				t.Errorf("RenderLocations() locations mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedForecasts, renderer.forecasts); diff != "" {
+				t.Errorf("RenderForecast() locations mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedHistories, renderer.histories); diff != "" {
+				t.Errorf("RenderHistory() durations mismatch (-want +got):\n%s", diff)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if !strings.Contains(reply.Text, tt.expectedText) {
+				t.Errorf("Execute() text = %q, want %q", reply.Text, tt.expectedText)
+			}
+			if radarTime := renderer.reader().RadarTimeText(); !strings.Contains(reply.Text, radarTime) {
+				t.Errorf("Execute() text = %q, want radar time %q", reply.Text, radarTime)
+			}
+			data, err := io.ReadAll(reply.Attachments[0].Reader)
+			if err != nil {
+				t.Fatalf("io.ReadAll() error = %v", err)
+			}
+			if string(data) != "png" {
+				t.Errorf("attachment = %q, want the rendered image", data)
+			}
+		})
+	}
+}
+
+// TestAmeshCommandExecuteElevation 設定した場合は画像の説明文に地点の標高を添え、取得できない場合も画像を返信することを確認する
+func TestAmeshCommandExecuteElevation(t *testing.T) {
+	tests := []struct {
+		name              string
+		text              string
+		elevation         bool
+		body              string
+		expectedElevation bool
+	}{
+		{
+			name:              "標高を添える",
+			text:              "amesh 35.6812,139.7671",
+			elevation:         true,
+			body:              `{"elevation":3.4,"hsrc":"5m（レーザ）"}`,
+			expectedElevation: true,
+		},
+		{
+			name:      "設定しない場合は添えない",
+			text:      "amesh 35.6812,139.7671",
+			elevation: false,
+			body:      `{"elevation":3.4,"hsrc":"5m（レーザ）"}`,
+		},
+		{
+			name:      "データがない地点は添えない",
+			text:      "amesh 35.6812,139.7671",
+			elevation: true,
+			body:      `{"elevation":"-----","hsrc":"-----"}`,
+		},
+		{
+			name:      "比較画像には添えない",
+			text:      "amesh 35.6812,139.7671 34.7025,135.4959",
+			elevation: true,
+			body:      `{"elevation":3.4,"hsrc":"5m（レーザ）"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.AmeshCommand{
+				Client:    newElevationClient(tt.body),
+				Renderer:  &fakeRenderer{radarTime: ameshtest.DefaultBaseTime},
+				Elevation: tt.elevation,
+			}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: tt.text},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+			})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := strings.Contains(reply.Attachments[0].Description, "\nElevation 3.4m"); got != tt.expectedElevation {
+				t.Errorf("Description = %q, want elevation: %v", reply.Attachments[0].Description, tt.expectedElevation)
+			}
+			if strings.Contains(reply.Text, "Elevation") {
+				t.Errorf("Text = %q, want no elevation", reply.Text)
+			}
+		})
+	}
+}
+
+// TestAmeshCommandExecuteCoverage 1か所の地点の返信に距離円の区間ごとの主な市区町村を添えることを確認する
+func TestAmeshCommandExecuteCoverage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		locale   i18n.Locale
+		coverage bool
+		expected string
+	}{
+		{
+			name:     "区間ごとに近い順に添える",
+			text:     "amesh 26.2124,127.6809",
+			locale:   i18n.LocaleEn,
+			coverage: true,
+			expected: "\n⭕ 0-10km: 那覇市、浦添市、豊見城市、糸満市\n⭕ 10-20km: 南城市、宜野湾市、沖縄市\n⭕ 20-30km: うるま市",
+		},
+		{
+			name:     "上限を超えた数を添える",
+			text:     "amesh 35.6812,139.7671",
+			locale:   i18n.LocaleJa,
+			coverage: true,
+			expected: "\n⭕ 0〜10km: 中央区、千代田区、港区、文京区、台東区 ほか12件\n⭕ 10〜20km: ",
+		},
+		{
+			name:     "設定しない場合は添えない",
+			text:     "amesh 26.2124,127.6809",
+			locale:   i18n.LocaleEn,
+			coverage: false,
+		},
+		{
+			name:     "比較画像には添えない",
+			text:     "amesh 26.2124,127.6809 35.6812,139.7671",
+			locale:   i18n.LocaleEn,
+			coverage: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.AmeshCommand{
+				Client:   &http.Client{Transport: httpclient.NewMockTransport(&httpclient.MockTransportParams{})},
+				Renderer: &fakeRenderer{radarTime: ameshtest.DefaultBaseTime},
+				Coverage: tt.coverage,
+			}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: tt.text},
+				TemplateData: &i18n.TemplateData{Locale: tt.locale},
+			})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if tt.expected == "" {
+				if strings.Contains(reply.Text, "⭕") {
+					t.Errorf("Text = %q, want no coverage", reply.Text)
+				}
+				return
+			}
+			if !strings.Contains(reply.Text, tt.expected) {
+				t.Errorf("Text = %q, want to contain %q", reply.Text, tt.expected)
+			}
+		})
+	}
+}
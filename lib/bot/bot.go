@@ -0,0 +1,325 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/metrics"
+	"hato-bot-go/lib/report"
+	"hato-bot-go/lib/requestid"
+	"hato-bot-go/lib/translate"
+)
+
+// DefaultTimeout 制限時間を設定していないコマンドの処理の制限時間
+// 画像のアップロード後に処理の完了を待つプラットフォームもあるため長めにする
+const DefaultTimeout = 90 * time.Second
+
+// Reaction 受信したメッセージに付けるリアクション
+type Reaction string
+
+const (
+	// ReactionProcessing コマンドを処理中であることを表すリアクション
+	ReactionProcessing Reaction = "👀"
+	// ReactionSuccess コマンドの結果を返信したことを表すリアクション
+	// 対応するリアクションを設定していないプラットフォームでは付けない
+	ReactionSuccess Reaction = "✅"
+	// ReactionBusy 混雑のためコマンドを処理しなかったことを表すリアクション
+	ReactionBusy Reaction = "⏳"
+)
+
+// IncomingMessage プラットフォームに依存しない受信メッセージ
+type IncomingMessage struct {
+	ID        string                    // メッセージのID
+	Text      string                    // 本文
+	UserID    string                    // 送信者のID（実行回数の制限に使う、空の場合は制限しない）
+	ReplyText string                    // 返信先のメッセージの本文（返信でない場合や取得できない場合は空）
+	Allows    func(command string) bool // 応答を許可するコマンドか判定する関数（nilの場合は全て許可）
+	Raw       any                       // プラットフォーム固有の元のメッセージ（アダプターが返信先の特定に使う）
+}
+
+// Attachment 返信に添付するファイル
+type Attachment struct {
+	Reader      io.ReadCloser // ファイルの内容（返信の送信後にEngineが閉じる）
+	FileName    string        // ファイル名
+	Description string        // 画像の説明文（対応するプラットフォームのみ）
+}
+
+// OutgoingReply プラットフォームに依存しない返信
+type OutgoingReply struct {
+	Command     string        // 返信するコマンド名（コマンドごとの返信方針の選択に使う）
+	Text        string        // 本文
+	Attachments []*Attachment // 添付ファイル
+}
+
+// Platform Misskey・mixi2などのプラットフォームのアダプター
+type Platform interface {
+	// Name プラットフォーム名（エラー報告のタグに使う）
+	Name() string
+	// TemplateData メッセージの送信者への返信テンプレートに渡す変数（言語・ユーザー）を返す
+	TemplateData(message *IncomingMessage) *i18n.TemplateData
+	// React メッセージにリアクションを付ける
+	React(ctx context.Context, message *IncomingMessage, reaction Reaction) error
+	// Reply メッセージに返信する
+	Reply(ctx context.Context, message *IncomingMessage, reply *OutgoingReply) error
+}
+
+// MarkupPlatform 返信の本文にMFMを使えるプラットフォーム
+// Platformが実装していない場合、コマンドは装飾のない文章で返信する
+type MarkupPlatform interface {
+	// SupportsMFM 返信の本文のMFMを装飾として表示するか
+	SupportsMFM() bool
+}
+
+// Request コマンドの実行に必要な情報
+type Request struct {
+	Message      *IncomingMessage   // 受信したメッセージ
+	TemplateData *i18n.TemplateData // 送信者への返信テンプレートに渡す変数
+	Templates    *i18n.Templates    // 返信テンプレート（nilの場合はメッセージカタログの文言）
+	MFM          bool               // 返信の本文にMFMを使えるか（mfm.NewBuilderに渡す）
+}
+
+// Command プラットフォームに依存しないコマンドの実装
+type Command interface {
+	// Name コマンド名
+	Name() string
+	// Match 本文がこのコマンドかを返す
+	Match(text string) bool
+	// Execute コマンドを実行して返信を作成する
+	Execute(ctx context.Context, req *Request) (*OutgoingReply, error)
+	// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+	ErrorKey(err error) i18n.Key
+}
+
+// PlaceCommand 引数に地名をとるコマンド
+// コマンドの処理の履歴には、このインターフェースを実装するコマンドの地名のみを記録する（翻訳する文章などは記録しない）
+type PlaceCommand interface {
+	Command
+	// Place 本文から履歴に記録する地名を返す
+	Place(text string) string
+}
+
+// EngineSetting Engineの設定
+type EngineSetting struct {
+	Platform      Platform                 // 返信先のプラットフォーム
+	Commands      []Command                // 受け付けるコマンド（先頭から順に照合する）
+	Templates     *i18n.Templates          // 返信テンプレート（nilの場合はメッセージカタログの文言）
+	Reporter      *report.Reporter         // エラーの報告先（nilの場合は報告しない）
+	Timeouts      map[string]time.Duration // コマンド名ごとの処理の制限時間（ない場合はDefaultTimeout）
+	RateLimiter   *RateLimiter             // 送信者ごとの実行回数の制限（nilの場合は制限しない）
+	History       *history.Store           // コマンドの処理の記録の保存先（nilの場合は保存しない）
+	Admins        []string                 // statsコマンド・admin selftestコマンド・admin reloadコマンドを使える送信者のID（空の場合は使わない、statsコマンドは履歴の保存も必要）
+	Reloader      Reloader                 // admin reloadコマンドで設定ファイルを読み込み直す処理（nilの場合はadmin reloadコマンドを使わない）
+	Disabled      []string                 // 設定ファイルで無効にしたコマンド名（一致したメッセージには無効である旨を返信する）
+	YahooAPIToken string                   // admin selftestコマンドの地名の検索に使うYahoo APIトークン
+	Middlewares   []Middleware             // 実行回数の制限と制限時間の間で実行する追加のミドルウェア
+	Aliases       map[string]string        // コマンドの別名からコマンド名への対応（例: {"雨雲": "amesh"}、全角・半角と大文字・小文字は区別しない）
+	ImageLimit    *amesh.ImageLimit        // 返信に添付するPNG画像の大きさの上限（nilの場合は制限しない）
+}
+
+// Engine 受信したメッセージからコマンドを選んで実行し、プラットフォームに返信する
+type Engine struct {
+	setting EngineSetting
+	handler HandlerFunc
+	aliases map[string]string // 正規化した別名とコマンド名からコマンド名への索引
+}
+
+// NewEngine 新しいEngineを作成する
+func NewEngine(setting *EngineSetting) *Engine {
+	if setting == nil || setting.Platform == nil {
+		return nil
+	}
+	e := &Engine{setting: *setting}
+	help := newHelpCommand(e.setting.Commands, e.setting.Disabled)
+	if e.setting.History != nil && 0 < len(e.setting.Admins) {
+		e.setting.Commands = append(slices.Clone(e.setting.Commands), &StatsCommand{Store: e.setting.History, Admins: e.setting.Admins})
+	}
+	if 0 < len(e.setting.Admins) {
+		// アップロードに対応するプラットフォームではアップロードも試す
+		uploader, _ := e.setting.Platform.(Uploader)
+		e.setting.Commands = append(slices.Clone(e.setting.Commands), &SelfTestCommand{
+			Admins:        e.setting.Admins,
+			YahooAPIToken: e.setting.YahooAPIToken,
+			Uploader:      uploader,
+		})
+	}
+	if 0 < len(e.setting.Admins) && e.setting.Reloader != nil {
+		e.setting.Commands = append(slices.Clone(e.setting.Commands), &ReloadCommand{Admins: e.setting.Admins, Reloader: e.setting.Reloader})
+	}
+	e.setting.Commands = append(slices.Clone(e.setting.Commands), help)
+	e.aliases = newAliases(e.setting.Aliases, e.setting.Commands)
+	e.handler = Chain(e.execute, e.middlewares()...)
+	return e
+}
+
+// middlewares コマンドの実行を包むミドルウェアを外側から順に返す
+// 許可されていないコマンドはログやメトリクスに残さず、エラーメッセージは制限時間の外側で返信する
+func (e *Engine) middlewares() []Middleware {
+	middlewares := []Middleware{
+		Recover(e.setting.Reporter, e.setting.Platform.Name()),
+		Allowlist(),
+		ErrorReply(&ErrorReplySetting{
+			Platform:  e.setting.Platform,
+			Templates: e.setting.Templates,
+			Reporter:  e.setting.Reporter,
+		}),
+		Disabled(e.setting.Disabled),
+		Logging(),
+		Metrics(metrics.Default),
+		History(e.setting.History, e.setting.Platform.Name()),
+		RateLimit(e.setting.RateLimiter),
+	}
+	middlewares = append(middlewares, e.setting.Middlewares...)
+	return append(middlewares, Timeout(e.setting.Timeouts))
+}
+
+// newHelpCommand 受け付けるコマンドを使えるものと無効にしたものに分けてhelpコマンドを作成する
+// 管理者向けのコマンドは含めない
+func newHelpCommand(commands []Command, disabled []string) *HelpCommand {
+	help := &HelpCommand{}
+	for _, command := range commands {
+		if slices.Contains(disabled, command.Name()) {
+			help.Disabled = append(help.Disabled, command.Name())
+		} else {
+			help.Commands = append(help.Commands, command.Name())
+		}
+	}
+	return help
+}
+
+// DefaultCommands 全プラットフォームで共通のコマンドを返す
+// 翻訳サービスがnilの場合はtranslateコマンドを含めない
+func DefaultCommands(yahooAPIToken string, translator translate.Translator) []Command {
+	commands := []Command{
+		&AmeshCommand{YahooAPIToken: yahooAPIToken},
+		&AmedasCommand{YahooAPIToken: yahooAPIToken},
+		&MapCommand{YahooAPIToken: yahooAPIToken},
+		&RainCommand{YahooAPIToken: yahooAPIToken},
+		&ElevationCommand{YahooAPIToken: yahooAPIToken},
+		&DistanceCommand{YahooAPIToken: yahooAPIToken},
+		&WikipediaCommand{},
+	}
+	if translator != nil {
+		commands = append(commands, &TranslateCommand{Translator: translator})
+	}
+	return commands
+}
+
+// Command 本文に一致するコマンドを返す
+// 一致するコマンドがない場合はnilを返す
+func (e *Engine) Command(text string) Command {
+	for _, command := range e.setting.Commands {
+		if command.Match(text) {
+			return command
+		}
+	}
+	return nil
+}
+
+// Handle メッセージに一致するコマンドをミドルウェアを通して実行し、返信する
+// コマンドの失敗はエラーメッセージを返信して報告し、エラーメッセージの返信に失敗した場合のみエラーを返す
+// コンテキストにリクエストIDがない場合は新しく付け、ログ・外部APIへのリクエスト・エラーメッセージに含める
+func (e *Engine) Handle(ctx context.Context, message *IncomingMessage) error {
+	if message == nil {
+		return lib.ErrParamsNil
+	}
+
+	// 別名や全角で書かれたコマンド名はコマンド名に置き換えてから照合する
+	if text := e.resolveAlias(message.Text); text != message.Text {
+		resolved := *message
+		resolved.Text = text
+		message = &resolved
+	}
+
+	command := e.Command(message.Text)
+	if command == nil {
+		return nil
+	}
+	return e.handler(requestid.Ensure(ctx), &Call{Command: command, Message: message})
+}
+
+// Shed 混雑のため処理しないメッセージがコマンドの場合は混雑のリアクションを付ける
+// コマンドは実行せず、画像の作成や返信もしない
+func (e *Engine) Shed(ctx context.Context, message *IncomingMessage) error {
+	if message == nil {
+		return lib.ErrParamsNil
+	}
+	command := e.Command(e.resolveAlias(message.Text))
+	if command == nil {
+		return nil
+	}
+	ctx = requestid.Ensure(ctx)
+	requestid.Logf(ctx, "Shed %s command: overloaded", command.Name())
+	if err := e.setting.Platform.React(ctx, message, ReactionBusy); err != nil {
+		return errors.Wrap(err, "Failed to React")
+	}
+	return nil
+}
+
+// execute 処理中のリアクションを付けてコマンドを実行し、結果を返信して成功のリアクションを付ける
+func (e *Engine) execute(ctx context.Context, call *Call) error {
+	if err := e.setting.Platform.React(ctx, call.Message, ReactionProcessing); err != nil {
+		return errors.Wrap(err, "Failed to React")
+	}
+
+	markup, _ := e.setting.Platform.(MarkupPlatform)
+	reply, err := call.Command.Execute(ctx, &Request{
+		Message:      call.Message,
+		TemplateData: e.setting.Platform.TemplateData(call.Message),
+		Templates:    e.setting.Templates,
+		MFM:          markup != nil && markup.SupportsMFM(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to Execute")
+	}
+	defer func(attachments []*Attachment) {
+		for _, attachment := range attachments {
+			if closeErr := attachment.Reader.Close(); closeErr != nil {
+				requestid.Logf(ctx, "Failed to Close: %v", closeErr)
+			}
+		}
+	}(reply.Attachments)
+
+	if err := e.limitAttachments(ctx, reply.Attachments); err != nil {
+		return errors.Wrap(err, "Failed to limitAttachments")
+	}
+	if err := e.setting.Platform.Reply(ctx, call.Message, reply); err != nil {
+		return errors.Wrap(err, "Failed to Reply")
+	}
+	// 返信は済んでいるため、リアクションの失敗はエラーメッセージを返信しない
+	if err := e.setting.Platform.React(ctx, call.Message, ReactionSuccess); err != nil {
+		requestid.Logf(ctx, "Failed to React: %v", err)
+	}
+	return nil
+}
+
+// limitAttachments 添付するPNG画像をプラットフォームの大きさの上限に収める
+// 上限を超える画像は縮小・減色した画像に置き換え、元の画像のReaderは閉じる
+func (e *Engine) limitAttachments(ctx context.Context, attachments []*Attachment) error {
+	if e.setting.ImageLimit == nil {
+		return nil
+	}
+	for _, attachment := range attachments {
+		if !strings.EqualFold(path.Ext(attachment.FileName), ".png") {
+			continue
+		}
+		limited, err := amesh.LimitPNG(attachment.Reader, e.setting.ImageLimit)
+		if err != nil {
+			return errors.Wrap(err, "Failed to amesh.LimitPNG")
+		}
+		if closeErr := attachment.Reader.Close(); closeErr != nil {
+			requestid.Logf(ctx, "Failed to Close: %v", closeErr)
+		}
+		attachment.Reader = io.NopCloser(limited)
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package bot_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// testRequestID テストで使うリクエストID
+const testRequestID = "0123456789abcdef"
+
+// errorReplyText エラーメッセージにテスト用のリクエストIDを添えた返信を返す
+func errorReplyText(key i18n.Key) string {
+	return i18n.Message(i18n.DefaultLocale, key) + "\n" + i18n.Message(i18n.DefaultLocale, i18n.KeyErrorRequestID, requestid.Short(testRequestID))
+}
+
+// recordingPlatform リアクションと返信を記録するプラットフォーム
+type recordingPlatform struct {
+	reactions []bot.Reaction
+	replies   []*bot.OutgoingReply
+	reactErr  error
+	replyErr  error
+}
+
+func (p *recordingPlatform) Name() string {
+	return "test"
+}
+
+func (p *recordingPlatform) TemplateData(_ *bot.IncomingMessage) *i18n.TemplateData {
+	return &i18n.TemplateData{Locale: i18n.DefaultLocale, User: "alice"}
+}
+
+func (p *recordingPlatform) React(_ context.Context, _ *bot.IncomingMessage, reaction bot.Reaction) error {
+	p.reactions = append(p.reactions, reaction)
+	return p.reactErr
+}
+
+func (p *recordingPlatform) Reply(_ context.Context, _ *bot.IncomingMessage, reply *bot.OutgoingReply) error {
+	p.replies = append(p.replies, reply)
+	return p.replyErr
+}
+
+// trackingReader Closeされたかを記録するReader
+type trackingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *trackingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// echoCommand 本文をそのまま返信するコマンド
+type echoCommand struct {
+	err        error
+	attachment *trackingReader
+	fileName   string   // 添付ファイル名（空の場合はecho.txt）
+	block      bool     // コンテキストが終了するまで処理を止める
+	panics     bool     // 実行中にパニックする
+	errorKey   i18n.Key // 失敗した場合の返信メッセージのキー（空の場合はKeyErrorCommand）
+	requestID  string
+}
+
+func (c *echoCommand) Name() string {
+	return "echo"
+}
+
+func (c *echoCommand) Match(text string) bool {
+	return strings.HasPrefix(text, "echo")
+}
+
+func (c *echoCommand) Execute(ctx context.Context, req *bot.Request) (*bot.OutgoingReply, error) {
+	c.requestID = requestid.FromContext(ctx)
+	if c.panics {
+		panic("echo panicked")
+	}
+	if c.block {
+		<-ctx.Done()
+		return nil, errors.Wrap(ctx.Err(), "Failed to wait")
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	reply := &bot.OutgoingReply{Command: c.Name(), Text: req.Message.Text + " " + req.TemplateData.User}
+	if c.attachment != nil {
+		fileName := c.fileName
+		if fileName == "" {
+			fileName = "echo.txt"
+		}
+		reply.Attachments = []*bot.Attachment{{Reader: c.attachment, FileName: fileName}}
+	}
+	return reply, nil
+}
+
+func (c *echoCommand) ErrorKey(_ error) i18n.Key {
+	if c.errorKey != "" {
+		return c.errorKey
+	}
+	return i18n.KeyErrorCommand
+}
+
+func TestEngineHandle(t *testing.T) {
+	errReply := errors.New("reply failed")
+	errorText := errorReplyText(i18n.KeyErrorCommand)
+	timeoutText := errorReplyText(i18n.KeyErrorTimeout)
+	outageText := "🚧 いま雨雲レーダーのデータが取れないっぽ、あとで試してほしいっぽ（気象庁に接続できないっぽ）\n" +
+		i18n.Message(i18n.DefaultLocale, i18n.KeyErrorRequestID, requestid.Short(testRequestID))
+
+	tests := []struct {
+		name              string
+		message           *bot.IncomingMessage
+		command           *echoCommand
+		timeouts          map[string]time.Duration
+		disabled          []string
+		reactErr          error
+		replyErr          error
+		expectedReactions []bot.Reaction
+		expectedReplies   []string
+		expectedError     error
+	}{
+		{
+			name:              "コマンドを実行して返信",
+			message:           &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command:           &echoCommand{attachment: &trackingReader{Reader: strings.NewReader("file")}},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing, bot.ReactionSuccess},
+			expectedReplies:   []string{"echo hello alice"},
+		},
+		{
+			name:    "コマンドでない",
+			message: &bot.IncomingMessage{ID: "1", Text: "hello"},
+			command: &echoCommand{},
+		},
+		{
+			name: "許可されていないコマンド",
+			message: &bot.IncomingMessage{ID: "1", Text: "echo hello", Allows: func(command string) bool {
+				return command != "echo"
+			}},
+			command: &echoCommand{},
+		},
+		{
+			name:            "無効にしたコマンドは実行せずに無効である旨を返信",
+			message:         &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command:         &echoCommand{},
+			disabled:        []string{"echo"},
+			expectedReplies: []string{errorReplyText(i18n.KeyErrorCommandDisabled)},
+		},
+		{
+			name:              "コマンドの失敗はエラーメッセージを返信",
+			message:           &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command:           &echoCommand{err: errors.New("failed")},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{errorText},
+		},
+		{
+			name:              "リアクションの失敗はエラーメッセージを返信",
+			message:           &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command:           &echoCommand{},
+			reactErr:          errors.New("react failed"),
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{errorText},
+		},
+		{
+			name:              "エラーメッセージの返信に失敗",
+			message:           &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command:           &echoCommand{err: errors.New("failed")},
+			replyErr:          errReply,
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{errorText},
+			expectedError:     errReply,
+		},
+		{
+			name:              "制限時間を超えたら時間切れのメッセージを返信",
+			message:           &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command:           &echoCommand{block: true},
+			timeouts:          map[string]time.Duration{"echo": 10 * time.Millisecond},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{timeoutText},
+		},
+		{
+			name:    "サーキットブレーカーが開いている外部サービスを返信",
+			message: &bot.IncomingMessage{ID: "1", Text: "echo hello"},
+			command: &echoCommand{
+				err:      errors.Wrap(&httpclient.CircuitOpenError{Upstream: httpclient.UpstreamJMA}, "Failed to Do"),
+				errorKey: i18n.KeyErrorUpstreamOutage,
+			},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{outageText},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			platform := &recordingPlatform{reactErr: tt.reactErr, replyErr: tt.replyErr}
+			engine := bot.NewEngine(&bot.EngineSetting{
+				Platform: platform,
+				Commands: []bot.Command{tt.command},
+				Timeouts: tt.timeouts,
+				Disabled: tt.disabled,
+			})
+
+			ctx := requestid.NewContext(t.Context(), testRequestID)
+			if err := engine.Handle(ctx, tt.message); !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Handle() error = %v, want %v", err, tt.expectedError)
+			}
+
+			if diff := cmp.Diff(tt.expectedReactions, platform.reactions); diff != "" {
+				t.Errorf("reactions mismatch (-want +got):\n%s", diff)
+			}
+			var replies []string
+			for _, reply := range platform.replies {
+				if reply.Command != "echo" {
+					t.Errorf("reply command = %q, want echo", reply.Command)
+				}
+				replies = append(replies, reply.Text)
+			}
+			if diff := cmp.Diff(tt.expectedReplies, replies); diff != "" {
+				t.Errorf("replies mismatch (-want +got):\n%s", diff)
+			}
+			// 添付ファイルは返信後に閉じる
+			if tt.command.attachment != nil && !tt.command.attachment.closed {
+				t.Error("attachment was not closed")
+			}
+		})
+	}
+}
+
+func TestEngineHandleRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string // 空の場合はコンテキストにリクエストIDを付けない
+	}{
+		{
+			name:      "コンテキストのリクエストIDを引き継ぐ",
+			requestID: testRequestID,
+		},
+		{
+			name:      "リクエストIDがない場合は新しく付ける",
+			requestID: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &echoCommand{}
+			engine := bot.NewEngine(&bot.EngineSetting{
+				Platform: &recordingPlatform{},
+				Commands: []bot.Command{command},
+			})
+
+			ctx := t.Context()
+			if tt.requestID != "" {
+				ctx = requestid.NewContext(ctx, tt.requestID)
+			}
+			if err := engine.Handle(ctx, &bot.IncomingMessage{ID: "1", Text: "echo hello"}); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+
+			switch {
+			case command.requestID == "":
+				t.Error("request ID was not attached to the context")
+			case tt.requestID != "" && command.requestID != tt.requestID:
+				t.Errorf("request ID = %q, want %q", command.requestID, tt.requestID)
+			}
+		})
+	}
+}
+
+// TestEngineShed 混雑のため処理しないコマンドに混雑のリアクションだけを付けることをテストする
+func TestEngineShed(t *testing.T) {
+	tests := []struct {
+		name              string
+		text              string
+		expectedReactions []bot.Reaction
+	}{
+		{
+			name:              "コマンドには混雑のリアクション",
+			text:              "echo hello",
+			expectedReactions: []bot.Reaction{bot.ReactionBusy},
+		},
+		{
+			name:              "コマンドでないメッセージには何もしない",
+			text:              "hello",
+			expectedReactions: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &echoCommand{}
+			platform := &recordingPlatform{}
+			engine := bot.NewEngine(&bot.EngineSetting{
+				Platform: platform,
+				Commands: []bot.Command{command},
+			})
+
+			if err := engine.Shed(t.Context(), &bot.IncomingMessage{ID: "1", Text: tt.text}); err != nil {
+				t.Fatalf("Shed() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.expectedReactions, platform.reactions); diff != "" {
+				t.Errorf("reactions mismatch (-want +got):\n%s", diff)
+			}
+			if len(platform.replies) != 0 || command.requestID != "" {
+				t.Errorf("Shed() executed the command: replies = %v", platform.replies)
+			}
+		})
+	}
+}
+
+// TestEngineHandleImageLimit 添付するPNG画像をプラットフォームの大きさの上限に収めて返信することをテストする
+func TestEngineHandleImageLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 512, 256))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		fileName     string
+		limit        *amesh.ImageLimit
+		expectedSize image.Point
+	}{
+		{name: "上限なし", fileName: "amesh.png", limit: nil, expectedSize: image.Pt(512, 256)},
+		{name: "上限を超える", fileName: "amesh.png", limit: &amesh.ImageLimit{MaxDimension: 256}, expectedSize: image.Pt(256, 128)},
+		{name: "PNG以外は制限しない", fileName: "amesh.dat", limit: &amesh.ImageLimit{MaxDimension: 256}, expectedSize: image.Pt(512, 256)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			attachment := &trackingReader{Reader: bytes.NewReader(buf.Bytes())}
+			platform := &recordingPlatform{}
+			engine := bot.NewEngine(&bot.EngineSetting{
+				Platform:   platform,
+				Commands:   []bot.Command{&echoCommand{attachment: attachment, fileName: tt.fileName}},
+				ImageLimit: tt.limit,
+			})
+
+			if err := engine.Handle(t.Context(), &bot.IncomingMessage{ID: "1", Text: "echo hello"}); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if !attachment.closed {
+				t.Error("original attachment was not closed")
+			}
+			if len(platform.replies) != 1 || len(platform.replies[0].Attachments) != 1 {
+				t.Fatalf("replies = %v, want 1 reply with 1 attachment", platform.replies)
+			}
+			imageConfig, err := png.DecodeConfig(platform.replies[0].Attachments[0].Reader)
+			if err != nil {
+				t.Fatalf("png.DecodeConfig() error = %v", err)
+			}
+			if size := image.Pt(imageConfig.Width, imageConfig.Height); size != tt.expectedSize {
+				t.Errorf("size = %v, want %v", size, tt.expectedSize)
+			}
+		})
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		setting   *bot.EngineSetting
+		expectNil bool
+	}{
+		{
+			name:      "nilの設定",
+			setting:   nil,
+			expectNil: true,
+		},
+		{
+			name:      "プラットフォームなし",
+			setting:   &bot.EngineSetting{Commands: bot.DefaultCommands("token", nil)},
+			expectNil: true,
+		},
+		{
+			name:    "正常系",
+			setting: &bot.EngineSetting{Platform: &recordingPlatform{}, Commands: bot.DefaultCommands("token", nil)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := bot.NewEngine(tt.setting); (got == nil) != tt.expectNil {
+				t.Errorf("NewEngine() = %v, expectNil = %v", got, tt.expectNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// DistanceCommand 2地点間の距離と方角を計算し、2地点を結んだ地図画像を返信するdistanceコマンド
+type DistanceCommand struct {
+	YahooAPIToken string          // ジオコーディング用Yahoo APIトークン（空の場合は埋め込みの地名の一覧だけで探す）
+	Client        httpclient.Doer // HTTPクライアント（nilの場合はameshパッケージの既定のクライアントとジオコーダ）
+	Clock         clock.Clock     // 画像のファイル名に使う時計（nilの場合はclock.Real）
+}
+
+// Name コマンド名
+func (c *DistanceCommand) Name() string {
+	return "distance"
+}
+
+// Match 本文がdistanceコマンドかを返す
+// 地名が「/」で区切られていない場合も一致させ、使い方を返信する
+func (c *DistanceCommand) Match(text string) bool {
+	return amesh.ParseDistanceCommand(text).IsDistance
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *DistanceCommand) ErrorKey(err error) i18n.Key {
+	return amesh.DistanceCommandErrorKey(err)
+}
+
+// Execute 2つの地名の距離と方角を計算して地図画像を作成し、画像を添付した返信を作成する
+func (c *DistanceCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	parsed := amesh.ParseDistanceCommand(req.Message.Text)
+	if parsed.From == "" || parsed.To == "" {
+		return nil, errors.Wrapf(amesh.ErrDistanceUsage, "text: %s", req.Message.Text)
+	}
+
+	// 位置を解析
+	from, err := c.parseLocation(ctx, parsed.From)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseLocation")
+	}
+	to, err := c.parseLocation(ctx, parsed.To)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseLocation")
+	}
+
+	// 画像を作成し、エンコードしながら読み出す
+	imageReader, err := c.createImageReader(ctx, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to createImageReader")
+	}
+
+	distance := amesh.MeasureDistance(from, to)
+	templateData := req.TemplateData
+	templateData.DistanceFrom = from.PlaceName
+	templateData.DistanceTo = to.PlaceName
+	templateData.Distance = distance.DistanceText()
+	templateData.Direction = distance.Direction(templateData.Locale)
+	templateData.Bearing = distance.BearingText()
+
+	requestid.Logf(ctx, "Successfully created distance image for %s and %s", from.PlaceName, to.PlaceName)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyDistanceSuccess, templateData),
+		Attachments: []*Attachment{{
+			Reader: imageReader,
+			FileName: amesh.GenerateFileName(&amesh.GenerateFileNameParams{
+				Locations: []*amesh.Location{from, to},
+				Clock:     c.Clock,
+				Prefix:    c.Name(),
+			}),
+			Description: req.Templates.Render(i18n.KeyDistanceDescription, templateData),
+		}},
+	}, nil
+}
+
+// parseLocation 設定に合わせたクライアントで地名を解析する
+func (c *DistanceCommand) parseLocation(ctx context.Context, place string) (*amesh.Location, error) {
+	if c.Client == nil {
+		location, err := amesh.ParseLocation(ctx, place, c.YahooAPIToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.ParseLocation")
+		}
+		return location, nil
+	}
+	location, err := amesh.ParseLocationWithClient(ctx, &amesh.ParseLocationParams{
+		Client:         c.Client,
+		GeocodeRequest: amesh.GeocodeRequest{Place: place, APIKey: c.YahooAPIToken},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseLocationWithClient")
+	}
+	return location, nil
+}
+
+// createImageReader 設定に合わせたクライアントで画像を作成する
+func (c *DistanceCommand) createImageReader(ctx context.Context, from, to *amesh.Location) (*amesh.ImageReader, error) {
+	if c.Client == nil {
+		imageReader, err := amesh.CreateDistanceImageReader(ctx, from, to)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateDistanceImageReader")
+		}
+		return imageReader, nil
+	}
+	imageReader, err := amesh.CreateDistanceImageReaderWithClient(ctx, &amesh.DistanceImageParams{
+		Client: c.Client,
+		From:   from,
+		To:     to,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateDistanceImageReaderWithClient")
+	}
+	return imageReader, nil
+}
@@ -0,0 +1,128 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestDistanceCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato distance 東京 / 大阪", expected: true},
+		{name: "区切りなしも使い方を返信する", text: "distance 東京", expected: true},
+		{name: "別のコマンド", text: "map 東京", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.DistanceCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestDistanceCommandExecute 2地点の距離と方角の返信に地図画像を添付することを確認する
+func TestDistanceCommandExecute(t *testing.T) {
+	tests := []struct {
+		name                string
+		locale              i18n.Locale
+		expectedText        string
+		expectedDescription string
+	}{
+		{
+			name:                "日本語",
+			locale:              i18n.LocaleJa,
+			expectedText:        "📏 35.68,139.77 から 34.70,135.50 までは約403.1kmで、方角は西南西（256°）だっぽ",
+			expectedDescription: "35.68,139.77 と 34.70,135.50 を結んだ地図",
+		},
+		{
+			name:                "英語",
+			locale:              i18n.LocaleEn,
+			expectedText:        "📏 From 35.68,139.77 to 34.70,135.50 is about 403.1km, heading WSW (256°)",
+			expectedDescription: "Map connecting 35.68,139.77 and 34.70,135.50",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tiles := ameshtest.NewServer(t, nil)
+			command := &bot.DistanceCommand{
+				Client: tiles.Client(),
+				Clock:  clocktest.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+			}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "distance 35.6812,139.7671 / 34.7025,135.4959"},
+				TemplateData: &i18n.TemplateData{Locale: tt.locale},
+			})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			attachment := reply.Attachments[0]
+			defer func() { _ = attachment.Reader.Close() }()
+
+			if reply.Text != tt.expectedText {
+				t.Errorf("Text = %q, want %q", reply.Text, tt.expectedText)
+			}
+			if attachment.Description != tt.expectedDescription {
+				t.Errorf("Description = %q, want %q", attachment.Description, tt.expectedDescription)
+			}
+			if expected := "distance_35.68,139.77_34.70,135.50_2030-01-01_0900JST_"; !strings.HasPrefix(attachment.FileName, expected) {
+				t.Errorf("FileName = %q, want prefix %q", attachment.FileName, expected)
+			}
+			if got := tiles.RequestsTo("jma.go.jp"); len(got) != 0 {
+				t.Errorf("JMA requests = %v, want none", got)
+			}
+		})
+	}
+}
+
+// TestDistanceCommandExecuteInvalid 外部APIにアクセスする前に失敗する場合をテストする
+func TestDistanceCommandExecuteInvalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           *bot.Request
+		expectedError error
+	}{
+		{
+			name:          "テンプレートの値がない",
+			req:           &bot.Request{Message: &bot.IncomingMessage{Text: "distance 東京 / 大阪"}},
+			expectedError: lib.ErrParamsNil,
+		},
+		{
+			name: "地名が区切られていない",
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "distance 東京 大阪"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedError: amesh.ErrDistanceUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.DistanceCommand{}
+			if _, err := command.Execute(t.Context(), tt.req); !errors.Is(err, tt.expectedError) {
+				t.Errorf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
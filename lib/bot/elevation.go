@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/elevation"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// ElevationCommand 地点の標高を返信するelevationコマンド
+type ElevationCommand struct {
+	YahooAPIToken string          // ジオコーディング用Yahoo APIトークン（空の場合は埋め込みの地名の一覧だけで探す）
+	Client        httpclient.Doer // HTTPクライアント（nilの場合はameshパッケージとelevationパッケージの既定のクライアント）
+}
+
+// Name コマンド名
+func (c *ElevationCommand) Name() string {
+	return "elevation"
+}
+
+// Match 本文がelevationコマンドかを返す
+func (c *ElevationCommand) Match(text string) bool {
+	return elevation.ParseElevationCommand(text).IsElevation
+}
+
+// Place 本文から履歴に記録する地名を返す
+func (c *ElevationCommand) Place(text string) string {
+	return elevation.ParseElevationCommand(text).Place
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *ElevationCommand) ErrorKey(err error) i18n.Key {
+	return elevation.CommandErrorKey(err)
+}
+
+// Execute 地名の地点の標高を取得し、返信を作成する
+func (c *ElevationCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	// 位置を解析
+	location, err := c.parseLocation(ctx, elevation.ParseElevationCommand(req.Message.Text).Place)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseLocation")
+	}
+
+	result, err := getElevation(ctx, c.Client, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to getElevation")
+	}
+
+	templateData := req.TemplateData
+	templateData.PlaceName = location.PlaceName
+	templateData.Lat = location.Lat
+	templateData.Lng = location.Lng
+	templateData.Elevation = result.Text()
+
+	requestid.Logf(ctx, "Successfully fetched elevation for %s (%s)", location.PlaceName, result.Source)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyElevationSuccess, templateData),
+	}, nil
+}
+
+// parseLocation 設定に合わせたクライアントで地名を解析する
+func (c *ElevationCommand) parseLocation(ctx context.Context, place string) (*amesh.Location, error) {
+	if c.Client == nil {
+		location, err := amesh.ParseLocation(ctx, place, c.YahooAPIToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.ParseLocation")
+		}
+		return location, nil
+	}
+	location, err := amesh.ParseLocationWithClient(ctx, &amesh.ParseLocationParams{
+		Client:         c.Client,
+		GeocodeRequest: amesh.GeocodeRequest{Place: place, APIKey: c.YahooAPIToken},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseLocationWithClient")
+	}
+	return location, nil
+}
+
+// getElevation 指定したクライアントで地点の標高を取得する（nilの場合はelevationパッケージの既定のクライアント）
+func getElevation(ctx context.Context, client httpclient.Doer, location *amesh.Location) (*elevation.Elevation, error) {
+	if client == nil {
+		result, err := elevation.GetElevation(ctx, location)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to elevation.GetElevation")
+		}
+		return result, nil
+	}
+	result, err := elevation.GetElevationWithClient(ctx, &elevation.GetElevationWithClientParams{
+		Client:   client,
+		Location: location,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to elevation.GetElevationWithClient")
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package bot_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/elevation"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+// newElevationClient 標高APIのレスポンスを返すHTTPクライアントを作成する
+func newElevationClient(body string) *http.Client {
+	return &http.Client{Transport: httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{{Pattern: "getelevation.php", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: body}}}},
+	})}
+}
+
+func TestElevationCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato elevation 富士山", expected: true},
+		{name: "地名なし", text: "elevation", expected: true},
+		{name: "別のコマンド", text: "amesh 東京", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.ElevationCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestElevationCommandExecute 地点の標高を返信することを確認する
+func TestElevationCommandExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		expected      string
+		expectedError error
+	}{
+		{
+			name:     "標高を返信する",
+			body:     `{"elevation":3775.5,"hsrc":"5m（写真測量）"}`,
+			expected: "⛰ The elevation of 35.36,138.73 (35.3606, 138.7274) is 3775.5m",
+		},
+		{
+			name:          "データがない地点",
+			body:          `{"elevation":"-----","hsrc":"-----"}`,
+			expectedError: elevation.ErrNoElevation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.ElevationCommand{Client: newElevationClient(tt.body)}
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "elevation 35.3606,138.7274"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if reply.Text != tt.expected {
+				t.Errorf("Text = %q, want %q", reply.Text, tt.expected)
+			}
+		})
+	}
+}
+
+// TestElevationCommandExecuteInvalid 外部APIにアクセスする前に失敗する場合をテストする
+func TestElevationCommandExecuteInvalid(t *testing.T) {
+	t.Parallel()
+	command := &bot.ElevationCommand{}
+	if _, err := command.Execute(t.Context(), &bot.Request{Message: &bot.IncomingMessage{Text: "elevation 東京"}}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("Execute() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
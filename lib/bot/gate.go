@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/metrics"
+)
+
+// Gateの設定を省略した場合の既定値
+const (
+	DefaultGateFailureThreshold = 3                // 受付を止めるまでの連続した失敗の回数
+	DefaultGateProbeInterval    = 30 * time.Second // 依存先の確認に失敗した場合に再び確認するまでの間隔
+	DefaultGateMaxBacklog       = 100              // 受付を止めている間に保留するメッセージの上限
+)
+
+// Probe 依存する外部サービスが使えるかの確認
+type Probe struct {
+	Name  string                          // ログに表示する名前
+	Check func(ctx context.Context) error // 使える場合はnilを返す
+}
+
+// GateSetting Gateの設定
+type GateSetting struct {
+	Probes           []Probe          // 受付を始める前と再開する前に全て成功する必要がある確認
+	Commands         []string         // 連続した失敗を数えるコマンド名（画像を作成するコマンドなど）
+	IgnoreError      func(error) bool // trueを返したエラーは失敗として数えない（利用者の入力の誤りなど、nilの場合は全て数える）
+	FailureThreshold int              // この回数連続して失敗したら受付を止める（0以下の場合はDefaultGateFailureThreshold）
+	ProbeInterval    time.Duration    // 確認に失敗した場合に再び確認するまでの間隔（0以下の場合はDefaultGateProbeInterval）
+	MaxBacklog       int              // 受付を止めている間に保留するメッセージの上限（0以下の場合はDefaultGateMaxBacklog、超えた場合は古いものから捨てる）
+	Clock            clock.Clock      // 確認の間隔を待つ時計（nilの場合はclock.Real）
+}
+
+// Gate 依存する外部サービスの状態に応じてメッセージの受付を止める
+// 起動時は確認が成功するまで受付を始めず、コマンドが連続して失敗した場合は受付を止めてメッセージを保留する
+// 止めている間は確認を繰り返し、成功したら保留したメッセージを受け取った順に処理して受付を再開する
+// 外部サービスの障害中に全ての利用者へエラーメッセージを返信しないようにする
+// nilのGateは常に受け付ける
+type Gate struct {
+	setting GateSetting
+	pausedC chan struct{}
+
+	mu       sync.Mutex
+	paused   bool
+	failures int
+	backlog  []func(ctx context.Context)
+}
+
+// NewGate 新しいGateを作成する
+func NewGate(setting *GateSetting) *Gate {
+	g := &Gate{pausedC: make(chan struct{}, 1)}
+	if setting != nil {
+		g.setting = *setting
+	}
+	if g.setting.FailureThreshold <= 0 {
+		g.setting.FailureThreshold = DefaultGateFailureThreshold
+	}
+	if g.setting.ProbeInterval <= 0 {
+		g.setting.ProbeInterval = DefaultGateProbeInterval
+	}
+	if g.setting.MaxBacklog <= 0 {
+		g.setting.MaxBacklog = DefaultGateMaxBacklog
+	}
+	g.setting.Clock = clock.Or(g.setting.Clock)
+	return g
+}
+
+// WaitReady 全ての確認が成功するまで間隔を空けて確認を繰り返す
+// ctxが終了した場合はエラーを返す
+func (g *Gate) WaitReady(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	for {
+		failed := g.probe(ctx)
+		if failed == 0 {
+			return nil
+		}
+		log.Printf("Waiting for %d dependencies, retrying in %s", failed, g.setting.ProbeInterval)
+		if err := g.setting.Clock.Sleep(ctx, g.setting.ProbeInterval); err != nil {
+			return errors.Wrap(err, "Failed to wait for dependencies")
+		}
+	}
+}
+
+// probe 全ての確認を実行し、失敗した数を返す
+func (g *Gate) probe(ctx context.Context) int {
+	failed := 0
+	for _, probe := range g.setting.Probes {
+		if err := probe.Check(ctx); err != nil {
+			log.Printf("Dependency %s is not ready: %v", probe.Name, err)
+			metrics.Default.Counter("bot.gate." + probe.Name + ".probe_failures").Inc()
+			failed++
+		}
+	}
+	return failed
+}
+
+// Paused 受付を止めているかを返す
+func (g *Gate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Submit 受け付けている場合はすぐにhandleを実行し、止めている場合は再開するまで保留する
+func (g *Gate) Submit(ctx context.Context, handle func(ctx context.Context)) {
+	if g == nil {
+		handle(ctx)
+		return
+	}
+	g.mu.Lock()
+	if !g.paused {
+		g.mu.Unlock()
+		handle(ctx)
+		return
+	}
+	if g.setting.MaxBacklog <= len(g.backlog) {
+		g.backlog = slices.Delete(g.backlog, 0, 1)
+		metrics.Default.Counter("bot.gate.dropped").Inc()
+		log.Printf("Backlog is full (%d): dropped the oldest message", g.setting.MaxBacklog)
+	}
+	g.backlog = append(g.backlog, handle)
+	g.mu.Unlock()
+}
+
+// Run 受付を止めるたびに確認が成功するまで待ち、保留したメッセージを処理して受付を再開する
+// ctxが終了するまで戻らない
+func (g *Gate) Run(ctx context.Context) {
+	if g == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.pausedC:
+		}
+		if err := g.WaitReady(ctx); err != nil {
+			return
+		}
+		g.resume(ctx)
+	}
+}
+
+// resume 受付を再開し、保留したメッセージを受け取った順に処理する
+// 処理の途中で再び受付を止めた場合は残りを保留したままにする
+func (g *Gate) resume(ctx context.Context) {
+	g.mu.Lock()
+	g.paused = false
+	g.failures = 0
+	log.Printf("Dependencies recovered: resuming with %d queued messages", len(g.backlog))
+	g.mu.Unlock()
+
+	for ctx.Err() == nil {
+		g.mu.Lock()
+		if g.paused || len(g.backlog) == 0 {
+			g.mu.Unlock()
+			return
+		}
+		handle := g.backlog[0]
+		g.backlog = g.backlog[1:]
+		g.mu.Unlock()
+		handle(ctx)
+	}
+}
+
+// record コマンドの結果を記録し、連続した失敗が上限に達したら受付を止める
+func (g *Gate) record(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		g.failures = 0
+		return
+	}
+	g.failures++
+	if g.paused || g.failures < g.setting.FailureThreshold {
+		return
+	}
+	g.paused = true
+	metrics.Default.Counter("bot.gate.paused").Inc()
+	log.Printf("Pausing message consumption after %d consecutive failures: %v", g.failures, err)
+	select {
+	case g.pausedC <- struct{}{}:
+	default:
+	}
+}
+
+// Middleware 対象のコマンドの成否を数えるミドルウェアを返す
+// 実行回数の制限と制限時間の間に置き、時間切れも失敗として数える
+func (g *Gate) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			if g == nil || !slices.Contains(g.setting.Commands, call.Command.Name()) {
+				return err
+			}
+			if err != nil && (errors.Is(err, ErrRateLimited) || (g.setting.IgnoreError != nil && g.setting.IgnoreError(err))) {
+				return err
+			}
+			g.record(err)
+			return err
+		}
+	}
+}
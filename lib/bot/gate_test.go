@@ -0,0 +1,217 @@
+package bot_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock/clocktest"
+)
+
+// errUpstream 外部サービスの障害を表すテスト用のエラー
+var errUpstream = errors.New("upstream unavailable")
+
+// errInput 利用者の入力の誤りを表すテスト用のエラー
+var errInput = errors.New("invalid input")
+
+func TestGateWaitReady(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	fake := clocktest.NewFake(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	gate := bot.NewGate(&bot.GateSetting{
+		Probes: []bot.Probe{{Name: "jma", Check: func(_ context.Context) error {
+			if calls.Add(1) < 3 {
+				return errUpstream
+			}
+			return nil
+		}}},
+		ProbeInterval: time.Minute,
+		Clock:         fake,
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- gate.WaitReady(context.Background()) }()
+
+	// 確認に失敗するたびにProbeIntervalだけ待ってから確認し直す
+	for want := int32(1); want < 3; want++ {
+		fake.BlockUntil(1)
+		if got := calls.Load(); got != want {
+			t.Errorf("calls before retry = %d, want %d", got, want)
+		}
+		fake.Advance(time.Minute)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestGateWaitReadyCanceled(t *testing.T) {
+	t.Parallel()
+	gate := bot.NewGate(&bot.GateSetting{
+		Probes:        []bot.Probe{{Name: "jma", Check: func(_ context.Context) error { return errUpstream }}},
+		ProbeInterval: time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := gate.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGateMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		results  []error
+		expected bool
+	}{
+		{
+			name:     "連続した失敗で受付を止める",
+			command:  "echo",
+			results:  []error{errUpstream, errUpstream, errUpstream},
+			expected: true,
+		},
+		{
+			name:     "成功で失敗の回数を戻す",
+			command:  "echo",
+			results:  []error{errUpstream, errUpstream, nil, errUpstream, errUpstream},
+			expected: false,
+		},
+		{
+			name:     "時間切れも失敗として数える",
+			command:  "echo",
+			results:  []error{bot.ErrTimeout, bot.ErrTimeout, bot.ErrTimeout},
+			expected: true,
+		},
+		{
+			name:     "入力の誤りと実行回数の制限は数えない",
+			command:  "echo",
+			results:  []error{errInput, bot.ErrRateLimited, errUpstream, errInput, errUpstream},
+			expected: false,
+		},
+		{
+			name:     "対象外のコマンドは数えない",
+			command:  "other",
+			results:  []error{errUpstream, errUpstream, errUpstream},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gate := bot.NewGate(&bot.GateSetting{
+				Commands:    []string{tt.command},
+				IgnoreError: func(err error) bool { return errors.Is(err, errInput) },
+			})
+			var i int
+			handler := bot.Chain(func(_ context.Context, _ *bot.Call) error {
+				err := tt.results[i]
+				i++
+				return err
+			}, gate.Middleware())
+
+			call := &bot.Call{Command: &echoCommand{}, Message: &bot.IncomingMessage{Text: "echo"}}
+			for range tt.results {
+				_ = handler(context.Background(), call)
+			}
+			if result := gate.Paused(); result != tt.expected {
+				t.Errorf("Paused() = %t, want %t", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGateBacklog(t *testing.T) {
+	t.Parallel()
+	var ready atomic.Bool
+	gate := bot.NewGate(&bot.GateSetting{
+		Probes: []bot.Probe{{Name: "jma", Check: func(_ context.Context) error {
+			if !ready.Load() {
+				return errUpstream
+			}
+			return nil
+		}}},
+		Commands:         []string{"echo"},
+		FailureThreshold: 1,
+		ProbeInterval:    time.Millisecond,
+		MaxBacklog:       2,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gate.Run(ctx)
+
+	var mu sync.Mutex
+	var handled []string
+	submit := func(text string) {
+		gate.Submit(ctx, func(_ context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, text)
+		})
+	}
+
+	// 1回の失敗で受付を止める
+	handler := bot.Chain(func(_ context.Context, _ *bot.Call) error { return errUpstream }, gate.Middleware())
+	_ = handler(ctx, &bot.Call{Command: &echoCommand{}, Message: &bot.IncomingMessage{Text: "echo"}})
+	if !gate.Paused() {
+		t.Fatal("Paused() = false, want true")
+	}
+
+	// 上限を超えた分は古いものから捨てる
+	submit("1")
+	submit("2")
+	submit("3")
+	mu.Lock()
+	if len(handled) != 0 {
+		t.Errorf("handled while paused = %v", handled)
+	}
+	mu.Unlock()
+
+	ready.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for gate.Paused() || func() bool { mu.Lock(); defer mu.Unlock(); return len(handled) < 2 }() {
+		if time.Now().After(deadline) {
+			t.Fatal("gate did not resume")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 再開した後はすぐに処理する
+	submit("4")
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff([]string{"2", "3", "4"}, handled); diff != "" {
+		t.Errorf("handled mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGateNil(t *testing.T) {
+	t.Parallel()
+	var gate *bot.Gate
+	if err := gate.WaitReady(context.Background()); err != nil {
+		t.Errorf("WaitReady() error = %v", err)
+	}
+	handled := false
+	gate.Submit(context.Background(), func(_ context.Context) { handled = true })
+	if !handled {
+		t.Error("Submit() did not handle the message")
+	}
+	handler := bot.Chain(func(_ context.Context, _ *bot.Call) error { return errUpstream }, gate.Middleware())
+	if err := handler(context.Background(), &bot.Call{Command: &echoCommand{}}); !errors.Is(err, errUpstream) {
+		t.Errorf("handler() error = %v, want %v", err, errUpstream)
+	}
+	if gate.Paused() {
+		t.Error("Paused() = true, want false")
+	}
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"context"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/mfm"
+)
+
+// HelpCommand 使えるコマンドと設定ファイルで無効にしたコマンドの一覧を返信するhelpコマンド
+type HelpCommand struct {
+	Commands []string // 使えるコマンド名（管理者向けのコマンドは含めない）
+	Disabled []string // 設定ファイルで無効にしたコマンド名
+}
+
+// Name コマンド名
+func (c *HelpCommand) Name() string {
+	return "help"
+}
+
+// Match 本文がhelpコマンドかを返す
+func (c *HelpCommand) Match(text string) bool {
+	return lib.ParseCommand(text, c.Name()).Matched
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *HelpCommand) ErrorKey(_ error) i18n.Key {
+	return i18n.KeyErrorHelpCommand
+}
+
+// Execute 使えるコマンドの一覧を返信する
+// 無効にしたコマンドがある場合は無効である旨も添える
+func (c *HelpCommand) Execute(_ context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	templateData := req.TemplateData
+	templateData.HelpCommands = formatCommandNames(c.Commands, req.MFM)
+	templateData.HelpDisabled = formatCommandNames(c.Disabled, req.MFM)
+	text := req.Templates.Render(i18n.KeyHelpSuccess, templateData)
+	if 0 < len(c.Disabled) {
+		text += "\n" + req.Templates.Render(i18n.KeyHelpDisabled, templateData)
+	}
+	return &OutgoingReply{Command: c.Name(), Text: text}, nil
+}
+
+// formatCommandNames コマンド名を区切り文字で連結する（MFMを使える場合はインラインコードにする）
+func formatCommandNames(names []string, markup bool) string {
+	builder := mfm.NewBuilder(markup)
+	for i, name := range names {
+		if 0 < i {
+			builder.Text(", ")
+		}
+		builder.Code(name)
+	}
+	return builder.String()
+}
@@ -0,0 +1,73 @@
+package bot_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/history"
+)
+
+// markupPlatform MFMを使えるrecordingPlatform
+type markupPlatform struct {
+	recordingPlatform
+}
+
+func (p *markupPlatform) SupportsMFM() bool {
+	return true
+}
+
+func TestEngineHelpCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  *bot.EngineSetting
+		mfm      bool
+		expected string
+	}{
+		{
+			name:     "使えるコマンドの一覧",
+			setting:  &bot.EngineSetting{Commands: []bot.Command{&bot.AmeshCommand{}, &bot.WikipediaCommand{}}},
+			expected: "📋 使えるコマンドだっぽ: amesh, wiki",
+		},
+		{
+			name: "無効にしたコマンドは分けて表示し、管理者向けのコマンドは含めない",
+			setting: &bot.EngineSetting{
+				Commands: []bot.Command{&bot.AmeshCommand{}, &bot.WikipediaCommand{}},
+				Admins:   []string{"admin"},
+				History:  &history.Store{},
+				Disabled: []string{"wiki"},
+			},
+			expected: "📋 使えるコマンドだっぽ: amesh\n無効になっているコマンド: wiki",
+		},
+		{
+			name:     "MFMを使えるプラットフォームではコマンド名をインラインコードにする",
+			setting:  &bot.EngineSetting{Commands: []bot.Command{&bot.AmeshCommand{}, &bot.WikipediaCommand{}}},
+			mfm:      true,
+			expected: "📋 使えるコマンドだっぽ: `amesh`, `wiki`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			platform := &markupPlatform{}
+			tt.setting.Platform = &platform.recordingPlatform
+			if tt.mfm {
+				tt.setting.Platform = platform
+			}
+			engine := bot.NewEngine(tt.setting)
+
+			if err := engine.Handle(t.Context(), &bot.IncomingMessage{ID: "1", Text: "@hato help", UserID: "user"}); err != nil {
+				t.Fatal(err)
+			}
+			var replies []string
+			for _, reply := range platform.replies {
+				replies = append(replies, reply.Text)
+			}
+			if diff := cmp.Diff([]string{tt.expected}, replies); diff != "" {
+				t.Errorf("replies mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// MapCommand 雨雲レーダーを重ねない地図画像を返信するmapコマンド
+type MapCommand struct {
+	YahooAPIToken string          // ジオコーディング用Yahoo APIトークン（空の場合は埋め込みの地名の一覧だけで探す）
+	Client        httpclient.Doer // HTTPクライアント（nilの場合はameshパッケージの既定のクライアントとジオコーダ）
+	Clock         clock.Clock     // 画像のファイル名に使う時計（nilの場合はclock.Real）
+}
+
+// Name コマンド名
+func (c *MapCommand) Name() string {
+	return "map"
+}
+
+// Match 本文がmapコマンドかを返す
+func (c *MapCommand) Match(text string) bool {
+	return amesh.ParseMapCommand(text).IsMap
+}
+
+// Place 本文から履歴に記録する地名を返す
+func (c *MapCommand) Place(text string) string {
+	return amesh.ParseMapCommand(text).Place
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *MapCommand) ErrorKey(err error) i18n.Key {
+	return amesh.MapCommandErrorKey(err)
+}
+
+// Execute 地名の地点にマーカーを立てた地図画像を作成し、画像を添付した返信を作成する
+func (c *MapCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	// 位置を解析
+	location, err := c.parseLocation(ctx, amesh.ParseMapCommand(req.Message.Text).Place)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseLocation")
+	}
+
+	// 画像を作成し、エンコードしながら読み出す
+	imageReader, err := c.createImageReader(ctx, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to createImageReader")
+	}
+
+	templateData := req.TemplateData
+	templateData.PlaceName = location.PlaceName
+	templateData.Lat = location.Lat
+	templateData.Lng = location.Lng
+
+	requestid.Logf(ctx, "Successfully created map image for %s", location.PlaceName)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyMapSuccess, templateData),
+		Attachments: []*Attachment{{
+			Reader: imageReader,
+			FileName: amesh.GenerateFileName(&amesh.GenerateFileNameParams{
+				Locations: []*amesh.Location{location},
+				Clock:     c.Clock,
+				Prefix:    c.Name(),
+			}),
+			Description: req.Templates.Render(i18n.KeyMapImageDescription, templateData),
+		}},
+	}, nil
+}
+
+// parseLocation 設定に合わせたクライアントで地名を解析する
+func (c *MapCommand) parseLocation(ctx context.Context, place string) (*amesh.Location, error) {
+	if c.Client == nil {
+		location, err := amesh.ParseLocation(ctx, place, c.YahooAPIToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.ParseLocation")
+		}
+		return location, nil
+	}
+	location, err := amesh.ParseLocationWithClient(ctx, &amesh.ParseLocationParams{
+		Client:         c.Client,
+		GeocodeRequest: amesh.GeocodeRequest{Place: place, APIKey: c.YahooAPIToken},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseLocationWithClient")
+	}
+	return location, nil
+}
+
+// createImageReader 設定に合わせたクライアントで画像を作成する
+func (c *MapCommand) createImageReader(ctx context.Context, location *amesh.Location) (*amesh.ImageReader, error) {
+	if c.Client == nil {
+		imageReader, err := amesh.CreateMapImageReader(ctx, location)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.CreateMapImageReader")
+		}
+		return imageReader, nil
+	}
+	imageReader, err := amesh.CreateMapImageReaderWithClient(ctx, &amesh.LocationImageParams{
+		Client:   c.Client,
+		Location: location,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateMapImageReaderWithClient")
+	}
+	return imageReader, nil
+}
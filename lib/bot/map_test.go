@@ -0,0 +1,81 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestMapCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato map 東京", expected: true},
+		{name: "ハッシュタグ", text: "#map 大阪", expected: true},
+		{name: "別のコマンド", text: "amesh 東京", expected: false},
+		{name: "コマンドでない", text: "mapping 東京", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.MapCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestMapCommandExecute 地図画像を添付した返信を作成することを確認する
+func TestMapCommandExecute(t *testing.T) {
+	t.Parallel()
+	tiles := ameshtest.NewServer(t, nil)
+	command := &bot.MapCommand{
+		Client: tiles.Client(),
+		Clock:  clocktest.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	reply, err := command.Execute(t.Context(), &bot.Request{
+		Message:      &bot.IncomingMessage{Text: "map 35.6812,139.7671"},
+		TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	attachment := reply.Attachments[0]
+	defer func() { _ = attachment.Reader.Close() }()
+
+	if expected := "🗺 Map of 35.68,139.77 (35.6812, 139.7671)"; reply.Text != expected {
+		t.Errorf("Text = %q, want %q", reply.Text, expected)
+	}
+	if expected := "Map of 35.68,139.77 (35.6812, 139.7671)"; attachment.Description != expected {
+		t.Errorf("Description = %q, want %q", attachment.Description, expected)
+	}
+	// 雨雲レーダーの時刻がないため、時計の時刻を日本時間で使う
+	if expected := "map_35.68,139.77_2030-01-01_0900JST_"; !strings.HasPrefix(attachment.FileName, expected) {
+		t.Errorf("FileName = %q, want prefix %q", attachment.FileName, expected)
+	}
+	if got := tiles.RequestsTo("jma.go.jp"); len(got) != 0 {
+		t.Errorf("JMA requests = %v, want none", got)
+	}
+}
+
+// TestMapCommandExecuteInvalid 外部APIにアクセスする前に失敗する場合をテストする
+func TestMapCommandExecuteInvalid(t *testing.T) {
+	t.Parallel()
+	command := &bot.MapCommand{}
+	if _, err := command.Execute(t.Context(), &bot.Request{Message: &bot.IncomingMessage{Text: "map 東京"}}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("Execute() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
@@ -0,0 +1,342 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/metrics"
+	"hato-bot-go/lib/report"
+	"hato-bot-go/lib/requestid"
+)
+
+var (
+	// ErrTimeout コマンドの処理が制限時間を超えたことを表すエラー
+	ErrTimeout = errors.New("command timed out")
+	// ErrRateLimited 送信者のコマンドの実行回数が上限に達したことを表すエラー
+	ErrRateLimited = errors.New("rate limited")
+	// ErrCommandDisabled 設定ファイルで無効にしたコマンドを使ったことを表すエラー
+	ErrCommandDisabled = errors.New("command disabled")
+)
+
+// Call ミドルウェアに渡すコマンドの呼び出し
+type Call struct {
+	Command Command          // 実行するコマンド
+	Message *IncomingMessage // 受信したメッセージ
+}
+
+// HandlerFunc コマンドの呼び出しを処理する関数
+type HandlerFunc func(ctx context.Context, call *Call) error
+
+// Middleware コマンドの処理を包んで認証・ログ・メトリクスなどの横断的な処理を追加する
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain ミドルウェアを重ねる
+// 先頭のミドルウェアが最も外側になり、最初に呼ばれる
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; 0 <= i; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// reportTags エラー報告に付けるタグを返す
+func reportTags(ctx context.Context, platform string, call *Call) map[string]string {
+	tags := map[string]string{"platform": platform, "command": call.Command.Name()}
+	if id := requestid.FromContext(ctx); id != "" {
+		tags["request_id"] = id
+	}
+	return tags
+}
+
+// Recover コマンドの処理中のパニックから回復して報告する
+func Recover(reporter *report.Reporter, platform string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			defer reporter.Recover(ctx, reportTags(ctx, platform, call))
+			return next(ctx, call)
+		}
+	}
+}
+
+// Logging コマンドの開始と失敗をリクエストID付きでログに出力する
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			requestid.Logf(ctx, "Processing %s command: %s", call.Command.Name(), call.Message.Text)
+			err := next(ctx, call)
+			if err != nil {
+				requestid.Logf(ctx, "Error processing %s command: %v", call.Command.Name(), err)
+			}
+			return err
+		}
+	}
+}
+
+// Metrics コマンドごとの実行回数・失敗回数・時間切れの回数を数える
+func Metrics(registry *metrics.Registry) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			prefix := "bot." + call.Command.Name()
+			registry.Counter(prefix + ".requests").Inc()
+			err := next(ctx, call)
+			switch {
+			case err == nil:
+			case errors.Is(err, ErrTimeout):
+				registry.Counter(prefix + ".timeouts").Inc()
+			case errors.Is(err, ErrRateLimited):
+				registry.Counter(prefix + ".rate_limited").Inc()
+			default:
+				registry.Counter(prefix + ".errors").Inc()
+			}
+			return err
+		}
+	}
+}
+
+// History コマンドの処理の記録を保存する
+// 送信者のIDはハッシュにして保存し、保存の失敗はログに出力してコマンドの結果に影響させない
+// 地名はPlaceCommandを実装するコマンドのみ記録する
+func History(store *history.Store, platform string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			start := time.Now()
+			err := next(ctx, call)
+			record := &history.Record{
+				At:         start,
+				User:       store.HashUser(platform, call.Message.UserID),
+				Platform:   platform,
+				Command:    call.Command.Name(),
+				DurationMS: time.Since(start).Milliseconds(),
+				Outcome:    outcome(err),
+			}
+			if command, ok := call.Command.(PlaceCommand); ok {
+				record.Place = command.Place(call.Message.Text)
+			}
+			if addErr := store.Add(record); addErr != nil {
+				requestid.Logf(ctx, "Failed to Add: %v", addErr)
+			}
+			return err
+		}
+	}
+}
+
+// outcome コマンドの実行結果のエラーから記録する処理結果を返す
+func outcome(err error) history.Outcome {
+	switch {
+	case err == nil:
+		return history.OutcomeSuccess
+	case errors.Is(err, ErrTimeout):
+		return history.OutcomeTimeout
+	case errors.Is(err, ErrRateLimited):
+		return history.OutcomeRateLimited
+	default:
+		return history.OutcomeError
+	}
+}
+
+// Allowlist メッセージが応答を許可していないコマンドを何もせずに無視する
+func Allowlist() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			if call.Message.Allows != nil && !call.Message.Allows(call.Command.Name()) {
+				return nil
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+// Disabled 設定ファイルで無効にしたコマンドを実行せずにErrCommandDisabledを返す
+// 無効にしたコマンドはログやメトリクスに残さない
+func Disabled(names []string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			if slices.Contains(names, call.Command.Name()) {
+				return errors.Wrapf(ErrCommandDisabled, "command: %s", call.Command.Name())
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+// ErrorReplySetting ErrorReplyの設定
+type ErrorReplySetting struct {
+	Platform  Platform         // 返信先のプラットフォーム
+	Templates *i18n.Templates  // 返信テンプレート（nilの場合はメッセージカタログの文言）
+	Reporter  *report.Reporter // エラーの報告先（nilの場合は報告しない）
+}
+
+// ErrorReply コマンドの失敗を報告してエラーメッセージを返信する
+// 利用者が問い合わせられるよう、エラーメッセージには短いリクエストIDを添える
+// エラーメッセージの返信に失敗した場合のみエラーを返す
+func ErrorReply(setting *ErrorReplySetting) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			if err == nil {
+				return nil
+			}
+
+			// 実行回数の上限と無効にしたコマンドは利用者の操作や運用者の設定によるものなので報告しない
+			if !errors.Is(err, ErrRateLimited) && !errors.Is(err, ErrCommandDisabled) {
+				setting.Reporter.Report(ctx, &report.Event{
+					Message: "Error processing " + call.Command.Name() + " command",
+					Err:     err,
+					Tags:    reportTags(ctx, setting.Platform.Name(), call),
+				})
+			}
+
+			templateData := setting.Platform.TemplateData(call.Message)
+			// サーキットブレーカーが開いている場合は停止中の外部サービスをエラーメッセージに含められるようにする
+			var circuitErr *httpclient.CircuitOpenError
+			if errors.As(err, &circuitErr) {
+				templateData.Upstream, templateData.UpstreamData = i18n.UpstreamName(templateData.Locale, circuitErr.Upstream)
+			}
+			text := setting.Templates.Render(errorKey(call.Command, err), templateData)
+			if id := requestid.FromContext(ctx); id != "" {
+				templateData.RequestID = requestid.Short(id)
+				text += "\n" + setting.Templates.Render(i18n.KeyErrorRequestID, templateData)
+			}
+
+			if replyErr := setting.Platform.Reply(ctx, call.Message, &OutgoingReply{
+				Command: call.Command.Name(),
+				Text:    text,
+			}); replyErr != nil {
+				return errors.Wrap(replyErr, "Failed to Reply")
+			}
+			return nil
+		}
+	}
+}
+
+// errorKey エラーに対応する返信メッセージのキーを返す
+func errorKey(command Command, err error) i18n.Key {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return i18n.KeyErrorTimeout
+	case errors.Is(err, ErrRateLimited):
+		return i18n.KeyErrorRateLimited
+	case errors.Is(err, ErrCommandDisabled):
+		return i18n.KeyErrorCommandDisabled
+	default:
+		return command.ErrorKey(err)
+	}
+}
+
+// Timeout コマンドの処理に制限時間を設ける
+// 制限時間を超えた場合はアップロード中のファイルを含めて処理を中断し、ErrTimeoutを返す
+func Timeout(timeouts map[string]time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			timeout, ok := timeouts[call.Command.Name()]
+			if !ok || timeout <= 0 {
+				timeout = DefaultTimeout
+			}
+			timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			err := next(timeoutCtx, call)
+			if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+				return errors.Mark(err, ErrTimeout)
+			}
+			return err
+		}
+	}
+}
+
+// RateLimiterSetting RateLimiterの設定
+type RateLimiterSetting struct {
+	Limit  int              // 期間内に実行できる回数
+	Window time.Duration    // 回数を数える期間
+	Now    func() time.Time // 現在時刻を返す関数（nilの場合はtime.Now）
+}
+
+// RateLimiter 送信者ごとのコマンドの実行回数を制限する
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+	calls  map[string][]time.Time
+}
+
+// NewRateLimiter 新しいRateLimiterを作成する
+// settingがnilの場合はnilを返す（nilのRateLimiterは制限しない）
+// 回数または期間が0以下の場合はSetLimitで制限を設定するまで制限しない
+func NewRateLimiter(setting *RateLimiterSetting) *RateLimiter {
+	if setting == nil {
+		return nil
+	}
+	now := setting.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &RateLimiter{
+		limit:  setting.Limit,
+		window: setting.Window,
+		now:    now,
+		calls:  make(map[string][]time.Time),
+	}
+}
+
+// Allow 送信者のコマンドの実行を記録し、期間内の実行回数が上限以下の場合はtrueを返す
+func (l *RateLimiter) Allow(user string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit <= 0 || l.window <= 0 {
+		return true
+	}
+	now := l.now()
+	calls := l.calls[user]
+	// 期間外の実行を取り除く
+	for 0 < len(calls) && !calls[0].After(now.Add(-l.window)) {
+		calls = calls[1:]
+	}
+	if l.limit <= len(calls) {
+		l.calls[user] = calls
+		return false
+	}
+	l.calls[user] = append(calls, now)
+	return true
+}
+
+// SetLimit 期間内に実行できる回数と回数を数える期間を変更する（設定ファイルを読み込み直した場合）
+// これまでの実行の記録は残し、新しい期間で数え直す
+// 回数または期間が0以下の場合は制限しない
+// レシーバーがnilの場合は何もしない
+func (l *RateLimiter) SetLimit(limit int, window time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+	l.window = window
+}
+
+// RateLimit 送信者ごとのコマンドの実行回数を制限する
+// 上限に達した場合はコマンドを実行せずにErrRateLimitedを返す
+func RateLimit(limiter *RateLimiter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, call *Call) error {
+			if call.Message.UserID != "" && !limiter.Allow(call.Message.UserID) {
+				return errors.Wrapf(ErrRateLimited, "user %s", call.Message.UserID)
+			}
+			return next(ctx, call)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package bot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/metrics"
+	"hato-bot-go/lib/requestid"
+)
+
+// recordingMiddleware 呼び出しの前後とnextから返ったエラーを記録するミドルウェアを返す
+func recordingMiddleware(name string, record func(string)) bot.Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, call *bot.Call) error {
+			record(name + " before")
+			err := next(ctx, call)
+			switch {
+			case errors.Is(err, bot.ErrTimeout):
+				record(name + " after timeout")
+			case errors.Is(err, bot.ErrRateLimited):
+				record(name + " after rate limited")
+			default:
+				record(name + " after")
+			}
+			return err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name        string
+		middlewares []string
+		expected    []string
+	}{
+		{
+			name:        "ミドルウェアなし",
+			middlewares: nil,
+			expected:    []string{"handler"},
+		},
+		{
+			name:        "先頭のミドルウェアが最も外側",
+			middlewares: []string{"a", "b", "c"},
+			expected:    []string{"a before", "b before", "c before", "handler", "c after", "b after", "a after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var records []string
+			record := func(s string) { records = append(records, s) }
+
+			middlewares := make([]bot.Middleware, 0, len(tt.middlewares))
+			for _, name := range tt.middlewares {
+				middlewares = append(middlewares, recordingMiddleware(name, record))
+			}
+			handler := bot.Chain(func(_ context.Context, _ *bot.Call) error {
+				record("handler")
+				return nil
+			}, middlewares...)
+
+			if err := handler(t.Context(), &bot.Call{Command: &echoCommand{}, Message: &bot.IncomingMessage{}}); err != nil {
+				t.Fatalf("handler() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, records); diff != "" {
+				t.Errorf("records mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestEngineMiddlewareOrder Engineの標準のミドルウェアと追加のミドルウェアの順序を確認する
+func TestEngineMiddlewareOrder(t *testing.T) {
+	rateLimitedText := errorReplyText(i18n.KeyErrorRateLimited)
+	timeoutText := errorReplyText(i18n.KeyErrorTimeout)
+	errorText := errorReplyText(i18n.KeyErrorCommand)
+
+	tests := []struct {
+		name              string
+		messages          []*bot.IncomingMessage
+		command           *echoCommand
+		timeouts          map[string]time.Duration
+		expectedRecords   []string
+		expectedReactions []bot.Reaction
+		expectedReplies   []string
+	}{
+		{
+			name:              "追加のミドルウェアはコマンドの実行を包む",
+			messages:          []*bot.IncomingMessage{{ID: "1", Text: "echo hello", UserID: "u1"}},
+			command:           &echoCommand{},
+			expectedRecords:   []string{"custom before", "custom after"},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing, bot.ReactionSuccess},
+			expectedReplies:   []string{"echo hello alice"},
+		},
+		{
+			name: "許可されていないコマンドは追加のミドルウェアより前に無視する",
+			messages: []*bot.IncomingMessage{{ID: "1", Text: "echo hello", UserID: "u1", Allows: func(_ string) bool {
+				return false
+			}}},
+			command: &echoCommand{},
+		},
+		{
+			name: "実行回数の制限は追加のミドルウェアより前に判定して返信する",
+			messages: []*bot.IncomingMessage{
+				{ID: "1", Text: "echo hello", UserID: "u1"},
+				{ID: "2", Text: "echo hello", UserID: "u1"},
+			},
+			command:           &echoCommand{},
+			expectedRecords:   []string{"custom before", "custom after"},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing, bot.ReactionSuccess},
+			expectedReplies:   []string{"echo hello alice", rateLimitedText},
+		},
+		{
+			name: "送信者ごとに実行回数を数える",
+			messages: []*bot.IncomingMessage{
+				{ID: "1", Text: "echo hello", UserID: "u1"},
+				{ID: "2", Text: "echo hello", UserID: "u2"},
+			},
+			command:           &echoCommand{},
+			expectedRecords:   []string{"custom before", "custom after", "custom before", "custom after"},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing, bot.ReactionSuccess, bot.ReactionProcessing, bot.ReactionSuccess},
+			expectedReplies:   []string{"echo hello alice", "echo hello alice"},
+		},
+		{
+			name:              "制限時間は追加のミドルウェアの内側で判定する",
+			messages:          []*bot.IncomingMessage{{ID: "1", Text: "echo hello", UserID: "u1"}},
+			command:           &echoCommand{block: true},
+			timeouts:          map[string]time.Duration{"echo": 10 * time.Millisecond},
+			expectedRecords:   []string{"custom before", "custom after timeout"},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{timeoutText},
+		},
+		{
+			name:              "コマンドの失敗は追加のミドルウェアの外側で返信する",
+			messages:          []*bot.IncomingMessage{{ID: "1", Text: "echo hello", UserID: "u1"}},
+			command:           &echoCommand{err: errors.New("failed")},
+			expectedRecords:   []string{"custom before", "custom after"},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+			expectedReplies:   []string{errorText},
+		},
+		{
+			name:              "パニックは最も外側で回復する",
+			messages:          []*bot.IncomingMessage{{ID: "1", Text: "echo hello", UserID: "u1"}},
+			command:           &echoCommand{panics: true},
+			expectedRecords:   []string{"custom before"},
+			expectedReactions: []bot.Reaction{bot.ReactionProcessing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var records []string
+			platform := &recordingPlatform{}
+			engine := bot.NewEngine(&bot.EngineSetting{
+				Platform:    platform,
+				Commands:    []bot.Command{tt.command},
+				Timeouts:    tt.timeouts,
+				RateLimiter: bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: 1, Window: time.Hour}),
+				Middlewares: []bot.Middleware{recordingMiddleware("custom", func(s string) {
+					records = append(records, s)
+				})},
+			})
+
+			for _, message := range tt.messages {
+				if err := engine.Handle(requestid.NewContext(t.Context(), testRequestID), message); err != nil {
+					t.Fatalf("Handle() error = %v", err)
+				}
+			}
+
+			if diff := cmp.Diff(tt.expectedRecords, records); diff != "" {
+				t.Errorf("records mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedReactions, platform.reactions); diff != "" {
+				t.Errorf("reactions mismatch (-want +got):\n%s", diff)
+			}
+			var replies []string
+			for _, reply := range platform.replies {
+				replies = append(replies, reply.Text)
+			}
+			if diff := cmp.Diff(tt.expectedReplies, replies); diff != "" {
+				t.Errorf("replies mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected map[string]int64
+	}{
+		{
+			name:     "成功",
+			err:      nil,
+			expected: map[string]int64{"bot.echo.requests": 1},
+		},
+		{
+			name:     "失敗",
+			err:      errors.New("failed"),
+			expected: map[string]int64{"bot.echo.requests": 1, "bot.echo.errors": 1},
+		},
+		{
+			name:     "時間切れ",
+			err:      errors.Mark(errors.New("failed"), bot.ErrTimeout),
+			expected: map[string]int64{"bot.echo.requests": 1, "bot.echo.timeouts": 1},
+		},
+		{
+			name:     "実行回数の制限",
+			err:      errors.Wrap(bot.ErrRateLimited, "user u1"),
+			expected: map[string]int64{"bot.echo.requests": 1, "bot.echo.rate_limited": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			registry := metrics.NewRegistry()
+			handler := bot.Chain(func(_ context.Context, _ *bot.Call) error {
+				return tt.err
+			}, bot.Metrics(registry))
+
+			if err := handler(t.Context(), &bot.Call{Command: &echoCommand{}, Message: &bot.IncomingMessage{}}); !errors.Is(err, tt.err) {
+				t.Fatalf("handler() error = %v, want %v", err, tt.err)
+			}
+			if diff := cmp.Diff(tt.expected, registry.Snapshot()); diff != "" {
+				t.Errorf("metrics mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// placeCommand 引数を地名として履歴に記録するechoコマンド
+type placeCommand struct {
+	echoCommand
+}
+
+func (c *placeCommand) Place(text string) string {
+	return lib.ParseCommand(text, c.Name()).Args
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  bot.Command
+		message  *bot.IncomingMessage
+		err      error
+		expected *history.Stats
+	}{
+		{
+			name:    "成功",
+			command: &placeCommand{},
+			message: &bot.IncomingMessage{Text: "@hato echo 東京", UserID: "u1"},
+			err:     nil,
+			expected: &history.Stats{
+				Total:     1,
+				Users:     1,
+				Commands:  map[string]*history.CommandStats{"echo": {Count: 1, Success: 1}},
+				TopPlaces: []history.PlaceCount{{Place: "東京", Count: 1}},
+			},
+		},
+		{
+			name:    "地名をとらないコマンドの引数は記録しない",
+			command: &echoCommand{},
+			message: &bot.IncomingMessage{Text: "@hato echo 翻訳する文章", UserID: "u1"},
+			err:     nil,
+			expected: &history.Stats{
+				Total:     1,
+				Users:     1,
+				Commands:  map[string]*history.CommandStats{"echo": {Count: 1, Success: 1}},
+				TopPlaces: []history.PlaceCount{},
+			},
+		},
+		{
+			name:    "失敗",
+			command: &placeCommand{},
+			message: &bot.IncomingMessage{Text: "echo", UserID: "u1"},
+			err:     errors.New("failed"),
+			expected: &history.Stats{
+				Total:     1,
+				Users:     1,
+				Commands:  map[string]*history.CommandStats{"echo": {Count: 1, Errors: 1}},
+				TopPlaces: []history.PlaceCount{},
+			},
+		},
+		{
+			name:    "時間切れ",
+			command: &placeCommand{},
+			message: &bot.IncomingMessage{Text: "echo 大阪"},
+			err:     errors.Mark(errors.New("failed"), bot.ErrTimeout),
+			expected: &history.Stats{
+				Total:     1,
+				Commands:  map[string]*history.CommandStats{"echo": {Count: 1, Timeouts: 1}},
+				TopPlaces: []history.PlaceCount{{Place: "大阪", Count: 1}},
+			},
+		},
+		{
+			name:    "実行回数の制限",
+			command: &placeCommand{},
+			message: &bot.IncomingMessage{Text: "echo", UserID: "u1"},
+			err:     errors.Wrap(bot.ErrRateLimited, "user u1"),
+			expected: &history.Stats{
+				Total:     1,
+				Users:     1,
+				Commands:  map[string]*history.CommandStats{"echo": {Count: 1, RateLimited: 1}},
+				TopPlaces: []history.PlaceCount{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store, err := history.NewStore(&history.StoreSetting{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := bot.Chain(func(_ context.Context, _ *bot.Call) error {
+				return tt.err
+			}, bot.History(store, "test"))
+
+			if err := handler(t.Context(), &bot.Call{Command: tt.command, Message: tt.message}); !errors.Is(err, tt.err) {
+				t.Fatalf("handler() error = %v, want %v", err, tt.err)
+			}
+			// 時刻と処理時間は実行環境によって変わるため比較しない
+			stats := store.Stats()
+			stats.Since = nil
+			if diff := cmp.Diff(tt.expected, stats); diff != "" {
+				t.Errorf("stats mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHistoryNilStore(t *testing.T) {
+	t.Parallel()
+	handler := bot.Chain(func(_ context.Context, _ *bot.Call) error {
+		return nil
+	}, bot.History(nil, "test"))
+
+	if err := handler(t.Context(), &bot.Call{Command: &echoCommand{}, Message: &bot.IncomingMessage{Text: "echo"}}); err != nil {
+		t.Errorf("handler() error = %v", err)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		limiter  func(now func() time.Time) *bot.RateLimiter
+		calls    []time.Duration // baseからの経過時間
+		expected []bool
+	}{
+		{
+			name: "上限まで許可",
+			limiter: func(now func() time.Time) *bot.RateLimiter {
+				return bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: 2, Window: time.Minute, Now: now})
+			},
+			calls:    []time.Duration{0, time.Second, 2 * time.Second},
+			expected: []bool{true, true, false},
+		},
+		{
+			name: "期間が過ぎたら再び許可",
+			limiter: func(now func() time.Time) *bot.RateLimiter {
+				return bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: 1, Window: time.Minute, Now: now})
+			},
+			calls:    []time.Duration{0, 30 * time.Second, time.Minute},
+			expected: []bool{true, false, true},
+		},
+		{
+			name: "nilのRateLimiterは制限しない",
+			limiter: func(_ func() time.Time) *bot.RateLimiter {
+				return bot.NewRateLimiter(nil)
+			},
+			calls:    []time.Duration{0, 0, 0},
+			expected: []bool{true, true, true},
+		},
+		{
+			name: "回数が0の場合は制限しない",
+			limiter: func(now func() time.Time) *bot.RateLimiter {
+				return bot.NewRateLimiter(&bot.RateLimiterSetting{Limit: 0, Window: time.Minute, Now: now})
+			},
+			calls:    []time.Duration{0, 0, 0},
+			expected: []bool{true, true, true},
+		},
+		{
+			name: "SetLimitで変更した制限",
+			limiter: func(now func() time.Time) *bot.RateLimiter {
+				limiter := bot.NewRateLimiter(&bot.RateLimiterSetting{Now: now})
+				limiter.SetLimit(1, time.Minute)
+				return limiter
+			},
+			calls:    []time.Duration{0, time.Second},
+			expected: []bool{true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			now := base
+			limiter := tt.limiter(func() time.Time {
+				return now
+			})
+
+			results := make([]bool, 0, len(tt.calls))
+			for _, elapsed := range tt.calls {
+				now = base.Add(elapsed)
+				results = append(results, limiter.Allow("u1"))
+			}
+			if diff := cmp.Diff(tt.expected, results); diff != "" {
+				t.Errorf("Allow() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"io"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// RainCommand 1時間先までの降水強度のグラフと雨が降り始める時刻を返信するrainコマンド
+type RainCommand struct {
+	YahooAPIToken string          // ジオコーディング用Yahoo APIトークン（空の場合は埋め込みの地名の一覧だけで探す）
+	Client        httpclient.Doer // HTTPクライアント（nilの場合はameshパッケージの既定のクライアントとジオコーダ）
+	Clock         clock.Clock     // グラフのファイル名に使う時計（nilの場合はclock.Real）
+}
+
+// Name コマンド名
+func (c *RainCommand) Name() string {
+	return "rain"
+}
+
+// Match 本文がrainコマンドかを返す
+func (c *RainCommand) Match(text string) bool {
+	return amesh.ParseRainCommand(text).IsRain
+}
+
+// Place 本文から履歴に記録する地名を返す
+func (c *RainCommand) Place(text string) string {
+	return amesh.ParseRainCommand(text).Place
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *RainCommand) ErrorKey(err error) i18n.Key {
+	return amesh.RainCommandErrorKey(err)
+}
+
+// Execute 地名の地点の降水強度の予測を取得し、降水強度のグラフを添付した返信を作成する
+// 今降っているか、1時間以内に降り始めるか、降らないかで返信の文言を変える
+func (c *RainCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	// 位置を解析
+	location, err := c.parseLocation(ctx, amesh.ParseRainCommand(req.Message.Text).Place)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parseLocation")
+	}
+
+	rainfall, err := c.getRainfall(ctx, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to getRainfall")
+	}
+	chartPNG, err := rainfall.ChartPNG()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ChartPNG")
+	}
+
+	templateData := req.TemplateData
+	templateData.PlaceName = location.PlaceName
+	templateData.Lat = location.Lat
+	templateData.Lng = location.Lng
+	radarTime := rainfall.Samples[0].Time
+	textKey := i18n.KeyRainNone
+	if startTime, ok := rainfall.StartTime(); ok {
+		textKey = i18n.KeyRainStart
+		if startTime.Equal(radarTime) {
+			textKey = i18n.KeyRainNow
+		}
+		templateData.RainStart = (&amesh.AmeshMetadata{RadarTime: startTime}).RadarTimeText()
+	}
+	templateData.RadarTime = (&amesh.AmeshMetadata{RadarTime: radarTime}).RadarTimeText()
+	text := req.Templates.Render(textKey, templateData) + "\n" + req.Templates.Render(i18n.KeyAmeshRadarTime, templateData)
+
+	requestid.Logf(ctx, "Successfully created rain chart for %s", location.PlaceName)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    text,
+		Attachments: []*Attachment{{
+			Reader: io.NopCloser(chartPNG),
+			FileName: amesh.GenerateFileName(&amesh.GenerateFileNameParams{
+				Locations: []*amesh.Location{location},
+				DataTime:  radarTime,
+				Clock:     c.Clock,
+				Prefix:    c.Name(),
+			}),
+			Description: req.Templates.Render(i18n.KeyRainDescription, templateData),
+		}},
+	}, nil
+}
+
+// parseLocation 設定に合わせたクライアントで地名を解析する
+func (c *RainCommand) parseLocation(ctx context.Context, place string) (*amesh.Location, error) {
+	if c.Client == nil {
+		location, err := amesh.ParseLocation(ctx, place, c.YahooAPIToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.ParseLocation")
+		}
+		return location, nil
+	}
+	location, err := amesh.ParseLocationWithClient(ctx, &amesh.ParseLocationParams{
+		Client:         c.Client,
+		GeocodeRequest: amesh.GeocodeRequest{Place: place, APIKey: c.YahooAPIToken},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.ParseLocationWithClient")
+	}
+	return location, nil
+}
+
+// getRainfall 設定に合わせたクライアントで降水強度の予測を取得する
+func (c *RainCommand) getRainfall(ctx context.Context, location *amesh.Location) (*amesh.Rainfall, error) {
+	if c.Client == nil {
+		rainfall, err := amesh.GetRainfall(ctx, location)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.GetRainfall")
+		}
+		return rainfall, nil
+	}
+	rainfall, err := amesh.GetRainfallWithClient(ctx, &amesh.GetRainfallWithClientParams{
+		Client:   c.Client,
+		Location: location,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.GetRainfallWithClient")
+	}
+	return rainfall, nil
+}
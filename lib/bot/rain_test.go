@@ -0,0 +1,78 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestRainCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato rain 東京", expected: true},
+		{name: "地名なし", text: "rain", expected: true},
+		{name: "別のコマンド", text: "amesh 東京", expected: false},
+		{name: "コマンドでない", text: "rainbow 東京", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.RainCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRainCommandExecute 降水強度のグラフを添付した返信を作成することを確認する
+func TestRainCommandExecute(t *testing.T) {
+	t.Parallel()
+	server := ameshtest.NewServer(t, &ameshtest.Scenario{Forecast: true})
+	command := &bot.RainCommand{
+		Client: server.Client(),
+		Clock:  clocktest.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	reply, err := command.Execute(t.Context(), &bot.Request{
+		Message:      &bot.IncomingMessage{Text: "rain 35.6812,139.7671"},
+		TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	attachment := reply.Attachments[0]
+	defer func() { _ = attachment.Reader.Close() }()
+
+	// テストサーバーの雨雲レーダーは全域で降水があるため、今降っている旨を返信する
+	if expected := "☔ It is raining now in 35.68,139.77 (35.6812, 139.7671)"; !strings.HasPrefix(reply.Text, expected) {
+		t.Errorf("Text = %q, want prefix %q", reply.Text, expected)
+	}
+	if expected := "rain_35.68,139.77_"; !strings.HasPrefix(attachment.FileName, expected) {
+		t.Errorf("FileName = %q, want prefix %q", attachment.FileName, expected)
+	}
+	if attachment.Description == "" {
+		t.Error("Description is empty")
+	}
+}
+
+// TestRainCommandExecuteInvalid 外部APIにアクセスする前に失敗する場合をテストする
+func TestRainCommandExecuteInvalid(t *testing.T) {
+	t.Parallel()
+	command := &bot.RainCommand{}
+	if _, err := command.Execute(t.Context(), &bot.Request{Message: &bot.IncomingMessage{Text: "rain 東京"}}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("Execute() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// Reloader 設定ファイルを読み込み直して再起動せずに反映する処理
+type Reloader interface {
+	// Reload 設定ファイルを読み込み直す（失敗した場合は前の設定のまま動く）
+	Reload() error
+}
+
+// ReloadCommand 設定ファイルを読み込み直して結果を管理者に返信するadmin reloadコマンド
+type ReloadCommand struct {
+	Admins   []string // admin reloadコマンドを使える送信者のID
+	Reloader Reloader // 設定ファイルを読み込み直す処理
+}
+
+// Name コマンド名
+func (c *ReloadCommand) Name() string {
+	return "reload"
+}
+
+// Match 本文がadmin reloadコマンドかを返す
+func (c *ReloadCommand) Match(text string) bool {
+	result := lib.ParseCommand(text, "admin")
+	args := strings.Fields(result.Args)
+	return result.Matched && 0 < len(args) && args[0] == c.Name()
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *ReloadCommand) ErrorKey(err error) i18n.Key {
+	if errors.Is(err, ErrNotAdmin) {
+		return i18n.KeyErrorNotAdmin
+	}
+	return i18n.KeyErrorReloadCommand
+}
+
+// Execute 管理者からのメッセージであれば設定ファイルを読み込み直す
+func (c *ReloadCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+	if req.Message.UserID == "" || !slices.Contains(c.Admins, req.Message.UserID) {
+		return nil, errors.Wrapf(ErrNotAdmin, "user: %s", req.Message.UserID)
+	}
+
+	if err := c.Reloader.Reload(); err != nil {
+		return nil, errors.Wrap(err, "Failed to Reload")
+	}
+
+	requestid.Logf(ctx, "Config reloaded by %s", req.Message.UserID)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyReloadResult, req.TemplateData),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package bot_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/i18n"
+)
+
+// countingReloader 読み込み直した回数を数えるReloader
+type countingReloader struct {
+	calls int
+	err   error
+}
+
+func (r *countingReloader) Reload() error {
+	r.calls++
+	return r.err
+}
+
+func TestReloadCommand(t *testing.T) {
+	errReload := errors.New("invalid config")
+	tests := []struct {
+		name          string
+		userID        string
+		err           error
+		expectedCalls int
+		expectedKey   i18n.Key
+		expectedError error
+	}{
+		{
+			name:          "管理者は読み込み直せる",
+			userID:        "admin",
+			expectedCalls: 1,
+		},
+		{
+			name:          "読み込み直しの失敗",
+			userID:        "admin",
+			err:           errReload,
+			expectedCalls: 1,
+			expectedKey:   i18n.KeyErrorReloadCommand,
+			expectedError: errReload,
+		},
+		{
+			name:          "管理者以外",
+			userID:        "user",
+			expectedKey:   i18n.KeyErrorNotAdmin,
+			expectedError: bot.ErrNotAdmin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reloader := &countingReloader{err: tt.err}
+			command := &bot.ReloadCommand{Admins: []string{"admin"}, Reloader: reloader}
+			if !command.Match("@hato admin reload") {
+				t.Fatal("Match() = false, want true")
+			}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato admin reload", UserID: tt.userID},
+				TemplateData: &i18n.TemplateData{Locale: i18n.DefaultLocale},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if reloader.calls != tt.expectedCalls {
+				t.Errorf("Reload() calls = %d, want %d", reloader.calls, tt.expectedCalls)
+			}
+			if tt.expectedError != nil {
+				if key := command.ErrorKey(err); key != tt.expectedKey {
+					t.Errorf("ErrorKey() = %s, want %s", key, tt.expectedKey)
+				}
+				return
+			}
+			if expected := "🔄 設定ファイルを読み込み直したっぽ"; reply.Text != expected {
+				t.Errorf("Text = %q, want %q", reply.Text, expected)
+			}
+		})
+	}
+}
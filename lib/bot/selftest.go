@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/mfm"
+	"hato-bot-go/lib/requestid"
+)
+
+// selfTestUpload アップロードの手順の名前
+const selfTestUpload = "upload"
+
+// Uploader 自己診断でファイルのアップロードを試せるプラットフォーム
+// Platformが実装している場合、admin selftestコマンドはアップロードの手順も実行する
+type Uploader interface {
+	// TestUpload ファイルをアップロードし、確認できたら削除する
+	TestUpload(ctx context.Context, reader io.Reader, fileName string) error
+}
+
+// SelfTestCommand 外部サービスを実際に呼び出して手順ごとの成否を管理者に返信するadmin selftestコマンド
+type SelfTestCommand struct {
+	Admins        []string        // admin selftestコマンドを使える送信者のID
+	YahooAPIToken string          // ジオコーディング用Yahoo APIトークン
+	Client        httpclient.Doer // HTTPクライアント（nilの場合はameshコマンドと同じクライアントとジオコーダ）
+	Geocoder      amesh.Geocoder  // 地名を探すジオコーダ（Clientを指定した場合のみ使う）
+	Uploader      Uploader        // アップロードを試すプラットフォーム（nilの場合はアップロードの手順を省く）
+}
+
+// Name コマンド名
+func (c *SelfTestCommand) Name() string {
+	return "selftest"
+}
+
+// Match 本文がadmin selftestコマンドかを返す
+func (c *SelfTestCommand) Match(text string) bool {
+	result := lib.ParseCommand(text, "admin")
+	args := strings.Fields(result.Args)
+	return result.Matched && 0 < len(args) && args[0] == c.Name()
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *SelfTestCommand) ErrorKey(err error) i18n.Key {
+	if errors.Is(err, ErrNotAdmin) {
+		return i18n.KeyErrorNotAdmin
+	}
+	return i18n.KeyErrorSelfTestCommand
+}
+
+// Execute 管理者からのメッセージであれば自己診断を行い、手順ごとの成否を返信する
+// 手順の失敗はコマンドのエラーにせず、返信に失敗した手順とエラーを含める
+func (c *SelfTestCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+	if req.Message.UserID == "" || !slices.Contains(c.Admins, req.Message.UserID) {
+		return nil, errors.Wrapf(ErrNotAdmin, "user: %s", req.Message.UserID)
+	}
+
+	result, err := c.selfTest(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to selfTest")
+	}
+	if c.Uploader != nil {
+		start := time.Now()
+		step := amesh.SelfTestStep{Name: selfTestUpload, Detail: "uploaded and deleted"}
+		if result.Image == nil {
+			step.Err = errors.New("no image to upload")
+		} else if err := c.Uploader.TestUpload(ctx, bytes.NewReader(result.Image), "selftest.png"); err != nil {
+			step.Err = errors.Wrap(err, "Failed to TestUpload")
+		}
+		step.Elapsed = time.Since(start)
+		result.Steps = append(result.Steps, step)
+	}
+
+	templateData := req.TemplateData
+	templateData.SelfTestSteps = mfm.NewBuilder(req.MFM).CodeBlock("", formatSelfTestSteps(result.Steps)).String()
+
+	requestid.Logf(ctx, "Self-test finished (failed: %v)", result.Failed())
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeySelfTestResult, templateData),
+	}, nil
+}
+
+// selfTest 設定に合わせたクライアントで自己診断を行う
+func (c *SelfTestCommand) selfTest(ctx context.Context) (*amesh.SelfTestResult, error) {
+	if c.Client == nil {
+		result, err := amesh.RunSelfTest(ctx, c.YahooAPIToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to amesh.RunSelfTest")
+		}
+		return result, nil
+	}
+	result, err := amesh.SelfTest(ctx, &amesh.SelfTestParams{
+		Client:   c.Client,
+		Geocoder: c.Geocoder,
+		APIKey:   c.YahooAPIToken,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.SelfTest")
+	}
+	return result, nil
+}
+
+// formatSelfTestSteps 手順ごとの成否を1行ずつ連結する
+func formatSelfTestSteps(steps []amesh.SelfTestStep) string {
+	lines := make([]string, 0, len(steps))
+	for _, step := range steps {
+		elapsed := step.Elapsed.Round(time.Millisecond)
+		if step.Err != nil {
+			lines = append(lines, fmt.Sprintf("❌ %s: %v (%s)", step.Name, step.Err, elapsed))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("✅ %s: %s (%s)", step.Name, step.Detail, elapsed))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,220 @@
+package bot_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/amesh/ameshtest"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+// recordingUploader アップロードされたファイル名を記録するUploader
+type recordingUploader struct {
+	fileNames []string
+	err       error
+}
+
+func (u *recordingUploader) TestUpload(_ context.Context, reader io.Reader, fileName string) error {
+	if _, err := io.ReadAll(reader); err != nil {
+		return err
+	}
+	u.fileNames = append(u.fileNames, fileName)
+	return u.err
+}
+
+// uploadingPlatform アップロードにも対応するプラットフォーム
+type uploadingPlatform struct {
+	recordingPlatform
+	recordingUploader
+}
+
+// fixedGeocoder 常に同じ位置を返すジオコーダ
+type fixedGeocoder struct{}
+
+func (fixedGeocoder) Geocode(_ context.Context, _ string) (*amesh.Location, error) {
+	return &amesh.Location{Lat: 35.68, Lng: 139.76, PlaceName: "東京都"}, nil
+}
+
+// newSelfTestServer 自己診断の外部サービスを模した偽のサーバーを起動する
+func newSelfTestServer(t *testing.T) *ameshtest.Server {
+	t.Helper()
+	return ameshtest.NewServer(t, &ameshtest.Scenario{BaseTime: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)})
+}
+
+func TestSelfTestCommand(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        string
+		uploader      *recordingUploader
+		mfm           bool
+		expectedLines []string
+		expectedError error
+	}{
+		{
+			name:     "全ての手順の成否を返信",
+			userID:   "admin",
+			uploader: &recordingUploader{},
+			expectedLines: []string{
+				"✅ geocode: 東京 -> 東京都",
+				"✅ targettimes: radar 2026-01-01 09:00 JST",
+				"✅ tile: 10/",
+				"✅ render: 256x256",
+				"✅ upload: uploaded and deleted",
+			},
+		},
+		{
+			name:     "アップロードの失敗を返信",
+			userID:   "admin",
+			uploader: &recordingUploader{err: httpclient.ErrHTTPRequestError},
+			expectedLines: []string{
+				"✅ render: 256x256",
+				"❌ upload: Failed to TestUpload",
+			},
+		},
+		{
+			name:   "アップロードに対応しないプラットフォーム",
+			userID: "admin",
+			expectedLines: []string{
+				"✅ render: 256x256",
+			},
+		},
+		{
+			name:   "MFMを使えるプラットフォームではコードブロックにする",
+			userID: "admin",
+			mfm:    true,
+			expectedLines: []string{
+				"```",
+				"✅ render: 256x256",
+			},
+		},
+		{
+			name:          "管理者以外",
+			userID:        "user",
+			expectedError: bot.ErrNotAdmin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.SelfTestCommand{
+				Admins:   []string{"admin"},
+				Client:   newSelfTestServer(t).Client(),
+				Geocoder: fixedGeocoder{},
+			}
+			if tt.uploader != nil {
+				command.Uploader = tt.uploader
+			}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato admin selftest", UserID: tt.userID},
+				TemplateData: &i18n.TemplateData{Locale: i18n.DefaultLocale},
+				MFM:          tt.mfm,
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				if key := command.ErrorKey(err); key != i18n.KeyErrorNotAdmin {
+					t.Errorf("ErrorKey() = %s, want %s", key, i18n.KeyErrorNotAdmin)
+				}
+				return
+			}
+
+			lines := strings.Split(reply.Text, "\n")
+			if lines[0] != "🩺 自己診断の結果だっぽ" {
+				t.Errorf("first line = %q", lines[0])
+			}
+			for _, expected := range tt.expectedLines {
+				found := false
+				for _, line := range lines {
+					found = found || strings.HasPrefix(line, expected)
+				}
+				if !found {
+					t.Errorf("reply has no line starting with %q:\n%s", expected, reply.Text)
+				}
+			}
+			if !tt.mfm && strings.Contains(reply.Text, "```") {
+				t.Errorf("reply has MFM:\n%s", reply.Text)
+			}
+			if tt.uploader == nil && strings.Contains(reply.Text, "upload") {
+				t.Errorf("reply has an upload step:\n%s", reply.Text)
+			}
+			if tt.uploader != nil && len(tt.uploader.fileNames) != 1 {
+				t.Errorf("uploads = %v, want 1", tt.uploader.fileNames)
+			}
+		})
+	}
+}
+
+func TestSelfTestCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "admin selftest", text: "@hato admin selftest", expected: true},
+		{name: "ハッシュタグ", text: "#admin selftest", expected: true},
+		{name: "adminのみ", text: "@hato admin", expected: false},
+		{name: "別のサブコマンド", text: "@hato admin stats", expected: false},
+		{name: "selftestのみ", text: "@hato selftest", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := (&bot.SelfTestCommand{}).Match(tt.text); result != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEngineSelfTestCommand(t *testing.T) {
+	tests := []struct {
+		name           string
+		setting        *bot.EngineSetting
+		expected       bool
+		expectUploader bool
+	}{
+		{
+			name:     "管理者がある場合はadmin selftestコマンドを追加",
+			setting:  &bot.EngineSetting{Platform: &recordingPlatform{}, Admins: []string{"admin"}},
+			expected: true,
+		},
+		{
+			name:           "アップロードに対応するプラットフォーム",
+			setting:        &bot.EngineSetting{Platform: &uploadingPlatform{}, Admins: []string{"admin"}},
+			expected:       true,
+			expectUploader: true,
+		},
+		{
+			name:    "管理者がない場合は追加しない",
+			setting: &bot.EngineSetting{Platform: &recordingPlatform{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := bot.NewEngine(tt.setting).Command("@hato admin selftest")
+			if (command != nil) != tt.expected {
+				t.Fatalf("Command(admin selftest) found = %v, want %v", command != nil, tt.expected)
+			}
+			if command == nil {
+				return
+			}
+			if uploader := command.(*bot.SelfTestCommand).Uploader; (uploader != nil) != tt.expectUploader {
+				t.Errorf("Uploader = %v, expectUploader = %v", uploader, tt.expectUploader)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/metrics"
+)
+
+// ErrOverloaded 処理を待つメッセージが上限に達したため処理しなかったことを表すエラー
+var ErrOverloaded = errors.New("overloaded")
+
+// Shedderの設定を省略した場合の既定値
+const (
+	DefaultShedderMaxDepth = 50 // 処理を待つメッセージの上限
+	DefaultShedderWorkers  = 1  // 同時に処理するメッセージの数
+)
+
+// ShedderSetting Shedderの設定
+type ShedderSetting struct {
+	MaxDepth int               // 処理を待つメッセージの上限（0以下の場合はDefaultShedderMaxDepth、超えた分は処理しない）
+	Workers  int               // 同時に処理するメッセージの数（0以下の場合はDefaultShedderWorkers）
+	Metrics  *metrics.Registry // 処理しなかった数と待っている数の記録先（nilの場合はmetrics.Default）
+}
+
+// Shedder 受信したメッセージを上限のあるキューに入れて処理する
+// メンションが殺到してキューが上限に達した場合は新しいメッセージを処理せずに捨て、
+// 受信したメッセージを溜め続けてメモリを使い果たさないようにする
+// nilのShedderは常にその場で処理する
+type Shedder struct {
+	setting ShedderSetting
+	queue   chan func(ctx context.Context)
+	shed    *metrics.Counter
+}
+
+// NewShedder 新しいShedderを作成する
+// 処理しなかった数をbot.shed.total、処理を待っている数をbot.shed.depthに記録する
+func NewShedder(setting *ShedderSetting) *Shedder {
+	s := &Shedder{}
+	if setting != nil {
+		s.setting = *setting
+	}
+	if s.setting.MaxDepth <= 0 {
+		s.setting.MaxDepth = DefaultShedderMaxDepth
+	}
+	if s.setting.Workers <= 0 {
+		s.setting.Workers = DefaultShedderWorkers
+	}
+	if s.setting.Metrics == nil {
+		s.setting.Metrics = metrics.Default
+	}
+	s.queue = make(chan func(ctx context.Context), s.setting.MaxDepth)
+	s.shed = s.setting.Metrics.Counter("bot.shed.total")
+	s.setting.Metrics.GaugeFunc("bot.shed.depth", func() int64 { return int64(s.Depth()) })
+	return s
+}
+
+// Submit handleを処理を待つキューに入れる
+// キューが上限に達している場合は入れずにfalseを返す
+func (s *Shedder) Submit(ctx context.Context, handle func(ctx context.Context)) bool {
+	if s == nil {
+		handle(ctx)
+		return true
+	}
+	select {
+	case s.queue <- handle:
+		return true
+	default:
+		s.shed.Inc()
+		return false
+	}
+}
+
+// Depth 処理を待っているメッセージの数を返す
+func (s *Shedder) Depth() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.queue)
+}
+
+// Run Workersの数だけキューのメッセージを受け取った順に処理する
+// ctxが終了するまで戻らず、処理を待っているメッセージは処理しない
+func (s *Shedder) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for range s.setting.Workers {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case handle := <-s.queue:
+					handle(ctx)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,67 @@
+package bot_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/metrics"
+)
+
+// TestShedderSubmit 処理を待つメッセージが上限に達した場合は処理せずに数えることをテストする
+func TestShedderSubmit(t *testing.T) {
+	t.Parallel()
+	registry := metrics.NewRegistry()
+	shedder := bot.NewShedder(&bot.ShedderSetting{MaxDepth: 2, Metrics: registry})
+
+	var handled atomic.Int32
+	done := make(chan struct{}, 3)
+	handle := func(_ context.Context) {
+		handled.Add(1)
+		done <- struct{}{}
+	}
+
+	// 処理を始める前に上限を超えて入れる
+	var accepted []bool
+	for range 3 {
+		accepted = append(accepted, shedder.Submit(t.Context(), handle))
+	}
+	if diff := cmp.Diff([]bool{true, true, false}, accepted); diff != "" {
+		t.Errorf("Submit() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]int64{"bot.shed.total": 1, "bot.shed.depth": 2}, registry.Snapshot()); diff != "" {
+		t.Errorf("Snapshot() mismatch (-want +got):\n%s", diff)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	stopped := make(chan struct{})
+	go func() {
+		shedder.Run(ctx)
+		close(stopped)
+	}()
+	<-done
+	<-done
+	cancel()
+	<-stopped
+
+	if got := handled.Load(); got != 2 {
+		t.Errorf("handled = %d, want 2", got)
+	}
+	if got := shedder.Depth(); got != 0 {
+		t.Errorf("Depth() = %d, want 0", got)
+	}
+}
+
+// TestShedderNil nilのShedderはその場で処理することをテストする
+func TestShedderNil(t *testing.T) {
+	t.Parallel()
+	var shedder *bot.Shedder
+	handled := false
+	if !shedder.Submit(t.Context(), func(_ context.Context) { handled = true }) || !handled {
+		t.Errorf("Submit() did not handle the message")
+	}
+	shedder.Run(t.Context())
+}
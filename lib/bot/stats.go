@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// ErrNotAdmin 管理者以外が管理者向けのコマンドを使ったことを表すエラー
+var ErrNotAdmin = errors.New("not an admin")
+
+// statsEmpty 集計の値がない場合に返信に表示する文字列
+const statsEmpty = "-"
+
+// StatsCommand コマンドの処理の履歴の集計を管理者に返信するstatsコマンド
+type StatsCommand struct {
+	Store  *history.Store // 集計するコマンドの処理の履歴
+	Admins []string       // statsコマンドを使える送信者のID
+}
+
+// Name コマンド名
+func (c *StatsCommand) Name() string {
+	return "stats"
+}
+
+// Match 本文がstatsコマンドかを返す
+func (c *StatsCommand) Match(text string) bool {
+	return lib.ParseCommand(text, c.Name()).Matched
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *StatsCommand) ErrorKey(err error) i18n.Key {
+	if errors.Is(err, ErrNotAdmin) {
+		return i18n.KeyErrorNotAdmin
+	}
+	return i18n.KeyErrorStatsCommand
+}
+
+// Execute 管理者からのメッセージであれば履歴を集計し、返信を作成する
+func (c *StatsCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+	if req.Message.UserID == "" || !slices.Contains(c.Admins, req.Message.UserID) {
+		return nil, errors.Wrapf(ErrNotAdmin, "user: %s", req.Message.UserID)
+	}
+
+	stats := c.Store.Stats()
+
+	templateData := req.TemplateData
+	templateData.StatsSince = statsEmpty
+	if stats.Since != nil {
+		templateData.StatsSince = stats.Since.Format(time.DateTime)
+	}
+	templateData.StatsTotal = strconv.Itoa(stats.Total)
+	templateData.StatsUsers = strconv.Itoa(stats.Users)
+	templateData.StatsCommands = formatCommandStats(stats.Commands)
+	templateData.StatsPlaces = formatPlaceCounts(stats.TopPlaces)
+
+	requestid.Logf(ctx, "Successfully summarized %d history records", stats.Total)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyStatsSuccess, templateData),
+	}, nil
+}
+
+// formatCommandStats コマンドごとの実行回数を多い順に連結する
+func formatCommandStats(commands map[string]*history.CommandStats) string {
+	names := slices.SortedFunc(maps.Keys(commands), func(a, b string) int {
+		return cmp.Or(cmp.Compare(commands[b].Count, commands[a].Count), cmp.Compare(a, b))
+	})
+	if len(names) == 0 {
+		return statsEmpty
+	}
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, commands[name].Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatPlaceCounts よく使われる地名と回数を連結する
+func formatPlaceCounts(places []history.PlaceCount) string {
+	if len(places) == 0 {
+		return statsEmpty
+	}
+
+	parts := make([]string, 0, len(places))
+	for _, place := range places {
+		parts = append(parts, fmt.Sprintf("%s %d", place.Place, place.Count))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,119 @@
+package bot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestStatsCommand(t *testing.T) {
+	since := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		records       []history.Record
+		userID        string
+		expected      string
+		expectedError error
+	}{
+		{
+			name: "コマンドと地名を多い順に返信",
+			records: []history.Record{
+				{At: since, User: "a", Command: "amesh", Place: "東京", Outcome: history.OutcomeSuccess},
+				{At: since.Add(time.Minute), User: "b", Command: "amesh", Place: "東京", Outcome: history.OutcomeSuccess},
+				{At: since.Add(2 * time.Minute), User: "a", Command: "amedas", Place: "大阪", Outcome: history.OutcomeError},
+				{At: since.Add(3 * time.Minute), User: "a", Command: "wiki", Outcome: history.OutcomeSuccess},
+			},
+			userID:   "admin",
+			expected: "📊 2026-01-31 12:00:00からのコマンドの記録は4件（2人）だっぽ\nコマンド: amesh 2, amedas 1, wiki 1\nよく使われる地名: 東京 2, 大阪 1",
+		},
+		{
+			name:     "記録なし",
+			userID:   "admin",
+			expected: "📊 -からのコマンドの記録は0件（0人）だっぽ\nコマンド: -\nよく使われる地名: -",
+		},
+		{
+			name:          "管理者以外",
+			userID:        "user",
+			expectedError: bot.ErrNotAdmin,
+		},
+		{
+			name:          "送信者のIDがない",
+			userID:        "",
+			expectedError: bot.ErrNotAdmin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store, err := history.NewStore(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.records {
+				if err := store.Add(&tt.records[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			command := &bot.StatsCommand{Store: store, Admins: []string{"admin"}}
+
+			reply, err := command.Execute(t.Context(), &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato stats", UserID: tt.userID},
+				TemplateData: &i18n.TemplateData{Locale: i18n.DefaultLocale},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				if key := command.ErrorKey(err); key != i18n.KeyErrorNotAdmin {
+					t.Errorf("ErrorKey() = %s, want %s", key, i18n.KeyErrorNotAdmin)
+				}
+				return
+			}
+			if reply.Text != tt.expected {
+				t.Errorf("Execute() text = %q, want %q", reply.Text, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEngineStatsCommand(t *testing.T) {
+	store, err := history.NewStore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		setting  *bot.EngineSetting
+		expected bool
+	}{
+		{
+			name:     "履歴と管理者がある場合はstatsコマンドを追加",
+			setting:  &bot.EngineSetting{Platform: &recordingPlatform{}, History: store, Admins: []string{"admin"}},
+			expected: true,
+		},
+		{
+			name:    "管理者がない場合は追加しない",
+			setting: &bot.EngineSetting{Platform: &recordingPlatform{}, History: store},
+		},
+		{
+			name:    "履歴がない場合は追加しない",
+			setting: &bot.EngineSetting{Platform: &recordingPlatform{}, Admins: []string{"admin"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			engine := bot.NewEngine(tt.setting)
+			if got := engine.Command("@hato stats") != nil; got != tt.expected {
+				t.Errorf("Command(stats) found = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+	"hato-bot-go/lib/translate"
+)
+
+// unknownLanguage 原文の言語を判定できなかった場合に返信に表示する言語名
+const unknownLanguage = "?"
+
+// TranslateCommand 文章または返信先の投稿を翻訳して返信するtranslateコマンド
+type TranslateCommand struct {
+	Translator translate.Translator // 翻訳サービス
+}
+
+// Name コマンド名
+func (c *TranslateCommand) Name() string {
+	return "translate"
+}
+
+// Match 本文がtranslateコマンドかを返す
+func (c *TranslateCommand) Match(text string) bool {
+	return translate.ParseTranslateCommand(text).IsTranslate
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *TranslateCommand) ErrorKey(err error) i18n.Key {
+	return translate.CommandErrorKey(err)
+}
+
+// Execute 送信者の言語に翻訳し、返信を作成する
+// コマンドの後に文章がない場合は返信先の投稿を翻訳する
+func (c *TranslateCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil || c.Translator == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	text := translate.ParseTranslateCommand(req.Message.Text).Text
+	if text == "" {
+		text = req.Message.ReplyText
+	}
+
+	target := req.TemplateData.Locale
+	if target == "" {
+		target = i18n.DefaultLocale
+	}
+
+	result, err := translate.Auto(ctx, &translate.AutoParams{
+		Translator: c.Translator,
+		Text:       text,
+		Target:     string(target),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to translate.Auto")
+	}
+
+	templateData := req.TemplateData
+	templateData.Translation = result.Text
+	templateData.SourceLanguage = result.Source
+	if templateData.SourceLanguage == "" {
+		templateData.SourceLanguage = unknownLanguage
+	}
+	templateData.TargetLanguage = result.Target
+
+	requestid.Logf(ctx, "Successfully translated %s to %s", templateData.SourceLanguage, result.Target)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyTranslateSuccess, templateData),
+	}, nil
+}
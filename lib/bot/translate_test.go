@@ -0,0 +1,153 @@
+package bot_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/translate"
+)
+
+// stubTranslator 原文の言語を固定で返し、受け取った文章をそのまま翻訳結果にする翻訳サービス
+type stubTranslator struct {
+	source string
+}
+
+func (s *stubTranslator) Translate(_ context.Context, req *translate.Request) (*translate.Result, error) {
+	return &translate.Result{Text: "translated: " + req.Text, Source: s.source, Target: req.Target}, nil
+}
+
+func TestTranslateCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato translate hello", expected: true},
+		{name: "文章なし", text: "@hato translate", expected: true},
+		{name: "別のコマンド", text: "amedas 東京", expected: false},
+		{name: "コマンドでない", text: "こんにちは", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.TranslateCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTranslateCommandExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		translator    translate.Translator
+		req           *bot.Request
+		expected      string
+		expectedError error
+	}{
+		{
+			name:       "コマンドの後の文章を翻訳する",
+			translator: &stubTranslator{source: "en"},
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato translate hello", ReplyText: "ignored"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleJa},
+			},
+			expected: i18n.Message(i18n.LocaleJa, i18n.KeyTranslateSuccess, "translated: hello", "en", "ja"),
+		},
+		{
+			name:       "文章がない場合は返信先を翻訳する",
+			translator: &stubTranslator{source: "fr"},
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato translate", ReplyText: "bonjour"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+			},
+			expected: i18n.Message(i18n.LocaleEn, i18n.KeyTranslateSuccess, "translated: bonjour", "fr", "en"),
+		},
+		{
+			name:       "原文の言語を判定できない場合",
+			translator: &stubTranslator{},
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "translate hello"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expected: i18n.Message(i18n.LocaleJa, i18n.KeyTranslateSuccess, "translated: hello", "?", "ja"),
+		},
+		{
+			name:       "翻訳する文章がない",
+			translator: &stubTranslator{source: "en"},
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato translate"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedError: translate.ErrNoText,
+		},
+		{
+			name:          "nilリクエスト",
+			translator:    &stubTranslator{},
+			req:           nil,
+			expectedError: lib.ErrParamsNil,
+		},
+		{
+			name:       "翻訳サービスなし",
+			translator: nil,
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "translate hello"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedError: lib.ErrParamsNil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.TranslateCommand{Translator: tt.translator}
+			reply, err := command.Execute(t.Context(), tt.req)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if reply.Command != "translate" {
+				t.Errorf("reply.Command = %q, want translate", reply.Command)
+			}
+			if reply.Text != tt.expected {
+				t.Errorf("reply.Text = %q, want %q", reply.Text, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultCommandsTranslate(t *testing.T) {
+	tests := []struct {
+		name       string
+		translator translate.Translator
+		expected   bool
+	}{
+		{name: "翻訳サービスなし", translator: nil, expected: false},
+		{name: "翻訳サービスあり", translator: &stubTranslator{}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			found := false
+			for _, command := range bot.DefaultCommands("token", tt.translator) {
+				if command.Name() == "translate" {
+					found = true
+				}
+			}
+			if found != tt.expected {
+				t.Errorf("translate command included = %v, want %v", found, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+	"hato-bot-go/lib/wikipedia"
+)
+
+// WikipediaCommand 語句をWikipediaで調べて要約とリンクを返信するwikiコマンド
+type WikipediaCommand struct {
+	// Lookup 語句を調べる関数（nilの場合はwikipedia.Lookup）
+	Lookup func(ctx context.Context, term string) (*wikipedia.Article, error)
+}
+
+// Name コマンド名
+func (c *WikipediaCommand) Name() string {
+	return "wiki"
+}
+
+// Match 本文がwikiコマンドかを返す
+func (c *WikipediaCommand) Match(text string) bool {
+	return wikipedia.ParseWikipediaCommand(text).IsWikipedia
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *WikipediaCommand) ErrorKey(err error) i18n.Key {
+	return wikipedia.CommandErrorKey(err)
+}
+
+// Execute 語句をWikipediaで調べ、返信を作成する
+// 曖昧さ回避のページの場合は候補の記事名を返信する
+func (c *WikipediaCommand) Execute(ctx context.Context, req *Request) (*OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	lookup := c.Lookup
+	if lookup == nil {
+		lookup = wikipedia.Lookup
+	}
+	article, err := lookup(ctx, wikipedia.ParseWikipediaCommand(req.Message.Text).Term)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to lookup")
+	}
+
+	templateData := req.TemplateData
+	article.FillTemplateData(templateData)
+
+	key := i18n.KeyWikipediaSuccess
+	if article.Disambiguation {
+		key = i18n.KeyWikipediaDisambiguation
+	}
+
+	requestid.Logf(ctx, "Successfully looked up wikipedia article %s", article.Title)
+	return &OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(key, templateData),
+	}, nil
+}
@@ -0,0 +1,119 @@
+package bot_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/wikipedia"
+)
+
+func TestWikipediaCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "wiki", text: "@hato wiki 鳩", expected: true},
+		{name: "what is", text: "@hato what is 鳩?", expected: true},
+		{name: "語句なし", text: "@hato wiki", expected: true},
+		{name: "isが続かないwhat", text: "@hato what time is it", expected: false},
+		{name: "別のコマンド", text: "amedas 東京", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &bot.WikipediaCommand{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWikipediaCommandExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		article       *wikipedia.Article
+		lookupErr     error
+		req           *bot.Request
+		expected      string
+		expectedTerm  string
+		expectedError error
+	}{
+		{
+			name:    "記事の要約",
+			article: &wikipedia.Article{Title: "ハト", Extract: "ハトは鳥類である。", URL: "https://ja.wikipedia.org/wiki/ハト"},
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato what is 鳩？"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleJa},
+			},
+			expected:     i18n.Message(i18n.LocaleJa, i18n.KeyWikipediaSuccess, "ハト", "ハトは鳥類である。", "https://ja.wikipedia.org/wiki/ハト"),
+			expectedTerm: "鳩",
+		},
+		{
+			name: "曖昧さ回避のページ",
+			article: &wikipedia.Article{
+				Title:          "GO",
+				URL:            "https://ja.wikipedia.org/wiki/GO",
+				Disambiguation: true,
+				Candidates:     []string{"Go (プログラミング言語)", "囲碁"},
+			},
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato wiki GO"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+			},
+			expected:     i18n.Message(i18n.LocaleEn, i18n.KeyWikipediaDisambiguation, "GO", "Go (プログラミング言語), 囲碁", "https://ja.wikipedia.org/wiki/GO"),
+			expectedTerm: "GO",
+		},
+		{
+			name:      "記事が見つからない",
+			lookupErr: wikipedia.ErrNotFound,
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato wiki 存在しない語句"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedTerm:  "存在しない語句",
+			expectedError: wikipedia.ErrNotFound,
+		},
+		{
+			name:          "nilリクエスト",
+			req:           nil,
+			expectedError: lib.ErrParamsNil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var term string
+			command := &bot.WikipediaCommand{
+				Lookup: func(_ context.Context, got string) (*wikipedia.Article, error) {
+					term = got
+					return tt.article, tt.lookupErr
+				},
+			}
+			reply, err := command.Execute(t.Context(), tt.req)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if term != tt.expectedTerm {
+				t.Errorf("looked up term = %q, want %q", term, tt.expectedTerm)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if reply.Command != "wiki" {
+				t.Errorf("reply.Command = %q, want wiki", reply.Command)
+			}
+			if reply.Text != tt.expected {
+				t.Errorf("reply.Text = %q, want %q", reply.Text, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+)
+
+// Bar 棒グラフの1本の棒
+type Bar struct {
+	Label string     // 棒の下に表示する文言（空の場合は表示しない）
+	Value float64    // 値（0以下の場合は棒を描画しない）
+	Color color.RGBA // 棒の色（ゼロ値の場合は青）
+}
+
+// BarChart 棒グラフ
+type BarChart struct {
+	Title  string  // 上部に表示する題名（空の場合は表示しない）
+	Bars   []Bar   // 左から順に描画する棒
+	Max    float64 // 縦軸の最大値（0以下の場合は棒の値の最大値）
+	YTicks int     // 縦軸の目盛りの区間の数（0の場合は目盛りを表示しない）
+	Width  int     // 画像の幅（0の場合はDefaultWidth）
+	Height int     // 画像の高さ（0の場合はDefaultHeight）
+}
+
+// Render 棒グラフを描画した画像を返す
+// 棒の下の文言は前の文言と重なる場合は表示しない
+func (c *BarChart) Render() *image.RGBA {
+	img, plot := newCanvas(c.Width, c.Height, c.Title, nil)
+	axis := valueAxis{max: c.Max, ticks: c.YTicks}
+	if axis.max <= 0 {
+		for _, bar := range c.Bars {
+			axis.max = max(axis.max, bar.Value)
+		}
+	}
+	plot = axis.inset(plot)
+	if plot.Empty() {
+		return img
+	}
+
+	axis.draw(img, plot)
+	if 0 < len(c.Bars) {
+		c.drawBars(img, plot, axis)
+	}
+	drawBaseline(img, plot)
+	return img
+}
+
+// drawBars 描画領域に棒と棒の下の文言を描画する
+func (c *BarChart) drawBars(img *image.RGBA, plot image.Rectangle, axis valueAxis) {
+	labels := make([]string, 0, len(c.Bars))
+	for i, bar := range c.Bars {
+		labels = append(labels, bar.Label)
+		if bar.Value <= 0 || axis.max <= 0 {
+			continue
+		}
+		left, right := slotBounds(plot, len(c.Bars), i)
+		fillRect(img, image.Rect(left+barGap/2, axis.y(plot, bar.Value), right-barGap/2, plot.Max.Y), barColor(bar.Color))
+	}
+	drawLabels(img, plot, labels)
+}
+
+// barColor 棒の色を返す
+func barColor(c color.RGBA) color.RGBA {
+	if c == (color.RGBA{}) {
+		return defaultBarColor
+	}
+	return c
+}
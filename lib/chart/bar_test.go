@@ -0,0 +1,89 @@
+package chart_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"hato-bot-go/lib/chart"
+)
+
+func TestBarChartRender(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{R: 0, G: 65, B: 255, A: 255}
+
+	// 余白を除いた描画領域は(16,16)-(624,284)、棒1本あたりの幅は152ピクセル
+	bars := []chart.Bar{
+		{Label: "NOW", Value: 1, Color: red},
+		{Label: "+5", Value: 2, Color: red},
+		{Label: "+10", Value: 0, Color: red},
+		{Label: "+15", Value: 4},
+	}
+
+	tests := []struct {
+		name     string
+		chart    *chart.BarChart
+		expected map[image.Point]color.RGBA
+	}{
+		{
+			name:  "最大値の棒は描画領域の高さで描画する",
+			chart: &chart.BarChart{Bars: bars},
+			expected: map[image.Point]color.RGBA{
+				{X: 92, Y: 270}:  red,
+				{X: 92, Y: 200}:  white,
+				{X: 396, Y: 280}: white,
+				{X: 548, Y: 20}:  blue,
+			},
+		},
+		{
+			name:  "最大値を指定した場合は超える棒を描画領域の高さで切る",
+			chart: &chart.BarChart{Bars: bars, Max: 2},
+			expected: map[image.Point]color.RGBA{
+				{X: 92, Y: 140}: white,
+				{X: 244, Y: 20}: red,
+				{X: 548, Y: 20}: blue,
+			},
+		},
+		{
+			name:  "目盛りを指定した場合は値の幅だけ描画領域を右にずらして補助線を描画する",
+			chart: &chart.BarChart{Bars: bars, YTicks: 2},
+			expected: map[image.Point]color.RGBA{
+				{X: 20, Y: 270}:  white,
+				{X: 400, Y: 150}: {R: 224, G: 224, B: 224, A: 255},
+				{X: 400, Y: 149}: white,
+				{X: 560, Y: 20}:  blue,
+			},
+		},
+		{
+			name:  "棒の間は余白にする",
+			chart: &chart.BarChart{Bars: bars},
+			expected: map[image.Point]color.RGBA{
+				{X: 168, Y: 280}: white,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			img := tt.chart.Render()
+			if size := img.Bounds().Size(); size != (image.Point{X: chart.DefaultWidth, Y: chart.DefaultHeight}) {
+				t.Errorf("image size = %v, want %dx%d", size, chart.DefaultWidth, chart.DefaultHeight)
+			}
+			for point, expected := range tt.expected {
+				if got := img.RGBAAt(point.X, point.Y); got != expected {
+					t.Errorf("pixel at %v = %v, want %v", point, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestBarChartRenderEmpty(t *testing.T) {
+	t.Parallel()
+	img := (&chart.BarChart{Title: "EMPTY", Width: 100, Height: 40}).Render()
+	if size := img.Bounds().Size(); size != (image.Point{X: 100, Y: 40}) {
+		t.Errorf("image size = %v, want 100x40", size)
+	}
+}
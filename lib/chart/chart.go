@@ -0,0 +1,177 @@
+// Package chart 埋め込みフォントで文字を描く、外部ライブラリに依存しない簡単なグラフの描画
+//
+// 棒グラフ（BarChart）と折れ線グラフ（LineChart）をimage.RGBAに描画する。
+// 埋め込みフォントは英数字と一部の記号のみ対応のため、題名や文言は英数字で表記する。
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"hato-bot-go/lib/font"
+)
+
+// グラフの寸法（ピクセル）
+const (
+	DefaultWidth  = 640 // Widthを指定しない場合の画像の幅
+	DefaultHeight = 320 // Heightを指定しない場合の画像の高さ
+	textScale     = 2   // 文字の拡大率
+	margin        = 16  // 画像の端と描画領域の間の余白
+	barGap        = 4   // 棒の間の余白
+	labelGap      = 6   // 文字と描画領域の間の余白
+	lineWidth     = 2   // 折れ線の太さ
+	markerSize    = 6   // 折れ線の点の目印の大きさ
+)
+
+// グラフの配色
+var (
+	backgroundColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	axisColor       = color.RGBA{R: 64, G: 64, B: 64, A: 255}
+	gridColor       = color.RGBA{R: 224, G: 224, B: 224, A: 255}
+	textColor       = color.RGBA{R: 32, G: 32, B: 32, A: 255}
+	defaultBarColor = color.RGBA{R: 0, G: 65, B: 255, A: 255}
+)
+
+// legendEntry 凡例の1項目
+type legendEntry struct {
+	name  string
+	color color.RGBA
+}
+
+// newCanvas 背景を塗った画像を作成し、題名と凡例を描画して、横軸の文言の領域を除いた描画領域を返す
+// 幅・高さが0以下の場合はDefaultWidth・DefaultHeightを使う
+func newCanvas(width, height int, title string, legend []legendEntry) (*image.RGBA, image.Rectangle) {
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if height <= 0 {
+		height = DefaultHeight
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(backgroundColor), image.Point{}, draw.Src)
+
+	textHeight := font.GlyphHeight * textScale
+	plot := image.Rect(margin, margin, width-margin, height-margin-textHeight-labelGap)
+	if title != "" || 0 < len(legend) {
+		drawText(img, margin, margin, title)
+		drawLegend(img, legend)
+		plot.Min.Y += textHeight + labelGap
+	}
+	return img, plot
+}
+
+// drawLegend 凡例を画像の右上に右詰めで描画する
+func drawLegend(img *image.RGBA, legend []legendEntry) {
+	textHeight := font.GlyphHeight * textScale
+	right := img.Bounds().Dx() - margin
+	for i := len(legend) - 1; 0 <= i; i-- {
+		entry := legend[i]
+		size := font.MeasureText(entry.name, textScale)
+		drawText(img, right-size.X, margin, entry.name)
+		right -= size.X + labelGap
+		fillRect(img, image.Rect(right-textHeight, margin, right, margin+textHeight), entry.color)
+		right -= textHeight + margin
+	}
+}
+
+// valueAxis 縦軸の値の範囲と目盛り
+type valueAxis struct {
+	min   float64 // 描画領域の下端の値
+	max   float64 // 描画領域の上端の値
+	ticks int     // 目盛りの区間の数（0の場合は目盛りを表示しない）
+}
+
+// inset 目盛りの文言の幅だけ左端を右にずらした描画領域を返す
+func (a valueAxis) inset(plot image.Rectangle) image.Rectangle {
+	if a.ticks <= 0 {
+		return plot
+	}
+	labelWidth := 0
+	for i := range a.ticks + 1 {
+		labelWidth = max(labelWidth, font.MeasureText(formatValue(a.tickValue(i)), textScale).X)
+	}
+	plot.Min.X += labelWidth + labelGap
+	return plot
+}
+
+// tickValue i番目（下から）の目盛りの値を返す
+func (a valueAxis) tickValue(i int) float64 {
+	return a.min + (a.max-a.min)*float64(i)/float64(a.ticks)
+}
+
+// y 値を描画領域のy座標に変換する
+// 範囲外の値は描画領域の上端・下端に切る
+func (a valueAxis) y(plot image.Rectangle, value float64) int {
+	ratio := 0.0
+	if a.min < a.max {
+		ratio = min(max((value-a.min)/(a.max-a.min), 0), 1)
+	}
+	return plot.Max.Y - int(float64(plot.Dy())*ratio)
+}
+
+// draw 目盛りの補助線と値を描画する
+func (a valueAxis) draw(img *image.RGBA, plot image.Rectangle) {
+	if a.ticks <= 0 {
+		return
+	}
+	textHeight := font.GlyphHeight * textScale
+	for i := range a.ticks + 1 {
+		value := a.tickValue(i)
+		y := a.y(plot, value)
+		fillRect(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), gridColor)
+		label := formatValue(value)
+		drawText(img, plot.Min.X-labelGap-font.MeasureText(label, textScale).X, y-textHeight/2, label)
+	}
+}
+
+// slotBounds 描画領域をn等分したi番目の区間の左端と右端のx座標を返す
+func slotBounds(plot image.Rectangle, n, i int) (int, int) {
+	slot := float64(plot.Dx()) / float64(n)
+	return plot.Min.X + int(float64(i)*slot), plot.Min.X + int(float64(i+1)*slot)
+}
+
+// drawLabels 描画領域の下に区間ごとの文言を中央揃えで描画する
+// 前の文言と重なる文言は表示しない
+func drawLabels(img *image.RGBA, plot image.Rectangle, labels []string) {
+	labelRight := plot.Min.X - labelGap // 最後に表示した文言の右端
+	for i, label := range labels {
+		left, right := slotBounds(plot, len(labels), i)
+		size := font.MeasureText(label, textScale)
+		x := (left+right)/2 - size.X/2
+		if label == "" || x < labelRight+labelGap {
+			continue
+		}
+		drawText(img, x, plot.Max.Y+labelGap, label)
+		labelRight = x + size.X
+	}
+}
+
+// drawBaseline 描画領域の下端に横軸を描画する
+func drawBaseline(img *image.RGBA, plot image.Rectangle) {
+	fillRect(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+1), axisColor)
+}
+
+// formatValue 目盛りの値を小数第2位までの最短の表記にする
+func formatValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// fillRect 矩形を塗りつぶす
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawText 左上の座標を指定して文字を描画する
+func drawText(img *image.RGBA, x, y int, text string) {
+	font.DrawText(&font.DrawTextParams{
+		Img:   img,
+		X:     x,
+		Y:     y,
+		Text:  text,
+		Color: textColor,
+		Scale: textScale,
+	})
+}
@@ -0,0 +1,116 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Series 折れ線グラフの1本の折れ線
+type Series struct {
+	Name   string     // 凡例に表示する名前（空の場合は凡例に表示しない）
+	Values []float64  // LineChart.Labelsの区間ごとの値（NaNの点は描画せず、前後の点を結ばない）
+	Color  color.RGBA // 線の色（ゼロ値の場合は青）
+}
+
+// LineChart 折れ線グラフ
+// 点は横軸をLabelsの数で等分した区間の中央に置く
+type LineChart struct {
+	Title  string   // 上部に表示する題名（空の場合は表示しない）
+	Labels []string // 横軸の区間ごとに表示する文言（空の文言は表示しない）
+	Series []Series // 描画する折れ線（後の折れ線を上に重ねる）
+	Min    float64  // 縦軸の最小値
+	Max    float64  // 縦軸の最大値（Min以下の場合は値の最大値）
+	YTicks int      // 縦軸の目盛りの区間の数（0の場合は目盛りを表示しない）
+	Width  int      // 画像の幅（0の場合はDefaultWidth）
+	Height int      // 画像の高さ（0の場合はDefaultHeight）
+}
+
+// Render 折れ線グラフを描画した画像を返す
+// 横軸の文言は前の文言と重なる場合は表示しない
+func (c *LineChart) Render() *image.RGBA {
+	var legend []legendEntry
+	for _, series := range c.Series {
+		if series.Name != "" {
+			legend = append(legend, legendEntry{name: series.Name, color: barColor(series.Color)})
+		}
+	}
+	img, plot := newCanvas(c.Width, c.Height, c.Title, legend)
+	plot = c.axis().inset(plot)
+	if plot.Empty() {
+		return img
+	}
+
+	c.axis().draw(img, plot)
+	if 0 < len(c.Labels) {
+		for _, series := range c.Series {
+			c.drawSeries(img, plot, series)
+		}
+		drawLabels(img, plot, c.Labels)
+	}
+	drawBaseline(img, plot)
+	return img
+}
+
+// axis 縦軸の値の範囲を返す
+// 最大値を指定しない場合は値の最大値を使い、値がない場合は最小値+1とする
+func (c *LineChart) axis() valueAxis {
+	axis := valueAxis{min: c.Min, max: c.Max, ticks: c.YTicks}
+	if axis.min < axis.max {
+		return axis
+	}
+	axis.max = math.Inf(-1)
+	for _, series := range c.Series {
+		for _, value := range series.Values {
+			if !math.IsNaN(value) {
+				axis.max = max(axis.max, value)
+			}
+		}
+	}
+	if axis.max <= axis.min {
+		axis.max = axis.min + 1
+	}
+	return axis
+}
+
+// drawSeries 折れ線と点の目印を描画する
+func (c *LineChart) drawSeries(img *image.RGBA, plot image.Rectangle, series Series) {
+	lineColor := barColor(series.Color)
+	axis := c.axis()
+	var prev *image.Point
+	for i, value := range series.Values {
+		if len(c.Labels) <= i || math.IsNaN(value) {
+			prev = nil
+			continue
+		}
+		left, right := slotBounds(plot, len(c.Labels), i)
+		point := image.Point{X: (left + right) / 2, Y: axis.y(plot, value)}
+		if prev != nil {
+			drawLine(img, *prev, point, lineColor)
+		}
+		fillRect(img, image.Rect(point.X-markerSize/2, point.Y-markerSize/2, point.X+markerSize/2, point.Y+markerSize/2).Intersect(img.Bounds()), lineColor)
+		prev = &point
+	}
+}
+
+// drawLine 2点を結ぶ太さlineWidthの線分を描画する
+func drawLine(img *image.RGBA, from, to image.Point, c color.RGBA) {
+	steps := max(abs(to.X-from.X), abs(to.Y-from.Y))
+	for i := range steps + 1 {
+		x := from.X
+		y := from.Y
+		if 0 < steps {
+			x += (to.X - from.X) * i / steps
+			y += (to.Y - from.Y) * i / steps
+		}
+		fillRect(img, image.Rect(x-lineWidth/2, y-lineWidth/2, x-lineWidth/2+lineWidth, y-lineWidth/2+lineWidth).Intersect(img.Bounds()), c)
+	}
+}
+
+// abs 整数の絶対値を返す
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package chart_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"hato-bot-go/lib/chart"
+)
+
+func TestLineChartRender(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{R: 0, G: 65, B: 255, A: 255}
+
+	// 余白を除いた描画領域は(16,16)-(624,284)、点は区間の中央（x=92,244,396,548）に置く
+	labels := []string{"MON", "TUE", "WED", "THU"}
+
+	tests := []struct {
+		name     string
+		chart    *chart.LineChart
+		expected map[image.Point]color.RGBA
+	}{
+		{
+			name: "点を線で結び、NaNの前後は結ばない",
+			chart: &chart.LineChart{
+				Labels: labels,
+				Series: []chart.Series{{Values: []float64{0, 10, math.NaN(), 5}, Color: red}},
+			},
+			expected: map[image.Point]color.RGBA{
+				{X: 92, Y: 282}:  red,
+				{X: 168, Y: 150}: red,
+				{X: 244, Y: 17}:  red,
+				{X: 396, Y: 83}:  white,
+				{X: 548, Y: 150}: red,
+			},
+		},
+		{
+			name: "最小値と最大値を指定した場合は範囲外の値を端に切る",
+			chart: &chart.LineChart{
+				Labels: labels,
+				Series: []chart.Series{{Values: []float64{4, 8}}},
+				Min:    4,
+				Max:    6,
+			},
+			expected: map[image.Point]color.RGBA{
+				{X: 92, Y: 282}: blue,
+				{X: 244, Y: 17}: blue,
+				{X: 396, Y: 17}: white,
+			},
+		},
+		{
+			name: "名前のある折れ線は右上に凡例を表示する",
+			chart: &chart.LineChart{
+				Labels: labels,
+				Series: []chart.Series{{Name: "A", Values: []float64{1, 2}, Color: red}},
+			},
+			expected: map[image.Point]color.RGBA{
+				{X: 600, Y: 20}: red,
+				{X: 590, Y: 20}: white,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			img := tt.chart.Render()
+			if size := img.Bounds().Size(); size != (image.Point{X: chart.DefaultWidth, Y: chart.DefaultHeight}) {
+				t.Errorf("image size = %v, want %dx%d", size, chart.DefaultWidth, chart.DefaultHeight)
+			}
+			for point, expected := range tt.expected {
+				if got := img.RGBAAt(point.X, point.Y); got != expected {
+					t.Errorf("pixel at %v = %v, want %v", point, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestLineChartRenderEmpty(t *testing.T) {
+	t.Parallel()
+	img := (&chart.LineChart{Title: "EMPTY", Series: []chart.Series{{Values: []float64{1}}}, Width: 100, Height: 40}).Render()
+	if size := img.Bounds().Size(); size != (image.Point{X: 100, Y: 40}) {
+		t.Errorf("image size = %v, want 100x40", size)
+	}
+}
@@ -0,0 +1,65 @@
+package clock
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Clock 現在時刻の取得と待機を提供する時計
+// 設定やパラメータで差し替えられるようにし、テストではclocktest.Fakeで実際に待たずに時間の経過を再現する
+type Clock interface {
+	Now() time.Time                                   // 現在時刻を返す
+	Sleep(ctx context.Context, d time.Duration) error // dだけ待つ（待っている間にctxが終了した場合はそのエラーを返す）
+	NewTicker(d time.Duration) Ticker                 // d間隔で時刻を送るTickerを作成する
+}
+
+// Ticker 一定の間隔で時刻を送るtime.Tickerの抽象
+type Ticker interface {
+	C() <-chan time.Time // 時刻を受け取るチャネル
+	Stop()               // 時刻の送信を止める
+}
+
+// Real 実際の時刻を使う時計
+var Real Clock = realClock{}
+
+// Or 時計がnilの場合はRealを返す
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// realClock timeパッケージを使う時計
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "Failed to sleep")
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{Ticker: time.NewTicker(d)}
+}
+
+// realTicker time.Tickerを使うTicker
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
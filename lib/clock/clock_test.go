@@ -0,0 +1,61 @@
+package clock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/clock/clocktest"
+)
+
+func TestRealSleep(t *testing.T) {
+	tests := []struct {
+		name          string
+		canceled      bool
+		duration      time.Duration
+		expectedError error
+	}{
+		{name: "待ち時間が経過", duration: time.Millisecond},
+		{name: "待っている間にコンテキストが終了", canceled: true, duration: time.Hour, expectedError: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(t.Context())
+			if tt.canceled {
+				cancel()
+			}
+			defer cancel()
+
+			if err := clock.Real.Sleep(ctx, tt.duration); !errors.Is(err, tt.expectedError) {
+				t.Errorf("Sleep() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestOr(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		clock    clock.Clock
+		expected clock.Clock
+	}{
+		{name: "未設定の場合は実際の時計", clock: nil, expected: clock.Real},
+		{name: "設定した時計", clock: fake, expected: fake},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := clock.Or(tt.clock); got != tt.expected {
+				t.Errorf("Or() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package clocktest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+)
+
+// Fake Advanceで進めるまで時刻が止まっているテスト用の時計
+// SleepとTickerは実際には待たず、Advanceで期限を過ぎた時点で起きる
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter Sleepで待っている処理またはTicker
+type waiter struct {
+	at     time.Time      // 次に起こす時刻
+	period time.Duration  // Tickerの間隔（Sleepの場合は0）
+	ch     chan time.Time // 起こす時刻を送るチャネル
+	fake   *Fake          // Stopで取り除く時計
+}
+
+// NewFake 指定した時刻で止まっている時計を作成する
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now 現在時刻を返す
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Sleep Advanceで時刻がdだけ進むまで待つ
+func (f *Fake) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	w := f.add(d, 0)
+	defer f.remove(w)
+
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "Failed to sleep")
+	case <-w.ch:
+		return nil
+	}
+}
+
+// NewTicker Advanceで時刻がdだけ進むたびに時刻を送るTickerを作成する
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clocktest.Fake.NewTicker")
+	}
+	return f.add(d, d)
+}
+
+// Advance 時刻をdだけ進め、期限を過ぎたSleepとTickerを起こす
+// Tickerは受け取られていない時刻があれば、time.Tickerと同じく新しい時刻を捨てる
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	remaining := f.waiters[:0]
+	for _, w := range f.waiters {
+		if f.now.Before(w.at) {
+			remaining = append(remaining, w)
+			continue
+		}
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+		if 0 < w.period {
+			for !f.now.Before(w.at) {
+				w.at = w.at.Add(w.period)
+			}
+			remaining = append(remaining, w)
+		}
+	}
+	f.waiters = remaining
+}
+
+// BlockUntil SleepとTickerの合計がn以上になるまで待つ
+// 別のゴルーチンが待ち始めてからAdvanceするために使う
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// add 待っている処理を登録する
+func (f *Fake) add(d, period time.Duration) *waiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &waiter{at: f.now.Add(d), period: period, ch: make(chan time.Time, 1), fake: f}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+	return w
+}
+
+// remove 待っている処理の登録を取り除く
+func (f *Fake) remove(target *waiter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, w := range f.waiters {
+		if w == target {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// C 時刻を受け取るチャネル
+func (w *waiter) C() <-chan time.Time {
+	return w.ch
+}
+
+// Stop Tickerの時刻の送信を止める
+func (w *waiter) Stop() {
+	w.fake.remove(w)
+}
@@ -0,0 +1,79 @@
+package clocktest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock/clocktest"
+)
+
+// testStart テストで使う時計の開始時刻
+var testStart = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+func TestFakeSleep(t *testing.T) {
+	t.Parallel()
+	fake := clocktest.NewFake(testStart)
+
+	done := make(chan error, 1)
+	go func() { done <- fake.Sleep(t.Context(), time.Minute) }()
+
+	fake.BlockUntil(1)
+	fake.Advance(59 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("Sleep() returned before the deadline: %v", err)
+	default:
+	}
+
+	fake.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Sleep() error = %v", err)
+	}
+	if got, want := fake.Now(), testStart.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
+
+func TestFakeSleepCanceled(t *testing.T) {
+	t.Parallel()
+	fake := clocktest.NewFake(testStart)
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan error, 1)
+	go func() { done <- fake.Sleep(ctx, time.Hour) }()
+
+	fake.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	t.Parallel()
+	fake := clocktest.NewFake(testStart)
+	ticker := fake.NewTicker(10 * time.Second)
+
+	fake.Advance(10 * time.Second)
+	if got, want := <-ticker.C(), testStart.Add(10*time.Second); !got.Equal(want) {
+		t.Errorf("tick = %v, want %v", got, want)
+	}
+
+	// 受け取っていない時刻があれば新しい時刻は捨てる
+	fake.Advance(10 * time.Second)
+	fake.Advance(10 * time.Second)
+	if got, want := <-ticker.C(), testStart.Add(20*time.Second); !got.Equal(want) {
+		t.Errorf("tick = %v, want %v", got, want)
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Minute)
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("tick after Stop = %v", tick)
+	default:
+	}
+}
@@ -0,0 +1,48 @@
+package lib
+
+import "strings"
+
+// ParseCommandResult コマンドの解析結果
+type ParseCommandResult struct {
+	Args    string // コマンド名に続く引数（前後の空白は除去する）
+	Matched bool   // 指定したコマンドか
+}
+
+// invisibleSeparatorReplacer 単語の区切りとして扱う不可視文字（ゼロ幅スペースなど）を空白に置き換える
+// 絵文字の結合に使うゼロ幅接合子（U+200D）などは地名の一部として残す
+var invisibleSeparatorReplacer = strings.NewReplacer(
+	"\u200b", " ", // ゼロ幅スペース
+	"\u2060", " ", // ワードジョイナー
+	"\ufeff", " ", // ゼロ幅ノーブレークスペース（BOM）
+	"\u180e", " ", // モンゴル語母音区切り
+)
+
+// SplitCommand メンションを除去したテキストをコマンド名と引数に分ける
+// ハッシュタグ（#amesh）で始まる場合は#を除いた名前をコマンド名とする
+// メンション以外の単語がない場合はfalseを返す
+func SplitCommand(text string) (string, string, bool) {
+	// @username を削除
+	var cleanWords []string
+	for _, word := range strings.Fields(StripMentions(text)) {
+		if !strings.HasPrefix(word, "@") {
+			cleanWords = append(cleanWords, word)
+		}
+	}
+	if len(cleanWords) == 0 {
+		return "", "", false
+	}
+	return strings.TrimPrefix(cleanWords[0], "#"), strings.Join(cleanWords[1:], " "), true
+}
+
+// ParseCommand メンションを除去したテキストが指定したコマンドで始まるか解析する
+// ハッシュタグ（#amesh）で始まる場合もコマンドとして扱う
+func ParseCommand(text, command string) ParseCommandResult {
+	name, args, ok := SplitCommand(text)
+	if !ok || name != command {
+		return ParseCommandResult{}
+	}
+	return ParseCommandResult{
+		Args:    args,
+		Matched: true,
+	}
+}
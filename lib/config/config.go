@@ -0,0 +1,613 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// PathEnv 設定ファイルのパスを指定する環境変数
+const PathEnv = "HATO_BOT_CONFIG"
+
+var (
+	// ErrInvalidTimeout コマンドや外部サービスへのリクエストの制限時間の設定値が不正であることを表すエラー
+	ErrInvalidTimeout = errors.New("invalid command timeout")
+	// ErrInvalidRateLimit コマンドの実行回数の制限の設定値が不正であることを表すエラー
+	ErrInvalidRateLimit = errors.New("invalid rate limit")
+	// ErrInvalidHistory コマンドの履歴の設定値が不正であることを表すエラー
+	ErrInvalidHistory = errors.New("invalid history")
+	// ErrInvalidJobQueue コマンドのジョブキューの設定値が不正であることを表すエラー
+	ErrInvalidJobQueue = errors.New("invalid job queue")
+	// ErrInvalidHTTPServer HTTPサーバーのTLSやリバースプロキシの設定値が不正であることを表すエラー
+	ErrInvalidHTTPServer = errors.New("invalid http server")
+	// ErrInvalidDependencyGate 依存する外部サービスによる受付の制御の設定値が不正であることを表すエラー
+	ErrInvalidDependencyGate = errors.New("invalid dependency gate")
+	// ErrInvalidLoadShedding 混雑時にメッセージを処理しない設定値が不正であることを表すエラー
+	ErrInvalidLoadShedding = errors.New("invalid load shedding")
+	// ErrInvalidMisskeyInstance 接続するMisskeyのインスタンスの設定値が不正であることを表すエラー
+	ErrInvalidMisskeyInstance = errors.New("invalid misskey instance")
+	// ErrInvalidAlias コマンドの別名の設定値が不正であることを表すエラー
+	ErrInvalidAlias = errors.New("invalid alias")
+	// ErrInvalidDrivePrune ドライブの古いファイルの削除の設定値が不正であることを表すエラー
+	ErrInvalidDrivePrune = errors.New("invalid drive prune")
+	// ErrInvalidStaleThreshold 雨雲レーダーのデータが古いとみなす経過時間の設定値が不正であることを表すエラー
+	ErrInvalidStaleThreshold = errors.New("invalid stale threshold")
+	// ErrInvalidLogLevel ログの出力レベルの設定値が不正であることを表すエラー
+	ErrInvalidLogLevel = errors.New("invalid log level")
+	// ErrInvalidFeature 機能の有効・無効の設定に存在しない機能を指定したことを表すエラー
+	ErrInvalidFeature = errors.New("invalid feature")
+)
+
+// Config 設定ファイルの内容
+type Config struct {
+	// Contact 運用者の連絡先（URLまたはメールアドレス、外部サービスへのリクエストのUser-Agentに含める）
+	// OpenStreetMapのタイルの利用規約で求められるため、公開して運用する場合は設定する
+	Contact string `json:"contact,omitempty"`
+
+	// Mode 実行モード（misskey・mixi2・cli・serve、コマンドライン引数や環境変数HATO_MODEで上書きできる）
+	Mode string `json:"mode,omitempty"`
+
+	// LogLevel ログの出力レベル（debug・info・warn・error、空の場合はinfo、設定ファイルを読み込み直すと反映する）
+	LogLevel string `json:"log_level,omitempty"`
+
+	// StateDir 履歴やTLS証明書などの状態を保存するディレクトリ（環境変数HATO_BOT_STATE_DIRで上書きできる、空の場合はXDG_STATE_HOMEなどから決める）
+	StateDir string `json:"state_dir,omitempty"`
+
+	// Templates 返信テンプレート（キーはamesh.success・error.command・reply.cwなどのメッセージキー、値はGoテンプレート）
+	Templates map[string]string `json:"templates,omitempty"`
+
+	// Aliases コマンドの別名からコマンド名への対応（例: {"雨雲": "amesh", "あめっしゅ": "amesh"}）
+	// 全角・半角と大文字・小文字は区別せず、実行モードで使えないコマンドへの別名は無視する
+	Aliases map[string]string `json:"aliases,omitempty"`
+
+	// Features コマンド名（amesh・translateなど）またはearthquakeごとの有効・無効（falseで無効、未設定の機能は有効）
+	// 無効にしたコマンドには無効である旨を返信し、helpコマンドの一覧で分けて表示する
+	Features map[string]bool `json:"features,omitempty"`
+
+	// CommandTimeouts コマンド名ごとの処理の制限時間（time.ParseDurationの形式、例: {"amesh": "30s"}）
+	CommandTimeouts map[string]string `json:"command_timeouts,omitempty"`
+
+	// HTTPTimeouts 外部サービスへのリクエストの種類ごとの制限時間（time.ParseDurationの形式、例: {"tiles": "20s"}）
+	// 種類はgeocoder・tiles・jma・misskey・upload・defaultで、未設定の種類は標準の制限時間を使う
+	HTTPTimeouts map[string]string `json:"http_timeouts,omitempty"`
+
+	// APIQuotas 外部サービス（yahoo_geocoder・nominatim・osm・jma）ごとのリクエスト数の目安（超えた場合は警告をログに出力する、未設定の外部サービスは確認しない）
+	APIQuotas map[string]APIQuota `json:"api_quotas,omitempty"`
+
+	// AmeshStaleThreshold 雨雲レーダーの基準時刻がこれより古い場合に画像と返信で注意する経過時間（time.ParseDurationの形式、空の場合は20m）
+	AmeshStaleThreshold string `json:"amesh_stale_threshold,omitempty"`
+
+	// AmeshOverlays ameshの画像に重ねるレイヤー名（radar・flood・snow）ごとの不透明度と合成方法（未設定のレイヤーは既定値で描画する）
+	AmeshOverlays map[string]AmeshOverlay `json:"amesh_overlays,omitempty"`
+
+	// AmeshMapStyle ameshやmapの画像のベースマップのスタイル（light・dark・auto、autoは地点の日の入りから日の出までdark、空の場合はlight）
+	AmeshMapStyle string `json:"amesh_map_style,omitempty"`
+
+	// AmeshElevation ameshコマンドの1か所の地点の画像の説明文に国土地理院の標高APIで取得した標高を添えるか
+	AmeshElevation bool `json:"amesh_elevation,omitempty"`
+
+	// AmeshCoverage ameshコマンドの1か所の地点の返信に、画像の距離円の区間ごとの主な市区町村（埋め込みの地名の一覧から選ぶ）を添えるか
+	AmeshCoverage bool `json:"amesh_coverage,omitempty"`
+
+	// ImageLimits プラットフォーム名（misskey・mixi2）ごとの返信に添付する画像の大きさの上限（未設定のプラットフォームは制限しない）
+	ImageLimits map[string]ImageLimit `json:"image_limits,omitempty"`
+
+	// RateLimit 送信者ごとのコマンドの実行回数の制限（未設定の場合は制限しない）
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+
+	// History コマンドの処理の履歴の保存先と保持期間（未設定の場合は保存しない）
+	History *History `json:"history,omitempty"`
+
+	// JobQueue Misskeyボットで受け付けたコマンドを処理の前に保存し、再起動後に処理するキュー（未設定の場合は保存しない）
+	JobQueue *JobQueue `json:"job_queue,omitempty"`
+
+	// Admins 管理者の送信者のID（statsコマンドなど管理者向けのコマンドを使える）
+	Admins []string `json:"admins,omitempty"`
+
+	// Geocoder 地名を探すジオコーダ（未設定の場合はYAHOO_API_TOKENがあればYahoo!ジオコーダ、なければ埋め込みの地名の一覧）
+	Geocoder *Geocoder `json:"geocoder,omitempty"`
+
+	// Translate translateコマンドで使う翻訳サービス（未設定の場合はtranslateコマンドを使わない）
+	Translate *Translate `json:"translate,omitempty"`
+
+	// Notifiers 画像や通知を送信するWebhook（定期投稿や警報などの一方向の出力に使う）
+	Notifiers []Notifier `json:"notifiers,omitempty"`
+
+	// Earthquake Misskeyボットで地震情報を自動で投稿する設定（未設定の場合は投稿しない）
+	Earthquake *Earthquake `json:"earthquake,omitempty"`
+
+	// DrivePrune Misskeyボットでドライブの古いファイルを定期的に削除する設定（未設定の場合は削除しない）
+	DrivePrune *DrivePrune `json:"drive_prune,omitempty"`
+
+	// HTTPServer /status・/metricsなどを提供するHTTPサーバーのTLSとリバースプロキシの設定（未設定の場合はTLSなし）
+	HTTPServer *HTTPServer `json:"http_server,omitempty"`
+
+	// DependencyGate Misskeyボットで依存する外部サービスの障害中にメッセージの受付を止める設定（未設定の場合は既定値で有効）
+	DependencyGate *DependencyGate `json:"dependency_gate,omitempty"`
+
+	// MisskeyInstances Misskeyボットで1つのプロセスから接続するインスタンスとアカウントの一覧（未設定の場合は環境変数MISSKEY_DOMAIN・MISSKEY_API_TOKENのアカウントのみ）
+	MisskeyInstances []MisskeyInstance `json:"misskey_instances,omitempty"`
+
+	// LoadShedding Misskeyボットで処理を待つメッセージの上限と、超えた場合に処理しない設定（未設定の場合は既定値で有効）
+	LoadShedding *LoadShedding `json:"load_shedding,omitempty"`
+}
+
+// Notifier 通知を送信するWebhookの設定
+type Notifier struct {
+	Name string `json:"name,omitempty"` // ログやメトリクスに表示する名前（空の場合はtype）
+	Type string `json:"type"`           // 送信先の種類（slack・discord・webhook）
+	URL  string `json:"url"`            // WebhookのURL
+}
+
+// AmeshOverlay ameshの画像に重ねるレイヤーの描画の設定
+type AmeshOverlay struct {
+	Opacity float64 `json:"opacity,omitempty"` // 不透明度（0より大きく1以下の割合、0の場合はレイヤーの既定値）
+	Blend   string  `json:"blend,omitempty"`   // 合成方法（normal・multiply・priority、空の場合はnormal）
+}
+
+// APIQuota 外部サービスへのリクエスト数の目安の設定
+type APIQuota struct {
+	Hourly int64 `json:"hourly,omitempty"` // 1時間（毎時0分から）のリクエスト数の目安（0の場合は確認しない）
+	Daily  int64 `json:"daily,omitempty"`  // 1日（0時から）のリクエスト数の目安（0の場合は確認しない）
+}
+
+// ImageLimit 返信に添付する画像の大きさの上限の設定
+type ImageLimit struct {
+	MaxDimension int `json:"max_dimension,omitempty"` // 幅と高さの上限（ピクセル、128以上、0の場合は制限しない）
+	MaxBytes     int `json:"max_bytes,omitempty"`     // PNG形式のファイルのバイト数の上限（0の場合は制限しない）
+	// ThumbnailDimension 幅か高さがこれを超える画像は縮小したサムネイルを添付し、元の大きさの画像へのリンクを本文に添える
+	// （ピクセル、128以上、Misskeyボットのみ、0の場合はサムネイルにしない）
+	ThumbnailDimension int `json:"thumbnail_dimension,omitempty"`
+}
+
+// RateLimit 送信者ごとのコマンドの実行回数の制限の設定
+type RateLimit struct {
+	Count  int    `json:"count"`  // 期間内に実行できる回数
+	Window string `json:"window"` // 回数を数える期間（time.ParseDurationの形式、例: "1m"）
+}
+
+// History コマンドの処理の履歴の設定
+type History struct {
+	Path      string `json:"path,omitempty"`      // 履歴を保存するJSON Linesファイルのパス（空の場合は状態のディレクトリのhistory/history.jsonl）
+	Retention string `json:"retention,omitempty"` // 履歴を保持する期間（time.ParseDurationの形式、例: "720h"、空の場合は無期限）
+	Secret    string `json:"secret"`              // 送信者のIDのハッシュに使う鍵（HMAC-SHA256の鍵）
+}
+
+// JobQueue コマンドのジョブキューの設定
+type JobQueue struct {
+	Path        string `json:"path,omitempty"`         // ジョブの状態を保存するJSON Linesファイルのパス（空の場合は状態のディレクトリのqueue/jobs.jsonl）
+	MaxAttempts int    `json:"max_attempts,omitempty"` // 1つのコマンドを処理する回数の上限（0の場合は3）
+	Retention   string `json:"retention,omitempty"`    // 処理したコマンドのIDを重複の判定のために保持する期間（time.ParseDurationの形式、空の場合は24h）
+}
+
+// Geocoder ジオコーダの設定
+type Geocoder struct {
+	Provider  string `json:"provider"`             // ジオコーダの種類（yahoo・nominatim）
+	APIKey    string `json:"api_key,omitempty"`    // APIキー（yahooでは必須）
+	URL       string `json:"url,omitempty"`        // 検索APIのURL（自前のNominatimサーバーなど既定のURLを上書きする場合のみ）
+	UserAgent string `json:"user_agent,omitempty"` // User-Agentに使うアプリケーション名（nominatimでは必須）
+	Email     string `json:"email,omitempty"`      // 運用者の連絡先のメールアドレス（nominatimでは必須）
+}
+
+// Translate 翻訳サービスの設定
+type Translate struct {
+	Provider string `json:"provider"`          // 翻訳サービスの種類（deepl・google・libretranslate）
+	APIKey   string `json:"api_key,omitempty"` // APIキー（LibreTranslateでは任意）
+	URL      string `json:"url,omitempty"`     // APIのURL（LibreTranslateでは必須、それ以外は既定のURLを上書きする場合のみ）
+}
+
+// Earthquake 地震情報の自動投稿の設定
+type Earthquake struct {
+	MinIntensity string `json:"min_intensity"`        // 投稿する最大震度の下限（1〜7、5弱・5強・6弱・6強は5-・5+・6-・6+とも書ける）
+	Map          bool   `json:"map,omitempty"`        // 震源の地図を添付するか
+	Visibility   string `json:"visibility,omitempty"` // ノートの公開範囲（空の場合はhome）
+	LocalOnly    bool   `json:"local_only,omitempty"` // ローカルのみに投稿するか
+	URL          string `json:"url,omitempty"`        // P2P地震情報のWebSocketのURL（既定のURLを上書きする場合のみ）
+}
+
+// DrivePrune ドライブの古いファイルの削除の設定
+// retentionとusage_limitの少なくとも一方を指定する
+type DrivePrune struct {
+	Retention  string  `json:"retention,omitempty"`   // アップロードからファイルを残す期間（time.ParseDurationの形式、例: "720h"、空の場合は期間では削除しない）
+	UsageLimit float64 `json:"usage_limit,omitempty"` // ドライブの容量に対する使用量の上限の割合（0より大きく1以下、例: 0.8、0の場合は使用量では削除しない）
+	Interval   string  `json:"interval,omitempty"`    // 確認の間隔（time.ParseDurationの形式、空の場合は1h）
+}
+
+// HTTPServer HTTPサーバーの設定
+// 証明書ファイルとautocertはどちらか一方のみ指定できる
+type HTTPServer struct {
+	CertFile         string   `json:"cert_file,omitempty"`          // TLSの証明書ファイルのパス（key_fileと一緒に指定する）
+	KeyFile          string   `json:"key_file,omitempty"`           // TLSの秘密鍵ファイルのパス（cert_fileと一緒に指定する）
+	AutocertDomains  []string `json:"autocert_domains,omitempty"`   // Let's Encryptから証明書を自動で取得するドメイン
+	AutocertCacheDir string   `json:"autocert_cache_dir,omitempty"` // 自動で取得した証明書の保存先（空の場合は状態のディレクトリのautocert）
+	TrustedProxies   []string `json:"trusted_proxies,omitempty"`    // X-Forwarded-Forを信頼するリバースプロキシのIPアドレスまたはCIDR
+}
+
+// DependencyGate 依存する外部サービスによる受付の制御の設定
+type DependencyGate struct {
+	Disabled         bool   `json:"disabled,omitempty"`          // 受付を制御しない
+	FailureThreshold int    `json:"failure_threshold,omitempty"` // 受付を止めるまでの画像の作成の連続した失敗の回数（0の場合は3）
+	ProbeInterval    string `json:"probe_interval,omitempty"`    // 確認に失敗した場合に再び確認するまでの間隔（time.ParseDurationの形式、空の場合は30s）
+	MaxBacklog       int    `json:"max_backlog,omitempty"`       // 受付を止めている間に保留するメッセージの上限（0の場合は100）
+}
+
+// MisskeyInstance Misskeyボットで接続するインスタンスとアカウントの設定
+// 返信方針などのインスタンスごとに指定しない設定は環境変数の値を共通で使う
+type MisskeyInstance struct {
+	Name             string     `json:"name,omitempty"`              // ログとジョブキューのファイル名で区別する名前（空の場合はdomain）
+	Domain           string     `json:"domain"`                      // インスタンスのドメイン
+	Token            string     `json:"token,omitempty"`             // APIトークン（token_envとどちらかが必須）
+	TokenEnv         string     `json:"token_env,omitempty"`         // APIトークンを読み込む環境変数名（設定ファイルにトークンを書かない場合）
+	RateLimit        *RateLimit `json:"rate_limit,omitempty"`        // 送信者ごとのコマンドの実行回数の制限（未設定の場合は全体のrate_limit）
+	RequestInterval  string     `json:"request_interval,omitempty"`  // APIリクエストの最短の間隔（time.ParseDurationの形式、空の場合は制限しない）
+	Commands         []string   `json:"commands,omitempty"`          // 受け付けるコマンド名（空の場合は全て、管理者向けのコマンドは常に受け付ける）
+	TimelineChannels string     `json:"timeline_channels,omitempty"` // 応答するタイムラインチャンネル（MISSKEY_TIMELINE_CHANNELSの書式、空の場合は環境変数の値）
+}
+
+// ResolveToken APIトークンを返す（tokenが空の場合はtoken_envの環境変数から読み込む）
+func (m *MisskeyInstance) ResolveToken(getenv func(key string) string) string {
+	if m.Token != "" || m.TokenEnv == "" {
+		return m.Token
+	}
+	return getenv(m.TokenEnv)
+}
+
+// ParseRequestInterval APIリクエストの最短の間隔を解析する
+// 空の場合は0（制限しない）を返す
+func (m *MisskeyInstance) ParseRequestInterval() (time.Duration, error) {
+	if m.RequestInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(m.RequestInterval)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidMisskeyInstance, "%s: request_interval: %v", m.Name, err)
+	}
+	if interval < 0 {
+		return 0, errors.Wrapf(ErrInvalidMisskeyInstance, "%s: request_interval: %s", m.Name, m.RequestInterval)
+	}
+	return interval, nil
+}
+
+// LoadShedding 混雑時にメッセージを処理しない設定
+type LoadShedding struct {
+	Disabled      bool `json:"disabled,omitempty"`        // 上限を設けずに受信した順に1件ずつ処理する
+	MaxQueueDepth int  `json:"max_queue_depth,omitempty"` // 処理を待つメッセージの上限（0の場合は50、超えた場合は混雑のリアクションを付けて処理しない）
+	Workers       int  `json:"workers,omitempty"`         // 同時に処理するメッセージの数（0の場合は1）
+}
+
+// Load 設定ファイルを読み込む
+// パスが空の場合は空の設定を返す
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // 運用者が指定したパスを読み込む
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to os.ReadFile")
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return &config, nil
+}
+
+// LoadFromEnv 環境変数HATO_BOT_CONFIGで指定された設定ファイルを読み込む
+func LoadFromEnv() (*Config, error) {
+	return Load(os.Getenv(PathEnv))
+}
+
+// ParseCommandTimeouts コマンド名ごとの処理の制限時間を解析する
+// 制限時間は正の値でなければならない
+func (c *Config) ParseCommandTimeouts() (map[string]time.Duration, error) {
+	return parseTimeouts(c.CommandTimeouts)
+}
+
+// ParseHTTPTimeouts 外部サービスへのリクエストの種類ごとの制限時間を解析する
+// 制限時間は正の値でなければならない
+func (c *Config) ParseHTTPTimeouts() (map[string]time.Duration, error) {
+	return parseTimeouts(c.HTTPTimeouts)
+}
+
+// ParseAmeshStaleThreshold 雨雲レーダーのデータが古いとみなす経過時間を解析する
+// 空の場合は0（既定値）を返す
+func (c *Config) ParseAmeshStaleThreshold() (time.Duration, error) {
+	if c.AmeshStaleThreshold == "" {
+		return 0, nil
+	}
+	threshold, err := time.ParseDuration(c.AmeshStaleThreshold)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidStaleThreshold, "amesh_stale_threshold: %v", err)
+	}
+	if threshold <= 0 {
+		return 0, errors.Wrapf(ErrInvalidStaleThreshold, "amesh_stale_threshold: %s", c.AmeshStaleThreshold)
+	}
+	return threshold, nil
+}
+
+// ParseFeatures 無効にした機能の名前を名前順に返す
+// knownに含まれない機能を指定した場合はエラーを返す
+func (c *Config) ParseFeatures(known []string) ([]string, error) {
+	var disabled []string
+	for name, enabled := range c.Features {
+		if !slices.Contains(known, name) {
+			return nil, errors.Wrapf(ErrInvalidFeature, "features: %s", name)
+		}
+		if !enabled {
+			disabled = append(disabled, name)
+		}
+	}
+	slices.Sort(disabled)
+	return disabled, nil
+}
+
+// ParseLogLevel ログの出力レベルを解析する
+// 空の場合はslog.LevelInfoを返す
+func (c *Config) ParseLogLevel() (slog.Level, error) {
+	if c.LogLevel == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, errors.Wrapf(ErrInvalidLogLevel, "log_level: %s", c.LogLevel)
+	}
+	return level, nil
+}
+
+// ParseAliases コマンドの別名を検査して返す
+// 別名とコマンド名は空白を含まない空でない文字列でなければならない
+func (c *Config) ParseAliases() (map[string]string, error) {
+	aliases := make(map[string]string, len(c.Aliases))
+	for alias, name := range c.Aliases {
+		if !isCommandWord(alias) {
+			return nil, errors.Wrapf(ErrInvalidAlias, "alias: %q", alias)
+		}
+		if !isCommandWord(name) {
+			return nil, errors.Wrapf(ErrInvalidAlias, "%s: command: %q", alias, name)
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
+
+// ParseMisskeyInstances 接続するインスタンスの設定を検査し、名前を補って返す
+// ドメインとトークンの指定がない場合、名前が重複する場合やファイル名に使えない場合はエラーを返す
+func (c *Config) ParseMisskeyInstances() ([]MisskeyInstance, error) {
+	instances := make([]MisskeyInstance, 0, len(c.MisskeyInstances))
+	names := make(map[string]bool, len(c.MisskeyInstances))
+	for i, instance := range c.MisskeyInstances {
+		if instance.Domain == "" {
+			return nil, errors.Wrapf(ErrInvalidMisskeyInstance, "misskey_instances[%d]: domain is empty", i)
+		}
+		if instance.Name == "" {
+			instance.Name = instance.Domain
+		}
+		if !isCommandWord(instance.Name) || strings.ContainsAny(instance.Name, `/\`) {
+			return nil, errors.Wrapf(ErrInvalidMisskeyInstance, "misskey_instances[%d]: name: %q", i, instance.Name)
+		}
+		if names[instance.Name] {
+			return nil, errors.Wrapf(ErrInvalidMisskeyInstance, "misskey_instances[%d]: duplicate name: %s", i, instance.Name)
+		}
+		names[instance.Name] = true
+		if instance.Token == "" && instance.TokenEnv == "" {
+			return nil, errors.Wrapf(ErrInvalidMisskeyInstance, "%s: token or token_env is required", instance.Name)
+		}
+		for _, name := range instance.Commands {
+			if !isCommandWord(name) {
+				return nil, errors.Wrapf(ErrInvalidMisskeyInstance, "%s: command: %q", instance.Name, name)
+			}
+		}
+		if _, err := instance.ParseRequestInterval(); err != nil {
+			return nil, errors.Wrap(err, "Failed to instance.ParseRequestInterval")
+		}
+		if _, err := instance.RateLimit.ParseWindow(); err != nil {
+			return nil, errors.Wrapf(err, "%s: rate_limit", instance.Name)
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// isCommandWord コマンド名や別名として使える1語の文字列か
+func isCommandWord(word string) bool {
+	return word != "" && len(strings.Fields(word)) == 1 && strings.TrimSpace(word) == word
+}
+
+// parseTimeouts 名前ごとの制限時間を解析する
+func parseTimeouts(values map[string]string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(values))
+	for name, value := range values {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidTimeout, "%s: %v", name, err)
+		}
+		if timeout <= 0 {
+			return nil, errors.Wrapf(ErrInvalidTimeout, "%s: %s", name, value)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// ParseWindow 実行回数を数える期間を解析する
+// 制限が未設定の場合は0を返す
+func (r *RateLimit) ParseWindow() (time.Duration, error) {
+	if r == nil {
+		return 0, nil
+	}
+	if r.Count <= 0 {
+		return 0, errors.Wrapf(ErrInvalidRateLimit, "count: %d", r.Count)
+	}
+	window, err := time.ParseDuration(r.Window)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidRateLimit, "window: %v", err)
+	}
+	if window <= 0 {
+		return 0, errors.Wrapf(ErrInvalidRateLimit, "window: %s", r.Window)
+	}
+	return window, nil
+}
+
+// ParseRetention 履歴を保持する期間を解析する
+// 保持期間が空の場合は0（無期限）を返す
+func (h *History) ParseRetention() (time.Duration, error) {
+	if h == nil || h.Retention == "" {
+		return 0, nil
+	}
+	retention, err := time.ParseDuration(h.Retention)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidHistory, "retention: %v", err)
+	}
+	if retention <= 0 {
+		return 0, errors.Wrapf(ErrInvalidHistory, "retention: %s", h.Retention)
+	}
+	return retention, nil
+}
+
+// ParseRetention 処理したコマンドのIDを保持する期間を解析する
+// 保持期間が空の場合は0（既定値）を返す
+func (q *JobQueue) ParseRetention() (time.Duration, error) {
+	if q == nil || q.Retention == "" {
+		return 0, nil
+	}
+	retention, err := time.ParseDuration(q.Retention)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidJobQueue, "retention: %v", err)
+	}
+	if retention <= 0 {
+		return 0, errors.Wrapf(ErrInvalidJobQueue, "retention: %s", q.Retention)
+	}
+	return retention, nil
+}
+
+// Validate 処理を待つメッセージの上限と同時に処理する数を検査する
+func (l *LoadShedding) Validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.MaxQueueDepth < 0 {
+		return errors.Wrapf(ErrInvalidLoadShedding, "max_queue_depth: %d", l.MaxQueueDepth)
+	}
+	if l.Workers < 0 {
+		return errors.Wrapf(ErrInvalidLoadShedding, "workers: %d", l.Workers)
+	}
+	return nil
+}
+
+// Validate TLSの設定の組み合わせを検査する
+func (h *HTTPServer) Validate() error {
+	if h == nil {
+		return nil
+	}
+	if (h.CertFile == "") != (h.KeyFile == "") {
+		return errors.Wrap(ErrInvalidHTTPServer, "cert_file and key_file must be set together")
+	}
+	if h.CertFile != "" && 0 < len(h.AutocertDomains) {
+		return errors.Wrap(ErrInvalidHTTPServer, "cert_file and autocert_domains cannot be set together")
+	}
+	for _, domain := range h.AutocertDomains {
+		if strings.TrimSpace(domain) == "" {
+			return errors.Wrap(ErrInvalidHTTPServer, "autocert_domains: empty domain")
+		}
+	}
+	return nil
+}
+
+// ParseTrustedProxies X-Forwarded-Forを信頼するリバースプロキシを解析する
+// IPアドレスはそのアドレスのみのプレフィックスとして扱う
+func (h *HTTPServer) ParseTrustedProxies() ([]netip.Prefix, error) {
+	if h == nil {
+		return nil, nil
+	}
+	prefixes := make([]netip.Prefix, 0, len(h.TrustedProxies))
+	for _, proxy := range h.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidHTTPServer, "trusted_proxies: %v", err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidHTTPServer, "trusted_proxies: %v", err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ParseProbeInterval 確認の間隔を解析する
+// 未設定または間隔が空の場合は0（既定値）を返す
+func (d *DependencyGate) ParseProbeInterval() (time.Duration, error) {
+	if d == nil {
+		return 0, nil
+	}
+	if d.FailureThreshold < 0 {
+		return 0, errors.Wrapf(ErrInvalidDependencyGate, "failure_threshold: %d", d.FailureThreshold)
+	}
+	if d.MaxBacklog < 0 {
+		return 0, errors.Wrapf(ErrInvalidDependencyGate, "max_backlog: %d", d.MaxBacklog)
+	}
+	if d.ProbeInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(d.ProbeInterval)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidDependencyGate, "probe_interval: %v", err)
+	}
+	if interval <= 0 {
+		return 0, errors.Wrapf(ErrInvalidDependencyGate, "probe_interval: %s", d.ProbeInterval)
+	}
+	return interval, nil
+}
+
+// ParseRetention ファイルを残す期間を解析し、使用量の上限の割合を検査する
+// 期間が空の場合は0（期間では削除しない）を返す
+func (d *DrivePrune) ParseRetention() (time.Duration, error) {
+	if d == nil {
+		return 0, nil
+	}
+	if d.UsageLimit < 0 || 1 < d.UsageLimit {
+		return 0, errors.Wrapf(ErrInvalidDrivePrune, "usage_limit: %v", d.UsageLimit)
+	}
+	if d.Retention == "" {
+		if d.UsageLimit == 0 {
+			return 0, errors.Wrap(ErrInvalidDrivePrune, "retention or usage_limit must be set")
+		}
+		return 0, nil
+	}
+	retention, err := time.ParseDuration(d.Retention)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidDrivePrune, "retention: %v", err)
+	}
+	if retention <= 0 {
+		return 0, errors.Wrapf(ErrInvalidDrivePrune, "retention: %s", d.Retention)
+	}
+	return retention, nil
+}
+
+// ParseInterval 確認の間隔を解析する
+// 未設定または間隔が空の場合は0（既定値）を返す
+func (d *DrivePrune) ParseInterval() (time.Duration, error) {
+	if d == nil || d.Interval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(d.Interval)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidDrivePrune, "interval: %v", err)
+	}
+	if interval <= 0 {
+		return 0, errors.Wrapf(ErrInvalidDrivePrune, "interval: %s", d.Interval)
+	}
+	return interval, nil
+}
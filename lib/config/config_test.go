@@ -0,0 +1,818 @@
+package config_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/config"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *string // nilの場合はファイルを作成しない
+		emptyPath   bool
+		expected    *config.Config
+		expectError bool
+	}{
+		{
+			name:      "パスが空の場合は空の設定",
+			emptyPath: true,
+			expected:  &config.Config{},
+		},
+		{
+			name:    "返信テンプレート",
+			content: new(`{"templates":{"reply.cw":"CW for {{.User}}"}}`),
+			expected: &config.Config{
+				Templates: map[string]string{"reply.cw": "CW for {{.User}}"},
+			},
+		},
+		{
+			name:     "実行モード",
+			content:  new(`{"mode":"serve"}`),
+			expected: &config.Config{Mode: "serve"},
+		},
+		{
+			name:    "通知先",
+			content: new(`{"notifiers":[{"name":"ops","type":"slack","url":"https://hooks.slack.com/services/T/B/X"}]}`),
+			expected: &config.Config{
+				Notifiers: []config.Notifier{{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/T/B/X"}},
+			},
+		},
+		{
+			name:    "地震情報の自動投稿",
+			content: new(`{"earthquake":{"min_intensity":"5弱","map":true,"visibility":"public"}}`),
+			expected: &config.Config{
+				Earthquake: &config.Earthquake{MinIntensity: "5弱", Map: true, Visibility: "public"},
+			},
+		},
+		{
+			name:        "ファイルが存在しない",
+			content:     nil,
+			expectError: true,
+		},
+		{
+			name:        "JSONが不正",
+			content:     new(`{"templates":`),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "config.json")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.emptyPath {
+				path = ""
+			}
+
+			result, err := config.Load(path)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Load() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("Load() diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigParseAliases(t *testing.T) {
+	tests := []struct {
+		name          string
+		aliases       map[string]string
+		expected      map[string]string
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			aliases:  nil,
+			expected: map[string]string{},
+		},
+		{
+			name:     "日本語の別名",
+			aliases:  map[string]string{"雨雲": "amesh", "あめっしゅ": "amesh", "地震": "eq"},
+			expected: map[string]string{"雨雲": "amesh", "あめっしゅ": "amesh", "地震": "eq"},
+		},
+		{
+			name:          "空の別名",
+			aliases:       map[string]string{"": "amesh"},
+			expectedError: config.ErrInvalidAlias,
+		},
+		{
+			name:          "空白を含む別名",
+			aliases:       map[string]string{"雨 雲": "amesh"},
+			expectedError: config.ErrInvalidAlias,
+		},
+		{
+			name:          "空のコマンド名",
+			aliases:       map[string]string{"雨雲": ""},
+			expectedError: config.ErrInvalidAlias,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.Config{Aliases: tt.aliases}
+			result, err := cfg.ParseAliases()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseAliases() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("ParseAliases() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigParseCommandTimeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeouts      map[string]string
+		expected      map[string]time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			timeouts: nil,
+			expected: map[string]time.Duration{},
+		},
+		{
+			name:     "コマンドごとの制限時間",
+			timeouts: map[string]string{"amesh": "30s", "amedas": "1m30s"},
+			expected: map[string]time.Duration{"amesh": 30 * time.Second, "amedas": 90 * time.Second},
+		},
+		{
+			name:          "解析できない値",
+			timeouts:      map[string]string{"amesh": "30"},
+			expectedError: config.ErrInvalidTimeout,
+		},
+		{
+			name:          "0以下の値",
+			timeouts:      map[string]string{"amesh": "0s"},
+			expectedError: config.ErrInvalidTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.Config{CommandTimeouts: tt.timeouts}
+			result, err := cfg.ParseCommandTimeouts()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseCommandTimeouts() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("ParseCommandTimeouts() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigParseHTTPTimeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeouts      map[string]string
+		expected      map[string]time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			timeouts: nil,
+			expected: map[string]time.Duration{},
+		},
+		{
+			name:     "種類ごとの制限時間",
+			timeouts: map[string]string{"tiles": "20s", "upload": "2m"},
+			expected: map[string]time.Duration{"tiles": 20 * time.Second, "upload": 2 * time.Minute},
+		},
+		{
+			name:          "0以下の値",
+			timeouts:      map[string]string{"geocoder": "-1s"},
+			expectedError: config.ErrInvalidTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.Config{HTTPTimeouts: tt.timeouts}
+			result, err := cfg.ParseHTTPTimeouts()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseHTTPTimeouts() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("ParseHTTPTimeouts() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRateLimitParseWindow(t *testing.T) {
+	tests := []struct {
+		name          string
+		rateLimit     *config.RateLimit
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:      "設定なし",
+			rateLimit: nil,
+			expected:  0,
+		},
+		{
+			name:      "期間の解析",
+			rateLimit: &config.RateLimit{Count: 5, Window: "1m"},
+			expected:  time.Minute,
+		},
+		{
+			name:          "0以下の回数",
+			rateLimit:     &config.RateLimit{Count: 0, Window: "1m"},
+			expectedError: config.ErrInvalidRateLimit,
+		},
+		{
+			name:          "解析できない期間",
+			rateLimit:     &config.RateLimit{Count: 5, Window: "1"},
+			expectedError: config.ErrInvalidRateLimit,
+		},
+		{
+			name:          "0以下の期間",
+			rateLimit:     &config.RateLimit{Count: 5, Window: "0s"},
+			expectedError: config.ErrInvalidRateLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.rateLimit.ParseWindow()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseWindow() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseWindow() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHistoryParseRetention(t *testing.T) {
+	tests := []struct {
+		name          string
+		history       *config.History
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			history:  nil,
+			expected: 0,
+		},
+		{
+			name:     "保持期間が空の場合は無期限",
+			history:  &config.History{Path: "history.jsonl"},
+			expected: 0,
+		},
+		{
+			name:     "保持期間の解析",
+			history:  &config.History{Path: "history.jsonl", Retention: "720h"},
+			expected: 720 * time.Hour,
+		},
+		{
+			name:          "解析できない保持期間",
+			history:       &config.History{Path: "history.jsonl", Retention: "30d"},
+			expectedError: config.ErrInvalidHistory,
+		},
+		{
+			name:          "0以下の保持期間",
+			history:       &config.History{Path: "history.jsonl", Retention: "-1h"},
+			expectedError: config.ErrInvalidHistory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.history.ParseRetention()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseRetention() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseRetention() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJobQueueParseRetention(t *testing.T) {
+	tests := []struct {
+		name          string
+		queue         *config.JobQueue
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "保持期間が空の場合は既定値",
+			queue:    &config.JobQueue{},
+			expected: 0,
+		},
+		{
+			name:     "保持期間の解析",
+			queue:    &config.JobQueue{Retention: "48h"},
+			expected: 48 * time.Hour,
+		},
+		{
+			name:          "0以下の保持期間",
+			queue:         &config.JobQueue{Retention: "0s"},
+			expectedError: config.ErrInvalidJobQueue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.queue.ParseRetention()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseRetention() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseRetention() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadSheddingValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		shedding      *config.LoadShedding
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			shedding: nil,
+		},
+		{
+			name:     "上限と同時に処理する数",
+			shedding: &config.LoadShedding{MaxQueueDepth: 20, Workers: 2},
+		},
+		{
+			name:          "負の上限",
+			shedding:      &config.LoadShedding{MaxQueueDepth: -1},
+			expectedError: config.ErrInvalidLoadShedding,
+		},
+		{
+			name:          "負の同時に処理する数",
+			shedding:      &config.LoadShedding{Workers: -1},
+			expectedError: config.ErrInvalidLoadShedding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.shedding.Validate(); !errors.Is(err, tt.expectedError) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestConfigParseFeatures(t *testing.T) {
+	known := []string{"amesh", "translate", "earthquake"}
+	tests := []struct {
+		name          string
+		features      map[string]bool
+		expected      []string
+		expectedError error
+	}{
+		{name: "設定なし"},
+		{
+			name:     "falseの機能のみ無効",
+			features: map[string]bool{"translate": false, "earthquake": false, "amesh": true},
+			expected: []string{"earthquake", "translate"},
+		},
+		{
+			name:          "存在しない機能",
+			features:      map[string]bool{"weather": false},
+			expectedError: config.ErrInvalidFeature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := (&config.Config{Features: tt.features}).ParseFeatures(known)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseFeatures() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("ParseFeatures() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name          string
+		logLevel      string
+		expected      slog.Level
+		expectedError error
+	}{
+		{name: "空の場合はinfo", expected: slog.LevelInfo},
+		{name: "debug", logLevel: "debug", expected: slog.LevelDebug},
+		{name: "大文字", logLevel: "WARN", expected: slog.LevelWarn},
+		{name: "存在しないレベル", logLevel: "verbose", expectedError: config.ErrInvalidLogLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := (&config.Config{LogLevel: tt.logLevel}).ParseLogLevel()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseLogLevel() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseLogLevel() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfigParseMisskeyInstances(t *testing.T) {
+	tests := []struct {
+		name          string
+		instances     []config.MisskeyInstance
+		expected      []config.MisskeyInstance
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			expected: []config.MisskeyInstance{},
+		},
+		{
+			name: "名前を省略した場合はドメイン",
+			instances: []config.MisskeyInstance{
+				{Domain: "misskey.example.com", Token: "token"},
+				{Name: "sub", Domain: "misskey.example.com", TokenEnv: "SUB_TOKEN", RequestInterval: "1s", Commands: []string{"amesh"}},
+			},
+			expected: []config.MisskeyInstance{
+				{Name: "misskey.example.com", Domain: "misskey.example.com", Token: "token"},
+				{Name: "sub", Domain: "misskey.example.com", TokenEnv: "SUB_TOKEN", RequestInterval: "1s", Commands: []string{"amesh"}},
+			},
+		},
+		{
+			name:          "ドメインがない",
+			instances:     []config.MisskeyInstance{{Token: "token"}},
+			expectedError: config.ErrInvalidMisskeyInstance,
+		},
+		{
+			name: "名前の重複",
+			instances: []config.MisskeyInstance{
+				{Domain: "misskey.example.com", Token: "token"},
+				{Name: "misskey.example.com", Domain: "misskey.example.net", Token: "token"},
+			},
+			expectedError: config.ErrInvalidMisskeyInstance,
+		},
+		{
+			name:          "ファイル名に使えない名前",
+			instances:     []config.MisskeyInstance{{Name: "../queue", Domain: "misskey.example.com", Token: "token"}},
+			expectedError: config.ErrInvalidMisskeyInstance,
+		},
+		{
+			name:          "トークンがない",
+			instances:     []config.MisskeyInstance{{Domain: "misskey.example.com"}},
+			expectedError: config.ErrInvalidMisskeyInstance,
+		},
+		{
+			name:          "空白を含むコマンド名",
+			instances:     []config.MisskeyInstance{{Domain: "misskey.example.com", Token: "token", Commands: []string{"amesh map"}}},
+			expectedError: config.ErrInvalidMisskeyInstance,
+		},
+		{
+			name:          "負のリクエストの間隔",
+			instances:     []config.MisskeyInstance{{Domain: "misskey.example.com", Token: "token", RequestInterval: "-1s"}},
+			expectedError: config.ErrInvalidMisskeyInstance,
+		},
+		{
+			name:          "不正な実行回数の制限",
+			instances:     []config.MisskeyInstance{{Domain: "misskey.example.com", Token: "token", RateLimit: &config.RateLimit{Window: "1m"}}},
+			expectedError: config.ErrInvalidRateLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.Config{MisskeyInstances: tt.instances}
+			result, err := cfg.ParseMisskeyInstances()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseMisskeyInstances() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("ParseMisskeyInstances() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigParseAmeshStaleThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *config.Config
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定なしの場合は既定値",
+			config:   &config.Config{},
+			expected: 0,
+		},
+		{
+			name:     "経過時間の解析",
+			config:   &config.Config{AmeshStaleThreshold: "30m"},
+			expected: 30 * time.Minute,
+		},
+		{
+			name:          "解析できない経過時間",
+			config:        &config.Config{AmeshStaleThreshold: "1d"},
+			expectedError: config.ErrInvalidStaleThreshold,
+		},
+		{
+			name:          "0以下の経過時間",
+			config:        &config.Config{AmeshStaleThreshold: "0s"},
+			expectedError: config.ErrInvalidStaleThreshold,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.config.ParseAmeshStaleThreshold()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseAmeshStaleThreshold() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseAmeshStaleThreshold() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPServerValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		httpServer    *config.HTTPServer
+		expectedError error
+	}{
+		{
+			name:       "設定なし",
+			httpServer: nil,
+		},
+		{
+			name:       "証明書ファイル",
+			httpServer: &config.HTTPServer{CertFile: "cert.pem", KeyFile: "key.pem"},
+		},
+		{
+			name:       "autocert",
+			httpServer: &config.HTTPServer{AutocertDomains: []string{"hato.example.com"}},
+		},
+		{
+			name:          "秘密鍵ファイルがない",
+			httpServer:    &config.HTTPServer{CertFile: "cert.pem"},
+			expectedError: config.ErrInvalidHTTPServer,
+		},
+		{
+			name:          "証明書ファイルとautocertの両方",
+			httpServer:    &config.HTTPServer{CertFile: "cert.pem", KeyFile: "key.pem", AutocertDomains: []string{"hato.example.com"}},
+			expectedError: config.ErrInvalidHTTPServer,
+		},
+		{
+			name:          "空のドメイン",
+			httpServer:    &config.HTTPServer{AutocertDomains: []string{" "}},
+			expectedError: config.ErrInvalidHTTPServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.httpServer.Validate(); !errors.Is(err, tt.expectedError) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestHTTPServerParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name          string
+		httpServer    *config.HTTPServer
+		expected      []string
+		expectedError error
+	}{
+		{
+			name:       "設定なし",
+			httpServer: nil,
+		},
+		{
+			name:       "IPアドレスとCIDR",
+			httpServer: &config.HTTPServer{TrustedProxies: []string{"10.0.0.1", "172.16.5.4/12", "::ffff:192.168.0.1", "fd00::/8"}},
+			expected:   []string{"10.0.0.1/32", "172.16.0.0/12", "192.168.0.1/32", "fd00::/8"},
+		},
+		{
+			name:          "解析できないアドレス",
+			httpServer:    &config.HTTPServer{TrustedProxies: []string{"proxy.local"}},
+			expectedError: config.ErrInvalidHTTPServer,
+		},
+		{
+			name:          "解析できないCIDR",
+			httpServer:    &config.HTTPServer{TrustedProxies: []string{"10.0.0.0/33"}},
+			expectedError: config.ErrInvalidHTTPServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.httpServer.ParseTrustedProxies()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseTrustedProxies() error = %v, want %v", err, tt.expectedError)
+			}
+			var actual []string
+			for _, prefix := range result {
+				actual = append(actual, prefix.String())
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("ParseTrustedProxies() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDependencyGateParseProbeInterval(t *testing.T) {
+	tests := []struct {
+		name          string
+		gate          *config.DependencyGate
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			gate:     nil,
+			expected: 0,
+		},
+		{
+			name:     "間隔が空の場合は既定値",
+			gate:     &config.DependencyGate{FailureThreshold: 5},
+			expected: 0,
+		},
+		{
+			name:     "間隔の解析",
+			gate:     &config.DependencyGate{ProbeInterval: "1m"},
+			expected: time.Minute,
+		},
+		{
+			name:          "解析できない間隔",
+			gate:          &config.DependencyGate{ProbeInterval: "1 minute"},
+			expectedError: config.ErrInvalidDependencyGate,
+		},
+		{
+			name:          "0以下の間隔",
+			gate:          &config.DependencyGate{ProbeInterval: "0s"},
+			expectedError: config.ErrInvalidDependencyGate,
+		},
+		{
+			name:          "負の失敗の回数",
+			gate:          &config.DependencyGate{FailureThreshold: -1},
+			expectedError: config.ErrInvalidDependencyGate,
+		},
+		{
+			name:          "負の保留の上限",
+			gate:          &config.DependencyGate{MaxBacklog: -1},
+			expectedError: config.ErrInvalidDependencyGate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.gate.ParseProbeInterval()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseProbeInterval() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseProbeInterval() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDrivePruneParseRetention(t *testing.T) {
+	tests := []struct {
+		name          string
+		prune         *config.DrivePrune
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定なし",
+			prune:    nil,
+			expected: 0,
+		},
+		{
+			name:     "期間の解析",
+			prune:    &config.DrivePrune{Retention: "720h"},
+			expected: 720 * time.Hour,
+		},
+		{
+			name:     "使用量の上限のみ",
+			prune:    &config.DrivePrune{UsageLimit: 0.8},
+			expected: 0,
+		},
+		{
+			name:          "期間も使用量の上限もない",
+			prune:         &config.DrivePrune{Interval: "1h"},
+			expectedError: config.ErrInvalidDrivePrune,
+		},
+		{
+			name:          "解析できない期間",
+			prune:         &config.DrivePrune{Retention: "30d"},
+			expectedError: config.ErrInvalidDrivePrune,
+		},
+		{
+			name:          "0以下の期間",
+			prune:         &config.DrivePrune{Retention: "0s"},
+			expectedError: config.ErrInvalidDrivePrune,
+		},
+		{
+			name:          "1を超える使用量の上限",
+			prune:         &config.DrivePrune{UsageLimit: 1.5},
+			expectedError: config.ErrInvalidDrivePrune,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.prune.ParseRetention()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseRetention() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseRetention() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDrivePruneParseInterval(t *testing.T) {
+	tests := []struct {
+		name          string
+		prune         *config.DrivePrune
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "間隔が空の場合は既定値",
+			prune:    &config.DrivePrune{Retention: "720h"},
+			expected: 0,
+		},
+		{
+			name:     "間隔の解析",
+			prune:    &config.DrivePrune{Interval: "6h"},
+			expected: 6 * time.Hour,
+		},
+		{
+			name:          "0以下の間隔",
+			prune:         &config.DrivePrune{Interval: "-1h"},
+			expectedError: config.ErrInvalidDrivePrune,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.prune.ParseInterval()
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseInterval() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseInterval() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package convert
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/requestid"
+)
+
+// Command 単位や通貨を変換して返信するconvertコマンド
+// bot.Commandを実装し、bot.DefaultCommandsに追加して使う
+type Command struct {
+	Rates RateProvider // 通貨の変換に使う為替レート（nilの場合はDefaultRates）
+}
+
+// Name コマンド名
+func (c *Command) Name() string {
+	return "convert"
+}
+
+// Match 本文がconvertコマンドかを返す
+func (c *Command) Match(text string) bool {
+	return ParseConvertCommand(text).IsConvert
+}
+
+// ErrorKey 実行に失敗した場合に返信するメッセージのキーを返す
+func (c *Command) ErrorKey(err error) i18n.Key {
+	switch {
+	case errors.Is(err, ErrInvalidFormat):
+		return i18n.KeyErrorConvertUsage
+	case errors.Is(err, ErrUnknownUnit):
+		return i18n.KeyErrorConvertUnknownUnit
+	case errors.Is(err, ErrIncompatibleUnits):
+		return i18n.KeyErrorConvertIncompatible
+	default:
+		return i18n.KeyErrorConvertCommand
+	}
+}
+
+// Execute 値を変換し、返信を作成する
+func (c *Command) Execute(ctx context.Context, req *bot.Request) (*bot.OutgoingReply, error) {
+	if req == nil || req.Message == nil || req.TemplateData == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	query, err := ParseQuery(ParseConvertCommand(req.Message.Text).Args)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ParseQuery")
+	}
+
+	rates := c.Rates
+	if rates == nil {
+		rates = DefaultRates
+	}
+	result, err := Convert(ctx, &ConvertParams{Query: query, Rates: rates})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Convert")
+	}
+
+	templateData := req.TemplateData
+	templateData.Amount = FormatNumber(result.Amount)
+	templateData.FromUnit = result.From
+	templateData.Converted = FormatNumber(result.Converted)
+	templateData.ToUnit = result.To
+
+	requestid.Logf(ctx, "Successfully converted %s to %s", result.From, result.To)
+	return &bot.OutgoingReply{
+		Command: c.Name(),
+		Text:    req.Templates.Render(i18n.KeyConvertSuccess, templateData),
+	}, nil
+}
@@ -0,0 +1,119 @@
+package convert_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/convert"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestCommandMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "メンション付き", text: "@hato convert 5 mile km", expected: true},
+		{name: "引数なし", text: "@hato convert", expected: true},
+		{name: "別のコマンド", text: "amedas 東京", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &convert.Command{}
+			if got := command.Match(tt.text); got != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCommandExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           *bot.Request
+		expected      string
+		expectedError error
+		expectedKey   i18n.Key
+	}{
+		{
+			name: "通貨",
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato convert 100 USD JPY"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleJa},
+			},
+			expected: i18n.Message(i18n.LocaleJa, i18n.KeyConvertSuccess, "100", "USD", "15000", "JPY"),
+		},
+		{
+			name: "単位",
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato convert 5 mile km"},
+				TemplateData: &i18n.TemplateData{Locale: i18n.LocaleEn},
+			},
+			expected: i18n.Message(i18n.LocaleEn, i18n.KeyConvertSuccess, "5", "mi", "8.05", "km"),
+		},
+		{
+			name: "書式が正しくない",
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato convert"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedError: convert.ErrInvalidFormat,
+			expectedKey:   i18n.KeyErrorConvertUsage,
+		},
+		{
+			name: "知らない単位",
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato convert 1 parsec km"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedError: convert.ErrUnknownUnit,
+			expectedKey:   i18n.KeyErrorConvertUnknownUnit,
+		},
+		{
+			name: "種類の違う単位",
+			req: &bot.Request{
+				Message:      &bot.IncomingMessage{Text: "@hato convert 1 kg km"},
+				TemplateData: &i18n.TemplateData{},
+			},
+			expectedError: convert.ErrIncompatibleUnits,
+			expectedKey:   i18n.KeyErrorConvertIncompatible,
+		},
+		{
+			name:          "nilリクエスト",
+			req:           nil,
+			expectedError: lib.ErrParamsNil,
+			expectedKey:   i18n.KeyErrorConvertCommand,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := &convert.Command{Rates: &stubRates{rates: map[string]map[string]float64{
+				"USD": {"JPY": 150},
+			}}}
+			reply, err := command.Execute(t.Context(), tt.req)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				if got := command.ErrorKey(err); got != tt.expectedKey {
+					t.Errorf("ErrorKey() = %q, want %q", got, tt.expectedKey)
+				}
+				return
+			}
+			if reply.Command != "convert" {
+				t.Errorf("reply.Command = %q, want convert", reply.Command)
+			}
+			if reply.Text != tt.expected {
+				t.Errorf("reply.Text = %q, want %q", reply.Text, tt.expected)
+			}
+		})
+	}
+}
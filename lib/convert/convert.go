@@ -0,0 +1,267 @@
+package convert
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+)
+
+var (
+	// ErrInvalidFormat convertコマンドの書式が正しくないことを表すエラー
+	ErrInvalidFormat = errors.New("invalid convert format")
+	// ErrUnknownUnit 単位表にも為替レートにもない単位を指定したことを表すエラー
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrIncompatibleUnits 種類の異なる単位の間で変換しようとしたことを表すエラー
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+// dimension 単位の種類
+type dimension string
+
+const (
+	dimensionLength      dimension = "length"      // 長さ（基準はm）
+	dimensionMass        dimension = "mass"        // 質量（基準はkg）
+	dimensionVolume      dimension = "volume"      // 体積（基準はL）
+	dimensionTemperature dimension = "temperature" // 温度（基準はK）
+	dimensionSpeed       dimension = "speed"       // 速さ（基準はm/s）
+)
+
+// unit 単位の定義
+// 基準の単位での値 = 値 × factor + offset
+type unit struct {
+	symbol    string    // 返信に表示する記号
+	dimension dimension // 単位の種類
+	factor    float64   // 基準の単位への倍率
+	offset    float64   // 基準の単位への加算値（温度のみ）
+}
+
+// unitDefinition 単位の定義と別名（小文字）
+type unitDefinition struct {
+	unit    unit
+	aliases []string
+}
+
+// units 単位の別名（小文字）と定義の対応表
+var units = newUnitTable([]unitDefinition{
+	{unit{symbol: "m", dimension: dimensionLength, factor: 1}, []string{"m", "meter", "meters", "metre", "metres"}},
+	{unit{symbol: "km", dimension: dimensionLength, factor: 1000}, []string{"km", "kilometer", "kilometers"}},
+	{unit{symbol: "cm", dimension: dimensionLength, factor: 0.01}, []string{"cm", "centimeter", "centimeters"}},
+	{unit{symbol: "mm", dimension: dimensionLength, factor: 0.001}, []string{"mm", "millimeter", "millimeters"}},
+	{unit{symbol: "mi", dimension: dimensionLength, factor: 1609.344}, []string{"mi", "mile", "miles"}},
+	{unit{symbol: "yd", dimension: dimensionLength, factor: 0.9144}, []string{"yd", "yard", "yards"}},
+	{unit{symbol: "ft", dimension: dimensionLength, factor: 0.3048}, []string{"ft", "foot", "feet"}},
+	{unit{symbol: "in", dimension: dimensionLength, factor: 0.0254}, []string{"in", "inch", "inches"}},
+	{unit{symbol: "nmi", dimension: dimensionLength, factor: 1852}, []string{"nmi"}},
+	{unit{symbol: "尺", dimension: dimensionLength, factor: 10.0 / 33}, []string{"尺"}},
+	{unit{symbol: "寸", dimension: dimensionLength, factor: 1.0 / 33}, []string{"寸"}},
+	{unit{symbol: "kg", dimension: dimensionMass, factor: 1}, []string{"kg", "kilogram", "kilograms"}},
+	{unit{symbol: "g", dimension: dimensionMass, factor: 0.001}, []string{"g", "gram", "grams"}},
+	{unit{symbol: "mg", dimension: dimensionMass, factor: 0.000001}, []string{"mg", "milligram", "milligrams"}},
+	{unit{symbol: "t", dimension: dimensionMass, factor: 1000}, []string{"t", "tonne", "tonnes"}},
+	{unit{symbol: "lb", dimension: dimensionMass, factor: 0.45359237}, []string{"lb", "lbs", "pound", "pounds"}},
+	{unit{symbol: "oz", dimension: dimensionMass, factor: 0.028349523125}, []string{"oz", "ounce", "ounces"}},
+	{unit{symbol: "L", dimension: dimensionVolume, factor: 1}, []string{"l", "liter", "liters", "litre", "litres"}},
+	{unit{symbol: "mL", dimension: dimensionVolume, factor: 0.001}, []string{"ml", "milliliter", "milliliters"}},
+	{unit{symbol: "gal", dimension: dimensionVolume, factor: 3.785411784}, []string{"gal", "gallon", "gallons"}},
+	{unit{symbol: "K", dimension: dimensionTemperature, factor: 1}, []string{"k", "kelvin"}},
+	{unit{symbol: "℃", dimension: dimensionTemperature, factor: 1, offset: 273.15}, []string{"c", "℃", "°c", "celsius"}},
+	{unit{symbol: "℉", dimension: dimensionTemperature, factor: 5.0 / 9, offset: 273.15 - 32*5.0/9}, []string{"f", "℉", "°f", "fahrenheit"}},
+	{unit{symbol: "m/s", dimension: dimensionSpeed, factor: 1}, []string{"m/s"}},
+	{unit{symbol: "km/h", dimension: dimensionSpeed, factor: 1 / 3.6}, []string{"km/h", "kmh", "kph"}},
+	{unit{symbol: "mph", dimension: dimensionSpeed, factor: 0.44704}, []string{"mph"}},
+	{unit{symbol: "kn", dimension: dimensionSpeed, factor: 1852.0 / 3600}, []string{"kn", "knot", "knots"}},
+})
+
+// separators 変換元と変換先の単位の間に書ける語
+var separators = []string{"to", "in", "->", "→"}
+
+// Query 変換の指定
+type Query struct {
+	Amount float64 // 変換する値
+	From   string  // 変換元の単位または通貨コード
+	To     string  // 変換先の単位または通貨コード
+}
+
+// ParseConvertCommandResult convertコマンドの解析結果を表す構造体
+type ParseConvertCommandResult struct {
+	Args      string
+	IsConvert bool
+}
+
+// ConvertParams 変換のリクエスト構造体
+type ConvertParams struct {
+	Query *Query       // 変換の指定
+	Rates RateProvider // 通貨の変換に使う為替レート
+}
+
+// Result 変換結果
+type Result struct {
+	Amount    float64 // 変換した値
+	From      string  // 変換元の単位の記号または通貨コード
+	To        string  // 変換先の単位の記号または通貨コード
+	Converted float64 // 変換後の値
+}
+
+// newUnitTable 単位の定義から別名をキーとする対応表を作成する
+func newUnitTable(definitions []unitDefinition) map[string]*unit {
+	table := make(map[string]*unit)
+	for _, definition := range definitions {
+		for _, alias := range definition.aliases {
+			table[alias] = &definition.unit
+		}
+	}
+	return table
+}
+
+// ParseConvertCommand 「convert 100 USD JPY」の形式のコマンドを解析する
+func ParseConvertCommand(text string) ParseConvertCommandResult {
+	parsed := lib.ParseCommand(text, "convert")
+	return ParseConvertCommandResult{Args: parsed.Args, IsConvert: parsed.Matched}
+}
+
+// ParseQuery convertコマンドの引数を解析する
+// 「100 USD JPY」「5 mile to km」「5km mi」の形式に対応する
+func ParseQuery(args string) (*Query, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return nil, ErrInvalidFormat
+	}
+
+	// 数値と単位が続けて書かれている場合は分割する（5km）
+	split := splitAmount(fields[0])
+	amountText, from := split.amount, split.unit
+	rest := fields[1:]
+	if from == "" {
+		if len(rest) == 0 {
+			return nil, errors.Wrapf(ErrInvalidFormat, "args: %s", args)
+		}
+		from, rest = rest[0], rest[1:]
+	}
+	if 1 < len(rest) && isSeparator(rest[0]) {
+		rest = rest[1:]
+	}
+	if len(rest) != 1 {
+		return nil, errors.Wrapf(ErrInvalidFormat, "args: %s", args)
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(amountText, ",", ""), 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, errors.Wrapf(ErrInvalidFormat, "amount: %s", amountText)
+	}
+	return &Query{Amount: amount, From: from, To: rest[0]}, nil
+}
+
+// splitAmountResult 数値と単位の分割結果
+type splitAmountResult struct {
+	amount string // 数値部分
+	unit   string // 数値に続く単位（続かない場合は空）
+}
+
+// splitAmount 先頭の数値部分と続く単位を分割する
+func splitAmount(s string) splitAmountResult {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.,+-", r)
+	})
+	if i <= 0 {
+		return splitAmountResult{amount: s}
+	}
+	return splitAmountResult{amount: s[:i], unit: s[i:]}
+}
+
+// isSeparator 単位の間に書ける語かを返す
+func isSeparator(s string) bool {
+	for _, separator := range separators {
+		if strings.EqualFold(s, separator) {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert 値を単位表または為替レートで変換する
+// 両方が単位表にあれば単位を、両方が通貨コードの形式であれば通貨を変換する
+func Convert(ctx context.Context, params *ConvertParams) (*Result, error) {
+	if params == nil || params.Query == nil {
+		return nil, lib.ErrParamsNil
+	}
+	query := params.Query
+
+	from, fromOK := units[strings.ToLower(query.From)]
+	to, toOK := units[strings.ToLower(query.To)]
+	switch {
+	case fromOK && toOK:
+		if from.dimension != to.dimension {
+			return nil, errors.Wrapf(ErrIncompatibleUnits, "%s -> %s", from.symbol, to.symbol)
+		}
+		base := query.Amount*from.factor + from.offset
+		return &Result{
+			Amount:    query.Amount,
+			From:      from.symbol,
+			To:        to.symbol,
+			Converted: (base - to.offset) / to.factor,
+		}, nil
+	case isCurrencyCode(query.From) && isCurrencyCode(query.To):
+		return convertCurrency(ctx, params)
+	case (fromOK && isCurrencyCode(query.To)) || (toOK && isCurrencyCode(query.From)):
+		return nil, errors.Wrapf(ErrIncompatibleUnits, "%s -> %s", query.From, query.To)
+	default:
+		return nil, errors.Wrapf(ErrUnknownUnit, "%s -> %s", query.From, query.To)
+	}
+}
+
+// convertCurrency 為替レートで通貨を変換する
+func convertCurrency(ctx context.Context, params *ConvertParams) (*Result, error) {
+	if params.Rates == nil {
+		return nil, lib.ErrParamsNil
+	}
+	from := strings.ToUpper(params.Query.From)
+	to := strings.ToUpper(params.Query.To)
+
+	rates, err := params.Rates.Rates(ctx, from)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Rates")
+	}
+	rate, ok := rates[to]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownUnit, "currency: %s", to)
+	}
+	return &Result{
+		Amount:    params.Query.Amount,
+		From:      from,
+		To:        to,
+		Converted: params.Query.Amount * rate,
+	}, nil
+}
+
+// isCurrencyCode ISO 4217の通貨コードの形式（英字3文字）かを返す
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || 'z' < r) && (r < 'A' || 'Z' < r) {
+			return false
+		}
+	}
+	return true
+}
+
+// negligible 浮動小数点の誤差として0とみなす絶対値
+const negligible = 1e-9
+
+// FormatNumber 変換結果を表示用の文字列にする
+// 絶対値が1以上の場合は小数第2位まで、1未満の場合は有効数字4桁で丸める
+func FormatNumber(v float64) string {
+	if math.Abs(v) < negligible {
+		return "0"
+	}
+	if math.Abs(v) < 1 {
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,277 @@
+package convert_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/convert"
+)
+
+// stubRates 固定の為替レートを返すRateProvider
+type stubRates struct {
+	rates map[string]map[string]float64
+	calls int
+}
+
+func (s *stubRates) Rates(_ context.Context, base string) (map[string]float64, error) {
+	s.calls++
+	rates, ok := s.rates[base]
+	if !ok {
+		return nil, convert.ErrUnknownUnit
+	}
+	return rates, nil
+}
+
+func TestParseConvertCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected convert.ParseConvertCommandResult
+	}{
+		{
+			name:     "メンション付き",
+			text:     "@hato convert 100 USD JPY",
+			expected: convert.ParseConvertCommandResult{Args: "100 USD JPY", IsConvert: true},
+		},
+		{
+			name:     "引数なし",
+			text:     "@hato convert",
+			expected: convert.ParseConvertCommandResult{IsConvert: true},
+		},
+		{
+			name:     "別のコマンド",
+			text:     "@hato amesh 東京",
+			expected: convert.ParseConvertCommandResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, convert.ParseConvertCommand(tt.text)); diff != "" {
+				t.Errorf("ParseConvertCommand(%q) mismatch (-want +got):\n%s", tt.text, diff)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          string
+		expected      *convert.Query
+		expectedError error
+	}{
+		{
+			name:     "通貨",
+			args:     "100 USD JPY",
+			expected: &convert.Query{Amount: 100, From: "USD", To: "JPY"},
+		},
+		{
+			name:     "toで区切る",
+			args:     "5 mile to km",
+			expected: &convert.Query{Amount: 5, From: "mile", To: "km"},
+		},
+		{
+			name:     "数値と単位が続く",
+			args:     "5km mi",
+			expected: &convert.Query{Amount: 5, From: "km", To: "mi"},
+		},
+		{
+			name:     "桁区切りと負の値",
+			args:     "-1,000.5 ft in m",
+			expected: &convert.Query{Amount: -1000.5, From: "ft", To: "m"},
+		},
+		{
+			name:     "単位のinch",
+			args:     "12 in cm",
+			expected: &convert.Query{Amount: 12, From: "in", To: "cm"},
+		},
+		{
+			name:          "引数なし",
+			args:          "",
+			expectedError: convert.ErrInvalidFormat,
+		},
+		{
+			name:          "変換先がない",
+			args:          "100 USD",
+			expectedError: convert.ErrInvalidFormat,
+		},
+		{
+			name:          "数値でない",
+			args:          "abc USD JPY",
+			expectedError: convert.ErrInvalidFormat,
+		},
+		{
+			name:          "引数が多すぎる",
+			args:          "1 m km mi",
+			expectedError: convert.ErrInvalidFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			query, err := convert.ParseQuery(tt.args)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseQuery(%q) error = %v, want %v", tt.args, err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, query); diff != "" {
+				t.Errorf("ParseQuery(%q) mismatch (-want +got):\n%s", tt.args, diff)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rates := map[string]map[string]float64{
+		"USD": {"USD": 1, "JPY": 150.5, "EUR": 0.9},
+	}
+
+	tests := []struct {
+		name          string
+		query         *convert.Query
+		expected      string
+		expectedFrom  string
+		expectedTo    string
+		expectedError error
+	}{
+		{
+			name:         "通貨",
+			query:        &convert.Query{Amount: 100, From: "usd", To: "JPY"},
+			expected:     "15050",
+			expectedFrom: "USD",
+			expectedTo:   "JPY",
+		},
+		{
+			name:         "長さ",
+			query:        &convert.Query{Amount: 5, From: "mile", To: "km"},
+			expected:     "8.05",
+			expectedFrom: "mi",
+			expectedTo:   "km",
+		},
+		{
+			name:         "1未満の値",
+			query:        &convert.Query{Amount: 1, From: "ft", To: "m"},
+			expected:     "0.3048",
+			expectedFrom: "ft",
+			expectedTo:   "m",
+		},
+		{
+			name:         "温度",
+			query:        &convert.Query{Amount: 100, From: "C", To: "F"},
+			expected:     "212",
+			expectedFrom: "℃",
+			expectedTo:   "℉",
+		},
+		{
+			name:         "温度の0",
+			query:        &convert.Query{Amount: 32, From: "°F", To: "℃"},
+			expected:     "0",
+			expectedFrom: "℉",
+			expectedTo:   "℃",
+		},
+		{
+			name:         "質量",
+			query:        &convert.Query{Amount: 10, From: "lb", To: "kg"},
+			expected:     "4.54",
+			expectedFrom: "lb",
+			expectedTo:   "kg",
+		},
+		{
+			name:         "速さ",
+			query:        &convert.Query{Amount: 36, From: "km/h", To: "m/s"},
+			expected:     "10",
+			expectedFrom: "km/h",
+			expectedTo:   "m/s",
+		},
+		{
+			name:          "種類の違う単位",
+			query:         &convert.Query{Amount: 1, From: "kg", To: "km"},
+			expectedError: convert.ErrIncompatibleUnits,
+		},
+		{
+			name:          "単位と通貨",
+			query:         &convert.Query{Amount: 1, From: "km", To: "JPY"},
+			expectedError: convert.ErrIncompatibleUnits,
+		},
+		{
+			name:          "為替レートにない通貨",
+			query:         &convert.Query{Amount: 1, From: "USD", To: "XYZ"},
+			expectedError: convert.ErrUnknownUnit,
+		},
+		{
+			name:          "知らない単位",
+			query:         &convert.Query{Amount: 1, From: "parsec", To: "km"},
+			expectedError: convert.ErrUnknownUnit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := convert.Convert(t.Context(), &convert.ConvertParams{
+				Query: tt.query,
+				Rates: &stubRates{rates: rates},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Convert() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if got := convert.FormatNumber(result.Converted); got != tt.expected {
+				t.Errorf("Converted = %s, want %s", got, tt.expected)
+			}
+			if result.From != tt.expectedFrom || result.To != tt.expectedTo {
+				t.Errorf("units = %s -> %s, want %s -> %s", result.From, result.To, tt.expectedFrom, tt.expectedTo)
+			}
+		})
+	}
+}
+
+func TestConvertNilParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params *convert.ConvertParams
+	}{
+		{name: "nilパラメータ", params: nil},
+		{name: "為替レートなしで通貨を変換", params: &convert.ConvertParams{Query: &convert.Query{Amount: 1, From: "USD", To: "JPY"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := convert.Convert(t.Context(), tt.params); !errors.Is(err, lib.ErrParamsNil) {
+				t.Errorf("Convert() error = %v, want %v", err, lib.ErrParamsNil)
+			}
+		})
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		expected string
+	}{
+		{name: "整数", value: 100, expected: "100"},
+		{name: "小数第2位で丸める", value: 8.04672, expected: "8.05"},
+		{name: "1未満は有効数字4桁", value: 0.0066445, expected: "0.006645"},
+		{name: "負の値", value: -17.7777, expected: "-17.78"},
+		{name: "誤差は0", value: 5.684e-14, expected: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := convert.FormatNumber(tt.value); got != tt.expected {
+				t.Errorf("FormatNumber(%v) = %s, want %s", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
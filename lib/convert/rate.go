@@ -0,0 +1,142 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+// DefaultExchangeRateURL 為替レートAPI（ExchangeRate-API のOpen Access版）のURL
+const DefaultExchangeRateURL = "https://open.er-api.com"
+
+// exchangeRateSuccess 為替レートAPIが成功した場合のresult
+const exchangeRateSuccess = "success"
+
+// defaultClient クライアント未指定時に使うHTTPクライアント
+// 為替レートAPIが不調な場合はサーキットブレーカーで即座に失敗させる
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
+	Timeout:   30 * time.Second,
+}
+
+// DefaultRates 為替レートが未指定の場合に使う、1日ごとにキャッシュする為替レートAPI
+// エンジンを作り直してもキャッシュを共有するためパッケージで1つだけ持つ
+var DefaultRates = NewDailyCache(&DailyCacheSetting{
+	Provider: &ExchangeRateAPI{Client: defaultClient},
+})
+
+// RateProvider 為替レートを提供するインターフェース
+type RateProvider interface {
+	// Rates 基準の通貨コード（大文字）1単位あたりの各通貨の量を通貨コードごとに返す
+	Rates(ctx context.Context, base string) (map[string]float64, error)
+}
+
+// ExchangeRateAPI ExchangeRate-APIから為替レートを取得する
+type ExchangeRateAPI struct {
+	Client  *http.Client // HTTPクライアント
+	BaseURL string       // APIのURL（空の場合はDefaultExchangeRateURL）
+}
+
+// exchangeRateResponse 為替レートAPIのレスポンス
+type exchangeRateResponse struct {
+	Result    string             `json:"result"`
+	ErrorType string             `json:"error-type"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+// Rates 基準の通貨の最新の為替レートを取得する
+func (a *ExchangeRateAPI) Rates(ctx context.Context, base string) (rates map[string]float64, err error) {
+	if a.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+	baseURL := a.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultExchangeRateURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/v6/latest/"+url.PathEscape(base), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+
+	body, err := httpclient.ExecuteAndReadBody(a.Client, req, httpclient.DefaultMaxResponseBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
+	}
+
+	var response exchangeRateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	if response.Result != exchangeRateSuccess {
+		// 未対応の通貨コードの場合はunsupported-codeが返る
+		return nil, errors.Wrapf(ErrUnknownUnit, "currency: %s, error-type: %s", base, response.ErrorType)
+	}
+	return response.Rates, nil
+}
+
+// DailyCacheSetting 為替レートのキャッシュの設定
+type DailyCacheSetting struct {
+	Provider RateProvider     // キャッシュする為替レート
+	Now      func() time.Time // 現在時刻（nilの場合はtime.Now）
+}
+
+// DailyCache 基準の通貨ごとに為替レートを1日（UTC）ごとにキャッシュする
+// 為替レートAPIの更新は1日1回のため、同じ日の2回目以降の変換ではAPIを呼ばない
+type DailyCache struct {
+	provider RateProvider
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry キャッシュした為替レート
+type cacheEntry struct {
+	day   string             // 取得した日（UTC、YYYY-MM-DD）
+	rates map[string]float64 // 為替レート
+}
+
+// NewDailyCache 為替レートのキャッシュを作成する
+func NewDailyCache(setting *DailyCacheSetting) *DailyCache {
+	now := setting.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &DailyCache{
+		provider: setting.Provider,
+		now:      now,
+		entries:  map[string]*cacheEntry{},
+	}
+}
+
+// Rates 同じ日に取得した為替レートがあればそれを、なければ新しく取得して返す
+// 取得に失敗した場合はキャッシュしない
+func (c *DailyCache) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	if c.provider == nil {
+		return nil, lib.ErrParamsNil
+	}
+	day := c.now().UTC().Format(time.DateOnly)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[base]; ok && entry.day == day {
+		return entry.rates, nil
+	}
+
+	rates, err := c.provider.Rates(ctx, base)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Rates")
+	}
+	c.entries[base] = &cacheEntry{day: day, rates: rates}
+	return rates, nil
+}
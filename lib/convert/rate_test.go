@@ -0,0 +1,135 @@
+package convert_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/convert"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestExchangeRateAPIRates(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      httpclient.MockResponse
+		expected      map[string]float64
+		expectedError error
+	}{
+		{
+			name: "成功",
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"result":"success","base_code":"USD","rates":{"USD":1,"JPY":150.5}}`,
+			},
+			expected: map[string]float64{"USD": 1, "JPY": 150.5},
+		},
+		{
+			name: "未対応の通貨コード",
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"result":"error","error-type":"unsupported-code"}`,
+			},
+			expectedError: convert.ErrUnknownUnit,
+		},
+		{
+			name:          "APIのエラー",
+			response:      httpclient.MockResponse{StatusCode: http.StatusServiceUnavailable, Body: `{}`},
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Fallback: tt.response})
+			api := &convert.ExchangeRateAPI{Client: transport.Client()}
+
+			rates, err := api.Rates(t.Context(), "USD")
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Rates() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, rates); diff != "" {
+				t.Errorf("Rates() mismatch (-want +got):\n%s", diff)
+			}
+
+			requests := transport.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+			if expectedURL := convert.DefaultExchangeRateURL + "/v6/latest/USD"; requests[0].URL != expectedURL {
+				t.Errorf("URL = %q, want %q", requests[0].URL, expectedURL)
+			}
+		})
+	}
+}
+
+func TestDailyCacheRates(t *testing.T) {
+	day := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		times         []time.Time
+		bases         []string
+		expectedCalls int
+	}{
+		{
+			name:          "同じ日は1回だけ取得する",
+			times:         []time.Time{day, day.Add(11 * time.Hour)},
+			bases:         []string{"USD", "USD"},
+			expectedCalls: 1,
+		},
+		{
+			name:          "日付が変わると取得し直す",
+			times:         []time.Time{day, day.Add(12 * time.Hour)},
+			bases:         []string{"USD", "USD"},
+			expectedCalls: 2,
+		},
+		{
+			name:          "基準の通貨ごとに取得する",
+			times:         []time.Time{day, day},
+			bases:         []string{"USD", "EUR"},
+			expectedCalls: 2,
+		},
+		{
+			name:          "取得に失敗した場合はキャッシュしない",
+			times:         []time.Time{day, day},
+			bases:         []string{"XYZ", "XYZ"},
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			provider := &stubRates{rates: map[string]map[string]float64{
+				"USD": {"JPY": 150},
+				"EUR": {"JPY": 160},
+			}}
+			var mu sync.Mutex
+			var now time.Time
+			cache := convert.NewDailyCache(&convert.DailyCacheSetting{
+				Provider: provider,
+				Now: func() time.Time {
+					mu.Lock()
+					defer mu.Unlock()
+					return now
+				},
+			})
+
+			for i, base := range tt.bases {
+				mu.Lock()
+				now = tt.times[i]
+				mu.Unlock()
+				_, _ = cache.Rates(t.Context(), base)
+			}
+			if provider.calls != tt.expectedCalls {
+				t.Errorf("provider calls = %d, want %d", provider.calls, tt.expectedCalls)
+			}
+		})
+	}
+}
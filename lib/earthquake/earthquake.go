@@ -0,0 +1,215 @@
+package earthquake
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/i18n"
+)
+
+// ErrUnknownScale 震度として解釈できない値を指定したことを表すエラー
+var ErrUnknownScale = errors.New("unknown seismic intensity")
+
+// Scale P2P地震情報の震度の値（震度×10、5弱は45など）
+type Scale int
+
+const (
+	ScaleUnknown Scale = -1 // 震度情報なし
+	Scale1       Scale = 10 // 震度1
+	Scale2       Scale = 20 // 震度2
+	Scale3       Scale = 30 // 震度3
+	Scale4       Scale = 40 // 震度4
+	Scale5Lower  Scale = 45 // 震度5弱
+	Scale5Plus   Scale = 46 // 震度5弱以上と推定されるが震度情報を入手していない
+	Scale5Upper  Scale = 50 // 震度5強
+	Scale6Lower  Scale = 55 // 震度6弱
+	Scale6Upper  Scale = 60 // 震度6強
+	Scale7       Scale = 70 // 震度7
+)
+
+// codeJMAQuake P2P地震情報の地震情報のコード
+const codeJMAQuake = 551
+
+// issueTypeScalePrompt 震源の情報を含まない震度速報の発表の種類
+const issueTypeScalePrompt = "ScalePrompt"
+
+// unknownValue 不明な値の表示
+const unknownValue = "?"
+
+// unknownCoordinate 震源の緯度・経度が不明な場合の値
+const unknownCoordinate = -200
+
+// jst P2P地震情報の時刻のタイムゾーン
+var jst = time.FixedZone("JST", 9*60*60)
+
+// timeLayout P2P地震情報の時刻の書式
+const timeLayout = "2006/01/02 15:04:05"
+
+// scaleNames 設定ファイルで指定できる震度の表記
+var scaleNames = map[string]Scale{
+	"1":  Scale1,
+	"2":  Scale2,
+	"3":  Scale3,
+	"4":  Scale4,
+	"5-": Scale5Lower,
+	"5弱": Scale5Lower,
+	"5+": Scale5Upper,
+	"5強": Scale5Upper,
+	"6-": Scale6Lower,
+	"6弱": Scale6Lower,
+	"6+": Scale6Upper,
+	"6強": Scale6Upper,
+	"7":  Scale7,
+}
+
+// scaleTexts 返信メッセージの言語ごとの震度の表記
+// 英語では弱・強を-・+で表す
+var scaleTexts = map[i18n.Locale]map[Scale]string{
+	i18n.LocaleJa: {
+		Scale1: "1", Scale2: "2", Scale3: "3", Scale4: "4",
+		Scale5Lower: "5弱", Scale5Plus: "5弱以上", Scale5Upper: "5強",
+		Scale6Lower: "6弱", Scale6Upper: "6強", Scale7: "7",
+	},
+	i18n.LocaleEn: {
+		Scale1: "1", Scale2: "2", Scale3: "3", Scale4: "4",
+		Scale5Lower: "5-", Scale5Plus: "5- or higher", Scale5Upper: "5+",
+		Scale6Lower: "6-", Scale6Upper: "6+", Scale7: "7",
+	},
+}
+
+// Quake 地震情報
+type Quake struct {
+	Time      time.Time // 発生時刻
+	Epicenter string    // 震源の地名（不明な場合は空）
+	Lat       float64   // 震源の緯度
+	Lng       float64   // 震源の経度
+	Depth     int       // 震源の深さ（km、不明な場合は-1）
+	Magnitude float64   // マグニチュード（不明な場合は-1）
+	MaxScale  Scale     // 最大震度
+	IssueType string    // 発表の種類（ScalePrompt・ScaleAndDestination・DetailScaleなど）
+	Points    []Point   // 各地の震度
+}
+
+// Point 震度観測点または地域の震度
+type Point struct {
+	Pref   string // 都道府県
+	Addr   string // 震度観測点名または地域名
+	IsArea bool   // 地域の震度か（震度速報では地域ごとの震度を発表する）
+	Scale  Scale  // 震度
+}
+
+// message P2P地震情報のWebSocketで受信するメッセージ
+type message struct {
+	Code  int `json:"code"`
+	Issue struct {
+		Type string `json:"type"`
+	} `json:"issue"`
+	Earthquake struct {
+		Time       string `json:"time"`
+		Hypocenter struct {
+			Name      string  `json:"name"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+			Depth     int     `json:"depth"`
+			Magnitude float64 `json:"magnitude"`
+		} `json:"hypocenter"`
+		MaxScale Scale `json:"maxScale"`
+	} `json:"earthquake"`
+	Points []struct {
+		Pref   string `json:"pref"`
+		Addr   string `json:"addr"`
+		IsArea bool   `json:"isArea"`
+		Scale  Scale  `json:"scale"`
+	} `json:"points"`
+}
+
+// ParseScale 設定ファイルの震度の表記（4・5弱・5-など）を解析する
+func ParseScale(s string) (Scale, error) {
+	name := strings.TrimPrefix(strings.TrimSpace(s), "震度")
+	scale, ok := scaleNames[name]
+	if !ok {
+		return ScaleUnknown, errors.Wrapf(ErrUnknownScale, "intensity: %s", s)
+	}
+	return scale, nil
+}
+
+// Text 震度を返信メッセージの言語で表記する
+// 震度情報がない場合は?を返す
+func (s Scale) Text(locale i18n.Locale) string {
+	texts, ok := scaleTexts[locale]
+	if !ok {
+		texts = scaleTexts[i18n.DefaultLocale]
+	}
+	if text, ok := texts[s]; ok {
+		return text
+	}
+	return unknownValue
+}
+
+// ParseMessage P2P地震情報のメッセージから地震情報を取り出す
+// 地震情報以外のメッセージ（津波予報・ユーザーの接続数など）の場合はnilを返す
+func ParseMessage(data []byte) (*Quake, error) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	if msg.Code != codeJMAQuake {
+		return nil, nil
+	}
+
+	quake := &Quake{
+		Lat:       msg.Earthquake.Hypocenter.Latitude,
+		Lng:       msg.Earthquake.Hypocenter.Longitude,
+		Depth:     msg.Earthquake.Hypocenter.Depth,
+		Magnitude: msg.Earthquake.Hypocenter.Magnitude,
+		MaxScale:  msg.Earthquake.MaxScale,
+		Epicenter: msg.Earthquake.Hypocenter.Name,
+		IssueType: msg.Issue.Type,
+	}
+	for _, point := range msg.Points {
+		quake.Points = append(quake.Points, Point(point))
+	}
+	if msg.Earthquake.Time != "" {
+		t, err := time.ParseInLocation(timeLayout, msg.Earthquake.Time, jst)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to time.ParseInLocation")
+		}
+		quake.Time = t
+	}
+	return quake, nil
+}
+
+// HasHypocenter 震源の位置がわかっているかを返す
+func (q *Quake) HasHypocenter() bool {
+	return q.Lat != unknownCoordinate && q.Lng != unknownCoordinate && !(q.Lat == 0 && q.Lng == 0)
+}
+
+// ID 同じ地震の続報をまとめるための識別子（発生時刻）を返す
+func (q *Quake) ID() string {
+	return q.Time.Format(time.RFC3339)
+}
+
+// FillTemplateData 地震情報を返信テンプレートの変数に設定する
+func (q *Quake) FillTemplateData(data *i18n.TemplateData) {
+	data.EarthquakeTime = unknownValue
+	if !q.Time.IsZero() {
+		data.EarthquakeTime = q.Time.In(jst).Format("2006/01/02 15:04")
+	}
+	data.Epicenter = q.Epicenter
+	if data.Epicenter == "" {
+		data.Epicenter = unknownValue
+	}
+	data.Intensity = q.MaxScale.Text(data.Locale)
+	data.Depth = unknownValue
+	if 0 <= q.Depth {
+		data.Depth = strconv.Itoa(q.Depth) + "km"
+	}
+	data.Magnitude = unknownValue
+	if 0 <= q.Magnitude {
+		data.Magnitude = strconv.FormatFloat(q.Magnitude, 'f', 1, 64)
+	}
+}
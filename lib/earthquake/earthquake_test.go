@@ -0,0 +1,198 @@
+package earthquake_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/earthquake"
+	"hato-bot-go/lib/i18n"
+)
+
+// quakeMessage 能登半島地震の各地の震度に関する情報を模したメッセージ
+const quakeMessage = `{
+	"code": 551,
+	"id": "659263a7d616be0b1c6b6f6b",
+	"issue": {"source": "気象庁", "time": "2024/01/01 16:14:00", "type": "DetailScale", "correct": "None"},
+	"earthquake": {
+		"time": "2024/01/01 16:10:00",
+		"hypocenter": {"name": "石川県能登地方", "latitude": 37.5, "longitude": 137.2, "depth": 10, "magnitude": 7.6},
+		"maxScale": 70,
+		"domesticTsunami": "Warning"
+	},
+	"points": [{"pref": "石川県", "addr": "志賀町", "isArea": false, "scale": 70}]
+}`
+
+func TestParseScale(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      earthquake.Scale
+		expectedError error
+	}{
+		{name: "数字", value: "4", expected: earthquake.Scale4},
+		{name: "弱", value: "5弱", expected: earthquake.Scale5Lower},
+		{name: "強を+で表記", value: "6+", expected: earthquake.Scale6Upper},
+		{name: "震度を付けて表記", value: " 震度7 ", expected: earthquake.Scale7},
+		{name: "存在しない震度", value: "8", expected: earthquake.ScaleUnknown, expectedError: earthquake.ErrUnknownScale},
+		{name: "空", value: "", expected: earthquake.ScaleUnknown, expectedError: earthquake.ErrUnknownScale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			scale, err := earthquake.ParseScale(tt.value)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseScale(%q) error = %v, want %v", tt.value, err, tt.expectedError)
+			}
+			if scale != tt.expected {
+				t.Errorf("ParseScale(%q) = %d, want %d", tt.value, scale, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScaleText(t *testing.T) {
+	tests := []struct {
+		name     string
+		scale    earthquake.Scale
+		locale   i18n.Locale
+		expected string
+	}{
+		{name: "日本語の5弱", scale: earthquake.Scale5Lower, locale: i18n.LocaleJa, expected: "5弱"},
+		{name: "英語の6強", scale: earthquake.Scale6Upper, locale: i18n.LocaleEn, expected: "6+"},
+		{name: "5弱以上と推定", scale: earthquake.Scale5Plus, locale: i18n.LocaleJa, expected: "5弱以上"},
+		{name: "言語の指定なし", scale: earthquake.Scale3, locale: "", expected: "3"},
+		{name: "震度情報なし", scale: earthquake.ScaleUnknown, locale: i18n.LocaleJa, expected: "?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.scale.Text(tt.locale); got != tt.expected {
+				t.Errorf("Text(%q) = %q, want %q", tt.locale, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name          string
+		data          string
+		expected      *earthquake.Quake
+		expectedError bool
+	}{
+		{
+			name: "地震情報",
+			data: quakeMessage,
+			expected: &earthquake.Quake{
+				Time:      time.Date(2024, 1, 1, 16, 10, 0, 0, jst),
+				Epicenter: "石川県能登地方",
+				Lat:       37.5,
+				Lng:       137.2,
+				Depth:     10,
+				Magnitude: 7.6,
+				MaxScale:  earthquake.Scale7,
+				IssueType: "DetailScale",
+				Points: []earthquake.Point{
+					{Pref: "石川県", Addr: "志賀町", Scale: earthquake.Scale7},
+				},
+			},
+		},
+		{
+			name:     "地震情報以外のメッセージ",
+			data:     `{"code":555,"areas":[]}`,
+			expected: nil,
+		},
+		{
+			name:          "JSONが不正",
+			data:          `{"code":`,
+			expectedError: true,
+		},
+		{
+			name:          "時刻が不正",
+			data:          `{"code":551,"earthquake":{"time":"2024-01-01T16:10:00"}}`,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			quake, err := earthquake.ParseMessage([]byte(tt.data))
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("ParseMessage() error = %v, expectedError = %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, quake); diff != "" {
+				t.Errorf("ParseMessage() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuakeHasHypocenter(t *testing.T) {
+	tests := []struct {
+		name     string
+		quake    *earthquake.Quake
+		expected bool
+	}{
+		{name: "震源あり", quake: &earthquake.Quake{Lat: 37.5, Lng: 137.2}, expected: true},
+		{name: "震源不明", quake: &earthquake.Quake{Lat: -200, Lng: -200}, expected: false},
+		{name: "震源の情報なし", quake: &earthquake.Quake{}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.quake.HasHypocenter(); got != tt.expected {
+				t.Errorf("HasHypocenter() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQuakeFillTemplateData(t *testing.T) {
+	tests := []struct {
+		name     string
+		quake    *earthquake.Quake
+		locale   i18n.Locale
+		expected string
+	}{
+		{
+			name: "日本語",
+			quake: &earthquake.Quake{
+				Time:      time.Date(2024, 1, 1, 7, 10, 0, 0, time.UTC),
+				Epicenter: "石川県能登地方",
+				Depth:     10,
+				Magnitude: 7.6,
+				MaxScale:  earthquake.Scale7,
+			},
+			locale:   i18n.LocaleJa,
+			expected: i18n.Message(i18n.LocaleJa, i18n.KeyEarthquakeAlert, "2024/01/01 16:10", "石川県能登地方", "7", "10km", "7.6"),
+		},
+		{
+			name:     "不明な値",
+			quake:    &earthquake.Quake{Depth: -1, Magnitude: -1, MaxScale: earthquake.Scale5Upper},
+			locale:   i18n.LocaleEn,
+			expected: i18n.Message(i18n.LocaleEn, i18n.KeyEarthquakeAlert, "?", "?", "5+", "?", "?"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data := &i18n.TemplateData{Locale: tt.locale}
+			tt.quake.FillTemplateData(data)
+
+			var templates *i18n.Templates
+			if got := templates.Render(i18n.KeyEarthquakeAlert, data); got != tt.expected {
+				t.Errorf("Render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package earthquake
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/font"
+	"hato-bot-go/lib/httpclient"
+)
+
+// ErrNoHypocenter 震源の位置がわからないため地図を作成できないことを表すエラー
+var ErrNoHypocenter = errors.New("hypocenter is unknown")
+
+const (
+	// mapZoom 震源の地図のズームレベル（震源から数百kmの範囲が収まる）
+	mapZoom = 7
+	// mapAroundTiles 震源の地図の周囲のタイル数
+	mapAroundTiles = 2
+	// markerRadius 震源のマーカーの半径（ピクセル）
+	markerRadius = 10
+	// markerBorder 震源のマーカーの縁取りの幅（ピクセル）
+	markerBorder = 3
+	// intensityTextScale 震度の文字の拡大率
+	intensityTextScale = 2
+	// intensityPadding 震度の文字の周りの余白（ピクセル）
+	intensityPadding = 3
+)
+
+var (
+	// markerColor 震源のマーカーの色
+	markerColor = color.RGBA{R: 230, G: 0, B: 0, A: 255}
+	// markerBorderColor 震度の表示と区別するための震源のマーカーの縁取りの色
+	markerBorderColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	// darkText 明るい背景の震度の文字色
+	darkText = color.RGBA{A: 255}
+	// lightText 暗い背景の震度の文字色
+	lightText = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// intensityStyle 地図上の震度の表示
+type intensityStyle struct {
+	text       string     // 表示する文字（埋め込みフォントは英数字のみ対応のため弱・強は-・+で表す）
+	background color.RGBA // 背景色
+	foreground color.RGBA // 文字色
+}
+
+// intensityStyles 震度ごとの表示（気象庁の震度の配色に合わせる）
+var intensityStyles = map[Scale]intensityStyle{
+	Scale1:      {text: "1", background: color.RGBA{R: 242, G: 242, B: 255, A: 255}, foreground: darkText},
+	Scale2:      {text: "2", background: color.RGBA{R: 0, G: 170, B: 255, A: 255}, foreground: darkText},
+	Scale3:      {text: "3", background: color.RGBA{R: 0, G: 65, B: 255, A: 255}, foreground: lightText},
+	Scale4:      {text: "4", background: color.RGBA{R: 250, G: 230, B: 150, A: 255}, foreground: darkText},
+	Scale5Lower: {text: "5-", background: color.RGBA{R: 255, G: 230, B: 0, A: 255}, foreground: darkText},
+	Scale5Plus:  {text: "5-", background: color.RGBA{R: 255, G: 230, B: 0, A: 255}, foreground: darkText},
+	Scale5Upper: {text: "5+", background: color.RGBA{R: 255, G: 153, B: 0, A: 255}, foreground: darkText},
+	Scale6Lower: {text: "6-", background: color.RGBA{R: 255, G: 40, B: 0, A: 255}, foreground: lightText},
+	Scale6Upper: {text: "6+", background: color.RGBA{R: 165, G: 0, B: 33, A: 255}, foreground: lightText},
+	Scale7:      {text: "7", background: color.RGBA{R: 180, G: 0, B: 104, A: 255}, foreground: lightText},
+}
+
+// defaultClient クライアント未指定時に使うHTTPクライアント
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
+	Timeout:   30 * time.Second,
+}
+
+// RenderMapParams 震源の地図の描画のリクエスト構造体
+type RenderMapParams struct {
+	Client      *http.Client      // HTTPクライアント
+	Lat         float64           // 震源の緯度
+	Lng         float64           // 震源の経度
+	Magnitude   float64           // マグニチュード（不明な場合は-1）
+	Depth       int               // 震源の深さ（km、不明な場合は-1）
+	Intensities []RegionIntensity // 地域ごとの震度（後の要素ほど上に描画する）
+}
+
+// RenderMap 震源を中心とした地図に地域ごとの震度と震源のマーカーを描画する
+// ベースマップ・震度・マーカー・マグニチュードと深さのラベルの順に重ねる
+// 読み終わる前に破棄する場合は必ずCloseすること
+func RenderMap(ctx context.Context, params *RenderMapParams) (*amesh.ImageReader, error) {
+	if params == nil || params.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	result, err := amesh.CreateAmeshImage(ctx, &amesh.CreateAmeshImageParams{
+		Client:      params.Client,
+		Lat:         params.Lat,
+		Lng:         params.Lng,
+		Zoom:        mapZoom,
+		AroundTiles: mapAroundTiles,
+		Layers: []amesh.Layer{
+			&amesh.BaseMapLayer{Client: params.Client},
+			&IntensityLayer{Intensities: params.Intensities},
+			&amesh.MarkerLayer{Markers: []amesh.Marker{
+				{Lat: params.Lat, Lng: params.Lng, Radius: markerRadius + markerBorder, Color: markerBorderColor},
+				{Lat: params.Lat, Lng: params.Lng, Radius: markerRadius, Color: markerColor},
+			}},
+			&amesh.LabelLayer{Text: mapLabel(params.Magnitude, params.Depth)},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.CreateAmeshImage")
+	}
+	return result.Reader(), nil
+}
+
+// CreateMapReaderWithClientParams 震源の地図の作成のリクエスト構造体
+type CreateMapReaderWithClientParams struct {
+	Client *http.Client // HTTPクライアント
+	Quake  *Quake       // 地震情報
+}
+
+// CreateMapReaderWithClient HTTPクライアントを指定して、地震情報の震源と都道府県ごとの最大震度の地図を作成する
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateMapReaderWithClient(ctx context.Context, params *CreateMapReaderWithClientParams) (*amesh.ImageReader, error) {
+	if params == nil || params.Client == nil || params.Quake == nil {
+		return nil, lib.ErrParamsNil
+	}
+	quake := params.Quake
+	if !quake.HasHypocenter() {
+		return nil, ErrNoHypocenter
+	}
+
+	reader, err := RenderMap(ctx, &RenderMapParams{
+		Client:      params.Client,
+		Lat:         quake.Lat,
+		Lng:         quake.Lng,
+		Magnitude:   quake.Magnitude,
+		Depth:       quake.Depth,
+		Intensities: quake.RegionIntensities(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to RenderMap")
+	}
+	return reader, nil
+}
+
+// CreateMapReader 地震情報の震源と都道府県ごとの最大震度の地図を作成する
+// 読み終わる前に破棄する場合は必ずCloseすること
+func CreateMapReader(ctx context.Context, quake *Quake) (*amesh.ImageReader, error) {
+	return CreateMapReaderWithClient(ctx, &CreateMapReaderWithClientParams{Client: defaultClient, Quake: quake})
+}
+
+// IntensityLayer 地域ごとの震度を震度の色の四角で描画するレイヤー
+type IntensityLayer struct {
+	Intensities []RegionIntensity
+}
+
+// Draw 地域ごとの震度を描画する
+// 震度情報のない地域は描画しない
+func (l *IntensityLayer) Draw(_ context.Context, canvas *image.RGBA, viewport *amesh.Viewport) error {
+	for _, region := range l.Intensities {
+		style, ok := intensityStyles[region.Scale]
+		if !ok {
+			continue
+		}
+		center := viewport.ImagePoint(region.Lat, region.Lng)
+		size := font.MeasureText(style.text, intensityTextScale)
+		box := image.Rect(
+			center.X-size.X/2-intensityPadding,
+			center.Y-size.Y/2-intensityPadding,
+			center.X+(size.X+1)/2+intensityPadding,
+			center.Y+(size.Y+1)/2+intensityPadding,
+		)
+		if !box.Overlaps(canvas.Bounds()) {
+			continue
+		}
+
+		// 背景の地図と区別するため黒い枠を付ける
+		draw.Draw(canvas, box.Inset(-1), image.NewUniform(darkText), image.Point{}, draw.Src)
+		draw.Draw(canvas, box, image.NewUniform(style.background), image.Point{}, draw.Src)
+		font.DrawText(&font.DrawTextParams{
+			Img:   canvas,
+			X:     box.Min.X + intensityPadding,
+			Y:     box.Min.Y + intensityPadding,
+			Text:  style.text,
+			Color: style.foreground,
+			Scale: intensityTextScale,
+		})
+	}
+	return nil
+}
+
+// FileName 震源の地図のファイル名を返す
+func (q *Quake) FileName() string {
+	return fmt.Sprintf("earthquake_%s.png", q.Time.In(jst).Format("20060102150405"))
+}
+
+// mapLabel 地図に表示するマグニチュードと深さのラベル（埋め込みフォントは英数字のみ対応のため英数字で表記する）
+func mapLabel(magnitude float64, depth int) string {
+	label := "M" + unknownValue
+	if 0 <= magnitude {
+		label = fmt.Sprintf("M%.1f", magnitude)
+	}
+	if 0 <= depth {
+		label += fmt.Sprintf(" %dkm", depth)
+	}
+	return label
+}
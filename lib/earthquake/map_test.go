@@ -0,0 +1,205 @@
+package earthquake_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/earthquake"
+	"hato-bot-go/lib/httpclient"
+)
+
+// newTileClient 全てのタイルに同じPNG画像を返すモックのHTTPクライアントを作成する
+func newTileClient(t *testing.T) *http.Client {
+	t.Helper()
+
+	tile := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(tile, tile.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 220, B: 240, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, tile); err != nil {
+		t.Fatal(err)
+	}
+
+	return httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: buf.String()},
+	}).Client()
+}
+
+func TestRenderMap(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        *earthquake.RenderMapParams
+		expectedError error
+	}{
+		{
+			name: "震源と震度の地図を作成",
+			params: &earthquake.RenderMapParams{
+				Lat:       37.5,
+				Lng:       137.2,
+				Magnitude: 7.6,
+				Depth:     10,
+				Intensities: []earthquake.RegionIntensity{
+					{Name: "新潟県", Lat: 37.902, Lng: 139.023, Scale: earthquake.Scale6Lower},
+					{Name: "石川県", Lat: 36.595, Lng: 136.626, Scale: earthquake.Scale7},
+				},
+			},
+		},
+		{
+			name:   "震度情報なし",
+			params: &earthquake.RenderMapParams{Lat: 35.0, Lng: 140.0, Magnitude: -1, Depth: -1},
+		},
+		{
+			name:          "パラメータなし",
+			expectedError: lib.ErrParamsNil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			params := tt.params
+			if params != nil {
+				p := *params
+				p.Client = newTileClient(t)
+				params = &p
+			}
+
+			reader, err := earthquake.RenderMap(t.Context(), params)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("RenderMap() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			defer func() { _ = reader.Close() }()
+
+			if _, err := png.Decode(reader); err != nil {
+				t.Errorf("png.Decode() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestIntensityLayerDraw(t *testing.T) {
+	viewport := &amesh.Viewport{Lat: 37.5, Lng: 137.2, Zoom: 7, AroundTiles: 1}
+	tests := []struct {
+		name        string
+		intensities []earthquake.RegionIntensity
+		expected    color.RGBA // 震度を表示する位置の少し上（文字の上の余白）の色
+	}{
+		{
+			name:        "震度7は紫の四角",
+			intensities: []earthquake.RegionIntensity{{Lat: 37.5, Lng: 137.2, Scale: earthquake.Scale7}},
+			expected:    color.RGBA{R: 180, G: 0, B: 104, A: 255},
+		},
+		{
+			name: "後の地域ほど上に描画",
+			intensities: []earthquake.RegionIntensity{
+				{Lat: 37.5, Lng: 137.2, Scale: earthquake.Scale4},
+				{Lat: 37.5, Lng: 137.2, Scale: earthquake.Scale5Upper},
+			},
+			expected: color.RGBA{R: 255, G: 153, B: 0, A: 255},
+		},
+		{
+			name:        "震度情報のない地域は描画しない",
+			intensities: []earthquake.RegionIntensity{{Lat: 37.5, Lng: 137.2, Scale: earthquake.ScaleUnknown}},
+			expected:    color.RGBA{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			canvas := image.NewRGBA(image.Rect(0, 0, viewport.Size(), viewport.Size()))
+			layer := &earthquake.IntensityLayer{Intensities: tt.intensities}
+			if err := layer.Draw(t.Context(), canvas, viewport); err != nil {
+				t.Fatalf("Draw() error = %v", err)
+			}
+
+			// 文字と重ならない四角の上の余白の色を確かめる
+			center := viewport.ImagePoint(37.5, 137.2)
+			if got := canvas.RGBAAt(center.X, center.Y-8); got != tt.expected {
+				t.Errorf("RGBAAt() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCreateMapReaderWithClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		quake         *earthquake.Quake
+		nilClient     bool
+		expectedError error
+	}{
+		{
+			name: "震源の地図を作成",
+			quake: &earthquake.Quake{
+				Lat:       37.5,
+				Lng:       137.2,
+				Depth:     10,
+				Magnitude: 7.6,
+				MaxScale:  earthquake.Scale7,
+				Points:    []earthquake.Point{{Pref: "石川県", Addr: "志賀町", Scale: earthquake.Scale7}},
+			},
+		},
+		{
+			name:          "震源が不明",
+			quake:         &earthquake.Quake{Lat: -200, Lng: -200},
+			expectedError: earthquake.ErrNoHypocenter,
+		},
+		{
+			name:          "地震情報なし",
+			expectedError: lib.ErrParamsNil,
+		},
+		{
+			name:          "クライアントなし",
+			quake:         &earthquake.Quake{Lat: 37.5, Lng: 137.2},
+			nilClient:     true,
+			expectedError: lib.ErrParamsNil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			client := newTileClient(t)
+			if tt.nilClient {
+				client = nil
+			}
+
+			reader, err := earthquake.CreateMapReaderWithClient(t.Context(), &earthquake.CreateMapReaderWithClientParams{
+				Client: client,
+				Quake:  tt.quake,
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("CreateMapReaderWithClient() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			defer func() { _ = reader.Close() }()
+
+			if _, err := png.Decode(reader); err != nil {
+				t.Errorf("png.Decode() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestQuakeFileName(t *testing.T) {
+	t.Parallel()
+	quake := &earthquake.Quake{Time: time.Date(2024, 1, 1, 7, 10, 0, 0, time.UTC)}
+	if got, want := quake.FileName(), "earthquake_20240101161000.png"; got != want {
+		t.Errorf("FileName() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package earthquake
+
+import (
+	"cmp"
+	"slices"
+)
+
+// prefectureLocation 都道府県の震度を表示する位置（都道府県庁所在地）
+type prefectureLocation struct {
+	Lat float64
+	Lng float64
+}
+
+// prefectureLocations 都道府県名と震度を表示する位置の対応
+var prefectureLocations = map[string]prefectureLocation{
+	"北海道":  {Lat: 43.064, Lng: 141.347},
+	"青森県":  {Lat: 40.824, Lng: 140.740},
+	"岩手県":  {Lat: 39.704, Lng: 141.153},
+	"宮城県":  {Lat: 38.269, Lng: 140.872},
+	"秋田県":  {Lat: 39.719, Lng: 140.102},
+	"山形県":  {Lat: 38.240, Lng: 140.364},
+	"福島県":  {Lat: 37.750, Lng: 140.468},
+	"茨城県":  {Lat: 36.341, Lng: 140.447},
+	"栃木県":  {Lat: 36.566, Lng: 139.884},
+	"群馬県":  {Lat: 36.391, Lng: 139.061},
+	"埼玉県":  {Lat: 35.857, Lng: 139.649},
+	"千葉県":  {Lat: 35.605, Lng: 140.123},
+	"東京都":  {Lat: 35.690, Lng: 139.692},
+	"神奈川県": {Lat: 35.448, Lng: 139.643},
+	"新潟県":  {Lat: 37.902, Lng: 139.023},
+	"富山県":  {Lat: 36.695, Lng: 137.211},
+	"石川県":  {Lat: 36.595, Lng: 136.626},
+	"福井県":  {Lat: 36.065, Lng: 136.222},
+	"山梨県":  {Lat: 35.664, Lng: 138.568},
+	"長野県":  {Lat: 36.651, Lng: 138.181},
+	"岐阜県":  {Lat: 35.391, Lng: 136.722},
+	"静岡県":  {Lat: 34.977, Lng: 138.383},
+	"愛知県":  {Lat: 35.180, Lng: 136.907},
+	"三重県":  {Lat: 34.730, Lng: 136.509},
+	"滋賀県":  {Lat: 35.004, Lng: 135.868},
+	"京都府":  {Lat: 35.021, Lng: 135.756},
+	"大阪府":  {Lat: 34.686, Lng: 135.520},
+	"兵庫県":  {Lat: 34.691, Lng: 135.183},
+	"奈良県":  {Lat: 34.685, Lng: 135.833},
+	"和歌山県": {Lat: 34.226, Lng: 135.168},
+	"鳥取県":  {Lat: 35.504, Lng: 134.238},
+	"島根県":  {Lat: 35.472, Lng: 133.051},
+	"岡山県":  {Lat: 34.662, Lng: 133.935},
+	"広島県":  {Lat: 34.397, Lng: 132.460},
+	"山口県":  {Lat: 34.186, Lng: 131.471},
+	"徳島県":  {Lat: 34.066, Lng: 134.559},
+	"香川県":  {Lat: 34.340, Lng: 134.043},
+	"愛媛県":  {Lat: 33.842, Lng: 132.766},
+	"高知県":  {Lat: 33.560, Lng: 133.531},
+	"福岡県":  {Lat: 33.607, Lng: 130.418},
+	"佐賀県":  {Lat: 33.249, Lng: 130.299},
+	"長崎県":  {Lat: 32.745, Lng: 129.874},
+	"熊本県":  {Lat: 32.790, Lng: 130.742},
+	"大分県":  {Lat: 33.238, Lng: 131.613},
+	"宮崎県":  {Lat: 31.911, Lng: 131.424},
+	"鹿児島県": {Lat: 31.560, Lng: 130.558},
+	"沖縄県":  {Lat: 26.212, Lng: 127.681},
+}
+
+// RegionIntensity 地図に表示する地域の震度
+type RegionIntensity struct {
+	Name  string  // 地域名
+	Lat   float64 // 震度を表示する位置の緯度
+	Lng   float64 // 震度を表示する位置の経度
+	Scale Scale   // 震度
+}
+
+// RegionIntensities 各地の震度を都道府県ごとの最大震度にまとめる
+// 観測点が多いと地図上の表示が重なるため、都道府県庁所在地の位置に1つだけ表示する
+// 震度の低い順に並べるため、順に描画すると震度の高い地域が上に重なる
+func (q *Quake) RegionIntensities() []RegionIntensity {
+	maxScales := map[string]Scale{}
+	for _, point := range q.Points {
+		if _, ok := prefectureLocations[point.Pref]; !ok {
+			continue
+		}
+		if current, ok := maxScales[point.Pref]; !ok || current < point.Scale {
+			maxScales[point.Pref] = point.Scale
+		}
+	}
+
+	regions := make([]RegionIntensity, 0, len(maxScales))
+	for pref, scale := range maxScales {
+		location := prefectureLocations[pref]
+		regions = append(regions, RegionIntensity{Name: pref, Lat: location.Lat, Lng: location.Lng, Scale: scale})
+	}
+	slices.SortFunc(regions, func(a, b RegionIntensity) int {
+		return cmp.Or(cmp.Compare(a.Scale, b.Scale), cmp.Compare(a.Name, b.Name))
+	})
+	return regions
+}
@@ -0,0 +1,58 @@
+package earthquake_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/earthquake"
+)
+
+func TestQuakeRegionIntensities(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   []earthquake.Point
+		expected []earthquake.RegionIntensity
+	}{
+		{
+			name: "都道府県ごとの最大震度を震度の低い順に並べる",
+			points: []earthquake.Point{
+				{Pref: "石川県", Addr: "輪島市", Scale: earthquake.Scale6Upper},
+				{Pref: "新潟県", Addr: "長岡市", Scale: earthquake.Scale6Lower},
+				{Pref: "石川県", Addr: "志賀町", Scale: earthquake.Scale7},
+				{Pref: "富山県", Addr: "富山市", Scale: earthquake.Scale5Upper},
+				{Pref: "新潟県", Addr: "新潟市", Scale: earthquake.Scale5Lower},
+			},
+			expected: []earthquake.RegionIntensity{
+				{Name: "富山県", Lat: 36.695, Lng: 137.211, Scale: earthquake.Scale5Upper},
+				{Name: "新潟県", Lat: 37.902, Lng: 139.023, Scale: earthquake.Scale6Lower},
+				{Name: "石川県", Lat: 36.595, Lng: 136.626, Scale: earthquake.Scale7},
+			},
+		},
+		{
+			name: "知らない都道府県は除く",
+			points: []earthquake.Point{
+				{Pref: "不明", Addr: "不明", Scale: earthquake.Scale3},
+				{Pref: "沖縄県", Addr: "那覇市", Scale: earthquake.Scale1},
+			},
+			expected: []earthquake.RegionIntensity{
+				{Name: "沖縄県", Lat: 26.212, Lng: 127.681, Scale: earthquake.Scale1},
+			},
+		},
+		{
+			name:     "震度情報なし",
+			points:   nil,
+			expected: []earthquake.RegionIntensity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			quake := &earthquake.Quake{Points: tt.points}
+			if diff := cmp.Diff(tt.expected, quake.RegionIntensities()); diff != "" {
+				t.Errorf("RegionIntensities() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package earthquake
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gorilla/websocket"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+)
+
+// DefaultURL P2P地震情報のWebSocket APIのURL
+const DefaultURL = "wss://api.p2pquake.net/v2/ws"
+
+const (
+	// defaultRetryInterval 接続が切れてから再接続するまでの待ち時間
+	defaultRetryInterval = 10 * time.Second
+	// recentQuakeLimit 続報で二重に投稿しないために記憶する地震の数
+	recentQuakeLimit = 100
+	// handshakeTimeout WebSocketの接続の確立の制限時間
+	handshakeTimeout = 10 * time.Second
+)
+
+// SubscriberSetting 地震情報の購読の設定
+type SubscriberSetting struct {
+	URL           string                                        // WebSocketのURL（空の場合はDefaultURL）
+	MinScale      Scale                                         // 通知する最大震度の下限
+	Handler       func(ctx context.Context, quake *Quake) error // 通知する地震情報を受け取る関数
+	RetryInterval time.Duration                                 // 再接続までの待ち時間（0の場合はdefaultRetryInterval）
+	Clock         clock.Clock                                   // 再接続までの待ち時間を待つ時計（nilの場合はclock.Real）
+}
+
+// Subscriber P2P地震情報のWebSocketを購読し、最大震度が下限以上の地震をHandlerに渡す
+// 同じ地震の続報（震源・震度に関する情報→各地の震度に関する情報など）は最初の1回だけ渡す
+type Subscriber struct {
+	setting      SubscriberSetting
+	recentQuakes []string // 通知済みの地震のID
+}
+
+// NewSubscriber 新しいSubscriberを作成する
+func NewSubscriber(setting *SubscriberSetting) *Subscriber {
+	s := *setting
+	if s.URL == "" {
+		s.URL = DefaultURL
+	}
+	if s.RetryInterval <= 0 {
+		s.RetryInterval = defaultRetryInterval
+	}
+	s.Clock = clock.Or(s.Clock)
+	return &Subscriber{setting: s}
+}
+
+// Run ctxがキャンセルされるまでWebSocketを購読する
+// 接続が切れた場合はRetryIntervalだけ待って再接続する
+func (s *Subscriber) Run(ctx context.Context) error {
+	if s.setting.Handler == nil {
+		return lib.ErrParamsNil
+	}
+
+	for ctx.Err() == nil {
+		if err := s.listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("P2P quake WebSocket connection lost: %v", err)
+		}
+
+		_ = s.setting.Clock.Sleep(ctx, s.setting.RetryInterval)
+	}
+	return nil
+}
+
+// listen WebSocketに接続し、接続が切れるまでメッセージを処理する
+func (s *Subscriber) listen(ctx context.Context) error {
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = handshakeTimeout
+
+	conn, resp, err := dialer.DialContext(ctx, s.setting.URL, http.Header{
+		"User-Agent": []string{httpclient.UserAgent(ctx)},
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to DialContext")
+	}
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+	defer func() { _ = conn.Close() }()
+
+	// 終了のシグナルを受け取った場合は読み込みを中断する
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	log.Printf("Connected to P2P quake WebSocket: %s", s.setting.URL)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return errors.Wrap(err, "Failed to ReadMessage")
+		}
+		if err := s.handle(ctx, data); err != nil {
+			log.Printf("Failed to handle earthquake message: %v", err)
+		}
+	}
+}
+
+// handle メッセージが通知する地震情報であればHandlerに渡す
+// 震度速報は震源がわからないため、震源を含む続報を待って通知する
+func (s *Subscriber) handle(ctx context.Context, data []byte) error {
+	quake, err := ParseMessage(data)
+	if err != nil {
+		return errors.Wrap(err, "Failed to ParseMessage")
+	}
+	if quake == nil || quake.IssueType == issueTypeScalePrompt || quake.MaxScale < s.setting.MinScale {
+		return nil
+	}
+	if slices.Contains(s.recentQuakes, quake.ID()) {
+		return nil
+	}
+	if len(s.recentQuakes) >= recentQuakeLimit {
+		s.recentQuakes = s.recentQuakes[1:]
+	}
+	s.recentQuakes = append(s.recentQuakes, quake.ID())
+
+	if err := s.setting.Handler(ctx, quake); err != nil {
+		return errors.Wrap(err, "Failed to Handler")
+	}
+	return nil
+}
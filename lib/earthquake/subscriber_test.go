@@ -0,0 +1,155 @@
+package earthquake_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/earthquake"
+)
+
+// quakeMessageParams テスト用の地震情報のメッセージの内容
+type quakeMessageParams struct {
+	issueType  string
+	occurredAt string
+	epicenter  string
+	maxScale   earthquake.Scale
+}
+
+// newQuakeMessage 地震情報のメッセージを作成する
+func newQuakeMessage(params *quakeMessageParams) string {
+	return fmt.Sprintf(
+		`{"code":551,"issue":{"type":%q},"earthquake":{"time":%q,"hypocenter":{"name":%q,"latitude":35.0,"longitude":140.0,"depth":30,"magnitude":5.0},"maxScale":%d}}`,
+		params.issueType, params.occurredAt, params.epicenter, params.maxScale,
+	)
+}
+
+// newQuakeServer 接続ごとにメッセージを送信して切断するWebSocketサーバーを作成する
+// 接続を受け付けるたびにconnectedに通知する
+func newQuakeServer(t *testing.T, messages []string, connected chan<- struct{}) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		for _, message := range messages {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return
+			}
+		}
+		select {
+		case connected <- struct{}{}:
+		default:
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestSubscriberRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		minScale earthquake.Scale
+		messages []string
+		expected []string
+	}{
+		{
+			name:     "下限以上の地震を続報を除いて通知する",
+			minScale: earthquake.Scale4,
+			messages: []string{
+				newQuakeMessage(&quakeMessageParams{issueType: "ScalePrompt", occurredAt: "2024/01/01 16:10:00", epicenter: "", maxScale: earthquake.Scale5Upper}),
+				newQuakeMessage(&quakeMessageParams{issueType: "ScaleAndDestination", occurredAt: "2024/01/01 16:10:00", epicenter: "石川県能登地方", maxScale: earthquake.Scale5Upper}),
+				newQuakeMessage(&quakeMessageParams{issueType: "DetailScale", occurredAt: "2024/01/01 16:10:00", epicenter: "石川県能登地方", maxScale: earthquake.Scale7}),
+				`{"code":555,"areas":[]}`,
+				newQuakeMessage(&quakeMessageParams{issueType: "DetailScale", occurredAt: "2024/01/01 16:18:00", epicenter: "能登半島沖", maxScale: earthquake.Scale3}),
+				`{"code":`,
+				newQuakeMessage(&quakeMessageParams{issueType: "DetailScale", occurredAt: "2024/01/01 16:56:00", epicenter: "石川県能登地方", maxScale: earthquake.Scale5Lower}),
+			},
+			expected: []string{"石川県能登地方", "石川県能登地方"},
+		},
+		{
+			name:     "震度情報のない地震は通知しない",
+			minScale: earthquake.Scale1,
+			messages: []string{
+				newQuakeMessage(&quakeMessageParams{issueType: "Foreign", occurredAt: "2024/01/01 10:00:00", epicenter: "南太平洋", maxScale: earthquake.ScaleUnknown}),
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			connected := make(chan struct{})
+			url := newQuakeServer(t, tt.messages, connected)
+
+			var mu sync.Mutex
+			var epicenters []string
+			fake := clocktest.NewFake(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+			subscriber := earthquake.NewSubscriber(&earthquake.SubscriberSetting{
+				URL:      url,
+				MinScale: tt.minScale,
+				Handler: func(_ context.Context, quake *earthquake.Quake) error {
+					mu.Lock()
+					defer mu.Unlock()
+					epicenters = append(epicenters, quake.Epicenter)
+					return nil
+				},
+				RetryInterval: time.Minute,
+				Clock:         fake,
+			})
+
+			ctx, cancel := context.WithCancel(t.Context())
+			done := make(chan error, 1)
+			go func() { done <- subscriber.Run(ctx) }()
+
+			// 再接続後に同じメッセージを受信しても二重に通知しないことを確かめるため2回接続させる
+			for i := range 2 {
+				select {
+				case <-connected:
+				case <-time.After(5 * time.Second):
+					t.Fatal("subscriber did not connect")
+				}
+				if i == 0 {
+					// 接続が切れたらRetryIntervalだけ待ってから再接続する
+					fake.BlockUntil(1)
+					fake.Advance(time.Minute)
+				}
+			}
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if diff := cmp.Diff(tt.expected, epicenters); diff != "" {
+				t.Errorf("notified epicenters mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSubscriberRunWithoutHandler(t *testing.T) {
+	t.Parallel()
+	subscriber := earthquake.NewSubscriber(&earthquake.SubscriberSetting{})
+	if err := subscriber.Run(t.Context()); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("Run() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
@@ -0,0 +1,166 @@
+package elevation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+// ErrNoElevation 地点の標高のデータがないことを表すエラー（海上や国外の地点）
+var ErrNoElevation = errors.New("no elevation data at the location")
+
+// DefaultAPIURL 国土地理院の標高APIのURL
+const DefaultAPIURL = "https://cyberjapandata2.gsi.go.jp/general/dem/scripts/getelevation.php"
+
+// defaultClient クライアント未指定時に使うHTTPクライアント
+// 国土地理院が不調な場合はサーキットブレーカーで即座に失敗させる
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
+	Timeout:   30 * time.Second,
+}
+
+// ameshCaption ameshコマンドの画像の説明文に標高を添えるか（SetAmeshCaptionで設定する）
+var ameshCaption atomic.Bool
+
+// Elevation 地点の標高
+type Elevation struct {
+	Meters float64 // 標高（メートル）
+	Source string  // 標高のデータソース（例: 5m（レーザ））
+}
+
+// GetElevationWithClientParams 標高取得のリクエスト構造体
+type GetElevationWithClientParams struct {
+	Client   httpclient.Doer // HTTPクライアント
+	APIURL   string          // 標高APIのURL（空の場合はDefaultAPIURL）
+	Location *amesh.Location // 位置情報
+}
+
+// ParseElevationCommandResult elevationコマンドの解析結果を表す構造体
+type ParseElevationCommandResult struct {
+	Place       string
+	IsElevation bool
+}
+
+// elevationResponse 標高APIのレスポンス
+// データがない地点ではelevationとhsrcが文字列の"-----"になる
+type elevationResponse struct {
+	Elevation json.RawMessage `json:"elevation"`
+	Hsrc      string          `json:"hsrc"`
+}
+
+// ParseElevationCommand elevationコマンドを解析
+func ParseElevationCommand(text string) ParseElevationCommandResult {
+	parsed := lib.ParseCommand(text, "elevation")
+	if !parsed.Matched {
+		return ParseElevationCommandResult{
+			Place:       "",
+			IsElevation: false,
+		}
+	}
+
+	place := parsed.Args
+	if place == "" {
+		place = "東京" // デフォルトの場所
+	}
+	return ParseElevationCommandResult{
+		Place:       place,
+		IsElevation: true,
+	}
+}
+
+// GetElevationWithClient HTTPクライアントを指定して国土地理院の標高APIから地点の標高を取得する
+// 標高のデータがない地点の場合はErrNoElevationを返す
+func GetElevationWithClient(ctx context.Context, params *GetElevationWithClientParams) (*Elevation, error) {
+	if params == nil || params.Client == nil || params.Location == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	apiURL := params.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	query := url.Values{}
+	query.Set("lon", strconv.FormatFloat(params.Location.Lng, 'f', -1, 64))
+	query.Set("lat", strconv.FormatFloat(params.Location.Lat, 'f', -1, 64))
+	query.Set("outtype", "JSON")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	body, err := httpclient.ExecuteAndReadBody(params.Client, req, httpclient.DefaultMaxResponseBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
+	}
+
+	var response elevationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	var meters float64
+	if err := json.Unmarshal(response.Elevation, &meters); err != nil {
+		return nil, errors.Wrapf(
+			ErrNoElevation,
+			"place: %s (%.4f, %.4f), elevation: %s",
+			params.Location.PlaceName,
+			params.Location.Lat,
+			params.Location.Lng,
+			response.Elevation,
+		)
+	}
+	return &Elevation{
+		Meters: meters,
+		Source: response.Hsrc,
+	}, nil
+}
+
+// GetElevation 地点の標高を取得する
+func GetElevation(ctx context.Context, location *amesh.Location) (*Elevation, error) {
+	return GetElevationWithClient(ctx, &GetElevationWithClientParams{
+		Client:   defaultClient,
+		Location: location,
+	})
+}
+
+// Text 標高を小数点以下1桁の文字列にする
+func (e *Elevation) Text() string {
+	return fmt.Sprintf("%.1f", e.Meters)
+}
+
+// CommandErrorKey elevationコマンド処理のエラーに応じた返信メッセージのキーを返す
+// 国土地理院が停止中の場合は停止中の外部サービスを伝え、地名の解析のエラーはameshコマンドと同じメッセージにする
+func CommandErrorKey(err error) i18n.Key {
+	var circuitErr *httpclient.CircuitOpenError
+	if errors.As(err, &circuitErr) && circuitErr.Upstream == httpclient.UpstreamGSI {
+		return i18n.KeyErrorUpstreamOutage
+	}
+	if errors.Is(err, ErrNoElevation) {
+		return i18n.KeyErrorNoElevation
+	}
+	if key := amesh.CommandErrorKey(err); key != i18n.KeyErrorCommand {
+		return key
+	}
+	return i18n.KeyErrorElevationCommand
+}
+
+// SetAmeshCaption ameshコマンドの画像の説明文に地点の標高を添えるかを設定する
+func SetAmeshCaption(enabled bool) {
+	ameshCaption.Store(enabled)
+}
+
+// AmeshCaption SetAmeshCaptionで設定した、ameshコマンドの画像の説明文に標高を添えるかを返す
+func AmeshCaption() bool {
+	return ameshCaption.Load()
+}
@@ -0,0 +1,117 @@
+package elevation_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/elevation"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+func TestParseElevationCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected elevation.ParseElevationCommandResult
+	}{
+		{name: "地名あり", text: "elevation 富士山", expected: elevation.ParseElevationCommandResult{Place: "富士山", IsElevation: true}},
+		{name: "地名なし", text: "@hato elevation", expected: elevation.ParseElevationCommandResult{Place: "東京", IsElevation: true}},
+		{name: "別のコマンド", text: "amesh 東京", expected: elevation.ParseElevationCommandResult{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, elevation.ParseElevationCommand(tt.text)); diff != "" {
+				t.Errorf("ParseElevationCommand(%q) mismatch (-want +got):\n%s", tt.text, diff)
+			}
+		})
+	}
+}
+
+func TestGetElevationWithClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    httpclient.MockResponse
+		expected    *elevation.Elevation
+		expectError error
+	}{
+		{
+			name:     "標高とデータソース",
+			response: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"elevation":40.2,"hsrc":"5m（レーザ）"}`},
+			expected: &elevation.Elevation{Meters: 40.2, Source: "5m（レーザ）"},
+		},
+		{
+			name:        "データがない地点",
+			response:    httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"elevation":"-----","hsrc":"-----"}`},
+			expectError: elevation.ErrNoElevation,
+		},
+		{
+			name:        "APIのエラー",
+			response:    httpclient.MockResponse{StatusCode: http.StatusInternalServerError, Body: "error"},
+			expectError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{{Pattern: "getelevation.php", Responses: []httpclient.MockResponse{tt.response}}},
+			})
+
+			got, err := elevation.GetElevationWithClient(t.Context(), &elevation.GetElevationWithClientParams{
+				Client:   &http.Client{Transport: transport},
+				Location: &amesh.Location{Lat: 35.6812, Lng: 139.7671, PlaceName: "東京駅"},
+			})
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("GetElevationWithClient() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("GetElevationWithClient() mismatch (-want +got):\n%s", diff)
+			}
+
+			// 経度・緯度の順にクエリで指定する
+			requests := transport.Requests()
+			if len(requests) != 1 || !strings.Contains(requests[0].URL, "lon=139.7671&lat=35.6812") && !strings.Contains(requests[0].URL, "lat=35.6812&lon=139.7671") {
+				t.Errorf("requests = %v, want a request with lat and lon", requests)
+			}
+		})
+	}
+}
+
+func TestGetElevationWithClientParamsNil(t *testing.T) {
+	if _, err := elevation.GetElevationWithClient(t.Context(), &elevation.GetElevationWithClientParams{}); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("GetElevationWithClient() error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
+
+func TestCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{name: "データがない地点", err: errors.Wrap(elevation.ErrNoElevation, "Failed to GetElevation"), expected: i18n.KeyErrorNoElevation},
+		{name: "ジオコーダが停止中", err: &httpclient.CircuitOpenError{Upstream: httpclient.UpstreamYahooGeocoder}, expected: i18n.KeyErrorUpstreamOutage},
+		{name: "その他の外部サービスの障害", err: errors.Wrap(httpclient.ErrCircuitOpen, "Failed to Do"), expected: i18n.KeyErrorUpstreamUnavailable},
+		{name: "国土地理院が停止中", err: &httpclient.CircuitOpenError{Upstream: httpclient.UpstreamGSI}, expected: i18n.KeyErrorUpstreamOutage},
+		{name: "その他のエラー", err: errors.New("unknown"), expected: i18n.KeyErrorElevationCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := elevation.CommandErrorKey(tt.err); got != tt.expected {
+				t.Errorf("CommandErrorKey() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
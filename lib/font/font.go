@@ -0,0 +1,142 @@
+package font
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// グリフの寸法（ピクセル）
+const (
+	GlyphWidth   = 5 // グリフの幅
+	GlyphHeight  = 7 // グリフの高さ
+	GlyphSpacing = 1 // グリフ間の余白
+)
+
+// glyphs 5x7ドットのビットマップフォント
+// 各行の下位5ビットが左から右のドットを表す（英小文字は大文字で描画する）
+var glyphs = map[rune][GlyphHeight]uint8{
+	' ':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	'!':  {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},
+	'"':  {0x0A, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00},
+	'#':  {0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A},
+	'%':  {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'\'': {0x0C, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	'(':  {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')':  {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'*':  {0x00, 0x04, 0x15, 0x0E, 0x15, 0x04, 0x00},
+	'+':  {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	'-':  {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	'/':  {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'0':  {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1':  {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3':  {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4':  {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5':  {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6':  {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9':  {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	':':  {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'<':  {0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02},
+	'=':  {0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00},
+	'>':  {0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08},
+	'?':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+	'A':  {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B':  {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C':  {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D':  {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G':  {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H':  {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I':  {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M':  {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P':  {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q':  {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R':  {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S':  {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T':  {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X':  {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'_':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+}
+
+// DrawTextParams テキスト描画のパラメータ
+type DrawTextParams struct {
+	Img   draw.Image  // 描画対象の画像
+	X     int         // 左上のX座標
+	Y     int         // 左上のY座標
+	Text  string      // 描画する文字列（未対応の文字は?で描画する）
+	Color color.Color // 文字色
+	Scale int         // 拡大率（1以上、0以下の場合は1）
+}
+
+// glyphOf 文字に対応するグリフを返す
+func glyphOf(r rune) [GlyphHeight]uint8 {
+	if glyph, ok := glyphs[r]; ok {
+		return glyph
+	}
+	if upper := []rune(strings.ToUpper(string(r))); len(upper) == 1 {
+		if glyph, ok := glyphs[upper[0]]; ok {
+			return glyph
+		}
+	}
+	return glyphs['?']
+}
+
+// MeasureText テキストを描画したときの幅と高さを返す
+func MeasureText(text string, scale int) image.Point {
+	scale = max(scale, 1)
+	n := len([]rune(text))
+	if n == 0 {
+		return image.Point{}
+	}
+	return image.Point{
+		X: (n*(GlyphWidth+GlyphSpacing) - GlyphSpacing) * scale,
+		Y: GlyphHeight * scale,
+	}
+}
+
+// DrawText ビットマップフォントでテキストを描画する
+func DrawText(params *DrawTextParams) {
+	if params == nil || params.Img == nil {
+		return
+	}
+	scale := max(params.Scale, 1)
+	bounds := params.Img.Bounds()
+	src := image.NewUniform(params.Color)
+
+	x := params.X
+	for _, r := range params.Text {
+		glyph := glyphOf(r)
+		for row, bits := range glyph {
+			for col := range GlyphWidth {
+				if bits&(1<<(GlyphWidth-1-col)) == 0 {
+					continue
+				}
+				dot := image.Rect(
+					x+col*scale,
+					params.Y+row*scale,
+					x+(col+1)*scale,
+					params.Y+(row+1)*scale,
+				).Intersect(bounds)
+				draw.Draw(params.Img, dot, src, image.Point{}, draw.Over)
+			}
+		}
+		x += (GlyphWidth + GlyphSpacing) * scale
+	}
+}
@@ -0,0 +1,101 @@
+package font_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/font"
+)
+
+func TestMeasureText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		scale    int
+		expected image.Point
+	}{
+		{name: "空文字列", text: "", scale: 1, expected: image.Point{}},
+		{name: "1文字", text: "A", scale: 1, expected: image.Point{X: 5, Y: 7}},
+		{name: "複数文字", text: "12:05", scale: 1, expected: image.Point{X: 29, Y: 7}},
+		{name: "拡大", text: "AB", scale: 3, expected: image.Point{X: 33, Y: 21}},
+		{name: "拡大率0は1倍", text: "AB", scale: 0, expected: image.Point{X: 11, Y: 7}},
+		{name: "マルチバイト文字は1文字として数える", text: "東京", scale: 1, expected: image.Point{X: 11, Y: 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(font.MeasureText(tt.text, tt.scale), tt.expected); diff != "" {
+				t.Errorf("MeasureText(%q, %d) diff: %s", tt.text, tt.scale, diff)
+			}
+		})
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	black := color.RGBA{A: 255}
+
+	tests := []struct {
+		name        string
+		text        string
+		scale       int
+		expectSet   []image.Point
+		expectUnset []image.Point
+	}{
+		{
+			name:        "Iの縦線と上下の横線",
+			text:        "I",
+			scale:       1,
+			expectSet:   []image.Point{{X: 1, Y: 0}, {X: 2, Y: 3}, {X: 3, Y: 6}},
+			expectUnset: []image.Point{{X: 0, Y: 3}, {X: 4, Y: 3}},
+		},
+		{
+			name:        "小文字は大文字で描画",
+			text:        "i",
+			scale:       1,
+			expectSet:   []image.Point{{X: 1, Y: 0}, {X: 2, Y: 3}, {X: 3, Y: 6}},
+			expectUnset: []image.Point{{X: 0, Y: 3}},
+		},
+		{
+			name:        "拡大して描画",
+			text:        "-",
+			scale:       2,
+			expectSet:   []image.Point{{X: 0, Y: 6}, {X: 9, Y: 7}},
+			expectUnset: []image.Point{{X: 0, Y: 5}, {X: 0, Y: 8}},
+		},
+		{
+			name:        "画像の範囲外にはみ出してもパニックしない",
+			text:        "WWWWWWWWWW",
+			scale:       4,
+			expectSet:   []image.Point{{X: 0, Y: 0}},
+			expectUnset: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+			font.DrawText(&font.DrawTextParams{
+				Img:   img,
+				Text:  tt.text,
+				Color: black,
+				Scale: tt.scale,
+			})
+
+			for _, p := range tt.expectSet {
+				if img.RGBAAt(p.X, p.Y) != black {
+					t.Errorf("pixel %v is not set", p)
+				}
+			}
+			for _, p := range tt.expectUnset {
+				if img.RGBAAt(p.X, p.Y) == black {
+					t.Errorf("pixel %v is unexpectedly set", p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"strings"
+	"unicode"
+)
+
+// zeroWidthJoiner 絵文字を組み合わせるゼロ幅接合子
+const zeroWidthJoiner = '\u200d'
+
+// GraphemeLength 書記素クラスタ（利用者から見た1文字）の数を返す
+// 結合文字・異体字セレクタ・肌の色の修飾子・ZWJで組み合わせた絵文字・国旗は1文字として数える
+func GraphemeLength(text string) int {
+	return len(graphemes(text))
+}
+
+// SplitText 1つあたりlimit文字（書記素クラスタの数）以内になるよう文章を分割する
+// 後半に改行がある場合は改行で、なければ空白で区切り、どちらもない場合はlimit文字で区切る
+// 区切りの前後の改行と空白は取り除き、limitが0以下の場合や収まる場合は分割しない
+func SplitText(text string, limit int) []string {
+	if limit <= 0 || GraphemeLength(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	clusters := graphemes(text)
+	for 0 < len(clusters) {
+		cut := len(clusters)
+		if limit < cut {
+			cut = splitPoint(clusters[:limit+1])
+		}
+		if chunk := strings.TrimRightFunc(strings.Join(clusters[:cut], ""), unicode.IsSpace); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		clusters = clusters[cut:]
+		for 0 < len(clusters) && strings.TrimSpace(clusters[0]) == "" {
+			clusters = clusters[1:]
+		}
+	}
+	return chunks
+}
+
+// splitPoint 最後の1文字を除いた範囲で区切る位置を返す
+// 短すぎる断片にならないよう、改行と空白は後半にあるもののみを使う
+func splitPoint(clusters []string) int {
+	limit := len(clusters) - 1
+	for _, isSeparator := range []func(cluster string) bool{
+		func(cluster string) bool { return strings.ContainsAny(cluster, "\r\n") },
+		func(cluster string) bool { return strings.TrimSpace(cluster) == "" },
+	} {
+		for i := limit; limit/2 < i; i-- {
+			if isSeparator(clusters[i]) {
+				return i
+			}
+		}
+	}
+	return limit
+}
+
+// graphemes 文章を書記素クラスタに分ける
+// Unicodeの書記素クラスタの規則（UAX #29）のうち、返信に現れる結合文字・絵文字・国旗・CRLFを扱う
+func graphemes(text string) []string {
+	var clusters []string
+	start := 0
+	prev := rune(-1)
+	regionalIndicators := 0 // 直前までに連続する国旗の地域指示記号の数
+	for i, r := range text {
+		if 0 <= prev && !joinsPrevious(prev, r, regionalIndicators) {
+			clusters = append(clusters, text[start:i])
+			start = i
+		}
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		} else {
+			regionalIndicators = 0
+		}
+		prev = r
+	}
+	if start < len(text) {
+		clusters = append(clusters, text[start:])
+	}
+	return clusters
+}
+
+// joinsPrevious rが直前の文字prevと同じ書記素クラスタになるか
+func joinsPrevious(prev, r rune, regionalIndicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == '\r' || prev == '\n' || r == '\r' || r == '\n':
+		return false
+	case r == zeroWidthJoiner || unicode.In(r, unicode.M, unicode.Variation_Selector):
+		return true
+	case 0x1f3fb <= r && r <= 0x1f3ff: // 肌の色の修飾子
+		return true
+	case 0xe0020 <= r && r <= 0xe007f: // 地域の旗のタグ文字
+		return true
+	case prev == zeroWidthJoiner:
+		return unicode.Is(unicode.So, r)
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		return regionalIndicators%2 == 1
+	default:
+		return false
+	}
+}
+
+// isRegionalIndicator 国旗を表す地域指示記号か
+func isRegionalIndicator(r rune) bool {
+	return 0x1f1e6 <= r && r <= 0x1f1ff
+}
@@ -0,0 +1,60 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+)
+
+func TestGraphemeLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "英数字と漢字", text: "amesh 東京", expected: 8},
+		{name: "結合文字の濁点", text: "がき", expected: 2},
+		{name: "異体字セレクタ", text: "☀️晴れ", expected: 3},
+		{name: "肌の色の修飾子", text: "👍🏽", expected: 1},
+		{name: "ZWJで組み合わせた絵文字", text: "👨‍👩‍👧", expected: 1},
+		{name: "国旗", text: "🇯🇵🇺🇸", expected: 2},
+		{name: "CRLF", text: "a\r\nb", expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := lib.GraphemeLength(tt.text); got != tt.expected {
+				t.Errorf("GraphemeLength(%q) = %d, want %d", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{name: "収まる場合は分割しない", text: "東京の雨雲", limit: 5, expected: []string{"東京の雨雲"}},
+		{name: "上限が0の場合は分割しない", text: "東京の雨雲", limit: 0, expected: []string{"東京の雨雲"}},
+		{name: "改行で区切る", text: "一行目の文章\n二行目の文章\n三行目の文章", limit: 10, expected: []string{"一行目の文章", "二行目の文章", "三行目の文章"}},
+		{name: "改行がなければ空白で区切る", text: "hello world again", limit: 12, expected: []string{"hello world", "again"}},
+		{name: "区切りがなければ上限で区切る", text: "あいうえおかきくけこさ", limit: 4, expected: []string{"あいうえ", "おかきく", "けこさ"}},
+		{name: "前半の改行では区切らない", text: "あ\nいうえおかき", limit: 4, expected: []string{"あ\nいう", "えおかき"}},
+		{name: "絵文字の途中では区切らない", text: "あい👨‍👩‍👧う", limit: 3, expected: []string{"あい👨‍👩‍👧", "う"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, lib.SplitText(tt.text, tt.limit)); diff != "" {
+				t.Errorf("SplitText() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// 環境変数（フラグを指定しない場合の既定値に使う）
+const (
+	URLEnv      = "HATO_HEALTH_CHECK_URL"      // 確認するURL
+	TimeoutEnv  = "HATO_HEALTH_CHECK_TIMEOUT"  // 1回のリクエストの制限時間（time.ParseDurationの形式）
+	RetriesEnv  = "HATO_HEALTH_CHECK_RETRIES"  // 失敗した場合に再試行する回数
+	ExpectEnv   = "HATO_HEALTH_CHECK_EXPECT"   // レスポンスのJSONに期待するフィールド（カンマ区切り）
+	InsecureEnv = "HATO_HEALTH_CHECK_INSECURE" // 空でない場合はTLSの証明書を検証しない
+)
+
+// 終了コード
+// Dockerは2を予約しているため使わない
+const (
+	ExitHealthy           = 0  // 正常
+	ExitUnhealthy         = 1  // 接続できたが、ステータスコードかレスポンスの内容が異常
+	ExitConnectionFailure = 3  // 接続できない、または制限時間内に応答がない
+	ExitUsage             = 64 // フラグや環境変数の値が不正
+)
+
+// DefaultURL URLを指定しない場合に確認するURL
+const DefaultURL = "http://localhost:8080/status"
+
+// maxResponseBytes 読み込むレスポンスボディの上限
+const maxResponseBytes = 1 << 20
+
+var (
+	// ErrConnection サーバーに接続できないか、制限時間内に応答がないことを表すエラー
+	ErrConnection = errors.New("connection failure")
+	// ErrUnhealthy サーバーが異常なステータスコードか期待しない内容を返したことを表すエラー
+	ErrUnhealthy = errors.New("unhealthy")
+	// ErrInvalidFlag フラグや環境変数の値が不正であることを表すエラー
+	ErrInvalidFlag = errors.New("invalid flag")
+)
+
+// Expectation レスポンスのJSONに期待するフィールド
+type Expectation struct {
+	Field string // トップレベルのフィールド名
+	Value string // 期待する値（空の場合はフィールドがあることのみ確認する）
+}
+
+// CheckParams ヘルスチェックのリクエスト構造体
+type CheckParams struct {
+	Client        httpclient.Doer // HTTPクライアント
+	URL           string          // 確認するURL
+	Timeout       time.Duration   // 1回のリクエストの制限時間
+	Retries       int             // 失敗した場合に再試行する回数
+	RetryInterval time.Duration   // 再試行するまでの待ち時間
+	Expect        []Expectation   // レスポンスのJSONに期待するフィールド
+}
+
+// Check URLにGETリクエストを送信し、成功のステータスコードと期待するフィールドを返すことを確認する
+// 失敗した場合はRetries回まで再試行し、最後の失敗をErrConnectionかErrUnhealthyで返す
+func Check(ctx context.Context, params *CheckParams) error {
+	if params == nil || params.Client == nil {
+		return errors.New("params or client is nil")
+	}
+
+	var err error
+	for attempt := 0; attempt <= params.Retries; attempt++ {
+		if 0 < attempt {
+			log.Printf("Health check failed (attempt %d/%d): %v", attempt, params.Retries+1, err)
+			timer := time.NewTimer(params.RetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Wrap(ErrConnection, ctx.Err().Error())
+			case <-timer.C:
+			}
+		}
+		if err = checkOnce(ctx, params); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkOnce 1回だけリクエストを送信して確認する
+func checkOnce(ctx context.Context, params *CheckParams) error {
+	if 0 < params.Timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params.URL, nil)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidFlag, "url: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	body, err := httpclient.ExecuteAndReadBody(params.Client, req, maxResponseBytes)
+	switch {
+	case errors.Is(err, httpclient.ErrHTTPRequestError), errors.Is(err, httpclient.ErrResponseTooLarge):
+		return errors.Wrap(ErrUnhealthy, err.Error())
+	case err != nil:
+		return errors.Wrap(ErrConnection, err.Error())
+	}
+	if len(params.Expect) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return errors.Wrapf(ErrUnhealthy, "response is not a JSON object: %v", err)
+	}
+	for _, expectation := range params.Expect {
+		raw, ok := fields[expectation.Field]
+		if !ok {
+			return errors.Wrapf(ErrUnhealthy, "field %q is missing", expectation.Field)
+		}
+		if expectation.Value == "" {
+			continue
+		}
+		if value := jsonValueString(raw); value != expectation.Value {
+			return errors.Wrapf(ErrUnhealthy, "field %q is %q, want %q", expectation.Field, value, expectation.Value)
+		}
+	}
+	return nil
+}
+
+// jsonValueString JSONの値を比較用の文字列にする
+// 文字列はそのままの値、それ以外は空白を除いたJSONの表記を返す
+func jsonValueString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// ParseExpectations 「フィールド」または「フィールド=値」のカンマ区切りの一覧を解析する
+func ParseExpectations(value string) ([]Expectation, error) {
+	var expectations []Expectation
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, expected, _ := strings.Cut(entry, "=")
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return nil, errors.Wrapf(ErrInvalidFlag, "expect: %q has no field name", entry)
+		}
+		expectations = append(expectations, Expectation{Field: field, Value: strings.TrimSpace(expected)})
+	}
+	return expectations, nil
+}
+
+// ParseFlags コマンドライン引数と環境変数からヘルスチェックの設定を作成する
+// フラグを指定しない場合は環境変数の値、環境変数もない場合は既定値を使う
+func ParseFlags(args []string, getenv func(string) string) (*CheckParams, error) {
+	timeout, err := envDuration(getenv, TimeoutEnv, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	retries, err := envInt(getenv, RetriesEnv, 0)
+	if err != nil {
+		return nil, err
+	}
+	url := getenv(URLEnv)
+	if url == "" {
+		url = DefaultURL
+	}
+
+	flags := flag.NewFlagSet("health_check", flag.ContinueOnError)
+	urlFlag := flags.String("url", url, "URL to check (env "+URLEnv+")")
+	timeoutFlag := flags.Duration("timeout", timeout, "timeout for each attempt (env "+TimeoutEnv+")")
+	retriesFlag := flags.Int("retries", retries, "number of retries after a failure (env "+RetriesEnv+")")
+	intervalFlag := flags.Duration("retry-interval", time.Second, "wait between attempts")
+	expectFlag := flags.String("expect", getenv(ExpectEnv), "comma-separated JSON fields to expect, as field or field=value (env "+ExpectEnv+")")
+	insecureFlag := flags.Bool("insecure", getenv(InsecureEnv) != "", "skip TLS certificate verification (env "+InsecureEnv+")")
+	if err := flags.Parse(args); err != nil {
+		return nil, errors.Wrap(ErrInvalidFlag, err.Error())
+	}
+	if 0 < flags.NArg() {
+		return nil, errors.Wrapf(ErrInvalidFlag, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if *timeoutFlag <= 0 {
+		return nil, errors.Wrapf(ErrInvalidFlag, "timeout: %s", *timeoutFlag)
+	}
+	if *retriesFlag < 0 {
+		return nil, errors.Wrapf(ErrInvalidFlag, "retries: %d", *retriesFlag)
+	}
+	if *intervalFlag < 0 {
+		return nil, errors.Wrapf(ErrInvalidFlag, "retry-interval: %s", *intervalFlag)
+	}
+	expectations, err := ParseExpectations(*expectFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CheckParams{
+		Client:        newClient(*insecureFlag),
+		URL:           *urlFlag,
+		Timeout:       *timeoutFlag,
+		Retries:       *retriesFlag,
+		RetryInterval: *intervalFlag,
+		Expect:        expectations,
+	}, nil
+}
+
+// newClient ヘルスチェック用のHTTPクライアントを作成する
+// insecureの場合はlocalhostで自己署名やautocertの証明書を使うサーバーを確認できるよう、証明書を検証しない
+func newClient(insecure bool) *http.Client {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok || !insecure {
+		return &http.Client{}
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // ローカルのサーバーの確認で明示的に指定した場合のみ
+	return &http.Client{Transport: transport}
+}
+
+// envDuration 環境変数の値を時間として解析する
+func envDuration(getenv func(string) string, key string, fallback time.Duration) (time.Duration, error) {
+	value := getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidFlag, "%s: %v", key, err)
+	}
+	return duration, nil
+}
+
+// envInt 環境変数の値を整数として解析する
+func envInt(getenv func(string) string, key string, fallback int) (int, error) {
+	value := getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidFlag, "%s: %v", key, err)
+	}
+	return n, nil
+}
+
+// ExitCode Checkなどのエラーに対応する終了コードを返す
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitHealthy
+	case errors.Is(err, ErrInvalidFlag):
+		return ExitUsage
+	case errors.Is(err, ErrConnection):
+		return ExitConnectionFailure
+	default:
+		return ExitUnhealthy
+	}
+}
@@ -0,0 +1,248 @@
+package healthcheck_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"hato-bot-go/lib/healthcheck"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		failures      int32 // 最初に500を返す回数
+		retries       int
+		expect        []healthcheck.Expectation
+		expectedError error
+		expectedCalls int32
+	}{
+		{
+			name:          "正常",
+			status:        http.StatusOK,
+			body:          `{"message":"hato-bot-go is running","version":"1.0.0"}`,
+			expectedCalls: 1,
+		},
+		{
+			name:   "期待するフィールド",
+			status: http.StatusOK,
+			body:   `{"message":"hato-bot-go is running","version":"1.0.0","ready":true}`,
+			expect: []healthcheck.Expectation{
+				{Field: "version"},
+				{Field: "message", Value: "hato-bot-go is running"},
+				{Field: "ready", Value: "true"},
+			},
+			expectedCalls: 1,
+		},
+		{
+			name:          "フィールドがない",
+			status:        http.StatusOK,
+			body:          `{"message":"hato-bot-go is running"}`,
+			expect:        []healthcheck.Expectation{{Field: "version"}},
+			expectedError: healthcheck.ErrUnhealthy,
+			expectedCalls: 1,
+		},
+		{
+			name:          "フィールドの値が異なる",
+			status:        http.StatusOK,
+			body:          `{"ready":false}`,
+			expect:        []healthcheck.Expectation{{Field: "ready", Value: "true"}},
+			expectedError: healthcheck.ErrUnhealthy,
+			expectedCalls: 1,
+		},
+		{
+			name:          "JSONでない",
+			status:        http.StatusOK,
+			body:          `ok`,
+			expect:        []healthcheck.Expectation{{Field: "message"}},
+			expectedError: healthcheck.ErrUnhealthy,
+			expectedCalls: 1,
+		},
+		{
+			name:          "異常なステータスコード",
+			status:        http.StatusServiceUnavailable,
+			body:          `{}`,
+			expectedError: healthcheck.ErrUnhealthy,
+			expectedCalls: 1,
+		},
+		{
+			name:          "再試行で成功",
+			status:        http.StatusOK,
+			body:          `{}`,
+			failures:      2,
+			retries:       2,
+			expectedCalls: 3,
+		},
+		{
+			name:          "再試行しても失敗",
+			status:        http.StatusOK,
+			body:          `{}`,
+			failures:      3,
+			retries:       2,
+			expectedError: healthcheck.ErrUnhealthy,
+			expectedCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if calls.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			err := healthcheck.Check(context.Background(), &healthcheck.CheckParams{
+				Client:  server.Client(),
+				URL:     server.URL + "/status",
+				Timeout: time.Second,
+				Retries: tt.retries,
+				Expect:  tt.expect,
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.expectedError)
+			}
+			if got := calls.Load(); got != tt.expectedCalls {
+				t.Errorf("calls = %d, want %d", got, tt.expectedCalls)
+			}
+		})
+	}
+}
+
+func TestCheckConnectionFailure(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	err := healthcheck.Check(context.Background(), &healthcheck.CheckParams{
+		Client:  http.DefaultClient,
+		URL:     url,
+		Timeout: time.Second,
+	})
+	if !errors.Is(err, healthcheck.ErrConnection) {
+		t.Fatalf("Check() error = %v, want %v", err, healthcheck.ErrConnection)
+	}
+	if code := healthcheck.ExitCode(err); code != healthcheck.ExitConnectionFailure {
+		t.Errorf("ExitCode() = %d, want %d", code, healthcheck.ExitConnectionFailure)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	err := healthcheck.Check(context.Background(), &healthcheck.CheckParams{
+		Client:  server.Client(),
+		URL:     server.URL,
+		Timeout: 50 * time.Millisecond,
+	})
+	if !errors.Is(err, healthcheck.ErrConnection) {
+		t.Fatalf("Check() error = %v, want %v", err, healthcheck.ErrConnection)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		env           map[string]string
+		expected      *healthcheck.CheckParams
+		expectedError error
+	}{
+		{
+			name: "既定値",
+			expected: &healthcheck.CheckParams{
+				URL:           healthcheck.DefaultURL,
+				Timeout:       5 * time.Second,
+				RetryInterval: time.Second,
+			},
+		},
+		{
+			name: "環境変数",
+			env: map[string]string{
+				healthcheck.URLEnv:     "https://localhost:8443/status",
+				healthcheck.TimeoutEnv: "2s",
+				healthcheck.RetriesEnv: "3",
+				healthcheck.ExpectEnv:  "version, message=hato-bot-go is running",
+			},
+			expected: &healthcheck.CheckParams{
+				URL:           "https://localhost:8443/status",
+				Timeout:       2 * time.Second,
+				Retries:       3,
+				RetryInterval: time.Second,
+				Expect: []healthcheck.Expectation{
+					{Field: "version"},
+					{Field: "message", Value: "hato-bot-go is running"},
+				},
+			},
+		},
+		{
+			name: "フラグは環境変数より優先する",
+			args: []string{"-url", "http://127.0.0.1:9090/status", "-timeout", "1s", "-retries", "1", "-retry-interval", "0s"},
+			env:  map[string]string{healthcheck.URLEnv: "http://localhost:8080/status", healthcheck.RetriesEnv: "5"},
+			expected: &healthcheck.CheckParams{
+				URL:     "http://127.0.0.1:9090/status",
+				Timeout: time.Second,
+				Retries: 1,
+			},
+		},
+		{
+			name:          "解析できない環境変数",
+			env:           map[string]string{healthcheck.TimeoutEnv: "5"},
+			expectedError: healthcheck.ErrInvalidFlag,
+		},
+		{
+			name:          "負の再試行回数",
+			args:          []string{"-retries", "-1"},
+			expectedError: healthcheck.ErrInvalidFlag,
+		},
+		{
+			name:          "フィールド名がない",
+			args:          []string{"-expect", "=ok"},
+			expectedError: healthcheck.ErrInvalidFlag,
+		},
+		{
+			name:          "存在しないフラグ",
+			args:          []string{"-port", "8080"},
+			expectedError: healthcheck.ErrInvalidFlag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := healthcheck.ParseFlags(tt.args, func(key string) string { return tt.env[key] })
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseFlags() error = %v, want %v", err, tt.expectedError)
+			}
+			if err != nil {
+				if code := healthcheck.ExitCode(err); code != healthcheck.ExitUsage {
+					t.Errorf("ExitCode() = %d, want %d", code, healthcheck.ExitUsage)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.expected, result, cmpopts.IgnoreFields(healthcheck.CheckParams{}, "Client")); diff != "" {
+				t.Errorf("ParseFlags() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
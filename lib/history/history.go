@@ -0,0 +1,346 @@
+package history
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Outcome コマンドの処理結果
+type Outcome string
+
+const (
+	OutcomeSuccess     Outcome = "success"      // 返信に成功した
+	OutcomeError       Outcome = "error"        // 処理中にエラーが発生した
+	OutcomeTimeout     Outcome = "timeout"      // 制限時間を超えた
+	OutcomeRateLimited Outcome = "rate_limited" // 実行回数の上限に達した
+)
+
+// topPlaceLimit 統計に含めるよく使われる地名の数
+const topPlaceLimit = 10
+
+// compactMinLines 実行中にファイルを書き直す最小の行数
+// 保持期間を過ぎた行がファイルの半分を超えた場合に書き直す
+const compactMinLines = 64
+
+// Record 処理したコマンドの記録
+type Record struct {
+	At         time.Time `json:"at"`          // 処理を開始した時刻
+	User       string    `json:"user"`        // 送信者のハッシュ（HashUserで作成し、IDそのものは保存しない）
+	Platform   string    `json:"platform"`    // プラットフォーム名
+	Command    string    `json:"command"`     // コマンド名
+	Place      string    `json:"place"`       // 地名（地名をとるコマンドのみ）
+	DurationMS int64     `json:"duration_ms"` // 処理時間（ミリ秒）
+	Outcome    Outcome   `json:"outcome"`     // 処理結果
+}
+
+// HashUser プラットフォームと送信者のIDから記録に保存するハッシュをsecretを鍵としたHMAC-SHA256で作成する
+// 鍵を知らなければ送信者のIDの候補からハッシュを計算して照合できない
+// 送信者のIDが空の場合は空文字列を返す
+func HashUser(secret []byte, platform, userID string) string {
+	if userID == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(platform + ":" + userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// StoreSetting Storeの設定
+type StoreSetting struct {
+	Path      string           // 記録を追記するJSON Linesファイルのパス（空の場合はメモリのみに保存する）
+	Retention time.Duration    // 記録を保持する期間（0以下の場合は無期限）
+	Secret    []byte           // 送信者のハッシュの鍵（空の場合は起動ごとに乱数で作成するため、再起動をまたいで送信者を数えられない）
+	Now       func() time.Time // 現在時刻を返す関数（nilの場合はtime.Now）
+}
+
+// Store コマンドの処理の記録を保存する
+// 複数のgoroutineから同時に使える
+type Store struct {
+	setting   StoreSetting
+	mu        sync.Mutex
+	records   []Record
+	file      *os.File
+	fileLines int // 追記用のファイルの行数
+}
+
+// NewStore 新しいStoreを作成する
+// ファイルがある場合は保持期間内の記録を読み込み、保持期間を過ぎた記録を除いて書き直す
+func NewStore(setting *StoreSetting) (*Store, error) {
+	if setting == nil {
+		setting = &StoreSetting{}
+	}
+	s := &Store{setting: *setting}
+	if s.setting.Now == nil {
+		s.setting.Now = time.Now
+	}
+	if len(s.setting.Secret) == 0 {
+		s.setting.Secret = make([]byte, sha256.Size)
+		if _, err := rand.Read(s.setting.Secret); err != nil {
+			return nil, errors.Wrap(err, "Failed to rand.Read")
+		}
+	}
+	if s.setting.Path == "" {
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, errors.Wrap(err, "Failed to load")
+	}
+	if err := s.compact(); err != nil {
+		return nil, errors.Wrap(err, "Failed to compact")
+	}
+	return s, nil
+}
+
+// HashUser プラットフォームと送信者のIDから記録に保存するハッシュを作成する
+// レシーバーがnilの場合は空文字列を返す
+func (s *Store) HashUser(platform, userID string) string {
+	if s == nil {
+		return ""
+	}
+	return HashUser(s.setting.Secret, platform, userID)
+}
+
+// load ファイルから保持期間内の記録を読み込む
+// 書き込みの途中で停止した場合などの解析できない行はログに出力して読み飛ばす
+func (s *Store) load() (err error) {
+	file, err := os.Open(s.setting.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Open")
+	}
+	defer func(file io.Closer) {
+		if closeErr := file.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("Skipped invalid history record at %s:%d: %v", s.setting.Path, line, err)
+			continue
+		}
+		s.records = append(s.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "Failed to Scan")
+	}
+	s.prune()
+	return nil
+}
+
+// compact 保持期間内の記録でファイルを書き直し、追記用に開く（起動時以外はロック取得済みで呼び出す）
+func (s *Store) compact() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return errors.Wrap(err, "Failed to Close")
+		}
+		s.file = nil
+	}
+
+	tmpPath := s.setting.Path + ".tmp"
+	tmp, err := os.Create(tmpPath) //nolint:gosec // 運用者が指定したパスに書き込む
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Create")
+	}
+	encoder := json.NewEncoder(tmp)
+	for i := range s.records {
+		if err := encoder.Encode(&s.records[i]); err != nil {
+			return errors.Join(errors.Wrap(err, "Failed to Encode"), tmp.Close())
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "Failed to Close")
+	}
+	if err := os.Rename(tmpPath, s.setting.Path); err != nil {
+		return errors.Wrap(err, "Failed to os.Rename")
+	}
+
+	s.file, err = os.OpenFile(s.setting.Path, os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // 運用者が指定したパスに書き込む
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.OpenFile")
+	}
+	s.fileLines = len(s.records)
+	return nil
+}
+
+// prune 保持期間を過ぎた記録を取り除く（ロック取得済みで呼び出す）
+func (s *Store) prune() {
+	if s.setting.Retention <= 0 {
+		return
+	}
+	cutoff := s.setting.Now().Add(-s.setting.Retention)
+	s.records = slices.DeleteFunc(s.records, func(record Record) bool {
+		return record.At.Before(cutoff)
+	})
+}
+
+// Add 記録を追加する
+// 保持期間を過ぎた行がファイルの半分を超えた場合はファイルを書き直す
+// レシーバーがnilの場合は何もしない
+func (s *Store) Add(record *Record) error {
+	if s == nil || record == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, *record)
+	s.prune()
+	if s.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "Failed to json.Marshal")
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "Failed to Write")
+	}
+	s.fileLines++
+
+	if compactMinLines <= s.fileLines && 2*len(s.records) < s.fileLines {
+		if err := s.compact(); err != nil {
+			return errors.Wrap(err, "Failed to compact")
+		}
+	}
+	return nil
+}
+
+// Close 追記用のファイルを閉じる
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.Close(); err != nil {
+		return errors.Wrap(err, "Failed to Close")
+	}
+	s.file = nil
+	return nil
+}
+
+// CommandStats コマンドごとの集計
+type CommandStats struct {
+	Count             int   `json:"count"`               // 実行回数
+	Success           int   `json:"success"`             // 成功した回数
+	Errors            int   `json:"errors"`              // エラーになった回数
+	Timeouts          int   `json:"timeouts"`            // 制限時間を超えた回数
+	RateLimited       int   `json:"rate_limited"`        // 実行回数の上限に達した回数
+	AverageDurationMS int64 `json:"average_duration_ms"` // 平均処理時間（ミリ秒）
+}
+
+// PlaceCount 地名ごとの実行回数
+type PlaceCount struct {
+	Place string `json:"place"`
+	Count int    `json:"count"`
+}
+
+// Stats 保持期間内の記録の集計
+type Stats struct {
+	Since     *time.Time               `json:"since,omitempty"` // 最も古い記録の時刻（記録がない場合はnil）
+	Total     int                      `json:"total"`           // 記録の数
+	Users     int                      `json:"users"`           // 送信者の数
+	Commands  map[string]*CommandStats `json:"commands"`        // コマンドごとの集計
+	TopPlaces []PlaceCount             `json:"top_places"`      // よく使われる地名（多い順）
+}
+
+// Stats 保持期間内の記録を集計する
+// レシーバーがnilの場合は空の集計を返す
+func (s *Store) Stats() *Stats {
+	stats := &Stats{Commands: map[string]*CommandStats{}, TopPlaces: []PlaceCount{}}
+	if s == nil {
+		return stats
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+
+	users := map[string]struct{}{}
+	places := map[string]int{}
+	durations := map[string]int64{}
+	for _, record := range s.records {
+		if stats.Since == nil || record.At.Before(*stats.Since) {
+			at := record.At
+			stats.Since = &at
+		}
+		stats.Total++
+		if record.User != "" {
+			users[record.User] = struct{}{}
+		}
+		if record.Place != "" {
+			places[record.Place]++
+		}
+
+		commandStats, ok := stats.Commands[record.Command]
+		if !ok {
+			commandStats = &CommandStats{}
+			stats.Commands[record.Command] = commandStats
+		}
+		commandStats.Count++
+		durations[record.Command] += record.DurationMS
+		switch record.Outcome {
+		case OutcomeSuccess:
+			commandStats.Success++
+		case OutcomeTimeout:
+			commandStats.Timeouts++
+		case OutcomeRateLimited:
+			commandStats.RateLimited++
+		default:
+			commandStats.Errors++
+		}
+	}
+	for command, commandStats := range stats.Commands {
+		commandStats.AverageDurationMS = durations[command] / int64(commandStats.Count)
+	}
+	stats.Users = len(users)
+
+	for place, count := range places {
+		stats.TopPlaces = append(stats.TopPlaces, PlaceCount{Place: place, Count: count})
+	}
+	slices.SortFunc(stats.TopPlaces, func(a, b PlaceCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Place, b.Place))
+	})
+	if topPlaceLimit < len(stats.TopPlaces) {
+		stats.TopPlaces = stats.TopPlaces[:topPlaceLimit]
+	}
+	return stats
+}
+
+// Handler 集計をJSONで返すHTTPハンドラーを返す
+func Handler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(s.Stats()); err != nil {
+			log.Printf("Failed to Encode: %v", err)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package history_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/history"
+)
+
+// testNow テストで使う現在時刻
+var testNow = time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+func TestHashUser(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	if got := history.HashUser(secret, "misskey", ""); got != "" {
+		t.Errorf("HashUser() with empty ID = %q, want empty", got)
+	}
+	hash := history.HashUser(secret, "misskey", "u1")
+	if len(hash) != 64 || strings.Contains(hash, "u1") {
+		t.Errorf("HashUser() = %q, want 64 hex chars without the ID", hash)
+	}
+	if hash != history.HashUser(secret, "misskey", "u1") {
+		t.Error("HashUser() is not stable")
+	}
+	if hash == history.HashUser(secret, "mixi2", "u1") {
+		t.Error("HashUser() should differ between platforms")
+	}
+	if hash == history.HashUser([]byte("other"), "misskey", "u1") {
+		t.Error("HashUser() should differ between secrets")
+	}
+	if hash == history.HashUser(nil, "misskey", "u1") {
+		t.Error("HashUser() should not match the hash without a secret")
+	}
+}
+
+func TestStoreHashUser(t *testing.T) {
+	t.Parallel()
+	store, err := history.NewStore(&history.StoreSetting{Secret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := store.HashUser("misskey", "u1"), history.HashUser([]byte("secret"), "misskey", "u1"); got != want {
+		t.Errorf("Store.HashUser() = %q, want %q", got, want)
+	}
+
+	// 鍵を指定しない場合はStoreごとに乱数の鍵を使う
+	first, err := history.NewStore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := history.NewStore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.HashUser("misskey", "u1") == second.HashUser("misskey", "u1") {
+		t.Error("Store.HashUser() without a secret should differ between stores")
+	}
+
+	var nilStore *history.Store
+	if got := nilStore.HashUser("misskey", "u1"); got != "" {
+		t.Errorf("nil Store.HashUser() = %q, want empty", got)
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  []history.Record
+		expected *history.Stats
+	}{
+		{
+			name:    "記録なし",
+			records: nil,
+			expected: &history.Stats{
+				Commands:  map[string]*history.CommandStats{},
+				TopPlaces: []history.PlaceCount{},
+			},
+		},
+		{
+			name: "コマンドごとと地名ごとの集計",
+			records: []history.Record{
+				{At: testNow.Add(-2 * time.Hour), User: "a", Command: "amesh", Place: "東京", DurationMS: 100, Outcome: history.OutcomeSuccess},
+				{At: testNow.Add(-time.Hour), User: "b", Command: "amesh", Place: "大阪", DurationMS: 300, Outcome: history.OutcomeError},
+				{At: testNow, User: "a", Command: "amesh", Place: "東京", DurationMS: 200, Outcome: history.OutcomeTimeout},
+				{At: testNow, User: "", Command: "amedas", Place: "", DurationMS: 50, Outcome: history.OutcomeRateLimited},
+			},
+			expected: &history.Stats{
+				Since: new(testNow.Add(-2 * time.Hour)),
+				Total: 4,
+				Users: 2,
+				Commands: map[string]*history.CommandStats{
+					"amesh":  {Count: 3, Success: 1, Errors: 1, Timeouts: 1, AverageDurationMS: 200},
+					"amedas": {Count: 1, RateLimited: 1, AverageDurationMS: 50},
+				},
+				TopPlaces: []history.PlaceCount{{Place: "東京", Count: 2}, {Place: "大阪", Count: 1}},
+			},
+		},
+		{
+			name: "保持期間を過ぎた記録は集計しない",
+			records: []history.Record{
+				{At: testNow.Add(-48 * time.Hour), User: "a", Command: "amesh", Outcome: history.OutcomeSuccess},
+				{At: testNow, User: "b", Command: "amesh", Outcome: history.OutcomeSuccess},
+			},
+			expected: &history.Stats{
+				Since:     new(testNow),
+				Total:     1,
+				Users:     1,
+				Commands:  map[string]*history.CommandStats{"amesh": {Count: 1, Success: 1}},
+				TopPlaces: []history.PlaceCount{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store, err := history.NewStore(&history.StoreSetting{
+				Retention: 24 * time.Hour,
+				Now:       func() time.Time { return testNow },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.records {
+				if err := store.Add(&tt.records[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if diff := cmp.Diff(tt.expected, store.Stats()); diff != "" {
+				t.Errorf("Stats() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStoreStatsTopPlaceLimit(t *testing.T) {
+	t.Parallel()
+	store, err := history.NewStore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 15 {
+		if err := store.Add(&history.Record{At: testNow, Command: "amesh", Place: fmt.Sprintf("place%02d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(store.Stats().TopPlaces); got != 10 {
+		t.Errorf("len(TopPlaces) = %d, want 10", got)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := testNow
+	setting := &history.StoreSetting{
+		Path:      path,
+		Retention: 24 * time.Hour,
+		Now:       func() time.Time { return now },
+	}
+
+	store, err := history.NewStore(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []history.Record{
+		{At: testNow.Add(-12 * time.Hour), User: "a", Command: "amesh", Outcome: history.OutcomeSuccess},
+		{At: testNow, User: "b", Command: "amesh", Outcome: history.OutcomeSuccess},
+	} {
+		if err := store.Add(&record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再起動時に保持期間を過ぎた記録を除いてファイルを書き直す
+	now = testNow.Add(18 * time.Hour)
+	reopened, err := history.NewStore(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := reopened.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	if got := reopened.Stats().Total; got != 1 {
+		t.Errorf("Stats().Total = %d, want 1", got)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"user":"b"`) {
+		t.Errorf("file content = %q, want only the record of user b", content)
+	}
+}
+
+func TestNewStoreInvalidLines(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	content := `{"at":"2026-01-31T11:00:00Z","user":"a","command":"amesh","outcome":"success"}` + "\n" +
+		"not json\n" +
+		`{"at":"2026-01-31T11:30:00Z","user":"b","command":"ame` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := history.NewStore(&history.StoreSetting{Path: path, Now: func() time.Time { return testNow }})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := store.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	if got := store.Stats().Total; got != 1 {
+		t.Errorf("Stats().Total = %d, want 1", got)
+	}
+
+	// 解析できない行は書き直したファイルに残さない
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(written)), "\n"); len(lines) != 1 {
+		t.Errorf("file lines = %d, want 1", len(lines))
+	}
+}
+
+func TestStoreCompactWhileRunning(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := testNow
+	store, err := history.NewStore(&history.StoreSetting{
+		Path:      path,
+		Retention: time.Hour,
+		Now:       func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := store.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// 1分ごとに記録を追加し、保持期間を過ぎた行が溜まり続けないことを確認する
+	for i := range 300 {
+		now = testNow.Add(time.Duration(i) * time.Minute)
+		if err := store.Add(&history.Record{At: now, Command: "amesh", Outcome: history.OutcomeSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if total := store.Stats().Total; len(lines) < total || 2*total < len(lines) {
+		t.Errorf("file lines = %d, want between %d and %d", len(lines), total, 2*total)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	t.Parallel()
+	var store *history.Store
+	if err := store.Add(&history.Record{Command: "amesh"}); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+	if got := store.Stats().Total; got != 0 {
+		t.Errorf("Stats().Total = %d, want 0", got)
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	store, err := history.NewStore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(&history.Record{At: testNow, User: "a", Command: "amesh", Place: "東京", Outcome: history.OutcomeSuccess}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	history.Handler(store)(recorder, httptest.NewRequest(http.MethodGet, "/stats", http.NoBody))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	var stats history.Stats
+	if err := json.Unmarshal(recorder.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	expected := history.Stats{
+		Since:     new(testNow),
+		Total:     1,
+		Users:     1,
+		Commands:  map[string]*history.CommandStats{"amesh": {Count: 1, Success: 1}},
+		TopPlaces: []history.PlaceCount{{Place: "東京", Count: 1}},
+	}
+	if diff := cmp.Diff(expected, stats); diff != "" {
+		t.Errorf("response mismatch (-want +got):\n%s", diff)
+	}
+}
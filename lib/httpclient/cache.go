@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/metrics"
+)
+
+// ResponseCacheSetting レスポンスキャッシュの設定
+type ResponseCacheSetting struct {
+	Name    string            // メトリクス名の接頭辞に使うキャッシュ名
+	TTL     time.Duration     // 再検証なしでキャッシュを返す期間
+	Metrics *metrics.Registry // ヒット数などを記録するレジストリ（nilの場合はmetrics.Default）
+	Clock   clock.Clock       // TTLの判定に使う時計（nilの場合はclock.Real）
+}
+
+// CacheStats キャッシュの利用状況
+type CacheStats struct {
+	Hits          int64 // TTL内でキャッシュをそのまま返した回数
+	Revalidations int64 // 条件付きGETで304を受け取りキャッシュを再利用した回数
+	Misses        int64 // レスポンス本体を取得した回数
+}
+
+// cacheEntry キャッシュされたレスポンス
+type cacheEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	storedAt     time.Time
+}
+
+// ResponseCache ETag/Last-Modifiedを利用した条件付きGETでレスポンスボディを短時間キャッシュする
+type ResponseCache struct {
+	mu            sync.Mutex
+	setting       ResponseCacheSetting
+	entries       map[string]*cacheEntry
+	hits          *metrics.Counter
+	revalidations *metrics.Counter
+	misses        *metrics.Counter
+}
+
+// NewResponseCache 新しいレスポンスキャッシュを作成する
+func NewResponseCache(setting *ResponseCacheSetting) *ResponseCache {
+	s := ResponseCacheSetting{}
+	if setting != nil {
+		s = *setting
+	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.Default
+	}
+	s.Clock = clock.Or(s.Clock)
+
+	prefix := "httpcache." + s.Name + "."
+	return &ResponseCache{
+		setting:       s,
+		entries:       make(map[string]*cacheEntry),
+		hits:          s.Metrics.Counter(prefix + "hits"),
+		revalidations: s.Metrics.Counter(prefix + "revalidations"),
+		misses:        s.Metrics.Counter(prefix + "misses"),
+	}
+}
+
+// Get URLのレスポンスボディを取得する
+// TTL内であればキャッシュを返し、期限切れであれば条件付きGETで再検証する
+// レシーバーがnilの場合はキャッシュせずに毎回取得する
+func (c *ResponseCache) Get(ctx context.Context, client Doer, url string) ([]byte, error) {
+	var entry *cacheEntry
+	if c != nil {
+		c.mu.Lock()
+		entry = c.entries[url]
+		c.mu.Unlock()
+
+		if entry != nil && c.setting.Clock.Now().Sub(entry.storedAt) < c.setting.TTL {
+			c.hits.Inc()
+			return entry.body, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	statuses := successStatuses
+	if entry != nil {
+		// キャッシュがある場合だけ条件付きGETにし、304を受け付ける
+		statuses = conditionalStatuses
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := executeHTTPRequest(client, req, statuses)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to executeHTTPRequest")
+	}
+
+	body, err := ReadResponseBody(resp, DefaultMaxResponseBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ReadResponseBody")
+	}
+
+	if c == nil {
+		return body, nil
+	}
+
+	now := c.setting.Clock.Now()
+	if resp.StatusCode == http.StatusNotModified {
+		c.revalidations.Inc()
+		c.store(url, &cacheEntry{
+			body:         entry.body,
+			etag:         entry.etag,
+			lastModified: entry.lastModified,
+			storedAt:     now,
+		})
+		return entry.body, nil
+	}
+
+	c.misses.Inc()
+	c.store(url, &cacheEntry{
+		body:         body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		storedAt:     now,
+	})
+	return body, nil
+}
+
+// store キャッシュエントリーを保存する
+func (c *ResponseCache) store(url string, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[url] = entry
+}
+
+// Stats キャッシュの利用状況を返す
+func (c *ResponseCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.hits.Value(),
+		Revalidations: c.revalidations.Value(),
+		Misses:        c.misses.Value(),
+	}
+}
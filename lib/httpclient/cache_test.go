@@ -0,0 +1,152 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/metrics"
+)
+
+// TestResponseCache ResponseCacheのTTLと条件付きGETをテストする
+func TestResponseCache(t *testing.T) {
+	const targetURL = "https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N1.json"
+	etagHeader := http.Header{"Etag": []string{`"v1"`}, "Last-Modified": []string{"Mon, 01 Jan 2024 12:00:00 GMT"}}
+
+	type step struct {
+		advance     time.Duration // リクエスト前に進める時間
+		expectBody  string
+		expectError error
+	}
+
+	tests := []struct {
+		name                string
+		responses           []httpclient.MockResponse
+		steps               []step
+		expectStats         httpclient.CacheStats
+		expectConditionalAt []int // 条件付きヘッダーが付与されるべきリクエストの番号
+	}{
+		{
+			name:      "TTL内はキャッシュを返す",
+			responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: "body1", Header: etagHeader}},
+			steps: []step{
+				{expectBody: "body1"},
+				{advance: 10 * time.Second, expectBody: "body1"},
+				{advance: 10 * time.Second, expectBody: "body1"},
+			},
+			expectStats:         httpclient.CacheStats{Hits: 2, Misses: 1},
+			expectConditionalAt: nil,
+		},
+		{
+			name: "期限切れは条件付きGETで再検証する",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: "body1", Header: etagHeader},
+				{StatusCode: http.StatusNotModified},
+				{StatusCode: http.StatusOK, Body: "body2"},
+			},
+			steps: []step{
+				{expectBody: "body1"},
+				{advance: time.Minute, expectBody: "body1"},
+				{advance: time.Minute, expectBody: "body2"},
+			},
+			expectStats:         httpclient.CacheStats{Revalidations: 1, Misses: 2},
+			expectConditionalAt: []int{1, 2},
+		},
+		{
+			name: "キャッシュがない場合の304はエラーにしてキャッシュしない",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusNotModified},
+				{StatusCode: http.StatusOK, Body: "body1"},
+			},
+			steps: []step{
+				{expectError: httpclient.ErrHTTPRequestError},
+				{expectBody: "body1"},
+			},
+			expectStats:         httpclient.CacheStats{Misses: 1},
+			expectConditionalAt: nil,
+		},
+		{
+			name: "エラーはキャッシュしない",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusInternalServerError},
+				{StatusCode: http.StatusOK, Body: "body1"},
+			},
+			steps: []step{
+				{expectError: httpclient.ErrHTTPRequestError},
+				{expectBody: "body1"},
+			},
+			expectStats:         httpclient.CacheStats{Misses: 1},
+			expectConditionalAt: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fake := clocktest.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{{Pattern: "targetTimes", Responses: tt.responses}},
+			})
+			cache := httpclient.NewResponseCache(&httpclient.ResponseCacheSetting{
+				Name:    "test",
+				TTL:     30 * time.Second,
+				Metrics: metrics.NewRegistry(),
+				Clock:   fake,
+			})
+
+			for i, s := range tt.steps {
+				fake.Advance(s.advance)
+				body, err := cache.Get(t.Context(), transport.Client(), targetURL)
+				if !errors.Is(err, s.expectError) {
+					t.Errorf("step %d error = %v, expectError = %v", i, err, s.expectError)
+					continue
+				}
+				if s.expectError == nil && string(body) != s.expectBody {
+					t.Errorf("step %d body = %q, want %q", i, body, s.expectBody)
+				}
+			}
+
+			if diff := cmp.Diff(cache.Stats(), tt.expectStats); diff != "" {
+				t.Errorf("Stats() diff: %s", diff)
+			}
+
+			var conditionalAt []int
+			for i, req := range transport.Requests() {
+				if req.Header.Get("If-None-Match") != "" {
+					conditionalAt = append(conditionalAt, i)
+				}
+			}
+			if diff := cmp.Diff(conditionalAt, tt.expectConditionalAt); diff != "" {
+				t.Errorf("conditional requests diff: %s", diff)
+			}
+		})
+	}
+}
+
+// TestResponseCacheNil nilのResponseCacheはキャッシュせずに取得することをテストする
+func TestResponseCacheNil(t *testing.T) {
+	t.Parallel()
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: "body"},
+	})
+
+	var cache *httpclient.ResponseCache
+	for range 2 {
+		body, err := cache.Get(t.Context(), transport.Client(), "https://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "body" {
+			t.Errorf("Get() body = %q, want %q", body, "body")
+		}
+	}
+
+	if called := len(transport.Requests()); called != 2 {
+		t.Errorf("transport called %d times, want 2", called)
+	}
+}
@@ -0,0 +1,263 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrCircuitOpen サーキットブレーカーが開いているため送信を中止したことを表すエラー
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// 外部サービスの識別子
+const (
+	UpstreamYahooGeocoder = "yahoo_geocoder" // Yahoo!ジオコーダAPI
+	UpstreamNominatim     = "nominatim"      // Nominatim（OpenStreetMapのジオコーダ）
+	UpstreamJMA           = "jma"            // 気象庁（タイル・JSON）
+	UpstreamOSM           = "osm"            // OpenStreetMapタイル
+	UpstreamMisskey       = "misskey"        // Misskey API
+	UpstreamWikipedia     = "wikipedia"      // Wikipedia API
+	UpstreamExchangeRate  = "exchange_rate"  // 為替レートAPI
+	UpstreamGSI           = "gsi"            // 国土地理院（標高API）
+)
+
+// upstreamHosts ホスト名と外部サービスの対応表（RegisterUpstreamで追加する）
+var upstreamHosts = map[string]string{
+	"map.yahooapis.jp":            UpstreamYahooGeocoder,
+	"nominatim.openstreetmap.org": UpstreamNominatim,
+	"www.jma.go.jp":               UpstreamJMA,
+	"tile.openstreetmap.org":      UpstreamOSM,
+	"ja.wikipedia.org":            UpstreamWikipedia,
+	"open.er-api.com":             UpstreamExchangeRate,
+	"cyberjapandata2.gsi.go.jp":   UpstreamGSI,
+}
+
+// upstreamHostsMu upstreamHostsを保護するロック
+var upstreamHostsMu sync.RWMutex
+
+// DefaultTimeoutTransport DefaultTransportがリクエストの種類ごとの制限時間を適用するRoundTripper（SetTimeoutsで設定する）
+// User-Agentのないリクエストには共通のUser-Agentを設定してから送信する
+var DefaultTimeoutTransport = NewTimeoutTransport(&UserAgentTransport{Base: http.DefaultTransport}, nil)
+
+// DefaultQuotaTransport DefaultTransportが外部サービスへのリクエスト数を数えるRoundTripper（SetQuotaLimitsで目安を設定する）
+// サーキットブレーカーで送信しなかったリクエストは数えない
+var DefaultQuotaTransport = NewQuotaTransport(DefaultTimeoutTransport, nil)
+
+// DefaultTransport パッケージをまたいで共有するサーキットブレーカー付きのRoundTripper
+// 同じ外部サービスへのリクエストはどのパッケージから送っても同じブレーカーで数え、
+// 制限時間を超えたリクエストも外部サービスの不調として数える
+var DefaultTransport = NewCircuitBreakerTransport(DefaultQuotaTransport, nil)
+
+// RegisterUpstream ホスト名を外部サービスの識別子に対応付ける
+// 設定で決まるホスト（Misskeyインスタンスなど）を起動時に登録する
+func RegisterUpstream(host, upstream string) {
+	upstreamHostsMu.Lock()
+	defer upstreamHostsMu.Unlock()
+
+	upstreamHosts[host] = upstream
+}
+
+// CircuitOpenError 開いているサーキットブレーカーの外部サービス名を保持するエラー
+type CircuitOpenError struct {
+	Upstream string // 停止中の外部サービス
+}
+
+func (e *CircuitOpenError) Error() string {
+	return "circuit breaker is open: " + e.Upstream
+}
+
+// Is errors.Is(err, ErrCircuitOpen)で判定できるようにする
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreakerSetting サーキットブレーカーの設定
+type CircuitBreakerSetting struct {
+	FailureThreshold int              // 連続失敗がこの回数に達したら開く
+	OpenDuration     time.Duration    // 開いてから試行を再開するまでの時間
+	Now              func() time.Time // 現在時刻の取得関数（nilの場合はtime.Now）
+}
+
+// DefaultCircuitBreakerSetting 標準のサーキットブレーカー設定
+func DefaultCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &CircuitBreakerSetting{
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// circuitState サーキットブレーカーの状態
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker 外部サービス1つ分のサーキットブレーカー
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	setting  CircuitBreakerSetting
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker 新しいサーキットブレーカーを作成する
+func NewCircuitBreaker(setting *CircuitBreakerSetting) *CircuitBreaker {
+	if setting == nil {
+		setting = DefaultCircuitBreakerSetting()
+	}
+	s := *setting
+	if s.Now == nil {
+		s.Now = time.Now
+	}
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = 1
+	}
+	return &CircuitBreaker{setting: s}
+}
+
+// Allow リクエストを送信してよいか判定する
+// 開いている間はfalseを返し、OpenDuration経過後は1件だけ試行を許可する
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if cb.setting.Now().Sub(cb.openedAt) < cb.setting.OpenDuration {
+			return false
+		}
+		cb.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// IsOpen 現在リクエストを遮断している状態か返す
+func (cb *CircuitBreaker) IsOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state == circuitOpen && cb.setting.Now().Sub(cb.openedAt) < cb.setting.OpenDuration
+}
+
+// RecordSuccess 成功を記録してブレーカーを閉じる
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state = circuitClosed
+	cb.failures = 0
+}
+
+// RecordFailure 失敗を記録し、閾値に達したらブレーカーを開く
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.setting.FailureThreshold <= cb.failures {
+		cb.state = circuitOpen
+		cb.openedAt = cb.setting.Now()
+	}
+}
+
+// release 試行中の結果を記録せずに解放し、次のリクエストで再試行できるようにする
+func (cb *CircuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitOpen
+	}
+}
+
+// CircuitBreakerTransport 外部サービスごとにサーキットブレーカーを適用するhttp.RoundTripper
+type CircuitBreakerTransport struct {
+	Base     http.RoundTripper // 実際の送信に使うRoundTripper（nilの場合はhttp.DefaultTransport）
+	mu       sync.Mutex
+	setting  *CircuitBreakerSetting
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakerTransport 新しいCircuitBreakerTransportを作成する
+func NewCircuitBreakerTransport(base http.RoundTripper, setting *CircuitBreakerSetting) *CircuitBreakerTransport {
+	if setting == nil {
+		setting = DefaultCircuitBreakerSetting()
+	}
+	return &CircuitBreakerTransport{
+		Base:     base,
+		setting:  setting,
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// UpstreamOf リクエスト先ホストから外部サービスの識別子を返す
+// 対応表にないホストはホスト名そのものを識別子とし、他の外部サービスと状態を共有しない
+func UpstreamOf(host string) string {
+	upstreamHostsMu.RLock()
+	defer upstreamHostsMu.RUnlock()
+
+	if upstream, ok := upstreamHosts[host]; ok {
+		return upstream
+	}
+	return host
+}
+
+// Breaker 外部サービスのサーキットブレーカーを取得する（存在しなければ作成する）
+func (t *CircuitBreakerTransport) Breaker(upstream string) *CircuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	breaker, ok := t.breakers[upstream]
+	if !ok {
+		breaker = NewCircuitBreaker(t.setting)
+		t.breakers[upstream] = breaker
+	}
+	return breaker
+}
+
+// RoundTrip ブレーカーが開いていれば即座に失敗し、そうでなければ送信して結果を記録する
+func (t *CircuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	upstream := UpstreamOf(req.URL.Hostname())
+	breaker := t.Breaker(upstream)
+	if !breaker.Allow() {
+		if req.Body != nil {
+			if err := req.Body.Close(); err != nil {
+				return nil, errors.Wrap(err, "Failed to Close")
+			}
+		}
+		return nil, &CircuitOpenError{Upstream: upstream}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	switch {
+	case err != nil:
+		// 呼び出し側のキャンセルは外部サービスの不調とみなさない
+		if req.Context().Err() == nil {
+			breaker.RecordFailure()
+		} else {
+			breaker.release()
+		}
+		return nil, errors.Wrap(err, "Failed to RoundTrip")
+	case http.StatusInternalServerError <= resp.StatusCode || resp.StatusCode == http.StatusTooManyRequests:
+		breaker.RecordFailure()
+	default:
+		breaker.RecordSuccess()
+	}
+
+	return resp, nil
+}
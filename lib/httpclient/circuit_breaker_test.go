@@ -0,0 +1,192 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// TestCircuitBreakerTransport CircuitBreakerTransportの開閉動作をテストする
+func TestCircuitBreakerTransport(t *testing.T) {
+	type step struct {
+		advance      time.Duration // リクエスト前に進める時間
+		url          string
+		expectError  error
+		expectStatus int
+	}
+
+	tests := []struct {
+		name         string
+		responses    []httpclient.MockResponse
+		steps        []step
+		expectCalled int
+	}{
+		{
+			name: "連続失敗で開き以降は送信しない",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusInternalServerError},
+			},
+			steps: []step{
+				{url: "https://tile.openstreetmap.org/1/1/1.png", expectStatus: http.StatusInternalServerError},
+				{url: "https://tile.openstreetmap.org/1/1/2.png", expectStatus: http.StatusInternalServerError},
+				{url: "https://tile.openstreetmap.org/1/1/3.png", expectError: httpclient.ErrCircuitOpen},
+				{url: "https://tile.openstreetmap.org/1/1/4.png", expectError: httpclient.ErrCircuitOpen},
+			},
+			expectCalled: 2,
+		},
+		{
+			name: "他の外部サービスには影響しない",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusServiceUnavailable},
+			},
+			steps: []step{
+				{url: "https://tile.openstreetmap.org/1/1/1.png", expectStatus: http.StatusServiceUnavailable},
+				{url: "https://tile.openstreetmap.org/1/1/2.png", expectStatus: http.StatusServiceUnavailable},
+				{url: "https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N1.json", expectStatus: http.StatusServiceUnavailable},
+			},
+			expectCalled: 3,
+		},
+		{
+			name: "4xxは失敗として数えない",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusNotFound},
+			},
+			steps: []step{
+				{url: "https://www.jma.go.jp/a", expectStatus: http.StatusNotFound},
+				{url: "https://www.jma.go.jp/b", expectStatus: http.StatusNotFound},
+				{url: "https://www.jma.go.jp/c", expectStatus: http.StatusNotFound},
+			},
+			expectCalled: 3,
+		},
+		{
+			name: "開いた後に時間が経過すると試行し成功すれば閉じる",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusInternalServerError},
+				{StatusCode: http.StatusInternalServerError},
+				{StatusCode: http.StatusOK},
+			},
+			steps: []step{
+				{url: "https://map.yahooapis.jp/geocode", expectStatus: http.StatusInternalServerError},
+				{url: "https://map.yahooapis.jp/geocode", expectStatus: http.StatusInternalServerError},
+				{url: "https://map.yahooapis.jp/geocode", expectError: httpclient.ErrCircuitOpen},
+				{advance: time.Minute, url: "https://map.yahooapis.jp/geocode", expectStatus: http.StatusOK},
+				{url: "https://map.yahooapis.jp/geocode", expectStatus: http.StatusOK},
+			},
+			expectCalled: 4,
+		},
+		{
+			name: "試行が失敗すると再び開く",
+			responses: []httpclient.MockResponse{
+				{Err: errors.New("connection refused")},
+			},
+			steps: []step{
+				{url: "https://example.com/api/i", expectError: errors.New("")},
+				{url: "https://example.com/api/i", expectError: errors.New("")},
+				{advance: time.Minute, url: "https://example.com/api/i", expectError: errors.New("")},
+				{url: "https://example.com/api/i", expectError: httpclient.ErrCircuitOpen},
+			},
+			expectCalled: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			base := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{{Pattern: "", Responses: tt.responses}},
+			})
+			client := &http.Client{
+				Transport: httpclient.NewCircuitBreakerTransport(base, &httpclient.CircuitBreakerSetting{
+					FailureThreshold: 2,
+					OpenDuration:     30 * time.Second,
+					Now:              func() time.Time { return now },
+				}),
+			}
+
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, s.url, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				resp, err := client.Do(req)
+				if s.expectError != nil {
+					if err == nil {
+						t.Errorf("step %d expected error", i)
+					} else if errors.Is(s.expectError, httpclient.ErrCircuitOpen) && !errors.Is(err, httpclient.ErrCircuitOpen) {
+						t.Errorf("step %d error = %v, want ErrCircuitOpen", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("step %d unexpected error: %v", i, err)
+					continue
+				}
+				if err := resp.Body.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != s.expectStatus {
+					t.Errorf("step %d status = %d, want %d", i, resp.StatusCode, s.expectStatus)
+				}
+			}
+
+			if called := len(base.Requests()); called != tt.expectCalled {
+				t.Errorf("base transport called %d times, want %d", called, tt.expectCalled)
+			}
+		})
+	}
+}
+
+// TestUpstreamOf ホスト名から外部サービスの識別子への対応をテストする
+func TestUpstreamOf(t *testing.T) {
+	httpclient.RegisterUpstream("misskey.example.com", httpclient.UpstreamMisskey)
+
+	tests := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{name: "対応表にあるホスト", host: "www.jma.go.jp", expected: httpclient.UpstreamJMA},
+		{name: "登録したホスト", host: "misskey.example.com", expected: httpclient.UpstreamMisskey},
+		{name: "対応表にないホストはホスト名そのもの", host: "api.example.org", expected: "api.example.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := httpclient.UpstreamOf(tt.host); got != tt.expected {
+				t.Errorf("UpstreamOf(%q) = %q, want %q", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestDefaultTransportShared DefaultTransportのブレーカーの状態がクライアントをまたいで共有されることをテストする
+func TestDefaultTransportShared(t *testing.T) {
+	t.Parallel()
+	breaker := httpclient.DefaultTransport.Breaker("shared.example.com")
+	for range httpclient.DefaultCircuitBreakerSetting().FailureThreshold {
+		breaker.RecordFailure()
+	}
+
+	client := &http.Client{Transport: httpclient.DefaultTransport}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://shared.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		if err := resp.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !errors.Is(err, httpclient.ErrCircuitOpen) {
+		t.Errorf("Do() error = %v, want ErrCircuitOpen", err)
+	}
+}
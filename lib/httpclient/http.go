@@ -1,19 +1,123 @@
 package httpclient
 
 import (
+	"bytes"
+	"io"
 	"net/http"
 	"slices"
 
 	"github.com/cockroachdb/errors"
-
-	"hato-bot-go/lib"
 )
 
 var ErrHTTPRequestError = errors.New("A http request returned error status")
 
+// StatusError 成功以外のステータスコードを保持するエラー
+type StatusError struct {
+	StatusCode int // レスポンスのステータスコード
+}
+
+func (e *StatusError) Error() string {
+	return ErrHTTPRequestError.Error()
+}
+
+// Is errors.Is(err, ErrHTTPRequestError)で判定できるようにする
+func (e *StatusError) Is(target error) bool {
+	return target == ErrHTTPRequestError
+}
+
+// ErrResponseTooLarge レスポンスボディが上限を超えたことを表すエラー
+var ErrResponseTooLarge = errors.New("response body is too large")
+
+// DefaultMaxResponseBytes 上限を指定しない場合に読み込むレスポンスボディの上限（16MiB）
+// 外部サービスが巨大なレスポンスを返した場合にメモリを使い果たさないようにする
+const DefaultMaxResponseBytes int64 = 16 << 20
+
+// Doer HTTPリクエストを送信するクライアント
+// *http.Clientのほか、計測用のラッパーやRoundTripperを使わない偽のクライアントを渡せる
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc 関数をDoerとして使うためのアダプター
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do 関数を呼び出す
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// successStatuses ExecuteHTTPRequestが成功とみなすステータス
+var successStatuses = []int{http.StatusOK, http.StatusAccepted, http.StatusNoContent}
+
+// conditionalStatuses 条件付きGETで成功とみなすステータス（304はキャッシュを再利用する）
+var conditionalStatuses = append(slices.Clone(successStatuses), http.StatusNotModified)
+
 // ExecuteHTTPRequest HTTPリクエストを実行し、共通のエラーハンドリングを行う
-func ExecuteHTTPRequest(client *http.Client, req *http.Request) (*http.Response, error) {
-	req.Header.Set("User-Agent", "hato-bot-go/"+lib.Version)
+// 成功した場合はレスポンスボディの所有権を呼び出し側に渡すため、呼び出し側が読み終えたら閉じる
+// 失敗した場合はレスポンスボディを閉じてから返す
+// レスポンスボディをすべて読み込む場合は上限付きで読んで閉じるExecuteAndReadBodyを使う
+func ExecuteHTTPRequest(client Doer, req *http.Request) (*http.Response, error) {
+	return executeHTTPRequest(client, req, successStatuses)
+}
+
+// ExecuteAndReadBody HTTPリクエストを実行し、レスポンスボディを上限まで読み込んで閉じる
+// maxBytesが0以下の場合はDefaultMaxResponseBytesを上限とし、超えた場合はErrResponseTooLargeを返す
+func ExecuteAndReadBody(client Doer, req *http.Request, maxBytes int64) ([]byte, error) {
+	resp, err := ExecuteHTTPRequest(client, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ExecuteHTTPRequest")
+	}
+	body, err := ReadResponseBody(resp, maxBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to ReadResponseBody")
+	}
+	return body, nil
+}
+
+// ReadResponseBody レスポンスボディを上限まで読み込んで閉じる
+// maxBytesが0以下の場合はDefaultMaxResponseBytesを上限とし、超えた場合はErrResponseTooLargeを返す
+func ReadResponseBody(resp *http.Response, maxBytes int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := CopyResponseBody(&buf, resp, maxBytes); err != nil {
+		return nil, errors.Wrap(err, "Failed to CopyResponseBody")
+	}
+	return buf.Bytes(), nil
+}
+
+// CopyResponseBody レスポンスボディを上限までdstに書き込んで閉じる
+// Content-Lengthが上限を超える場合は読み込まずにErrResponseTooLargeを返す
+// maxBytesが0以下の場合はDefaultMaxResponseBytesを上限とする
+func CopyResponseBody(dst io.Writer, resp *http.Response, maxBytes int64) (n int64, err error) {
+	defer func(body io.ReadCloser) {
+		if closeErr := body.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(resp.Body)
+
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxResponseBytes
+	}
+	if maxBytes < resp.ContentLength {
+		return 0, errors.Wrapf(ErrResponseTooLarge, "Content-Length %d exceeds %d bytes", resp.ContentLength, maxBytes)
+	}
+
+	// 上限より1バイト多く読めた場合は上限を超えている
+	n, err = io.Copy(dst, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return n, errors.Wrap(err, "Failed to io.Copy")
+	}
+	if maxBytes < n {
+		return n, errors.Wrapf(ErrResponseTooLarge, "exceeds %d bytes", maxBytes)
+	}
+	return n, nil
+}
+
+// executeHTTPRequest HTTPリクエストを実行し、statusesに含まれないステータスをエラーにする
+// User-Agentは呼び出し側が設定していない場合のみ設定する（連絡先を求める外部サービス向け）
+func executeHTTPRequest(client Doer, req *http.Request, statuses []int) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent(req.Context()))
+	}
 
 	resp, err := client.Do(req) //nolint:gosec //G704
 	if err != nil {
@@ -21,12 +125,12 @@ func ExecuteHTTPRequest(client *http.Client, req *http.Request) (*http.Response,
 	}
 
 	// レスポンスステータスを確認
-	if !slices.Contains([]int{http.StatusOK, http.StatusAccepted, http.StatusNoContent}, resp.StatusCode) {
+	if !slices.Contains(statuses, resp.StatusCode) {
 		if err := resp.Body.Close(); err != nil {
 			return nil, errors.Wrap(err, "Failed to Close")
 		}
 
-		return nil, errors.Wrapf(ErrHTTPRequestError, "ステータス %d", resp.StatusCode)
+		return nil, errors.Wrapf(&StatusError{StatusCode: resp.StatusCode}, "ステータス %d", resp.StatusCode)
 	}
 
 	return resp, nil
@@ -0,0 +1,215 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/requestid"
+)
+
+func TestExecuteHTTPRequestUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		userAgent string
+		expected  string
+	}{
+		{
+			name:     "リクエストIDをコメントに含める",
+			ctx:      requestid.NewContext(context.Background(), "0123456789abcdef"),
+			expected: "hato-bot-go/" + lib.BuildVersion() + " (req=0123456789abcdef)",
+		},
+		{
+			name:     "リクエストIDなし",
+			ctx:      context.Background(),
+			expected: "hato-bot-go/" + lib.BuildVersion(),
+		},
+		{
+			name:      "呼び出し側が設定したUser-Agentはそのまま使う",
+			ctx:       requestid.NewContext(context.Background(), "0123456789abcdef"),
+			userAgent: "hato-bot-go (admin@example.com)",
+			expected:  "hato-bot-go (admin@example.com)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK},
+			})
+			req, err := http.NewRequestWithContext(tt.ctx, http.MethodGet, "https://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+
+			resp, err := httpclient.ExecuteHTTPRequest(transport.Client(), req)
+			if err != nil {
+				t.Fatalf("ExecuteHTTPRequest() error = %v", err)
+			}
+			if err := resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			requests := transport.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+			if result := requests[0].Header.Get("User-Agent"); result != tt.expected {
+				t.Errorf("User-Agent = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteHTTPRequestStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectError error
+	}{
+		{name: "200", status: http.StatusOK},
+		{name: "204", status: http.StatusNoContent},
+		{name: "304は条件付きGET以外ではエラー", status: http.StatusNotModified, expectError: httpclient.ErrHTTPRequestError},
+		{name: "404", status: http.StatusNotFound, expectError: httpclient.ErrHTTPRequestError},
+		{name: "500", status: http.StatusInternalServerError, expectError: httpclient.ErrHTTPRequestError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: tt.status},
+			})
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := httpclient.ExecuteHTTPRequest(transport.Client(), req)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ExecuteHTTPRequest() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if err == nil {
+				if err := resp.Body.Close(); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var statusErr *httpclient.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+				t.Errorf("StatusError = %v, want status %d", statusErr, tt.status)
+			}
+		})
+	}
+}
+
+// TestExecuteHTTPRequestDoer RoundTripperを使わないクライアントでもリクエストを送信できることを確認する
+func TestExecuteHTTPRequestDoer(t *testing.T) {
+	t.Parallel()
+	var received *http.Request
+	client := httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		received = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := httpclient.ExecuteHTTPRequest(client, req)
+	if err != nil {
+		t.Fatalf("ExecuteHTTPRequest() error = %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if received == nil || received.Header.Get("User-Agent") == "" {
+		t.Errorf("received = %v, want request with User-Agent", received)
+	}
+}
+
+// closeRecorder 閉じられたかを記録するレスポンスボディ
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestExecuteAndReadBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		maxBytes      int64
+		expected      string
+		expectedError error
+	}{
+		{
+			name:          "上限ちょうど",
+			body:          "12345",
+			contentLength: -1,
+			maxBytes:      5,
+			expected:      "12345",
+		},
+		{
+			name:          "本文が上限を超える",
+			body:          "123456",
+			contentLength: -1,
+			maxBytes:      5,
+			expectedError: httpclient.ErrResponseTooLarge,
+		},
+		{
+			name:          "Content-Lengthが上限を超える場合は読み込まない",
+			body:          "1",
+			contentLength: 6,
+			maxBytes:      5,
+			expectedError: httpclient.ErrResponseTooLarge,
+		},
+		{
+			name:          "上限が0以下の場合は既定の上限",
+			body:          "12345",
+			contentLength: 5,
+			expected:      "12345",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			body := &closeRecorder{Reader: strings.NewReader(tt.body)}
+			client := httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: body, ContentLength: tt.contentLength}, nil
+			})
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := httpclient.ExecuteAndReadBody(client, req, tt.maxBytes)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ExecuteAndReadBody() error = %v, want %v", err, tt.expectedError)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("ExecuteAndReadBody() = %q, want %q", result, tt.expected)
+			}
+			if !body.closed {
+				t.Error("response body is not closed")
+			}
+		})
+	}
+}
@@ -4,27 +4,168 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
 )
 
-type roundTrip struct {
-	statusCode   int
-	responseBody string
+// MockResponse モックが返すレスポンスの定義
+type MockResponse struct {
+	StatusCode int         // ステータスコード
+	Body       string      // レスポンスボディ
+	Header     http.Header // レスポンスヘッダー
+	Err        error       // 設定した場合はレスポンスの代わりにこのエラーを返す
+}
+
+// MockRoute URLパターンごとのレスポンス定義
+type MockRoute struct {
+	Pattern   string         // リクエストURLに含まれる文字列
+	Method    string         // 対象のHTTPメソッド（空の場合はすべて）
+	Responses []MockResponse // 呼び出し順に返すレスポンス（使い切った後は最後のものを返し続ける）
+}
+
+// CapturedRequest モックが受け取ったリクエストの記録
+type CapturedRequest struct {
+	Method string      // HTTPメソッド
+	URL    string      // リクエストURL
+	Header http.Header // リクエストヘッダー
+	Body   []byte      // リクエストボディ
+}
+
+// MockTransportParams モックトランスポート作成のパラメータ
+type MockTransportParams struct {
+	Routes   []MockRoute  // URLパターンごとのレスポンス定義（先頭から順に照合）
+	Fallback MockResponse // どのルートにも一致しない場合のレスポンス
+}
+
+// mockRouteState ルートと呼び出し回数の組
+type mockRouteState struct {
+	route MockRoute
+	calls int
+}
+
+// MockTransport リクエストを記録しながら定義済みのレスポンスを返すhttp.RoundTripper
+type MockTransport struct {
+	mu       sync.Mutex
+	routes   []*mockRouteState
+	fallback MockResponse
+	requests []CapturedRequest
+}
+
+// NewMockTransport 新しいモックトランスポートを作成する
+func NewMockTransport(params *MockTransportParams) *MockTransport {
+	t := &MockTransport{
+		fallback: MockResponse{StatusCode: http.StatusNotFound, Body: "Not Found"},
+	}
+	if params == nil {
+		return t
+	}
+
+	for _, route := range params.Routes {
+		t.routes = append(t.routes, &mockRouteState{route: route})
+	}
+	if params.Fallback.StatusCode != 0 || params.Fallback.Err != nil {
+		t.fallback = params.Fallback
+	}
+
+	return t
 }
 
-func (f roundTrip) RoundTrip(_ *http.Request) (*http.Response, error) {
+// RoundTrip リクエストを記録し、一致するルートのレスポンスを返す
+func (t *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to io.ReadAll")
+		}
+		if err := req.Body.Close(); err != nil {
+			return nil, errors.Wrap(err, "Failed to Close")
+		}
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, CapturedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	mockResp := t.nextResponse(req)
+	t.mu.Unlock()
+
+	if mockResp.Err != nil {
+		return nil, mockResp.Err
+	}
+
+	header := mockResp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
 	return &http.Response{
-		StatusCode: f.statusCode,
-		Body:       io.NopCloser(strings.NewReader(f.responseBody)),
-		Header:     make(http.Header),
+		StatusCode: mockResp.StatusCode,
+		Body:       io.NopCloser(strings.NewReader(mockResp.Body)),
+		Header:     header,
+		Request:    req,
 	}, nil
 }
 
+// nextResponse リクエストに一致するルートの次のレスポンスを返す（ロック取得済みで呼び出す）
+func (t *MockTransport) nextResponse(req *http.Request) MockResponse {
+	requestURL := req.URL.String()
+	for _, state := range t.routes {
+		if state.route.Method != "" && state.route.Method != req.Method {
+			continue
+		}
+		if !strings.Contains(requestURL, state.route.Pattern) {
+			continue
+		}
+		if len(state.route.Responses) == 0 {
+			return t.fallback
+		}
+
+		index := min(state.calls, len(state.route.Responses)-1)
+		state.calls++
+		return state.route.Responses[index]
+	}
+
+	return t.fallback
+}
+
+// Requests 記録されたすべてのリクエストを受信順に返す
+func (t *MockTransport) Requests() []CapturedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	requests := make([]CapturedRequest, len(t.requests))
+	copy(requests, t.requests)
+	return requests
+}
+
+// RequestsTo URLに指定の文字列を含むリクエストのみを受信順に返す
+func (t *MockTransport) RequestsTo(pattern string) []CapturedRequest {
+	var requests []CapturedRequest
+	for _, req := range t.Requests() {
+		if strings.Contains(req.URL, pattern) {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// Client このトランスポートを使うHTTPクライアントを作成する
+func (t *MockTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
 // NewMockHTTPClient 指定されたステータスコードとレスポンスボディでモックHTTPクライアントを作成する
 func NewMockHTTPClient(statusCode int, responseBody string) *http.Client {
-	return &http.Client{
-		Transport: roundTrip{
-			statusCode:   statusCode,
-			responseBody: responseBody,
+	return NewMockTransport(&MockTransportParams{
+		Fallback: MockResponse{
+			StatusCode: statusCode,
+			Body:       responseBody,
 		},
-	}
+	}).Client()
 }
@@ -0,0 +1,141 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// TestMockTransport MockTransportのルーティング・順序・記録・エラー注入をテストする
+func TestMockTransport(t *testing.T) {
+	errInjected := errors.New("接続エラー")
+
+	type request struct {
+		method string
+		url    string
+		body   string
+	}
+
+	tests := []struct {
+		name          string
+		params        *httpclient.MockTransportParams
+		requests      []request
+		expectStatus  []int
+		expectBodies  []string
+		expectErrors  []error
+		expectCapture []string
+	}{
+		{
+			name: "URLパターンごとにレスポンスを返す",
+			params: &httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "/api/notes/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: "note"}}},
+					{Pattern: "/api/drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: "file"}}},
+				},
+			},
+			requests: []request{
+				{method: http.MethodPost, url: "https://example.com/api/drive/files/create", body: "a"},
+				{method: http.MethodPost, url: "https://example.com/api/notes/create", body: "b"},
+				{method: http.MethodPost, url: "https://example.com/api/unknown", body: "c"},
+			},
+			expectStatus:  []int{http.StatusOK, http.StatusOK, http.StatusNotFound},
+			expectBodies:  []string{"file", "note", "Not Found"},
+			expectErrors:  []error{nil, nil, nil},
+			expectCapture: []string{"a", "b", "c"},
+		},
+		{
+			name: "レスポンスを順番に返し最後のものを繰り返す",
+			params: &httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{
+						Pattern: "targetTimes",
+						Responses: []httpclient.MockResponse{
+							{StatusCode: http.StatusInternalServerError, Body: "1"},
+							{StatusCode: http.StatusOK, Body: "2"},
+						},
+					},
+				},
+			},
+			requests: []request{
+				{method: http.MethodGet, url: "https://example.com/targetTimes_N1.json"},
+				{method: http.MethodGet, url: "https://example.com/targetTimes_N2.json"},
+				{method: http.MethodGet, url: "https://example.com/targetTimes_N3.json"},
+			},
+			expectStatus:  []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK},
+			expectBodies:  []string{"1", "2", "2"},
+			expectErrors:  []error{nil, nil, nil},
+			expectCapture: []string{"", "", ""},
+		},
+		{
+			name: "メソッドで絞り込みエラーを注入する",
+			params: &httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "/api/", Method: http.MethodGet, Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: "get"}}},
+					{Pattern: "/api/", Method: http.MethodPost, Responses: []httpclient.MockResponse{{Err: errInjected}}},
+				},
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusTeapot},
+			},
+			requests: []request{
+				{method: http.MethodGet, url: "https://example.com/api/i"},
+				{method: http.MethodPost, url: "https://example.com/api/i", body: "{}"},
+				{method: http.MethodGet, url: "https://example.com/other"},
+			},
+			expectStatus:  []int{http.StatusOK, 0, http.StatusTeapot},
+			expectBodies:  []string{"get", "", ""},
+			expectErrors:  []error{nil, errInjected, nil},
+			expectCapture: []string{"", "{}", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(tt.params)
+			client := transport.Client()
+
+			for i, r := range tt.requests {
+				req, err := http.NewRequestWithContext(t.Context(), r.method, r.url, strings.NewReader(r.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				resp, err := client.Do(req)
+				if !errors.Is(err, tt.expectErrors[i]) {
+					t.Errorf("request %d error = %v, expectError = %v", i, err, tt.expectErrors[i])
+					continue
+				}
+				if err != nil {
+					continue
+				}
+
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := resp.Body.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != tt.expectStatus[i] {
+					t.Errorf("request %d status = %d, want %d", i, resp.StatusCode, tt.expectStatus[i])
+				}
+				if string(body) != tt.expectBodies[i] {
+					t.Errorf("request %d body = %q, want %q", i, body, tt.expectBodies[i])
+				}
+			}
+
+			var captured []string
+			for _, req := range transport.Requests() {
+				captured = append(captured, string(req.Body))
+			}
+			if diff := cmp.Diff(captured, tt.expectCapture); diff != "" {
+				t.Errorf("Requests() body diff: %s", diff)
+			}
+		})
+	}
+}
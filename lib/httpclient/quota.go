@@ -0,0 +1,179 @@
+package httpclient
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/metrics"
+)
+
+// ErrInvalidQuotaLimit 外部サービスの利用量の目安の設定値が不正であることを表すエラー
+var ErrInvalidQuotaLimit = errors.New("invalid quota limit")
+
+// QuotaUpstreams 利用量を数える外部サービス（設定ファイルのapi_quotasのキー）
+// 利用規約や無料枠でリクエスト数の上限が決まっている外部サービスを対象にする
+var QuotaUpstreams = []string{UpstreamYahooGeocoder, UpstreamNominatim, UpstreamOSM, UpstreamJMA}
+
+// QuotaLimit 外部サービスへのリクエスト数の目安
+// 超えてもリクエストは止めず、時間帯ごとに1回だけ警告をログに出力する
+type QuotaLimit struct {
+	Hourly int64 // 1時間（毎時0分から）のリクエスト数の目安（0の場合は確認しない）
+	Daily  int64 // 1日（0時から）のリクエスト数の目安（0の場合は確認しない）
+}
+
+// QuotaUsage 外部サービスへのリクエスト数
+type QuotaUsage struct {
+	Hour  int64 // 現在の1時間のリクエスト数
+	Day   int64 // 今日のリクエスト数
+	Total int64 // 起動してからのリクエスト数
+}
+
+// QuotaTransportSetting QuotaTransportの設定
+type QuotaTransportSetting struct {
+	Metrics *metrics.Registry // リクエスト数を記録するレジストリ（nilの場合はmetrics.Default）
+	Clock   clock.Clock       // 時間帯の判定に使う時計（nilの場合はclock.Real）
+}
+
+// quotaCounter 外部サービス1つ分のリクエスト数
+type quotaCounter struct {
+	hourStart time.Time
+	dayStart  time.Time
+	hour      int64
+	day       int64
+	total     *metrics.Counter
+	warnings  *metrics.Counter
+}
+
+// roll 現在時刻が別の時間帯に入っていれば、その時間帯のリクエスト数を0に戻す
+func (c *quotaCounter) roll(now time.Time) {
+	hourStart := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+	if !hourStart.Equal(c.hourStart) {
+		c.hourStart, c.hour = hourStart, 0
+	}
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !dayStart.Equal(c.dayStart) {
+		c.dayStart, c.day = dayStart, 0
+	}
+}
+
+// QuotaTransport QuotaUpstreamsへのリクエスト数を時間帯ごとに数えるhttp.RoundTripper
+// リクエスト数は/metricsのapi_quota.<外部サービス>.hour・day・requestsで確認できる
+type QuotaTransport struct {
+	Base     http.RoundTripper // 実際の送信に使うRoundTripper（nilの場合はhttp.DefaultTransport）
+	mu       sync.Mutex
+	setting  QuotaTransportSetting
+	limits   map[string]QuotaLimit
+	counters map[string]*quotaCounter
+}
+
+// NewQuotaTransport 新しいQuotaTransportを作成し、リクエスト数をレジストリに登録する
+func NewQuotaTransport(base http.RoundTripper, setting *QuotaTransportSetting) *QuotaTransport {
+	s := QuotaTransportSetting{}
+	if setting != nil {
+		s = *setting
+	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.Default
+	}
+	s.Clock = clock.Or(s.Clock)
+
+	t := &QuotaTransport{
+		Base:     base,
+		setting:  s,
+		counters: make(map[string]*quotaCounter, len(QuotaUpstreams)),
+	}
+	for _, upstream := range QuotaUpstreams {
+		prefix := "api_quota." + upstream + "."
+		t.counters[upstream] = &quotaCounter{
+			total:    s.Metrics.Counter(prefix + "requests"),
+			warnings: s.Metrics.Counter(prefix + "warnings"),
+		}
+		s.Metrics.GaugeFunc(prefix+"hour", func() int64 { return t.Usage(upstream).Hour })
+		s.Metrics.GaugeFunc(prefix+"day", func() int64 { return t.Usage(upstream).Day })
+	}
+	return t
+}
+
+// SetLimits 外部サービスごとのリクエスト数の目安を設定する（limitsにない外部サービスは確認しない）
+// QuotaUpstreams以外の外部サービスや負の値が含まれる場合はErrInvalidQuotaLimitを返し、何も変更しない
+func (t *QuotaTransport) SetLimits(limits map[string]QuotaLimit) error {
+	for upstream, limit := range limits {
+		if !slices.Contains(QuotaUpstreams, upstream) {
+			return errors.Wrapf(ErrInvalidQuotaLimit, "upstream: %s", upstream)
+		}
+		if limit.Hourly < 0 || limit.Daily < 0 {
+			return errors.Wrapf(ErrInvalidQuotaLimit, "%s: hourly: %d, daily: %d", upstream, limit.Hourly, limit.Daily)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.limits = limits
+	return nil
+}
+
+// Usage 外部サービスへのリクエスト数を返す（QuotaUpstreams以外の外部サービスは0）
+func (t *QuotaTransport) Usage(upstream string) QuotaUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counter, ok := t.counters[upstream]
+	if !ok {
+		return QuotaUsage{}
+	}
+	counter.roll(t.setting.Clock.Now())
+	return QuotaUsage{Hour: counter.hour, Day: counter.day, Total: counter.total.Value()}
+}
+
+// record リクエストを1件数え、目安を初めて超えた時間帯では警告をログに出力する
+func (t *QuotaTransport) record(upstream string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counter, ok := t.counters[upstream]
+	if !ok {
+		return
+	}
+	counter.roll(t.setting.Clock.Now())
+	counter.hour++
+	counter.day++
+	counter.total.Inc()
+
+	limit := t.limits[upstream]
+	if 0 < limit.Hourly && counter.hour == limit.Hourly+1 {
+		counter.warnings.Inc()
+		log.Printf("Requests to %s exceeded the hourly limit of %d", upstream, limit.Hourly)
+	}
+	if 0 < limit.Daily && counter.day == limit.Daily+1 {
+		counter.warnings.Inc()
+		log.Printf("Requests to %s exceeded the daily limit of %d", upstream, limit.Daily)
+	}
+}
+
+// RoundTrip リクエスト数を数えて送信する
+func (t *QuotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.record(UpstreamOf(req.URL.Hostname()))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to RoundTrip")
+	}
+	return resp, nil
+}
+
+// SetQuotaLimits DefaultTransportの外部サービスごとのリクエスト数の目安を設定する
+// 設定ファイルの値を起動時に反映する
+func SetQuotaLimits(limits map[string]QuotaLimit) error {
+	return DefaultQuotaTransport.SetLimits(limits)
+}
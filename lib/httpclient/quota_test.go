@@ -0,0 +1,151 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/metrics"
+)
+
+// TestQuotaTransport 外部サービスへのリクエスト数を時間帯ごとに数え、目安を超えた回数を記録することをテストする
+func TestQuotaTransport(t *testing.T) {
+	type step struct {
+		advance time.Duration // リクエスト前に進める時間
+		url     string
+	}
+
+	tests := []struct {
+		name     string
+		limits   map[string]httpclient.QuotaLimit
+		steps    []step
+		expected map[string]int64
+	}{
+		{
+			name: "外部サービスごとに数える",
+			steps: []step{
+				{url: "https://map.yahooapis.jp/geocode/V1/geoCoder"},
+				{url: "https://tile.openstreetmap.org/10/909/403.png"},
+				{url: "https://tile.openstreetmap.org/10/910/403.png"},
+				{url: "https://example.com/"},
+			},
+			expected: map[string]int64{
+				"api_quota.yahoo_geocoder.hour": 1, "api_quota.yahoo_geocoder.day": 1, "api_quota.yahoo_geocoder.requests": 1,
+				"api_quota.osm.hour": 2, "api_quota.osm.day": 2, "api_quota.osm.requests": 2,
+			},
+		},
+		{
+			name: "時間帯が変わると0から数える",
+			steps: []step{
+				{url: "https://www.jma.go.jp/a"},
+				{advance: 50 * time.Minute, url: "https://www.jma.go.jp/b"},
+				{advance: 20 * time.Minute, url: "https://www.jma.go.jp/c"},
+				{advance: 24 * time.Hour},
+			},
+			expected: map[string]int64{
+				"api_quota.jma.hour": 0, "api_quota.jma.day": 0, "api_quota.jma.requests": 3,
+			},
+		},
+		{
+			name:   "目安を超えた時間帯ごとに1回警告する",
+			limits: map[string]httpclient.QuotaLimit{httpclient.UpstreamYahooGeocoder: {Hourly: 1, Daily: 2}},
+			steps: []step{
+				{url: "https://map.yahooapis.jp/a"},
+				{url: "https://map.yahooapis.jp/b"},
+				{url: "https://map.yahooapis.jp/c"},
+				{advance: time.Hour, url: "https://map.yahooapis.jp/d"},
+			},
+			expected: map[string]int64{
+				"api_quota.yahoo_geocoder.hour": 1, "api_quota.yahoo_geocoder.day": 4, "api_quota.yahoo_geocoder.requests": 4,
+				"api_quota.yahoo_geocoder.warnings": 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fake := clocktest.NewFake(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+			registry := metrics.NewRegistry()
+			transport := httpclient.NewQuotaTransport(httpclient.NewMockHTTPClient(http.StatusOK, "").Transport, &httpclient.QuotaTransportSetting{
+				Metrics: registry,
+				Clock:   fake,
+			})
+			if err := transport.SetLimits(tt.limits); err != nil {
+				t.Fatalf("SetLimits() error = %v", err)
+			}
+			client := &http.Client{Transport: transport}
+
+			for _, s := range tt.steps {
+				fake.Advance(s.advance)
+				if s.url == "" {
+					continue
+				}
+				req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, s.url, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+				if err := resp.Body.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// リクエストのない外部サービスの値は比較しない
+			snapshot := registry.Snapshot()
+			for name, value := range snapshot {
+				if _, ok := tt.expected[name]; !ok && value == 0 {
+					delete(snapshot, name)
+				}
+			}
+			if diff := cmp.Diff(tt.expected, snapshot); diff != "" {
+				t.Errorf("Snapshot() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuotaTransportSetLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        map[string]httpclient.QuotaLimit
+		expectedError error
+	}{
+		{
+			name:   "設定なし",
+			limits: nil,
+		},
+		{
+			name:   "外部サービスごとの目安",
+			limits: map[string]httpclient.QuotaLimit{httpclient.UpstreamYahooGeocoder: {Daily: 50000}, httpclient.UpstreamOSM: {Hourly: 1000}},
+		},
+		{
+			name:          "数えない外部サービス",
+			limits:        map[string]httpclient.QuotaLimit{httpclient.UpstreamMisskey: {Hourly: 1}},
+			expectedError: httpclient.ErrInvalidQuotaLimit,
+		},
+		{
+			name:          "負の値",
+			limits:        map[string]httpclient.QuotaLimit{httpclient.UpstreamJMA: {Daily: -1}},
+			expectedError: httpclient.ErrInvalidQuotaLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewQuotaTransport(nil, &httpclient.QuotaTransportSetting{Metrics: metrics.NewRegistry()})
+			if err := transport.SetLimits(tt.limits); !errors.Is(err, tt.expectedError) {
+				t.Errorf("SetLimits() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// リクエストの種類（設定ファイルのhttp_timeoutsのキー）
+const (
+	TimeoutGeocoder = "geocoder" // ジオコーダ（Yahoo!ジオコーダ・Nominatim）
+	TimeoutTiles    = "tiles"    // 地図・レーダーのタイル画像
+	TimeoutJMA      = "jma"      // 気象庁のJSON
+	TimeoutMisskey  = "misskey"  // Misskey API
+	TimeoutUpload   = "upload"   // Misskeyドライブへのファイルのアップロード
+	TimeoutDefault  = "default"  // その他の外部サービス
+)
+
+// ErrUnknownTimeoutKind 存在しないリクエストの種類の制限時間を設定したことを表すエラー
+var ErrUnknownTimeoutKind = errors.New("unknown request kind for timeout")
+
+// misskeyUploadPath Misskeyドライブへのアップロードのパス
+const misskeyUploadPath = "/api/drive/files/create"
+
+// DefaultTimeouts 標準のリクエストの種類ごとの制限時間
+// 応答が返らない外部サービスがあってもコマンドの処理が終わらなくならないよう、すべての種類に上限を設ける
+func DefaultTimeouts() map[string]time.Duration {
+	return map[string]time.Duration{
+		TimeoutGeocoder: 10 * time.Second,
+		TimeoutTiles:    15 * time.Second,
+		TimeoutJMA:      10 * time.Second,
+		TimeoutMisskey:  30 * time.Second,
+		TimeoutUpload:   60 * time.Second,
+		TimeoutDefault:  30 * time.Second,
+	}
+}
+
+// RequestKind リクエスト先とパスからリクエストの種類を判定する
+func RequestKind(req *http.Request) string {
+	upstream := UpstreamOf(req.URL.Hostname())
+	switch {
+	case upstream == UpstreamYahooGeocoder || upstream == UpstreamNominatim:
+		return TimeoutGeocoder
+	case strings.HasSuffix(req.URL.Path, ".png"):
+		return TimeoutTiles
+	case upstream == UpstreamJMA:
+		return TimeoutJMA
+	case upstream == UpstreamMisskey && req.URL.Path == misskeyUploadPath:
+		return TimeoutUpload
+	case upstream == UpstreamMisskey:
+		return TimeoutMisskey
+	default:
+		return TimeoutDefault
+	}
+}
+
+// TimeoutTransport リクエストの種類ごとの制限時間を適用するhttp.RoundTripper
+// 制限時間はレスポンスボディを読み終えるまでを含む
+type TimeoutTransport struct {
+	Base     http.RoundTripper // 実際の送信に使うRoundTripper（nilの場合はhttp.DefaultTransport）
+	mu       sync.RWMutex
+	timeouts map[string]time.Duration
+}
+
+// NewTimeoutTransport 新しいTimeoutTransportを作成する
+// timeoutsにない種類はDefaultTimeoutsの制限時間を使う（存在しない種類を含む場合はすべて標準の制限時間）
+func NewTimeoutTransport(base http.RoundTripper, timeouts map[string]time.Duration) *TimeoutTransport {
+	t := &TimeoutTransport{Base: base, timeouts: DefaultTimeouts()}
+	if err := t.SetTimeouts(timeouts); err != nil {
+		// 存在しない種類は無視し、標準の制限時間を使う
+		log.Printf("Failed to SetTimeouts: %v", err)
+	}
+	return t
+}
+
+// SetTimeouts リクエストの種類ごとの制限時間を設定する
+// timeoutsにない種類と0以下の制限時間はDefaultTimeoutsの制限時間に戻す
+// 存在しない種類が含まれる場合はErrUnknownTimeoutKindを返し、何も変更しない
+func (t *TimeoutTransport) SetTimeouts(timeouts map[string]time.Duration) error {
+	merged := DefaultTimeouts()
+	for kind, timeout := range timeouts {
+		if _, ok := merged[kind]; !ok {
+			return errors.Wrapf(ErrUnknownTimeoutKind, "kind: %s", kind)
+		}
+		if 0 < timeout {
+			merged[kind] = timeout
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timeouts = merged
+	return nil
+}
+
+// Timeouts 現在のリクエストの種類ごとの制限時間を返す
+func (t *TimeoutTransport) Timeouts() map[string]time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return maps.Clone(t.timeouts)
+}
+
+// Timeout リクエストの種類の制限時間を返す（未知の種類はdefaultの制限時間）
+func (t *TimeoutTransport) Timeout(kind string) time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if timeout, ok := t.timeouts[kind]; ok {
+		return timeout
+	}
+	return t.timeouts[TimeoutDefault]
+}
+
+// RoundTrip リクエストの種類の制限時間を付けて送信する
+// 制限時間はレスポンスボディを閉じるまで有効にする
+func (t *TimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout(RequestKind(req)))
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "Failed to RoundTrip")
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose 閉じたときにリクエストのコンテキストを解放するレスポンスボディ
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close レスポンスボディを閉じてコンテキストを解放する
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// SetTimeouts DefaultTransportのリクエストの種類ごとの制限時間を設定する
+// 設定ファイルの値を起動時に反映する
+func SetTimeouts(timeouts map[string]time.Duration) error {
+	return DefaultTimeoutTransport.SetTimeouts(timeouts)
+}
@@ -0,0 +1,112 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// roundTripperFunc 関数をhttp.RoundTripperとして使う
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRequestKind(t *testing.T) {
+	httpclient.RegisterUpstream("misskey.example.com", httpclient.UpstreamMisskey)
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{name: "Yahoo!ジオコーダ", url: "https://map.yahooapis.jp/geocode/V1/geoCoder?query=x", expected: httpclient.TimeoutGeocoder},
+		{name: "Nominatim", url: "https://nominatim.openstreetmap.org/search?q=x", expected: httpclient.TimeoutGeocoder},
+		{name: "OpenStreetMapのタイル", url: "https://tile.openstreetmap.org/10/1/1.png", expected: httpclient.TimeoutTiles},
+		{name: "気象庁のタイル", url: "https://www.jma.go.jp/bosai/jmatile/data/nowc/1/none/1/surf/hrpns/10/1/1.png", expected: httpclient.TimeoutTiles},
+		{name: "気象庁のJSON", url: "https://www.jma.go.jp/bosai/jmatile/data/nowc/targetTimes_N1.json", expected: httpclient.TimeoutJMA},
+		{name: "Misskey API", url: "https://misskey.example.com/api/notes/create", expected: httpclient.TimeoutMisskey},
+		{name: "Misskeyドライブへのアップロード", url: "https://misskey.example.com/api/drive/files/create", expected: httpclient.TimeoutUpload},
+		{name: "その他", url: "https://ja.wikipedia.org/w/api.php", expected: httpclient.TimeoutDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := httpclient.RequestKind(req); result != tt.expected {
+				t.Errorf("RequestKind(%s) = %s, want %s", tt.url, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestTimeoutTransport 応答しない外部サービスへのリクエストが種類ごとの制限時間で打ち切られることを確認する
+func TestTimeoutTransport(t *testing.T) {
+	t.Parallel()
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	transport := httpclient.NewTimeoutTransport(base, map[string]time.Duration{httpclient.TimeoutDefault: 50 * time.Millisecond})
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = transport.RoundTrip(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RoundTrip() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); 5*time.Second < elapsed {
+		t.Errorf("elapsed = %v, want about 50ms", elapsed)
+	}
+}
+
+func TestTimeoutTransportSetTimeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeouts      map[string]time.Duration
+		expected      time.Duration
+		expectedError error
+	}{
+		{
+			name:     "設定した制限時間",
+			timeouts: map[string]time.Duration{httpclient.TimeoutTiles: 20 * time.Second},
+			expected: 20 * time.Second,
+		},
+		{
+			name:     "未設定の場合は標準の制限時間",
+			timeouts: nil,
+			expected: httpclient.DefaultTimeouts()[httpclient.TimeoutTiles],
+		},
+		{
+			name:          "存在しない種類",
+			timeouts:      map[string]time.Duration{"unknown": time.Second},
+			expected:      httpclient.DefaultTimeouts()[httpclient.TimeoutTiles],
+			expectedError: httpclient.ErrUnknownTimeoutKind,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewTimeoutTransport(nil, nil)
+			if err := transport.SetTimeouts(tt.timeouts); !errors.Is(err, tt.expectedError) {
+				t.Fatalf("SetTimeouts() error = %v, want %v", err, tt.expectedError)
+			}
+			if result := transport.Timeout(httpclient.TimeoutTiles); result != tt.expected {
+				t.Errorf("Timeout(tiles) = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/requestid"
+)
+
+// AppName User-Agentに含めるアプリケーション名
+const AppName = "hato-bot-go"
+
+// contact User-Agentに含める運用者の連絡先（SetContactで設定する）
+var contact atomic.Value
+
+// SetContact User-Agentに含める運用者の連絡先（URLまたはメールアドレス）を設定する
+// OpenStreetMapのタイルなど、利用者を識別できるUser-Agentを求める外部サービス向けに起動時に設定する
+func SetContact(value string) {
+	contact.Store(strings.TrimSpace(value))
+}
+
+// Contact User-Agentに含める運用者の連絡先を返す（未設定の場合は空）
+func Contact() string {
+	value, _ := contact.Load().(string)
+	return value
+}
+
+// UserAgent HTTPリクエストのUser-Agentを返す
+// アプリケーション名とビルド情報を含むバージョンに、運用者の連絡先を付ける
+// コンテキストにリクエストIDがある場合は、外部サービス側のログと照合できるようコメントに含める
+func UserAgent(ctx context.Context) string {
+	userAgent := AppName + "/" + lib.BuildVersion()
+
+	var comments []string
+	if value := Contact(); value != "" {
+		comments = append(comments, "+"+value)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		comments = append(comments, "req="+id)
+	}
+	if 0 < len(comments) {
+		userAgent += " (" + strings.Join(comments, "; ") + ")"
+	}
+	return userAgent
+}
+
+// UserAgentTransport User-Agentが設定されていないリクエストにUserAgentを設定するhttp.RoundTripper
+// ExecuteHTTPRequestを通さないリクエストにも同じUser-Agentを付ける
+type UserAgentTransport struct {
+	Base http.RoundTripper // 実際の送信に使うRoundTripper（nilの場合はhttp.DefaultTransport）
+}
+
+// RoundTrip User-Agentを設定して送信する
+// 元のリクエストは変更せず、複製したリクエストに設定する
+func (t *UserAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", UserAgent(req.Context()))
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to RoundTrip")
+	}
+	return resp, nil
+}
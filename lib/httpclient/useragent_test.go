@@ -0,0 +1,94 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/requestid"
+)
+
+// TestUserAgentContact 運用者の連絡先をUser-Agentに含めることを確認する
+// 連絡先はパッケージ全体で共有するため並列に実行しない
+func TestUserAgentContact(t *testing.T) {
+	httpclient.SetContact(" https://example.com/hato ")
+	t.Cleanup(func() { httpclient.SetContact("") })
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{
+			name:     "連絡先とリクエストID",
+			ctx:      requestid.NewContext(context.Background(), "0123456789abcdef"),
+			expected: "hato-bot-go/" + lib.BuildVersion() + " (+https://example.com/hato; req=0123456789abcdef)",
+		},
+		{
+			name:     "連絡先のみ",
+			ctx:      context.Background(),
+			expected: "hato-bot-go/" + lib.BuildVersion() + " (+https://example.com/hato)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := httpclient.UserAgent(tt.ctx); result != tt.expected {
+				t.Errorf("UserAgent() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestUserAgentTransport User-Agentのないリクエストだけに共通のUser-Agentを設定し、元のリクエストを変更しないことを確認する
+func TestUserAgentTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:     "User-Agentがない場合は共通のUser-Agent",
+			expected: "hato-bot-go/" + lib.BuildVersion(),
+		},
+		{
+			name:      "設定済みのUser-Agentはそのまま使う",
+			userAgent: "custom/1.0",
+			expected:  "custom/1.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mock := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK},
+			})
+			transport := &httpclient.UserAgentTransport{Base: mock}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://tile.openstreetmap.org/1/1/1.png", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if err := resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if result := mock.Requests()[0].Header.Get("User-Agent"); result != tt.expected {
+				t.Errorf("User-Agent = %q, want %q", result, tt.expected)
+			}
+			if result := req.Header.Get("User-Agent"); result != tt.userAgent {
+				t.Errorf("original User-Agent = %q, want %q", result, tt.userAgent)
+			}
+		})
+	}
+}
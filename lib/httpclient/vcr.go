@@ -0,0 +1,275 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+)
+
+// VCRMode VCRTransportの動作
+type VCRMode string
+
+const (
+	VCRReplay VCRMode = "replay" // 記録済みのレスポンスを返す（ネットワークに接続しない）
+	VCRRecord VCRMode = "record" // 実際にリクエストを送信し、レスポンスを記録する
+)
+
+// VCRRecordEnv 空でない値を設定するとVCRTransportを記録モードにする環境変数
+// 例: HATO_VCR_RECORD=1 YAHOO_API_TOKEN=... go test ./lib/amesh/ -run VCR
+const VCRRecordEnv = "HATO_VCR_RECORD"
+
+// ErrVCRInteractionNotFound 再生モードでリクエストに一致する記録がないことを表すエラー
+var ErrVCRInteractionNotFound = errors.New("no recorded interaction for request")
+
+// vcrRedacted 記録から取り除いた秘密の値の代わりに書き込む文字列
+const vcrRedacted = "REDACTED"
+
+// vcrSecretParams 記録する前に値を取り除くクエリパラメータとJSONのキー
+// Yahoo!のappid、MisskeyのAPIトークン（i）、Nominatimの連絡先（email）など
+var vcrSecretParams = []string{"appid", "api_key", "apikey", "key", "token", "access_token", "i", "email"}
+
+// vcrSecretHeaders 記録しないリクエスト・レスポンスヘッダー
+var vcrSecretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// VCRInteraction 記録したリクエストとレスポンスの組（秘密の値は取り除き済み）
+type VCRInteraction struct {
+	Method      string      `json:"method"`                 // HTTPメソッド
+	URL         string      `json:"url"`                    // リクエストURL
+	RequestBody string      `json:"request_body,omitempty"` // リクエストボディ（参考用、照合には使わない）
+	StatusCode  int         `json:"status_code"`            // ステータスコード
+	Header      http.Header `json:"header,omitempty"`       // レスポンスヘッダー
+	Body        string      `json:"body,omitempty"`         // UTF-8のレスポンスボディ
+	BodyBase64  []byte      `json:"body_base64,omitempty"`  // 画像などUTF-8でないレスポンスボディ
+}
+
+// vcrCassette 記録ファイルの内容
+type vcrCassette struct {
+	Interactions []VCRInteraction `json:"interactions"`
+}
+
+// VCRSetting VCRTransportの設定
+type VCRSetting struct {
+	Path string            // 記録ファイルのパス（testdata以下のJSONファイル）
+	Mode VCRMode           // 動作（空の場合は環境変数HATO_VCR_RECORDがあれば記録、なければ再生）
+	Base http.RoundTripper // 記録モードで実際に送信するトランスポート（nilの場合はDefaultTransport）
+}
+
+// VCRTransport 外部サービスのレスポンスを記録ファイルに記録し、CIではネットワークなしで再生するhttp.RoundTripper
+// 記録する前にAPIキーやトークンなどの秘密の値を取り除くため、記録ファイルはそのままリポジトリに含められる
+// リクエストはメソッドと秘密の値を取り除いたURLで照合し、同じリクエストは記録した順に再生する
+type VCRTransport struct {
+	mu           sync.Mutex
+	setting      VCRSetting
+	interactions []VCRInteraction
+	used         []bool
+}
+
+// NewVCRTransport 新しいVCRTransportを作成する
+// 再生モードでは記録ファイルを読み込む（ファイルがない場合はエラー）
+func NewVCRTransport(setting *VCRSetting) (*VCRTransport, error) {
+	if setting == nil || setting.Path == "" {
+		return nil, errors.New("VCR path is empty")
+	}
+	t := &VCRTransport{setting: *setting}
+	if t.setting.Mode == "" {
+		t.setting.Mode = VCRReplay
+		if os.Getenv(VCRRecordEnv) != "" {
+			t.setting.Mode = VCRRecord
+		}
+	}
+	if t.setting.Base == nil {
+		t.setting.Base = DefaultTransport
+	}
+	if t.setting.Mode == VCRRecord {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(t.setting.Path))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to os.ReadFile")
+	}
+	var cassette vcrCassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	t.interactions = cassette.Interactions
+	t.used = make([]bool, len(cassette.Interactions))
+	return t, nil
+}
+
+// Mode 動作を返す
+func (t *VCRTransport) Mode() VCRMode {
+	return t.setting.Mode
+}
+
+// RoundTrip 記録モードではリクエストを送信して記録し、再生モードでは記録済みのレスポンスを返す
+func (t *VCRTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to io.ReadAll")
+		}
+		if err := req.Body.Close(); err != nil {
+			return nil, errors.Wrap(err, "Failed to Close")
+		}
+	}
+	requestURL := sanitizeURL(req.URL)
+
+	if t.setting.Mode == VCRRecord {
+		return t.record(req, requestURL, requestBody)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, interaction := range t.interactions {
+		if t.used[i] || interaction.Method != req.Method || interaction.URL != requestURL {
+			continue
+		}
+		t.used[i] = true
+		body := []byte(interaction.Body)
+		if interaction.BodyBase64 != nil {
+			body = interaction.BodyBase64
+		}
+		header := interaction.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			StatusCode: interaction.StatusCode,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+	return nil, errors.Wrapf(ErrVCRInteractionNotFound, "%s %s", req.Method, requestURL)
+}
+
+// record リクエストを送信し、秘密の値を取り除いてレスポンスを記録する
+func (t *VCRTransport) record(req *http.Request, requestURL string, requestBody []byte) (*http.Response, error) {
+	outgoing := req.Clone(req.Context())
+	if requestBody != nil {
+		outgoing.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+	resp, err := t.setting.Base.RoundTrip(outgoing)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to RoundTrip")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "Failed to Close")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to io.ReadAll")
+	}
+
+	interaction := VCRInteraction{
+		Method:      req.Method,
+		URL:         requestURL,
+		RequestBody: string(sanitizeJSONBody(requestBody)),
+		StatusCode:  resp.StatusCode,
+		Header:      sanitizeHeader(resp.Header),
+	}
+	if utf8.Valid(body) {
+		interaction.Body = string(body)
+	} else {
+		interaction.BodyBase64 = body
+	}
+	t.mu.Lock()
+	t.interactions = append(t.interactions, interaction)
+	t.mu.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Save 記録モードの場合は記録したやり取りを記録ファイルに書き込む（再生モードでは何もしない）
+// 並行したリクエストでも記録ファイルの差分が安定するよう、メソッドとURLの順に並べて書き込む
+func (t *VCRTransport) Save() error {
+	if t.setting.Mode != VCRRecord {
+		return nil
+	}
+
+	t.mu.Lock()
+	interactions := slices.Clone(t.interactions)
+	t.mu.Unlock()
+	slices.SortStableFunc(interactions, func(a, b VCRInteraction) int {
+		return strings.Compare(a.Method+" "+a.URL, b.Method+" "+b.URL)
+	})
+
+	// URLの&などをエスケープせず、記録ファイルを読みやすくする
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(vcrCassette{Interactions: interactions}); err != nil {
+		return errors.Wrap(err, "Failed to Encode")
+	}
+	if err := os.MkdirAll(filepath.Dir(t.setting.Path), 0o750); err != nil {
+		return errors.Wrap(err, "Failed to os.MkdirAll")
+	}
+	if err := os.WriteFile(filepath.Clean(t.setting.Path), buf.Bytes(), 0o600); err != nil {
+		return errors.Wrap(err, "Failed to os.WriteFile")
+	}
+	return nil
+}
+
+// Client このトランスポートを使うHTTPクライアントを作成する
+func (t *VCRTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// sanitizeURL 秘密のクエリパラメータの値を取り除いたURLを返す
+func sanitizeURL(u *url.URL) string {
+	sanitized := *u
+	query := sanitized.Query()
+	for _, param := range vcrSecretParams {
+		if query.Has(param) {
+			query.Set(param, vcrRedacted)
+		}
+	}
+	sanitized.RawQuery = query.Encode()
+	return sanitized.String()
+}
+
+// sanitizeJSONBody JSONオブジェクトのリクエストボディから秘密のキーの値を取り除く
+// JSONオブジェクトでない場合は内容を記録しない
+func sanitizeJSONBody(body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	var object map[string]any
+	if err := json.Unmarshal(body, &object); err != nil {
+		return []byte(vcrRedacted)
+	}
+	for _, key := range vcrSecretParams {
+		if _, ok := object[key]; ok {
+			object[key] = vcrRedacted
+		}
+	}
+	sanitized, err := json.Marshal(object)
+	if err != nil {
+		return []byte(vcrRedacted)
+	}
+	return sanitized
+}
+
+// sanitizeHeader 秘密のヘッダーを除いたレスポンスヘッダーを返す
+func sanitizeHeader(header http.Header) http.Header {
+	sanitized := header.Clone()
+	for _, key := range vcrSecretHeaders {
+		sanitized.Del(key)
+	}
+	return sanitized
+}
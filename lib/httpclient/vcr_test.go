@@ -0,0 +1,153 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// TestVCRTransport 記録したレスポンスから秘密の値が取り除かれ、ネットワークなしで同じ順に再生できることを確認する
+func TestVCRTransport(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vcr", "cassette.json")
+	base := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "geocoder", Method: http.MethodGet, Responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `{"Feature": [1]}`},
+				{StatusCode: http.StatusOK, Body: `{"Feature": [2]}`},
+			}},
+			{Pattern: "/api/i", Method: http.MethodPost, Responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `{"id": "bot"}`, Header: http.Header{"Set-Cookie": {"session=secret-cookie"}}},
+			}},
+			{Pattern: "tile.png", Method: http.MethodGet, Responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: "\x89PNG\xff\xfe"},
+			}},
+		},
+	})
+
+	requests := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{method: http.MethodGet, url: "https://example.com/geocoder?appid=secret-appid&query=%E6%9D%B1%E4%BA%AC"},
+		{method: http.MethodGet, url: "https://example.com/geocoder?appid=secret-appid&query=%E6%9D%B1%E4%BA%AC"},
+		{method: http.MethodPost, url: "https://misskey.example.com/api/i", body: `{"i": "secret-token"}`},
+		{method: http.MethodGet, url: "https://example.com/tile.png"},
+	}
+	do := func(t *testing.T, client *http.Client, method, url, body string) (int, string, error) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(context.Background(), method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("http.NewRequestWithContext() error = %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret-header")
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("io.ReadAll() error = %v", err)
+		}
+		return resp.StatusCode, string(data), nil
+	}
+
+	recorder, err := httpclient.NewVCRTransport(&httpclient.VCRSetting{Path: path, Mode: httpclient.VCRRecord, Base: base})
+	if err != nil {
+		t.Fatalf("NewVCRTransport() error = %v", err)
+	}
+	recorded := make([]string, 0, len(requests))
+	for _, r := range requests {
+		_, body, err := do(t, recorder.Client(), r.method, r.url, r.body)
+		if err != nil {
+			t.Fatalf("record %s error = %v", r.url, err)
+		}
+		recorded = append(recorded, body)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	cassette, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	for _, secret := range []string{"secret-appid", "secret-token", "secret-header", "secret-cookie"} {
+		if strings.Contains(string(cassette), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, cassette)
+		}
+	}
+
+	// 再生時はAPIキーが異なっても秘密の値を取り除いたURLで照合する
+	player, err := httpclient.NewVCRTransport(&httpclient.VCRSetting{Path: path, Mode: httpclient.VCRReplay})
+	if err != nil {
+		t.Fatalf("NewVCRTransport() error = %v", err)
+	}
+	for i, r := range requests {
+		url := strings.ReplaceAll(r.url, "secret-appid", "dummy")
+		status, body, err := do(t, player.Client(), r.method, url, r.body)
+		if err != nil {
+			t.Fatalf("replay %s error = %v", url, err)
+		}
+		if status != http.StatusOK || body != recorded[i] {
+			t.Errorf("replay %s = (%d, %q), want (200, %q)", url, status, body, recorded[i])
+		}
+	}
+
+	// 記録した回数より多いリクエストは再生できない
+	_, _, err = do(t, player.Client(), requests[0].method, requests[0].url, "")
+	if !errors.Is(err, httpclient.ErrVCRInteractionNotFound) {
+		t.Errorf("replay after exhausted error = %v, want %v", err, httpclient.ErrVCRInteractionNotFound)
+	}
+}
+
+// TestNewVCRTransport 設定に応じて記録ファイルを読み込むかを確認する
+func TestNewVCRTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		setting      *httpclient.VCRSetting
+		expectedMode httpclient.VCRMode
+		wantErr      bool
+	}{
+		{
+			name:    "設定がnil",
+			wantErr: true,
+		},
+		{
+			name:    "再生モードで記録ファイルがない",
+			setting: &httpclient.VCRSetting{Path: filepath.Join(t.TempDir(), "missing.json"), Mode: httpclient.VCRReplay},
+			wantErr: true,
+		},
+		{
+			name:         "記録モードでは記録ファイルがなくてもよい",
+			setting:      &httpclient.VCRSetting{Path: filepath.Join(t.TempDir(), "missing.json"), Mode: httpclient.VCRRecord},
+			expectedMode: httpclient.VCRRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport, err := httpclient.NewVCRTransport(tt.setting)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewVCRTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && transport.Mode() != tt.expectedMode {
+				t.Errorf("Mode() = %v, want %v", transport.Mode(), tt.expectedMode)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Locale 返信メッセージの言語
+type Locale string
+
+const (
+	LocaleJa Locale = "ja" // 日本語
+	LocaleEn Locale = "en" // 英語
+
+	// DefaultLocale 言語が指定されていない場合や未対応の言語の場合に使う言語
+	DefaultLocale = LocaleJa
+)
+
+// Key メッセージカタログのキー
+type Key string
+
+const (
+	KeyAmeshSuccess             Key = "amesh.success"              // amesh画像の返信（地名、緯度、経度）
+	KeyAmeshImageDescription    Key = "amesh.image_description"    // amesh画像の説明文（地名、緯度、経度）
+	KeyAmeshCompareSuccess      Key = "amesh.compare_success"      // 複数地点の比較画像の返信（番号付きの地名一覧）
+	KeyAmeshCompareDescription  Key = "amesh.compare_description"  // 複数地点の比較画像の説明文（番号付きの地名一覧）
+	KeyAmeshForecastSuccess     Key = "amesh.forecast_success"     // 予測のパネルを並べた画像の返信（地名、緯度、経度）
+	KeyAmeshForecastDescription Key = "amesh.forecast_description" // 予測のパネルを並べた画像の説明文（地名、緯度、経度）
+	KeyAmeshHistorySuccess      Key = "amesh.history_success"      // 過去の雨雲レーダーを並べた画像の返信（地名、緯度、経度）
+	KeyAmeshHistoryDescription  Key = "amesh.history_description"  // 過去の雨雲レーダーを並べた画像の説明文（地名、緯度、経度）
+	KeyAmeshRadarTime           Key = "amesh.radar_time"           // amesh画像の雨雲レーダーの時刻（時刻）
+	KeyAmeshStaleWarning        Key = "amesh.stale_warning"        // 雨雲レーダーのデータが古い場合の注意（時刻）
+	KeyAmeshElevation           Key = "amesh.elevation"            // amesh画像の説明文に添える地点の標高（標高）
+	KeyAmeshCoverage            Key = "amesh.coverage"             // ameshコマンドの返信に添える距離円の区間の主な市区町村（内側の半径、外側の半径、市区町村の一覧）
+	KeyAmeshCoverageMore        Key = "amesh.coverage_more"        // 距離円の区間の市区町村の一覧に添える省略した数（省略した数）
+	KeyMapSuccess               Key = "map.success"                // mapコマンドの返信（地名、緯度、経度）
+	KeyMapImageDescription      Key = "map.image_description"      // mapコマンドの地図画像の説明文（地名、緯度、経度）
+	KeyRainStart                Key = "rain.start"                 // rainコマンドで1時間以内に雨が降り始める場合の返信（地名、緯度、経度、降り始める時刻）
+	KeyRainNow                  Key = "rain.now"                   // rainコマンドで今雨が降っている場合の返信（地名、緯度、経度）
+	KeyRainNone                 Key = "rain.none"                  // rainコマンドで1時間以内に雨が降らない場合の返信（地名、緯度、経度）
+	KeyRainDescription          Key = "rain.description"           // rainコマンドの降水強度のグラフの説明文（地名、緯度、経度）
+	KeyElevationSuccess         Key = "elevation.success"          // elevationコマンドの返信（地名、緯度、経度、標高）
+	KeyDistanceSuccess          Key = "distance.success"           // distanceコマンドの返信（出発地の地名、到着地の地名、距離、16方位、方位角）
+	KeyDistanceDescription      Key = "distance.description"       // distanceコマンドの地図画像の説明文（出発地の地名、到着地の地名）
+	KeyAmedasSuccess            Key = "amedas.success"             // amedasコマンドの返信（地名、観測所名、観測時刻、気温、湿度、風向、風速、降水量）
+	KeyTranslateSuccess         Key = "translate.success"          // translateコマンドの返信（翻訳結果、原文の言語、翻訳先の言語）
+	KeyWikipediaSuccess         Key = "wikipedia.success"          // wikiコマンドの返信（記事名、要約、URL）
+	KeyWikipediaDisambiguation  Key = "wikipedia.disambiguation"   // wikiコマンドで曖昧さ回避のページだった場合の返信（記事名、候補の記事名、URL）
+	KeyConvertSuccess           Key = "convert.success"            // convertコマンドの返信（変換する値、変換元の単位、変換後の値、変換先の単位）
+	KeyEarthquakeAlert          Key = "earthquake.alert"           // 地震情報の自動投稿（発生時刻、震源、最大震度、深さ、マグニチュード）
+	KeyStatsSuccess             Key = "stats.success"              // statsコマンドの返信（集計の開始時刻、記録の数、送信者の数、コマンドごとの回数、よく使われる地名）
+	KeySelfTestResult           Key = "selftest.result"            // admin selftestコマンドの返信（手順ごとの結果）
+	KeyReloadResult             Key = "reload.result"              // admin reloadコマンドの返信
+	KeyHelpSuccess              Key = "help.success"               // helpコマンドの返信（使えるコマンドの一覧）
+	KeyHelpDisabled             Key = "help.disabled"              // helpコマンドの返信に添える無効にしたコマンド（無効にしたコマンドの一覧）
+	KeyReplyCW                  Key = "reply.cw"                   // CWされた投稿への返信のCW
+	KeyReplyCWWeather           Key = "reply.cw_weather"           // 天気画像のCW（MisskeyのCWの付け方がweatherの場合に画像を添付する返信に付ける）
+	KeyReplyFullImage           Key = "reply.full_image"           // サムネイルを添付した返信に添える元の大きさの画像へのリンク（URL）
+	KeyErrorCommand             Key = "error.command"              // コマンド処理中のエラー
+	KeyErrorUpstreamUnavailable Key = "error.upstream_unavailable" // 外部サービスの障害
+	KeyErrorUpstreamOutage      Key = "error.upstream_outage"      // 気象庁やジオコーダのサーキットブレーカーが開いている（取得できないデータ、停止中の外部サービス）
+	KeyErrorNoRadarData         Key = "error.no_radar_data"        // レーダーデータが取得できない
+	KeyErrorUnknownLayer        Key = "error.unknown_layer"        // 存在しないレイヤーの指定
+	KeyErrorTooManyPlaces       Key = "error.too_many_places"      // 比較する地点が多すぎる
+	KeyErrorNoForecastData      Key = "error.no_forecast_data"     // 雨雲レーダーの予測が取得できない
+	KeyErrorForecastComparison  Key = "error.forecast_comparison"  // 複数の地点の予測を並べようとした
+	KeyErrorNoHistoryData       Key = "error.no_history_data"      // 過去の雨雲レーダーが取得できない
+	KeyErrorHistoryComparison   Key = "error.history_comparison"   // 複数の地点の過去の雨雲レーダーを並べようとした
+	KeyErrorHistoryDuration     Key = "error.history_duration"     // 過去の雨雲レーダーを並べる期間が範囲外
+	KeyErrorMapCommand          Key = "error.map_command"          // mapコマンド処理中のエラー
+	KeyErrorRainCommand         Key = "error.rain_command"         // rainコマンド処理中のエラー
+	KeyErrorElevationCommand    Key = "error.elevation_command"    // elevationコマンド処理中のエラー
+	KeyErrorNoElevation         Key = "error.no_elevation"         // 地点の標高のデータがない
+	KeyErrorDistanceCommand     Key = "error.distance_command"     // distanceコマンド処理中のエラー
+	KeyErrorDistanceUsage       Key = "error.distance_usage"       // distanceコマンドの書式が正しくない
+	KeyErrorAmedasCommand       Key = "error.amedas_command"       // amedasコマンド処理中のエラー
+	KeyErrorNoAmedasStation     Key = "error.no_amedas_station"    // 近くにアメダス観測所がない
+	KeyErrorTranslateCommand    Key = "error.translate_command"    // translateコマンド処理中のエラー
+	KeyErrorTranslateNoText     Key = "error.translate_no_text"    // 翻訳する文章がない
+	KeyErrorWikipediaCommand    Key = "error.wikipedia_command"    // wikiコマンド処理中のエラー
+	KeyErrorWikipediaNotFound   Key = "error.wikipedia_not_found"  // 語句に一致する記事がない
+	KeyErrorWikipediaNoTerm     Key = "error.wikipedia_no_term"    // 調べる語句がない
+	KeyErrorConvertCommand      Key = "error.convert_command"      // convertコマンド処理中のエラー
+	KeyErrorConvertUsage        Key = "error.convert_usage"        // convertコマンドの書式が正しくない
+	KeyErrorConvertUnknownUnit  Key = "error.convert_unknown_unit" // 知らない単位や通貨
+	KeyErrorConvertIncompatible Key = "error.convert_incompatible" // 種類の異なる単位の間の変換
+	KeyErrorStatsCommand        Key = "error.stats_command"        // statsコマンド処理中のエラー
+	KeyErrorSelfTestCommand     Key = "error.selftest_command"     // admin selftestコマンド処理中のエラー
+	KeyErrorReloadCommand       Key = "error.reload_command"       // admin reloadコマンドで設定ファイルを読み込み直せなかった
+	KeyErrorHelpCommand         Key = "error.help_command"         // helpコマンド処理中のエラー
+	KeyErrorCommandDisabled     Key = "error.command_disabled"     // 設定ファイルで無効にしたコマンドを使った
+	KeyErrorNotAdmin            Key = "error.not_admin"            // 管理者以外が管理者向けのコマンドを使った
+	KeyErrorTimeout             Key = "error.timeout"              // コマンドの処理が制限時間を超えた
+	KeyErrorRateLimited         Key = "error.rate_limited"         // 送信者のコマンドの実行回数が上限に達した
+	KeyErrorRequestID           Key = "error.request_id"           // エラーメッセージに添える問い合わせ用のリクエストID（リクエストID）
+)
+
+// catalog 言語ごとのメッセージ
+// 書式指定子の並びは言語間で揃える
+var catalog = map[Locale]map[Key]string{
+	LocaleJa: {
+		KeyAmeshSuccess:             "📡 %s (%.4f, %.4f) の雨雲レーダー画像だっぽ",
+		KeyAmeshImageDescription:    "%s (%.4f, %.4f) の雨雲レーダー画像",
+		KeyAmeshCompareSuccess:      "📡 %s の雨雲レーダーを並べたっぽ",
+		KeyAmeshCompareDescription:  "%s の雨雲レーダーの比較画像",
+		KeyAmeshForecastSuccess:     "📡 %s (%.4f, %.4f) の雨雲レーダーと1時間先までの予測だっぽ（予測は外れることもあるっぽ）",
+		KeyAmeshForecastDescription: "%s (%.4f, %.4f) の雨雲レーダーと予測の画像",
+		KeyAmeshHistorySuccess:      "📡 %s (%.4f, %.4f) のこれまでの雨雲レーダーを並べたっぽ",
+		KeyAmeshHistoryDescription:  "%s (%.4f, %.4f) の過去の雨雲レーダーを並べた画像",
+		KeyAmeshRadarTime:           "レーダー時刻 %s",
+		KeyAmeshElevation:           "標高 %sm",
+		KeyAmeshCoverage:            "⭕ %s〜%skm: %s",
+		KeyAmeshCoverageMore:        "ほか%s件",
+		KeyAmeshStaleWarning:        "⚠️ 気象庁のデータが更新されていないっぽ。%s の古い雨雲レーダーだから、今の様子とは違うかもしれないっぽ",
+		KeyMapSuccess:               "🗺 %s (%.4f, %.4f) の地図だっぽ",
+		KeyMapImageDescription:      "%s (%.4f, %.4f) の地図",
+		KeyRainStart:                "☔ %s (%.4f, %.4f) は%sごろから雨が降りそうだっぽ",
+		KeyRainNow:                  "☔ %s (%.4f, %.4f) は今雨が降っているっぽ",
+		KeyRainNone:                 "🌤 %s (%.4f, %.4f) は1時間以内に雨は降らなさそうだっぽ",
+		KeyRainDescription:          "%s (%.4f, %.4f) の1時間先までの降水強度のグラフ",
+		KeyElevationSuccess:         "⛰ %s (%.4f, %.4f) の標高は%smだっぽ",
+		KeyDistanceSuccess:          "📏 %s から %s までは約%skmで、方角は%s（%s°）だっぽ",
+		KeyDistanceDescription:      "%s と %s を結んだ地図",
+		KeyAmedasSuccess:            "🌡 %s に最も近いアメダス %s の %s の観測値だっぽ\n気温: %s℃\n湿度: %s%%\n風: %s %sm/s\n降水量（前1時間）: %smm",
+		KeyTranslateSuccess:         "🌐 %s\n（%s → %s）",
+		KeyWikipediaSuccess:         "📖 %s\n%s\n%s",
+		KeyWikipediaDisambiguation:  "📖 「%s」にはいくつかの意味があるっぽ\n候補: %s\n%s",
+		KeyConvertSuccess:           "🔁 %s %s は %s %s だっぽ",
+		KeyEarthquakeAlert:          "⚠️ 地震情報だっぽ\n%s頃、%sで最大震度%sの地震があったっぽ\n震源の深さ: %s、マグニチュード: %s",
+		KeyStatsSuccess:             "📊 %sからのコマンドの記録は%s件（%s人）だっぽ\nコマンド: %s\nよく使われる地名: %s",
+		KeySelfTestResult:           "🩺 自己診断の結果だっぽ\n%s",
+		KeyReloadResult:             "🔄 設定ファイルを読み込み直したっぽ",
+		KeyHelpSuccess:              "📋 使えるコマンドだっぽ: %s",
+		KeyHelpDisabled:             "無効になっているコマンド: %s",
+		KeyReplyCW:                  "隠すっぽ！",
+		KeyReplyCWWeather:           "天気画像",
+		KeyReplyFullImage:           "🔍 元の大きさの画像: %s",
+		KeyErrorCommand:             "申し訳ないっぽ。ameshコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorUpstreamUnavailable: "地図サービスが不調っぽ。しばらくしてからもう一度試してほしいっぽ",
+		KeyErrorUpstreamOutage:      "🚧 いま%sのデータが取れないっぽ、あとで試してほしいっぽ（%sに接続できないっぽ）",
+		KeyErrorNoRadarData:         "レーダーデータ取得失敗っぽ。しばらくしてからもう一度試してほしいっぽ",
+		KeyErrorUnknownLayer:        "知らないレイヤーっぽ。layer=にはradar・flood・snowのどれかを指定してほしいっぽ",
+		KeyErrorTooManyPlaces:       "一度に並べられるのは4か所までっぽ",
+		KeyErrorNoForecastData:      "雨雲レーダーの予測が取れなかったっぽ。しばらくしてからもう一度試してほしいっぽ",
+		KeyErrorForecastComparison:  "予測を並べられるのは1か所だけっぽ",
+		KeyErrorNoHistoryData:       "過去の雨雲レーダーが取れなかったっぽ。しばらくしてからもう一度試してほしいっぽ",
+		KeyErrorHistoryComparison:   "過去の雨雲レーダーを並べられるのは1か所だけっぽ",
+		KeyErrorHistoryDuration:     "さかのぼれるのは30mから3hまでっぽ",
+		KeyErrorMapCommand:          "申し訳ないっぽ。mapコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorRainCommand:         "申し訳ないっぽ。rainコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorElevationCommand:    "申し訳ないっぽ。elevationコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorNoElevation:         "その地点の標高のデータが見つからなかったっぽ。海の上や国外の地点は調べられないっぽ",
+		KeyErrorDistanceCommand:     "申し訳ないっぽ。distanceコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorDistanceUsage:       "使い方: distance 東京 / 大阪 のように2つの地名を/で区切ってほしいっぽ",
+		KeyErrorAmedasCommand:       "申し訳ないっぽ。amedasコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorNoAmedasStation:     "近くにアメダスの観測所が見つからなかったっぽ",
+		KeyErrorTranslateCommand:    "申し訳ないっぽ。translateコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorTranslateNoText:     "翻訳する文章がないっぽ。translateの後に文章を書くか、翻訳したい投稿に返信してほしいっぽ",
+		KeyErrorWikipediaCommand:    "申し訳ないっぽ。wikiコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorWikipediaNotFound:   "その言葉の記事は見つからなかったっぽ",
+		KeyErrorWikipediaNoTerm:     "調べたい言葉を教えてほしいっぽ。例: what is 鳩",
+		KeyErrorConvertCommand:      "申し訳ないっぽ。convertコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorConvertUsage:        "使い方: convert 100 USD JPY または convert 5 mile km っぽ",
+		KeyErrorConvertUnknownUnit:  "知らない単位か通貨っぽ",
+		KeyErrorConvertIncompatible: "種類の違う単位の間では変換できないっぽ",
+		KeyErrorStatsCommand:        "申し訳ないっぽ。statsコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorSelfTestCommand:     "申し訳ないっぽ。selftestコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorReloadCommand:       "設定ファイルを読み込み直せなかったっぽ。前の設定のまま動いているっぽ",
+		KeyErrorHelpCommand:         "申し訳ないっぽ。helpコマンドの処理中にエラーが発生したっぽ",
+		KeyErrorCommandDisabled:     "このコマンドはこのボットでは無効になっているっぽ",
+		KeyErrorNotAdmin:            "このコマンドは管理者だけが使えるっぽ",
+		KeyErrorTimeout:             "時間がかかりすぎたので中断したっぽ。しばらくしてからもう一度試してほしいっぽ",
+		KeyErrorRateLimited:         "コマンドの使いすぎっぽ。少し時間をおいてから試してほしいっぽ",
+		KeyErrorRequestID:           "（問い合わせID: %s）",
+	},
+	LocaleEn: {
+		KeyAmeshSuccess:             "📡 Rain radar image for %s (%.4f, %.4f)",
+		KeyAmeshImageDescription:    "Rain radar image for %s (%.4f, %.4f)",
+		KeyAmeshCompareSuccess:      "📡 Rain radar comparison for %s",
+		KeyAmeshCompareDescription:  "Rain radar comparison image for %s",
+		KeyAmeshForecastSuccess:     "📡 Rain radar and forecast up to 1 hour ahead for %s (%.4f, %.4f) (forecasts may be wrong)",
+		KeyAmeshForecastDescription: "Rain radar and forecast image for %s (%.4f, %.4f)",
+		KeyAmeshHistorySuccess:      "📡 Rain radar over the past hours for %s (%.4f, %.4f)",
+		KeyAmeshHistoryDescription:  "Past rain radar image for %s (%.4f, %.4f)",
+		KeyAmeshRadarTime:           "Radar time %s",
+		KeyAmeshElevation:           "Elevation %sm",
+		KeyAmeshCoverage:            "⭕ %s-%skm: %s",
+		KeyAmeshCoverageMore:        "and %s more",
+		KeyAmeshStaleWarning:        "⚠️ JMA data has not been updated. This radar is from %s and may not reflect current conditions",
+		KeyMapSuccess:               "🗺 Map of %s (%.4f, %.4f)",
+		KeyMapImageDescription:      "Map of %s (%.4f, %.4f)",
+		KeyRainStart:                "☔ %s (%.4f, %.4f): rain is likely to start around %s",
+		KeyRainNow:                  "☔ It is raining now in %s (%.4f, %.4f)",
+		KeyRainNone:                 "🌤 No rain is expected within an hour in %s (%.4f, %.4f)",
+		KeyRainDescription:          "Precipitation chart for the next hour in %s (%.4f, %.4f)",
+		KeyElevationSuccess:         "⛰ The elevation of %s (%.4f, %.4f) is %sm",
+		KeyDistanceSuccess:          "📏 From %s to %s is about %skm, heading %s (%s°)",
+		KeyDistanceDescription:      "Map connecting %s and %s",
+		KeyAmedasSuccess:            "🌡 Nearest AMeDAS station to %s: %s (as of %s)\nTemperature: %s°C\nHumidity: %s%%\nWind: %s %sm/s\nPrecipitation (1h): %smm",
+		KeyTranslateSuccess:         "🌐 %s\n(%s → %s)",
+		KeyWikipediaSuccess:         "📖 %s\n%s\n%s",
+		KeyWikipediaDisambiguation:  "📖 \"%s\" may refer to several articles\nCandidates: %s\n%s",
+		KeyConvertSuccess:           "🔁 %s %s = %s %s",
+		KeyEarthquakeAlert:          "⚠️ Earthquake information\nAn earthquake occurred around %s in %s with a maximum seismic intensity of %s\nDepth: %s, Magnitude: %s",
+		KeyStatsSuccess:             "📊 %[2]s commands from %[3]s users since %[1]s\nCommands: %[4]s\nTop places: %[5]s",
+		KeySelfTestResult:           "🩺 Self-test results\n%s",
+		KeyReloadResult:             "🔄 Reloaded the config file.",
+		KeyHelpSuccess:              "📋 Available commands: %s",
+		KeyHelpDisabled:             "Disabled commands: %s",
+		KeyReplyCW:                  "Hidden!",
+		KeyReplyCWWeather:           "Weather image",
+		KeyReplyFullImage:           "🔍 Full-size image: %s",
+		KeyErrorCommand:             "Sorry, an error occurred while processing the amesh command.",
+		KeyErrorUpstreamUnavailable: "The map service seems to be having trouble. Please try again later.",
+		KeyErrorUpstreamOutage:      "🚧 %s data is unavailable right now because %s is down. Please try again later.",
+		KeyErrorNoRadarData:         "Failed to fetch radar data. Please try again later.",
+		KeyErrorUnknownLayer:        "Unknown layer. Please specify radar, flood or snow for layer=.",
+		KeyErrorTooManyPlaces:       "Up to 4 places can be compared at once.",
+		KeyErrorNoForecastData:      "Failed to fetch the rain radar forecast. Please try again later.",
+		KeyErrorForecastComparison:  "The forecast is available for only one place at a time.",
+		KeyErrorNoHistoryData:       "Failed to fetch the past rain radar. Please try again later.",
+		KeyErrorHistoryComparison:   "The past rain radar is available for only one place at a time.",
+		KeyErrorHistoryDuration:     "The history can go back between 30m and 3h.",
+		KeyErrorMapCommand:          "Sorry, an error occurred while processing the map command.",
+		KeyErrorRainCommand:         "Sorry, an error occurred while processing the rain command.",
+		KeyErrorElevationCommand:    "Sorry, an error occurred while processing the elevation command.",
+		KeyErrorNoElevation:         "No elevation data was found for the place. Points at sea or outside Japan are not supported.",
+		KeyErrorDistanceCommand:     "Sorry, an error occurred while processing the distance command.",
+		KeyErrorDistanceUsage:       "Usage: separate two places with /, like distance Tokyo / Osaka",
+		KeyErrorAmedasCommand:       "Sorry, an error occurred while processing the amedas command.",
+		KeyErrorNoAmedasStation:     "No AMeDAS station was found near the place.",
+		KeyErrorTranslateCommand:    "Sorry, an error occurred while processing the translate command.",
+		KeyErrorTranslateNoText:     "Nothing to translate. Write text after translate, or reply to the post you want translated.",
+		KeyErrorWikipediaCommand:    "Sorry, an error occurred while processing the wiki command.",
+		KeyErrorWikipediaNotFound:   "No article was found for the term.",
+		KeyErrorWikipediaNoTerm:     "Please tell me what to look up. Example: what is pigeon",
+		KeyErrorConvertCommand:      "Sorry, an error occurred while processing the convert command.",
+		KeyErrorConvertUsage:        "Usage: convert 100 USD JPY or convert 5 mile km",
+		KeyErrorConvertUnknownUnit:  "Unknown unit or currency.",
+		KeyErrorConvertIncompatible: "Cannot convert between different kinds of units.",
+		KeyErrorStatsCommand:        "Sorry, an error occurred while processing the stats command.",
+		KeyErrorSelfTestCommand:     "Sorry, an error occurred while processing the selftest command.",
+		KeyErrorReloadCommand:       "Failed to reload the config file. The previous settings are still in use.",
+		KeyErrorHelpCommand:         "Sorry, an error occurred while processing the help command.",
+		KeyErrorCommandDisabled:     "This command is disabled on this bot.",
+		KeyErrorNotAdmin:            "This command is only available to administrators.",
+		KeyErrorTimeout:             "The command took too long and was cancelled. Please try again later.",
+		KeyErrorRateLimited:         "You are sending commands too often. Please wait a moment and try again.",
+		KeyErrorRequestID:           "(Request ID: %s)",
+	},
+}
+
+// upstreamNames 外部サービスの識別子（httpclient.UpstreamJMAなど）ごとの表示名と、そこから取得するデータの名前
+var upstreamNames = map[Locale]map[string][2]string{
+	LocaleJa: {
+		"jma":            {"気象庁", "雨雲レーダー"},
+		"yahoo_geocoder": {"Yahoo!ジオコーダ", "地名検索"},
+		"nominatim":      {"Nominatim", "地名検索"},
+		"osm":            {"OpenStreetMap", "地図"},
+		"gsi":            {"国土地理院", "標高"},
+	},
+	LocaleEn: {
+		"jma":            {"JMA", "Rain radar"},
+		"yahoo_geocoder": {"Yahoo! Geocoder", "Place search"},
+		"nominatim":      {"Nominatim", "Place search"},
+		"osm":            {"OpenStreetMap", "Map"},
+		"gsi":            {"GSI", "Elevation"},
+	},
+}
+
+// UpstreamName 外部サービスの識別子から表示名と取得するデータの名前を返す
+// 言語に識別子がない場合はDefaultLocaleの名前、どちらにもない場合は識別子をそのまま返す
+func UpstreamName(locale Locale, upstream string) (name, data string) {
+	names, ok := upstreamNames[locale][upstream]
+	if !ok {
+		names, ok = upstreamNames[DefaultLocale][upstream]
+	}
+	if !ok {
+		return upstream, upstream
+	}
+	return names[0], names[1]
+}
+
+// ParseLocale 言語タグ（ja, en-US, en_GBなど）から対応する言語を返す
+// 空文字列や未対応の言語の場合はDefaultLocaleを返す
+func ParseLocale(s string) Locale {
+	tag := strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(tag, "-_"); 0 <= i {
+		tag = tag[:i]
+	}
+
+	if _, ok := catalog[Locale(tag)]; ok {
+		return Locale(tag)
+	}
+	return DefaultLocale
+}
+
+// Message 指定した言語のメッセージを返す
+// 言語にキーがない場合はDefaultLocaleのメッセージ、どちらにもない場合はキーをそのまま返す
+func Message(locale Locale, key Key, args ...any) string {
+	format, ok := catalog[locale][key]
+	if !ok {
+		format, ok = catalog[DefaultLocale][key]
+	}
+	if !ok {
+		return string(key)
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,125 @@
+package i18n_test
+
+import (
+	"testing"
+
+	"hato-bot-go/lib/i18n"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected i18n.Locale
+	}{
+		{name: "日本語", input: "ja", expected: i18n.LocaleJa},
+		{name: "英語", input: "en", expected: i18n.LocaleEn},
+		{name: "地域付きの英語", input: "en-US", expected: i18n.LocaleEn},
+		{name: "アンダースコア区切りと大文字", input: " EN_gb ", expected: i18n.LocaleEn},
+		{name: "空文字列はデフォルト", input: "", expected: i18n.DefaultLocale},
+		{name: "未対応の言語はデフォルト", input: "fr", expected: i18n.DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := i18n.ParseLocale(tt.input); result != tt.expected {
+				t.Errorf("ParseLocale(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   i18n.Locale
+		key      i18n.Key
+		args     []any
+		expected string
+	}{
+		{
+			name:     "日本語の書式付きメッセージ",
+			locale:   i18n.LocaleJa,
+			key:      i18n.KeyAmeshSuccess,
+			args:     []any{"東京", 35.6895, 139.6917},
+			expected: "📡 東京 (35.6895, 139.6917) の雨雲レーダー画像だっぽ",
+		},
+		{
+			name:     "英語の書式付きメッセージ",
+			locale:   i18n.LocaleEn,
+			key:      i18n.KeyAmeshSuccess,
+			args:     []any{"Tokyo", 35.6895, 139.6917},
+			expected: "📡 Rain radar image for Tokyo (35.6895, 139.6917)",
+		},
+		{
+			name:     "書式なしのメッセージ",
+			locale:   i18n.LocaleJa,
+			key:      i18n.KeyReplyCW,
+			expected: "隠すっぽ！",
+		},
+		{
+			name:     "未対応の言語はデフォルトの言語",
+			locale:   i18n.Locale("fr"),
+			key:      i18n.KeyReplyCW,
+			expected: "隠すっぽ！",
+		},
+		{
+			name:     "存在しないキーはキーを返す",
+			locale:   i18n.LocaleEn,
+			key:      i18n.Key("unknown.key"),
+			expected: "unknown.key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := i18n.Message(tt.locale, tt.key, tt.args...); result != tt.expected {
+				t.Errorf("Message() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUpstreamName(t *testing.T) {
+	tests := []struct {
+		name         string
+		locale       i18n.Locale
+		upstream     string
+		expectedName string
+		expectedData string
+	}{
+		{
+			name:         "日本語の気象庁",
+			locale:       i18n.LocaleJa,
+			upstream:     "jma",
+			expectedName: "気象庁",
+			expectedData: "雨雲レーダー",
+		},
+		{
+			name:         "英語のジオコーダ",
+			locale:       i18n.LocaleEn,
+			upstream:     "yahoo_geocoder",
+			expectedName: "Yahoo! Geocoder",
+			expectedData: "Place search",
+		},
+		{
+			name:         "知らない外部サービスは識別子",
+			locale:       i18n.LocaleJa,
+			upstream:     "example.com",
+			expectedName: "example.com",
+			expectedData: "example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			name, data := i18n.UpstreamName(tt.locale, tt.upstream)
+			if name != tt.expectedName || data != tt.expectedData {
+				t.Errorf("UpstreamName() = (%q, %q), want (%q, %q)", name, data, tt.expectedName, tt.expectedData)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package i18n
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrUnknownTemplateKey 返信テンプレートのキーがメッセージカタログに存在しないことを表すエラー
+var ErrUnknownTemplateKey = errors.New("unknown template key")
+
+// TemplateData 返信テンプレートに渡す変数
+type TemplateData struct {
+	Locale    Locale  // 返信メッセージの言語
+	PlaceName string  // 地名
+	Lat       float64 // 緯度
+	Lng       float64 // 経度
+	User      string  // 返信先のユーザー
+	RadarTime string  // 雨雲レーダーの時刻（例: 12:05 JST）
+	RequestID string  // 問い合わせ用の短いリクエストID（エラーメッセージ）
+
+	// サーキットブレーカーが開いている外部サービス（エラーメッセージ）
+	Upstream     string // 停止中の外部サービスの表示名
+	UpstreamData string // 停止中の外部サービスから取得するデータの名前
+
+	// rainコマンドの予測
+	RainStart string // 雨が降り始める時刻（例: 12:35 JST）
+
+	// elevationコマンドとameshコマンドの画像の説明文の標高
+	Elevation string // 標高（メートル、小数点以下1桁）
+
+	// distanceコマンドの計測結果
+	DistanceFrom string // 出発地の地名
+	DistanceTo   string // 到着地の地名
+	Distance     string // 大円距離（km）
+	Direction    string // 出発地から見た到着地の16方位
+	Bearing      string // 出発地から見た到着地の方位角（度、北から時計回り）
+
+	// ameshコマンドの返信に添える距離円の区間の主な市区町村
+	CoverageInner  string // 区間の内側の円の半径（km）
+	CoverageOuter  string // 区間の外側の円の半径（km）
+	CoveragePlaces string // 中心から近い順の市区町村名（区切り文字で連結）
+	CoverageMore   string // 省略した市区町村の数
+
+	// amedasコマンドの観測値（欠測の場合は---）
+	Station       string // アメダス観測所名
+	ObservedAt    string // 観測時刻
+	Temperature   string // 気温（℃）
+	Humidity      string // 湿度（%）
+	WindDirection string // 風向（16方位）
+	WindSpeed     string // 風速（m/s）
+	Precipitation string // 前1時間降水量（mm）
+
+	// translateコマンドの翻訳結果
+	Translation    string // 翻訳した文章
+	SourceLanguage string // 原文の言語（ISO 639-1、判定できなかった場合は空）
+	TargetLanguage string // 翻訳先の言語（ISO 639-1）
+
+	// wikiコマンドの記事
+	Title      string // 記事名
+	Extract    string // 記事の要約
+	URL        string // 記事のURL（サムネイルを添付した返信では元の大きさの画像のURL）
+	Candidates string // 曖昧さ回避のページの場合の候補の記事名（区切り文字で連結）
+
+	// convertコマンドの変換結果
+	Amount    string // 変換する値
+	FromUnit  string // 変換元の単位の記号または通貨コード
+	Converted string // 変換後の値
+	ToUnit    string // 変換先の単位の記号または通貨コード
+
+	// statsコマンドの集計（記録がない値は-）
+	StatsSince    string // 最も古い記録の時刻
+	StatsTotal    string // 記録の数
+	StatsUsers    string // 送信者の数
+	StatsCommands string // コマンドごとの実行回数（多い順に区切り文字で連結）
+	StatsPlaces   string // よく使われる地名と回数（多い順に区切り文字で連結）
+
+	// admin selftestコマンドの結果
+	SelfTestSteps string // 手順ごとの成否と結果（1行に1手順）
+
+	// helpコマンドの一覧
+	HelpCommands string // 使えるコマンド名（区切り文字で連結）
+	HelpDisabled string // 設定ファイルで無効にしたコマンド名（区切り文字で連結）
+
+	// 地震情報（不明な値は?）
+	EarthquakeTime string // 発生時刻
+	Epicenter      string // 震源
+	Intensity      string // 最大震度
+	Depth          string // 震源の深さ
+	Magnitude      string // マグニチュード
+}
+
+// Templates メッセージキーごとの返信テンプレート
+// 設定されていないキーはメッセージカタログの文言を使う
+// 複数のgoroutineから同時に使える
+type Templates struct {
+	mu        sync.RWMutex
+	templates map[Key]*template.Template
+}
+
+// ParseTemplates キーとGoテンプレート文字列の組から返信テンプレートを作成する
+func ParseTemplates(src map[string]string) (*Templates, error) {
+	templates := &Templates{templates: make(map[Key]*template.Template, len(src))}
+	for name, text := range src {
+		key := Key(name)
+		if _, ok := catalog[DefaultLocale][key]; !ok {
+			return nil, errors.Wrapf(ErrUnknownTemplateKey, "key: %s", name)
+		}
+
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to template.Parse")
+		}
+		templates.templates[key] = tmpl
+	}
+	return templates, nil
+}
+
+// Render メッセージキーに対応する返信メッセージを作成する
+// テンプレートがない場合や実行に失敗した場合はメッセージカタログの文言を返す
+// レシーバーがnilの場合は常にメッセージカタログの文言を返す
+func (t *Templates) Render(key Key, data *TemplateData) string {
+	if data == nil {
+		data = &TemplateData{}
+	}
+
+	if t != nil {
+		t.mu.RLock()
+		tmpl, ok := t.templates[key]
+		t.mu.RUnlock()
+		if ok {
+			var sb strings.Builder
+			err := tmpl.Execute(&sb, data)
+			if err == nil {
+				return sb.String()
+			}
+			log.Printf("Failed to execute template %s: %v", key, err)
+		}
+	}
+
+	return Message(data.Locale, key, catalogArgs(key, data)...)
+}
+
+// Replace 返信テンプレートをotherの内容に置き換える（設定ファイルを読み込み直した場合）
+// 同じTemplatesを参照しているボットやコマンドは次の返信から新しいテンプレートを使う
+// レシーバーがnilの場合は何もしない
+func (t *Templates) Replace(other *Templates) {
+	if t == nil {
+		return
+	}
+	var templates map[Key]*template.Template
+	if other != nil {
+		other.mu.RLock()
+		templates = other.templates
+		other.mu.RUnlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.templates = templates
+}
+
+// catalogArgs メッセージカタログの書式指定子に渡す引数を返す
+func catalogArgs(key Key, data *TemplateData) []any {
+	switch key {
+	case KeyAmeshSuccess, KeyAmeshImageDescription, KeyAmeshForecastSuccess, KeyAmeshForecastDescription, KeyAmeshHistorySuccess, KeyAmeshHistoryDescription, KeyMapSuccess, KeyMapImageDescription:
+		return []any{data.PlaceName, data.Lat, data.Lng}
+	case KeyRainStart:
+		return []any{data.PlaceName, data.Lat, data.Lng, data.RainStart}
+	case KeyRainNow, KeyRainNone, KeyRainDescription:
+		return []any{data.PlaceName, data.Lat, data.Lng}
+	case KeyElevationSuccess:
+		return []any{data.PlaceName, data.Lat, data.Lng, data.Elevation}
+	case KeyDistanceSuccess:
+		return []any{data.DistanceFrom, data.DistanceTo, data.Distance, data.Direction, data.Bearing}
+	case KeyDistanceDescription:
+		return []any{data.DistanceFrom, data.DistanceTo}
+	case KeyAmeshElevation:
+		return []any{data.Elevation}
+	case KeyAmeshCoverage:
+		return []any{data.CoverageInner, data.CoverageOuter, data.CoveragePlaces}
+	case KeyAmeshCoverageMore:
+		return []any{data.CoverageMore}
+	case KeyAmeshCompareSuccess, KeyAmeshCompareDescription:
+		return []any{data.PlaceName}
+	case KeyAmeshRadarTime, KeyAmeshStaleWarning:
+		return []any{data.RadarTime}
+	case KeyErrorRequestID:
+		return []any{data.RequestID}
+	case KeyErrorUpstreamOutage:
+		return []any{data.UpstreamData, data.Upstream}
+	case KeyWikipediaSuccess:
+		return []any{data.Title, data.Extract, data.URL}
+	case KeyWikipediaDisambiguation:
+		return []any{data.Title, data.Candidates, data.URL}
+	case KeyEarthquakeAlert:
+		return []any{data.EarthquakeTime, data.Epicenter, data.Intensity, data.Depth, data.Magnitude}
+	case KeyStatsSuccess:
+		return []any{data.StatsSince, data.StatsTotal, data.StatsUsers, data.StatsCommands, data.StatsPlaces}
+	case KeySelfTestResult:
+		return []any{data.SelfTestSteps}
+	case KeyHelpSuccess:
+		return []any{data.HelpCommands}
+	case KeyHelpDisabled:
+		return []any{data.HelpDisabled}
+	case KeyReplyFullImage:
+		return []any{data.URL}
+	case KeyConvertSuccess:
+		return []any{data.Amount, data.FromUnit, data.Converted, data.ToUnit}
+	case KeyTranslateSuccess:
+		return []any{data.Translation, data.SourceLanguage, data.TargetLanguage}
+	case KeyAmedasSuccess:
+		return []any{
+			data.PlaceName,
+			data.Station,
+			data.ObservedAt,
+			data.Temperature,
+			data.Humidity,
+			data.WindDirection,
+			data.WindSpeed,
+			data.Precipitation,
+		}
+	default:
+		return nil
+	}
+}
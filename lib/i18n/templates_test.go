@@ -0,0 +1,161 @@
+package i18n_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/i18n"
+)
+
+func TestParseTemplates(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         map[string]string
+		expectError error
+	}{
+		{
+			name: "メッセージキーのテンプレート",
+			src:  map[string]string{"amesh.success": "{{.PlaceName}}の画像"},
+		},
+		{
+			name:        "存在しないキー",
+			src:         map[string]string{"unknown.key": "text"},
+			expectError: i18n.ErrUnknownTemplateKey,
+		},
+		{
+			name:        "構文エラー",
+			src:         map[string]string{"reply.cw": "{{.User"},
+			expectError: errors.New(""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := i18n.ParseTemplates(tt.src)
+			if tt.expectError == nil {
+				if err != nil {
+					t.Errorf("ParseTemplates() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ParseTemplates() expected error")
+			}
+			if errors.Is(tt.expectError, i18n.ErrUnknownTemplateKey) && !errors.Is(err, i18n.ErrUnknownTemplateKey) {
+				t.Errorf("ParseTemplates() error = %v, want ErrUnknownTemplateKey", err)
+			}
+		})
+	}
+}
+
+func TestTemplatesRender(t *testing.T) {
+	data := &i18n.TemplateData{
+		Locale:    i18n.LocaleJa,
+		PlaceName: "東京",
+		Lat:       35.6895,
+		Lng:       139.6917,
+		User:      "alice",
+		RadarTime: "12:05 JST",
+		RequestID: "0123abcd",
+	}
+
+	tests := []struct {
+		name     string
+		src      map[string]string // nilの場合はテンプレートなし
+		key      i18n.Key
+		data     *i18n.TemplateData
+		expected string
+	}{
+		{
+			name:     "テンプレートで上書き",
+			src:      map[string]string{"amesh.success": `@{{.User}} {{.PlaceName}} ({{printf "%.2f" .Lat}}, {{printf "%.2f" .Lng}})`},
+			key:      i18n.KeyAmeshSuccess,
+			data:     data,
+			expected: "@alice 東京 (35.69, 139.69)",
+		},
+		{
+			name:     "テンプレートがないキーはカタログの文言",
+			src:      map[string]string{"reply.cw": "CW"},
+			key:      i18n.KeyAmeshSuccess,
+			data:     data,
+			expected: "📡 東京 (35.6895, 139.6917) の雨雲レーダー画像だっぽ",
+		},
+		{
+			name:     "レーダー時刻のカタログの文言",
+			src:      nil,
+			key:      i18n.KeyAmeshRadarTime,
+			data:     data,
+			expected: "レーダー時刻 12:05 JST",
+		},
+		{
+			name:     "古い雨雲レーダーの注意のカタログの文言",
+			src:      nil,
+			key:      i18n.KeyAmeshStaleWarning,
+			data:     data,
+			expected: "⚠️ 気象庁のデータが更新されていないっぽ。12:05 JST の古い雨雲レーダーだから、今の様子とは違うかもしれないっぽ",
+		},
+		{
+			name:     "リクエストIDのカタログの文言",
+			src:      nil,
+			key:      i18n.KeyErrorRequestID,
+			data:     data,
+			expected: "（問い合わせID: 0123abcd）",
+		},
+		{
+			name:     "nilのテンプレートはカタログの文言",
+			src:      nil,
+			key:      i18n.KeyReplyCW,
+			data:     nil,
+			expected: "隠すっぽ！",
+		},
+		{
+			name:     "実行に失敗した場合はカタログの文言",
+			src:      map[string]string{"reply.cw": "{{.Unknown}}"},
+			key:      i18n.KeyReplyCW,
+			data:     data,
+			expected: "隠すっぽ！",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var templates *i18n.Templates
+			if tt.src != nil {
+				var err error
+				templates, err = i18n.ParseTemplates(tt.src)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if result := templates.Render(tt.key, tt.data); result != tt.expected {
+				t.Errorf("Render() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestTemplatesReplace 置き換えた返信テンプレートを次の返信から使うことをテストする
+func TestTemplatesReplace(t *testing.T) {
+	t.Parallel()
+	templates, err := i18n.ParseTemplates(map[string]string{"reply.cw": "before"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := i18n.ParseTemplates(map[string]string{"reply.cw": "after"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates.Replace(reloaded)
+	if result := templates.Render(i18n.KeyReplyCW, nil); result != "after" {
+		t.Errorf("Render() = %q, want %q", result, "after")
+	}
+	templates.Replace(nil)
+	if result := templates.Render(i18n.KeyReplyCW, nil); result != "隠すっぽ！" {
+		t.Errorf("Render() = %q, want %q", result, "隠すっぽ！")
+	}
+}
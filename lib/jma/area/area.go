@@ -0,0 +1,349 @@
+package area
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+)
+
+// エラー定数
+var (
+	ErrOfficeNotFound  = errors.New("jma office not found")
+	ErrStationNotFound = errors.New("amedas station not found")
+)
+
+// 気象庁の地域・観測所の一覧のURL
+const (
+	areaURL         = "https://www.jma.go.jp/bosai/common/const/area.json"
+	stationTableURL = "https://www.jma.go.jp/bosai/amedas/const/amedastable.json"
+)
+
+// prefectureSuffixes 名前で探す際に補う都道府県名の接尾辞
+var prefectureSuffixes = []string{"都", "府", "県"}
+
+// embeddedArea 気象庁から取得できない場合に使う地域の一覧（area.jsonのcentersとofficesのみ）
+//
+//go:embed data/area.json
+var embeddedArea []byte
+
+// embeddedStationTable 気象庁から取得できない場合に使う観測所の一覧（主要な観測所のみ）
+//
+//go:embed data/amedastable.json
+var embeddedStationTable []byte
+
+// defaultClient Defaultが使うHTTPクライアント
+// 外部サービスが不調な場合はサーキットブレーカーで即座に失敗させ、埋め込みデータを使う
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
+	Timeout:   30 * time.Second,
+}
+
+// Default 気象庁から取得した一覧を1日キャッシュするResolver
+// 地域・観測所の一覧はほとんど変わらないため長めにキャッシュする
+var Default = NewResolver(&ResolverSetting{
+	Client: defaultClient,
+	AreaCache: httpclient.NewResponseCache(&httpclient.ResponseCacheSetting{
+		Name: "jma_area",
+		TTL:  24 * time.Hour,
+	}),
+	StationCache: httpclient.NewResponseCache(&httpclient.ResponseCacheSetting{
+		Name: "jma_amedastable",
+		TTL:  24 * time.Hour,
+	}),
+})
+
+// Office 府県予報区（天気予報・警報を発表する単位）
+type Office struct {
+	Code       string  // 地域コード（130000など）
+	Name       string  // 名前（東京都など）
+	EnName     string  // 英語の名前
+	OfficeName string  // 担当する気象台
+	Parent     string  // 地方予報区のコード
+	Lat        float64 // 代表地点の緯度（不明な場合は0）
+	Lng        float64 // 代表地点の経度（不明な場合は0）
+}
+
+// Station アメダス観測所
+type Station struct {
+	Code     string  // 観測所番号
+	Name     string  // 観測所名（漢字）
+	KanaName string  // 観測所名（かな）
+	EnName   string  // 観測所名（英語）
+	Lat      float64 // 緯度
+	Lng      float64 // 経度
+}
+
+// NearestOfficeResult 最寄りの府県予報区の検索結果
+type NearestOfficeResult struct {
+	Office     Office  // 府県予報区
+	DistanceKm float64 // 指定した位置から代表地点までの距離（キロメートル）
+}
+
+// NearestStationResult 最寄りの観測所の検索結果
+type NearestStationResult struct {
+	Station    Station // 観測所
+	DistanceKm float64 // 指定した位置から観測所までの距離（キロメートル）
+}
+
+// ResolverSetting Resolverの設定
+type ResolverSetting struct {
+	Client       *http.Client              // HTTPクライアント（nilの場合は埋め込みデータだけを使う）
+	AreaCache    *httpclient.ResponseCache // 地域の一覧のキャッシュ（nilの場合はキャッシュしない）
+	StationCache *httpclient.ResponseCache // 観測所の一覧のキャッシュ（nilの場合はキャッシュしない）
+}
+
+// Resolver 地名や座標から気象庁の地域コード・アメダスの観測所番号を探す
+// 気象庁から一覧を取得できない場合は埋め込みデータで探す
+type Resolver struct {
+	setting ResolverSetting
+}
+
+// areaTable 地域の一覧（area.json）
+type areaTable struct {
+	Offices map[string]areaEntry `json:"offices"`
+}
+
+// areaEntry 地域の一覧の要素
+type areaEntry struct {
+	Name       string `json:"name"`
+	EnName     string `json:"enName"`
+	OfficeName string `json:"officeName"`
+	Parent     string `json:"parent"`
+}
+
+// stationTableEntry 観測所の一覧（amedastable.json）の要素
+// 緯度・経度は[度, 分]の組で表される
+type stationTableEntry struct {
+	KjName string     `json:"kjName"`
+	KnName string     `json:"knName"`
+	EnName string     `json:"enName"`
+	Lat    [2]float64 `json:"lat"`
+	Lon    [2]float64 `json:"lon"`
+}
+
+// fetchParams 一覧の取得のパラメータ
+type fetchParams struct {
+	URL      string                    // 取得するURL
+	Cache    *httpclient.ResponseCache // キャッシュ
+	Fallback []byte                    // 取得できない場合に使う埋め込みデータ
+}
+
+// NewResolver 新しいResolverを作成する
+func NewResolver(setting *ResolverSetting) *Resolver {
+	s := ResolverSetting{}
+	if setting != nil {
+		s = *setting
+	}
+	return &Resolver{setting: s}
+}
+
+// Offices 府県予報区の一覧を地域コード順に返す
+func (r *Resolver) Offices(ctx context.Context) ([]Office, error) {
+	body := r.fetch(ctx, &fetchParams{URL: areaURL, Cache: r.setting.AreaCache, Fallback: embeddedArea})
+	var table areaTable
+	if err := json.Unmarshal(body, &table); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+
+	offices := make([]Office, 0, len(table.Offices))
+	for code, entry := range table.Offices {
+		location := officeLocations[code]
+		offices = append(offices, Office{
+			Code:       code,
+			Name:       entry.Name,
+			EnName:     entry.EnName,
+			OfficeName: entry.OfficeName,
+			Parent:     entry.Parent,
+			Lat:        location.Lat,
+			Lng:        location.Lng,
+		})
+	}
+	slices.SortFunc(offices, func(a, b Office) int { return cmp.Compare(a.Code, b.Code) })
+	return offices, nil
+}
+
+// OfficeByName 都道府県名や府県予報区の名前から府県予報区を探す
+// 「東京」のように都府県を省略した名前や英語の名前でも探せる
+func (r *Resolver) OfficeByName(ctx context.Context, name string) (*Office, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.Wrap(ErrOfficeNotFound, "name is empty")
+	}
+	offices, err := r.Offices(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Offices")
+	}
+
+	for _, candidate := range nameCandidates(name) {
+		code, aliased := officeAliases[candidate]
+		for _, office := range offices {
+			if (aliased && office.Code == code) || office.Name == candidate || strings.EqualFold(office.EnName, candidate) {
+				return &office, nil
+			}
+		}
+	}
+	return nil, errors.Wrapf(ErrOfficeNotFound, "name: %s", name)
+}
+
+// NearestOffice 代表地点が指定した位置に最も近い府県予報区を探す
+func (r *Resolver) NearestOffice(ctx context.Context, lat, lng float64) (*NearestOfficeResult, error) {
+	offices, err := r.Offices(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Offices")
+	}
+
+	var nearest *NearestOfficeResult
+	for _, office := range offices {
+		if office.Lat == 0 && office.Lng == 0 {
+			continue
+		}
+		distance := distanceKm(lat, lng, office.Lat, office.Lng)
+		if nearest == nil || distance < nearest.DistanceKm {
+			nearest = &NearestOfficeResult{Office: office, DistanceKm: distance}
+		}
+	}
+	if nearest == nil {
+		return nil, errors.Wrapf(ErrOfficeNotFound, "location: (%.4f, %.4f)", lat, lng)
+	}
+	return nearest, nil
+}
+
+// Stations アメダス観測所の一覧を観測所番号順に返す
+func (r *Resolver) Stations(ctx context.Context) ([]Station, error) {
+	body := r.fetch(ctx, &fetchParams{URL: stationTableURL, Cache: r.setting.StationCache, Fallback: embeddedStationTable})
+	var table map[string]stationTableEntry
+	if err := json.Unmarshal(body, &table); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+
+	stations := make([]Station, 0, len(table))
+	for code, entry := range table {
+		stations = append(stations, Station{
+			Code:     code,
+			Name:     entry.KjName,
+			KanaName: entry.KnName,
+			EnName:   entry.EnName,
+			Lat:      degrees(entry.Lat),
+			Lng:      degrees(entry.Lon),
+		})
+	}
+	slices.SortFunc(stations, func(a, b Station) int { return cmp.Compare(a.Code, b.Code) })
+	return stations, nil
+}
+
+// StationByName 観測所名（漢字・かな・英語）から観測所を探す
+// 同じ名前の観測所が複数ある場合は観測所番号の小さいものを返す
+func (r *Resolver) StationByName(ctx context.Context, name string) (*Station, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.Wrap(ErrStationNotFound, "name is empty")
+	}
+	stations, err := r.Stations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Stations")
+	}
+
+	for _, station := range stations {
+		if station.Name == name || station.KanaName == name || strings.EqualFold(station.EnName, name) {
+			return &station, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrStationNotFound, "name: %s", name)
+}
+
+// NearestStation 指定した位置に最も近い観測所を探す
+// 距離が同じ場合は観測所番号の小さいものを返す
+func (r *Resolver) NearestStation(ctx context.Context, lat, lng float64) (*NearestStationResult, error) {
+	nearby, err := r.StationsNear(ctx, &StationsNearParams{Lat: lat, Lng: lng})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to StationsNear")
+	}
+	if len(nearby) == 0 {
+		return nil, errors.Wrapf(ErrStationNotFound, "location: (%.4f, %.4f)", lat, lng)
+	}
+	return &nearby[0], nil
+}
+
+// StationsNearParams 近くの観測所の検索のパラメータ
+type StationsNearParams struct {
+	Lat           float64 // 緯度
+	Lng           float64 // 経度
+	MaxDistanceKm float64 // 探す範囲（キロメートル、0以下の場合は制限しない）
+}
+
+// StationsNear 指定した範囲内の観測所を近い順に返す
+// 距離が同じ場合は観測所番号の小さいものを先にする
+func (r *Resolver) StationsNear(ctx context.Context, params *StationsNearParams) ([]NearestStationResult, error) {
+	stations, err := r.Stations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Stations")
+	}
+
+	nearby := make([]NearestStationResult, 0, len(stations))
+	for _, station := range stations {
+		distance := distanceKm(params.Lat, params.Lng, station.Lat, station.Lng)
+		if 0 < params.MaxDistanceKm && params.MaxDistanceKm < distance {
+			continue
+		}
+		nearby = append(nearby, NearestStationResult{Station: station, DistanceKm: distance})
+	}
+	// 観測所番号順の一覧を安定ソートするため、距離が同じ場合は観測所番号順になる
+	slices.SortStableFunc(nearby, func(a, b NearestStationResult) int {
+		return cmp.Compare(a.DistanceKm, b.DistanceKm)
+	})
+	return nearby, nil
+}
+
+// fetch 一覧を気象庁から取得する
+// クライアントが未指定または取得に失敗した場合は埋め込みデータを返す
+func (r *Resolver) fetch(ctx context.Context, params *fetchParams) []byte {
+	if r.setting.Client == nil {
+		return params.Fallback
+	}
+	body, err := params.Cache.Get(ctx, r.setting.Client, params.URL)
+	if err != nil {
+		log.Printf("Failed to fetch %s, using embedded data: %v", params.URL, err)
+		return params.Fallback
+	}
+	return body
+}
+
+// nameCandidates 名前で探す際の候補（そのまま・都府県を補ったもの）を返す
+func nameCandidates(name string) []string {
+	name = strings.TrimSpace(name)
+	candidates := []string{name}
+	for _, suffix := range prefectureSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return candidates
+		}
+	}
+	for _, suffix := range prefectureSuffixes {
+		candidates = append(candidates, name+suffix)
+	}
+	return candidates
+}
+
+// degrees [度, 分]の組を度に変換する
+func degrees(degreesMinutes [2]float64) float64 {
+	return degreesMinutes[0] + degreesMinutes[1]/60
+}
+
+// distanceKm 2点間の距離を球面三角法（ハーバサイン公式）で計算する
+func distanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371.0 // 地球半径（キロメートル）
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,273 @@
+package area_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/jma/area"
+)
+
+const (
+	areaResponse = `{
+		"centers": {"010300": {"name": "関東甲信地方", "enName": "Kanto Koshin", "officeName": "気象庁", "children": ["130000"]}},
+		"offices": {
+			"130000": {"name": "東京都", "enName": "Tokyo", "officeName": "気象庁", "parent": "010300", "children": ["130010"]},
+			"999999": {"name": "テスト地方", "enName": "Test", "officeName": "テスト気象台", "parent": "010300"}
+		},
+		"class10s": {"130010": {"name": "東京地方", "enName": "Tokyo Region", "parent": "130000"}}
+	}`
+	stationTableResponse = `{
+		"44132": {"type": "A", "kjName": "東京", "knName": "とうきょう", "enName": "Tokyo", "lat": [35, 41.5], "lon": [139, 45.0]},
+		"44136": {"type": "C", "kjName": "練馬", "knName": "ねりま", "enName": "Nerima", "lat": [35, 44.1], "lon": [139, 40.1]}
+	}`
+)
+
+// newResolvers オフライン・気象庁から取得・取得失敗時のResolverを作成する
+func newResolvers() map[string]*area.Resolver {
+	online := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "area.json", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: areaResponse}}},
+			{Pattern: "amedastable.json", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: stationTableResponse}}},
+		},
+	})
+	failing := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusServiceUnavailable},
+	})
+	return map[string]*area.Resolver{
+		"offline": area.NewResolver(nil),
+		"online":  area.NewResolver(&area.ResolverSetting{Client: online.Client()}),
+		"failing": area.NewResolver(&area.ResolverSetting{Client: failing.Client()}),
+	}
+}
+
+func TestResolverOffices(t *testing.T) {
+	tests := []struct {
+		name          string
+		resolver      string
+		expectedCount int
+		expectedFirst area.Office
+	}{
+		{
+			name:          "埋め込みデータ",
+			resolver:      "offline",
+			expectedCount: 58,
+			expectedFirst: area.Office{Code: "011000", Name: "宗谷地方", EnName: "Soya", OfficeName: "稚内地方気象台", Parent: "010100", Lat: 45.415, Lng: 141.679},
+		},
+		{
+			name:          "気象庁から取得",
+			resolver:      "online",
+			expectedCount: 2,
+			expectedFirst: area.Office{Code: "130000", Name: "東京都", EnName: "Tokyo", OfficeName: "気象庁", Parent: "010300", Lat: 35.690, Lng: 139.692},
+		},
+		{
+			name:          "取得できない場合は埋め込みデータ",
+			resolver:      "failing",
+			expectedCount: 58,
+			expectedFirst: area.Office{Code: "011000", Name: "宗谷地方", EnName: "Soya", OfficeName: "稚内地方気象台", Parent: "010100", Lat: 45.415, Lng: 141.679},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			offices, err := newResolvers()[tt.resolver].Offices(t.Context())
+			if err != nil {
+				t.Fatalf("Offices() error = %v", err)
+			}
+			if len(offices) != tt.expectedCount {
+				t.Fatalf("len(Offices()) = %d, want %d", len(offices), tt.expectedCount)
+			}
+			if diff := cmp.Diff(tt.expectedFirst, offices[0]); diff != "" {
+				t.Errorf("Offices()[0] mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolverOfficeByName(t *testing.T) {
+	tests := []struct {
+		name          string
+		resolver      string
+		query         string
+		expectedCode  string
+		expectedError error
+	}{
+		{name: "都道府県名", resolver: "offline", query: "石川県", expectedCode: "170000"},
+		{name: "都府県を省略", resolver: "offline", query: " 大阪 ", expectedCode: "270000"},
+		{name: "英語の名前", resolver: "offline", query: "tokyo", expectedCode: "130000"},
+		{name: "複数の府県予報区に分かれる道", resolver: "offline", query: "北海道", expectedCode: "016000"},
+		{name: "複数の府県予報区に分かれる県を省略", resolver: "offline", query: "鹿児島", expectedCode: "460100"},
+		{name: "府県予報区の名前", resolver: "offline", query: "八重山地方", expectedCode: "474000"},
+		{name: "気象庁から取得", resolver: "online", query: "テスト地方", expectedCode: "999999"},
+		{name: "存在しない名前", resolver: "offline", query: "ムー大陸", expectedError: area.ErrOfficeNotFound},
+		{name: "空", resolver: "offline", query: "", expectedError: area.ErrOfficeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			office, err := newResolvers()[tt.resolver].OfficeByName(t.Context(), tt.query)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("OfficeByName(%q) error = %v, want %v", tt.query, err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if office.Code != tt.expectedCode {
+				t.Errorf("OfficeByName(%q).Code = %s, want %s", tt.query, office.Code, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestResolverNearestOffice(t *testing.T) {
+	tests := []struct {
+		name         string
+		resolver     string
+		lat          float64
+		lng          float64
+		expectedCode string
+	}{
+		{name: "渋谷", resolver: "offline", lat: 35.658, lng: 139.702, expectedCode: "130000"},
+		{name: "函館", resolver: "offline", lat: 41.77, lng: 140.73, expectedCode: "017000"},
+		{name: "石垣島", resolver: "offline", lat: 24.34, lng: 124.16, expectedCode: "474000"},
+		{name: "代表地点のない府県予報区は除く", resolver: "online", lat: 0, lng: 0, expectedCode: "130000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := newResolvers()[tt.resolver].NearestOffice(t.Context(), tt.lat, tt.lng)
+			if err != nil {
+				t.Fatalf("NearestOffice() error = %v", err)
+			}
+			if result.Office.Code != tt.expectedCode {
+				t.Errorf("NearestOffice().Office.Code = %s, want %s", result.Office.Code, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestResolverStationByName(t *testing.T) {
+	tests := []struct {
+		name          string
+		resolver      string
+		query         string
+		expectedCode  string
+		expectedError error
+	}{
+		{name: "漢字", resolver: "offline", query: "那覇", expectedCode: "91197"},
+		{name: "かな", resolver: "offline", query: "さっぽろ", expectedCode: "14163"},
+		{name: "英語", resolver: "offline", query: "OSAKA", expectedCode: "62078"},
+		{name: "気象庁から取得", resolver: "online", query: "練馬", expectedCode: "44136"},
+		{name: "取得できない場合は埋め込みデータ", resolver: "failing", query: "東京", expectedCode: "44132"},
+		{name: "埋め込みデータにない観測所", resolver: "offline", query: "練馬", expectedError: area.ErrStationNotFound},
+		{name: "空", resolver: "online", query: " ", expectedError: area.ErrStationNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			station, err := newResolvers()[tt.resolver].StationByName(t.Context(), tt.query)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("StationByName(%q) error = %v, want %v", tt.query, err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if station.Code != tt.expectedCode {
+				t.Errorf("StationByName(%q).Code = %s, want %s", tt.query, station.Code, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestResolverNearestStation(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver string
+		lat      float64
+		lng      float64
+		expected area.Station
+	}{
+		{
+			name:     "気象庁から取得",
+			resolver: "online",
+			lat:      35.735,
+			lng:      139.668,
+			expected: area.Station{Code: "44136", Name: "練馬", KanaName: "ねりま", EnName: "Nerima", Lat: 35 + 44.1/60, Lng: 139 + 40.1/60},
+		},
+		{
+			name:     "埋め込みデータ",
+			resolver: "offline",
+			lat:      35.69,
+			lng:      139.75,
+			expected: area.Station{Code: "44132", Name: "東京", KanaName: "とうきょう", EnName: "Tokyo", Lat: 35 + 41.5/60, Lng: 139.75},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := newResolvers()[tt.resolver].NearestStation(t.Context(), tt.lat, tt.lng)
+			if err != nil {
+				t.Fatalf("NearestStation() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, result.Station); diff != "" {
+				t.Errorf("NearestStation().Station mismatch (-want +got):\n%s", diff)
+			}
+			// どちらも観測所から1km以内の位置を指定している
+			if 1 < result.DistanceKm {
+				t.Errorf("NearestStation().DistanceKm = %f, want less than 1", result.DistanceKm)
+			}
+		})
+	}
+}
+
+func TestResolverStationsNear(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDistanceKm float64
+		expected      []string
+	}{
+		{
+			name:     "範囲を制限しない場合は近い順にすべて",
+			expected: []string{"44136", "44132"},
+		},
+		{
+			name:          "範囲外の観測所は除く",
+			maxDistanceKm: 5,
+			expected:      []string{"44136"},
+		},
+		{
+			name:          "範囲内に観測所がない",
+			maxDistanceKm: 0.001,
+			expected:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := newResolvers()["online"].StationsNear(t.Context(), &area.StationsNearParams{
+				Lat:           35.735,
+				Lng:           139.668,
+				MaxDistanceKm: tt.maxDistanceKm,
+			})
+			if err != nil {
+				t.Fatalf("StationsNear() error = %v", err)
+			}
+			codes := []string{}
+			for _, nearby := range result {
+				codes = append(codes, nearby.Station.Code)
+			}
+			if diff := cmp.Diff(tt.expected, codes); diff != "" {
+				t.Errorf("StationsNear() codes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
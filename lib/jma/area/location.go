@@ -0,0 +1,78 @@
+package area
+
+// officeLocation 府県予報区の代表地点（予報を担当する気象台の所在地付近）
+type officeLocation struct {
+	Lat float64
+	Lng float64
+}
+
+// officeLocations 府県予報区のコードと代表地点の対応
+// area.jsonには位置の情報がないため、座標から最寄りの府県予報区を探す際に使う
+var officeLocations = map[string]officeLocation{
+	"011000": {Lat: 45.415, Lng: 141.679}, // 宗谷地方（稚内）
+	"012000": {Lat: 43.757, Lng: 142.372}, // 上川・留萌地方（旭川）
+	"013000": {Lat: 44.017, Lng: 144.280}, // 網走・北見・紋別地方（網走）
+	"014030": {Lat: 42.922, Lng: 143.212}, // 十勝地方（帯広）
+	"014100": {Lat: 42.985, Lng: 144.377}, // 釧路・根室地方（釧路）
+	"015000": {Lat: 42.312, Lng: 140.974}, // 胆振・日高地方（室蘭）
+	"016000": {Lat: 43.060, Lng: 141.329}, // 石狩・空知・後志地方（札幌）
+	"017000": {Lat: 41.817, Lng: 140.753}, // 渡島・檜山地方（函館）
+	"020000": {Lat: 40.824, Lng: 140.740}, // 青森県
+	"030000": {Lat: 39.704, Lng: 141.153}, // 岩手県
+	"040000": {Lat: 38.269, Lng: 140.872}, // 宮城県
+	"050000": {Lat: 39.719, Lng: 140.102}, // 秋田県
+	"060000": {Lat: 38.240, Lng: 140.364}, // 山形県
+	"070000": {Lat: 37.750, Lng: 140.468}, // 福島県
+	"080000": {Lat: 36.341, Lng: 140.447}, // 茨城県
+	"090000": {Lat: 36.566, Lng: 139.884}, // 栃木県
+	"100000": {Lat: 36.391, Lng: 139.061}, // 群馬県
+	"110000": {Lat: 35.857, Lng: 139.649}, // 埼玉県
+	"120000": {Lat: 35.605, Lng: 140.123}, // 千葉県
+	"130000": {Lat: 35.690, Lng: 139.692}, // 東京都
+	"140000": {Lat: 35.448, Lng: 139.643}, // 神奈川県
+	"150000": {Lat: 37.902, Lng: 139.023}, // 新潟県
+	"160000": {Lat: 36.695, Lng: 137.211}, // 富山県
+	"170000": {Lat: 36.595, Lng: 136.626}, // 石川県
+	"180000": {Lat: 36.065, Lng: 136.222}, // 福井県
+	"190000": {Lat: 35.664, Lng: 138.568}, // 山梨県
+	"200000": {Lat: 36.651, Lng: 138.181}, // 長野県
+	"210000": {Lat: 35.391, Lng: 136.722}, // 岐阜県
+	"220000": {Lat: 34.977, Lng: 138.383}, // 静岡県
+	"230000": {Lat: 35.180, Lng: 136.907}, // 愛知県
+	"240000": {Lat: 34.730, Lng: 136.509}, // 三重県
+	"250000": {Lat: 35.004, Lng: 135.868}, // 滋賀県
+	"260000": {Lat: 35.021, Lng: 135.756}, // 京都府
+	"270000": {Lat: 34.686, Lng: 135.520}, // 大阪府
+	"280000": {Lat: 34.691, Lng: 135.183}, // 兵庫県
+	"290000": {Lat: 34.685, Lng: 135.833}, // 奈良県
+	"300000": {Lat: 34.226, Lng: 135.168}, // 和歌山県
+	"310000": {Lat: 35.504, Lng: 134.238}, // 鳥取県
+	"320000": {Lat: 35.472, Lng: 133.051}, // 島根県
+	"330000": {Lat: 34.662, Lng: 133.935}, // 岡山県
+	"340000": {Lat: 34.397, Lng: 132.460}, // 広島県
+	"350000": {Lat: 34.186, Lng: 131.471}, // 山口県
+	"360000": {Lat: 34.066, Lng: 134.559}, // 徳島県
+	"370000": {Lat: 34.340, Lng: 134.043}, // 香川県
+	"380000": {Lat: 33.842, Lng: 132.766}, // 愛媛県
+	"390000": {Lat: 33.560, Lng: 133.531}, // 高知県
+	"400000": {Lat: 33.607, Lng: 130.418}, // 福岡県
+	"410000": {Lat: 33.249, Lng: 130.299}, // 佐賀県
+	"420000": {Lat: 32.745, Lng: 129.874}, // 長崎県
+	"430000": {Lat: 32.790, Lng: 130.742}, // 熊本県
+	"440000": {Lat: 33.238, Lng: 131.613}, // 大分県
+	"450000": {Lat: 31.911, Lng: 131.424}, // 宮崎県
+	"460040": {Lat: 28.378, Lng: 129.495}, // 奄美地方（名瀬）
+	"460100": {Lat: 31.560, Lng: 130.558}, // 鹿児島県（奄美地方除く）
+	"471000": {Lat: 26.212, Lng: 127.681}, // 沖縄本島地方（那覇）
+	"472000": {Lat: 25.829, Lng: 131.229}, // 大東島地方（南大東）
+	"473000": {Lat: 24.790, Lng: 125.278}, // 宮古島地方（宮古島）
+	"474000": {Lat: 24.337, Lng: 124.164}, // 八重山地方（石垣島）
+}
+
+// officeAliases 府県予報区の名前と一致しない都道府県名と府県予報区のコードの対応
+// 複数の府県予報区に分かれる都道府県は、都道府県庁所在地を含む府県予報区にする
+var officeAliases = map[string]string{
+	"北海道":  "016000",
+	"鹿児島県": "460100",
+	"沖縄県":  "471000",
+}
@@ -0,0 +1,368 @@
+package jobqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+)
+
+// ErrUnknownJob キューにないジョブを指定したことを表すエラー
+var ErrUnknownJob = errors.New("unknown job")
+
+// Status ジョブの状態
+// pending → processing → done の順に進み、失敗した場合は試行回数が上限に達するまでpendingに戻す
+type Status string
+
+const (
+	StatusPending    Status = "pending"    // 処理を待っている（再起動後に処理する）
+	StatusProcessing Status = "processing" // 処理中（再起動時に残っている場合は処理の途中で停止したためpendingに戻す）
+	StatusDone       Status = "done"       // 処理が完了した
+	StatusFailed     Status = "failed"     // 試行回数の上限まで失敗した
+)
+
+const (
+	// DefaultMaxAttempts StoreSetting.MaxAttemptsを指定しない場合の試行回数の上限
+	DefaultMaxAttempts = 3
+	// DefaultRetention StoreSetting.Retentionを指定しない場合の完了したジョブを保持する期間
+	DefaultRetention = 24 * time.Hour
+)
+
+// compactMinLines 実行中にファイルを書き直す最小の行数
+// ファイルの行数がジョブの数の2倍を超えた場合に書き直す
+const compactMinLines = 64
+
+// Job キューに入れたコマンドの処理
+type Job struct {
+	ID        string          `json:"id"`                // 冪等性の判定に使うID（ノートIDなど、同じIDのジョブは1回だけ入れる）
+	Kind      string          `json:"kind"`              // 再起動後にPayloadを復元するためのジョブの種類
+	Payload   json.RawMessage `json:"payload,omitempty"` // 処理に必要なデータ（完了・失敗したジョブでは削除する）
+	Status    Status          `json:"status"`            // 状態
+	Attempts  int             `json:"attempts"`          // 処理を始めた回数
+	Error     string          `json:"error,omitempty"`   // 最後に失敗した理由
+	UpdatedAt time.Time       `json:"updated_at"`        // 状態を変更した時刻
+}
+
+// finished 完了または失敗して再び処理しないジョブか
+func (j *Job) finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed
+}
+
+// StoreSetting Storeの設定
+type StoreSetting struct {
+	Path        string        // 状態の変化を追記するJSON Linesファイルのパス（空の場合はメモリのみに保存する）
+	MaxAttempts int           // 試行回数の上限（0以下の場合はDefaultMaxAttempts）
+	Retention   time.Duration // 完了・失敗したジョブのIDを重複の判定のために保持する期間（0以下の場合はDefaultRetention）
+	Clock       clock.Clock   // 状態を変更した時刻と保持期間の判定に使う時計（nilの場合はclock.Real）
+}
+
+// Store 処理の途中で再起動してもコマンドを失わないよう、受け付けたコマンドを処理の前に保存するキュー
+// 状態の変化をファイルに追記し、起動時に最後の状態を読み込む
+// 複数のgoroutineから同時に使える
+type Store struct {
+	setting   StoreSetting
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	order     []string // ジョブを入れた順のID
+	file      *os.File
+	fileLines int // 追記用のファイルの行数
+}
+
+// Open 新しいStoreを作成する
+// ファイルがある場合はジョブの最後の状態を読み込み、処理中のまま停止したジョブはpendingに戻し、
+// 保持期間を過ぎた完了・失敗したジョブを除いて書き直す
+func Open(setting *StoreSetting) (*Store, error) {
+	s := &Store{jobs: make(map[string]*Job)}
+	if setting != nil {
+		s.setting = *setting
+	}
+	if s.setting.MaxAttempts <= 0 {
+		s.setting.MaxAttempts = DefaultMaxAttempts
+	}
+	if s.setting.Retention <= 0 {
+		s.setting.Retention = DefaultRetention
+	}
+	s.setting.Clock = clock.Or(s.setting.Clock)
+	if s.setting.Path == "" {
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, errors.Wrap(err, "Failed to load")
+	}
+	for _, id := range s.order {
+		if job := s.jobs[id]; job.Status == StatusProcessing {
+			log.Printf("Job %s was interrupted, retrying", id) //nolint:gosec //G706
+			job.Status = StatusPending
+		}
+	}
+	s.prune()
+	if err := s.compact(); err != nil {
+		return nil, errors.Wrap(err, "Failed to compact")
+	}
+	return s, nil
+}
+
+// load ファイルからジョブの最後の状態を読み込む
+// 書き込みの途中で停止した場合などの解析できない行はログに出力して読み飛ばす
+func (s *Store) load() (err error) {
+	file, err := os.Open(s.setting.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Open")
+	}
+	defer func(file io.Closer) {
+		if closeErr := file.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var job Job
+		if err := json.Unmarshal(scanner.Bytes(), &job); err != nil || job.ID == "" {
+			log.Printf("Skipped invalid job at %s:%d: %v", s.setting.Path, line, err)
+			continue
+		}
+		if _, ok := s.jobs[job.ID]; !ok {
+			s.order = append(s.order, job.ID)
+		}
+		s.jobs[job.ID] = &job
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "Failed to Scan")
+	}
+	return nil
+}
+
+// compact 現在のジョブの状態でファイルを書き直し、追記用に開く（起動時以外はロック取得済みで呼び出す）
+func (s *Store) compact() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return errors.Wrap(err, "Failed to Close")
+		}
+		s.file = nil
+	}
+
+	tmpPath := s.setting.Path + ".tmp"
+	tmp, err := os.Create(tmpPath) //nolint:gosec // 運用者が指定したパスに書き込む
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.Create")
+	}
+	encoder := json.NewEncoder(tmp)
+	for _, id := range s.order {
+		if err := encoder.Encode(s.jobs[id]); err != nil {
+			return errors.Join(errors.Wrap(err, "Failed to Encode"), tmp.Close())
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "Failed to Close")
+	}
+	if err := os.Rename(tmpPath, s.setting.Path); err != nil {
+		return errors.Wrap(err, "Failed to os.Rename")
+	}
+
+	s.file, err = os.OpenFile(s.setting.Path, os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // 運用者が指定したパスに書き込む
+	if err != nil {
+		return errors.Wrap(err, "Failed to os.OpenFile")
+	}
+	s.fileLines = len(s.order)
+	return nil
+}
+
+// prune 保持期間を過ぎた完了・失敗したジョブを取り除く（ロック取得済みで呼び出す）
+func (s *Store) prune() {
+	cutoff := s.setting.Clock.Now().Add(-s.setting.Retention)
+	s.order = slices.DeleteFunc(s.order, func(id string) bool {
+		job := s.jobs[id]
+		if job.finished() && job.UpdatedAt.Before(cutoff) {
+			delete(s.jobs, id)
+			return true
+		}
+		return false
+	})
+}
+
+// save ジョブの状態をファイルに追記する（ロック取得済みで呼び出す）
+// 追記した行がジョブの数の2倍を超えた場合はファイルを書き直す
+func (s *Store) save(job *Job) error {
+	job.UpdatedAt = s.setting.Clock.Now()
+	if s.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "Failed to json.Marshal")
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "Failed to Write")
+	}
+	s.fileLines++
+
+	if compactMinLines <= s.fileLines && 2*len(s.order) < s.fileLines {
+		s.prune()
+		if err := s.compact(); err != nil {
+			return errors.Wrap(err, "Failed to compact")
+		}
+	}
+	return nil
+}
+
+// Enqueue ジョブをpendingでキューに入れる
+// 同じIDのジョブがある場合（ストリーミングの再接続で同じノートを受信した場合など）は入れずにfalseを返す
+// レシーバーがnilの場合は保存せずにtrueを返す
+func (s *Store) Enqueue(id, kind string, payload any) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to json.Marshal")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; ok {
+		return false, nil
+	}
+	job := &Job{ID: id, Kind: kind, Payload: data, Status: StatusPending}
+	s.jobs[id] = job
+	s.order = append(s.order, id)
+	if err := s.save(job); err != nil {
+		return true, errors.Wrap(err, "Failed to save")
+	}
+	return true, nil
+}
+
+// update ジョブの状態を変更して保存する
+func (s *Store) update(id string, fn func(job *Job)) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return errors.Wrapf(ErrUnknownJob, "id: %s", id)
+	}
+	fn(job)
+	if err := s.save(job); err != nil {
+		return errors.Wrap(err, "Failed to save")
+	}
+	return nil
+}
+
+// Start ジョブを処理中にして試行回数を数える
+// レシーバーがnilの場合は何もしない
+func (s *Store) Start(id string) error {
+	return s.update(id, func(job *Job) {
+		job.Status = StatusProcessing
+		job.Attempts++
+	})
+}
+
+// Complete ジョブを完了にする
+// レシーバーがnilの場合は何もしない
+func (s *Store) Complete(id string) error {
+	return s.update(id, func(job *Job) {
+		job.Status = StatusDone
+		job.Payload = nil
+		job.Error = ""
+	})
+}
+
+// Fail ジョブの失敗を記録し、試行回数が上限未満であれば次の起動時に再び処理するようpendingに戻す
+// レシーバーがnilの場合は何もしない
+func (s *Store) Fail(id string, cause error) error {
+	return s.update(id, func(job *Job) {
+		job.Status = StatusPending
+		if cause != nil {
+			job.Error = cause.Error()
+		}
+		if s.setting.MaxAttempts <= job.Attempts {
+			job.Status = StatusFailed
+			job.Payload = nil
+		}
+	})
+}
+
+// Discard ジョブを処理せずにfailedにする（混雑のため処理しなかったジョブなど、次の起動時にも処理しない）
+// レシーバーがnilの場合は何もしない
+func (s *Store) Discard(id string, cause error) error {
+	return s.update(id, func(job *Job) {
+		job.Status = StatusFailed
+		job.Payload = nil
+		if cause != nil {
+			job.Error = cause.Error()
+		}
+	})
+}
+
+// Pending 処理を待っているジョブを入れた順に返す（起動時に前回の実行で処理しなかったジョブを処理する）
+// レシーバーがnilの場合は空を返す
+func (s *Store) Pending() []Job {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []Job
+	for _, id := range s.order {
+		if job := s.jobs[id]; job.Status == StatusPending {
+			pending = append(pending, *job)
+		}
+	}
+	return pending
+}
+
+// Get ジョブの現在の状態を返す（キューにない場合はfalse）
+func (s *Store) Get(id string) (Job, bool) {
+	if s == nil {
+		return Job{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Close 追記用のファイルを閉じる
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.Close(); err != nil {
+		return errors.Wrap(err, "Failed to Close")
+	}
+	s.file = nil
+	return nil
+}
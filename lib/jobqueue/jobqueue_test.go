@@ -0,0 +1,220 @@
+package jobqueue_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/jobqueue"
+)
+
+// testNow テストで使う現在時刻
+var testNow = time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+// ignoreTime 状態を変更した時刻を比較しない
+var ignoreTime = cmpopts.IgnoreFields(jobqueue.Job{}, "UpdatedAt")
+
+// openStore テスト用のStoreを開き、テストの終了時に閉じる
+func openStore(t *testing.T, setting *jobqueue.StoreSetting) *jobqueue.Store {
+	t.Helper()
+	store, err := jobqueue.Open(setting)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := store.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return store
+}
+
+func TestStoreEnqueue(t *testing.T) {
+	t.Parallel()
+	store := openStore(t, nil)
+
+	for _, tt := range []struct {
+		id       string
+		expected bool
+	}{
+		{id: "n1", expected: true},
+		{id: "n2", expected: true},
+		{id: "n1", expected: false}, // 再接続で同じノートを受信した場合
+	} {
+		added, err := store.Enqueue(tt.id, "note", map[string]string{"id": tt.id})
+		if err != nil {
+			t.Fatalf("Enqueue(%s) error = %v", tt.id, err)
+		}
+		if added != tt.expected {
+			t.Errorf("Enqueue(%s) = %v, want %v", tt.id, added, tt.expected)
+		}
+	}
+
+	// 処理を始めたジョブや完了したジョブも重複として扱う
+	if err := store.Start("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := store.Enqueue("n1", "note", nil); err != nil || added {
+		t.Errorf("Enqueue(n1) after Complete = %v, %v, want false, nil", added, err)
+	}
+
+	expected := []jobqueue.Job{{ID: "n2", Kind: "note", Payload: []byte(`{"id":"n2"}`), Status: jobqueue.StatusPending}}
+	if diff := cmp.Diff(expected, store.Pending(), ignoreTime); diff != "" {
+		t.Errorf("Pending() mismatch (-want +got):\n%s", diff)
+	}
+	if err := store.Start("missing"); !errors.Is(err, jobqueue.ErrUnknownJob) {
+		t.Errorf("Start(missing) error = %v, want %v", err, jobqueue.ErrUnknownJob)
+	}
+}
+
+func TestStoreFail(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failures    int
+		expected    jobqueue.Job
+	}{
+		{
+			name:        "上限未満の失敗はpendingに戻す",
+			maxAttempts: 3,
+			failures:    2,
+			expected:    jobqueue.Job{ID: "n1", Kind: "note", Payload: []byte(`"payload"`), Status: jobqueue.StatusPending, Attempts: 2, Error: "boom"},
+		},
+		{
+			name:     "上限まで失敗した場合はfailed",
+			failures: jobqueue.DefaultMaxAttempts,
+			expected: jobqueue.Job{ID: "n1", Kind: "note", Status: jobqueue.StatusFailed, Attempts: 3, Error: "boom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := openStore(t, &jobqueue.StoreSetting{MaxAttempts: tt.maxAttempts})
+			if _, err := store.Enqueue("n1", "note", "payload"); err != nil {
+				t.Fatal(err)
+			}
+			for range tt.failures {
+				if err := store.Start("n1"); err != nil {
+					t.Fatal(err)
+				}
+				if err := store.Fail("n1", errors.New("boom")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			job, ok := store.Get("n1")
+			if !ok {
+				t.Fatal("Get(n1) = false, want true")
+			}
+			if diff := cmp.Diff(tt.expected, job, ignoreTime); diff != "" {
+				t.Errorf("Get() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStoreDiscard(t *testing.T) {
+	t.Parallel()
+	store := openStore(t, nil)
+	if _, err := store.Enqueue("n1", "note", "payload"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Discard("n1", errors.New("overloaded")); err != nil {
+		t.Fatal(err)
+	}
+
+	job, _ := store.Get("n1")
+	expected := jobqueue.Job{ID: "n1", Kind: "note", Status: jobqueue.StatusFailed, Error: "overloaded"}
+	if diff := cmp.Diff(expected, job, ignoreTime); diff != "" {
+		t.Errorf("Get() mismatch (-want +got):\n%s", diff)
+	}
+	if pending := store.Pending(); pending != nil {
+		t.Errorf("Pending() = %v, want nil", pending)
+	}
+}
+
+// TestStoreReopen 再起動後に処理していないジョブを復元し、古いジョブを削除することをテストする
+func TestStoreReopen(t *testing.T) {
+	t.Parallel()
+	fake := clocktest.NewFake(testNow)
+	setting := &jobqueue.StoreSetting{Path: filepath.Join(t.TempDir(), "jobs.jsonl"), Clock: fake}
+
+	first, err := jobqueue.Open(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"old", "done", "interrupted", "pending"} {
+		if _, err := first.Enqueue(id, "note", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"old", "done", "interrupted"} {
+		if err := first.Start(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := first.Complete("old"); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(jobqueue.DefaultRetention)
+	if err := first.Complete("done"); err != nil {
+		t.Fatal(err)
+	}
+	// 処理の途中で停止した場合を再現するため、interruptedは処理中のまま閉じる
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 書き込みの途中で停止した行は読み飛ばす
+	file, err := os.OpenFile(setting.Path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"id":"broken","kin`); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Advance(time.Minute)
+	second := openStore(t, setting)
+	expected := []jobqueue.Job{
+		{ID: "interrupted", Kind: "note", Payload: []byte(`"interrupted"`), Status: jobqueue.StatusPending, Attempts: 1},
+		{ID: "pending", Kind: "note", Payload: []byte(`"pending"`), Status: jobqueue.StatusPending},
+	}
+	if diff := cmp.Diff(expected, second.Pending(), ignoreTime); diff != "" {
+		t.Errorf("Pending() mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := second.Get("old"); ok {
+		t.Error("Get(old) = true, want pruned")
+	}
+	if added, err := second.Enqueue("done", "note", nil); err != nil || added {
+		t.Errorf("Enqueue(done) = %v, %v, want false, nil", added, err)
+	}
+}
+
+// TestStoreNil 設定しない場合のnilのStoreが何もしないことをテストする
+func TestStoreNil(t *testing.T) {
+	t.Parallel()
+	var store *jobqueue.Store
+	if added, err := store.Enqueue("n1", "note", nil); err != nil || !added {
+		t.Errorf("Enqueue() = %v, %v, want true, nil", added, err)
+	}
+	if err := errors.Join(store.Start("n1"), store.Fail("n1", nil), store.Complete("n1"), store.Close()); err != nil {
+		t.Errorf("error = %v, want nil", err)
+	}
+	if pending := store.Pending(); pending != nil {
+		t.Errorf("Pending() = %v, want nil", pending)
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter 単調増加するカウンター
+type Counter struct {
+	value atomic.Int64
+}
+
+// Inc カウンターを1増やす
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add カウンターをn増やす
+func (c *Counter) Add(n int64) {
+	c.value.Add(n)
+}
+
+// Value 現在の値を返す
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+// Registry 名前付きカウンターとゲージの集合
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+	gauges   map[string]func() int64
+}
+
+// Default プロセス全体で共有するレジストリ
+var Default = NewRegistry()
+
+// NewRegistry 新しいレジストリを作成する
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*Counter), gauges: make(map[string]func() int64)}
+}
+
+// Counter 指定した名前のカウンターを返す（存在しなければ作成する）
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counter, ok := r.counters[name]
+	if !ok {
+		counter = &Counter{}
+		r.counters[name] = counter
+	}
+	return counter
+}
+
+// GaugeFunc 指定した名前のゲージを登録する（同じ名前のゲージは置き換える）
+// ゲージは増減する値で、Snapshotのたびにfnを呼んで現在値を取得する
+func (r *Registry) GaugeFunc(name string, fn func() int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gauges[name] = fn
+}
+
+// Snapshot すべてのカウンターとゲージの現在値を返す
+func (r *Registry) Snapshot() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(r.counters)+len(r.gauges))
+	for name, counter := range r.counters {
+		snapshot[name] = counter.Value()
+	}
+	for name, fn := range r.gauges {
+		snapshot[name] = fn()
+	}
+	return snapshot
+}
+
+// Handler カウンターの現在値をJSONで返すHTTPハンドラーを作成する
+func Handler(r *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(r.Snapshot()); err != nil {
+			log.Printf("Failed to Encode: %v", err)
+		}
+	}
+}
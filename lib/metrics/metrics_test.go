@@ -0,0 +1,67 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/metrics"
+)
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		apply    func(r *metrics.Registry)
+		expected map[string]int64
+	}{
+		{
+			name:     "カウンターなし",
+			apply:    func(_ *metrics.Registry) {},
+			expected: map[string]int64{},
+		},
+		{
+			name: "同じ名前のカウンターは共有される",
+			apply: func(r *metrics.Registry) {
+				r.Counter("a").Inc()
+				r.Counter("a").Add(2)
+				r.Counter("b").Inc()
+			},
+			expected: map[string]int64{"a": 3, "b": 1},
+		},
+		{
+			name: "ゲージは取得のたびに値を求める",
+			apply: func(r *metrics.Registry) {
+				r.Counter("a").Inc()
+				r.GaugeFunc("g", func() int64 { return 1 })
+				r.GaugeFunc("g", func() int64 { return 5 })
+			},
+			expected: map[string]int64{"a": 1, "g": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			registry := metrics.NewRegistry()
+			tt.apply(registry)
+
+			if diff := cmp.Diff(registry.Snapshot(), tt.expected); diff != "" {
+				t.Errorf("Snapshot() diff: %s", diff)
+			}
+
+			recorder := httptest.NewRecorder()
+			metrics.Handler(registry)(recorder, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/metrics", nil))
+
+			var body map[string]int64
+			if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(body, tt.expected); diff != "" {
+				t.Errorf("Handler() body diff: %s", diff)
+			}
+		})
+	}
+}
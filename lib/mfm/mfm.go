@@ -0,0 +1,87 @@
+package mfm
+
+import (
+	"strings"
+)
+
+// Builder MisskeyのMFM（Markup language For Misskey）で装飾した文章を組み立てる
+// MFMに対応していないプラットフォーム向けに作成した場合は装飾を付けずに中身の文字列だけを書き出す
+// 引数の文字列はエスケープせずにそのまま書き出す
+type Builder struct {
+	markup  bool
+	builder strings.Builder
+}
+
+// NewBuilder 新しいBuilderを作成する
+// markupがfalseの場合はMFMの装飾を付けずに書き出す
+func NewBuilder(markup bool) *Builder {
+	return &Builder{markup: markup}
+}
+
+// Text 装飾しない文字列を書き出す
+func (b *Builder) Text(text string) *Builder {
+	b.builder.WriteString(text)
+	return b
+}
+
+// Line 改行を書き出す
+func (b *Builder) Line() *Builder {
+	b.builder.WriteByte('\n')
+	return b
+}
+
+// Bold 太字（**text**）を書き出す
+func (b *Builder) Bold(text string) *Builder {
+	return b.wrap("**", text, "**")
+}
+
+// Small 小さい文字（<small>text</small>）を書き出す
+func (b *Builder) Small(text string) *Builder {
+	return b.wrap("<small>", text, "</small>")
+}
+
+// X2 2倍の大きさの文字（$[x2 text]）を書き出す
+func (b *Builder) X2(text string) *Builder {
+	return b.wrap("$[x2 ", text, "]")
+}
+
+// Code インラインコード（`text`）を書き出す
+func (b *Builder) Code(text string) *Builder {
+	return b.wrap("`", text, "`")
+}
+
+// Link 表示する文字列を指定したリンク（[label](url)）を書き出す
+// MFMに対応していない場合は「label url」の形で書き出し、labelが空の場合はURLのみを書き出す
+func (b *Builder) Link(label, url string) *Builder {
+	switch {
+	case label == "":
+		return b.Text(url)
+	case b.markup:
+		return b.Text("[" + label + "](" + url + ")")
+	default:
+		return b.Text(label + " " + url)
+	}
+}
+
+// CodeBlock 言語を指定したコードブロックを書き出す
+// MFMに対応していない場合はコードをそのまま書き出す
+func (b *Builder) CodeBlock(lang, code string) *Builder {
+	return b.wrap("```"+lang+"\n", strings.TrimSuffix(code, "\n"), "\n```")
+}
+
+// String 組み立てた文章を返す
+func (b *Builder) String() string {
+	return b.builder.String()
+}
+
+// wrap MFMに対応している場合のみtextの前後にprefixとsuffixを付けて書き出す
+func (b *Builder) wrap(prefix, text, suffix string) *Builder {
+	if b.markup {
+		b.builder.WriteString(prefix)
+	}
+	b.builder.WriteString(text)
+	if b.markup {
+		b.builder.WriteString(suffix)
+	}
+	return b
+}
@@ -0,0 +1,51 @@
+package mfm_test
+
+import (
+	"testing"
+
+	"hato-bot-go/lib/mfm"
+)
+
+// build テストで使う装飾を全て含む文章を組み立てる
+func build(markup bool) string {
+	return mfm.NewBuilder(markup).
+		X2("☔").Text(" ").Bold("東京").Text("の雨雲").Line().
+		Small("気象庁").Text(" ").Code("amesh").Line().
+		Link("記事", "https://ja.wikipedia.org/wiki/雨").Text(" ").Link("", "https://example.com").Line().
+		CodeBlock("", "✅ geocode\n✅ radar\n").
+		String()
+}
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		markup   bool
+		expected string
+	}{
+		{
+			name:   "MFMで装飾",
+			markup: true,
+			expected: "$[x2 ☔] **東京**の雨雲\n" +
+				"<small>気象庁</small> `amesh`\n" +
+				"[記事](https://ja.wikipedia.org/wiki/雨) https://example.com\n" +
+				"```\n✅ geocode\n✅ radar\n```",
+		},
+		{
+			name:   "MFMに対応していないプラットフォームでは装飾しない",
+			markup: false,
+			expected: "☔ 東京の雨雲\n" +
+				"気象庁 amesh\n" +
+				"記事 https://ja.wikipedia.org/wiki/雨 https://example.com\n" +
+				"✅ geocode\n✅ radar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := build(tt.markup); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package misskey
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrInvalidAccount アカウント情報のレスポンスが不正であることを表すエラー
+var ErrInvalidAccount = errors.New("invalid account")
+
+// Account APIトークンのアカウント情報
+type Account struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name,omitempty"`
+	IsBot    bool   `json:"isBot"`
+}
+
+// FetchAccount APIトークンのアカウント情報を取得する（/api/i）
+// トークンが無効な場合はhttpclient.ErrHTTPRequestErrorを返す
+func (bot *Bot) FetchAccount(ctx context.Context) (*Account, error) {
+	body, err := bot.apiRequest(ctx, "i", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
+
+	var account Account
+	if err := json.Unmarshal(body, &account); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	if account.ID == "" {
+		return nil, errors.Wrapf(ErrInvalidAccount, "account id is empty: %s", body)
+	}
+	return &account, nil
+}
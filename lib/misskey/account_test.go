@@ -0,0 +1,72 @@
+package misskey_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+func TestFetchAccount(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		body          string
+		expected      *misskey.Account
+		expectedError error
+	}{
+		{
+			name:       "アカウント情報",
+			statusCode: http.StatusOK,
+			body:       `{"id":"9abc","username":"hato","name":"鳩","isBot":true}`,
+			expected:   &misskey.Account{ID: "9abc", Username: "hato", Name: "鳩", IsBot: true},
+		},
+		{
+			name:          "無効なトークン",
+			statusCode:    http.StatusUnauthorized,
+			body:          `{"error":{"code":"AUTHENTICATION_FAILED"}}`,
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+		{
+			name:          "IDのないレスポンス",
+			statusCode:    http.StatusOK,
+			body:          `{}`,
+			expectedError: misskey.ErrInvalidAccount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: tt.statusCode, Body: tt.body},
+			})
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{Domain: "example.com", Token: "token", Client: transport.Client()})
+
+			account, err := bot.FetchAccount(t.Context())
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("FetchAccount() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, account); diff != "" {
+				t.Errorf("FetchAccount() mismatch (-want +got):\n%s", diff)
+			}
+
+			requests := transport.RequestsTo("/api/i")
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+			var body map[string]any
+			if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(map[string]any{"i": "token"}, body); diff != "" {
+				t.Errorf("request body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -6,213 +6,284 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"maps"
 	"mime/multipart"
 	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/gorilla/websocket"
 
 	"hato-bot-go/lib"
-	"hato-bot-go/lib/amesh"
 	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
 )
 
+// maxAPIResponseBytes Misskey APIのレスポンスボディの上限
+// 通知の一覧でも数百KB程度のため、それを大きく超えるレスポンスは読み込まない
+const maxAPIResponseBytes int64 = 4 << 20
+
 // Bot Misskeyボットクライアント
+// ノートの作成やリアクションなどのAPI呼び出しは複数のgoroutineから同時に行える
+// WebSocketからの読み込みはListenEventsを呼び出した1つのgoroutineのみが行い、書き込みは接続ごとの送信goroutineのみが行う
 type Bot struct {
 	BotSetting *BotSetting
-	UserAgent  string
-	WSConn     *websocket.Conn
+	UserAgent  string            // WebSocket接続のUser-Agent（空の場合はhttpclient.UserAgent）
+	Dialer     *websocket.Dialer // WebSocket接続に使うDialer（nilの場合はwebsocket.DefaultDialer）
+
+	wsMu                sync.RWMutex       // wsConnとwsSendsの差し替えを保護する
+	wsConn              *websocket.Conn    // WebSocket接続（接続していない場合はnil）
+	wsSends             chan wsSendRequest // 送信goroutineへの送信の要求（接続していない場合はnil）
+	sinceNotificationID string             // ポーリングで取得済みの最新の通知のID
+
+	throttleMu  sync.Mutex // nextRequestを保護する
+	nextRequest time.Time  // 次のAPIリクエストを送信できる時刻（RequestIntervalを指定した場合のみ使う）
+
+	capabilities      atomic.Pointer[Capabilities] // DetectCapabilitiesで判定したインスタンスの機能（未判定の場合はnil）
+	maxNoteTextLength atomic.Int64                 // DetectCapabilitiesで取得したノートの本文の文字数の上限（未取得の場合は0）
 }
 
-// CreateNote ノートを作成
-func (bot *Bot) CreateNote(ctx context.Context, params *CreateNoteParams) (err error) {
+// CreateNote 返信元のノートに返信するノートを作成
+func (bot *Bot) CreateNote(ctx context.Context, params *CreateNoteParams) error {
+	_, err := bot.createReplyNote(ctx, params)
+	return err
+}
+
+// createReplyNote 返信元のノートに返信するノートを作成し、作成したノートを返す
+func (bot *Bot) createReplyNote(ctx context.Context, params *CreateNoteParams) (*Note, error) {
 	if params == nil || params.OriginalNote == nil {
-		return lib.ErrParamsNil
+		return nil, lib.ErrParamsNil
 	}
 
-	// noteから必要な情報を取得
-	visibility := params.OriginalNote.Visibility
-	replyID := params.OriginalNote.ID
-
-	// 公開範囲がpublicならばhomeにする
-	if visibility == "public" {
-		visibility = "home"
+	policy := bot.BotSetting.ReplyPolicy
+	if params.Policy != nil {
+		policy = *params.Policy
 	}
 
+	visibility, localOnly := replyVisibility(params.OriginalNote, policy)
 	data := map[string]any{
 		"text":       params.Text,
 		"visibility": visibility,
 	}
 
-	if replyID != "" {
-		data["replyId"] = replyID
+	// 引用の場合はrenoteId、それ以外はreplyIdで元ノートに紐付ける
+	// フォロワー限定・指名ノートはMisskeyが引用を受け付けないためリプライにする
+	// スレッドの続きは直前の返信へのリプライにする
+	if params.ThreadReplyID != "" {
+		data["replyId"] = params.ThreadReplyID
+	} else if params.OriginalNote.ID != "" {
+		if policy.Mode == ReplyModeQuote && isQuotable(params.OriginalNote.Visibility) {
+			data["renoteId"] = params.OriginalNote.ID
+		} else {
+			data["replyId"] = params.OriginalNote.ID
+		}
+	}
+
+	// 指名ノートは元ノートの投稿者に宛てる
+	if visibility == "specified" && params.OriginalNote.User.ID != "" {
+		data["visibleUserIds"] = []string{params.OriginalNote.User.ID}
+	}
+
+	// 元ノートが連合なしの場合や方針・公開範囲の規則で指定された場合はローカルのみに投稿する
+	// localOnlyに対応していないインスタンスでは指定しない
+	if (policy.LocalOnly || localOnly || params.OriginalNote.LocalOnly) && bot.Capabilities().LocalOnly {
+		data["localOnly"] = true
 	}
 
 	if 0 < len(params.FileIDs) {
 		data["fileIds"] = params.FileIDs
 	}
 
-	// 元の投稿がCWされていた場合、それに合わせてCW投稿する
-	if params.OriginalNote.CW != nil {
-		data["cw"] = "隠すっぽ！"
+	if cw, ok := bot.replyCW(params, policy); ok {
+		data["cw"] = cw
 	}
 
-	// jscpd:ignore-start
-	resp, err := bot.apiRequest(ctx, "notes/create", data)
+	note, err := bot.createNote(ctx, data)
 	if err != nil {
-		return errors.Wrap(err, "Failed to apiRequest")
+		return nil, errors.Wrap(err, "Failed to createNote")
 	}
-	defer func(body io.ReadCloser) {
-		if closeErr := body.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-	// jscpd:ignore-end
+	return note, nil
+}
 
-	var result struct {
-		CreatedNote Note `json:"createdNote"`
+// replyCW 返信ノートに付けるCWの文言を返す
+// 元の投稿がCWされていた場合は方針に応じた文言でCW投稿し、天気画像のCWでは画像を添付する返信を常にCW投稿する
+func (bot *Bot) replyCW(params *CreateNoteParams, policy ReplyPolicy) (string, bool) {
+	original := params.OriginalNote
+	templateData := bot.TemplateDataFor(original.User.Username, original.User.Host)
+	if policy.CW == CWModeWeather && 0 < len(params.FileIDs) {
+		return bot.BotSetting.Templates.Render(i18n.KeyReplyCWWeather, templateData), true
+	}
+	if original.CW == nil {
+		return "", false
 	}
+	if policy.CW == CWModeMirror && *original.CW != "" {
+		return *original.CW, true
+	}
+	if policy.CWText != "" {
+		return policy.CWText, true
+	}
+	return bot.BotSetting.Templates.Render(i18n.KeyReplyCW, templateData), true
+}
 
-	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return errors.Wrap(err, "Failed to json.NewDecoder")
+// PostNote 返信ではない新しいノートを作成
+// 地震情報などボットから発信するノートに使う
+func (bot *Bot) PostNote(ctx context.Context, params *PostNoteParams) error {
+	if params == nil {
+		return lib.ErrParamsNil
 	}
 
-	return nil
+	visibility := params.Visibility
+	if visibility == "" {
+		visibility = "home"
+	}
+	data := map[string]any{
+		"text":       params.Text,
+		"visibility": visibility,
+	}
+	if params.LocalOnly && bot.Capabilities().LocalOnly {
+		data["localOnly"] = true
+	}
+	if 0 < len(params.FileIDs) {
+		data["fileIds"] = params.FileIDs
+	}
+
+	_, err := bot.createNote(ctx, data)
+	return err
 }
 
-// UploadFile ファイルをアップロード
-func (bot *Bot) UploadFile(ctx context.Context, reader io.Reader, fileName string) (file *File, err error) {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-	defer func(writer *multipart.Writer) {
-		if closeErr := writer.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(writer)
+// createNote notes/create APIでノートを作成し、作成したノートを返す
+func (bot *Bot) createNote(ctx context.Context, data map[string]any) (*Note, error) {
+	body, err := bot.apiRequest(ctx, "notes/create", data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
 
-	// トークンフィールドを追加
-	if writeErr := writer.WriteField("i", bot.BotSetting.Token); writeErr != nil {
-		return nil, errors.Wrap(writeErr, "Failed to WriteField")
+	var result struct {
+		CreatedNote Note `json:"createdNote"`
 	}
 
-	// ファイルフィールドを追加
-	part, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to CreateFormFile")
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
 	}
 
-	if _, copyErr := io.Copy(part, reader); copyErr != nil {
-		return nil, errors.Wrap(copyErr, "Failed to io.Copy")
+	return &result.CreatedNote, nil
+}
+
+// isQuotable 公開範囲のノートを引用できるか判定する
+func isQuotable(visibility string) bool {
+	return visibility != "followers" && visibility != "specified"
+}
+
+// ReplyPolicyFor コマンドの返信方針を返す
+func (bot *Bot) ReplyPolicyFor(command string) *ReplyPolicy {
+	policy := bot.BotSetting.ReplyPolicy
+	if override, ok := bot.BotSetting.CommandReplyPolicies[command]; ok {
+		policy = policy.Override(override)
 	}
+	return &policy
+}
 
-	if closeErr := writer.Close(); closeErr != nil {
-		return nil, errors.Wrap(closeErr, "Failed to Close")
+// UploadFile ファイルをアップロード
+// マルチパートのリクエストボディはパイプで書き出しながら送信するため、ファイル全体をメモリ上に保持しない
+func (bot *Bot) UploadFile(ctx context.Context, reader io.Reader, fileName string) (file *File, err error) {
+	if err := bot.throttle(ctx); err != nil {
+		return nil, errors.Wrap(err, "Failed to throttle")
 	}
 
+	pr, pw := io.Pipe()
+	// 送信が途中で終わった場合も書き込み側のgoroutineを終了させる
+	defer func(pr *io.PipeReader) {
+		if closeErr := pr.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(pr)
+
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(bot.writeUploadBody(&writeUploadBodyParams{
+			Writer:   writer,
+			Reader:   reader,
+			FileName: fileName,
+		}))
+	}()
+
 	url := fmt.Sprintf("https://%s/api/drive/files/create", bot.BotSetting.Domain)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pr)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	// jscpd:ignore-start
-	resp, err := httpclient.ExecuteHTTPRequest(bot.BotSetting.Client, req)
+	body, err := httpclient.ExecuteAndReadBody(bot.BotSetting.Client, req, maxAPIResponseBytes)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to executeHTTPRequest")
+		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
 	}
-	defer func(body io.ReadCloser) {
-		if closeErr := body.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-	// jscpd:ignore-end
 
 	var uploadedFile File
-	if err = json.NewDecoder(resp.Body).Decode(&uploadedFile); err != nil {
-		return nil, errors.Wrap(err, "Failed to json.NewDecoder")
+	if err = json.Unmarshal(body, &uploadedFile); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
 	}
 
 	return &uploadedFile, nil
 }
 
-// AddReaction リアクションを追加
-func (bot *Bot) AddReaction(ctx context.Context, noteID, reaction string) (err error) {
-	data := map[string]any{
-		"noteId":   noteID,
-		"reaction": reaction,
+// DeleteFile アップロードしたファイルを削除
+func (bot *Bot) DeleteFile(ctx context.Context, fileID string) error {
+	if fileID == "" {
+		return lib.ErrParamsEmptyString
 	}
 
-	// jscpd:ignore-start
-	resp, err := bot.apiRequest(ctx, "notes/reactions/create", data)
-	if err != nil {
+	if _, err := bot.apiRequest(ctx, "drive/files/delete", map[string]any{"fileId": fileID}); err != nil {
 		return errors.Wrap(err, "Failed to apiRequest")
 	}
-	defer func(body io.ReadCloser) {
-		if closeErr := body.Close(); closeErr != nil {
-			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
-		}
-	}(resp.Body)
-	// jscpd:ignore-end
-
 	return nil
 }
 
-// ProcessAmeshCommand ameshコマンドを処理
-func (bot *Bot) ProcessAmeshCommand(ctx context.Context, params *ProcessAmeshCommandParams) error {
-	if params == nil || params.Note == nil {
-		return lib.ErrParamsNil
-	}
-	if params.YahooAPIToken == "" {
-		return lib.ErrParamsEmptyString
-	}
+// writeUploadBodyParams マルチパートボディ書き出しのリクエスト構造体
+type writeUploadBodyParams struct {
+	Writer   *multipart.Writer // 書き出し先
+	Reader   io.Reader         // アップロードするファイルの内容
+	FileName string            // ファイル名
+}
 
-	// 処理中リアクションを追加
-	if err := bot.AddReaction(ctx, params.Note.ID, "👀"); err != nil {
-		return errors.Wrap(err, "Failed to AddReaction")
+// writeUploadBody ファイルアップロードのマルチパートボディを書き出す
+func (bot *Bot) writeUploadBody(params *writeUploadBodyParams) error {
+	// トークンフィールドを追加
+	if err := params.Writer.WriteField("i", bot.BotSetting.Token); err != nil {
+		return errors.Wrap(err, "Failed to WriteField")
 	}
 
-	// 位置を解析
-	location, err := amesh.ParseLocationWithLog(ctx, params.Place, params.YahooAPIToken)
+	// ファイルフィールドを追加
+	part, err := params.Writer.CreateFormFile("file", params.FileName)
 	if err != nil {
-		return errors.Wrap(err, "Failed to amesh.ParseLocationWithLog")
+		return errors.Wrap(err, "Failed to CreateFormFile")
 	}
 
-	// 画像をメモリ上に作成
-	imageReader, err := amesh.CreateImageReader(ctx, location)
-	if err != nil {
-		return errors.Wrap(err, "Failed to amesh.CreateImageReader")
+	if _, err := io.Copy(part, params.Reader); err != nil {
+		return errors.Wrap(err, "Failed to io.Copy")
 	}
 
-	// ファイル名を生成
-	fileName := amesh.GenerateFileName(location)
-
-	// Misskeyにメモリから直接アップロード
-	uploadedFile, err := bot.UploadFile(ctx, imageReader, fileName)
-	if err != nil {
-		return errors.Wrap(err, "Failed to UploadFile")
+	if err := params.Writer.Close(); err != nil {
+		return errors.Wrap(err, "Failed to Close")
 	}
+	return nil
+}
 
-	// 結果をノートとして投稿
-	text := fmt.Sprintf(
-		"📡 %s (%.4f, %.4f) の雨雲レーダー画像だっぽ",
-		location.PlaceName,
-		location.Lat,
-		location.Lng,
-	)
-	if err := bot.CreateNote(ctx, &CreateNoteParams{
-		Text:         text,
-		FileIDs:      []string{uploadedFile.ID},
-		OriginalNote: params.Note,
-	}); err != nil {
-		return errors.Wrap(err, "Failed to CreateNote")
+// AddReaction リアクションを追加
+// 任意の絵文字でリアクションできないインスタンスでは"like"でリアクションする
+func (bot *Bot) AddReaction(ctx context.Context, noteID, reaction string) error {
+	data := map[string]any{
+		"noteId":   noteID,
+		"reaction": bot.supportedReaction(reaction),
 	}
 
-	log.Printf("Successfully processed amesh command for %s", location.PlaceName)
+	if _, err := bot.apiRequest(ctx, "notes/reactions/create", data); err != nil {
+		return errors.Wrap(err, "Failed to apiRequest")
+	}
 	return nil
 }
 
@@ -220,72 +291,198 @@ func (bot *Bot) ProcessAmeshCommand(ctx context.Context, params *ProcessAmeshCom
 func (bot *Bot) Connect() error {
 	wsURL := fmt.Sprintf("wss://%s/streaming?i=%s", bot.BotSetting.Domain, bot.BotSetting.Token)
 
-	dialer := websocket.DefaultDialer
-	dialer.HandshakeTimeout = 10 * time.Second
+	// 共有のDialerを書き換えないよう複製してから制限時間を設定する
+	dialer := *websocket.DefaultDialer
+	if bot.Dialer != nil {
+		dialer = *bot.Dialer
+	}
+	if dialer.HandshakeTimeout == 0 {
+		dialer.HandshakeTimeout = 10 * time.Second
+	}
 
+	userAgent := bot.UserAgent
+	if userAgent == "" {
+		userAgent = httpclient.UserAgent(context.Background())
+	}
 	conn, _, err := dialer.Dial(wsURL, http.Header{
-		"User-Agent": []string{bot.UserAgent},
+		"User-Agent": []string{userAgent},
 	})
 	if err != nil {
 		return errors.Wrap(err, "Failed to Dial")
 	}
 
-	bot.WSConn = conn
+	bot.setConn(conn)
 
 	// メインチャンネルに接続
-	connectMsg := struct {
-		Type string            `json:"type"`
-		Body map[string]string `json:"body,omitempty"`
-	}{
-		Type: "connect",
-		Body: map[string]string{
-			"channel": "main",
-			"id":      "main",
-		},
+	if err := bot.connectChannel("main", "main", nil); err != nil {
+		return errors.Wrap(err, "Failed to connectChannel")
 	}
 
-	if err := bot.WSConn.WriteJSON(connectMsg); err != nil {
-		return errors.Wrap(err, "Failed to WriteJSON")
+	// タイムラインチャンネルに接続
+	for _, channel := range bot.BotSetting.TimelineChannels {
+		if err := bot.connectChannel(string(channel.Kind), channel.ConnectionID(), channel.connectParams()); err != nil {
+			return errors.Wrap(err, "Failed to connectChannel")
+		}
+		bot.logger().Info("Subscribed to timeline", "kind", channel.Kind, "target", channel.Target)
 	}
 
-	log.Printf("Connected to Misskey WebSocket: %s", bot.BotSetting.Domain)
+	bot.logger().Info("Connected to Misskey WebSocket", "domain", bot.BotSetting.Domain)
 	return nil
 }
 
+// EventHandlers ストリーミングで受信したイベントごとのハンドラー
+type EventHandlers struct {
+	OnMention     func(note *Note)           // メンション（指名ノートを含む）を受信した場合
+	OnChatMessage func(message *ChatMessage) // チャットメッセージを受信した場合（nilの場合は無視する）
+	// OnTimelineNote タイムラインチャンネルでノートを受信した場合（nilの場合は無視する）
+	// メンションとして受信済みのノートは渡さない
+	OnTimelineNote func(note *Note, channel *TimelineChannel)
+	// OnReaction BotSetting.ReactionTriggersに設定したリアクションがボットのノートに付けられた場合（nilの場合は無視する）
+	OnReaction func(event *ReactionEvent, trigger *ReactionTrigger)
+}
+
+// recentNoteLimit 重複処理を防ぐために記憶するノートIDの数
+const recentNoteLimit = 100
+
 // Listen WebSocketメッセージを監視
-func (bot *Bot) Listen(messageHandler func(note *Note)) error {
-	if messageHandler == nil {
+func (bot *Bot) Listen(ctx context.Context, messageHandler func(note *Note)) error {
+	return bot.ListenEvents(ctx, &EventHandlers{OnMention: messageHandler})
+}
+
+// ListenEvents WebSocketメッセージを監視し、イベントの種類に応じたハンドラーを呼び出す
+// ctxが終了した場合は接続を閉じてnilを返す
+func (bot *Bot) ListenEvents(ctx context.Context, handlers *EventHandlers) error {
+	if handlers == nil || handlers.OnMention == nil {
 		return errors.New("messageHandler cannot be nil")
 	}
 
+	conn := bot.conn()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	// 終了のシグナルを受け取った場合は読み込みを中断する
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	// メンションとタイムラインの両方で受信したノートを二重に処理しないよう記憶する
+	var recentNotes []string
+	markHandled := func(noteID string) bool {
+		if slices.Contains(recentNotes, noteID) {
+			return false
+		}
+		if len(recentNotes) >= recentNoteLimit {
+			recentNotes = recentNotes[1:]
+		}
+		recentNotes = append(recentNotes, noteID)
+		return true
+	}
+
 	for {
 		var msg struct {
 			Type string `json:"type"`
 			Body struct {
-				ID   string `json:"id"`
-				Type string `json:"type"`
-				Body Note   `json:"body"`
+				ID   string          `json:"id"`
+				Type string          `json:"type"`
+				Body json.RawMessage `json:"body"`
 			} `json:"body"`
 		}
-		if err := bot.WSConn.ReadJSON(&msg); err != nil {
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			return errors.Wrap(err, "Failed to ReadJSON")
 		}
 
-		// メンションイベントの処理
-		if msg.Type != "channel" || msg.Body.Type != "mention" {
+		if msg.Type != "channel" {
 			continue
 		}
 
-		note := msg.Body.Body
-		log.Printf("Received mention from @%s: %s", note.User.Username, note.Text)
+		switch msg.Body.Type {
+		// メンションイベントの処理
+		case "mention":
+			var note Note
+			if err := json.Unmarshal(msg.Body.Body, &note); err != nil {
+				bot.logger().Error("Failed to json.Unmarshal mention", "error", err)
+				continue
+			}
+			if !markHandled(note.ID) {
+				continue
+			}
+			bot.logger().Info("Received mention", "user", note.User.Username, "text", note.Text)
+
+			// メッセージハンドラーを呼び出し
+			handlers.OnMention(&note)
+		// チャットメッセージイベントの処理
+		case "newChatMessage":
+			if handlers.OnChatMessage == nil {
+				continue
+			}
+
+			var message ChatMessage
+			if err := json.Unmarshal(msg.Body.Body, &message); err != nil {
+				bot.logger().Error("Failed to json.Unmarshal chat message", "error", err)
+				continue
+			}
+			bot.logger().Info("Received chat message", "user", message.FromUser.Username, "text", message.Text)
+
+			handlers.OnChatMessage(&message)
+		// タイムラインチャンネルのノートの処理
+		case "note":
+			channel := bot.timelineChannel(msg.Body.ID)
+			if handlers.OnTimelineNote == nil || channel == nil {
+				continue
+			}
+
+			var note Note
+			if err := json.Unmarshal(msg.Body.Body, &note); err != nil {
+				bot.logger().Error("Failed to json.Unmarshal timeline note", "error", err)
+				continue
+			}
+			if !markHandled(note.ID) {
+				continue
+			}
+			bot.logger().Info("Received timeline note", "channel", channel.ConnectionID(), "user", note.User.Username, "text", note.Text)
+
+			handlers.OnTimelineNote(&note, channel)
+		// リアクションなどの通知の処理
+		case "notification":
+			if handlers.OnReaction == nil {
+				continue
+			}
+
+			var notification Notification
+			if err := json.Unmarshal(msg.Body.Body, &notification); err != nil {
+				bot.logger().Error("Failed to json.Unmarshal notification", "error", err)
+				continue
+			}
+			event, trigger, ok := bot.reactionEvent(&notification)
+			if !ok {
+				continue
+			}
+			bot.logger().Info("Received reaction", "reaction", event.Reaction, "user", event.User.Username, "note", event.Note.ID)
+
+			handlers.OnReaction(event, trigger)
+		}
+	}
+}
 
-		// メッセージハンドラーを呼び出し
-		messageHandler(&note)
+// timelineChannel 接続IDに対応するタイムラインチャンネルを返す
+func (bot *Bot) timelineChannel(id string) *TimelineChannel {
+	for i := range bot.BotSetting.TimelineChannels {
+		if bot.BotSetting.TimelineChannels[i].ConnectionID() == id {
+			return &bot.BotSetting.TimelineChannels[i]
+		}
 	}
+	return nil
 }
 
-// apiRequest MisskeyAPIリクエストを送信
-func (bot *Bot) apiRequest(ctx context.Context, endpoint string, data map[string]any) (*http.Response, error) {
+// apiRequest MisskeyAPIリクエストを送信し、レスポンスボディを読み込む
+func (bot *Bot) apiRequest(ctx context.Context, endpoint string, data map[string]any) ([]byte, error) {
+	if err := bot.throttle(ctx); err != nil {
+		return nil, errors.Wrap(err, "Failed to throttle")
+	}
+
 	// データにトークンを追加
 	payload := map[string]any{
 		"i": bot.BotSetting.Token,
@@ -306,10 +503,10 @@ func (bot *Bot) apiRequest(ctx context.Context, endpoint string, data map[string
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpclient.ExecuteHTTPRequest(bot.BotSetting.Client, req)
+	body, err := httpclient.ExecuteAndReadBody(bot.BotSetting.Client, req, maxAPIResponseBytes)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to executeHTTPRequest")
+		return nil, errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
 	}
 
-	return resp, nil
+	return body, nil
 }
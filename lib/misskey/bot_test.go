@@ -1,14 +1,20 @@
 package misskey_test
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 
 	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
 
 	"hato-bot-go/lib"
 	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
 	"hato-bot-go/lib/misskey"
 )
 
@@ -123,58 +129,459 @@ func TestCreateNote(t *testing.T) {
 }
 
 func TestUploadFile(t *testing.T) {
+	errRead := errors.New("read failed")
+
 	tests := []struct {
 		name         string
 		fileName     string
-		readerData   string
+		reader       io.Reader
 		statusCode   int
 		responseBody string
+		expectBody   []string
 		expectError  error
 	}{
 		{
 			name:         "成功したファイルアップロード",
 			fileName:     "test.txt",
-			readerData:   "test file content",
+			reader:       strings.NewReader("test file content"),
 			statusCode:   http.StatusOK,
 			responseBody: `{"id":"file123","name":"test.txt","url":"https://example.com/file123"}`,
+			expectBody:   []string{`name="i"`, "token", `filename="test.txt"`, "test file content"},
 			expectError:  nil,
 		},
 		// jscpd:ignore-start
 		{
 			name:         "APIエラー応答",
 			fileName:     "test.txt",
-			readerData:   "test content",
+			reader:       strings.NewReader("test content"),
 			statusCode:   http.StatusBadRequest,
 			responseBody: `{"error":"bad request"}`,
 			expectError:  httpclient.ErrHTTPRequestError,
 		},
 		// jscpd:ignore-end
+		{
+			name:         "ファイルの読み込みに失敗",
+			fileName:     "test.txt",
+			reader:       iotest.ErrReader(errRead),
+			statusCode:   http.StatusOK,
+			responseBody: `{"id":"file123"}`,
+			expectError:  errRead,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			t.Helper()
-			mockClient := httpclient.NewMockHTTPClient(tt.statusCode, tt.responseBody)
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{
+					StatusCode: tt.statusCode,
+					Body:       tt.responseBody,
+				},
+			})
 			bot := misskey.NewBotWithClient(&misskey.BotSetting{
 				Domain: "example.com",
 				Token:  "token",
-				Client: mockClient,
+				Client: transport.Client(),
 			})
 
-			reader := strings.NewReader(tt.readerData)
-			if _, err := bot.UploadFile(t.Context(), reader, tt.fileName); !errors.Is(err, tt.expectError) {
+			if _, err := bot.UploadFile(t.Context(), tt.reader, tt.fileName); !errors.Is(err, tt.expectError) {
 				t.Errorf("UploadFile() error = %v, expectError = %v", err, tt.expectError)
 			}
+
+			// パイプで書き出したマルチパートボディがそのまま送信されていること
+			for _, want := range tt.expectBody {
+				requests := transport.RequestsTo("drive/files/create")
+				if len(requests) != 1 {
+					t.Fatalf("requests = %d, want 1", len(requests))
+				}
+				if !strings.Contains(string(requests[0].Body), want) {
+					t.Errorf("request body does not contain %q", want)
+				}
+			}
 		})
 	}
 }
 
-func TestProcessAmeshCommand(t *testing.T) {
+func TestCreateNoteRequestPayload(t *testing.T) {
+	cw := "CW"
 	tests := []struct {
-		name        string
-		params      *misskey.ProcessAmeshCommandParams
-		expectError error
+		name          string
+		botPolicy     misskey.ReplyPolicy
+		templates     map[string]string
+		params        *misskey.CreateNoteParams
+		expectPayload map[string]any
+	}{
+		{
+			name: "publicの返信はhomeで投稿",
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: &misskey.Note{
+					ID:         "original123",
+					Visibility: "public",
+				},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+			},
+		},
+		{
+			name: "規則でリモートのユーザーにはフォロワー限定で返信",
+			botPolicy: misskey.ReplyPolicy{VisibilityRules: []misskey.VisibilityRule{
+				{Match: misskey.VisibilityMatchRemote, Visibility: "followers"},
+				{Match: misskey.VisibilityMatchAll, Visibility: misskey.VisibilityFollow},
+			}},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: func() *misskey.Note {
+					note := &misskey.Note{ID: "original123", Visibility: "public"}
+					note.User.Host = "remote.example.com"
+					return note
+				}(),
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "followers",
+				"replyId":    "original123",
+			},
+		},
+		{
+			name: "規則で元ノートに合わせてローカルのみに投稿",
+			botPolicy: misskey.ReplyPolicy{VisibilityRules: []misskey.VisibilityRule{
+				{Match: misskey.VisibilityMatchRemote, Visibility: "followers"},
+				{Match: misskey.VisibilityMatchAll, Visibility: misskey.VisibilityFollow, LocalOnly: true},
+			}},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "public"},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "public",
+				"replyId":    "original123",
+				"localOnly":  true,
+			},
+		},
+		{
+			name: "規則でも元ノートより広い公開範囲にはしない",
+			botPolicy: misskey.ReplyPolicy{VisibilityRules: []misskey.VisibilityRule{
+				{Match: misskey.VisibilityMatchAll, Visibility: "home"},
+			}},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "followers"},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "followers",
+				"replyId":    "original123",
+			},
+		},
+		{
+			name:      "CWの文言を返信テンプレートで上書き",
+			templates: map[string]string{"reply.cw": "@{{.User}}への返信"},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: func() *misskey.Note {
+					note := &misskey.Note{ID: "original123", Visibility: "home", CW: &cw}
+					note.User.Username = "alice"
+					note.User.Host = "remote.example.com"
+					return note
+				}(),
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+				"cw":         "@alice@remote.example.comへの返信",
+			},
+		},
+		{
+			name: "CW付きの元ノートにはCW付きで返信",
+			params: &misskey.CreateNoteParams{
+				Text:    "test note",
+				FileIDs: []string{"file123"},
+				OriginalNote: &misskey.Note{
+					ID:         "original123",
+					Visibility: "followers",
+					CW:         &cw,
+				},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "followers",
+				"replyId":    "original123",
+				"fileIds":    []any{"file123"},
+				"cw":         "隠すっぽ！",
+			},
+		},
+		{
+			name:      "元ノートのCWの文言をそのまま使う",
+			botPolicy: misskey.ReplyPolicy{CW: misskey.CWModeMirror},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "home", CW: &cw},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+				"cw":         "CW",
+			},
+		},
+		{
+			name:      "元ノートのCWの文言が空の場合は決まった文言",
+			botPolicy: misskey.ReplyPolicy{CW: misskey.CWModeMirror},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "home", CW: new(string)},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+				"cw":         "隠すっぽ！",
+			},
+		},
+		{
+			name:      "方針でCWの文言を指定",
+			botPolicy: misskey.ReplyPolicy{CWText: "ネタバレ注意っぽ"},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "home", CW: &cw},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+				"cw":         "ネタバレ注意っぽ",
+			},
+		},
+		{
+			name:      "画像を添付する返信は天気画像のCW",
+			botPolicy: misskey.ReplyPolicy{CW: misskey.CWModeWeather},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				FileIDs:      []string{"file123"},
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "home"},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+				"fileIds":    []any{"file123"},
+				"cw":         "天気画像",
+			},
+		},
+		{
+			name:      "天気画像のCWでも画像のない返信はCWなし",
+			botPolicy: misskey.ReplyPolicy{CW: misskey.CWModeWeather},
+			params: &misskey.CreateNoteParams{
+				Text:         "test note",
+				OriginalNote: &misskey.Note{ID: "original123", Visibility: "home"},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+			},
+		},
+		{
+			name:      "インスタンス全体の方針で引用・ローカルのみ",
+			botPolicy: misskey.ReplyPolicy{Mode: misskey.ReplyModeQuote, LocalOnly: true},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: &misskey.Note{
+					ID:         "original123",
+					Visibility: "home",
+				},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"renoteId":   "original123",
+				"localOnly":  true,
+			},
+		},
+		{
+			name:      "ノートごとの方針で指名ノートとして投稿者に返信",
+			botPolicy: misskey.ReplyPolicy{Mode: misskey.ReplyModeQuote},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: func() *misskey.Note {
+					note := &misskey.Note{ID: "original123", Visibility: "public"}
+					note.User.ID = "user123"
+					return note
+				}(),
+				Policy: &misskey.ReplyPolicy{Visibility: "specified"},
+			},
+			expectPayload: map[string]any{
+				"i":              "token",
+				"text":           "test note",
+				"visibility":     "specified",
+				"replyId":        "original123",
+				"visibleUserIds": []any{"user123"},
+			},
+		},
+		{
+			name:      "方針の公開範囲が元ノートより広い場合は元ノートに合わせる",
+			botPolicy: misskey.ReplyPolicy{Visibility: "public"},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: &misskey.Note{
+					ID:         "original123",
+					Visibility: "followers",
+				},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "followers",
+				"replyId":    "original123",
+			},
+		},
+		{
+			name:      "指名ノートへの返信は方針でhomeが指定されていても指名ノートにする",
+			botPolicy: misskey.ReplyPolicy{Visibility: "home"},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: func() *misskey.Note {
+					note := &misskey.Note{ID: "original123", Visibility: "specified"}
+					note.User.ID = "user123"
+					return note
+				}(),
+			},
+			expectPayload: map[string]any{
+				"i":              "token",
+				"text":           "test note",
+				"visibility":     "specified",
+				"replyId":        "original123",
+				"visibleUserIds": []any{"user123"},
+			},
+		},
+		{
+			name:      "フォロワー限定の元ノートは引用せずにリプライする",
+			botPolicy: misskey.ReplyPolicy{Mode: misskey.ReplyModeQuote},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: &misskey.Note{
+					ID:         "original123",
+					Visibility: "followers",
+				},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "followers",
+				"replyId":    "original123",
+			},
+		},
+		{
+			name:      "指名ノートの元ノートは引用せずにリプライする",
+			botPolicy: misskey.ReplyPolicy{Mode: misskey.ReplyModeQuote},
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: func() *misskey.Note {
+					note := &misskey.Note{ID: "original123", Visibility: "specified"}
+					note.User.ID = "user123"
+					return note
+				}(),
+			},
+			expectPayload: map[string]any{
+				"i":              "token",
+				"text":           "test note",
+				"visibility":     "specified",
+				"replyId":        "original123",
+				"visibleUserIds": []any{"user123"},
+			},
+		},
+		{
+			name: "連合なしの元ノートにはローカルのみで返信",
+			params: &misskey.CreateNoteParams{
+				Text: "test note",
+				OriginalNote: &misskey.Note{
+					ID:         "original123",
+					Visibility: "home",
+					LocalOnly:  true,
+				},
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
+				"replyId":    "original123",
+				"localOnly":  true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{
+						Pattern:   "/api/notes/create",
+						Method:    http.MethodPost,
+						Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"created123"}}`}},
+					},
+				},
+			})
+			templates, err := i18n.ParseTemplates(tt.templates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain:      "example.com",
+				Token:       "token",
+				Client:      transport.Client(),
+				ReplyPolicy: tt.botPolicy,
+				Templates:   templates,
+			})
+
+			if err := bot.CreateNote(t.Context(), tt.params); err != nil {
+				t.Fatalf("CreateNote() error = %v", err)
+			}
+
+			requests := transport.RequestsTo("https://example.com/api/notes/create")
+			if len(requests) != 1 {
+				t.Fatalf("notes/create called %d times, want 1", len(requests))
+			}
+			if contentType := requests[0].Header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+
+			var payload map[string]any
+			if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(payload, tt.expectPayload); diff != "" {
+				t.Errorf("notes/create payload diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPostNote(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        *misskey.PostNoteParams
+		expectPayload map[string]any
+		expectError   error
 	}{
 		{
 			name:        "nilリクエスト",
@@ -182,38 +589,181 @@ func TestProcessAmeshCommand(t *testing.T) {
 			expectError: lib.ErrParamsNil,
 		},
 		{
-			name: "nilノート",
-			params: &misskey.ProcessAmeshCommandParams{
-				Note:          nil,
-				Place:         "東京",
-				YahooAPIToken: "YahooAPIToken",
+			name:   "公開範囲の指定がなければhomeで投稿",
+			params: &misskey.PostNoteParams{Text: "test note"},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "home",
 			},
-			expectError: lib.ErrParamsNil,
 		},
 		{
-			name: "Yahoo APIトークンが設定されていない",
-			params: &misskey.ProcessAmeshCommandParams{
-				Note: &misskey.Note{
-					ID:         "note123",
-					Visibility: "home",
-				},
-				Place: "東京",
+			name: "公開範囲・ローカルのみ・添付ファイルを指定",
+			params: &misskey.PostNoteParams{
+				Text:       "test note",
+				FileIDs:    []string{"file123"},
+				Visibility: "public",
+				LocalOnly:  true,
+			},
+			expectPayload: map[string]any{
+				"i":          "token",
+				"text":       "test note",
+				"visibility": "public",
+				"localOnly":  true,
+				"fileIds":    []any{"file123"},
 			},
-			expectError: lib.ErrParamsEmptyString, // Yahoo APIトークンが設定されていないためエラーが発生する
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			runSimpleBotTest(t, &runSimpleBotTestParams{
-				StatusCode: http.StatusNoContent,
-				TestFunc: func(bot *misskey.Bot) error {
-					return bot.ProcessAmeshCommand(t.Context(), tt.params)
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"created123"}}`},
+			})
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain: "example.com",
+				Token:  "token",
+				Client: transport.Client(),
+			})
+
+			if err := bot.PostNote(t.Context(), tt.params); !errors.Is(err, tt.expectError) {
+				t.Fatalf("PostNote() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if tt.expectError != nil {
+				return
+			}
+
+			requests := transport.RequestsTo("https://example.com/api/notes/create")
+			if len(requests) != 1 {
+				t.Fatalf("notes/create called %d times, want 1", len(requests))
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(payload, tt.expectPayload); diff != "" {
+				t.Errorf("notes/create payload diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestBotConcurrentRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+	}{
+		{
+			name:    "1つのworker",
+			workers: 1,
+		},
+		{
+			name:    "複数のworkerから同時に呼び出す",
+			workers: 16,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "notes/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"created123"}}`}}},
 				},
-				ExpectError: tt.expectError,
-				TestName:    "ProcessAmeshCommand()",
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusNoContent},
 			})
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{Domain: "example.com", Token: "token", Client: transport.Client()})
+
+			var wg sync.WaitGroup
+			for range tt.workers {
+				wg.Go(func() {
+					if err := bot.AddReaction(t.Context(), "note123", "👀"); err != nil {
+						t.Errorf("AddReaction() error = %v", err)
+					}
+					if err := bot.CreateNote(t.Context(), &misskey.CreateNoteParams{
+						Text:         "test",
+						OriginalNote: &misskey.Note{ID: "note123", Visibility: "home"},
+					}); err != nil {
+						t.Errorf("CreateNote() error = %v", err)
+					}
+				})
+			}
+			wg.Wait()
+
+			if got := len(transport.RequestsTo("notes/reactions/create")); got != tt.workers {
+				t.Errorf("reactions = %d, want %d", got, tt.workers)
+			}
+			if got := len(transport.RequestsTo("notes/create")); got != tt.workers {
+				t.Errorf("notes = %d, want %d", got, tt.workers)
+			}
+		})
+	}
+}
+
+func TestReplyPolicyFor(t *testing.T) {
+	bot := misskey.NewBotWithClient(&misskey.BotSetting{
+		Domain:      "example.com",
+		Token:       "token",
+		Client:      httpclient.NewMockHTTPClient(http.StatusOK, ""),
+		ReplyPolicy: misskey.ReplyPolicy{Visibility: "home", LocalOnly: true},
+		CommandReplyPolicies: map[string]misskey.ReplyPolicy{
+			"amesh": {Mode: misskey.ReplyModeQuote},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		command  string
+		expected *misskey.ReplyPolicy
+	}{
+		{
+			name:     "コマンドの方針で上書き",
+			command:  "amesh",
+			expected: &misskey.ReplyPolicy{Mode: misskey.ReplyModeQuote, Visibility: "home", LocalOnly: true},
+		},
+		{
+			name:     "方針のないコマンドはインスタンス全体の方針",
+			command:  "other",
+			expected: &misskey.ReplyPolicy{Visibility: "home", LocalOnly: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(bot.ReplyPolicyFor(tt.command), tt.expected); diff != "" {
+				t.Errorf("ReplyPolicyFor(%q) diff: %s", tt.command, diff)
+			}
+		})
+	}
+}
+
+func TestReplyPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      misskey.ReplyPolicy
+		expectError error
+	}{
+		{name: "空の方針", policy: misskey.ReplyPolicy{}, expectError: nil},
+		{name: "有効な方針", policy: misskey.ReplyPolicy{Mode: misskey.ReplyModeQuote, Visibility: "specified"}, expectError: nil},
+		{name: "不正な返信方法", policy: misskey.ReplyPolicy{Mode: "dm"}, expectError: misskey.ErrInvalidReplyPolicy},
+		{name: "不正な公開範囲", policy: misskey.ReplyPolicy{Visibility: "private"}, expectError: misskey.ErrInvalidReplyPolicy},
+		{
+			name:        "不正な公開範囲の規則",
+			policy:      misskey.ReplyPolicy{VisibilityRules: []misskey.VisibilityRule{{Match: "bot", Visibility: "home"}}},
+			expectError: misskey.ErrInvalidReplyPolicy,
+		},
+		{name: "有効なCWの付け方", policy: misskey.ReplyPolicy{CW: misskey.CWModeMirror}, expectError: nil},
+		{name: "不正なCWの付け方", policy: misskey.ReplyPolicy{CW: "hide"}, expectError: misskey.ErrInvalidReplyPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.policy.Validate(); !errors.Is(err, tt.expectError) {
+				t.Errorf("Validate() error = %v, expectError = %v", err, tt.expectError)
+			}
 		})
 	}
 }
@@ -0,0 +1,73 @@
+package misskey
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+)
+
+// ChatMessage Misskeyのチャットメッセージ構造体
+type ChatMessage struct {
+	ID         string `json:"id"`
+	Text       string `json:"text,omitempty"`
+	FromUserID string `json:"fromUserId"`
+	FromUser   struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Host     string `json:"host,omitempty"`
+	} `json:"fromUser"`
+	ToUserID string `json:"toUserId,omitempty"`
+}
+
+// SendChatMessageParams チャットメッセージ送信のリクエスト構造体
+type SendChatMessageParams struct {
+	ToUserID string // 送信先ユーザーのID
+	Text     string // メッセージのテキスト
+	FileID   string // 添付ファイルのID（空の場合は添付しない）
+}
+
+// SendChatMessage ユーザーにチャットメッセージを送信
+// チャットに対応していないインスタンスではErrChatUnsupportedを返す
+func (bot *Bot) SendChatMessage(ctx context.Context, params *SendChatMessageParams) error {
+	if params == nil {
+		return lib.ErrParamsNil
+	}
+	if params.ToUserID == "" {
+		return lib.ErrParamsEmptyString
+	}
+	if !bot.Capabilities().Chat {
+		return ErrChatUnsupported
+	}
+
+	data := map[string]any{
+		"toUserId": params.ToUserID,
+		"text":     params.Text,
+	}
+	if params.FileID != "" {
+		data["fileId"] = params.FileID
+	}
+
+	if _, err := bot.apiRequest(ctx, "chat/messages/create-to-user", data); err != nil {
+		return errors.Wrap(err, "Failed to apiRequest")
+	}
+	return nil
+}
+
+// AddChatReaction チャットメッセージにリアクションを追加
+// チャットに対応していないインスタンスではErrChatUnsupportedを返す
+func (bot *Bot) AddChatReaction(ctx context.Context, messageID, reaction string) error {
+	if !bot.Capabilities().Chat {
+		return ErrChatUnsupported
+	}
+	data := map[string]any{
+		"messageId": messageID,
+		"reaction":  reaction,
+	}
+
+	if _, err := bot.apiRequest(ctx, "chat/messages/react", data); err != nil {
+		return errors.Wrap(err, "Failed to apiRequest")
+	}
+	return nil
+}
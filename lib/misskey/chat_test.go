@@ -0,0 +1,349 @@
+package misskey_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+func TestSendChatMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        *misskey.SendChatMessageParams
+		expectError   error
+		expectPayload map[string]any
+	}{
+		{
+			name:        "nilリクエスト",
+			params:      nil,
+			expectError: lib.ErrParamsNil,
+		},
+		{
+			name:        "送信先が空",
+			params:      &misskey.SendChatMessageParams{Text: "test"},
+			expectError: lib.ErrParamsEmptyString,
+		},
+		{
+			name: "テキストのみ",
+			params: &misskey.SendChatMessageParams{
+				ToUserID: "user123",
+				Text:     "test message",
+			},
+			expectPayload: map[string]any{
+				"i":        "token",
+				"toUserId": "user123",
+				"text":     "test message",
+			},
+		},
+		{
+			name: "ファイル添付",
+			params: &misskey.SendChatMessageParams{
+				ToUserID: "user123",
+				Text:     "test message",
+				FileID:   "file123",
+			},
+			expectPayload: map[string]any{
+				"i":        "token",
+				"toUserId": "user123",
+				"text":     "test message",
+				"fileId":   "file123",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{
+						Pattern:   "/api/chat/messages/create-to-user",
+						Method:    http.MethodPost,
+						Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"message456"}`}},
+					},
+				},
+			})
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain: "example.com",
+				Token:  "token",
+				Client: transport.Client(),
+			})
+
+			err := bot.SendChatMessage(t.Context(), tt.params)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("SendChatMessage() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			requests := transport.RequestsTo("https://example.com/api/chat/messages/create-to-user")
+			if tt.expectPayload == nil {
+				if len(requests) != 0 {
+					t.Errorf("chat/messages/create-to-user called %d times, want 0", len(requests))
+				}
+				return
+			}
+			if len(requests) != 1 {
+				t.Fatalf("chat/messages/create-to-user called %d times, want 1", len(requests))
+			}
+
+			var payload map[string]any
+			if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(payload, tt.expectPayload); diff != "" {
+				t.Errorf("chat/messages/create-to-user payload diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestAddChatReaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		expectError error
+	}{
+		{
+			name:        "正常系",
+			statusCode:  http.StatusNoContent,
+			expectError: nil,
+		},
+		{
+			name:        "APIエラー",
+			statusCode:  http.StatusBadRequest,
+			expectError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			runSimpleBotTest(t, &runSimpleBotTestParams{
+				StatusCode: tt.statusCode,
+				TestFunc: func(bot *misskey.Bot) error {
+					return bot.AddChatReaction(t.Context(), "message123", "👀")
+				},
+				ExpectError: tt.expectError,
+				TestName:    "AddChatReaction()",
+			})
+		})
+	}
+}
+
+func TestListenEvents(t *testing.T) {
+	tests := []struct {
+		name               string
+		messages           []string
+		enableChat         bool
+		timelineChannels   []misskey.TimelineChannel
+		reactionTriggers   []misskey.ReactionTrigger
+		expectMentions     []string
+		expectChatMessages []string
+		expectTimelineNote []string
+		expectReactions    []string
+	}{
+		{
+			name: "メンションとチャットメッセージを振り分ける",
+			messages: []string{
+				`{"type":"channel","body":{"id":"main","type":"mention","body":{"id":"note1","text":"@hato amesh 東京"}}}`,
+				`{"type":"channel","body":{"id":"main","type":"newChatMessage","body":{"id":"message1","text":"amesh 大阪","fromUserId":"user1"}}}`,
+				`{"type":"channel","body":{"id":"main","type":"followed","body":{}}}`,
+				`{"type":"noteUpdated","body":{}}`,
+			},
+			enableChat:         true,
+			expectMentions:     []string{"note1"},
+			expectChatMessages: []string{"message1"},
+		},
+		{
+			name: "チャットハンドラーがない場合はチャットメッセージを無視する",
+			messages: []string{
+				`{"type":"channel","body":{"id":"main","type":"newChatMessage","body":{"id":"message1","text":"amesh 大阪","fromUserId":"user1"}}}`,
+				`{"type":"channel","body":{"id":"main","type":"mention","body":{"id":"note1","text":"@hato amesh 東京"}}}`,
+			},
+			enableChat:         false,
+			expectMentions:     []string{"note1"},
+			expectChatMessages: nil,
+		},
+		{
+			name: "購読中のタイムラインのノートを受信し、メンション済みのノートは除く",
+			messages: []string{
+				`{"type":"channel","body":{"id":"main","type":"mention","body":{"id":"note1","text":"@hato #amesh 東京"}}}`,
+				`{"type":"channel","body":{"id":"hashtag:amesh","type":"note","body":{"id":"note1","text":"@hato #amesh 東京"}}}`,
+				`{"type":"channel","body":{"id":"hashtag:amesh","type":"note","body":{"id":"note2","text":"#amesh 大阪"}}}`,
+				`{"type":"channel","body":{"id":"hashtag:unknown","type":"note","body":{"id":"note3","text":"#amesh 福岡"}}}`,
+			},
+			timelineChannels: []misskey.TimelineChannel{
+				{Kind: misskey.TimelineChannelHashtag, Target: "amesh"},
+			},
+			expectMentions:     []string{"note1"},
+			expectTimelineNote: []string{"hashtag:amesh/note2"},
+		},
+		{
+			name: "設定したリアクションの通知のみ受信する",
+			messages: []string{
+				`{"type":"channel","body":{"id":"main","type":"notification","body":{"id":"n1","type":"reaction","reaction":":amesh@.:","note":{"id":"note1","text":"東京"},"user":{"id":"user1","username":"alice"}}}}`,
+				`{"type":"channel","body":{"id":"main","type":"notification","body":{"id":"n2","type":"reaction","reaction":"👍","note":{"id":"note2","text":"大阪"},"user":{"id":"user1","username":"alice"}}}}`,
+				`{"type":"channel","body":{"id":"main","type":"notification","body":{"id":"n3","type":"follow","user":{"id":"user2","username":"bob"}}}}`,
+				`{"type":"channel","body":{"id":"main","type":"mention","body":{"id":"note3","text":"@hato amesh 東京"}}}`,
+			},
+			reactionTriggers: []misskey.ReactionTrigger{{Reaction: ":amesh:", Command: "amesh"}},
+			expectMentions:   []string{"note3"},
+			expectReactions:  []string{"amesh/note1/alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer func() { _ = conn.Close() }()
+				for _, message := range tt.messages {
+					if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}))
+			defer server.Close()
+
+			conn, resp, err := websocket.DefaultDialer.DialContext(
+				t.Context(),
+				"ws"+strings.TrimPrefix(server.URL, "http"),
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Body != nil {
+				if err := resp.Body.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer func() { _ = conn.Close() }()
+
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain:           "example.com",
+				Token:            "token",
+				Client:           http.DefaultClient,
+				TimelineChannels: tt.timelineChannels,
+				ReactionTriggers: tt.reactionTriggers,
+			})
+			bot.SetConn(conn)
+
+			var mentions, chatMessages, timelineNotes, reactions []string
+			handlers := &misskey.EventHandlers{
+				OnMention: func(note *misskey.Note) {
+					mentions = append(mentions, note.ID)
+				},
+				OnTimelineNote: func(note *misskey.Note, channel *misskey.TimelineChannel) {
+					timelineNotes = append(timelineNotes, channel.ConnectionID()+"/"+note.ID)
+				},
+				OnReaction: func(event *misskey.ReactionEvent, trigger *misskey.ReactionTrigger) {
+					reactions = append(reactions, trigger.Command+"/"+event.Note.ID+"/"+event.User.Username)
+				},
+			}
+			if tt.enableChat {
+				handlers.OnChatMessage = func(message *misskey.ChatMessage) {
+					chatMessages = append(chatMessages, message.ID)
+				}
+			}
+
+			// サーバーが接続を閉じるとエラーで終了する
+			if err := bot.ListenEvents(t.Context(), handlers); err == nil {
+				t.Error("ListenEvents() expected error after connection closed")
+			}
+
+			if diff := cmp.Diff(mentions, tt.expectMentions); diff != "" {
+				t.Errorf("mentions diff: %s", diff)
+			}
+			if diff := cmp.Diff(chatMessages, tt.expectChatMessages); diff != "" {
+				t.Errorf("chat messages diff: %s", diff)
+			}
+			if diff := cmp.Diff(timelineNotes, tt.expectTimelineNote); diff != "" {
+				t.Errorf("timeline notes diff: %s", diff)
+			}
+			if diff := cmp.Diff(reactions, tt.expectReactions); diff != "" {
+				t.Errorf("reactions diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestListenEventsNilHandler(t *testing.T) {
+	t.Parallel()
+	bot := misskey.NewBotWithClient(&misskey.BotSetting{Domain: "example.com", Token: "token", Client: http.DefaultClient})
+
+	if err := bot.ListenEvents(t.Context(), nil); err == nil {
+		t.Error("ListenEvents(nil) expected error")
+	}
+	if err := bot.ListenEvents(t.Context(), &misskey.EventHandlers{}); err == nil {
+		t.Error("ListenEvents() without OnMention expected error")
+	}
+	handlers := &misskey.EventHandlers{OnMention: func(*misskey.Note) {}}
+	if err := bot.ListenEvents(t.Context(), handlers); !errors.Is(err, misskey.ErrNotConnected) {
+		t.Errorf("ListenEvents() without connection error = %v, want %v", err, misskey.ErrNotConnected)
+	}
+}
+
+// TestListenEventsCancel 終了のシグナルを受け取るとメッセージを待たずに終了することを確認する
+func TestListenEventsCancel(t *testing.T) {
+	t.Parallel()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		// クライアントが接続を閉じるまで何も送らない
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(t.Context(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != nil {
+		if err := resp.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bot := misskey.NewBotWithClient(&misskey.BotSetting{Domain: "example.com", Token: "token", Client: http.DefaultClient})
+	bot.SetConn(conn)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- bot.ListenEvents(ctx, &misskey.EventHandlers{OnMention: func(*misskey.Note) {}})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ListenEvents() error = %v, want nil after cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenEvents() did not return after cancel")
+	}
+}
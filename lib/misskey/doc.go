@@ -0,0 +1,15 @@
+// Package misskey MisskeyのAPIとストリーミングを使うボットのクライアント
+//
+// 他のGoプログラムから使える安定したAPIは次のとおり。
+//
+//   - ボットの作成: NewBot（WithHTTPClient・WithUserAgent・WithLogger・WithRateLimitで指定し、返信方針や言語は作成後にBotSettingで指定する）
+//   - ノート・チャット: Bot.CreateNote・Bot.PostNote・Bot.SendChatMessage・Bot.AddReaction・Bot.AddChatReaction
+//   - ドライブ: Bot.UploadFile・Bot.DeleteFile・Bot.FetchDriveUsage・Bot.ListDriveFiles・NewDrivePruner
+//   - イベントの受信: Bot.Connect・Bot.ListenEvents（WebSocket）、Bot.PollNotifications（ポーリング）
+//   - インスタンスの機能の判定: Bot.FetchMeta・Bot.DetectCapabilities・Bot.Capabilities・Bot.MaxNoteTextLength
+//   - botパッケージのエンジンとの接続: NewPlatform
+//
+// 関数の引数にする構造体は「関数名+Params」（複数の関数で共有する場合は「対象+Params」）、
+// 設定は「型名+Setting」、結果は「関数名+Result」と名付ける。
+// 名前を変えた型は型エイリアスとして以前の名前を残し、Deprecatedと記載する。
+package misskey
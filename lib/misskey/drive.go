@@ -0,0 +1,175 @@
+package misskey
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/clock"
+)
+
+// DefaultDrivePruneInterval ドライブの古いファイルを確認する間隔の既定値
+const DefaultDrivePruneInterval = time.Hour
+
+// driveFileLimit 1回のdrive/filesで取得するファイルの最大数
+const driveFileLimit = 100
+
+// DriveUsage ドライブの容量と使用量（/api/drive）
+type DriveUsage struct {
+	Capacity int64 `json:"capacity"` // 容量（バイト）
+	Usage    int64 `json:"usage"`    // 使用量（バイト）
+}
+
+// DriveFile ドライブのファイル
+type DriveFile struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`      // ファイルの大きさ（バイト）
+	CreatedAt time.Time `json:"createdAt"` // アップロードした時刻
+}
+
+// FetchDriveUsage ドライブの容量と使用量を取得する（/api/drive）
+func (bot *Bot) FetchDriveUsage(ctx context.Context) (*DriveUsage, error) {
+	body, err := bot.apiRequest(ctx, "drive", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
+
+	var usage DriveUsage
+	if err := json.Unmarshal(body, &usage); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return &usage, nil
+}
+
+// ListDriveFiles ドライブの最上位のフォルダのファイルをuntilIDより古いものから新しい順に取得する（drive/files）
+// untilIDが空の場合は最新のファイルから取得する
+func (bot *Bot) ListDriveFiles(ctx context.Context, untilID string, limit int) ([]DriveFile, error) {
+	data := map[string]any{
+		"limit":    limit,
+		"folderId": nil,
+	}
+	if untilID != "" {
+		data["untilId"] = untilID
+	}
+
+	body, err := bot.apiRequest(ctx, "drive/files", data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
+
+	var files []DriveFile
+	if err := json.Unmarshal(body, &files); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return files, nil
+}
+
+// DrivePrunerSetting ドライブの古いファイルの削除の設定
+// RetentionとUsageLimitの少なくとも一方を指定する
+type DrivePrunerSetting struct {
+	Retention  time.Duration // アップロードからこの期間を過ぎたファイルを削除する（0の場合は期間では削除しない）
+	UsageLimit float64       // 容量に対する使用量がこの割合を超える場合に古いファイルから削除する（0の場合は使用量では削除しない）
+	Interval   time.Duration // 確認の間隔（0の場合はDefaultDrivePruneInterval）
+	Clock      clock.Clock   // 現在時刻と確認の間隔に使う時計（nilの場合はclock.Real）
+}
+
+// DrivePruneResult 1回の確認で削除したファイル
+type DrivePruneResult struct {
+	Deleted    int   // 削除したファイルの数
+	FreedBytes int64 // 削除したファイルの大きさの合計（バイト）
+}
+
+// DrivePruner 返信に添付した画像などでドライブが埋まらないよう、最上位のフォルダの古いファイルを定期的に削除する
+type DrivePruner struct {
+	bot     *Bot
+	setting DrivePrunerSetting
+}
+
+// NewDrivePruner 新しいDrivePrunerを作成する
+func NewDrivePruner(b *Bot, setting *DrivePrunerSetting) *DrivePruner {
+	if b == nil || setting == nil {
+		return nil
+	}
+	s := *setting
+	if s.Interval <= 0 {
+		s.Interval = DefaultDrivePruneInterval
+	}
+	s.Clock = clock.Or(s.Clock)
+	return &DrivePruner{bot: b, setting: s}
+}
+
+// Run ctxがキャンセルされるまでIntervalごとに古いファイルを削除する
+// 起動直後にも1回確認し、確認の失敗はログに出力して次の確認を待つ
+func (p *DrivePruner) Run(ctx context.Context) error {
+	if p == nil {
+		return lib.ErrParamsNil
+	}
+	for ctx.Err() == nil {
+		result, err := p.Prune(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			p.bot.logger().Error("Failed to prune drive files", "error", err)
+		case result != nil && 0 < result.Deleted:
+			p.bot.logger().Info("Pruned drive files", "deleted", result.Deleted, "bytes", result.FreedBytes)
+		}
+		_ = p.setting.Clock.Sleep(ctx, p.setting.Interval)
+	}
+	return nil
+}
+
+// Prune 保持期間を過ぎたファイルを削除し、使用量が上限を超えている場合はさらに古いファイルから削除する
+// 削除に失敗した場合も、それまでに削除したファイルを結果として返す
+func (p *DrivePruner) Prune(ctx context.Context) (*DrivePruneResult, error) {
+	files, err := p.listFiles(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to listFiles")
+	}
+	// 古い順に削除する
+	slices.SortFunc(files, func(a, b DriveFile) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	var excess int64
+	if 0 < p.setting.UsageLimit {
+		usage, err := p.bot.FetchDriveUsage(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to FetchDriveUsage")
+		}
+		excess = usage.Usage - int64(float64(usage.Capacity)*p.setting.UsageLimit)
+	}
+
+	result := &DrivePruneResult{}
+	cutoff := p.setting.Clock.Now().Add(-p.setting.Retention)
+	for _, file := range files {
+		expired := 0 < p.setting.Retention && file.CreatedAt.Before(cutoff)
+		if !expired && excess <= result.FreedBytes {
+			break
+		}
+		if err := p.bot.DeleteFile(ctx, file.ID); err != nil {
+			return result, errors.Wrapf(err, "Failed to DeleteFile: %s", file.ID)
+		}
+		result.Deleted++
+		result.FreedBytes += file.Size
+	}
+	return result, nil
+}
+
+// listFiles 最上位のフォルダの全てのファイルを新しい順に取得する
+func (p *DrivePruner) listFiles(ctx context.Context) ([]DriveFile, error) {
+	var files []DriveFile
+	untilID := ""
+	for {
+		page, err := p.bot.ListDriveFiles(ctx, untilID, driveFileLimit)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to ListDriveFiles")
+		}
+		files = append(files, page...)
+		if len(page) < driveFileLimit {
+			return files, nil
+		}
+		untilID = page[len(page)-1].ID
+	}
+}
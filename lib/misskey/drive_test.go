@@ -0,0 +1,174 @@
+package misskey_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/clock/clocktest"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+// driveFilesJSON 新しい順のファイルの一覧のレスポンスを作成する
+func driveFilesJSON(t *testing.T, files []misskey.DriveFile) string {
+	t.Helper()
+	data, err := json.Marshal(files)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return string(data)
+}
+
+// deletedFileIDs drive/files/deleteで削除を要求したファイルのIDを返す
+func deletedFileIDs(t *testing.T, transport *httpclient.MockTransport) []string {
+	t.Helper()
+	var ids []string
+	for _, request := range transport.RequestsTo("drive/files/delete") {
+		var body map[string]any
+		if err := json.Unmarshal(request.Body, &body); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		ids = append(ids, body["fileId"].(string))
+	}
+	return ids
+}
+
+func TestDrivePrunerPrune(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	files := []misskey.DriveFile{
+		{ID: "file3", Name: "amesh.png", Size: 300, CreatedAt: now.Add(-24 * time.Hour)},
+		{ID: "file2", Name: "amesh.png", Size: 200, CreatedAt: now.Add(-10 * 24 * time.Hour)},
+		{ID: "file1", Name: "amesh.png", Size: 100, CreatedAt: now.Add(-40 * 24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name            string
+		setting         *misskey.DrivePrunerSetting
+		usage           string
+		deleteStatus    int
+		expected        *misskey.DrivePruneResult
+		expectError     bool
+		expectedDeletes []string
+	}{
+		{
+			name:            "保持期間を過ぎたファイルを削除",
+			setting:         &misskey.DrivePrunerSetting{Retention: 30 * 24 * time.Hour},
+			expected:        &misskey.DrivePruneResult{Deleted: 1, FreedBytes: 100},
+			expectedDeletes: []string{"file1"},
+		},
+		{
+			name:            "使用量が上限を下回るまで古いファイルから削除",
+			setting:         &misskey.DrivePrunerSetting{UsageLimit: 0.5},
+			usage:           `{"capacity":1000,"usage":700}`,
+			expected:        &misskey.DrivePruneResult{Deleted: 2, FreedBytes: 300},
+			expectedDeletes: []string{"file1", "file2"},
+		},
+		{
+			name:            "使用量が上限以下の場合は保持期間のみで削除",
+			setting:         &misskey.DrivePrunerSetting{Retention: 5 * 24 * time.Hour, UsageLimit: 0.8},
+			usage:           `{"capacity":1000,"usage":600}`,
+			expected:        &misskey.DrivePruneResult{Deleted: 2, FreedBytes: 300},
+			expectedDeletes: []string{"file1", "file2"},
+		},
+		{
+			name:     "削除するファイルがない",
+			setting:  &misskey.DrivePrunerSetting{Retention: 60 * 24 * time.Hour, UsageLimit: 0.8},
+			usage:    `{"capacity":1000,"usage":600}`,
+			expected: &misskey.DrivePruneResult{},
+		},
+		{
+			name:            "削除に失敗",
+			setting:         &misskey.DrivePrunerSetting{Retention: 30 * 24 * time.Hour},
+			deleteStatus:    http.StatusInternalServerError,
+			expected:        &misskey.DrivePruneResult{},
+			expectError:     true,
+			expectedDeletes: []string{"file1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "/api/drive/files/delete", Responses: []httpclient.MockResponse{{StatusCode: max(tt.deleteStatus, http.StatusNoContent)}}},
+					{Pattern: "/api/drive/files", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: driveFilesJSON(t, files)}}},
+					{Pattern: "/api/drive", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: tt.usage}}},
+				},
+			})
+			setting := *tt.setting
+			setting.Clock = clocktest.NewFake(now)
+			pruner := misskey.NewDrivePruner(misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain: "example.com",
+				Token:  "token",
+				Client: transport.Client(),
+			}), &setting)
+
+			result, err := pruner.Prune(t.Context())
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Prune() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("Prune() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedDeletes, deletedFileIDs(t, transport)); diff != "" {
+				t.Errorf("deleted files mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestDrivePrunerPrunePagination 1回で取得できない数のファイルを続けて取得し、最も古いファイルから削除することをテストする
+func TestDrivePrunerPrunePagination(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var firstPage []misskey.DriveFile
+	for i := range 100 {
+		firstPage = append(firstPage, misskey.DriveFile{ID: fmt.Sprintf("new%03d", i), Size: 10, CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	secondPage := []misskey.DriveFile{{ID: "oldest", Size: 10, CreatedAt: now.Add(-24 * time.Hour)}}
+
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "/api/drive/files/delete", Responses: []httpclient.MockResponse{{StatusCode: http.StatusNoContent}}},
+			{Pattern: "/api/drive/files", Responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: driveFilesJSON(t, firstPage)},
+				{StatusCode: http.StatusOK, Body: driveFilesJSON(t, secondPage)},
+			}},
+		},
+	})
+	pruner := misskey.NewDrivePruner(misskey.NewBotWithClient(&misskey.BotSetting{
+		Domain: "example.com",
+		Token:  "token",
+		Client: transport.Client(),
+	}), &misskey.DrivePrunerSetting{Retention: 12 * time.Hour, Clock: clocktest.NewFake(now)})
+
+	if _, err := pruner.Prune(t.Context()); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	lists := transport.RequestsTo("/api/drive/files")
+	var untilIDs []string
+	for _, request := range lists {
+		if strings.HasSuffix(request.URL, "/delete") {
+			continue
+		}
+		var body map[string]any
+		if err := json.Unmarshal(request.Body, &body); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		untilID, _ := body["untilId"].(string)
+		untilIDs = append(untilIDs, untilID)
+	}
+	if diff := cmp.Diff([]string{"", "new099"}, untilIDs); diff != "" {
+		t.Errorf("untilId mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"oldest"}, deletedFileIDs(t, transport)); diff != "" {
+		t.Errorf("deleted files mismatch (-want +got):\n%s", diff)
+	}
+}
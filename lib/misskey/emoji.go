@@ -0,0 +1,82 @@
+package misskey
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/bot"
+)
+
+// DefaultReactions NewPlatformで作成したPlatformが付けるリアクションの絵文字
+// 成功のリアクション（bot.ReactionSuccess）は設定した場合のみ付ける
+var DefaultReactions = map[bot.Reaction]string{
+	bot.ReactionProcessing: string(bot.ReactionProcessing),
+	bot.ReactionBusy:       string(bot.ReactionBusy),
+}
+
+// CustomEmoji インスタンスのカスタム絵文字
+type CustomEmoji struct {
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases"`
+	Category string   `json:"category"`
+}
+
+// FetchCustomEmojis インスタンスのカスタム絵文字の一覧を取得する（/api/emojis）
+func (bot *Bot) FetchCustomEmojis(ctx context.Context) ([]CustomEmoji, error) {
+	body, err := bot.apiRequest(ctx, "emojis", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
+
+	var response struct {
+		Emojis []CustomEmoji `json:"emojis"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return response.Emojis, nil
+}
+
+// customEmojiName カスタム絵文字（:hato:の形式）の名前を返す（カスタム絵文字でない場合は空）
+func customEmojiName(reaction string) string {
+	reaction = NormalizeReaction(reaction)
+	if len(reaction) <= 2 || !strings.HasPrefix(reaction, ":") || !strings.HasSuffix(reaction, ":") {
+		return ""
+	}
+	return reaction[1 : len(reaction)-1]
+}
+
+// VerifyReactions Reactionsのカスタム絵文字がインスタンスにあるか確認し、ない場合はリアクションのUnicodeの絵文字に置き換える
+// カスタム絵文字の一覧を取得できない場合は全てのカスタム絵文字を置き換えてエラーを返す
+func (p *Platform) VerifyReactions(ctx context.Context) error {
+	var custom []bot.Reaction
+	for reaction, emoji := range p.Reactions {
+		if customEmojiName(emoji) != "" {
+			custom = append(custom, reaction)
+		}
+	}
+	if len(custom) == 0 {
+		return nil
+	}
+
+	emojis, err := p.Bot.FetchCustomEmojis(ctx)
+	available := make(map[string]bool, len(emojis))
+	for _, emoji := range emojis {
+		available[emoji.Name] = true
+	}
+	for _, reaction := range custom {
+		emoji := p.Reactions[reaction]
+		if err == nil && available[customEmojiName(emoji)] {
+			continue
+		}
+		p.Bot.logger().Warn("Custom emoji is not available, using the fallback", "emoji", emoji, "fallback", string(reaction))
+		p.Reactions[reaction] = string(reaction)
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to FetchCustomEmojis")
+	}
+	return nil
+}
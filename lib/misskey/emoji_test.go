@@ -0,0 +1,76 @@
+package misskey_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/httpclient"
+)
+
+func TestPlatformVerifyReactions(t *testing.T) {
+	emojis := `{"emojis":[{"name":"hato","aliases":["bird"],"category":"animal"}]}`
+
+	tests := []struct {
+		name             string
+		reactions        map[bot.Reaction]string
+		status           int
+		expected         map[bot.Reaction]string
+		expectError      bool
+		expectedRequests int
+	}{
+		{
+			name:             "インスタンスにあるカスタム絵文字はそのまま使う",
+			reactions:        map[bot.Reaction]string{bot.ReactionProcessing: ":hato:", bot.ReactionSuccess: ":hato@.:"},
+			status:           http.StatusOK,
+			expected:         map[bot.Reaction]string{bot.ReactionProcessing: ":hato:", bot.ReactionSuccess: ":hato@.:"},
+			expectedRequests: 1,
+		},
+		{
+			name:             "インスタンスにないカスタム絵文字はUnicodeの絵文字に置き換える",
+			reactions:        map[bot.Reaction]string{bot.ReactionProcessing: ":hato:", bot.ReactionSuccess: ":done:"},
+			status:           http.StatusOK,
+			expected:         map[bot.Reaction]string{bot.ReactionProcessing: ":hato:", bot.ReactionSuccess: "✅"},
+			expectedRequests: 1,
+		},
+		{
+			name:      "カスタム絵文字がない場合は確認しない",
+			reactions: map[bot.Reaction]string{bot.ReactionProcessing: "🕊️"},
+			expected:  map[bot.Reaction]string{bot.ReactionProcessing: "🕊️"},
+		},
+		{
+			name:             "一覧の取得に失敗した場合はUnicodeの絵文字に置き換える",
+			reactions:        map[bot.Reaction]string{bot.ReactionProcessing: ":hato:", bot.ReactionSuccess: "👍"},
+			status:           http.StatusInternalServerError,
+			expected:         map[bot.Reaction]string{bot.ReactionProcessing: "👀", bot.ReactionSuccess: "👍"},
+			expectError:      true,
+			expectedRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "/api/emojis", Responses: []httpclient.MockResponse{{StatusCode: tt.status, Body: emojis}}},
+				},
+			})
+			platform := newTestPlatform(transport)
+			platform.Reactions = tt.reactions
+
+			err := platform.VerifyReactions(t.Context())
+			if (err != nil) != tt.expectError {
+				t.Fatalf("VerifyReactions() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(tt.expected, platform.Reactions); diff != "" {
+				t.Errorf("Reactions mismatch (-want +got):\n%s", diff)
+			}
+			if requests := transport.RequestsTo("/api/emojis"); len(requests) != tt.expectedRequests {
+				t.Errorf("emojis called %d times, want %d", len(requests), tt.expectedRequests)
+			}
+		})
+	}
+}
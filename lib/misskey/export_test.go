@@ -0,0 +1,8 @@
+package misskey
+
+import "github.com/gorilla/websocket"
+
+// SetConn 外部パッケージのテストからWebSocket接続を設定する
+func (bot *Bot) SetConn(conn *websocket.Conn) {
+	bot.setConn(conn)
+}
@@ -0,0 +1,156 @@
+package misskey
+
+import (
+	"sync"
+	"time"
+
+	"hato-bot-go/lib/metrics"
+)
+
+// LoopGuardの設定を省略した場合の既定値
+const (
+	DefaultMaxRepliesPerThread = 5         // 1つの会話スレッドに返信する上限
+	DefaultThreadWindow        = time.Hour // 会話スレッドへの返信数を数える期間
+)
+
+// SkipReason LoopGuardがノートに応答しない理由
+type SkipReason string
+
+const (
+	SkipNone        SkipReason = ""             // 応答する
+	SkipSelf        SkipReason = "self"         // ボット自身のノート
+	SkipBot         SkipReason = "bot"          // isBotのユーザーのノート
+	SkipRenote      SkipReason = "renote"       // 引用なしのリノート
+	SkipThreadLimit SkipReason = "thread_limit" // 会話スレッドへの返信数が上限に達した
+)
+
+// LoopGuardSetting LoopGuardの設定
+type LoopGuardSetting struct {
+	SelfID              string           // ボット自身のユーザーID（空の場合は自分のノートを判定しない）
+	ReplyToSelf         bool             // ボット自身のノートにも応答する
+	ReplyToBots         bool             // isBotのユーザーのノートにも応答する
+	ReplyToRenotes      bool             // 引用なしのリノートにも応答する
+	MaxRepliesPerThread int              // 1つの会話スレッドに期間内に返信する上限（0の場合はDefaultMaxRepliesPerThread、負の場合は上限なし）
+	ThreadWindow        time.Duration    // 会話スレッドへの返信数を数える期間（0以下の場合はDefaultThreadWindow）
+	Now                 func() time.Time // 現在時刻を返す関数（nilの場合はtime.Now）
+}
+
+// LoopGuard 他のボットとの返信の応酬などで返信がループしないよう、応答するノートを絞り込む
+// 会話スレッドはリプライ先をたどって判定し、スレッドごとに期間内の返信数を数える
+type LoopGuard struct {
+	setting LoopGuardSetting
+	mu      sync.Mutex
+	threads map[string]threadNote  // ノートIDから会話スレッド
+	replies map[string][]time.Time // 会話スレッドIDごとの返信した時刻
+}
+
+// threadNote ノートが属する会話スレッドと、そのノートを受信した時刻
+type threadNote struct {
+	threadID string
+	seen     time.Time
+}
+
+// NewLoopGuard 新しいLoopGuardを作成する
+func NewLoopGuard(setting *LoopGuardSetting) *LoopGuard {
+	g := &LoopGuard{
+		threads: make(map[string]threadNote),
+		replies: make(map[string][]time.Time),
+	}
+	if setting != nil {
+		g.setting = *setting
+	}
+	if g.setting.MaxRepliesPerThread == 0 {
+		g.setting.MaxRepliesPerThread = DefaultMaxRepliesPerThread
+	}
+	if g.setting.ThreadWindow <= 0 {
+		g.setting.ThreadWindow = DefaultThreadWindow
+	}
+	if g.setting.Now == nil {
+		g.setting.Now = time.Now
+	}
+	return g
+}
+
+// IsRenote 引用なしのリノートか（本文も添付ファイルもなく、リノート先だけがある）
+func (n *Note) IsRenote() bool {
+	return n.RenoteID != "" && n.Text == "" && len(n.FileIDs) == 0
+}
+
+// Check ノートに応答するかを判定し、応答しない場合はその理由を返す
+// 応答する場合は会話スレッドへの返信として数える（nilのLoopGuardは常に応答する）
+func (g *LoopGuard) Check(note *Note) SkipReason {
+	if g == nil || note == nil {
+		return SkipNone
+	}
+
+	reason := g.check(note)
+	if reason != SkipNone {
+		metrics.Default.Counter("misskey.loop_guard." + string(reason)).Inc()
+	}
+	return reason
+}
+
+// check 判定の本体
+func (g *LoopGuard) check(note *Note) SkipReason {
+	switch {
+	case !g.setting.ReplyToSelf && g.setting.SelfID != "" && note.User.ID == g.setting.SelfID:
+		return SkipSelf
+	case !g.setting.ReplyToBots && note.User.IsBot:
+		return SkipBot
+	case !g.setting.ReplyToRenotes && note.IsRenote():
+		return SkipRenote
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.setting.Now()
+	g.prune(now)
+
+	threadID := g.threadOf(note)
+	g.threads[note.ID] = threadNote{threadID: threadID, seen: now}
+	replies := g.replies[threadID]
+	if 0 < g.setting.MaxRepliesPerThread && g.setting.MaxRepliesPerThread <= len(replies) {
+		return SkipThreadLimit
+	}
+	g.replies[threadID] = append(replies, now)
+	return SkipNone
+}
+
+// threadOf ノートが属する会話スレッドのIDを返す
+// リプライ先か、その更にリプライ先（ボットの返信へのリプライの場合）が既知のスレッドに属していればそのスレッド、
+// それ以外はリプライ先のID（リプライでない場合は自身のID）を使う
+func (g *LoopGuard) threadOf(note *Note) string {
+	if known, ok := g.threads[note.ReplyID]; ok && note.ReplyID != "" {
+		return known.threadID
+	}
+	if note.Reply != nil && note.Reply.ReplyID != "" {
+		if known, ok := g.threads[note.Reply.ReplyID]; ok {
+			return known.threadID
+		}
+	}
+	if note.ReplyID != "" {
+		return note.ReplyID
+	}
+	return note.ID
+}
+
+// prune 期間外の返信と、期間外に受信したノートの記録を取り除く
+func (g *LoopGuard) prune(now time.Time) {
+	cutoff := now.Add(-g.setting.ThreadWindow)
+	for id, known := range g.threads {
+		if !known.seen.After(cutoff) {
+			delete(g.threads, id)
+		}
+	}
+	for threadID, replies := range g.replies {
+		for 0 < len(replies) && !replies[0].After(cutoff) {
+			replies = replies[1:]
+		}
+		if len(replies) == 0 {
+			delete(g.replies, threadID)
+			continue
+		}
+		g.replies[threadID] = replies
+	}
+}
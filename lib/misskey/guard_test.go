@@ -0,0 +1,151 @@
+package misskey_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/misskey"
+)
+
+// newGuardNote テスト用のノートを作成する
+func newGuardNote(id, userID, replyID string) *misskey.Note {
+	note := &misskey.Note{ID: id, Text: "@hato amesh", ReplyID: replyID}
+	note.User.ID = userID
+	return note
+}
+
+func TestLoopGuardCheck(t *testing.T) {
+	t.Parallel()
+	botNote := newGuardNote("note1", "otherbot", "")
+	botNote.User.IsBot = true
+	renote := &misskey.Note{ID: "note2", RenoteID: "note1"}
+	quote := &misskey.Note{ID: "note3", Text: "これ", RenoteID: "note1"}
+
+	tests := []struct {
+		name     string
+		setting  misskey.LoopGuardSetting
+		notes    []*misskey.Note
+		expected []misskey.SkipReason
+	}{
+		{
+			name:     "自分のノートには応答しない",
+			setting:  misskey.LoopGuardSetting{SelfID: "bot"},
+			notes:    []*misskey.Note{newGuardNote("note1", "bot", ""), newGuardNote("note2", "alice", "")},
+			expected: []misskey.SkipReason{misskey.SkipSelf, misskey.SkipNone},
+		},
+		{
+			name:     "設定すれば自分のノートにも応答する",
+			setting:  misskey.LoopGuardSetting{SelfID: "bot", ReplyToSelf: true},
+			notes:    []*misskey.Note{newGuardNote("note1", "bot", "")},
+			expected: []misskey.SkipReason{misskey.SkipNone},
+		},
+		{
+			name:     "ボットのノートには応答しない",
+			notes:    []*misskey.Note{botNote},
+			expected: []misskey.SkipReason{misskey.SkipBot},
+		},
+		{
+			name:     "設定すればボットのノートにも応答する",
+			setting:  misskey.LoopGuardSetting{ReplyToBots: true},
+			notes:    []*misskey.Note{botNote},
+			expected: []misskey.SkipReason{misskey.SkipNone},
+		},
+		{
+			name:     "引用なしのリノートには応答せず引用には応答する",
+			notes:    []*misskey.Note{renote, quote},
+			expected: []misskey.SkipReason{misskey.SkipRenote, misskey.SkipNone},
+		},
+		{
+			name:     "設定すればリノートにも応答する",
+			setting:  misskey.LoopGuardSetting{ReplyToRenotes: true},
+			notes:    []*misskey.Note{renote},
+			expected: []misskey.SkipReason{misskey.SkipNone},
+		},
+		{
+			name:    "同じ会話スレッドへの返信は上限まで",
+			setting: misskey.LoopGuardSetting{MaxRepliesPerThread: 2},
+			notes: []*misskey.Note{
+				newGuardNote("note1", "alice", ""),
+				newGuardNote("note2", "alice", "note1"),
+				newGuardNote("note3", "alice", "note2"),
+				newGuardNote("note4", "bob", ""),
+			},
+			expected: []misskey.SkipReason{misskey.SkipNone, misskey.SkipNone, misskey.SkipThreadLimit, misskey.SkipNone},
+		},
+		{
+			name:    "ボットの返信へのリプライも同じ会話スレッドとして数える",
+			setting: misskey.LoopGuardSetting{MaxRepliesPerThread: 1},
+			notes: []*misskey.Note{
+				newGuardNote("note1", "alice", ""),
+				func() *misskey.Note {
+					note := newGuardNote("note3", "alice", "reply1")
+					note.Reply = &misskey.Note{ID: "reply1", ReplyID: "note1"}
+					return note
+				}(),
+			},
+			expected: []misskey.SkipReason{misskey.SkipNone, misskey.SkipThreadLimit},
+		},
+		{
+			name:    "負の上限は制限しない",
+			setting: misskey.LoopGuardSetting{MaxRepliesPerThread: -1},
+			notes: []*misskey.Note{
+				newGuardNote("note1", "alice", ""),
+				newGuardNote("note2", "alice", "note1"),
+				newGuardNote("note3", "alice", "note2"),
+				newGuardNote("note4", "alice", "note3"),
+				newGuardNote("note5", "alice", "note4"),
+				newGuardNote("note6", "alice", "note5"),
+			},
+			expected: []misskey.SkipReason{
+				misskey.SkipNone, misskey.SkipNone, misskey.SkipNone, misskey.SkipNone, misskey.SkipNone, misskey.SkipNone,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			guard := misskey.NewLoopGuard(&tt.setting)
+			var result []misskey.SkipReason
+			for _, note := range tt.notes {
+				result = append(result, guard.Check(note))
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("Check() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoopGuardThreadWindow(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard := misskey.NewLoopGuard(&misskey.LoopGuardSetting{
+		MaxRepliesPerThread: 1,
+		ThreadWindow:        time.Minute,
+		Now:                 func() time.Time { return now },
+	})
+
+	if reason := guard.Check(newGuardNote("note1", "alice", "")); reason != misskey.SkipNone {
+		t.Fatalf("Check() = %q, want none", reason)
+	}
+	if reason := guard.Check(newGuardNote("note2", "alice", "note1")); reason != misskey.SkipThreadLimit {
+		t.Fatalf("Check() = %q, want %q", reason, misskey.SkipThreadLimit)
+	}
+
+	// 期間が過ぎると同じ会話スレッドにも再び返信する
+	now = now.Add(2 * time.Minute)
+	if reason := guard.Check(newGuardNote("note3", "alice", "note1")); reason != misskey.SkipNone {
+		t.Errorf("Check() = %q, want none", reason)
+	}
+}
+
+func TestLoopGuardNil(t *testing.T) {
+	t.Parallel()
+	var guard *misskey.LoopGuard
+	if reason := guard.Check(newGuardNote("note1", "alice", "")); reason != misskey.SkipNone {
+		t.Errorf("Check() = %q, want none", reason)
+	}
+}
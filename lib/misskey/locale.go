@@ -0,0 +1,60 @@
+package misskey
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/i18n"
+)
+
+// ErrInvalidUserLocale ユーザーごとの言語の設定値が不正であることを表すエラー
+var ErrInvalidUserLocale = errors.New("invalid user locale")
+
+// acct ユーザー名とホストからアカウント名（ローカルはusername、リモートはusername@host）を返す
+func acct(username, host string) string {
+	if host == "" {
+		return username
+	}
+	return username + "@" + host
+}
+
+// LocaleFor ユーザーへの返信に使う言語を返す
+// ユーザーごとの設定がない場合はインスタンス全体の言語を使う
+func (bot *Bot) LocaleFor(username, host string) i18n.Locale {
+	if locale, ok := bot.BotSetting.UserLocales[acct(username, host)]; ok {
+		return locale
+	}
+	if bot.BotSetting.Locale == "" {
+		return i18n.DefaultLocale
+	}
+	return bot.BotSetting.Locale
+}
+
+// TemplateDataFor ユーザーへの返信テンプレートに渡す変数を返す
+func (bot *Bot) TemplateDataFor(username, host string) *i18n.TemplateData {
+	return &i18n.TemplateData{
+		Locale: bot.LocaleFor(username, host),
+		User:   acct(username, host),
+	}
+}
+
+// ParseUserLocales ユーザーごとの言語の設定文字列を解析する
+// 設定は「アカウント名:言語」をカンマ区切りで並べたもの（例: alice:en,bob@example.com:ja）
+func ParseUserLocales(s string) (map[string]i18n.Locale, error) {
+	locales := make(map[string]i18n.Locale)
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		account, locale, ok := strings.Cut(entry, ":")
+		account = strings.TrimPrefix(strings.TrimSpace(account), "@")
+		if !ok || account == "" {
+			return nil, errors.Wrapf(ErrInvalidUserLocale, "entry: %s", entry)
+		}
+		locales[account] = i18n.ParseLocale(locale)
+	}
+	return locales, nil
+}
@@ -0,0 +1,107 @@
+package misskey_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/misskey"
+)
+
+func TestLocaleFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		locale      i18n.Locale
+		userLocales map[string]i18n.Locale
+		username    string
+		host        string
+		expected    i18n.Locale
+	}{
+		{
+			name:     "未設定の場合はデフォルトの言語",
+			username: "alice",
+			expected: i18n.DefaultLocale,
+		},
+		{
+			name:     "インスタンス全体の言語",
+			locale:   i18n.LocaleEn,
+			username: "alice",
+			expected: i18n.LocaleEn,
+		},
+		{
+			name:        "ローカルユーザーの言語",
+			locale:      i18n.LocaleJa,
+			userLocales: map[string]i18n.Locale{"alice": i18n.LocaleEn},
+			username:    "alice",
+			expected:    i18n.LocaleEn,
+		},
+		{
+			name:        "リモートユーザーはホストを含めて判定",
+			locale:      i18n.LocaleJa,
+			userLocales: map[string]i18n.Locale{"alice": i18n.LocaleEn},
+			username:    "alice",
+			host:        "remote.example.com",
+			expected:    i18n.LocaleJa,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain:      "example.com",
+				Token:       "token",
+				Client:      http.DefaultClient,
+				Locale:      tt.locale,
+				UserLocales: tt.userLocales,
+			})
+			if result := bot.LocaleFor(tt.username, tt.host); result != tt.expected {
+				t.Errorf("LocaleFor() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseUserLocales(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    map[string]i18n.Locale
+		expectError error
+	}{
+		{
+			name:     "空文字列",
+			input:    "",
+			expected: map[string]i18n.Locale{},
+		},
+		{
+			name:  "ローカルとリモートのユーザー",
+			input: "alice:en, @bob@remote.example.com:ja-JP",
+			expected: map[string]i18n.Locale{
+				"alice":                  i18n.LocaleEn,
+				"bob@remote.example.com": i18n.LocaleJa,
+			},
+		},
+		{
+			name:        "言語がない",
+			input:       "alice",
+			expectError: misskey.ErrInvalidUserLocale,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := misskey.ParseUserLocales(tt.input)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ParseUserLocales() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseUserLocales() diff: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package misskey
+
+import "log/slog"
+
+// Logger ボットの動作を記録するログの出力先（*slog.Loggerなど）
+// argsはslogと同じくキーと値を交互に並べる
+type Logger interface {
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// logger 設定されたログの出力先を返す（未設定の場合はslog.Default）
+func (bot *Bot) logger() Logger {
+	if bot.BotSetting == nil || bot.BotSetting.Logger == nil {
+		return slog.Default()
+	}
+	return bot.BotSetting.Logger
+}
@@ -0,0 +1,163 @@
+package misskey
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrChatUnsupported インスタンスがチャットに対応していないことを表すエラー
+var ErrChatUnsupported = errors.New("chat is not supported by the instance")
+
+// DefaultMaxNoteTextLength インスタンスの情報を取得する前や取得できなかった場合に使うノートの本文の文字数の上限（Misskeyの既定値）
+const DefaultMaxNoteTextLength = 3000
+
+// legacyReaction 任意の絵文字でリアクションできないインスタンスで代わりに使うリアクション
+const legacyReaction = "like"
+
+// modernMajorVersion 任意の絵文字でのリアクションとlocalOnlyに対応したMisskeyのメジャーバージョン
+const modernMajorVersion = 12
+
+// chatVersion チャットのAPI（chat/messages/create-to-user）が追加されたMisskeyのバージョン
+var chatVersion = []int{2025, 4, 0}
+
+// InstanceMeta Misskeyインスタンスの情報（/api/meta）
+type InstanceMeta struct {
+	Name                string         `json:"name"`
+	Version             string         `json:"version"`
+	BasedMisskeyVersion string         `json:"basedMisskeyVersion,omitempty"` // フォークが基にしたMisskeyのバージョン（CherryPickなど）
+	Features            map[string]any `json:"features,omitempty"`            // インスタンスで有効な機能
+	MaxNoteTextLength   int            `json:"maxNoteTextLength,omitempty"`   // ノートの本文の文字数の上限
+}
+
+// Capabilities インスタンスが対応している機能
+// 対応していない機能は使わずに代わりの動作をする
+type Capabilities struct {
+	EmojiReactions bool // 任意の絵文字でリアクションできるか（できない場合は"like"でリアクションする）
+	LocalOnly      bool // ノートにlocalOnlyを指定できるか（できない場合は指定せずに投稿する）
+	Chat           bool // チャットのAPIがあるか（ない場合はErrChatUnsupportedを返す）
+}
+
+// allCapabilities バージョンを判定できない場合や確認する前に使う、全ての機能に対応している前提の値
+var allCapabilities = Capabilities{EmojiReactions: true, LocalOnly: true, Chat: true}
+
+// Capabilities バージョンから対応している機能を判定する
+// フォークはbasedMisskeyVersionを優先し、バージョンを解釈できない場合は全ての機能に対応しているとみなす
+func (m *InstanceMeta) Capabilities() Capabilities {
+	version := m.BasedMisskeyVersion
+	if version == "" {
+		version = m.Version
+	}
+	parts, ok := parseVersion(version)
+	if !ok {
+		return allCapabilities
+	}
+	modern := modernMajorVersion <= parts[0]
+	return Capabilities{
+		EmojiReactions: modern,
+		LocalOnly:      modern,
+		Chat:           0 <= compareVersion(parts, chatVersion),
+	}
+}
+
+// parseVersion 「2025.4.1-beta.0」のようなバージョンを数値の並びに変換する
+// 数値でない部分がある場合はfalseを返す
+func parseVersion(version string) ([]int, bool) {
+	version, _, _ = strings.Cut(version, "-")
+	version, _, _ = strings.Cut(version, "+")
+	if version == "" {
+		return nil, false
+	}
+	fields := strings.Split(version, ".")
+	parts := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// compareVersion バージョンの数値の並びを比較する（足りない桁は0とみなす）
+func compareVersion(a, b []int) int {
+	for i := range max(len(a), len(b)) {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// FetchMeta インスタンスの情報を取得する（/api/meta）
+func (bot *Bot) FetchMeta(ctx context.Context) (*InstanceMeta, error) {
+	body, err := bot.apiRequest(ctx, "meta", map[string]any{"detail": false})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
+
+	var meta InstanceMeta
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return &meta, nil
+}
+
+// DetectCapabilities インスタンスの情報を取得し、対応している機能を以降のAPI呼び出しに使う
+// 起動時に1回呼び出す。取得に失敗した場合は全ての機能に対応している前提のまま動作する
+func (bot *Bot) DetectCapabilities(ctx context.Context) (*InstanceMeta, error) {
+	meta, err := bot.FetchMeta(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to FetchMeta")
+	}
+	capabilities := meta.Capabilities()
+	bot.capabilities.Store(&capabilities)
+	bot.maxNoteTextLength.Store(int64(meta.MaxNoteTextLength))
+	bot.logger().Info("Detected instance capabilities",
+		"version", meta.Version,
+		"emojiReactions", capabilities.EmojiReactions,
+		"localOnly", capabilities.LocalOnly,
+		"chat", capabilities.Chat,
+		"maxNoteTextLength", bot.MaxNoteTextLength(),
+	)
+	return meta, nil
+}
+
+// Capabilities DetectCapabilitiesで判定した機能を返す（呼び出す前は全ての機能に対応している前提の値）
+func (bot *Bot) Capabilities() Capabilities {
+	if capabilities := bot.capabilities.Load(); capabilities != nil {
+		return *capabilities
+	}
+	return allCapabilities
+}
+
+// MaxNoteTextLength DetectCapabilitiesで取得したノートの本文の文字数の上限を返す
+// 呼び出す前や、インスタンスが上限を返さなかった場合はDefaultMaxNoteTextLength
+func (bot *Bot) MaxNoteTextLength() int {
+	if length := bot.maxNoteTextLength.Load(); 0 < length {
+		return int(length)
+	}
+	return DefaultMaxNoteTextLength
+}
+
+// supportedReaction インスタンスで使えるリアクションを返す
+func (bot *Bot) supportedReaction(reaction string) string {
+	if bot.Capabilities().EmojiReactions {
+		return reaction
+	}
+	return legacyReaction
+}
@@ -0,0 +1,148 @@
+package misskey_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+func TestInstanceMetaCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     *misskey.InstanceMeta
+		expected misskey.Capabilities
+	}{
+		{
+			name:     "チャットに対応したバージョン",
+			meta:     &misskey.InstanceMeta{Version: "2025.4.0"},
+			expected: misskey.Capabilities{EmojiReactions: true, LocalOnly: true, Chat: true},
+		},
+		{
+			name:     "プレリリースのバージョン",
+			meta:     &misskey.InstanceMeta{Version: "2025.10.1-beta.2"},
+			expected: misskey.Capabilities{EmojiReactions: true, LocalOnly: true, Chat: true},
+		},
+		{
+			name:     "チャットがないバージョン",
+			meta:     &misskey.InstanceMeta{Version: "2024.11.0"},
+			expected: misskey.Capabilities{EmojiReactions: true, LocalOnly: true},
+		},
+		{
+			name:     "任意の絵文字でリアクションできないバージョン",
+			meta:     &misskey.InstanceMeta{Version: "11.37.1"},
+			expected: misskey.Capabilities{},
+		},
+		{
+			name:     "フォークは基にしたMisskeyのバージョンで判定",
+			meta:     &misskey.InstanceMeta{Version: "4.11.1", BasedMisskeyVersion: "2024.5.0"},
+			expected: misskey.Capabilities{EmojiReactions: true, LocalOnly: true},
+		},
+		{
+			name:     "解釈できないバージョンは全ての機能に対応しているとみなす",
+			meta:     &misskey.InstanceMeta{Version: "unknown"},
+			expected: misskey.Capabilities{EmojiReactions: true, LocalOnly: true, Chat: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, tt.meta.Capabilities()); diff != "" {
+				t.Errorf("Capabilities() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestDetectCapabilities 判定した機能に合わせてリアクション・localOnly・チャットの動作を変えることを確認する
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		name              string
+		metaBody          string
+		expectedReaction  string
+		expectedLocalOnly bool
+		expectedChatError error
+	}{
+		{
+			name:              "判定前と同じく全ての機能を使う",
+			metaBody:          `{"name":"example","version":"2025.4.0","features":{"localTimeline":true}}`,
+			expectedReaction:  "👀",
+			expectedLocalOnly: true,
+		},
+		{
+			name:              "古いバージョンでは代わりの動作にする",
+			metaBody:          `{"name":"example","version":"11.37.1"}`,
+			expectedReaction:  "like",
+			expectedChatError: misskey.ErrChatUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "/api/meta", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: tt.metaBody}}},
+				},
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+			})
+			b, err := misskey.NewBot("example.com", "token", misskey.WithHTTPClient(transport.Client()))
+			if err != nil {
+				t.Fatalf("NewBot() error = %v", err)
+			}
+
+			if _, err := b.DetectCapabilities(t.Context()); err != nil {
+				t.Fatalf("DetectCapabilities() error = %v", err)
+			}
+			if err := b.AddReaction(t.Context(), "note123", "👀"); err != nil {
+				t.Fatalf("AddReaction() error = %v", err)
+			}
+			if err := b.PostNote(t.Context(), &misskey.PostNoteParams{Text: "地震情報", LocalOnly: true}); err != nil {
+				t.Fatalf("PostNote() error = %v", err)
+			}
+			err = b.SendChatMessage(t.Context(), &misskey.SendChatMessageParams{ToUserID: "user1", Text: "こんにちは"})
+			if !errors.Is(err, tt.expectedChatError) {
+				t.Errorf("SendChatMessage() error = %v, want %v", err, tt.expectedChatError)
+			}
+
+			var reaction struct {
+				Reaction string `json:"reaction"`
+			}
+			if err := json.Unmarshal(transport.RequestsTo("notes/reactions/create")[0].Body, &reaction); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if reaction.Reaction != tt.expectedReaction {
+				t.Errorf("reaction = %q, want %q", reaction.Reaction, tt.expectedReaction)
+			}
+			var note struct {
+				LocalOnly bool `json:"localOnly"`
+			}
+			if err := json.Unmarshal(transport.RequestsTo("notes/create")[0].Body, &note); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if note.LocalOnly != tt.expectedLocalOnly {
+				t.Errorf("localOnly = %v, want %v", note.LocalOnly, tt.expectedLocalOnly)
+			}
+		})
+	}
+}
+
+func TestDetectCapabilitiesFailure(t *testing.T) {
+	t.Parallel()
+	b, err := misskey.NewBot("example.com", "token", misskey.WithHTTPClient(httpclient.NewMockHTTPClient(http.StatusInternalServerError, "")))
+	if err != nil {
+		t.Fatalf("NewBot() error = %v", err)
+	}
+	if _, err := b.DetectCapabilities(t.Context()); !errors.Is(err, httpclient.ErrHTTPRequestError) {
+		t.Errorf("DetectCapabilities() error = %v, want %v", err, httpclient.ErrHTTPRequestError)
+	}
+	if diff := cmp.Diff(misskey.Capabilities{EmojiReactions: true, LocalOnly: true, Chat: true}, b.Capabilities()); diff != "" {
+		t.Errorf("Capabilities() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -2,16 +2,109 @@ package misskey
 
 import (
 	"net/http"
+	"slices"
 	"time"
 
-	"hato-bot-go/lib"
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+var (
+	// ErrInvalidReplyPolicy 返信方針の設定値が不正であることを表すエラー
+	ErrInvalidReplyPolicy = errors.New("invalid reply policy")
+	// ErrInvalidBotSetting Botの作成に必要な設定がないか、設定値が不正であることを表すエラー
+	ErrInvalidBotSetting = errors.New("invalid bot setting")
+)
+
+// ReplyMode 返信ノートの作り方
+type ReplyMode string
+
+const (
+	ReplyModeReply ReplyMode = "reply" // 元ノートへのリプライ（replyId）
+	ReplyModeQuote ReplyMode = "quote" // 元ノートの引用（renoteId）
 )
 
+// CWMode 返信ノートのCWの付け方
+type CWMode string
+
+const (
+	CWModeReplace CWMode = "replace" // CW付きの元ノートには決まった文言のCWで返信する
+	CWModeMirror  CWMode = "mirror"  // CW付きの元ノートには元ノートと同じ文言のCWで返信する
+	CWModeWeather CWMode = "weather" // 画像を添付する返信は元ノートによらず天気画像のCWを付け、それ以外はreplaceと同じ
+)
+
+// cwModes 指定できるCWの付け方
+var cwModes = []CWMode{CWModeReplace, CWModeMirror, CWModeWeather}
+
+// visibilities Misskeyのノートの公開範囲
+var visibilities = []string{"public", "home", "followers", "specified"}
+
+// ReplyPolicy 返信ノートの作成方針
+type ReplyPolicy struct {
+	Mode            ReplyMode        // 返信方法（空の場合はリプライ）
+	Visibility      string           // 強制する公開範囲（空の場合は公開範囲の規則に従う）
+	VisibilityRules []VisibilityRule // 公開範囲の規則（最初に一致したものを使う、nilの場合はDefaultVisibilityRules）
+	LocalOnly       bool             // 連合せずにローカルのみに投稿する
+	CW              CWMode           // CWの付け方（空の場合はreplace）
+	CWText          string           // replaceで付けるCWの文言（空の場合は返信テンプレートreply.cw）
+}
+
+// Validate 返信方針の設定値を検証する
+func (p ReplyPolicy) Validate() error {
+	if p.Mode != "" && p.Mode != ReplyModeReply && p.Mode != ReplyModeQuote {
+		return errors.Wrapf(ErrInvalidReplyPolicy, "mode: %s", p.Mode)
+	}
+	if p.Visibility != "" && !slices.Contains(visibilities, p.Visibility) {
+		return errors.Wrapf(ErrInvalidReplyPolicy, "visibility: %s", p.Visibility)
+	}
+	for _, rule := range p.VisibilityRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.CW != "" && !slices.Contains(cwModes, p.CW) {
+		return errors.Wrapf(ErrInvalidReplyPolicy, "cw: %s", p.CW)
+	}
+	return nil
+}
+
+// Override overrideで設定されている項目を上書きした返信方針を返す
+func (p ReplyPolicy) Override(override ReplyPolicy) ReplyPolicy {
+	if override.Mode != "" {
+		p.Mode = override.Mode
+	}
+	if override.Visibility != "" {
+		p.Visibility = override.Visibility
+	}
+	if override.VisibilityRules != nil {
+		p.VisibilityRules = override.VisibilityRules
+	}
+	p.LocalOnly = p.LocalOnly || override.LocalOnly
+	if override.CW != "" {
+		p.CW = override.CW
+	}
+	if override.CWText != "" {
+		p.CWText = override.CWText
+	}
+	return p
+}
+
 // BotSetting Misskeyボットの設定
 type BotSetting struct {
-	Domain string       // Misskeyのドメイン
-	Token  string       // APIトークン
-	Client *http.Client // HTTPクライアント
+	Domain               string                 // Misskeyのドメイン
+	Token                string                 // APIトークン
+	Client               httpclient.Doer        // HTTPクライアント（*http.Clientなど）
+	ReplyPolicy          ReplyPolicy            // インスタンス全体の返信方針
+	CommandReplyPolicies map[string]ReplyPolicy // コマンド名ごとの返信方針（インスタンス全体の方針を上書きする）
+	TimelineChannels     []TimelineChannel      // メンションなしでも応答するタイムラインチャンネル
+	ReactionTriggers     []ReactionTrigger      // ボットのノートへのリアクションで実行するコマンド
+	Locale               i18n.Locale            // 返信メッセージの言語（空の場合はi18n.DefaultLocale）
+	UserLocales          map[string]i18n.Locale // アカウント名ごとの返信メッセージの言語
+	Templates            *i18n.Templates        // 返信テンプレート（nilの場合はメッセージカタログの文言）
+	Logger               Logger                 // ログの出力先（nilの場合はslog.Default）
+	RequestInterval      time.Duration          // APIリクエストの最短の間隔（0の場合は制限しない）
 }
 
 // Note Misskeyのノート構造体
@@ -21,19 +114,37 @@ type Note struct {
 	Visibility string   `json:"visibility,omitempty"`
 	FileIDs    []string `json:"fileIds,omitempty"`
 	ReplyID    string   `json:"replyId,omitempty"`
+	Reply      *Note    `json:"reply,omitempty"`
+	RenoteID   string   `json:"renoteId,omitempty"`
+	Renote     *Note    `json:"renote,omitempty"`
 	CW         *string  `json:"cw,omitempty"`
-	User       struct {
-		ID       string `json:"id"`
-		Username string `json:"username"`
-		Host     string `json:"host,omitempty"`
-	} `json:"user"`
+	LocalOnly  bool     `json:"localOnly,omitempty"`
+	User       User     `json:"user"`
+}
+
+// User Misskeyのユーザー構造体
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Host     string `json:"host,omitempty"`
+	IsBot    bool   `json:"isBot,omitempty"`
 }
 
 // CreateNoteParams ノート作成のリクエスト構造体
 type CreateNoteParams struct {
-	Text         string   // ノートのテキスト
-	FileIDs      []string // 添付ファイルのID一覧
-	OriginalNote *Note    // 返信元のノート
+	Text          string       // ノートのテキスト
+	FileIDs       []string     // 添付ファイルのID一覧
+	OriginalNote  *Note        // 返信元のノート
+	Policy        *ReplyPolicy // 返信方針（nilの場合はインスタンス全体の方針）
+	ThreadReplyID string       // 長い返信を分割したスレッドの続きとしてリプライするノートのID（公開範囲やCWはOriginalNoteへの返信と同じ）
+}
+
+// PostNoteParams 返信ではないノートの作成のリクエスト構造体
+type PostNoteParams struct {
+	Text       string   // ノートのテキスト
+	FileIDs    []string // 添付ファイルのID一覧
+	Visibility string   // 公開範囲（空の場合はhome）
+	LocalOnly  bool     // ローカルのみに投稿するか
 }
 
 // File アップロードされたファイルの構造体
@@ -43,13 +154,10 @@ type File struct {
 	URL  string `json:"url"`
 }
 
-type ProcessAmeshCommandParams struct {
-	Note          *Note
-	Place         string
-	YahooAPIToken string
-}
-
 // NewBotWithClient HTTPクライアント注入可能なBotインスタンスを作成
+// botSettingかClientがnilの場合はnilを返す
+//
+// Deprecated: NewBotとWithHTTPClientを使う
 func NewBotWithClient(botSetting *BotSetting) *Bot {
 	if botSetting == nil {
 		return nil
@@ -59,15 +167,34 @@ func NewBotWithClient(botSetting *BotSetting) *Bot {
 	}
 	return &Bot{
 		BotSetting: botSetting,
-		UserAgent:  "hato-bot-go/" + lib.Version,
 	}
 }
 
-// NewBot 新しいBotインスタンスを作成
-func NewBot(domain, token string) *Bot {
-	return NewBotWithClient(&BotSetting{
-		Domain: domain,
-		Token:  token,
-		Client: &http.Client{Timeout: 30 * time.Second},
-	})
+// NewBot 新しいBotインスタンスを作成する
+// 既定のHTTPクライアントを使う場合は、Misskeyインスタンスのホストをサーキットブレーカーの外部サービスとして登録する
+// ドメインかAPIトークンが空の場合や、オプションの値が不正な場合はErrInvalidBotSettingを返す
+func NewBot(domain, token string, opts ...Option) (*Bot, error) {
+	if domain == "" || token == "" {
+		return nil, errors.Wrap(ErrInvalidBotSetting, "domain and token are required")
+	}
+	b := &Bot{
+		BotSetting: &BotSetting{
+			Domain: domain,
+			Token:  token,
+		},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.BotSetting.RequestInterval < 0 {
+		return nil, errors.Wrapf(ErrInvalidBotSetting, "rate limit interval: %s", b.BotSetting.RequestInterval)
+	}
+	if b.BotSetting.Client == nil {
+		httpclient.RegisterUpstream(domain, httpclient.UpstreamMisskey)
+		// 制限時間はAPIとアップロードでそれぞれDefaultTransportが適用する
+		b.BotSetting.Client = &http.Client{
+			Transport: httpclient.DefaultTransport,
+		}
+	}
+	return b, nil
 }
@@ -0,0 +1,378 @@
+package misskeytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"hato-bot-go/lib/misskey"
+)
+
+// waitTimeout WaitNotesなどで期待する状態になるまで待つ時間の上限
+const waitTimeout = 5 * time.Second
+
+// maxUploadBytes drive/files/createで受け付けるファイルの大きさの上限
+const maxUploadBytes = 32 << 20
+
+// Call 偽のサーバーが受け取ったAPIの呼び出し
+type Call struct {
+	Endpoint string         // /api/以下のエンドポイント（notes/createなど）
+	Body     map[string]any // JSONのリクエストボディ（drive/files/createではファイル以外のフィールド）
+}
+
+// CreatedNote notes/createで作成されたノート
+type CreatedNote struct {
+	ID             string   `json:"-"`
+	Text           string   `json:"text"`
+	Visibility     string   `json:"visibility"`
+	ReplyID        string   `json:"replyId"`
+	RenoteID       string   `json:"renoteId"`
+	FileIDs        []string `json:"fileIds"`
+	VisibleUserIDs []string `json:"visibleUserIds"`
+	LocalOnly      bool     `json:"localOnly"`
+}
+
+// UploadedFile drive/files/createでアップロードされたファイル
+type UploadedFile struct {
+	ID   string // ファイルID
+	Name string // ファイル名
+	Data []byte // ファイルの内容
+}
+
+// Reaction notes/reactions/createで付けられたリアクション
+type Reaction struct {
+	NoteID   string `json:"noteId"`
+	Reaction string `json:"reaction"`
+}
+
+// stream ストリーミングの接続と接続済みのチャンネル
+type stream struct {
+	mu       sync.Mutex // connへの書き込みを保護する
+	conn     *websocket.Conn
+	channels map[string]string // 接続IDごとのチャンネル名
+}
+
+// Server notes/create、drive/files/create、notes/reactions/createとストリーミングを模したMisskeyの偽のサーバー
+// NewBotで作成したBotはこのサーバーのAPIとストリーミングに接続する
+// 上記以外のAPIは呼び出しを記録して空のJSONオブジェクトを返す
+type Server struct {
+	*httptest.Server
+
+	tb       testing.TB
+	token    string
+	upgrader websocket.Upgrader
+
+	mu        sync.Mutex
+	nextID    int
+	calls     []Call
+	notes     []CreatedNote
+	files     []UploadedFile
+	reactions []Reaction
+	streams   []*stream
+}
+
+// NewServer APIトークンがtokenのMisskeyの偽のサーバーをTLSで起動し、テストの終了時に停止する
+func NewServer(t testing.TB, token string) *Server {
+	t.Helper()
+	s := &Server{tb: t, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", s.serveAPI)
+	mux.HandleFunc("/streaming", s.serveStreaming)
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Domain BotSetting.Domainに指定するホスト名とポート
+func (s *Server) Domain() string {
+	u, _ := url.Parse(s.URL)
+	return u.Host
+}
+
+// NewBot このサーバーに接続するBotを作成する
+func (s *Server) NewBot() *misskey.Bot {
+	client := s.Client()
+	b, err := misskey.NewBot(s.Domain(), s.token, misskey.WithHTTPClient(client))
+	if err != nil {
+		s.tb.Fatalf("misskey.NewBot() error = %v", err)
+	}
+	b.Dialer = &websocket.Dialer{TLSClientConfig: client.Transport.(*http.Transport).TLSClientConfig}
+	return b
+}
+
+// Calls 受け取ったAPIの呼び出しのうちendpointへのものを返す（空の場合は全て）
+func (s *Server) Calls(endpoint string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, call := range s.calls {
+		if endpoint == "" || call.Endpoint == endpoint {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Notes 作成されたノートを作成された順に返す
+func (s *Server) Notes() []CreatedNote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.notes)
+}
+
+// Files アップロードされたファイルをアップロードされた順に返す
+func (s *Server) Files() []UploadedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.files)
+}
+
+// Reactions 付けられたリアクションを付けられた順に返す
+func (s *Server) Reactions() []Reaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.reactions)
+}
+
+// WaitNotes ノートがn件以上作成されるまで待ち、作成されたノートを返す
+func (s *Server) WaitNotes(t testing.TB, n int) []CreatedNote {
+	t.Helper()
+	s.waitFor(t, fmt.Sprintf("%d notes", n), func() bool { return n <= len(s.Notes()) })
+	return s.Notes()
+}
+
+// Mention ストリーミングのmainチャンネルに接続しているBotにメンションを送る
+// mainチャンネルに接続するまで待つ
+func (s *Server) Mention(t testing.TB, note *misskey.Note) {
+	t.Helper()
+	s.Publish(t, "main", "mention", note)
+}
+
+// Publish ストリーミングでchannelに接続しているBotにイベントを送る
+// channelに接続するまで待つ
+func (s *Server) Publish(t testing.TB, channel, eventType string, body any) {
+	t.Helper()
+	var targets []*stream
+	s.waitFor(t, "connection to "+channel, func() bool {
+		targets = s.streamsOn(channel)
+		return 0 < len(targets)
+	})
+
+	for _, target := range targets {
+		target.mu.Lock()
+		for id, name := range target.channels {
+			if name != channel {
+				continue
+			}
+			message := map[string]any{
+				"type": "channel",
+				"body": map[string]any{"id": id, "type": eventType, "body": body},
+			}
+			if err := target.conn.WriteJSON(message); err != nil {
+				target.mu.Unlock()
+				t.Fatalf("WriteJSON() error = %v", err)
+			}
+		}
+		target.mu.Unlock()
+	}
+}
+
+// CloseStreams 全てのストリーミングの接続を切断する（再接続のテスト用）
+func (s *Server) CloseStreams() {
+	s.mu.Lock()
+	streams := slices.Clone(s.streams)
+	s.mu.Unlock()
+	for _, target := range streams {
+		_ = target.conn.Close()
+	}
+}
+
+// streamsOn channelに接続しているストリーミングの接続を返す
+func (s *Server) streamsOn(channel string) []*stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var streams []*stream
+	for _, target := range s.streams {
+		target.mu.Lock()
+		for _, name := range target.channels {
+			if name == channel {
+				streams = append(streams, target)
+				break
+			}
+		}
+		target.mu.Unlock()
+	}
+	return streams
+}
+
+// waitFor conditionがtrueになるまで待つ（waitTimeoutを過ぎた場合はテストを失敗させる）
+func (s *Server) waitFor(t testing.TB, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s (calls: %v)", what, s.Calls(""))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newID 作成したノートやファイルのIDを払い出す
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s%d", prefix, s.nextID)
+}
+
+// serveAPI APIの呼び出しを記録し、エンドポイントに応じたレスポンスを返す
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	endpoint := strings.TrimPrefix(r.URL.Path, "/api/")
+	if endpoint == "drive/files/create" {
+		s.serveUpload(w, r)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAM")
+		return
+	}
+	if body["i"] != s.token {
+		writeError(w, http.StatusUnauthorized, "CREDENTIAL_REQUIRED")
+		return
+	}
+	delete(body, "i")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Endpoint: endpoint, Body: body})
+
+	switch endpoint {
+	case "notes/create":
+		var note CreatedNote
+		if err := json.Unmarshal(data, &note); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_PARAM")
+			return
+		}
+		note.ID = s.newID("note")
+		s.notes = append(s.notes, note)
+		writeJSON(w, map[string]any{"createdNote": map[string]any{"id": note.ID, "text": note.Text}})
+	case "notes/reactions/create":
+		var reaction Reaction
+		if err := json.Unmarshal(data, &reaction); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_PARAM")
+			return
+		}
+		s.reactions = append(s.reactions, reaction)
+		w.WriteHeader(http.StatusNoContent)
+	case "i":
+		writeJSON(w, map[string]any{"id": "bot", "username": "hato", "name": "hato-bot", "isBot": true})
+	default:
+		writeJSON(w, map[string]any{})
+	}
+}
+
+// serveUpload マルチパートで送られたファイルを記録する
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAM")
+		return
+	}
+	if r.FormValue("i") != s.token {
+		writeError(w, http.StatusUnauthorized, "CREDENTIAL_REQUIRED")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAM")
+		return
+	}
+	defer func() { _ = file.Close() }()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uploaded := UploadedFile{ID: s.newID("file"), Name: header.Filename, Data: data}
+	s.files = append(s.files, uploaded)
+	s.calls = append(s.calls, Call{Endpoint: "drive/files/create", Body: map[string]any{"name": header.Filename}})
+	writeJSON(w, map[string]any{"id": uploaded.ID, "name": uploaded.Name, "url": s.URL + "/files/" + uploaded.ID})
+}
+
+// serveStreaming ストリーミングの接続を受け付け、チャンネルへの接続を記録する
+func (s *Server) serveStreaming(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("i") != s.token {
+		writeError(w, http.StatusUnauthorized, "CREDENTIAL_REQUIRED")
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	target := &stream{conn: conn, channels: map[string]string{}}
+	s.mu.Lock()
+	s.streams = append(s.streams, target)
+	s.mu.Unlock()
+
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		s.streams = slices.DeleteFunc(s.streams, func(other *stream) bool { return other == target })
+		s.mu.Unlock()
+	}()
+
+	for {
+		var message struct {
+			Type string `json:"type"`
+			Body struct {
+				Channel string `json:"channel"`
+				ID      string `json:"id"`
+			} `json:"body"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			return
+		}
+		target.mu.Lock()
+		switch message.Type {
+		case "connect":
+			target.channels[message.Body.ID] = message.Body.Channel
+		case "disconnect":
+			delete(target.channels, message.Body.ID)
+		}
+		target.mu.Unlock()
+	}
+}
+
+// writeJSON 値をJSONで書き込む
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError Misskeyと同じ形式のエラーを書き込む
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": code, "message": code}})
+}
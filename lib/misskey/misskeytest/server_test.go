@@ -0,0 +1,97 @@
+package misskeytest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/misskey"
+	"hato-bot-go/lib/misskey/misskeytest"
+)
+
+// TestServer ボットがストリーミングでメンションを受信し、APIで返信・アップロード・リアクションできることを確認する
+func TestServer(t *testing.T) {
+	t.Parallel()
+	server := misskeytest.NewServer(t, "token")
+	misskeyBot := server.NewBot()
+	if err := misskeyBot.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	mentions := make(chan *misskey.Note, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- misskeyBot.Listen(ctx, func(note *misskey.Note) { mentions <- note })
+	}()
+
+	sent := &misskey.Note{ID: "mention1", Text: "@hato amesh", Visibility: "public"}
+	sent.User.ID = "user1"
+	sent.User.Username = "alice"
+	server.Mention(t, sent)
+
+	var received *misskey.Note
+	select {
+	case received = <-mentions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for mention")
+	}
+	if diff := cmp.Diff(sent, received); diff != "" {
+		t.Errorf("mention mismatch (-want +got):\n%s", diff)
+	}
+
+	file, err := misskeyBot.UploadFile(ctx, strings.NewReader("image"), "amesh.png")
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if err := misskeyBot.AddReaction(ctx, received.ID, "👀"); err != nil {
+		t.Fatalf("AddReaction() error = %v", err)
+	}
+	if err := misskeyBot.CreateNote(ctx, &misskey.CreateNoteParams{Text: "reply", FileIDs: []string{file.ID}, OriginalNote: received}); err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+
+	expectedNotes := []misskeytest.CreatedNote{{Text: "reply", Visibility: "home", ReplyID: "mention1", FileIDs: []string{file.ID}}}
+	if diff := cmp.Diff(expectedNotes, server.WaitNotes(t, 1), cmpIgnoreID); diff != "" {
+		t.Errorf("Notes() mismatch (-want +got):\n%s", diff)
+	}
+	expectedFiles := []misskeytest.UploadedFile{{ID: file.ID, Name: "amesh.png", Data: []byte("image")}}
+	if diff := cmp.Diff(expectedFiles, server.Files()); diff != "" {
+		t.Errorf("Files() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]misskeytest.Reaction{{NoteID: "mention1", Reaction: "👀"}}, server.Reactions()); diff != "" {
+		t.Errorf("Reactions() mismatch (-want +got):\n%s", diff)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Listen() error = %v", err)
+	}
+}
+
+// cmpIgnoreID サーバーが払い出したノートのIDを比較しない
+var cmpIgnoreID = cmp.Transformer("ignoreID", func(note misskeytest.CreatedNote) misskeytest.CreatedNote {
+	note.ID = ""
+	return note
+})
+
+// TestServerRejectsToken 異なるAPIトークンでは接続もAPIの呼び出しもできないことを確認する
+func TestServerRejectsToken(t *testing.T) {
+	t.Parallel()
+	server := misskeytest.NewServer(t, "token")
+	misskeyBot := server.NewBot()
+	misskeyBot.BotSetting.Token = "wrong"
+
+	if err := misskeyBot.Connect(); err == nil {
+		t.Error("Connect() error = nil, want an error")
+	}
+	if err := misskeyBot.AddReaction(t.Context(), "note1", "👀"); err == nil {
+		t.Error("AddReaction() error = nil, want an error")
+	}
+	if calls := server.Calls(""); len(calls) != 0 {
+		t.Errorf("Calls() = %v, want none", calls)
+	}
+}
@@ -0,0 +1,73 @@
+package misskey
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+)
+
+// Option NewBotで作成するBotの設定を変更するオプション
+type Option func(*Bot)
+
+// WithHTTPClient APIリクエストに使うHTTPクライアントを指定する
+// 指定しない場合はhttpclient.DefaultTransportを使うクライアントを使う
+func WithHTTPClient(client httpclient.Doer) Option {
+	return func(b *Bot) {
+		b.BotSetting.Client = client
+	}
+}
+
+// WithUserAgent WebSocket接続のUser-Agentを指定する
+// 指定しない場合はhttpclient.UserAgentを使う
+func WithUserAgent(userAgent string) Option {
+	return func(b *Bot) {
+		b.UserAgent = userAgent
+	}
+}
+
+// WithLogger ログの出力先を指定する
+// 指定しない場合はslog.Defaultに出力する
+func WithLogger(logger Logger) Option {
+	return func(b *Bot) {
+		b.BotSetting.Logger = logger
+	}
+}
+
+// WithRateLimit APIリクエストとファイルのアップロードの最短の間隔を指定する
+// 複数のgoroutineから呼び出しても、リクエストの間隔はintervalより短くならない
+func WithRateLimit(interval time.Duration) Option {
+	return func(b *Bot) {
+		b.BotSetting.RequestInterval = interval
+	}
+}
+
+// throttle 前回のリクエストからRequestIntervalが経つまで待つ
+// 待っている間にコンテキストがキャンセルされた場合はそのエラーを返す
+func (bot *Bot) throttle(ctx context.Context) error {
+	interval := bot.BotSetting.RequestInterval
+	if interval <= 0 {
+		return nil
+	}
+
+	bot.throttleMu.Lock()
+	now := clock.Real.Now()
+	start := bot.nextRequest
+	if start.Before(now) {
+		start = now
+	}
+	bot.nextRequest = start.Add(interval)
+	bot.throttleMu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	if err := clock.Real.Sleep(ctx, delay); err != nil {
+		return errors.Wrap(err, "Failed to wait for rate limit")
+	}
+	return nil
+}
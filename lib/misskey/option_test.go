@@ -0,0 +1,120 @@
+package misskey_test
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+func TestNewBot(t *testing.T) {
+	client := httpclient.NewMockHTTPClient(http.StatusOK, "")
+	logger := slog.New(slog.DiscardHandler)
+
+	tests := []struct {
+		name          string
+		domain        string
+		token         string
+		opts          []misskey.Option
+		expectedError error
+		verify        func(t *testing.T, b *misskey.Bot)
+	}{
+		{
+			name:   "オプションなし",
+			domain: "example.com",
+			token:  "token",
+			verify: func(t *testing.T, b *misskey.Bot) {
+				if b.BotSetting.Client == nil {
+					t.Error("Client = nil, want the default client")
+				}
+			},
+		},
+		{
+			name:   "全てのオプション",
+			domain: "example.com",
+			token:  "token",
+			opts: []misskey.Option{
+				misskey.WithHTTPClient(client),
+				misskey.WithUserAgent("test-agent/1.0"),
+				misskey.WithLogger(logger),
+				misskey.WithRateLimit(time.Second),
+			},
+			verify: func(t *testing.T, b *misskey.Bot) {
+				if b.BotSetting.Client != client {
+					t.Errorf("Client = %v, want the given client", b.BotSetting.Client)
+				}
+				if b.UserAgent != "test-agent/1.0" {
+					t.Errorf("UserAgent = %q, want %q", b.UserAgent, "test-agent/1.0")
+				}
+				if b.BotSetting.Logger != logger {
+					t.Errorf("Logger = %v, want the given logger", b.BotSetting.Logger)
+				}
+				if b.BotSetting.RequestInterval != time.Second {
+					t.Errorf("RequestInterval = %s, want %s", b.BotSetting.RequestInterval, time.Second)
+				}
+			},
+		},
+		{
+			name:          "ドメインなし",
+			token:         "token",
+			expectedError: misskey.ErrInvalidBotSetting,
+		},
+		{
+			name:          "APIトークンなし",
+			domain:        "example.com",
+			expectedError: misskey.ErrInvalidBotSetting,
+		},
+		{
+			name:          "負の間隔",
+			domain:        "example.com",
+			token:         "token",
+			opts:          []misskey.Option{misskey.WithHTTPClient(client), misskey.WithRateLimit(-time.Second)},
+			expectedError: misskey.ErrInvalidBotSetting,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := misskey.NewBot(tt.domain, tt.token, tt.opts...)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("NewBot() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				if b != nil {
+					t.Errorf("NewBot() = %v, want nil", b)
+				}
+				return
+			}
+			tt.verify(t, b)
+		})
+	}
+}
+
+// TestNewBotRateLimit WithRateLimitの間隔を空けてAPIリクエストを送信することを確認する
+func TestNewBotRateLimit(t *testing.T) {
+	t.Parallel()
+	const interval = 20 * time.Millisecond
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusNoContent},
+	})
+	b, err := misskey.NewBot("example.com", "token", misskey.WithHTTPClient(transport.Client()), misskey.WithRateLimit(interval))
+	if err != nil {
+		t.Fatalf("NewBot() error = %v", err)
+	}
+
+	start := time.Now()
+	for range 3 {
+		if err := b.AddReaction(t.Context(), "note123", "👀"); err != nil {
+			t.Fatalf("AddReaction() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("elapsed = %s, want >= %s", elapsed, 2*interval)
+	}
+}
@@ -0,0 +1,192 @@
+package misskey
+
+import (
+	"context"
+	"io"
+	"maps"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/i18n"
+)
+
+// Platform bot.PlatformのMisskey向けアダプター
+// IncomingMessage.Rawには*Noteまたは*ChatMessageを指定する
+type Platform struct {
+	Bot         *Bot
+	RetryDelays []time.Duration         // 返信のノートやチャットメッセージの作成に一時的に失敗した場合に再試行するまでの待ち時間（空の場合は再試行しない）
+	Clock       clock.Clock             // 再試行までの待ち時間に使う時計（nilの場合はclock.Real）
+	Reactions   map[bot.Reaction]string // リアクションごとに付ける絵文字（カスタム絵文字は:hato:の形式、設定していないリアクションは付けない）
+	// ThumbnailDimension 添付するPNG画像の幅か高さがこれを超える場合は縮小したサムネイルを添付し、
+	// 元の大きさの画像もアップロードして本文にリンクを添える（0の場合はそのまま添付する）
+	ThumbnailDimension int
+}
+
+// NewPlatform Botを使うPlatformを作成する
+// 返信の作成に一時的に失敗した場合はDefaultRetryDelaysの待ち時間で再試行し、リアクションはDefaultReactionsを付ける
+func NewPlatform(b *Bot) *Platform {
+	return &Platform{Bot: b, RetryDelays: DefaultRetryDelays, Reactions: maps.Clone(DefaultReactions)}
+}
+
+// IncomingMessage ノートをプラットフォームに依存しない受信メッセージに変換する
+func (n *Note) IncomingMessage() *bot.IncomingMessage {
+	message := &bot.IncomingMessage{ID: n.ID, Text: n.Text, UserID: n.User.ID, Raw: n}
+	if n.Reply != nil {
+		message.ReplyText = n.Reply.Text
+	}
+	return message
+}
+
+// IncomingMessage チャットメッセージをプラットフォームに依存しない受信メッセージに変換する
+func (m *ChatMessage) IncomingMessage() *bot.IncomingMessage {
+	return &bot.IncomingMessage{ID: m.ID, Text: m.Text, UserID: m.FromUserID, Raw: m}
+}
+
+// Name プラットフォーム名
+func (p *Platform) Name() string {
+	return "misskey"
+}
+
+// SupportsMFM ノートとチャットメッセージの本文のMFMを装飾として表示する
+func (p *Platform) SupportsMFM() bool {
+	return true
+}
+
+// TemplateData メッセージの送信者への返信テンプレートに渡す変数を返す
+func (p *Platform) TemplateData(message *bot.IncomingMessage) *i18n.TemplateData {
+	switch raw := message.Raw.(type) {
+	case *Note:
+		return p.Bot.TemplateDataFor(raw.User.Username, raw.User.Host)
+	case *ChatMessage:
+		return p.Bot.TemplateDataFor(raw.FromUser.Username, raw.FromUser.Host)
+	default:
+		return p.Bot.TemplateDataFor("", "")
+	}
+}
+
+// React ノートまたはチャットメッセージにリアクションに対応する絵文字を付ける
+// 対応する絵文字を設定していない場合は何もしない
+func (p *Platform) React(ctx context.Context, message *bot.IncomingMessage, reaction bot.Reaction) error {
+	emoji, ok := p.Reactions[reaction]
+	if !ok {
+		return nil
+	}
+
+	switch raw := message.Raw.(type) {
+	case *Note:
+		if err := p.Bot.AddReaction(ctx, raw.ID, emoji); err != nil {
+			return errors.Wrap(err, "Failed to AddReaction")
+		}
+	case *ChatMessage:
+		if err := p.Bot.AddChatReaction(ctx, raw.ID, emoji); err != nil {
+			return errors.Wrap(err, "Failed to AddChatReaction")
+		}
+	default:
+		return lib.ErrParamsNil
+	}
+	return nil
+}
+
+// Reply 添付ファイルをアップロードし、ノートにはノートで、チャットメッセージにはチャットで返信する
+// ThumbnailDimensionを超えるPNG画像はサムネイルを添付し、元の大きさの画像へのリンクを本文に添える
+// ノートの本文がインスタンスの文字数の上限を超える場合は分割し、2つ目以降は直前の返信へのリプライとしてスレッドにする
+// 返信の作成に一時的に失敗した場合は再試行し、最終的に失敗した場合はアップロードしたファイルを削除する
+func (p *Platform) Reply(ctx context.Context, message *bot.IncomingMessage, reply *bot.OutgoingReply) (err error) {
+	attachments := reply.Attachments
+	// チャットメッセージに添付できるファイルは1つのみのため、残りはアップロードしない
+	if _, ok := message.Raw.(*ChatMessage); ok && 1 < len(attachments) {
+		attachments = attachments[:1]
+	}
+
+	var uploaded []string // 返信に失敗した場合に削除するファイルのID
+	defer func() {
+		if err != nil {
+			p.deleteFiles(ctx, uploaded)
+		}
+	}()
+
+	text := reply.Text
+	var fileIDs []string
+	for _, attachment := range attachments {
+		file, err := p.uploadAttachment(ctx, attachment, &uploaded)
+		if err != nil {
+			return errors.Wrap(err, "Failed to uploadAttachment")
+		}
+		fileIDs = append(fileIDs, file.FileID)
+		if file.FullURL != "" {
+			templateData := p.TemplateData(message)
+			templateData.URL = file.FullURL
+			text += "\n" + p.Bot.BotSetting.Templates.Render(i18n.KeyReplyFullImage, templateData)
+		}
+	}
+
+	switch raw := message.Raw.(type) {
+	case *Note:
+		policy := p.Bot.ReplyPolicyFor(reply.Command)
+		threadReplyID := ""
+		for i, part := range lib.SplitText(text, p.Bot.MaxNoteTextLength()) {
+			params := &CreateNoteParams{
+				Text:          part,
+				OriginalNote:  raw,
+				Policy:        policy,
+				ThreadReplyID: threadReplyID,
+			}
+			if i == 0 {
+				params.FileIDs = fileIDs
+			}
+			var created *Note
+			if err := withRetry(ctx, p.RetryDelays, p.Clock, p.Bot.logger(), func() (err error) {
+				created, err = p.Bot.createReplyNote(ctx, params)
+				return err
+			}); err != nil {
+				return errors.Wrap(err, "Failed to CreateNote")
+			}
+			// 添付したファイルは最初の返信に残るため、続きの返信に失敗しても削除しない
+			uploaded = nil
+			threadReplyID = created.ID
+		}
+	case *ChatMessage:
+		params := &SendChatMessageParams{
+			ToUserID: raw.FromUserID,
+			Text:     text,
+		}
+		if 0 < len(fileIDs) {
+			params.FileID = fileIDs[0]
+		}
+		if err := withRetry(ctx, p.RetryDelays, p.Clock, p.Bot.logger(), func() error {
+			return p.Bot.SendChatMessage(ctx, params)
+		}); err != nil {
+			return errors.Wrap(err, "Failed to SendChatMessage")
+		}
+	default:
+		return lib.ErrParamsNil
+	}
+	return nil
+}
+
+// TestUpload ドライブにファイルをアップロードし、すぐに削除する（admin selftestコマンドのアップロードの手順）
+func (p *Platform) TestUpload(ctx context.Context, reader io.Reader, fileName string) error {
+	file, err := p.Bot.UploadFile(ctx, reader, fileName)
+	if err != nil {
+		return errors.Wrap(err, "Failed to UploadFile")
+	}
+	if err := p.Bot.DeleteFile(ctx, file.ID); err != nil {
+		return errors.Wrap(err, "Failed to DeleteFile")
+	}
+	return nil
+}
+
+// deleteFiles 返信に使えなかったファイルを削除する
+// 返信の失敗がタイムアウトによる場合も削除できるよう、ctxのキャンセルは引き継がない
+func (p *Platform) deleteFiles(ctx context.Context, fileIDs []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, fileID := range fileIDs {
+		if err := p.Bot.DeleteFile(ctx, fileID); err != nil {
+			p.Bot.logger().Error("Failed to delete uploaded file", "file", fileID, "error", err)
+		}
+	}
+}
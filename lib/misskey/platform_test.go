@@ -0,0 +1,671 @@
+package misskey_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+// newPlatformTransport ファイルのアップロードにIDを返すMockTransportを作成する
+func newPlatformTransport() *httpclient.MockTransport {
+	return httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file123"}`}}},
+		},
+		Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+	})
+}
+
+// newTestPlatform MockTransportを使うPlatformを作成する
+// 返信の作成の再試行は待たずに2回まで行う
+func newTestPlatform(transport *httpclient.MockTransport) *misskey.Platform {
+	platform := misskey.NewPlatform(misskey.NewBotWithClient(&misskey.BotSetting{
+		Domain: "example.com",
+		Token:  "token",
+		Client: transport.Client(),
+		CommandReplyPolicies: map[string]misskey.ReplyPolicy{
+			"amedas": {Visibility: "followers"},
+		},
+	}))
+	platform.RetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	return platform
+}
+
+// testNote テスト用のノート
+func testNote() *misskey.Note {
+	note := &misskey.Note{ID: "note123", Text: "@hato amesh 東京", Visibility: "public"}
+	note.User.Username = "alice"
+	return note
+}
+
+// testChatMessage テスト用のチャットメッセージ
+func testChatMessage() *misskey.ChatMessage {
+	message := &misskey.ChatMessage{ID: "message123", Text: "amesh 東京", FromUserID: "user123"}
+	message.FromUser.Username = "alice"
+	return message
+}
+
+func TestPlatformReact(t *testing.T) {
+	tests := []struct {
+		name             string
+		message          *bot.IncomingMessage
+		reaction         bot.Reaction
+		reactions        map[bot.Reaction]string
+		expectedEndpoint string
+		expectedBody     map[string]any
+	}{
+		{
+			name:             "ノート",
+			message:          testNote().IncomingMessage(),
+			reaction:         bot.ReactionProcessing,
+			expectedEndpoint: "notes/reactions/create",
+			expectedBody:     map[string]any{"i": "token", "noteId": "note123", "reaction": "👀"},
+		},
+		{
+			name:             "チャットメッセージ",
+			message:          testChatMessage().IncomingMessage(),
+			reaction:         bot.ReactionProcessing,
+			expectedEndpoint: "chat/messages/react",
+			expectedBody:     map[string]any{"i": "token", "messageId": "message123", "reaction": "👀"},
+		},
+		{
+			name:             "設定したカスタム絵文字",
+			message:          testNote().IncomingMessage(),
+			reaction:         bot.ReactionSuccess,
+			reactions:        map[bot.Reaction]string{bot.ReactionSuccess: ":hato:"},
+			expectedEndpoint: "notes/reactions/create",
+			expectedBody:     map[string]any{"i": "token", "noteId": "note123", "reaction": ":hato:"},
+		},
+		{
+			name:     "設定していないリアクションは付けない",
+			message:  testNote().IncomingMessage(),
+			reaction: bot.ReactionSuccess,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := newPlatformTransport()
+			platform := newTestPlatform(transport)
+			if tt.reactions != nil {
+				platform.Reactions = tt.reactions
+			}
+
+			if err := platform.React(t.Context(), tt.message, tt.reaction); err != nil {
+				t.Fatalf("React() error = %v", err)
+			}
+
+			if tt.expectedEndpoint == "" {
+				if requests := transport.Requests(); len(requests) != 0 {
+					t.Fatalf("requests = %d, want 0", len(requests))
+				}
+				return
+			}
+			requests := transport.RequestsTo(tt.expectedEndpoint)
+			if len(requests) != 1 {
+				t.Fatalf("%s called %d times, want 1", tt.expectedEndpoint, len(requests))
+			}
+			var body map[string]any
+			if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedBody, body); diff != "" {
+				t.Errorf("request body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPlatformReply(t *testing.T) {
+	tests := []struct {
+		name             string
+		message          *bot.IncomingMessage
+		reply            *bot.OutgoingReply
+		expectedUploads  int
+		expectedEndpoint string
+		expectedBody     map[string]any
+	}{
+		{
+			name:    "画像付きのノートで返信",
+			message: testNote().IncomingMessage(),
+			reply: &bot.OutgoingReply{
+				Command:     "amesh",
+				Text:        "東京の雨雲レーダー",
+				Attachments: []*bot.Attachment{{Reader: io.NopCloser(strings.NewReader("png")), FileName: "amesh.png"}},
+			},
+			expectedUploads:  1,
+			expectedEndpoint: "notes/create",
+			expectedBody: map[string]any{
+				"i": "token", "text": "東京の雨雲レーダー", "replyId": "note123", "visibility": "home", "fileIds": []any{"file123"},
+			},
+		},
+		{
+			name:             "コマンドごとの返信方針",
+			message:          testNote().IncomingMessage(),
+			reply:            &bot.OutgoingReply{Command: "amedas", Text: "東京の観測値"},
+			expectedEndpoint: "notes/create",
+			expectedBody: map[string]any{
+				"i": "token", "text": "東京の観測値", "replyId": "note123", "visibility": "followers",
+			},
+		},
+		{
+			name:    "チャットで返信",
+			message: testChatMessage().IncomingMessage(),
+			reply: &bot.OutgoingReply{
+				Command:     "amesh",
+				Text:        "東京の雨雲レーダー",
+				Attachments: []*bot.Attachment{{Reader: io.NopCloser(strings.NewReader("png")), FileName: "amesh.png"}},
+			},
+			expectedUploads:  1,
+			expectedEndpoint: "chat/messages/create-to-user",
+			expectedBody: map[string]any{
+				"i": "token", "text": "東京の雨雲レーダー", "toUserId": "user123", "fileId": "file123",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := newPlatformTransport()
+			platform := newTestPlatform(transport)
+
+			if err := platform.Reply(t.Context(), tt.message, tt.reply); err != nil {
+				t.Fatalf("Reply() error = %v", err)
+			}
+
+			if got := len(transport.RequestsTo("drive/files/create")); got != tt.expectedUploads {
+				t.Errorf("uploads = %d, want %d", got, tt.expectedUploads)
+			}
+			requests := transport.RequestsTo(tt.expectedEndpoint)
+			if len(requests) != 1 {
+				t.Fatalf("%s called %d times, want 1", tt.expectedEndpoint, len(requests))
+			}
+			var body map[string]any
+			if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedBody, body); diff != "" {
+				t.Errorf("request body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestPlatformReplyDeletesUploads 返信に失敗した場合にアップロードしたファイルを削除することを確認する
+func TestPlatformReplyDeletesUploads(t *testing.T) {
+	attachments := func(n int) []*bot.Attachment {
+		result := make([]*bot.Attachment, 0, n)
+		for range n {
+			result = append(result, &bot.Attachment{Reader: io.NopCloser(strings.NewReader("png")), FileName: "amesh.png"})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name            string
+		message         *bot.IncomingMessage
+		attachments     int
+		routes          []httpclient.MockRoute
+		expectError     bool
+		expectedUploads int
+		expectedDeletes []string
+	}{
+		{
+			name:        "ノートの作成に失敗した場合はファイルを削除",
+			message:     testNote().IncomingMessage(),
+			attachments: 1,
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+				{Pattern: "notes/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError, Body: `{}`}}},
+			},
+			expectError:     true,
+			expectedUploads: 1,
+			expectedDeletes: []string{"file1"},
+		},
+		{
+			name:        "2つ目のアップロードに失敗した場合は1つ目を削除",
+			message:     testNote().IncomingMessage(),
+			attachments: 2,
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{
+					{StatusCode: http.StatusOK, Body: `{"id":"file1"}`},
+					{StatusCode: http.StatusInternalServerError, Body: `{}`},
+				}},
+			},
+			expectError:     true,
+			expectedUploads: 2,
+			expectedDeletes: []string{"file1"},
+		},
+		{
+			name:        "チャットの送信に失敗した場合はファイルを削除",
+			message:     testChatMessage().IncomingMessage(),
+			attachments: 1,
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+				{Pattern: "chat/messages/create-to-user", Responses: []httpclient.MockResponse{{StatusCode: http.StatusBadRequest, Body: `{}`}}},
+			},
+			expectError:     true,
+			expectedUploads: 1,
+			expectedDeletes: []string{"file1"},
+		},
+		{
+			name:        "チャットには添付できる1つ目のファイルのみアップロード",
+			message:     testChatMessage().IncomingMessage(),
+			attachments: 3,
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+			},
+			expectedUploads: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes:   tt.routes,
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+			})
+			platform := newTestPlatform(transport)
+
+			err := platform.Reply(t.Context(), tt.message, &bot.OutgoingReply{
+				Command:     "amesh",
+				Text:        "東京の雨雲レーダー",
+				Attachments: attachments(tt.attachments),
+			})
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Reply() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			if got := len(transport.RequestsTo("drive/files/create")); got != tt.expectedUploads {
+				t.Errorf("uploads = %d, want %d", got, tt.expectedUploads)
+			}
+			var deletes []string
+			for _, request := range transport.RequestsTo("drive/files/delete") {
+				var body struct {
+					FileID string `json:"fileId"`
+				}
+				if err := json.Unmarshal(request.Body, &body); err != nil {
+					t.Fatal(err)
+				}
+				deletes = append(deletes, body.FileID)
+			}
+			if diff := cmp.Diff(tt.expectedDeletes, deletes); diff != "" {
+				t.Errorf("deleted files mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestPlatformReplyRetry 返信の作成に一時的に失敗した場合は再試行し、再試行しても失敗した場合はファイルを削除することをテストする
+func TestPlatformReplyRetry(t *testing.T) {
+	tests := []struct {
+		name            string
+		message         *bot.IncomingMessage
+		responses       []httpclient.MockResponse
+		expectError     bool
+		expectedCreates int
+		expectedDeletes int
+	}{
+		{
+			name:            "サーバーのエラーの後に成功",
+			message:         testNote().IncomingMessage(),
+			responses:       []httpclient.MockResponse{{StatusCode: http.StatusBadGateway, Body: `{}`}, {StatusCode: http.StatusOK, Body: `{}`}},
+			expectedCreates: 2,
+		},
+		{
+			name:            "接続の失敗の後に成功",
+			message:         testNote().IncomingMessage(),
+			responses:       []httpclient.MockResponse{{Err: errors.New("connection reset")}, {StatusCode: http.StatusOK, Body: `{}`}},
+			expectedCreates: 2,
+		},
+		{
+			name:            "レート制限の後にチャットの送信に成功",
+			message:         testChatMessage().IncomingMessage(),
+			responses:       []httpclient.MockResponse{{StatusCode: http.StatusTooManyRequests, Body: `{}`}, {StatusCode: http.StatusOK, Body: `{}`}},
+			expectedCreates: 2,
+		},
+		{
+			name:            "再試行しても失敗",
+			message:         testNote().IncomingMessage(),
+			responses:       []httpclient.MockResponse{{StatusCode: http.StatusServiceUnavailable, Body: `{}`}},
+			expectError:     true,
+			expectedCreates: 3,
+			expectedDeletes: 1,
+		},
+		{
+			name:            "リクエストの内容による失敗は再試行しない",
+			message:         testNote().IncomingMessage(),
+			responses:       []httpclient.MockResponse{{StatusCode: http.StatusBadRequest, Body: `{}`}},
+			expectError:     true,
+			expectedCreates: 1,
+			expectedDeletes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+					{Pattern: "notes/create", Responses: tt.responses},
+					{Pattern: "chat/messages/create-to-user", Responses: tt.responses},
+				},
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+			})
+			platform := newTestPlatform(transport)
+
+			err := platform.Reply(t.Context(), tt.message, &bot.OutgoingReply{
+				Command:     "amesh",
+				Text:        "東京の雨雲レーダー",
+				Attachments: []*bot.Attachment{{Reader: io.NopCloser(strings.NewReader("png")), FileName: "amesh.png"}},
+			})
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Reply() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			creates := len(transport.RequestsTo("notes/create")) + len(transport.RequestsTo("chat/messages/create-to-user"))
+			if creates != tt.expectedCreates {
+				t.Errorf("creates = %d, want %d", creates, tt.expectedCreates)
+			}
+			if got := len(transport.RequestsTo("drive/files/delete")); got != tt.expectedDeletes {
+				t.Errorf("deletes = %d, want %d", got, tt.expectedDeletes)
+			}
+		})
+	}
+}
+
+// TestPlatformReplyThread 本文がノートの文字数の上限を超える返信を分割してスレッドにすることをテストする
+func TestPlatformReplyThread(t *testing.T) {
+	tests := []struct {
+		name            string
+		responses       []httpclient.MockResponse
+		expectError     bool
+		expectedNotes   []map[string]any
+		expectedDeletes int
+	}{
+		{
+			name: "直前の返信へのリプライで続ける",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"reply1"}}`},
+				{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"reply2"}}`},
+				{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"reply3"}}`},
+			},
+			expectedNotes: []map[string]any{
+				{"i": "token", "text": "一行目の文章", "replyId": "note123", "visibility": "home", "fileIds": []any{"file1"}},
+				{"i": "token", "text": "二行目の文章", "replyId": "reply1", "visibility": "home"},
+				{"i": "token", "text": "三行目の文章", "replyId": "reply2", "visibility": "home"},
+			},
+		},
+		{
+			name: "続きの返信に失敗しても最初の返信のファイルは削除しない",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `{"createdNote":{"id":"reply1"}}`},
+				{StatusCode: http.StatusBadRequest, Body: `{}`},
+			},
+			expectError: true,
+			expectedNotes: []map[string]any{
+				{"i": "token", "text": "一行目の文章", "replyId": "note123", "visibility": "home", "fileIds": []any{"file1"}},
+				{"i": "token", "text": "二行目の文章", "replyId": "reply1", "visibility": "home"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "/api/meta", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"version":"2025.4.0","maxNoteTextLength":10}`}}},
+					{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+					{Pattern: "notes/create", Responses: tt.responses},
+				},
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+			})
+			platform := newTestPlatform(transport)
+			if _, err := platform.Bot.DetectCapabilities(t.Context()); err != nil {
+				t.Fatalf("DetectCapabilities() error = %v", err)
+			}
+
+			err := platform.Reply(t.Context(), testNote().IncomingMessage(), &bot.OutgoingReply{
+				Command:     "amesh",
+				Text:        "一行目の文章\n二行目の文章\n三行目の文章",
+				Attachments: []*bot.Attachment{{Reader: io.NopCloser(strings.NewReader("png")), FileName: "amesh.png"}},
+			})
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Reply() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			var notes []map[string]any
+			for _, request := range transport.RequestsTo("notes/create") {
+				var body map[string]any
+				if err := json.Unmarshal(request.Body, &body); err != nil {
+					t.Fatal(err)
+				}
+				notes = append(notes, body)
+			}
+			if diff := cmp.Diff(tt.expectedNotes, notes); diff != "" {
+				t.Errorf("notes mismatch (-want +got):\n%s", diff)
+			}
+			if got := len(transport.RequestsTo("drive/files/delete")); got != tt.expectedDeletes {
+				t.Errorf("deletes = %d, want %d", got, tt.expectedDeletes)
+			}
+		})
+	}
+}
+
+// encodeTestPNG 幅と高さを指定したテスト用のPNG画像を作成する
+func encodeTestPNG(t *testing.T, width, height int) io.ReadCloser {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return io.NopCloser(buf)
+}
+
+// TestPlatformReplyThumbnail 大きな画像はサムネイルを添付し、元の大きさの画像へのリンクを添えることをテストする
+func TestPlatformReplyThumbnail(t *testing.T) {
+	tests := []struct {
+		name            string
+		width           int
+		expectedUploads int
+		expectedBody    map[string]any
+	}{
+		{
+			name:            "上限を超える画像はサムネイルを添付",
+			width:           300,
+			expectedUploads: 2,
+			expectedBody: map[string]any{
+				"i": "token", "text": "東京の雨雲レーダー\n🔍 元の大きさの画像: https://example.com/files/full.png",
+				"replyId": "note123", "visibility": "home", "fileIds": []any{"thumbnail"},
+			},
+		},
+		{
+			name:            "上限に収まる画像はそのまま添付",
+			width:           128,
+			expectedUploads: 1,
+			expectedBody: map[string]any{
+				"i": "token", "text": "東京の雨雲レーダー", "replyId": "note123", "visibility": "home", "fileIds": []any{"full"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{
+					{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{
+						{StatusCode: http.StatusOK, Body: `{"id":"full","url":"https://example.com/files/full.png"}`},
+						{StatusCode: http.StatusOK, Body: `{"id":"thumbnail","url":"https://example.com/files/thumbnail.png"}`},
+					}},
+				},
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+			})
+			platform := newTestPlatform(transport)
+			platform.ThumbnailDimension = 128
+
+			err := platform.Reply(t.Context(), testNote().IncomingMessage(), &bot.OutgoingReply{
+				Command:     "amesh",
+				Text:        "東京の雨雲レーダー",
+				Attachments: []*bot.Attachment{{Reader: encodeTestPNG(t, tt.width, 100), FileName: "amesh.png"}},
+			})
+			if err != nil {
+				t.Fatalf("Reply() error = %v", err)
+			}
+
+			if got := len(transport.RequestsTo("drive/files/create")); got != tt.expectedUploads {
+				t.Errorf("uploads = %d, want %d", got, tt.expectedUploads)
+			}
+			requests := transport.RequestsTo("notes/create")
+			if len(requests) != 1 {
+				t.Fatalf("notes/create called %d times, want 1", len(requests))
+			}
+			var body map[string]any
+			if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedBody, body); diff != "" {
+				t.Errorf("request body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestPlatformLogger 再試行とファイルの削除の失敗を設定したログの出力先に記録することを確認する
+func TestPlatformLogger(t *testing.T) {
+	t.Parallel()
+	transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+			{Pattern: "drive/files/delete", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError, Body: `{}`}}},
+			{Pattern: "notes/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusServiceUnavailable, Body: `{}`}}},
+		},
+	})
+	output := &bytes.Buffer{}
+	platform := newTestPlatform(transport)
+	platform.Bot.BotSetting.Logger = slog.New(slog.NewTextHandler(output, nil))
+
+	err := platform.Reply(t.Context(), testNote().IncomingMessage(), &bot.OutgoingReply{
+		Command:     "amesh",
+		Text:        "東京の雨雲レーダー",
+		Attachments: []*bot.Attachment{{Reader: io.NopCloser(strings.NewReader("png")), FileName: "amesh.png"}},
+	})
+	if err == nil {
+		t.Fatal("Reply() error = nil, want error")
+	}
+
+	logs := output.String()
+	if got := strings.Count(logs, "level=WARN msg=Retrying"); got != 2 {
+		t.Errorf("retry logs = %d, want 2:\n%s", got, logs)
+	}
+	if !strings.Contains(logs, `level=ERROR msg="Failed to delete uploaded file" file=file1`) {
+		t.Errorf("logs = %q, want the failed deletion", logs)
+	}
+}
+
+func TestNoteIncomingMessage(t *testing.T) {
+	tests := []struct {
+		name              string
+		reply             *misskey.Note
+		expectedReplyText string
+	}{
+		{
+			name:              "返信でない",
+			reply:             nil,
+			expectedReplyText: "",
+		},
+		{
+			name:              "返信先の本文",
+			reply:             &misskey.Note{ID: "note100", Text: "Hello, world!"},
+			expectedReplyText: "Hello, world!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			note := testNote()
+			note.User.ID = "user123"
+			note.Reply = tt.reply
+
+			message := note.IncomingMessage()
+			if message.UserID != "user123" {
+				t.Errorf("UserID = %q, want user123", message.UserID)
+			}
+			if message.ReplyText != tt.expectedReplyText {
+				t.Errorf("ReplyText = %q, want %q", message.ReplyText, tt.expectedReplyText)
+			}
+		})
+	}
+}
+
+func TestPlatformTestUpload(t *testing.T) {
+	tests := []struct {
+		name            string
+		routes          []httpclient.MockRoute
+		expectError     bool
+		expectedDeletes int
+	}{
+		{
+			name: "アップロードしたファイルを削除",
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+			},
+			expectedDeletes: 1,
+		},
+		{
+			name: "アップロードに失敗",
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError, Body: `{}`}}},
+			},
+			expectError: true,
+		},
+		{
+			name: "削除に失敗",
+			routes: []httpclient.MockRoute{
+				{Pattern: "drive/files/create", Responses: []httpclient.MockResponse{{StatusCode: http.StatusOK, Body: `{"id":"file1"}`}}},
+				{Pattern: "drive/files/delete", Responses: []httpclient.MockResponse{{StatusCode: http.StatusInternalServerError, Body: `{}`}}},
+			},
+			expectError:     true,
+			expectedDeletes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes:   tt.routes,
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{}`},
+			})
+			platform := newTestPlatform(transport)
+
+			err := platform.TestUpload(t.Context(), strings.NewReader("png"), "selftest.png")
+			if (err != nil) != tt.expectError {
+				t.Fatalf("TestUpload() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if got := len(transport.RequestsTo("drive/files/delete")); got != tt.expectedDeletes {
+				t.Errorf("deletes = %d, want %d", got, tt.expectedDeletes)
+			}
+		})
+	}
+}
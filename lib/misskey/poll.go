@@ -0,0 +1,127 @@
+package misskey
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrInvalidTransport イベントの受信方法の設定値が不正であることを表すエラー
+var ErrInvalidTransport = errors.New("invalid transport")
+
+// Transport イベントの受信方法
+type Transport string
+
+const (
+	TransportStreaming Transport = "streaming" // WebSocketのストリーミング
+	TransportPolling   Transport = "polling"   // i/notificationsのポーリング（WebSocketが使えない環境向け）
+)
+
+// DefaultPollInterval 通知のポーリング間隔の既定値
+const DefaultPollInterval = 10 * time.Second
+
+// notificationLimit 1回のポーリングで取得する通知の最大数
+const notificationLimit = 50
+
+// ParseTransport イベントの受信方法の設定値を解析する
+// 空の場合はストリーミングを返す
+func ParseTransport(s string) (Transport, error) {
+	switch transport := Transport(strings.TrimSpace(s)); transport {
+	case "":
+		return TransportStreaming, nil
+	case TransportStreaming, TransportPolling:
+		return transport, nil
+	default:
+		return "", errors.Wrapf(ErrInvalidTransport, "transport: %s", s)
+	}
+}
+
+// Notification Misskeyの通知構造体
+type Notification struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Note     *Note  `json:"note,omitempty"`
+	Reaction string `json:"reaction,omitempty"` // リアクションの通知の絵文字
+	User     *User  `json:"user,omitempty"`     // 通知の元になったユーザー
+}
+
+// FetchNotifications sinceIDより新しいメンションとリプライの通知を古い順に取得する
+// BotSetting.ReactionTriggersを設定している場合はリアクションの通知も取得する
+// sinceIDが空の場合は最新の通知から取得する
+func (bot *Bot) FetchNotifications(ctx context.Context, sinceID string, limit int) (notifications []Notification, err error) {
+	includeTypes := []string{"mention", "reply"}
+	if 0 < len(bot.BotSetting.ReactionTriggers) {
+		includeTypes = append(includeTypes, "reaction")
+	}
+	data := map[string]any{
+		"limit":        limit,
+		"includeTypes": includeTypes,
+	}
+	if sinceID != "" {
+		data["sinceId"] = sinceID
+	}
+
+	body, err := bot.apiRequest(ctx, "i/notifications", data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to apiRequest")
+	}
+
+	if err := json.Unmarshal(body, &notifications); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+
+	// サーバーのバージョンによって並び順が異なるため、時系列順のIDで並べ替える
+	slices.SortFunc(notifications, func(a, b Notification) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return notifications, nil
+}
+
+// PollNotifications 前回の取得以降の通知を取得し、メンションとリプライのノートとリアクションをハンドラーに渡す
+// ストリーミングと同じハンドラーを使うが、チャットメッセージとタイムラインチャンネルには対応しない
+// 最初の呼び出しでは起動前の通知に応答しないよう、最新の通知の位置を記録するだけにする
+func (bot *Bot) PollNotifications(ctx context.Context, handlers *EventHandlers) error {
+	if handlers == nil || handlers.OnMention == nil {
+		return errors.New("messageHandler cannot be nil")
+	}
+
+	if bot.sinceNotificationID == "" {
+		latest, err := bot.FetchNotifications(ctx, "", 1)
+		if err != nil {
+			return errors.Wrap(err, "Failed to FetchNotifications")
+		}
+		// 通知がない場合は全ての通知より前のIDから取得する
+		bot.sinceNotificationID = "0"
+		if 0 < len(latest) {
+			bot.sinceNotificationID = latest[len(latest)-1].ID
+		}
+		return nil
+	}
+
+	notifications, err := bot.FetchNotifications(ctx, bot.sinceNotificationID, notificationLimit)
+	if err != nil {
+		return errors.Wrap(err, "Failed to FetchNotifications")
+	}
+
+	for _, notification := range notifications {
+		bot.sinceNotificationID = notification.ID
+		if notification.Note == nil {
+			continue
+		}
+		if notification.Type == "reaction" {
+			if event, trigger, ok := bot.reactionEvent(&notification); ok && handlers.OnReaction != nil {
+				bot.logger().Info("Received reaction", "reaction", event.Reaction, "user", event.User.Username, "note", event.Note.ID)
+				handlers.OnReaction(event, trigger)
+			}
+			continue
+		}
+		bot.logger().Info("Received notification", "type", notification.Type, "user", notification.Note.User.Username, "text", notification.Note.Text)
+
+		handlers.OnMention(notification.Note)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package misskey_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/misskey"
+)
+
+func TestParseTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      misskey.Transport
+		expectedError error
+	}{
+		{
+			name:     "空の場合はストリーミング",
+			input:    "",
+			expected: misskey.TransportStreaming,
+		},
+		{
+			name:     "ストリーミング",
+			input:    "streaming",
+			expected: misskey.TransportStreaming,
+		},
+		{
+			name:     "ポーリング",
+			input:    " polling ",
+			expected: misskey.TransportPolling,
+		},
+		{
+			name:          "不明な受信方法",
+			input:         "websocket",
+			expectedError: misskey.ErrInvalidTransport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := misskey.ParseTransport(tt.input)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("ParseTransport() error = %v, want %v", err, tt.expectedError)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseTransport() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchNotifications(t *testing.T) {
+	tests := []struct {
+		name          string
+		sinceID       string
+		triggers      []misskey.ReactionTrigger
+		statusCode    int
+		body          string
+		expectedIDs   []string
+		expectedBody  map[string]any
+		expectedError error
+	}{
+		{
+			name:        "古い順に並べ替える",
+			sinceID:     "a1",
+			statusCode:  http.StatusOK,
+			body:        `[{"id":"a3","type":"mention","note":{"id":"note3"}},{"id":"a2","type":"reply","note":{"id":"note2"}}]`,
+			expectedIDs: []string{"a2", "a3"},
+			expectedBody: map[string]any{
+				"i":            "token",
+				"limit":        float64(10),
+				"includeTypes": []any{"mention", "reply"},
+				"sinceId":      "a1",
+			},
+		},
+		{
+			name:        "sinceIDが空の場合は指定しない",
+			sinceID:     "",
+			statusCode:  http.StatusOK,
+			body:        `[]`,
+			expectedIDs: nil,
+			expectedBody: map[string]any{
+				"i":            "token",
+				"limit":        float64(10),
+				"includeTypes": []any{"mention", "reply"},
+			},
+		},
+		{
+			name:        "リアクションで実行するコマンドがある場合はリアクションの通知も取得する",
+			sinceID:     "",
+			triggers:    []misskey.ReactionTrigger{{Reaction: ":amesh:", Command: "amesh"}},
+			statusCode:  http.StatusOK,
+			body:        `[]`,
+			expectedIDs: nil,
+			expectedBody: map[string]any{
+				"i":            "token",
+				"limit":        float64(10),
+				"includeTypes": []any{"mention", "reply", "reaction"},
+			},
+		},
+		{
+			name:          "APIエラー応答",
+			sinceID:       "a1",
+			statusCode:    http.StatusBadRequest,
+			body:          `{"error":"bad request"}`,
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: tt.statusCode, Body: tt.body},
+			})
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain:           "example.com",
+				Token:            "token",
+				Client:           transport.Client(),
+				ReactionTriggers: tt.triggers,
+			})
+
+			notifications, err := bot.FetchNotifications(t.Context(), tt.sinceID, 10)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("FetchNotifications() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+
+			var ids []string
+			for _, notification := range notifications {
+				ids = append(ids, notification.ID)
+			}
+			if diff := cmp.Diff(tt.expectedIDs, ids); diff != "" {
+				t.Errorf("notification IDs mismatch (-want +got):\n%s", diff)
+			}
+
+			requests := transport.RequestsTo("i/notifications")
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+			var body map[string]any
+			if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedBody, body); diff != "" {
+				t.Errorf("request body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPollNotifications(t *testing.T) {
+	tests := []struct {
+		name              string
+		responses         []httpclient.MockResponse
+		polls             int
+		expectedMentions  []string
+		expectedReactions []string
+		expectedSinceIDs  []any
+	}{
+		{
+			name: "起動前の通知には応答せず、続きから取得する",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `[{"id":"a1","type":"mention","note":{"id":"note1"}}]`},
+				{StatusCode: http.StatusOK, Body: `[{"id":"a3","type":"reply","note":{"id":"note3"}},{"id":"a2","type":"mention","note":{"id":"note2"}}]`},
+				{StatusCode: http.StatusOK, Body: `[]`},
+			},
+			polls:            3,
+			expectedMentions: []string{"note2", "note3"},
+			expectedSinceIDs: []any{nil, "a1", "a3"},
+		},
+		{
+			name: "通知がない場合は全ての通知を対象にする",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `[]`},
+				{StatusCode: http.StatusOK, Body: `[{"id":"a1","type":"mention","note":{"id":"note1"}}]`},
+			},
+			polls:            2,
+			expectedMentions: []string{"note1"},
+			expectedSinceIDs: []any{nil, "0"},
+		},
+		{
+			name: "取得に失敗した場合は同じ位置から再取得する",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `[{"id":"a1","type":"mention","note":{"id":"note1"}}]`},
+				{StatusCode: http.StatusInternalServerError, Body: `{}`},
+				{StatusCode: http.StatusOK, Body: `[{"id":"a2","type":"mention","note":{"id":"note2"}}]`},
+			},
+			polls:            3,
+			expectedMentions: []string{"note2"},
+			expectedSinceIDs: []any{nil, "a1", "a1"},
+		},
+		{
+			name: "リアクションの通知は設定したリアクションのみ渡す",
+			responses: []httpclient.MockResponse{
+				{StatusCode: http.StatusOK, Body: `[]`},
+				{StatusCode: http.StatusOK, Body: `[` +
+					`{"id":"a1","type":"reaction","reaction":":amesh@.:","note":{"id":"note1"},"user":{"id":"user1","username":"alice"}},` +
+					`{"id":"a2","type":"reaction","reaction":"👍","note":{"id":"note2"},"user":{"id":"user1","username":"alice"}}]`},
+			},
+			polls:             2,
+			expectedReactions: []string{"note1"},
+			expectedSinceIDs:  []any{nil, "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Routes: []httpclient.MockRoute{{Pattern: "i/notifications", Responses: tt.responses}},
+			})
+			bot := misskey.NewBotWithClient(&misskey.BotSetting{
+				Domain:           "example.com",
+				Token:            "token",
+				Client:           transport.Client(),
+				ReactionTriggers: []misskey.ReactionTrigger{{Reaction: ":amesh:", Command: "amesh"}},
+			})
+
+			var mentions, reactions []string
+			handlers := &misskey.EventHandlers{
+				OnMention: func(note *misskey.Note) {
+					mentions = append(mentions, note.ID)
+				},
+				OnReaction: func(event *misskey.ReactionEvent, _ *misskey.ReactionTrigger) {
+					reactions = append(reactions, event.Note.ID)
+				},
+			}
+			for range tt.polls {
+				// 取得の失敗は次の呼び出しで再取得する
+				_ = bot.PollNotifications(t.Context(), handlers)
+			}
+
+			if diff := cmp.Diff(tt.expectedMentions, mentions); diff != "" {
+				t.Errorf("mentions mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedReactions, reactions); diff != "" {
+				t.Errorf("reactions mismatch (-want +got):\n%s", diff)
+			}
+			var sinceIDs []any
+			for _, req := range transport.RequestsTo("i/notifications") {
+				var body map[string]any
+				if err := json.Unmarshal(req.Body, &body); err != nil {
+					t.Fatal(err)
+				}
+				sinceIDs = append(sinceIDs, body["sinceId"])
+			}
+			if diff := cmp.Diff(tt.expectedSinceIDs, sinceIDs); diff != "" {
+				t.Errorf("sinceId mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPollNotificationsNilHandler(t *testing.T) {
+	t.Parallel()
+	bot := misskey.NewBotWithClient(&misskey.BotSetting{Domain: "example.com", Token: "token", Client: http.DefaultClient})
+
+	if err := bot.PollNotifications(t.Context(), nil); err == nil {
+		t.Error("PollNotifications(nil) expected error")
+	}
+	if err := bot.PollNotifications(t.Context(), &misskey.EventHandlers{}); err == nil {
+		t.Error("PollNotifications() without OnMention expected error")
+	}
+}
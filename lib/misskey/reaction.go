@@ -0,0 +1,82 @@
+package misskey
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrInvalidReactionTrigger リアクションで実行するコマンドの設定値が不正であることを表すエラー
+var ErrInvalidReactionTrigger = errors.New("invalid reaction trigger")
+
+// ReactionTrigger ボットのノートへのリアクションで実行するコマンドの設定
+type ReactionTrigger struct {
+	Reaction string // リアクションの絵文字（カスタム絵文字は:amesh:の形式）
+	Command  string // 実行するコマンド名
+}
+
+// ReactionEvent ボットのノートにリアクションが付けられた通知
+type ReactionEvent struct {
+	Reaction string // リアクションの絵文字
+	Note     *Note  // リアクションが付けられたノート
+	User     User   // リアクションを付けたユーザー
+}
+
+// NormalizeReaction リアクションの絵文字を比較できる形式にする
+// カスタム絵文字のホスト（:amesh@.:や:amesh@example.com:）を取り除く
+func NormalizeReaction(reaction string) string {
+	reaction = strings.TrimSpace(reaction)
+	if len(reaction) < 2 || !strings.HasPrefix(reaction, ":") || !strings.HasSuffix(reaction, ":") {
+		return reaction
+	}
+	name, _, _ := strings.Cut(reaction[1:len(reaction)-1], "@")
+	return ":" + name + ":"
+}
+
+// ParseReactionTriggers リアクションで実行するコマンドの設定文字列を解析する
+// 設定は「絵文字=コマンド」をカンマ区切りで並べたもの（例: :amesh:=amesh,☔=amesh）
+func ParseReactionTriggers(s string) ([]ReactionTrigger, error) {
+	var triggers []ReactionTrigger
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		reaction, command, ok := strings.Cut(entry, "=")
+		trigger := ReactionTrigger{
+			Reaction: NormalizeReaction(reaction),
+			Command:  strings.TrimSpace(command),
+		}
+		if !ok || trigger.Reaction == "" || trigger.Reaction == "::" || trigger.Command == "" {
+			return nil, errors.Wrapf(ErrInvalidReactionTrigger, "entry: %s", entry)
+		}
+
+		triggers = append(triggers, trigger)
+	}
+	return triggers, nil
+}
+
+// reactionTrigger リアクションに対応する設定を返す（対応する設定がない場合はnil）
+func (bot *Bot) reactionTrigger(reaction string) *ReactionTrigger {
+	reaction = NormalizeReaction(reaction)
+	for i := range bot.BotSetting.ReactionTriggers {
+		if bot.BotSetting.ReactionTriggers[i].Reaction == reaction {
+			return &bot.BotSetting.ReactionTriggers[i]
+		}
+	}
+	return nil
+}
+
+// reactionEvent リアクションの通知からイベントを作成する
+// リアクションの通知でない場合や、対応する設定がない場合はfalseを返す
+func (bot *Bot) reactionEvent(notification *Notification) (*ReactionEvent, *ReactionTrigger, bool) {
+	if notification.Type != "reaction" || notification.Note == nil || notification.User == nil {
+		return nil, nil, false
+	}
+	trigger := bot.reactionTrigger(notification.Reaction)
+	if trigger == nil {
+		return nil, nil, false
+	}
+	return &ReactionEvent{Reaction: notification.Reaction, Note: notification.Note, User: *notification.User}, trigger, true
+}
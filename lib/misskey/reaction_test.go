@@ -0,0 +1,78 @@
+package misskey_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/misskey"
+)
+
+func TestNormalizeReaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "ローカルのカスタム絵文字", input: ":amesh@.:", expected: ":amesh:"},
+		{name: "リモートのカスタム絵文字", input: ":amesh@example.com:", expected: ":amesh:"},
+		{name: "ホストのないカスタム絵文字", input: ":amesh:", expected: ":amesh:"},
+		{name: "Unicodeの絵文字", input: "☔", expected: "☔"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := misskey.NormalizeReaction(tt.input); result != tt.expected {
+				t.Errorf("NormalizeReaction() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseReactionTriggers(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []misskey.ReactionTrigger
+		expectError error
+	}{
+		{
+			name:     "空文字列",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:  "カスタム絵文字とUnicodeの絵文字",
+			input: ":amesh@.:=amesh, ☔=amesh",
+			expected: []misskey.ReactionTrigger{
+				{Reaction: ":amesh:", Command: "amesh"},
+				{Reaction: "☔", Command: "amesh"},
+			},
+		},
+		{
+			name:        "コマンドがない",
+			input:       ":amesh:",
+			expectError: misskey.ErrInvalidReactionTrigger,
+		},
+		{
+			name:        "絵文字がない",
+			input:       "=amesh",
+			expectError: misskey.ErrInvalidReactionTrigger,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := misskey.ParseReactionTriggers(tt.input)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ParseReactionTriggers() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseReactionTriggers() diff: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package misskey
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/clock"
+	"hato-bot-go/lib/httpclient"
+)
+
+// DefaultRetryDelays NewPlatformで作成したPlatformが、返信のノートやチャットメッセージの作成に一時的に失敗した場合に再試行するまでの待ち時間
+var DefaultRetryDelays = []time.Duration{time.Second, 3 * time.Second}
+
+// isRetryable 再試行すれば成功する可能性のある失敗か
+// サーバーのエラー（5xx）・レート制限（429）・接続の失敗は再試行し、リクエストの内容による失敗やctxの終了、サーキットブレーカーの停止は再試行しない
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, httpclient.ErrCircuitOpen) {
+		return false
+	}
+	var statusErr *httpclient.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || http.StatusInternalServerError <= statusErr.StatusCode
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// withRetry fnを実行し、一時的な失敗の場合はdelaysの待ち時間ごとに再試行する
+// 再試行はloggerに記録し、最後の失敗のエラーを返す
+func withRetry(ctx context.Context, delays []time.Duration, clk clock.Clock, logger Logger, fn func() error) error {
+	err := fn()
+	for _, delay := range delays {
+		if err == nil || !isRetryable(ctx, err) {
+			return err
+		}
+		logger.Warn("Retrying", "delay", delay, "error", err)
+		if sleepErr := clock.Or(clk).Sleep(ctx, delay); sleepErr != nil {
+			return errors.Join(err, sleepErr)
+		}
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package misskey
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/bot"
+)
+
+// uploadedAttachment アップロードした添付ファイル
+type uploadedAttachment struct {
+	FileID  string // 返信に添付するファイルのID（サムネイルを作成した場合はサムネイルのID）
+	FullURL string // サムネイルを作成した場合の元の大きさの画像のURL（作成しない場合は空）
+}
+
+// uploadAttachment 添付ファイルをアップロードする
+// 幅か高さがThumbnailDimensionを超えるPNG画像は、元の大きさの画像と縮小したサムネイルの両方をアップロードする
+// アップロードしたファイルのIDは、返信に失敗した場合に削除できるようuploadedに追加する
+func (p *Platform) uploadAttachment(ctx context.Context, attachment *bot.Attachment, uploaded *[]string) (*uploadedAttachment, error) {
+	var reader io.Reader = attachment.Reader
+	var thumbnail *bytes.Buffer
+	if 0 < p.ThumbnailDimension && strings.EqualFold(path.Ext(attachment.FileName), ".png") {
+		data, err := io.ReadAll(attachment.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to io.ReadAll")
+		}
+		reader = bytes.NewReader(data)
+		thumbnail, err = thumbnailPNG(data, p.ThumbnailDimension)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to thumbnailPNG")
+		}
+	}
+
+	// エンコード結果をそのままMisskeyにアップロード
+	file, err := p.Bot.UploadFile(ctx, reader, attachment.FileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to UploadFile")
+	}
+	*uploaded = append(*uploaded, file.ID)
+	if thumbnail == nil {
+		return &uploadedAttachment{FileID: file.ID}, nil
+	}
+
+	thumbnailFile, err := p.Bot.UploadFile(ctx, thumbnail, thumbnailFileName(attachment.FileName))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to UploadFile")
+	}
+	*uploaded = append(*uploaded, thumbnailFile.ID)
+	return &uploadedAttachment{FileID: thumbnailFile.ID, FullURL: file.URL}, nil
+}
+
+// thumbnailPNG 幅か高さがdimensionを超えるPNG画像を縮小したサムネイルを返す
+// 収まっている場合はサムネイルを作成せずにnilを返す
+func thumbnailPNG(data []byte, dimension int) (*bytes.Buffer, error) {
+	imageConfig, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(amesh.ErrInvalidPNG, "Failed to png.DecodeConfig: %v", err)
+	}
+	if max(imageConfig.Width, imageConfig.Height) <= dimension {
+		return nil, nil
+	}
+	thumbnail, err := amesh.LimitPNG(bytes.NewReader(data), &amesh.ImageLimit{MaxDimension: dimension})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to amesh.LimitPNG")
+	}
+	return thumbnail, nil
+}
+
+// thumbnailFileName サムネイルのファイル名（amesh.pngはamesh_thumbnail.png）
+func thumbnailFileName(fileName string) string {
+	ext := path.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "_thumbnail" + ext
+}
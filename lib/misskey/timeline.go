@@ -0,0 +1,80 @@
+package misskey
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrInvalidTimelineChannel タイムラインチャンネルの設定値が不正であることを表すエラー
+var ErrInvalidTimelineChannel = errors.New("invalid timeline channel")
+
+// TimelineChannelKind 購読するタイムラインチャンネルの種類
+type TimelineChannelKind string
+
+const (
+	TimelineChannelHashtag TimelineChannelKind = "hashtag" // ハッシュタグタイムライン
+	TimelineChannelAntenna TimelineChannelKind = "antenna" // アンテナタイムライン
+)
+
+// TimelineChannel メンションなしでも応答するタイムラインチャンネルの設定
+type TimelineChannel struct {
+	Kind     TimelineChannelKind // チャンネルの種類
+	Target   string              // ハッシュタグ（#なし）またはアンテナID
+	Commands []string            // 応答を許可するコマンド名（空の場合は全て許可）
+}
+
+// ConnectionID ストリーミングでチャンネルを識別するID
+func (c *TimelineChannel) ConnectionID() string {
+	return fmt.Sprintf("%s:%s", c.Kind, c.Target)
+}
+
+// Allows コマンドへの応答が許可されているか
+func (c *TimelineChannel) Allows(command string) bool {
+	return len(c.Commands) == 0 || slices.Contains(c.Commands, command)
+}
+
+// connectParams チャンネル接続時に送信するパラメータ
+func (c *TimelineChannel) connectParams() map[string]any {
+	if c.Kind == TimelineChannelAntenna {
+		return map[string]any{"antennaId": c.Target}
+	}
+	return map[string]any{"q": [][]string{{c.Target}}}
+}
+
+// ParseTimelineChannels タイムラインチャンネルの設定文字列を解析する
+// 設定は「種類:対象=コマンド|コマンド」をカンマ区切りで並べたもの（例: hashtag:amesh=amesh,antenna:9abc）
+func ParseTimelineChannels(s string) ([]TimelineChannel, error) {
+	var channels []TimelineChannel
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		target, commands, _ := strings.Cut(entry, "=")
+		kind, target, ok := strings.Cut(target, ":")
+		target = strings.TrimPrefix(strings.TrimSpace(target), "#")
+		if !ok || target == "" {
+			return nil, errors.Wrapf(ErrInvalidTimelineChannel, "entry: %s", entry)
+		}
+
+		channel := TimelineChannel{
+			Kind:   TimelineChannelKind(strings.TrimSpace(kind)),
+			Target: target,
+		}
+		if channel.Kind != TimelineChannelHashtag && channel.Kind != TimelineChannelAntenna {
+			return nil, errors.Wrapf(ErrInvalidTimelineChannel, "kind: %s", kind)
+		}
+		for command := range strings.SplitSeq(commands, "|") {
+			if command = strings.TrimSpace(command); command != "" {
+				channel.Commands = append(channel.Commands, command)
+			}
+		}
+
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
@@ -0,0 +1,105 @@
+package misskey_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/misskey"
+)
+
+func TestParseTimelineChannels(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []misskey.TimelineChannel
+		expectError error
+	}{
+		{
+			name:     "空文字列",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:  "ハッシュタグとアンテナ",
+			input: "hashtag:amesh=amesh, antenna:9abc",
+			expected: []misskey.TimelineChannel{
+				{Kind: misskey.TimelineChannelHashtag, Target: "amesh", Commands: []string{"amesh"}},
+				{Kind: misskey.TimelineChannelAntenna, Target: "9abc"},
+			},
+		},
+		{
+			name:  "#付きのハッシュタグと複数のコマンド",
+			input: "hashtag:#weather=amesh|amedas",
+			expected: []misskey.TimelineChannel{
+				{Kind: misskey.TimelineChannelHashtag, Target: "weather", Commands: []string{"amesh", "amedas"}},
+			},
+		},
+		{
+			name:        "不明な種類",
+			input:       "global:amesh",
+			expectError: misskey.ErrInvalidTimelineChannel,
+		},
+		{
+			name:        "対象がない",
+			input:       "hashtag:",
+			expectError: misskey.ErrInvalidTimelineChannel,
+		},
+		{
+			name:        "種類がない",
+			input:       "amesh",
+			expectError: misskey.ErrInvalidTimelineChannel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := misskey.ParseTimelineChannels(tt.input)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ParseTimelineChannels() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseTimelineChannels() diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestTimelineChannelAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		channel  misskey.TimelineChannel
+		command  string
+		expected bool
+	}{
+		{
+			name:     "許可リストが空の場合は全て許可",
+			channel:  misskey.TimelineChannel{Kind: misskey.TimelineChannelHashtag, Target: "amesh"},
+			command:  "amesh",
+			expected: true,
+		},
+		{
+			name:     "許可リストに含まれる",
+			channel:  misskey.TimelineChannel{Kind: misskey.TimelineChannelHashtag, Target: "amesh", Commands: []string{"amesh"}},
+			command:  "amesh",
+			expected: true,
+		},
+		{
+			name:     "許可リストに含まれない",
+			channel:  misskey.TimelineChannel{Kind: misskey.TimelineChannelAntenna, Target: "9abc", Commands: []string{"amedas"}},
+			command:  "amesh",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := tt.channel.Allows(tt.command); result != tt.expected {
+				t.Errorf("Allows() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package misskey
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// 公開範囲の規則の条件（VisibilityRule.Match）
+// これらのほかに元ノートの公開範囲（public/home/followers/specified）を指定できる
+const (
+	VisibilityMatchAll    = "*"      // 全てのノート
+	VisibilityMatchRemote = "remote" // リモートのユーザーのノート
+	VisibilityMatchLocal  = "local"  // ローカルのユーザーのノート
+)
+
+// VisibilityFollow 元ノートと同じ公開範囲で返信することを表すVisibilityRule.Visibility
+const VisibilityFollow = "follow"
+
+// visibilityLocalOnlySuffix 規則の設定文字列でローカルのみの投稿を表す接尾辞
+const visibilityLocalOnlySuffix = "+local"
+
+// VisibilityRule 元ノートに応じて返信ノートの公開範囲を決める規則
+type VisibilityRule struct {
+	Match      string // 規則を適用する元ノートの条件（VisibilityMatchAllなど、または元ノートの公開範囲）
+	Visibility string // 返信ノートの公開範囲（VisibilityFollowの場合は元ノートに合わせる）
+	LocalOnly  bool   // 連合せずにローカルのみに投稿する
+}
+
+// DefaultVisibilityRules 返信方針で規則を指定しない場合の規則
+// 公開の元ノートにはホームで返信し、それ以外は元ノートに合わせる
+var DefaultVisibilityRules = []VisibilityRule{{Match: "public", Visibility: "home"}}
+
+// Validate 規則の設定値を検証する
+func (r VisibilityRule) Validate() error {
+	if r.Match != VisibilityMatchAll && r.Match != VisibilityMatchRemote && r.Match != VisibilityMatchLocal &&
+		!slices.Contains(visibilities, r.Match) {
+		return errors.Wrapf(ErrInvalidReplyPolicy, "visibility rule match: %s", r.Match)
+	}
+	if r.Visibility != VisibilityFollow && !slices.Contains(visibilities, r.Visibility) {
+		return errors.Wrapf(ErrInvalidReplyPolicy, "visibility rule visibility: %s", r.Visibility)
+	}
+	return nil
+}
+
+// Matches 元ノートが規則の条件に一致するか
+func (r VisibilityRule) Matches(note *Note) bool {
+	switch r.Match {
+	case VisibilityMatchAll:
+		return true
+	case VisibilityMatchRemote:
+		return note.User.Host != ""
+	case VisibilityMatchLocal:
+		return note.User.Host == ""
+	default:
+		return r.Match == note.Visibility
+	}
+}
+
+// ParseVisibilityRules 公開範囲の規則の設定文字列を解析する
+// 設定は「条件=公開範囲」をカンマ区切りで並べたもので、公開範囲に+localを付けるとローカルのみに投稿する
+// （例: remote=followers,public=home,*=follow+local）
+func ParseVisibilityRules(s string) ([]VisibilityRule, error) {
+	var rules []VisibilityRule
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		match, visibility, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidReplyPolicy, "visibility rule: %s", entry)
+		}
+		visibility = strings.TrimSpace(visibility)
+		rule := VisibilityRule{
+			Match:      strings.TrimSpace(match),
+			Visibility: strings.TrimSuffix(visibility, visibilityLocalOnlySuffix),
+			LocalOnly:  strings.HasSuffix(visibility, visibilityLocalOnlySuffix),
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// replyVisibility 返信ノートの公開範囲とローカルのみに投稿するかを決定する
+// 方針で公開範囲が指定されている場合はそれを使い、それ以外は最初に一致した規則を使う（一致しない場合は元ノートに合わせる）
+// いずれの場合も元ノートより広い公開範囲にはしない
+func replyVisibility(originalNote *Note, policy ReplyPolicy) (string, bool) {
+	if policy.Visibility != "" {
+		return narrowerVisibility(originalNote.Visibility, policy.Visibility), false
+	}
+
+	rules := policy.VisibilityRules
+	if rules == nil {
+		rules = DefaultVisibilityRules
+	}
+	for _, rule := range rules {
+		if !rule.Matches(originalNote) {
+			continue
+		}
+		if rule.Visibility == VisibilityFollow {
+			return originalNote.Visibility, rule.LocalOnly
+		}
+		return narrowerVisibility(originalNote.Visibility, rule.Visibility), rule.LocalOnly
+	}
+	return originalNote.Visibility, false
+}
+
+// narrowerVisibility 元ノートの公開範囲と指定した公開範囲のうち狭い方を返す
+func narrowerVisibility(original, visibility string) string {
+	if visibilityRank(original) < visibilityRank(visibility) {
+		return original
+	}
+	return visibility
+}
+
+// visibilityRank 公開範囲の広さを返す（狭いほど小さい）
+// 不明な公開範囲は最も広いものとして扱う
+func visibilityRank(visibility string) int {
+	index := slices.Index(visibilities, visibility)
+	if index < 0 {
+		return len(visibilities) + 1
+	}
+	return len(visibilities) - index
+}
@@ -0,0 +1,62 @@
+package misskey_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/misskey"
+)
+
+func TestParseVisibilityRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []misskey.VisibilityRule
+		expectError error
+	}{
+		{
+			name:     "空文字列",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:  "条件ごとの公開範囲とローカルのみ",
+			input: "remote=followers, public=home, *=follow+local",
+			expected: []misskey.VisibilityRule{
+				{Match: misskey.VisibilityMatchRemote, Visibility: "followers"},
+				{Match: "public", Visibility: "home"},
+				{Match: misskey.VisibilityMatchAll, Visibility: misskey.VisibilityFollow, LocalOnly: true},
+			},
+		},
+		{
+			name:        "公開範囲がない",
+			input:       "remote",
+			expectError: misskey.ErrInvalidReplyPolicy,
+		},
+		{
+			name:        "不明な条件",
+			input:       "bot=home",
+			expectError: misskey.ErrInvalidReplyPolicy,
+		},
+		{
+			name:        "不明な公開範囲",
+			input:       "remote=private",
+			expectError: misskey.ErrInvalidReplyPolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := misskey.ParseVisibilityRules(tt.input)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("ParseVisibilityRules() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if diff := cmp.Diff(result, tt.expected); diff != "" {
+				t.Errorf("ParseVisibilityRules() diff: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package misskey
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/gorilla/websocket"
+)
+
+// ErrNotConnected WebSocketに接続していないことを表すエラー
+var ErrNotConnected = errors.New("websocket not connected")
+
+// wsSendRequest 送信goroutineへの送信の要求
+type wsSendRequest struct {
+	message any        // 送信するJSONメッセージ
+	result  chan error // 送信の結果を受け取る
+}
+
+// setConn WebSocket接続を差し替え、古い接続を閉じる
+// 接続ごとに送信goroutineを起動し、書き込みはそのgoroutineだけが行う（gorilla/websocketは同時に1つの書き込みのみ許す）
+// 送信中のメッセージがある場合は送信が終わるまで待ってから差し替える
+func (bot *Bot) setConn(conn *websocket.Conn) {
+	bot.wsMu.Lock()
+	defer bot.wsMu.Unlock()
+
+	if bot.wsConn != nil {
+		close(bot.wsSends)
+		if err := bot.wsConn.Close(); err != nil {
+			bot.logger().Error("Failed to Close", "error", err)
+		}
+	}
+
+	bot.wsConn = conn
+	bot.wsSends = nil
+	if conn != nil {
+		bot.wsSends = make(chan wsSendRequest)
+		go sendLoop(conn, bot.wsSends)
+	}
+}
+
+// conn 現在のWebSocket接続を返す（接続していない場合はnil）
+func (bot *Bot) conn() *websocket.Conn {
+	bot.wsMu.RLock()
+	defer bot.wsMu.RUnlock()
+	return bot.wsConn
+}
+
+// sendLoop 送信の要求を受け取ってWebSocketに書き込む
+// 要求のチャネルが閉じられると終了する
+func sendLoop(conn *websocket.Conn, requests <-chan wsSendRequest) {
+	for request := range requests {
+		request.result <- conn.WriteJSON(request.message)
+	}
+}
+
+// writeJSON 送信goroutineを通してWebSocketにJSONメッセージを送信する
+// 複数のgoroutineから同時に呼び出せる
+func (bot *Bot) writeJSON(v any) error {
+	// 送信が終わるまで接続が差し替えられないよう読み込みロックを保持する
+	bot.wsMu.RLock()
+	defer bot.wsMu.RUnlock()
+
+	if bot.wsSends == nil {
+		return ErrNotConnected
+	}
+
+	result := make(chan error, 1)
+	bot.wsSends <- wsSendRequest{message: v, result: result}
+	if err := <-result; err != nil {
+		return errors.Wrap(err, "Failed to WriteJSON")
+	}
+	return nil
+}
+
+// connectChannel ストリーミングのチャンネルに接続する
+func (bot *Bot) connectChannel(channel, id string, params map[string]any) error {
+	body := map[string]any{
+		"channel": channel,
+		"id":      id,
+	}
+	if params != nil {
+		body["params"] = params
+	}
+
+	connectMsg := struct {
+		Type string         `json:"type"`
+		Body map[string]any `json:"body,omitempty"`
+	}{
+		Type: "connect",
+		Body: body,
+	}
+
+	if err := bot.writeJSON(connectMsg); err != nil {
+		return errors.Wrap(err, "Failed to writeJSON")
+	}
+	return nil
+}
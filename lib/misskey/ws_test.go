@@ -0,0 +1,135 @@
+package misskey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gorilla/websocket"
+)
+
+// testConn テスト用サーバーへのWebSocket接続
+type testConn struct {
+	conn     *websocket.Conn
+	received <-chan int // 接続を閉じた後にサーバーが受信したメッセージの数を受け取る
+}
+
+// newTestConn メッセージを受信して数えるWebSocketサーバーに接続する
+func newTestConn(t *testing.T) *testConn {
+	t.Helper()
+	received := make(chan int, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		count := 0
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				received <- count
+				return
+			}
+			count++
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(t.Context(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != nil {
+		if err := resp.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &testConn{conn: conn, received: received}
+}
+
+func TestConnectChannelConcurrent(t *testing.T) {
+	tests := []struct {
+		name    string
+		writers int
+	}{
+		{
+			name:    "1つのgoroutineから書き込む",
+			writers: 1,
+		},
+		{
+			name:    "複数のgoroutineから同時に書き込む",
+			writers: 32,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testConn := newTestConn(t)
+			bot := &Bot{BotSetting: &BotSetting{Domain: "example.com", Token: "token", Client: http.DefaultClient}}
+			bot.setConn(testConn.conn)
+
+			var wg sync.WaitGroup
+			for range tt.writers {
+				wg.Go(func() {
+					if err := bot.connectChannel("main", "main", nil); err != nil {
+						t.Errorf("connectChannel() error = %v", err)
+					}
+				})
+			}
+			wg.Wait()
+
+			// 接続を閉じるとサーバーが受信したメッセージの数を返す
+			bot.setConn(nil)
+			if count := <-testConn.received; count != tt.writers {
+				t.Errorf("received messages = %d, want %d", count, tt.writers)
+			}
+		})
+	}
+}
+
+func TestWriteJSONNotConnected(t *testing.T) {
+	t.Parallel()
+	bot := &Bot{BotSetting: &BotSetting{Domain: "example.com", Token: "token", Client: http.DefaultClient}}
+
+	if err := bot.writeJSON(map[string]any{"type": "connect"}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("writeJSON() error = %v, want %v", err, ErrNotConnected)
+	}
+}
+
+// TestSetConnWhileWriting 書き込み中に接続を差し替えてもメッセージを失わないことを確認する
+func TestSetConnWhileWriting(t *testing.T) {
+	t.Parallel()
+	const writers = 32
+	first := newTestConn(t)
+	second := newTestConn(t)
+	bot := &Bot{BotSetting: &BotSetting{Domain: "example.com", Token: "token", Client: http.DefaultClient}}
+	bot.setConn(first.conn)
+
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Go(func() {
+			if err := bot.connectChannel("main", "main", nil); err != nil {
+				t.Errorf("connectChannel() error = %v", err)
+			}
+		})
+		if i == writers/2 {
+			bot.setConn(second.conn)
+		}
+	}
+	wg.Wait()
+
+	if bot.conn() != second.conn {
+		t.Error("conn() did not return the replaced connection")
+	}
+	bot.setConn(nil)
+	if count := <-first.received + <-second.received; count != writers {
+		t.Errorf("received messages = %d, want %d", count, writers)
+	}
+}
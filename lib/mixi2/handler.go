@@ -6,7 +6,6 @@ package mixi2
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -22,33 +21,59 @@ import (
 
 	"hato-bot-go/lib"
 	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/bot"
+	"hato-bot-go/lib/convert"
+	"hato-bot-go/lib/history"
 	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/report"
+	"hato-bot-go/lib/translate"
 )
 
 type HandlerSetting struct {
 	Conn          *grpc.ClientConn
 	Authenticator auth.Authenticator
 	YahooAPIToken string
+	Locale        i18n.Locale              // 返信メッセージの言語（空の場合はi18n.DefaultLocale）
+	Templates     *i18n.Templates          // 返信テンプレート（nilの場合はメッセージカタログの文言）
+	Reporter      *report.Reporter         // エラーの報告先（nilの場合は報告しない）
+	Timeouts      map[string]time.Duration // コマンド名ごとの処理の制限時間（ない場合はbot.DefaultTimeout）
+	RateLimiter   *bot.RateLimiter         // 送信者ごとのコマンドの実行回数の制限（nilの場合は制限しない）
+	History       *history.Store           // コマンドの処理の履歴の保存先（nilの場合は保存しない）
+	Admins        []string                 // statsコマンド・admin selftestコマンド・admin reloadコマンドを使える送信者のID
+	Reloader      bot.Reloader             // admin reloadコマンドで設定ファイルを読み込み直す処理（nilの場合はadmin reloadコマンドを使わない）
+	Disabled      []string                 // 設定ファイルで無効にしたコマンド名
+	Translator    translate.Translator     // translateコマンドの翻訳サービス（nilの場合はtranslateコマンドを使わない）
+	Aliases       map[string]string        // コマンドの別名からコマンド名への対応
+	ImageLimit    *amesh.ImageLimit        // 返信に添付するPNG画像の大きさの上限（nilの場合は制限しない）
 }
 
+// uploadClient 取得したuploadURLへのメディアのアップロードに使うHTTPクライアント
+// 1回のリクエストの制限時間はDefaultTransportが適用し、コマンドの制限時間はリクエストのcontextで適用する
+var uploadClient = &http.Client{Transport: httpclient.DefaultTransport}
+
 type uploadFileParams struct {
 	description string
 	buffer      *bytes.Buffer
 }
 
-// processAmeshCommandParams ameshコマンドの処理パラメータ
-type processAmeshCommandParams struct {
-	Place         string
-	YahooAPIToken string
-	PostID        string
-	PostMask      *modelv1.PostMask
-}
-
 // Handler event.EventHandlerインターフェースを実装する
 type Handler struct {
 	APIClient     application_apiv1.ApplicationServiceClient
 	Authenticator auth.Authenticator
 	YahooAPIToken string
+	Locale        i18n.Locale
+	Templates     *i18n.Templates
+	Reporter      *report.Reporter
+	Timeouts      map[string]time.Duration
+	RateLimiter   *bot.RateLimiter
+	History       *history.Store
+	Admins        []string
+	Reloader      bot.Reloader
+	Disabled      []string
+	Translator    translate.Translator
+	Aliases       map[string]string
+	ImageLimit    *amesh.ImageLimit
 }
 
 // NewHandler 新しいHandlerを作成する
@@ -57,10 +82,23 @@ func NewHandler(config *HandlerSetting) *Handler {
 		APIClient:     application_apiv1.NewApplicationServiceClient(config.Conn),
 		Authenticator: config.Authenticator,
 		YahooAPIToken: config.YahooAPIToken,
+		Locale:        config.Locale,
+		Templates:     config.Templates,
+		Reporter:      config.Reporter,
+		Timeouts:      config.Timeouts,
+		RateLimiter:   config.RateLimiter,
+		History:       config.History,
+		Admins:        config.Admins,
+		Reloader:      config.Reloader,
+		Disabled:      config.Disabled,
+		Translator:    config.Translator,
+		Aliases:       config.Aliases,
+		ImageLimit:    config.ImageLimit,
 	}
 }
 
 // uploadMedia 取得したuploadURLにメディアデータを送信する
+// コマンドの制限時間を超えた場合やctxがキャンセルされた場合は送信中のアップロードを中断する
 func (h *Handler) uploadMedia(ctx context.Context, uploadURL string, buffer *bytes.Buffer) (err error) {
 	// アクセストークンを取得
 	accessToken, err := h.Authenticator.GetAccessToken(ctx)
@@ -69,7 +107,7 @@ func (h *Handler) uploadMedia(ctx context.Context, uploadURL string, buffer *byt
 	}
 
 	// アップロードリクエストを作成
-	req, err := http.NewRequest(http.MethodPost, uploadURL, buffer)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, buffer)
 	if err != nil {
 		return errors.Wrap(err, "Failed to http.NewRequestWithContext")
 	}
@@ -77,9 +115,8 @@ func (h *Handler) uploadMedia(ctx context.Context, uploadURL string, buffer *byt
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Content-Type", "application/octet-stream")
 
-	// タイムアウト付きでアップロードを実行
 	// jscpd:ignore-start
-	resp, err := httpclient.ExecuteHTTPRequest(&http.Client{Timeout: 30 * time.Second}, req)
+	resp, err := httpclient.ExecuteHTTPRequest(uploadClient, req)
 	if err != nil {
 		return errors.Wrap(err, "Failed to httpclient.ExecuteHTTPRequest")
 	}
@@ -157,57 +194,97 @@ func (h *Handler) uploadFile(ctx context.Context, params *uploadFileParams) (str
 	return mediaID, nil
 }
 
-// processAmeshCommand ameshコマンドを処理
-func (h *Handler) processAmeshCommand(ctx context.Context, authCtx context.Context, params *processAmeshCommandParams) error {
-	if params == nil {
-		return lib.ErrParamsNil
+// stampIDs リアクションに対応するmixi2のスタンプ
+var stampIDs = map[bot.Reaction]string{
+	bot.ReactionProcessing: "o_eye",
+}
+
+// engine コマンドを実行して返信するエンジンを返す
+func (h *Handler) engine() *bot.Engine {
+	return bot.NewEngine(&bot.EngineSetting{
+		Platform:      h,
+		Commands:      append(bot.DefaultCommands(h.YahooAPIToken, h.Translator), &convert.Command{}),
+		Templates:     h.Templates,
+		Reporter:      h.Reporter,
+		Timeouts:      h.Timeouts,
+		RateLimiter:   h.RateLimiter,
+		History:       h.History,
+		Admins:        h.Admins,
+		Reloader:      h.Reloader,
+		Disabled:      h.Disabled,
+		YahooAPIToken: h.YahooAPIToken,
+		Aliases:       h.Aliases,
+		ImageLimit:    h.ImageLimit,
+	})
+}
+
+// Name プラットフォーム名
+func (h *Handler) Name() string {
+	return "mixi2"
+}
+
+// TemplateData ポストの投稿者への返信テンプレートに渡す変数を返す
+func (h *Handler) TemplateData(message *bot.IncomingMessage) *i18n.TemplateData {
+	templateData := &i18n.TemplateData{Locale: h.Locale}
+	if post, ok := message.Raw.(*modelv1.Post); ok {
+		templateData.User = post.GetCreatorId()
 	}
-	if params.PostID == "" {
-		return lib.ErrParamsEmptyString
+	return templateData
+}
+
+// React ポストにリアクションに対応するスタンプを付ける
+// 対応するスタンプがない場合は何もしない
+func (h *Handler) React(ctx context.Context, message *bot.IncomingMessage, reaction bot.Reaction) error {
+	stampID, ok := stampIDs[reaction]
+	if !ok {
+		return nil
 	}
 
-	// 処理中リアクションを追加
-	if _, err := h.APIClient.AddStampToPost(authCtx, &application_apiv1.AddStampToPostRequest{
-		PostId:  params.PostID,
-		StampId: "o_eye",
+	if _, err := h.APIClient.AddStampToPost(ctx, &application_apiv1.AddStampToPostRequest{
+		PostId:  message.ID,
+		StampId: stampID,
 	}); err != nil {
 		return errors.Wrap(err, "Failed to APIClient.AddStampToPost")
 	}
+	return nil
+}
 
-	// 位置を解析してログに出力
-	location, err := amesh.ParseLocationWithLog(ctx, params.Place, params.YahooAPIToken)
-	if err != nil {
-		return errors.Wrap(err, "Failed to amesh.ParseLocationWithLog")
+// Reply 添付ファイルをアップロードし、ポストに返信する
+func (h *Handler) Reply(ctx context.Context, message *bot.IncomingMessage, reply *bot.OutgoingReply) error {
+	post, ok := message.Raw.(*modelv1.Post)
+	if !ok || post == nil {
+		return lib.ErrParamsNil
 	}
 
-	description := fmt.Sprintf("%s (%.4f, %.4f) の雨雲レーダー画像", location.PlaceName, location.Lat, location.Lng)
-
-	// 画像をメモリ上に作成
-	imageBuffer, err := amesh.CreateImageBuffer(ctx, location)
-	if err != nil {
-		return errors.Wrap(err, "Failed to amesh.CreateImageBuffer")
-	}
+	var mediaIDs []string
+	for _, attachment := range reply.Attachments {
+		// アップロード開始時にサイズが必要なため、メモリ上に読み出す
+		buffer := &bytes.Buffer{}
+		if _, err := buffer.ReadFrom(attachment.Reader); err != nil {
+			return errors.Wrap(err, "Failed to ReadFrom")
+		}
 
-	// mixi2にメモリから直接アップロード
-	mediaID, err := h.uploadFile(authCtx, &uploadFileParams{
-		description: description,
-		buffer:      imageBuffer,
-	})
-	if err != nil {
-		return errors.Wrap(err, "Failed to uploadFile")
+		// mixi2にメモリから直接アップロード
+		mediaID, err := h.uploadFile(ctx, &uploadFileParams{
+			description: attachment.Description,
+			buffer:      buffer,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Failed to uploadFile")
+		}
+		mediaIDs = append(mediaIDs, mediaID)
 	}
 
 	// 結果をポストとして投稿
-	if _, err := h.APIClient.CreatePost(authCtx, &application_apiv1.CreatePostRequest{
-		Text:            fmt.Sprintf("📡 %sだっぽ", description),
-		MediaIdList:     []string{mediaID},
-		InReplyToPostId: &params.PostID,
-		PostMask:        params.PostMask,
+	postID := post.GetPostId()
+	if _, err := h.APIClient.CreatePost(ctx, &application_apiv1.CreatePostRequest{
+		Text:            reply.Text,
+		MediaIdList:     mediaIDs,
+		InReplyToPostId: &postID,
+		PostMask:        post.GetPostMask(),
 	}); err != nil {
 		return errors.Wrap(err, "Failed to APIClient.CreatePost")
 	}
-
-	log.Printf("Successfully processed amesh command for %s", location.PlaceName)
 	return nil
 }
 
@@ -217,6 +294,8 @@ func (h *Handler) Handle(ctx context.Context, event *modelv1.Event) error {
 		return nil
 	}
 
+	defer h.Reporter.Recover(ctx, map[string]string{"platform": "mixi2"})
+
 	log.Printf("received POST_CREATED event: event_id=%s\n", event.GetEventId())
 	postCreatedEvent := event.GetPostCreatedEvent()
 
@@ -246,44 +325,27 @@ func (h *Handler) Handle(ctx context.Context, event *modelv1.Event) error {
 		return lib.ErrParamsEmptyString
 	}
 
+	message := &bot.IncomingMessage{ID: postID, Text: text, UserID: post.GetCreatorId(), Raw: post}
 	postMask := post.GetPostMask()
 
 	if postMask != nil {
-		postMask.Caption = "隠すっぽ！"
+		postMask.Caption = h.Templates.Render(i18n.KeyReplyCW, h.TemplateData(message))
 	}
 
-	// ameshコマンドを解析
-	parseResult := amesh.ParseAmeshCommand(text)
-
-	if !parseResult.IsAmesh {
+	// コマンドでない場合は認証せずに終了
+	engine := h.engine()
+	if engine.Command(text) == nil {
 		return nil
 	}
 
-	log.Printf("Processing amesh command for place: %s", parseResult.Place)
-
 	authCtx, err := h.Authenticator.AuthorizedContext(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to Authenticator.AuthorizedContext")
 	}
 
-	// ameshコマンドを処理
-	if err := h.processAmeshCommand(ctx, authCtx, &processAmeshCommandParams{
-		Place:         parseResult.Place,
-		YahooAPIToken: h.YahooAPIToken,
-		PostID:        postID,
-		PostMask:      postMask,
-	}); err != nil {
-		log.Printf("Error processing amesh command: %v", err)
-
-		// エラーメッセージを投稿
-		if _, err := h.APIClient.CreatePost(authCtx, &application_apiv1.CreatePostRequest{
-			Text:            "申し訳ないっぽ。ameshコマンドの処理中にエラーが発生したっぽ",
-			InReplyToPostId: &postID,
-			PostMask:        postMask,
-		}); err != nil {
-			return errors.Wrap(err, "Failed to APIClient.CreatePost")
-		}
+	// コマンドを実行して返信
+	if err := engine.Handle(authCtx, message); err != nil {
+		return errors.Wrap(err, "Failed to Handle")
 	}
-
 	return nil
 }
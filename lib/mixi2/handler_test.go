@@ -1,7 +1,12 @@
 package mixi2
 
 import (
+	"bytes"
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/cockroachdb/errors"
@@ -26,6 +31,14 @@ func mentionedPostCreatedEvent(post *modelv1.Post) *modelv1.Event {
 	}
 }
 
+// errorReplyRequest エラーメッセージの返信のリクエストを本文と返信先のポストIDで照合するMatcher
+// 本文の後に添える問い合わせIDは実行ごとに変わるため、本文は前方一致で照合する
+func errorReplyRequest(text, postID string) gomock.Matcher {
+	return gomock.Cond(func(req *apiv1.CreatePostRequest) bool {
+		return strings.HasPrefix(req.GetText(), text) && req.GetInReplyToPostId() == postID && len(req.GetMediaIdList()) == 0
+	})
+}
+
 func TestHandle(t *testing.T) {
 	errAuthFailed := errors.New("認証に失敗しました")
 
@@ -125,17 +138,15 @@ func TestHandle(t *testing.T) {
 				mockAuth.EXPECT().
 					AuthorizedContext(ctx).
 					Return(ctx, nil)
+				// コマンドは制限時間とリクエストIDを付けたcontextで実行するため、contextは照合しない
 				mockClient.EXPECT().
-					AddStampToPost(ctx, &apiv1.AddStampToPostRequest{
+					AddStampToPost(gomock.Any(), &apiv1.AddStampToPostRequest{
 						PostId:  postID,
 						StampId: "o_eye",
 					}).
 					Return(nil, errors.New("スタンプ追加エラー"))
 				mockClient.EXPECT().
-					CreatePost(ctx, &apiv1.CreatePostRequest{
-						Text:            "申し訳ないっぽ。ameshコマンドの処理中にエラーが発生したっぽ",
-						InReplyToPostId: &postID,
-					}).
+					CreatePost(gomock.Any(), errorReplyRequest("申し訳ないっぽ。ameshコマンドの処理中にエラーが発生したっぽ", postID)).
 					Return(&apiv1.CreatePostResponse{}, nil)
 				return &Handler{
 					Authenticator: mockAuth,
@@ -146,6 +157,35 @@ func TestHandle(t *testing.T) {
 			ev:          mentionedPostCreatedEvent(&modelv1.Post{PostId: "post123", Text: "amesh 東京"}),
 			expectError: nil,
 		},
+		{
+			name: "amedasコマンドでAddStampToPostが失敗してもエラーメッセージを投稿して正常終了",
+			makeHandler: func(t *testing.T) *Handler {
+				ctrl := gomock.NewController(t)
+				mockAuth := NewMockAuthenticator(ctrl)
+				mockClient := NewMockApplicationServiceClient(ctrl)
+				ctx := t.Context()
+				postID := "post123"
+				mockAuth.EXPECT().
+					AuthorizedContext(ctx).
+					Return(ctx, nil)
+				// コマンドは制限時間とリクエストIDを付けたcontextで実行するため、contextは照合しない
+				mockClient.EXPECT().
+					AddStampToPost(gomock.Any(), &apiv1.AddStampToPostRequest{
+						PostId:  postID,
+						StampId: "o_eye",
+					}).
+					Return(nil, errors.New("スタンプ追加エラー"))
+				mockClient.EXPECT().
+					CreatePost(gomock.Any(), errorReplyRequest("申し訳ないっぽ。amedasコマンドの処理中にエラーが発生したっぽ", postID)).
+					Return(&apiv1.CreatePostResponse{}, nil)
+				return &Handler{
+					Authenticator: mockAuth,
+					APIClient:     mockClient,
+				}
+			},
+			ev:          mentionedPostCreatedEvent(&modelv1.Post{PostId: "post123", Text: "amedas 東京"}),
+			expectError: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -158,3 +198,56 @@ func TestHandle(t *testing.T) {
 		})
 	}
 }
+
+// TestUploadMedia アップロードがコマンドのcontextに従うことをテストする
+func TestUploadMedia(t *testing.T) {
+	tests := []struct {
+		name          string
+		cancel        bool
+		expectError   error
+		expectUploads int32
+	}{
+		{
+			name:          "アップロードする",
+			expectUploads: 1,
+		},
+		{
+			name:          "キャンセルされたcontextでは送信しない",
+			cancel:        true,
+			expectError:   context.Canceled,
+			expectUploads: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var uploads atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				uploads.Add(1)
+				if got := r.Header.Get("Authorization"); got != "Bearer token" {
+					t.Errorf("Authorization = %q, want %q", got, "Bearer token")
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			mockAuth := NewMockAuthenticator(gomock.NewController(t))
+			mockAuth.EXPECT().GetAccessToken(gomock.Any()).Return("token", nil)
+			if tt.cancel {
+				cancel()
+			}
+
+			handler := &Handler{Authenticator: mockAuth}
+			err := handler.uploadMedia(ctx, server.URL, bytes.NewBufferString("image"))
+			if !errors.Is(err, tt.expectError) {
+				t.Errorf("uploadMedia() error = %v, expectError = %v", err, tt.expectError)
+			}
+			if got := uploads.Load(); got != tt.expectUploads {
+				t.Errorf("uploads = %d, want %d", got, tt.expectUploads)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package mixi2
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mixigroup/mixi2-application-sdk-go/auth"
+	"github.com/mixigroup/mixi2-application-sdk-go/event/stream"
+	application_streamv1 "github.com/mixigroup/mixi2-application-sdk-go/gen/go/social/mixi/application/service/application_stream/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"hato-bot-go/lib/app"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/report"
+)
+
+// Run mixi2ボットとして実行する
+// ctxがキャンセルされるとストリームの監視を終了する
+func Run(ctx context.Context, common *app.Common, _ []string) (err error) {
+	// 環境変数から設定を取得
+	streamAddress := os.Getenv("MIXI2_STREAM_ADDRESS")
+	clientID := os.Getenv("MIXI2_CLIENT_ID")
+	clientSecret := os.Getenv("MIXI2_CLIENT_SECRET")
+	tokenURL := os.Getenv("MIXI2_TOKEN_URL")
+	apiAddress := os.Getenv("MIXI2_API_ADDRESS")
+
+	if streamAddress == "" || clientID == "" || clientSecret == "" || tokenURL == "" || apiAddress == "" {
+		return errors.New("MIXI2_STREAM_ADDRESS, MIXI2_CLIENT_ID, MIXI2_CLIENT_SECRET, MIXI2_TOKEN_URL and MIXI2_API_ADDRESS environment variables must be set")
+	}
+	streamAddress = strings.NewReplacer("\n", "", "\r", "").Replace(streamAddress)
+
+	yahooAPIToken := os.Getenv("YAHOO_API_TOKEN")
+
+	// Yahoo APIキーも設定ファイルのジオコーダもない場合は埋め込みの地名の一覧だけで地名を探す
+	if yahooAPIToken == "" && common.Geocoder == nil {
+		log.Println("YAHOO_API_TOKEN is not set: place names are resolved from the embedded gazetteer only")
+	}
+
+	// エラー報告を設定（シークレットは送信内容から除去する）
+	reporter, err := report.NewReporterFromEnv(clientSecret, yahooAPIToken)
+	if err != nil {
+		return errors.Wrap(err, "Failed to report.NewReporterFromEnv")
+	}
+
+	withTransportCredentials := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS13,
+	}))
+
+	// gRPCストリーム接続確立
+	streamConn, err := grpc.NewClient(streamAddress, withTransportCredentials)
+	if err != nil {
+		return errors.Wrap(err, "Failed to grpc.NewClient")
+	}
+	defer func(streamConn *grpc.ClientConn) {
+		if closeErr := streamConn.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(streamConn)
+
+	// gRPC API接続確立
+	apiConn, err := grpc.NewClient(apiAddress, withTransportCredentials)
+	if err != nil {
+		return errors.Wrap(err, "Failed to grpc.NewClient")
+	}
+	defer func(apiConn *grpc.ClientConn) {
+		if closeErr := apiConn.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(apiConn)
+
+	log.Println("hato-bot-go started")
+
+	// 認証クライアント作成
+	authenticator, err := auth.NewAuthenticator(clientID, clientSecret, tokenURL)
+	if err != nil {
+		return errors.Wrap(err, "Failed to auth.NewAuthenticator")
+	}
+
+	log.Printf("starting stream watcher: address=%s\n", streamAddress) //nolint:gosec //G706
+
+	// 監視開始
+	if err := stream.NewStreamWatcher(
+		application_streamv1.NewApplicationServiceClient(streamConn),
+		authenticator,
+	).Watch(ctx, NewHandler(&HandlerSetting{
+		Conn:          apiConn,
+		Authenticator: authenticator,
+		YahooAPIToken: yahooAPIToken,
+		Locale:        i18n.ParseLocale(os.Getenv("MIXI2_LOCALE")),
+		Templates:     common.Templates,
+		Reporter:      reporter,
+		Timeouts:      common.CommandTimeouts,
+		RateLimiter:   common.RateLimiter,
+		History:       common.History,
+		Admins:        common.Config.Admins,
+		Reloader:      common.Reloader,
+		Disabled:      common.DisabledFeatures,
+		Translator:    common.Translator,
+		Aliases:       common.Aliases,
+		ImageLimit:    common.ImageLimits["mixi2"],
+	})); err != nil && !errors.Is(err, context.Canceled) {
+		// ストリームが終了した場合は運用者に報告する
+		reporter.Report(context.Background(), &report.Event{
+			Level:   report.LevelFatal,
+			Message: "mixi2 stream watcher stopped",
+			Err:     err,
+			Tags:    map[string]string{"platform": "mixi2"},
+		})
+		return errors.Wrap(err, "Failed to Watch")
+	}
+
+	log.Println("stopped")
+	return nil
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// mentionPattern Misskeyのメンション（@user・@user@example.com）と直後の区切りの記号
+// 単語の途中の@（メールアドレスなど）はメンションとして扱わない
+var mentionPattern = regexp.MustCompile(`(^|[^\w@.])@\w+(?:@[\w-]+(?:\.[\w-]+)*)?[:,、]*`)
+
+// mfmFunctionPattern MFMの関数（$[x2 …]や$[fg.color=f00 …]）の開始
+var mfmFunctionPattern = regexp.MustCompile(`^\$\[\w+(?:\.[\w.,=-]*)?\s`)
+
+// mfmDecorationReplacer MFMの装飾のタグと記号を取り除く
+// 段落を分けるcenterは空白に、文中に置く装飾は詰めて取り除く
+var mfmDecorationReplacer = strings.NewReplacer(
+	"<center>", " ", "</center>", " ",
+	"<small>", "", "</small>", "",
+	"<plain>", "", "</plain>", "",
+	"<i>", "", "</i>", "",
+	"<b>", "", "</b>", "",
+	"<s>", "", "</s>", "",
+	"***", "", "**", "", "~~", "",
+)
+
+// NormalizeMessage コマンドを解析する前にメッセージの表記ゆれを取り除く
+// NFKCで全角の英数字・記号や半角のカタカナをそろえ、メンションとMFMの装飾を取り除き、不可視の区切り文字を空白にする
+func NormalizeMessage(text string) string {
+	text = norm.NFKC.String(text)
+	text = StripMentions(text)
+	text = StripMFM(text)
+	return invisibleSeparatorReplacer.Replace(text)
+}
+
+// StripMentions メンションを空白に置き換える
+// 改行や全角スペースの直後や、不可視文字で区切られたメンションも取り除く
+func StripMentions(text string) string {
+	return mentionPattern.ReplaceAllString(invisibleSeparatorReplacer.Replace(text), "$1 ")
+}
+
+// StripMFM MFMの関数（入れ子も含む）と装飾を取り除き、中身の文字列だけを残す
+func StripMFM(text string) string {
+	text = mfmDecorationReplacer.Replace(text)
+	if !strings.Contains(text, "$[") {
+		return text
+	}
+
+	var builder strings.Builder
+	depth := 0
+	for i := 0; i < len(text); {
+		if text[i] == '$' {
+			if match := mfmFunctionPattern.FindStringIndex(text[i:]); match != nil {
+				depth++
+				i += match[1]
+				builder.WriteByte(' ')
+				continue
+			}
+		}
+		if text[i] == ']' && 0 < depth {
+			depth--
+			i++
+			builder.WriteByte(' ')
+			continue
+		}
+		builder.WriteByte(text[i])
+		i++
+	}
+	return builder.String()
+}
@@ -0,0 +1,67 @@
+package lib_test
+
+import (
+	"strings"
+	"testing"
+
+	"hato-bot-go/lib"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "全角の英数字と空白", text: "ａｍｅｓｈ　１２３", expected: "amesh 123"},
+		{name: "半角のカタカナ", text: "ﾄｳｷｮｳ", expected: "トウキョウ"},
+		{name: "ローカルのメンション", text: "@bot amesh", expected: "amesh"},
+		{name: "リモートのメンション", text: "@bot@example.com amesh", expected: "amesh"},
+		{name: "全角の@のメンション", text: "＠ｂｏｔ amesh", expected: "amesh"},
+		{name: "改行の直後のメンション", text: "こんにちは\n@bot amesh", expected: "こんにちは amesh"},
+		{name: "メールアドレスは残す", text: "info@example.com", expected: "info@example.com"},
+		{name: "MFMの装飾", text: "**amesh** ~~東京~~", expected: "amesh 東京"},
+		{name: "MFMのタグ", text: "<small>amesh</small> <b>東京</b>", expected: "amesh 東京"},
+		{name: "MFMの関数", text: "$[x2 amesh] $[fg.color=f00 東京]", expected: "amesh 東京"},
+		{name: "入れ子のMFMの関数", text: "$[spin.speed=2s $[x3 amesh]]", expected: "amesh"},
+		{name: "関数でない角括弧", text: "$[amesh] [東京]", expected: "$[amesh] [東京]"},
+		{name: "ゼロ幅スペース", text: "amesh​東京", expected: "amesh 東京"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := strings.Join(strings.Fields(lib.NormalizeMessage(tt.text)), " ")
+			if got != tt.expected {
+				t.Errorf("NormalizeMessage(%q) = %q, want %q", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		expectedName string
+		expectedArgs string
+		expectedOK   bool
+	}{
+		{name: "コマンドと引数", text: "amesh 東京 駅", expectedName: "amesh", expectedArgs: "東京 駅", expectedOK: true},
+		{name: "ハッシュタグ", text: "#amesh 東京", expectedName: "amesh", expectedArgs: "東京", expectedOK: true},
+		{name: "記号が続くリモートのメンション", text: "@bot@example.com:amesh 東京", expectedName: "amesh", expectedArgs: "東京", expectedOK: true},
+		{name: "メンションのみ", text: "@bot @user@example.com", expectedOK: false},
+		{name: "空", text: "", expectedOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			name, args, ok := lib.SplitCommand(tt.text)
+			if name != tt.expectedName || args != tt.expectedArgs || ok != tt.expectedOK {
+				t.Errorf("SplitCommand(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.text, name, args, ok, tt.expectedName, tt.expectedArgs, tt.expectedOK)
+			}
+		})
+	}
+}
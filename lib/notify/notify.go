@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/metrics"
+)
+
+var (
+	// ErrUnknownNotifierType 存在しない送信先の種類を指定したことを表すエラー
+	ErrUnknownNotifierType = errors.New("unknown notifier type")
+	// ErrNotifierURLRequired 送信先のURLが設定されていないことを表すエラー
+	ErrNotifierURLRequired = errors.New("notifier url is required")
+)
+
+// 送信先の種類
+const (
+	TypeSlack   = "slack"   // Slackのincoming webhook
+	TypeDiscord = "discord" // DiscordのWebhook
+	TypeWebhook = "webhook" // 任意のURLにJSONをPOSTするWebhook
+)
+
+// Notification 送信する通知の内容
+type Notification struct {
+	Text     string               // 本文
+	Image    []byte               // PNG形式の画像（nilの場合は画像なし）
+	FileName string               // 画像のファイル名
+	ImageURL string               // 画像を取得できるURL（Slackなど画像を添付できない送信先で使う）
+	Metadata *amesh.AmeshMetadata // 画像の作成に使ったデータの情報（nilの場合は送信しない）
+}
+
+// Notifier 通知の送信先
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// Target 名前付きの送信先
+type Target struct {
+	Name     string   // ログに表示する名前
+	Notifier Notifier // 送信先
+}
+
+// DispatcherSetting 通知の送信の設定
+type DispatcherSetting struct {
+	Targets []Target          // 送信先
+	Metrics *metrics.Registry // 送信数などを記録するレジストリ（nilの場合はmetrics.Default）
+}
+
+// Dispatcher 設定したすべての送信先に通知を送信する
+// レシーバーがnilの場合は何もしない
+type Dispatcher struct {
+	targets []Target
+	sent    *metrics.Counter
+	failed  *metrics.Counter
+}
+
+// NewDispatcher 新しいDispatcherを作成する
+func NewDispatcher(setting *DispatcherSetting) *Dispatcher {
+	s := DispatcherSetting{}
+	if setting != nil {
+		s = *setting
+	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.Default
+	}
+
+	return &Dispatcher{
+		targets: s.Targets,
+		sent:    s.Metrics.Counter("notify.sent"),
+		failed:  s.Metrics.Counter("notify.failures"),
+	}
+}
+
+// NewDispatcherFromConfig 設定ファイルの送信先からDispatcherを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+// 送信先が設定されていない場合はnilを返す
+func NewDispatcherFromConfig(settings []config.Notifier, client *http.Client) (*Dispatcher, error) {
+	if len(settings) == 0 {
+		return nil, nil
+	}
+
+	targets := make([]Target, 0, len(settings))
+	for i, setting := range settings {
+		notifier, err := newNotifier(&setting, client)
+		if err != nil {
+			return nil, errors.Wrapf(err, "notifiers[%d]", i)
+		}
+		name := setting.Name
+		if name == "" {
+			name = setting.Type
+		}
+		targets = append(targets, Target{Name: name, Notifier: notifier})
+	}
+	return NewDispatcher(&DispatcherSetting{Targets: targets}), nil
+}
+
+// newNotifier 送信先の設定からNotifierを作成する
+func newNotifier(setting *config.Notifier, client *http.Client) (Notifier, error) {
+	if setting.URL == "" {
+		return nil, ErrNotifierURLRequired
+	}
+
+	switch strings.ToLower(setting.Type) {
+	case TypeSlack:
+		return NewSlackNotifier(setting.URL, client), nil
+	case TypeDiscord:
+		return NewDiscordNotifier(setting.URL, client), nil
+	case TypeWebhook:
+		return NewWebhookNotifier(setting.URL, client), nil
+	default:
+		return nil, errors.Wrapf(ErrUnknownNotifierType, "type: %s", setting.Type)
+	}
+}
+
+// Notify すべての送信先に通知を送信する
+// 一部の送信先で失敗しても残りの送信先には送信し、失敗したものをまとめて返す
+func (d *Dispatcher) Notify(ctx context.Context, notification *Notification) error {
+	if d == nil || notification == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, target := range d.targets {
+		if err := target.Notifier.Notify(ctx, notification); err != nil {
+			d.failed.Inc()
+			log.Printf("Failed to send notification to %s: %v", target.Name, err)
+			errs = append(errs, errors.Wrapf(err, "Failed to Notify %s", target.Name))
+			continue
+		}
+		d.sent.Inc()
+	}
+	return errors.Join(errs...)
+}
+
+// metadataSummary 画像の作成に使ったデータの情報を1行にまとめる
+// 出典の表示を兼ねるため、画像を添付する通知には必ず付ける
+func metadataSummary(metadata *amesh.AmeshMetadata) string {
+	if metadata == nil {
+		return ""
+	}
+
+	var parts []string
+	if radarTime := metadata.RadarTimeText(); radarTime != "" {
+		parts = append(parts, "レーダー時刻 "+radarTime)
+	}
+	if 0 < metadata.LightningCount {
+		parts = append(parts, fmt.Sprintf("落雷 %d件", metadata.LightningCount))
+	}
+	if 0 < len(metadata.Providers) {
+		parts = append(parts, "出典: "+strings.Join(metadata.Providers, ", "))
+	}
+	return strings.Join(parts, " / ")
+}
@@ -0,0 +1,134 @@
+package notify_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/metrics"
+	"hato-bot-go/lib/notify"
+)
+
+// recordingNotifier 送信内容を記録する送信先
+type recordingNotifier struct {
+	notifications []*notify.Notification
+	err           error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification *notify.Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return n.err
+}
+
+func TestNewDispatcherFromConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      []config.Notifier
+		expectNil     bool
+		expectedError error
+	}{
+		{
+			name:      "送信先なし",
+			settings:  nil,
+			expectNil: true,
+		},
+		{
+			name: "すべての種類",
+			settings: []config.Notifier{
+				{Type: "slack", URL: "https://hooks.slack.com/services/T/B/X"},
+				{Type: "Discord", URL: "https://discord.com/api/webhooks/1/token"},
+				{Name: "ops", Type: "webhook", URL: "https://hooks.example.com/amesh"},
+			},
+		},
+		{
+			name:          "存在しない種類",
+			settings:      []config.Notifier{{Type: "teams", URL: "https://example.com"}},
+			expectedError: notify.ErrUnknownNotifierType,
+		},
+		{
+			name:          "URLなし",
+			settings:      []config.Notifier{{Type: "slack"}},
+			expectedError: notify.ErrNotifierURLRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dispatcher, err := notify.NewDispatcherFromConfig(tt.settings, nil)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("NewDispatcherFromConfig() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError == nil && (dispatcher == nil) != tt.expectNil {
+				t.Errorf("NewDispatcherFromConfig() = %v, expectNil = %v", dispatcher, tt.expectNil)
+			}
+		})
+	}
+}
+
+func TestDispatcherNotify(t *testing.T) {
+	tests := []struct {
+		name           string
+		errs           []error // 送信先ごとに返すエラー
+		expectError    bool
+		expectedSent   int64
+		expectedFailed int64
+	}{
+		{
+			name:         "すべて成功",
+			errs:         []error{nil, nil},
+			expectedSent: 2,
+		},
+		{
+			name:           "失敗した送信先があっても残りに送信",
+			errs:           []error{errors.New("failed"), nil},
+			expectError:    true,
+			expectedSent:   1,
+			expectedFailed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			registry := metrics.NewRegistry()
+			notifiers := make([]*recordingNotifier, 0, len(tt.errs))
+			targets := make([]notify.Target, 0, len(tt.errs))
+			for _, err := range tt.errs {
+				notifier := &recordingNotifier{err: err}
+				notifiers = append(notifiers, notifier)
+				targets = append(targets, notify.Target{Name: "test", Notifier: notifier})
+			}
+			dispatcher := notify.NewDispatcher(&notify.DispatcherSetting{Targets: targets, Metrics: registry})
+
+			notification := &notify.Notification{Text: "東京の雨雲レーダー"}
+			if err := dispatcher.Notify(t.Context(), notification); (err != nil) != tt.expectError {
+				t.Fatalf("Notify() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			for i, notifier := range notifiers {
+				if len(notifier.notifications) != 1 || notifier.notifications[0] != notification {
+					t.Errorf("notifier %d received %v, want the notification once", i, notifier.notifications)
+				}
+			}
+			if got := registry.Counter("notify.sent").Value(); got != tt.expectedSent {
+				t.Errorf("notify.sent = %d, want %d", got, tt.expectedSent)
+			}
+			if got := registry.Counter("notify.failures").Value(); got != tt.expectedFailed {
+				t.Errorf("notify.failures = %d, want %d", got, tt.expectedFailed)
+			}
+		})
+	}
+}
+
+// TestDispatcherNil nilのDispatcherは何もしないことをテストする
+func TestDispatcherNil(t *testing.T) {
+	t.Parallel()
+	var dispatcher *notify.Dispatcher
+
+	if err := dispatcher.Notify(t.Context(), &notify.Notification{Text: "test"}); err != nil {
+		t.Errorf("Notify() error = %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+// defaultNotifierTimeout 送信先へのリクエストのタイムアウト
+const defaultNotifierTimeout = 30 * time.Second
+
+// defaultFileName ファイル名が指定されていない場合の画像のファイル名
+const defaultFileName = "amesh.png"
+
+// newNotifierClient 送信先用のHTTPクライアントを返す
+func newNotifierClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: defaultNotifierTimeout}
+}
+
+// fileName 通知の画像のファイル名を返す
+func (n *Notification) fileName() string {
+	if n.FileName == "" {
+		return defaultFileName
+	}
+	return n.FileName
+}
+
+// post リクエストを送信してレスポンスを閉じる
+func post(client *http.Client, req *http.Request) (err error) {
+	resp, err := httpclient.ExecuteHTTPRequest(client, req)
+	if err != nil {
+		return errors.Wrap(err, "Failed to ExecuteHTTPRequest")
+	}
+	defer func(body io.ReadCloser) {
+		if closeErr := body.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(resp.Body)
+
+	return nil
+}
+
+// newJSONRequest 値をJSONでPOSTするリクエストを作成する
+func newJSONRequest(ctx context.Context, url string, value any) (*http.Request, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Marshal")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// WebhookMetadata 汎用Webhookに送信する画像の作成に使ったデータの情報
+type WebhookMetadata struct {
+	RadarTime      *time.Time `json:"radar_time,omitempty"`
+	LightningCount int        `json:"lightning_count"`
+	TilesFetched   int        `json:"tiles_fetched"`
+	TilesFailed    int        `json:"tiles_failed"`
+	Providers      []string   `json:"providers,omitempty"`
+	BoundingBox    [4]float64 `json:"bounding_box"` // 南端の緯度・西端の経度・北端の緯度・東端の経度
+}
+
+// WebhookPayload 汎用Webhookに送信するJSON
+type WebhookPayload struct {
+	Text     string           `json:"text"`
+	Image    []byte           `json:"image,omitempty"` // PNG形式の画像（Base64）
+	FileName string           `json:"file_name,omitempty"`
+	ImageURL string           `json:"image_url,omitempty"`
+	Metadata *WebhookMetadata `json:"metadata,omitempty"`
+	Service  string           `json:"service"`
+	Version  string           `json:"version"`
+}
+
+// WebhookNotifier 通知をJSONで任意のURLにPOSTする送信先
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier 新しいWebhookNotifierを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+func NewWebhookNotifier(webhookURL string, client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    webhookURL,
+		Client: newNotifierClient(client),
+	}
+}
+
+// Notify 通知を送信する
+func (n *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	payload := &WebhookPayload{
+		Text:     notification.Text,
+		Image:    notification.Image,
+		ImageURL: notification.ImageURL,
+		Service:  "hato-bot-go",
+		Version:  lib.Version,
+	}
+	if notification.Image != nil {
+		payload.FileName = notification.fileName()
+	}
+	if m := notification.Metadata; m != nil {
+		payload.Metadata = &WebhookMetadata{
+			LightningCount: m.LightningCount,
+			TilesFetched:   m.Tiles.Fetched,
+			TilesFailed:    m.Tiles.Failed,
+			Providers:      m.Providers,
+			BoundingBox:    [4]float64{m.BoundingBox.MinLat, m.BoundingBox.MinLng, m.BoundingBox.MaxLat, m.BoundingBox.MaxLng},
+		}
+		if !m.RadarTime.IsZero() {
+			payload.Metadata.RadarTime = &m.RadarTime
+		}
+	}
+
+	req, err := newJSONRequest(ctx, n.URL, payload)
+	if err != nil {
+		return errors.Wrap(err, "Failed to newJSONRequest")
+	}
+	if err := post(n.Client, req); err != nil {
+		return errors.Wrap(err, "Failed to post")
+	}
+	return nil
+}
+
+// slackBlock SlackのBlock Kitのブロック
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text,omitempty"`
+	Elements []*slackText `json:"elements,omitempty"`
+	ImageURL string       `json:"image_url,omitempty"`
+	AltText  string       `json:"alt_text,omitempty"`
+}
+
+// slackText SlackのBlock Kitのテキスト
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackPayload Slackのincoming webhookに送信するJSON
+type slackPayload struct {
+	Text   string        `json:"text"`
+	Blocks []*slackBlock `json:"blocks"`
+}
+
+// SlackNotifier 通知をSlackのincoming webhookに送信する送信先
+// incoming webhookはファイルを添付できないため、画像はImageURLを指定した場合のみ表示する
+type SlackNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewSlackNotifier 新しいSlackNotifierを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+func NewSlackNotifier(webhookURL string, client *http.Client) *SlackNotifier {
+	return &SlackNotifier{
+		URL:    webhookURL,
+		Client: newNotifierClient(client),
+	}
+}
+
+// Notify 通知を送信する
+func (n *SlackNotifier) Notify(ctx context.Context, notification *Notification) error {
+	payload := &slackPayload{
+		Text: notification.Text,
+		Blocks: []*slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: notification.Text}},
+		},
+	}
+	if notification.ImageURL != "" {
+		payload.Blocks = append(payload.Blocks, &slackBlock{
+			Type:     "image",
+			ImageURL: notification.ImageURL,
+			AltText:  notification.fileName(),
+		})
+	}
+	if summary := metadataSummary(notification.Metadata); summary != "" {
+		payload.Blocks = append(payload.Blocks, &slackBlock{
+			Type:     "context",
+			Elements: []*slackText{{Type: "mrkdwn", Text: summary}},
+		})
+	}
+
+	req, err := newJSONRequest(ctx, n.URL, payload)
+	if err != nil {
+		return errors.Wrap(err, "Failed to newJSONRequest")
+	}
+	if err := post(n.Client, req); err != nil {
+		return errors.Wrap(err, "Failed to post")
+	}
+	return nil
+}
+
+// discordEmbed DiscordのWebhookの埋め込み
+type discordEmbed struct {
+	Image  *discordURL    `json:"image,omitempty"`
+	Footer *discordFooter `json:"footer,omitempty"`
+}
+
+// discordURL DiscordのWebhookの埋め込みの画像
+type discordURL struct {
+	URL string `json:"url"`
+}
+
+// discordFooter DiscordのWebhookの埋め込みのフッター
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// discordAttachment DiscordのWebhookで添付するファイル
+type discordAttachment struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
+}
+
+// discordPayload DiscordのWebhookに送信するJSON
+type discordPayload struct {
+	Content     string              `json:"content"`
+	Embeds      []*discordEmbed     `json:"embeds,omitempty"`
+	Attachments []discordAttachment `json:"attachments,omitempty"`
+}
+
+// DiscordNotifier 通知をDiscordのWebhookに送信する送信先
+// 画像はファイルとして添付し、埋め込みに表示する
+type DiscordNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewDiscordNotifier 新しいDiscordNotifierを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+func NewDiscordNotifier(webhookURL string, client *http.Client) *DiscordNotifier {
+	return &DiscordNotifier{
+		URL:    webhookURL,
+		Client: newNotifierClient(client),
+	}
+}
+
+// Notify 通知を送信する
+func (n *DiscordNotifier) Notify(ctx context.Context, notification *Notification) error {
+	payload := &discordPayload{Content: notification.Text}
+
+	embed := &discordEmbed{}
+	switch {
+	case notification.Image != nil:
+		payload.Attachments = []discordAttachment{{ID: 0, Filename: notification.fileName()}}
+		embed.Image = &discordURL{URL: "attachment://" + notification.fileName()}
+	case notification.ImageURL != "":
+		embed.Image = &discordURL{URL: notification.ImageURL}
+	}
+	if summary := metadataSummary(notification.Metadata); summary != "" {
+		embed.Footer = &discordFooter{Text: summary}
+	}
+	if embed.Image != nil || embed.Footer != nil {
+		payload.Embeds = []*discordEmbed{embed}
+	}
+
+	if notification.Image == nil {
+		req, err := newJSONRequest(ctx, n.URL, payload)
+		if err != nil {
+			return errors.Wrap(err, "Failed to newJSONRequest")
+		}
+		if err := post(n.Client, req); err != nil {
+			return errors.Wrap(err, "Failed to post")
+		}
+		return nil
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := notification.writeDiscordParts(writer, payload); err != nil {
+		return errors.Wrap(err, "Failed to writeDiscordParts")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, &body)
+	if err != nil {
+		return errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	if err := post(n.Client, req); err != nil {
+		return errors.Wrap(err, "Failed to post")
+	}
+	return nil
+}
+
+// writeDiscordParts payload_jsonと画像ファイルをmultipart/form-dataとして書き込む
+func (n *Notification) writeDiscordParts(writer *multipart.Writer, payload *discordPayload) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "Failed to json.Marshal")
+	}
+
+	if err := writer.WriteField("payload_json", string(payloadJSON)); err != nil {
+		return errors.Wrap(err, "Failed to WriteField")
+	}
+	part, err := writer.CreateFormFile("files[0]", n.fileName())
+	if err != nil {
+		return errors.Wrap(err, "Failed to CreateFormFile")
+	}
+	if _, err := part.Write(n.Image); err != nil {
+		return errors.Wrap(err, "Failed to Write")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "Failed to Close")
+	}
+	return nil
+}
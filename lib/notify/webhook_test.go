@@ -0,0 +1,309 @@
+package notify_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/amesh"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/notify"
+)
+
+// testMetadata テスト用の画像の作成に使ったデータの情報
+func testMetadata() *amesh.AmeshMetadata {
+	return &amesh.AmeshMetadata{
+		RadarTime:      time.Date(2024, 1, 1, 3, 5, 0, 0, time.UTC),
+		LightningCount: 2,
+		Tiles:          amesh.TileStats{Fetched: 25, Failed: 1},
+		Providers:      []string{amesh.ProviderOpenStreetMap, amesh.ProviderJMA},
+		BoundingBox:    amesh.BoundingBox{MinLat: 35, MinLng: 139, MaxLat: 36, MaxLng: 140},
+	}
+}
+
+func TestWebhookNotifierNotify(t *testing.T) {
+	radarTime := time.Date(2024, 1, 1, 3, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		notification  *notify.Notification
+		statusCode    int
+		expected      *notify.WebhookPayload
+		expectedError error
+	}{
+		{
+			name: "画像と情報を送信",
+			notification: &notify.Notification{
+				Text:     "東京の雨雲レーダー",
+				Image:    []byte("png"),
+				Metadata: testMetadata(),
+			},
+			statusCode: http.StatusNoContent,
+			expected: &notify.WebhookPayload{
+				Text:     "東京の雨雲レーダー",
+				Image:    []byte("png"),
+				FileName: "amesh.png",
+				Metadata: &notify.WebhookMetadata{
+					RadarTime:      &radarTime,
+					LightningCount: 2,
+					TilesFetched:   25,
+					TilesFailed:    1,
+					Providers:      []string{amesh.ProviderOpenStreetMap, amesh.ProviderJMA},
+					BoundingBox:    [4]float64{35, 139, 36, 140},
+				},
+				Service: "hato-bot-go",
+				Version: "1.0",
+			},
+		},
+		{
+			name:         "本文のみ",
+			notification: &notify.Notification{Text: "大雨警報"},
+			statusCode:   http.StatusOK,
+			expected: &notify.WebhookPayload{
+				Text:    "大雨警報",
+				Service: "hato-bot-go",
+				Version: "1.0",
+			},
+		},
+		{
+			name:          "Webhookがエラーを返す",
+			notification:  &notify.Notification{Text: "大雨警報"},
+			statusCode:    http.StatusInternalServerError,
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: tt.statusCode},
+			})
+			notifier := notify.NewWebhookNotifier("https://hooks.example.com/amesh", transport.Client())
+
+			if err := notifier.Notify(t.Context(), tt.notification); !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Notify() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			requests := transport.RequestsTo("https://hooks.example.com/amesh")
+			if len(requests) != 1 {
+				t.Fatalf("webhook called %d times, want 1", len(requests))
+			}
+			var payload notify.WebhookPayload
+			if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, &payload); diff != "" {
+				t.Errorf("payload mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSlackNotifierNotify(t *testing.T) {
+	tests := []struct {
+		name           string
+		notification   *notify.Notification
+		expectedBlocks []string // ブロックの種類
+		expectedFooter string
+	}{
+		{
+			name: "画像のURLと情報",
+			notification: &notify.Notification{
+				Text:     "東京の雨雲レーダー",
+				Image:    []byte("png"),
+				ImageURL: "https://example.com/amesh?place=東京",
+				Metadata: testMetadata(),
+			},
+			expectedBlocks: []string{"section", "image", "context"},
+			expectedFooter: "レーダー時刻 12:05 JST / 落雷 2件 / 出典: OpenStreetMap, 気象庁",
+		},
+		{
+			name:           "画像のURLなし",
+			notification:   &notify.Notification{Text: "大雨警報", Image: []byte("png")},
+			expectedBlocks: []string{"section"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK},
+			})
+			notifier := notify.NewSlackNotifier("https://hooks.slack.com/services/T/B/X", transport.Client())
+
+			if err := notifier.Notify(t.Context(), tt.notification); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			requests := transport.RequestsTo("hooks.slack.com")
+			if len(requests) != 1 {
+				t.Fatalf("webhook called %d times, want 1", len(requests))
+			}
+			var payload struct {
+				Text   string `json:"text"`
+				Blocks []struct {
+					Type     string `json:"type"`
+					Elements []struct {
+						Text string `json:"text"`
+					} `json:"elements"`
+				} `json:"blocks"`
+			}
+			if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+				t.Fatal(err)
+			}
+
+			if payload.Text != tt.notification.Text {
+				t.Errorf("text = %q, want %q", payload.Text, tt.notification.Text)
+			}
+			blocks := make([]string, 0, len(payload.Blocks))
+			footer := ""
+			for _, block := range payload.Blocks {
+				blocks = append(blocks, block.Type)
+				if block.Type == "context" && 0 < len(block.Elements) {
+					footer = block.Elements[0].Text
+				}
+			}
+			if diff := cmp.Diff(tt.expectedBlocks, blocks); diff != "" {
+				t.Errorf("blocks mismatch (-want +got):\n%s", diff)
+			}
+			if footer != tt.expectedFooter {
+				t.Errorf("context = %q, want %q", footer, tt.expectedFooter)
+			}
+		})
+	}
+}
+
+func TestDiscordNotifierNotify(t *testing.T) {
+	tests := []struct {
+		name              string
+		notification      *notify.Notification
+		expectMultipart   bool
+		expectedImageURL  string
+		expectedFooter    string
+		expectedFileBytes []byte
+	}{
+		{
+			name: "画像を添付",
+			notification: &notify.Notification{
+				Text:     "東京の雨雲レーダー",
+				Image:    []byte("png"),
+				FileName: "amesh_tokyo.png",
+				Metadata: testMetadata(),
+			},
+			expectMultipart:   true,
+			expectedImageURL:  "attachment://amesh_tokyo.png",
+			expectedFooter:    "レーダー時刻 12:05 JST / 落雷 2件 / 出典: OpenStreetMap, 気象庁",
+			expectedFileBytes: []byte("png"),
+		},
+		{
+			name:             "画像のURL",
+			notification:     &notify.Notification{Text: "東京の雨雲レーダー", ImageURL: "https://example.com/amesh.png"},
+			expectedImageURL: "https://example.com/amesh.png",
+		},
+		{
+			name:         "本文のみ",
+			notification: &notify.Notification{Text: "大雨警報"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusNoContent},
+			})
+			notifier := notify.NewDiscordNotifier("https://discord.com/api/webhooks/1/token", transport.Client())
+
+			if err := notifier.Notify(t.Context(), tt.notification); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			requests := transport.RequestsTo("discord.com")
+			if len(requests) != 1 {
+				t.Fatalf("webhook called %d times, want 1", len(requests))
+			}
+
+			payloadJSON := requests[0].Body
+			var fileBytes []byte
+			mediaType, params, err := mime.ParseMediaType(requests[0].Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (mediaType == "multipart/form-data") != tt.expectMultipart {
+				t.Fatalf("Content-Type = %s, expectMultipart = %v", mediaType, tt.expectMultipart)
+			}
+			if tt.expectMultipart {
+				reader := multipart.NewReader(bytes.NewReader(requests[0].Body), params["boundary"])
+				for {
+					part, err := reader.NextPart()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					data, err := io.ReadAll(part)
+					if err != nil {
+						t.Fatal(err)
+					}
+					switch part.FormName() {
+					case "payload_json":
+						payloadJSON = data
+					case "files[0]":
+						fileBytes = data
+					}
+				}
+			}
+
+			var payload struct {
+				Content string `json:"content"`
+				Embeds  []struct {
+					Image *struct {
+						URL string `json:"url"`
+					} `json:"image"`
+					Footer *struct {
+						Text string `json:"text"`
+					} `json:"footer"`
+				} `json:"embeds"`
+			}
+			if err := json.Unmarshal(payloadJSON, &payload); err != nil {
+				t.Fatal(err)
+			}
+
+			if payload.Content != tt.notification.Text {
+				t.Errorf("content = %q, want %q", payload.Content, tt.notification.Text)
+			}
+			imageURL, footer := "", ""
+			if 0 < len(payload.Embeds) {
+				if payload.Embeds[0].Image != nil {
+					imageURL = payload.Embeds[0].Image.URL
+				}
+				if payload.Embeds[0].Footer != nil {
+					footer = payload.Embeds[0].Footer.Text
+				}
+			}
+			if imageURL != tt.expectedImageURL {
+				t.Errorf("embed image = %q, want %q", imageURL, tt.expectedImageURL)
+			}
+			if footer != tt.expectedFooter {
+				t.Errorf("embed footer = %q, want %q", footer, tt.expectedFooter)
+			}
+			if !bytes.Equal(fileBytes, tt.expectedFileBytes) {
+				t.Errorf("file = %q, want %q", fileBytes, tt.expectedFileBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/metrics"
+)
+
+// ErrInvalidSampleRate サンプリング率の設定値が不正であることを表すエラー
+var ErrInvalidSampleRate = errors.New("invalid sample rate")
+
+// 環境変数名
+const (
+	SentryDSNEnv  = "ERROR_REPORT_SENTRY_DSN"  // SentryのDSN
+	WebhookURLEnv = "ERROR_REPORT_WEBHOOK_URL" // エラーをJSONでPOSTするWebhookのURL
+	SampleRateEnv = "ERROR_REPORT_SAMPLE_RATE" // 送信するエラーの割合（0〜1、未設定の場合は1）
+)
+
+// Level エラーの重大度
+type Level string
+
+const (
+	LevelError Level = "error" // コマンド処理の失敗など
+	LevelFatal Level = "fatal" // パニックや連続した失敗など運用者の対応が必要なもの
+)
+
+// redacted 秘匿情報を置き換える文字列
+const redacted = "[REDACTED]"
+
+// secretPatterns URLのクエリやJSONに含まれるトークンのパターン
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`((?:^|[?&\s])(?:i|appid|token|access_token)=)[^&\s"']+`),
+	regexp.MustCompile(`("(?:i|token|access_token)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`((?i:authorization|bearer)[:\s]+)\S+`),
+}
+
+// Event 送信するエラーの内容
+type Event struct {
+	Level   Level             // 重大度（空の場合はLevelError）
+	Message string            // 概要
+	Err     error             // 原因のエラー
+	Tags    map[string]string // 検索用のタグ（コマンド名・プラットフォームなど）
+}
+
+// Payload 秘匿情報を除去した送信内容
+type Payload struct {
+	Level     Level             `json:"level"`
+	Message   string            `json:"message"`
+	Error     string            `json:"error,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Service   string            `json:"service"`
+	Version   string            `json:"version"`
+}
+
+// Sink エラーの送信先
+type Sink interface {
+	Send(ctx context.Context, payload *Payload) error
+}
+
+// ReporterSetting エラー報告の設定
+type ReporterSetting struct {
+	Sinks      []Sink            // 送信先
+	SampleRate float64           // 送信するエラーの割合（0〜1、LevelFatalは常に送信する）
+	Secrets    []string          // 送信前に除去するトークンなどの文字列
+	Rand       func() float64    // サンプリング用の乱数生成関数（nilの場合はrand.Float64）
+	Now        func() time.Time  // 現在時刻の取得関数（nilの場合はtime.Now）
+	Metrics    *metrics.Registry // 送信数などを記録するレジストリ（nilの場合はmetrics.Default）
+}
+
+// Reporter エラーをサンプリング・秘匿情報の除去をした上で送信先に報告する
+// レシーバーがnilの場合は何もしない
+type Reporter struct {
+	setting ReporterSetting
+	sent    *metrics.Counter
+	sampled *metrics.Counter
+	failed  *metrics.Counter
+}
+
+// NewReporter 新しいReporterを作成する
+func NewReporter(setting *ReporterSetting) *Reporter {
+	s := ReporterSetting{}
+	if setting != nil {
+		s = *setting
+	}
+	if s.Rand == nil {
+		s.Rand = rand.Float64
+	}
+	if s.Now == nil {
+		s.Now = time.Now
+	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.Default
+	}
+
+	return &Reporter{
+		setting: s,
+		sent:    s.Metrics.Counter("report.sent"),
+		sampled: s.Metrics.Counter("report.sampled_out"),
+		failed:  s.Metrics.Counter("report.failures"),
+	}
+}
+
+// NewReporterFromEnv 環境変数の設定からReporterを作成する
+// 送信先が設定されていない場合はnilを返す
+func NewReporterFromEnv(secrets ...string) (*Reporter, error) {
+	var sinks []Sink
+	if dsn := os.Getenv(SentryDSNEnv); dsn != "" {
+		sink, err := NewSentrySink(dsn, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to NewSentrySink")
+		}
+		sinks = append(sinks, sink)
+	}
+	if webhookURL := os.Getenv(WebhookURLEnv); webhookURL != "" {
+		sinks = append(sinks, NewWebhookSink(webhookURL, nil))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	sampleRate := 1.0
+	if v := os.Getenv(SampleRateEnv); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to strconv.ParseFloat")
+		}
+		if parsed < 0 || 1 < parsed {
+			return nil, errors.Wrapf(ErrInvalidSampleRate, "sample rate: %s", v)
+		}
+		sampleRate = parsed
+	}
+
+	return NewReporter(&ReporterSetting{
+		Sinks:      sinks,
+		SampleRate: sampleRate,
+		Secrets:    secrets,
+	}), nil
+}
+
+// Scrub 文字列からトークンなどの秘匿情報を除去する
+func (r *Reporter) Scrub(s string) string {
+	for _, secret := range r.setting.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return s
+}
+
+// Report エラーを報告する
+// 送信の失敗はログに出力し、呼び出し元には返さない
+func (r *Reporter) Report(ctx context.Context, event *Event) {
+	if r == nil || event == nil {
+		return
+	}
+
+	level := event.Level
+	if level == "" {
+		level = LevelError
+	}
+	if level != LevelFatal && r.setting.SampleRate <= r.setting.Rand() {
+		r.sampled.Inc()
+		return
+	}
+
+	payload := &Payload{
+		Level:     level,
+		Message:   r.Scrub(event.Message),
+		Tags:      make(map[string]string, len(event.Tags)),
+		Timestamp: r.setting.Now().UTC(),
+		Service:   "hato-bot-go",
+		Version:   lib.Version,
+	}
+	if event.Err != nil {
+		payload.Error = r.Scrub(event.Err.Error())
+	}
+	for key, value := range event.Tags {
+		payload.Tags[key] = r.Scrub(value)
+	}
+
+	for _, sink := range r.setting.Sinks {
+		if err := sink.Send(ctx, payload); err != nil {
+			r.failed.Inc()
+			log.Printf("Failed to send error report: %v", err)
+			continue
+		}
+		r.sent.Inc()
+	}
+}
+
+// Recover パニックを回復してLevelFatalとして報告する
+// deferで呼び出すこと
+func (r *Reporter) Recover(ctx context.Context, tags map[string]string) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	log.Printf("Recovered from panic: %v\n%s", recovered, stack)
+	r.Report(ctx, &Event{
+		Level:   LevelFatal,
+		Message: fmt.Sprintf("panic: %v", recovered),
+		Err:     errors.Newf("%v\n%s", recovered, stack),
+		Tags:    tags,
+	})
+}
+
+// FailureCounter 連続した失敗の回数を数える
+type FailureCounter struct {
+	Threshold int // 報告する連続失敗回数
+	count     int
+}
+
+// Fail 失敗を記録し、連続失敗回数が閾値に達した場合はtrueを返す
+// 閾値の倍数ごとにtrueを返すため、失敗が続く間は繰り返し報告できる
+func (c *FailureCounter) Fail() bool {
+	c.count++
+	return 0 < c.Threshold && c.count%c.Threshold == 0
+}
+
+// Count 現在の連続失敗回数を返す
+func (c *FailureCounter) Count() int {
+	return c.count
+}
+
+// Reset 成功した場合に連続失敗回数を戻す
+func (c *FailureCounter) Reset() {
+	c.count = 0
+}
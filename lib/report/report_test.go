@@ -0,0 +1,187 @@
+package report_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/metrics"
+	"hato-bot-go/lib/report"
+)
+
+// recordingSink 送信内容を記録する送信先
+type recordingSink struct {
+	payloads []*report.Payload
+	err      error
+}
+
+func (s *recordingSink) Send(_ context.Context, payload *report.Payload) error {
+	s.payloads = append(s.payloads, payload)
+	return s.err
+}
+
+func TestReporterReport(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		sampleRate    float64
+		random        float64
+		event         *report.Event
+		expectPayload *report.Payload // nilの場合は送信されない
+	}{
+		{
+			name:       "秘匿情報を除去して送信",
+			sampleRate: 1,
+			random:     0.5,
+			event: &report.Event{
+				Message: "Failed to Dial wss://example.com/streaming?i=secret-token",
+				Err:     errors.New(`request {"i":"another-token"} appid=yahoo-key failed`),
+				Tags:    map[string]string{"command": "amesh"},
+			},
+			expectPayload: &report.Payload{
+				Level:     report.LevelError,
+				Message:   "Failed to Dial wss://example.com/streaming?i=[REDACTED]",
+				Error:     `request {"i":"[REDACTED]"} appid=[REDACTED] failed`,
+				Tags:      map[string]string{"command": "amesh"},
+				Timestamp: now,
+				Service:   "hato-bot-go",
+				Version:   "1.0",
+			},
+		},
+		{
+			name:       "設定した秘匿文字列を除去",
+			sampleRate: 1,
+			random:     0.5,
+			event:      &report.Event{Message: "token is my-secret"},
+			expectPayload: &report.Payload{
+				Level:     report.LevelError,
+				Message:   "token is [REDACTED]",
+				Tags:      map[string]string{},
+				Timestamp: now,
+				Service:   "hato-bot-go",
+				Version:   "1.0",
+			},
+		},
+		{
+			name:          "サンプリングで除外",
+			sampleRate:    0.1,
+			random:        0.5,
+			event:         &report.Event{Message: "error"},
+			expectPayload: nil,
+		},
+		{
+			name:       "fatalはサンプリングせずに送信",
+			sampleRate: 0,
+			random:     0.5,
+			event:      &report.Event{Level: report.LevelFatal, Message: "panic"},
+			expectPayload: &report.Payload{
+				Level:     report.LevelFatal,
+				Message:   "panic",
+				Tags:      map[string]string{},
+				Timestamp: now,
+				Service:   "hato-bot-go",
+				Version:   "1.0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			sink := &recordingSink{}
+			reporter := report.NewReporter(&report.ReporterSetting{
+				Sinks:      []report.Sink{sink},
+				SampleRate: tt.sampleRate,
+				Secrets:    []string{"my-secret"},
+				Rand:       func() float64 { return tt.random },
+				Now:        func() time.Time { return now },
+				Metrics:    metrics.NewRegistry(),
+			})
+
+			reporter.Report(t.Context(), tt.event)
+
+			if tt.expectPayload == nil {
+				if len(sink.payloads) != 0 {
+					t.Errorf("sent %d payloads, want 0", len(sink.payloads))
+				}
+				return
+			}
+			if len(sink.payloads) != 1 {
+				t.Fatalf("sent %d payloads, want 1", len(sink.payloads))
+			}
+			if diff := cmp.Diff(sink.payloads[0], tt.expectPayload); diff != "" {
+				t.Errorf("payload diff: %s", diff)
+			}
+		})
+	}
+}
+
+// TestReporterNil nilのReporterは何もしないことをテストする
+func TestReporterNil(t *testing.T) {
+	t.Parallel()
+	var reporter *report.Reporter
+
+	reporter.Report(t.Context(), &report.Event{Message: "error"})
+	func() {
+		defer reporter.Recover(t.Context(), nil)
+		panic("test")
+	}()
+}
+
+// TestReporterRecover パニックを回復してfatalとして報告することをテストする
+func TestReporterRecover(t *testing.T) {
+	t.Parallel()
+	sink := &recordingSink{}
+	registry := metrics.NewRegistry()
+	reporter := report.NewReporter(&report.ReporterSetting{
+		Sinks:      []report.Sink{sink, &recordingSink{err: errors.New("send failed")}},
+		SampleRate: 0,
+		Metrics:    registry,
+	})
+
+	func() {
+		defer reporter.Recover(t.Context(), map[string]string{"platform": "misskey"})
+		panic("something wrong")
+	}()
+
+	if len(sink.payloads) != 1 {
+		t.Fatalf("sent %d payloads, want 1", len(sink.payloads))
+	}
+	payload := sink.payloads[0]
+	if payload.Level != report.LevelFatal || payload.Message != "panic: something wrong" {
+		t.Errorf("payload = %+v", payload)
+	}
+	if payload.Tags["platform"] != "misskey" {
+		t.Errorf("tags = %v", payload.Tags)
+	}
+
+	expected := map[string]int64{"report.failures": 1, "report.sampled_out": 0, "report.sent": 1}
+	if diff := cmp.Diff(registry.Snapshot(), expected); diff != "" {
+		t.Errorf("metrics diff: %s", diff)
+	}
+}
+
+func TestFailureCounter(t *testing.T) {
+	t.Parallel()
+	counter := &report.FailureCounter{Threshold: 3}
+
+	var results []bool
+	for range 7 {
+		results = append(results, counter.Fail())
+	}
+	if diff := cmp.Diff(results, []bool{false, false, true, false, false, true, false}); diff != "" {
+		t.Errorf("Fail() diff: %s", diff)
+	}
+
+	counter.Reset()
+	if counter.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", counter.Count())
+	}
+	if counter.Fail() {
+		t.Error("Fail() after Reset() = true, want false")
+	}
+}
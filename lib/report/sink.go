@@ -0,0 +1,191 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+// ErrInvalidSentryDSN SentryのDSNが不正であることを表すエラー
+var ErrInvalidSentryDSN = errors.New("invalid sentry dsn")
+
+// defaultSinkTimeout 送信先へのリクエストのタイムアウト
+const defaultSinkTimeout = 10 * time.Second
+
+// newSinkClient 送信先用のHTTPクライアントを返す
+func newSinkClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: defaultSinkTimeout}
+}
+
+// post リクエストを送信してレスポンスを閉じる
+func post(client *http.Client, req *http.Request) (err error) {
+	resp, err := httpclient.ExecuteHTTPRequest(client, req)
+	if err != nil {
+		return errors.Wrap(err, "Failed to ExecuteHTTPRequest")
+	}
+	defer func(body io.ReadCloser) {
+		if closeErr := body.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "Failed to Close"))
+		}
+	}(resp.Body)
+
+	return nil
+}
+
+// WebhookSink エラーをJSONでWebhookにPOSTする送信先
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookSink 新しいWebhookSinkを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+func NewWebhookSink(webhookURL string, client *http.Client) *WebhookSink {
+	return &WebhookSink{
+		URL:    webhookURL,
+		Client: newSinkClient(client),
+	}
+}
+
+// Send エラーを送信する
+func (s *WebhookSink) Send(ctx context.Context, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "Failed to json.Marshal")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := post(s.Client, req); err != nil {
+		return errors.Wrap(err, "Failed to post")
+	}
+	return nil
+}
+
+// SentrySink エラーをSentryのenvelope APIに送信する送信先
+type SentrySink struct {
+	dsn         string
+	envelopeURL string
+	publicKey   string
+	Client      *http.Client
+}
+
+// NewSentrySink DSNから新しいSentrySinkを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+// DSNの形式は https://<公開鍵>@<ホスト>/<プロジェクトID>
+func NewSentrySink(dsn string, client *http.Client) (*SentrySink, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to url.Parse")
+	}
+
+	projectID := strings.Trim(parsed.Path, "/")
+	if parsed.User == nil || parsed.User.Username() == "" || parsed.Host == "" || projectID == "" {
+		return nil, ErrInvalidSentryDSN
+	}
+
+	// プロジェクトIDの前にパスがある場合（リバースプロキシ配下など）はそのまま残す
+	prefix := ""
+	if i := strings.LastIndex(projectID, "/"); 0 <= i {
+		prefix, projectID = "/"+projectID[:i], projectID[i+1:]
+	}
+
+	return &SentrySink{
+		dsn:         dsn,
+		envelopeURL: fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, prefix, projectID),
+		publicKey:   parsed.User.Username(),
+		Client:      newSinkClient(client),
+	}, nil
+}
+
+// sentryEvent Sentryのイベント
+type sentryEvent struct {
+	EventID   string            `json:"event_id"`
+	Timestamp string            `json:"timestamp"`
+	Level     Level             `json:"level"`
+	Platform  string            `json:"platform"`
+	Logger    string            `json:"logger"`
+	Release   string            `json:"release"`
+	Message   string            `json:"message"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// Send エラーを送信する
+func (s *SentrySink) Send(ctx context.Context, payload *Payload) error {
+	eventID, err := newEventID()
+	if err != nil {
+		return errors.Wrap(err, "Failed to newEventID")
+	}
+	timestamp := payload.Timestamp.Format(time.RFC3339)
+
+	event := sentryEvent{
+		EventID:   eventID,
+		Timestamp: timestamp,
+		Level:     payload.Level,
+		Platform:  "go",
+		Logger:    payload.Service,
+		Release:   payload.Service + "@" + payload.Version,
+		Message:   payload.Message,
+		Tags:      payload.Tags,
+	}
+	if payload.Error != "" {
+		event.Extra = map[string]string{"error": payload.Error}
+	}
+
+	// envelopeはヘッダー・アイテムヘッダー・アイテムを改行で区切る
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, part := range []any{
+		map[string]string{"event_id": eventID, "dsn": s.dsn, "sent_at": timestamp},
+		map[string]string{"type": "event"},
+		event,
+	} {
+		if err := encoder.Encode(part); err != nil {
+			return errors.Wrap(err, "Failed to Encode")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.envelopeURL, &body)
+	if err != nil {
+		return errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf(
+		"Sentry sentry_version=7, sentry_client=hato-bot-go/%s, sentry_key=%s",
+		lib.Version,
+		s.publicKey,
+	))
+
+	if err := post(s.Client, req); err != nil {
+		return errors.Wrap(err, "Failed to post")
+	}
+	return nil
+}
+
+// newEventID Sentryのイベントに使う32桁の16進数のIDを生成する
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "Failed to rand.Read")
+	}
+	return hex.EncodeToString(b), nil
+}
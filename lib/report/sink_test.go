@@ -0,0 +1,151 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/report"
+)
+
+func testPayload() *report.Payload {
+	return &report.Payload{
+		Level:     report.LevelError,
+		Message:   "Error processing amesh command",
+		Error:     "Failed to CreateNote",
+		Tags:      map[string]string{"platform": "misskey"},
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Service:   "hato-bot-go",
+		Version:   "1.0",
+	}
+}
+
+func TestWebhookSinkSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		expectError error
+	}{
+		{
+			name:        "正常系",
+			statusCode:  http.StatusNoContent,
+			expectError: nil,
+		},
+		{
+			name:        "Webhookがエラーを返す",
+			statusCode:  http.StatusInternalServerError,
+			expectError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: tt.statusCode},
+			})
+			sink := report.NewWebhookSink("https://hooks.example.com/error", transport.Client())
+
+			if err := sink.Send(t.Context(), testPayload()); !errors.Is(err, tt.expectError) {
+				t.Fatalf("Send() error = %v, expectError = %v", err, tt.expectError)
+			}
+
+			requests := transport.RequestsTo("https://hooks.example.com/error")
+			if len(requests) != 1 {
+				t.Fatalf("webhook called %d times, want 1", len(requests))
+			}
+			var payload report.Payload
+			if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(&payload, testPayload()); diff != "" {
+				t.Errorf("webhook payload diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestNewSentrySink(t *testing.T) {
+	tests := []struct {
+		name        string
+		dsn         string
+		expectURL   string
+		expectError bool
+	}{
+		{
+			name:      "標準的なDSN",
+			dsn:       "https://public@o0.ingest.sentry.io/12345",
+			expectURL: "https://o0.ingest.sentry.io/api/12345/envelope/",
+		},
+		{
+			name:      "パス付きのDSN",
+			dsn:       "https://public@sentry.example.com/sentry/12345",
+			expectURL: "https://sentry.example.com/sentry/api/12345/envelope/",
+		},
+		{
+			name:        "公開鍵がない",
+			dsn:         "https://sentry.example.com/12345",
+			expectError: true,
+		},
+		{
+			name:        "プロジェクトIDがない",
+			dsn:         "https://public@sentry.example.com/",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{
+				Fallback: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"id":"abc"}`},
+			})
+
+			sink, err := report.NewSentrySink(tt.dsn, transport.Client())
+			if tt.expectError {
+				if !errors.Is(err, report.ErrInvalidSentryDSN) {
+					t.Errorf("NewSentrySink() error = %v, want ErrInvalidSentryDSN", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := sink.Send(t.Context(), testPayload()); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			requests := transport.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("sentry called %d times, want 1", len(requests))
+			}
+			req := requests[0]
+			if req.URL != tt.expectURL {
+				t.Errorf("URL = %q, want %q", req.URL, tt.expectURL)
+			}
+			if auth := req.Header.Get("X-Sentry-Auth"); !bytes.Contains([]byte(auth), []byte("sentry_key=public")) {
+				t.Errorf("X-Sentry-Auth = %q", auth)
+			}
+
+			// envelopeの3行目がイベント本体
+			lines := bytes.Split(bytes.TrimSpace(req.Body), []byte("\n"))
+			if len(lines) != 3 {
+				t.Fatalf("envelope has %d lines, want 3", len(lines))
+			}
+			var event map[string]any
+			if err := json.Unmarshal(lines[2], &event); err != nil {
+				t.Fatal(err)
+			}
+			if event["message"] != "Error processing amesh command" || event["level"] != "error" {
+				t.Errorf("event = %v", event)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// ShortLength 利用者に返信する短いリクエストIDの長さ
+const ShortLength = 8
+
+// contextKey コンテキストにリクエストIDを保存するキー
+type contextKey struct{}
+
+// New 新しいリクエストIDを作成する
+func New() string {
+	b := make([]byte, 8)
+	// crypto/randのReadは失敗しない
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewContext リクエストIDを付けたコンテキストを返す
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext コンテキストのリクエストIDを返す
+// リクエストIDがない場合は空文字列を返す
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Ensure コンテキストにリクエストIDがない場合は新しいリクエストIDを付けて返す
+func Ensure(ctx context.Context) context.Context {
+	if FromContext(ctx) != "" {
+		return ctx
+	}
+	return NewContext(ctx, New())
+}
+
+// Short 利用者に返信する短いリクエストIDを返す
+// ログはリクエストID全体を出力するため、短いIDの前方一致で検索できる
+func Short(id string) string {
+	if len(id) <= ShortLength {
+		return id
+	}
+	return id[:ShortLength]
+}
+
+// Prefix ログの行頭に付けるリクエストIDを返す
+// リクエストIDがない場合は空文字列を返す
+func Prefix(ctx context.Context) string {
+	id := FromContext(ctx)
+	if id == "" {
+		return ""
+	}
+	return "[req=" + id + "] "
+}
+
+// Logf コンテキストのリクエストIDを行頭に付けてログを出力する
+func Logf(ctx context.Context, format string, args ...any) {
+	log.Print(Prefix(ctx) + fmt.Sprintf(format, args...))
+}
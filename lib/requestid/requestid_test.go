@@ -0,0 +1,144 @@
+package requestid_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"hato-bot-go/lib/requestid"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	id := requestid.New()
+	if len(id) != 16 {
+		t.Errorf("len(New()) = %d, want 16", len(id))
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		t.Errorf("New() = %q is not hex: %v", id, err)
+	}
+	if other := requestid.New(); other == id {
+		t.Errorf("New() returned the same ID twice: %q", id)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{
+			name:     "リクエストIDあり",
+			ctx:      requestid.NewContext(context.Background(), "0123456789abcdef"),
+			expected: "0123456789abcdef",
+		},
+		{
+			name:     "リクエストIDなし",
+			ctx:      context.Background(),
+			expected: "",
+		},
+		{
+			name:     "nilのコンテキスト",
+			ctx:      nil,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := requestid.FromContext(tt.ctx); result != tt.expected {
+				t.Errorf("FromContext() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string // 空の場合は新しいIDが付くことのみ確認する
+	}{
+		{
+			name:     "既存のリクエストIDを残す",
+			ctx:      requestid.NewContext(context.Background(), "0123456789abcdef"),
+			expected: "0123456789abcdef",
+		},
+		{
+			name:     "リクエストIDがない場合は新しく付ける",
+			ctx:      context.Background(),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := requestid.FromContext(requestid.Ensure(tt.ctx))
+			if result == "" {
+				t.Fatal("Ensure() did not attach a request ID")
+			}
+			if tt.expected != "" && result != tt.expected {
+				t.Errorf("Ensure() request ID = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShort(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		expected string
+	}{
+		{
+			name:     "先頭の8文字",
+			id:       "0123456789abcdef",
+			expected: "01234567",
+		},
+		{
+			name:     "短いIDはそのまま",
+			id:       "0123",
+			expected: "0123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := requestid.Short(tt.id); result != tt.expected {
+				t.Errorf("Short() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{
+			name:     "リクエストIDあり",
+			ctx:      requestid.NewContext(context.Background(), "0123456789abcdef"),
+			expected: "[req=0123456789abcdef] ",
+		},
+		{
+			name:     "リクエストIDなし",
+			ctx:      context.Background(),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := requestid.Prefix(tt.ctx); result != tt.expected {
+				t.Errorf("Prefix() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
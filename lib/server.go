@@ -3,15 +3,43 @@ package lib
 import (
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/crypto/acme/autocert"
+
+	"hato-bot-go/lib/history"
+	"hato-bot-go/lib/metrics"
 )
 
+// DefaultAutocertCacheDir HTTPServerSetting.AutocertCacheDirを指定しない場合の証明書の保存先
+const DefaultAutocertCacheDir = "autocert-cache"
+
+// HTTPServerSetting HTTPサーバーのTLSとリバースプロキシの設定
+// 証明書ファイルもautocertのドメインも指定しない場合はTLSなしで待ち受ける
+type HTTPServerSetting struct {
+	CertFile         string         // TLSの証明書ファイルのパス
+	KeyFile          string         // TLSの秘密鍵ファイルのパス
+	AutocertDomains  []string       // Let's Encryptから証明書を自動で取得するドメイン
+	AutocertCacheDir string         // 自動で取得した証明書の保存先（空の場合はDefaultAutocertCacheDir）
+	TrustedProxies   []netip.Prefix // X-Forwarded-Forを信頼するリバースプロキシのアドレス
+}
+
+// TLS TLSで待ち受けるかを返す
+func (s *HTTPServerSetting) TLS() bool {
+	return s != nil && (s.CertFile != "" || 0 < len(s.AutocertDomains))
+}
+
 // statusHandler /statusエンドポイントのハンドラー
 func statusHandler(w http.ResponseWriter, _ *http.Request) {
 	response := map[string]string{
 		"message": "hato-bot-go is running",
-		"version": Version,
+		"version": BuildVersion(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -21,20 +49,158 @@ func statusHandler(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-// StartStatusHTTPServer HTTPサーバーを開始
-func StartStatusHTTPServer() {
-	http.HandleFunc("/status", statusHandler)
-
-	port := "8080"
-	log.Printf("Starting HTTP server on port %s", port)
+// RegisterStatusHandlers /statusと/metricsのエンドポイントを登録する
+func RegisterStatusHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/status", statusHandler)
+	mux.HandleFunc("/metrics", metrics.Handler(metrics.Default))
+}
 
-	server := &http.Server{
+// NewHTTPServer 指定したポートで待ち受けるHTTPサーバーを作成する
+func NewHTTPServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
 		Addr:         ":" + port,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
-	if err := server.ListenAndServe(); err != nil {
+}
+
+// ListenAndServe 設定に応じてTLSなし・証明書ファイル・autocertのいずれかでHTTPサーバーを開始する
+// autocertの場合はHTTP-01チャレンジに応答できないため、TLS-ALPN-01チャレンジで証明書を取得する
+func ListenAndServe(server *http.Server, setting *HTTPServerSetting) error {
+	switch {
+	case !setting.TLS():
+		if err := server.ListenAndServe(); err != nil {
+			return errors.Wrap(err, "Failed to ListenAndServe")
+		}
+	case setting.CertFile != "":
+		if err := server.ListenAndServeTLS(setting.CertFile, setting.KeyFile); err != nil {
+			return errors.Wrap(err, "Failed to ListenAndServeTLS")
+		}
+	default:
+		cacheDir := setting.AutocertCacheDir
+		if cacheDir == "" {
+			cacheDir = DefaultAutocertCacheDir
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(setting.AutocertDomains...),
+			Cache:      autocert.DirCache(cacheDir),
+		}
+		server.TLSConfig = manager.TLSConfig()
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			return errors.Wrap(err, "Failed to ListenAndServeTLS")
+		}
+	}
+	return nil
+}
+
+// ClientIP リクエストの送信元のIPアドレスを返す
+// 直接の接続元が信頼するリバースプロキシの場合は、X-Forwarded-Forを右から辿って最初の信頼しないアドレスを返す
+// 信頼しないクライアントはX-Forwarded-Forを偽装できるため、左端の値は使わない
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !trusted(addr, trustedProxies) {
+		return remote
+	}
+
+	var forwarded []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(value, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(entry))
+		}
+	}
+	client := addr.String()
+	for _, entry := range slices.Backward(forwarded) {
+		forwardedAddr, err := netip.ParseAddr(entry)
+		if err != nil {
+			// 解析できない値より左は信頼できない
+			break
+		}
+		client = forwardedAddr.Unmap().String()
+		if !trusted(forwardedAddr, trustedProxies) {
+			break
+		}
+	}
+	return client
+}
+
+// trusted アドレスが信頼するリバースプロキシに含まれるかを返す
+func trusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	return slices.ContainsFunc(trustedProxies, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// statusRecorder 書き込んだステータスコードを記録するhttp.ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader ステータスコードを記録して書き込む
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write ステータスコードが未設定の場合は200として記録して書き込む
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	if err != nil {
+		return n, errors.Wrap(err, "Failed to Write")
+	}
+	return n, nil
+}
+
+// Unwrap http.ResponseControllerが元のhttp.ResponseWriterを使えるようにする
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LogRequests リクエストごとに送信元のIPアドレス・メソッド・パス・ステータスコード・処理時間をログに出力する
+// クエリにはAPIキーなどが含まれうるため、ログにはパスのみ出力する
+func LogRequests(next http.Handler, trustedProxies []netip.Prefix) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		log.Printf("http: %s %s %s %d %s", ClientIP(r, trustedProxies), r.Method, r.URL.Path, status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// StartStatusHTTPServer HTTPサーバーを開始
+// /status・/metricsに加えて、コマンドの処理の履歴の集計を/statsで返す
+func StartStatusHTTPServer(store *history.Store, setting *HTTPServerSetting) {
+	mux := http.NewServeMux()
+	RegisterStatusHandlers(mux)
+	mux.HandleFunc("/stats", history.Handler(store))
+
+	var trustedProxies []netip.Prefix
+	if setting != nil {
+		trustedProxies = setting.TrustedProxies
+	}
+
+	port := "8080"
+	log.Printf("Starting HTTP server on port %s (TLS: %t)", port, setting.TLS())
+
+	server := NewHTTPServer(port, LogRequests(mux, trustedProxies))
+	if err := ListenAndServe(server, setting); err != nil {
 		log.Printf("HTTP server error: %v", err)
 	}
 }
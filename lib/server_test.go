@@ -0,0 +1,136 @@
+package lib_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"hato-bot-go/lib"
+)
+
+func TestClientIP(t *testing.T) {
+	trustedProxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		trustProxies bool
+		expected     string
+	}{
+		{
+			name:       "X-Forwarded-Forなし",
+			remoteAddr: "203.0.113.5:54321",
+			expected:   "203.0.113.5",
+		},
+		{
+			name:         "信頼しない接続元のX-Forwarded-Forは無視する",
+			remoteAddr:   "203.0.113.5:54321",
+			forwardedFor: []string{"198.51.100.7"},
+			trustProxies: true,
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "信頼するプロキシからの接続",
+			remoteAddr:   "10.0.0.2:54321",
+			forwardedFor: []string{"198.51.100.7"},
+			trustProxies: true,
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "信頼するプロキシを設定しない場合は接続元",
+			remoteAddr:   "10.0.0.2:54321",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "10.0.0.2",
+		},
+		{
+			name:         "偽装された左端の値は使わない",
+			remoteAddr:   "10.0.0.2:54321",
+			forwardedFor: []string{"192.0.2.1, 198.51.100.7, 10.0.0.3"},
+			trustProxies: true,
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "複数のヘッダー",
+			remoteAddr:   "[fd00::2]:54321",
+			forwardedFor: []string{"192.0.2.1", "2001:db8::1"},
+			trustProxies: true,
+			expected:     "2001:db8::1",
+		},
+		{
+			name:         "全て信頼するプロキシの場合は左端",
+			remoteAddr:   "10.0.0.2:54321",
+			forwardedFor: []string{"10.0.0.4, 10.0.0.3"},
+			trustProxies: true,
+			expected:     "10.0.0.4",
+		},
+		{
+			name:         "解析できない値で止める",
+			remoteAddr:   "10.0.0.2:54321",
+			forwardedFor: []string{"unknown, 10.0.0.3"},
+			trustProxies: true,
+			expected:     "10.0.0.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodGet, "/status", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			var proxies []netip.Prefix
+			if tt.trustProxies {
+				proxies = trustedProxies
+			}
+			if result := lib.ClientIP(r, proxies); result != tt.expected {
+				t.Errorf("ClientIP() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	t.Parallel()
+	handler := lib.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("hato"))
+	}), nil)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status?api_key=secret", nil))
+	if recorder.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusTeapot)
+	}
+	if body := recorder.Body.String(); body != "hato" {
+		t.Errorf("body = %q, want %q", body, "hato")
+	}
+}
+
+func TestHTTPServerSettingTLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  *lib.HTTPServerSetting
+		expected bool
+	}{
+		{name: "設定なし", setting: nil, expected: false},
+		{name: "リバースプロキシのみ", setting: &lib.HTTPServerSetting{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}, expected: false},
+		{name: "証明書ファイル", setting: &lib.HTTPServerSetting{CertFile: "cert.pem", KeyFile: "key.pem"}, expected: true},
+		{name: "autocert", setting: &lib.HTTPServerSetting{AutocertDomains: []string{"hato.example.com"}}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if result := tt.setting.TLS(); result != tt.expected {
+				t.Errorf("TLS() = %t, want %t", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DirEnv 状態を保存するディレクトリを指定する環境変数
+const DirEnv = "HATO_BOT_STATE_DIR"
+
+// appName XDG_STATE_HOMEなどの下に作るディレクトリ名
+const appName = "hato-bot-go"
+
+// 状態の種類ごとのサブディレクトリ
+const (
+	History  = "history"  // コマンドの処理の履歴
+	Autocert = "autocert" // 自動で取得したTLS証明書
+	Queue    = "queue"    // 処理の前に保存したコマンドのジョブ
+)
+
+// ErrInvalidName サブディレクトリの名前が不正であることを表すエラー
+var ErrInvalidName = errors.New("invalid state name")
+
+// Dir 実行環境ごとに状態を保存するディレクトリ
+// キャッシュや履歴などは種類ごとのサブディレクトリに保存し、種類ごとに使用量の確認と削除ができる
+type Dir struct {
+	Root string // ディレクトリのパス
+}
+
+// ResolveParams Resolveのパラメータ
+type ResolveParams struct {
+	Configured string                  // 設定ファイルのstate_dirの値（空の場合は環境変数で決める）
+	Getenv     func(key string) string // 環境変数の取得（nilの場合はos.Getenv）
+}
+
+// Resolve 状態を保存するディレクトリを決める
+// HATO_BOT_STATE_DIR・設定ファイルのstate_dir・$XDG_STATE_HOME/hato-bot-go・$HOME/.local/state/hato-bot-goの順に使い、
+// HOMEのないコンテナなどではテンポラリディレクトリの下を使う（ディレクトリは使うまで作成しない）
+func Resolve(params *ResolveParams) *Dir {
+	p := ResolveParams{}
+	if params != nil {
+		p = *params
+	}
+	getenv := p.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	switch {
+	case getenv(DirEnv) != "":
+		return &Dir{Root: filepath.Clean(getenv(DirEnv))}
+	case p.Configured != "":
+		return &Dir{Root: filepath.Clean(p.Configured)}
+	case getenv("XDG_STATE_HOME") != "":
+		return &Dir{Root: filepath.Join(getenv("XDG_STATE_HOME"), appName)}
+	case getenv("HOME") != "" && getenv("HOME") != "/":
+		return &Dir{Root: filepath.Join(getenv("HOME"), ".local", "state", appName)}
+	default:
+		return &Dir{Root: filepath.Join(os.TempDir(), appName)}
+	}
+}
+
+// validateName サブディレクトリの名前がRootの直下を指すか検査する
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.Wrapf(ErrInvalidName, "name: %q", name)
+	}
+	return nil
+}
+
+// Path サブディレクトリの下のパスを返す（ディレクトリは作成しない）
+func (d *Dir) Path(name string, elem ...string) string {
+	return filepath.Join(append([]string{d.Root, name}, elem...)...)
+}
+
+// Ensure サブディレクトリを作成してパスを返す
+func (d *Dir) Ensure(name string) (string, error) {
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+	path := d.Path(name)
+	if err := os.MkdirAll(path, 0o750); err != nil {
+		return "", errors.Wrap(err, "Failed to os.MkdirAll")
+	}
+	return path, nil
+}
+
+// Usage サブディレクトリの使用量
+type Usage struct {
+	Name  string // サブディレクトリの名前
+	Files int    // ファイルの数
+	Bytes int64  // ファイルの大きさの合計（バイト）
+}
+
+// Usage サブディレクトリごとの使用量を名前順に返す（Rootがない場合は空）
+// Rootの直下のファイルはサブディレクトリと同じく1件として数える
+func (d *Dir) Usage() ([]Usage, error) {
+	entries, err := os.ReadDir(d.Root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to os.ReadDir")
+	}
+
+	usages := make([]Usage, 0, len(entries))
+	for _, entry := range entries {
+		usage := Usage{Name: entry.Name()}
+		if err := filepath.WalkDir(d.Path(entry.Name()), func(_ string, file fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if file.IsDir() {
+				return nil
+			}
+			info, err := file.Info()
+			if err != nil {
+				return errors.Wrap(err, "Failed to Info")
+			}
+			usage.Files++
+			usage.Bytes += info.Size()
+			return nil
+		}); err != nil {
+			return nil, errors.Wrap(err, "Failed to filepath.WalkDir")
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// Purge 指定したサブディレクトリを削除し、削除した使用量を返す（namesが空の場合は全て削除する）
+// 存在しないサブディレクトリは無視する
+func (d *Dir) Purge(names ...string) ([]Usage, error) {
+	for _, name := range names {
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+	}
+	usages, err := d.Usage()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Usage")
+	}
+
+	var purged []Usage
+	for _, usage := range usages {
+		if 0 < len(names) && !slices.Contains(names, usage.Name) {
+			continue
+		}
+		if err := os.RemoveAll(d.Path(usage.Name)); err != nil {
+			return purged, errors.Wrap(err, "Failed to os.RemoveAll")
+		}
+		purged = append(purged, usage)
+	}
+	return purged, nil
+}
@@ -0,0 +1,152 @@
+package state_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/state"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		env        map[string]string
+		expected   string
+	}{
+		{
+			name:       "環境変数を優先",
+			configured: "/srv/hato",
+			env:        map[string]string{state.DirEnv: "/data", "XDG_STATE_HOME": "/xdg", "HOME": "/home/hato"},
+			expected:   "/data",
+		},
+		{
+			name:       "設定ファイルのstate_dir",
+			configured: "/srv/hato/",
+			env:        map[string]string{"XDG_STATE_HOME": "/xdg", "HOME": "/home/hato"},
+			expected:   "/srv/hato",
+		},
+		{
+			name:     "XDG_STATE_HOME",
+			env:      map[string]string{"XDG_STATE_HOME": "/xdg", "HOME": "/home/hato"},
+			expected: "/xdg/hato-bot-go",
+		},
+		{
+			name:     "ホームディレクトリ",
+			env:      map[string]string{"HOME": "/home/hato"},
+			expected: "/home/hato/.local/state/hato-bot-go",
+		},
+		{
+			name:     "HOMEのないコンテナ",
+			env:      map[string]string{"HOME": "/"},
+			expected: filepath.Join(os.TempDir(), "hato-bot-go"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := state.Resolve(&state.ResolveParams{
+				Configured: tt.configured,
+				Getenv:     func(key string) string { return tt.env[key] },
+			})
+			if dir.Root != tt.expected {
+				t.Errorf("Root = %q, want %q", dir.Root, tt.expected)
+			}
+		})
+	}
+}
+
+// writeFile 状態のディレクトリの下にファイルを作成する
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirUsageAndPurge(t *testing.T) {
+	tests := []struct {
+		name           string
+		purge          []string
+		expectedPurged []state.Usage
+		expectedRest   []state.Usage
+		expectedError  error
+	}{
+		{
+			name:           "指定したサブディレクトリを削除",
+			purge:          []string{state.Autocert, "missing"},
+			expectedPurged: []state.Usage{{Name: "autocert", Files: 2, Bytes: 30}},
+			expectedRest:   []state.Usage{{Name: "history", Files: 1, Bytes: 100}},
+		},
+		{
+			name: "名前を省略した場合は全て削除",
+			expectedPurged: []state.Usage{
+				{Name: "autocert", Files: 2, Bytes: 30},
+				{Name: "history", Files: 1, Bytes: 100},
+			},
+			expectedRest: []state.Usage{},
+		},
+		{
+			name:          "ディレクトリの外を指す名前",
+			purge:         []string{".."},
+			expectedError: state.ErrInvalidName,
+			expectedRest: []state.Usage{
+				{Name: "autocert", Files: 2, Bytes: 30},
+				{Name: "history", Files: 1, Bytes: 100},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := &state.Dir{Root: t.TempDir()}
+			writeFile(t, dir.Path(state.History, "history.jsonl"), 100)
+			writeFile(t, dir.Path(state.Autocert, "example.com"), 20)
+			writeFile(t, dir.Path(state.Autocert, "acme", "account"), 10)
+
+			purged, err := dir.Purge(tt.purge...)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Purge() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expectedPurged, purged); diff != "" {
+				t.Errorf("Purge() mismatch (-want +got):\n%s", diff)
+			}
+
+			rest, err := dir.Usage()
+			if err != nil {
+				t.Fatalf("Usage() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.expectedRest, rest); diff != "" {
+				t.Errorf("Usage() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDirEnsure(t *testing.T) {
+	t.Parallel()
+	dir := &state.Dir{Root: filepath.Join(t.TempDir(), "state")}
+	if usages, err := dir.Usage(); err != nil || usages != nil {
+		t.Fatalf("Usage() = %v, %v, want nil, nil", usages, err)
+	}
+
+	path, err := dir.Ensure(state.History)
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Errorf("Stat(%s) = %v, %v, want directory", path, info, err)
+	}
+	if _, err := dir.Ensure("../history"); !errors.Is(err, state.ErrInvalidName) {
+		t.Errorf("Ensure() error = %v, want %v", err, state.ErrInvalidName)
+	}
+}
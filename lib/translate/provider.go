@@ -0,0 +1,262 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+)
+
+// 翻訳サービスの既定のURL
+const (
+	deepLFreeURL = "https://api-free.deepl.com/v2/translate"                  // DeepL API Free
+	deepLProURL  = "https://api.deepl.com/v2/translate"                       // DeepL API Pro
+	googleURL    = "https://translation.googleapis.com/language/translate/v2" // Google Cloud Translation API
+)
+
+// postJSONParams JSONのPOSTのリクエスト構造体
+type postJSONParams struct {
+	client *http.Client      // HTTPクライアント
+	url    string            // 送信先のURL
+	header map[string]string // 追加するヘッダー
+	body   any               // JSONで送信する値
+	result any               // レスポンスのJSONを読み込む値
+}
+
+// postJSON 値をJSONでPOSTし、レスポンスのJSONを読み込む
+func postJSON(ctx context.Context, params *postJSONParams) error {
+	body, err := json.Marshal(params.body)
+	if err != nil {
+		return errors.Wrap(err, "Failed to json.Marshal")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, params.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range params.header {
+		req.Header.Set(name, value)
+	}
+
+	respBody, err := httpclient.ExecuteAndReadBody(params.client, req, httpclient.DefaultMaxResponseBytes)
+	if err != nil {
+		return errors.Wrap(err, "Failed to ExecuteAndReadBody")
+	}
+	if err := json.Unmarshal(respBody, params.result); err != nil {
+		return errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return nil
+}
+
+// DeepLTranslator DeepL APIで翻訳する
+type DeepLTranslator struct {
+	apiKey string
+	url    string
+	client *http.Client
+}
+
+// NewDeepLTranslator 新しいDeepLTranslatorを作成する
+// URLが空の場合は、APIキーが:fxで終わる場合はFree版、それ以外はPro版のURLを使う
+func NewDeepLTranslator(setting *ProviderSetting) *DeepLTranslator {
+	url := setting.URL
+	if url == "" {
+		url = deepLProURL
+		if strings.HasSuffix(setting.APIKey, ":fx") {
+			url = deepLFreeURL
+		}
+	}
+	return &DeepLTranslator{apiKey: setting.APIKey, url: url, client: newTranslatorClient(setting.Client)}
+}
+
+// deepLRequest DeepL APIのリクエスト
+type deepLRequest struct {
+	Text       []string `json:"text"`
+	SourceLang string   `json:"source_lang,omitempty"`
+	TargetLang string   `json:"target_lang"`
+}
+
+// deepLResponse DeepL APIのレスポンス
+type deepLResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+// Translate 文章を翻訳する
+func (t *DeepLTranslator) Translate(ctx context.Context, req *Request) (*Result, error) {
+	if req == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	target := normalizeLanguage(req.Target)
+	targetLang := strings.ToUpper(target)
+	// 英語は地域を指定する必要がある
+	if target == "en" {
+		targetLang = "EN-US"
+	}
+
+	var resp deepLResponse
+	if err := postJSON(ctx, &postJSONParams{
+		client: t.client,
+		url:    t.url,
+		header: map[string]string{"Authorization": "DeepL-Auth-Key " + t.apiKey},
+		body: &deepLRequest{
+			Text:       []string{req.Text},
+			SourceLang: strings.ToUpper(normalizeLanguage(req.Source)),
+			TargetLang: targetLang,
+		},
+		result: &resp,
+	}); err != nil {
+		return nil, errors.Wrap(err, "Failed to postJSON")
+	}
+	if len(resp.Translations) == 0 {
+		return nil, ErrNoTranslation
+	}
+
+	translation := resp.Translations[0]
+	return &Result{
+		Text:   translation.Text,
+		Source: normalizeLanguage(translation.DetectedSourceLanguage),
+		Target: target,
+	}, nil
+}
+
+// GoogleTranslator Google Cloud Translation API（v2）で翻訳する
+type GoogleTranslator struct {
+	apiKey string
+	url    string
+	client *http.Client
+}
+
+// NewGoogleTranslator 新しいGoogleTranslatorを作成する
+func NewGoogleTranslator(setting *ProviderSetting) *GoogleTranslator {
+	url := setting.URL
+	if url == "" {
+		url = googleURL
+	}
+	return &GoogleTranslator{apiKey: setting.APIKey, url: url, client: newTranslatorClient(setting.Client)}
+}
+
+// googleRequest Google Cloud Translation APIのリクエスト
+type googleRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+}
+
+// googleResponse Google Cloud Translation APIのレスポンス
+type googleResponse struct {
+	Data struct {
+		Translations []struct {
+			TranslatedText         string `json:"translatedText"`
+			DetectedSourceLanguage string `json:"detectedSourceLanguage"`
+		} `json:"translations"`
+	} `json:"data"`
+}
+
+// Translate 文章を翻訳する
+func (t *GoogleTranslator) Translate(ctx context.Context, req *Request) (*Result, error) {
+	if req == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	source := normalizeLanguage(req.Source)
+	target := normalizeLanguage(req.Target)
+	var resp googleResponse
+	if err := postJSON(ctx, &postJSONParams{
+		client: t.client,
+		url:    t.url,
+		// APIキーをURLに含めるとエラーのログに残るため、ヘッダーで送信する
+		header: map[string]string{"X-Goog-Api-Key": t.apiKey},
+		// HTMLエスケープされないよう、プレーンテキストとして翻訳する
+		body:   &googleRequest{Q: req.Text, Source: source, Target: target, Format: "text"},
+		result: &resp,
+	}); err != nil {
+		return nil, errors.Wrap(err, "Failed to postJSON")
+	}
+	if len(resp.Data.Translations) == 0 {
+		return nil, ErrNoTranslation
+	}
+
+	translation := resp.Data.Translations[0]
+	// 原文の言語を指定した場合は判定結果を返さない
+	detected := normalizeLanguage(translation.DetectedSourceLanguage)
+	if detected == "" {
+		detected = source
+	}
+	return &Result{Text: translation.TranslatedText, Source: detected, Target: target}, nil
+}
+
+// LibreTranslator LibreTranslateで翻訳する
+type LibreTranslator struct {
+	apiKey string
+	url    string
+	client *http.Client
+}
+
+// NewLibreTranslator 新しいLibreTranslatorを作成する
+// URLにはLibreTranslateのサーバーのURL（例: https://libretranslate.example.com）を指定する
+func NewLibreTranslator(setting *ProviderSetting) *LibreTranslator {
+	return &LibreTranslator{
+		apiKey: setting.APIKey,
+		url:    strings.TrimSuffix(setting.URL, "/") + "/translate",
+		client: newTranslatorClient(setting.Client),
+	}
+}
+
+// libreRequest LibreTranslateのリクエスト
+type libreRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// libreResponse LibreTranslateのレスポンス
+type libreResponse struct {
+	TranslatedText   string `json:"translatedText"`
+	DetectedLanguage *struct {
+		Language string `json:"language"`
+	} `json:"detectedLanguage,omitempty"`
+}
+
+// Translate 文章を翻訳する
+func (t *LibreTranslator) Translate(ctx context.Context, req *Request) (*Result, error) {
+	if req == nil {
+		return nil, lib.ErrParamsNil
+	}
+
+	source := normalizeLanguage(req.Source)
+	if source == "" {
+		source = "auto"
+	}
+	target := normalizeLanguage(req.Target)
+	var resp libreResponse
+	if err := postJSON(ctx, &postJSONParams{
+		client: t.client,
+		url:    t.url,
+		body:   &libreRequest{Q: req.Text, Source: source, Target: target, Format: "text", APIKey: t.apiKey},
+		result: &resp,
+	}); err != nil {
+		return nil, errors.Wrap(err, "Failed to postJSON")
+	}
+	if resp.TranslatedText == "" {
+		return nil, ErrNoTranslation
+	}
+
+	detected := normalizeLanguage(req.Source)
+	if resp.DetectedLanguage != nil {
+		detected = normalizeLanguage(resp.DetectedLanguage.Language)
+	}
+	return &Result{Text: resp.TranslatedText, Source: detected, Target: target}, nil
+}
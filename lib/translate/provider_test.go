@@ -0,0 +1,211 @@
+package translate_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/translate"
+)
+
+// newTranslatorParams テスト用の翻訳サービス作成のパラメータ
+type newTranslatorParams struct {
+	provider string
+	apiKey   string
+	url      string
+	client   *http.Client
+}
+
+// newTestTranslator 翻訳サービスの種類に対応するTranslatorを作成する
+func newTestTranslator(params *newTranslatorParams) translate.Translator {
+	setting := &translate.ProviderSetting{APIKey: params.apiKey, URL: params.url, Client: params.client}
+	switch params.provider {
+	case translate.ProviderDeepL:
+		return translate.NewDeepLTranslator(setting)
+	case translate.ProviderGoogle:
+		return translate.NewGoogleTranslator(setting)
+	default:
+		return translate.NewLibreTranslator(setting)
+	}
+}
+
+func TestTranslators(t *testing.T) {
+	tests := []struct {
+		name           string
+		provider       string
+		apiKey         string
+		url            string
+		request        *translate.Request
+		response       httpclient.MockResponse
+		expected       *translate.Result
+		expectedURL    string
+		expectedHeader map[string]string
+		expectedBody   map[string]any
+		expectedError  error
+	}{
+		{
+			name:     "DeepL（Free版）",
+			provider: translate.ProviderDeepL,
+			apiKey:   "key:fx",
+			request:  &translate.Request{Text: "hello", Target: "ja"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"translations":[{"detected_source_language":"EN","text":"こんにちは"}]}`,
+			},
+			expected:       &translate.Result{Text: "こんにちは", Source: "en", Target: "ja"},
+			expectedURL:    "https://api-free.deepl.com/v2/translate",
+			expectedHeader: map[string]string{"Authorization": "DeepL-Auth-Key key:fx"},
+			expectedBody:   map[string]any{"text": []any{"hello"}, "target_lang": "JA"},
+		},
+		{
+			name:     "DeepL（Pro版・英語への翻訳）",
+			provider: translate.ProviderDeepL,
+			apiKey:   "key",
+			request:  &translate.Request{Text: "こんにちは", Source: "ja", Target: "en"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"translations":[{"detected_source_language":"JA","text":"Hello"}]}`,
+			},
+			expected:       &translate.Result{Text: "Hello", Source: "ja", Target: "en"},
+			expectedURL:    "https://api.deepl.com/v2/translate",
+			expectedHeader: map[string]string{"Authorization": "DeepL-Auth-Key key"},
+			expectedBody:   map[string]any{"text": []any{"こんにちは"}, "source_lang": "JA", "target_lang": "EN-US"},
+		},
+		{
+			name:     "DeepLの翻訳結果なし",
+			provider: translate.ProviderDeepL,
+			apiKey:   "key",
+			request:  &translate.Request{Text: "hello", Target: "ja"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"translations":[]}`,
+			},
+			expectedError: translate.ErrNoTranslation,
+		},
+		{
+			name:     "Google",
+			provider: translate.ProviderGoogle,
+			apiKey:   "key",
+			request:  &translate.Request{Text: "hello", Target: "ja"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"data":{"translations":[{"translatedText":"こんにちは","detectedSourceLanguage":"en"}]}}`,
+			},
+			expected:       &translate.Result{Text: "こんにちは", Source: "en", Target: "ja"},
+			expectedURL:    "https://translation.googleapis.com/language/translate/v2",
+			expectedHeader: map[string]string{"X-Goog-Api-Key": "key"},
+			expectedBody:   map[string]any{"q": "hello", "target": "ja", "format": "text"},
+		},
+		{
+			name:     "Google（原文の言語を指定）",
+			provider: translate.ProviderGoogle,
+			apiKey:   "key",
+			request:  &translate.Request{Text: "こんにちは", Source: "ja", Target: "en"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"data":{"translations":[{"translatedText":"Hello"}]}}`,
+			},
+			expected:       &translate.Result{Text: "Hello", Source: "ja", Target: "en"},
+			expectedURL:    "https://translation.googleapis.com/language/translate/v2",
+			expectedHeader: map[string]string{"X-Goog-Api-Key": "key"},
+			expectedBody:   map[string]any{"q": "こんにちは", "source": "ja", "target": "en", "format": "text"},
+		},
+		{
+			name:     "Googleのエラー応答",
+			provider: translate.ProviderGoogle,
+			apiKey:   "key",
+			request:  &translate.Request{Text: "hello", Target: "ja"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       `{"error":{"code":403}}`,
+			},
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+		{
+			name:     "LibreTranslate",
+			provider: translate.ProviderLibreTranslate,
+			url:      "https://libretranslate.example.com/",
+			request:  &translate.Request{Text: "bonjour", Target: "ja"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"translatedText":"こんにちは","detectedLanguage":{"confidence":90,"language":"fr"}}`,
+			},
+			expected:     &translate.Result{Text: "こんにちは", Source: "fr", Target: "ja"},
+			expectedURL:  "https://libretranslate.example.com/translate",
+			expectedBody: map[string]any{"q": "bonjour", "source": "auto", "target": "ja", "format": "text"},
+		},
+		{
+			name:     "LibreTranslate（APIキーあり・原文の言語を指定）",
+			provider: translate.ProviderLibreTranslate,
+			apiKey:   "key",
+			url:      "https://libretranslate.example.com",
+			request:  &translate.Request{Text: "こんにちは", Source: "ja", Target: "en"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"translatedText":"Hello"}`,
+			},
+			expected:     &translate.Result{Text: "Hello", Source: "ja", Target: "en"},
+			expectedURL:  "https://libretranslate.example.com/translate",
+			expectedBody: map[string]any{"q": "こんにちは", "source": "ja", "target": "en", "format": "text", "api_key": "key"},
+		},
+		{
+			name:     "LibreTranslateの翻訳結果なし",
+			provider: translate.ProviderLibreTranslate,
+			url:      "https://libretranslate.example.com",
+			request:  &translate.Request{Text: "bonjour", Target: "ja"},
+			response: httpclient.MockResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{}`,
+			},
+			expectedError: translate.ErrNoTranslation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := httpclient.NewMockTransport(&httpclient.MockTransportParams{Fallback: tt.response})
+			translator := newTestTranslator(&newTranslatorParams{
+				provider: tt.provider,
+				apiKey:   tt.apiKey,
+				url:      tt.url,
+				client:   transport.Client(),
+			})
+
+			result, err := translator.Translate(t.Context(), tt.request)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Translate() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("Translate() mismatch (-want +got):\n%s", diff)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+
+			requests := transport.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+			if requests[0].URL != tt.expectedURL {
+				t.Errorf("URL = %q, want %q", requests[0].URL, tt.expectedURL)
+			}
+			for name, value := range tt.expectedHeader {
+				if got := requests[0].Header.Get(name); got != value {
+					t.Errorf("header %s = %q, want %q", name, got, value)
+				}
+			}
+			var body map[string]any
+			if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedBody, body); diff != "" {
+				t.Errorf("request body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package translate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/i18n"
+)
+
+var (
+	// ErrUnknownProvider 存在しない翻訳サービスの種類を指定したことを表すエラー
+	ErrUnknownProvider = errors.New("unknown translate provider")
+	// ErrAPIKeyRequired 翻訳サービスのAPIキーが設定されていないことを表すエラー
+	ErrAPIKeyRequired = errors.New("translate api key is required")
+	// ErrURLRequired 翻訳サービスのURLが設定されていないことを表すエラー
+	ErrURLRequired = errors.New("translate url is required")
+	// ErrNoText 翻訳する文章がないことを表すエラー
+	ErrNoText = errors.New("no text to translate")
+	// ErrNoTranslation 翻訳サービスが翻訳結果を返さなかったことを表すエラー
+	ErrNoTranslation = errors.New("no translation returned")
+)
+
+// 翻訳サービスの種類
+const (
+	ProviderDeepL          = "deepl"          // DeepL API
+	ProviderGoogle         = "google"         // Google Cloud Translation API（v2）
+	ProviderLibreTranslate = "libretranslate" // LibreTranslate
+)
+
+// defaultTranslatorTimeout 翻訳サービスへのリクエストのタイムアウト
+const defaultTranslatorTimeout = 30 * time.Second
+
+// Request 翻訳のリクエスト
+type Request struct {
+	Text   string // 翻訳する文章
+	Source string // 原文の言語（ISO 639-1、空の場合は翻訳サービスが判定する）
+	Target string // 翻訳先の言語（ISO 639-1）
+}
+
+// Result 翻訳の結果
+type Result struct {
+	Text   string // 翻訳した文章
+	Source string // 原文の言語（ISO 639-1の小文字、判定できなかった場合は空）
+	Target string // 翻訳先の言語（ISO 639-1の小文字）
+}
+
+// Translator 翻訳サービス
+type Translator interface {
+	Translate(ctx context.Context, req *Request) (*Result, error)
+}
+
+// ProviderSetting 翻訳サービスの設定
+type ProviderSetting struct {
+	APIKey string       // APIキー
+	URL    string       // APIのURL（空の場合は翻訳サービスの既定のURL）
+	Client *http.Client // HTTPクライアント（nilの場合はタイムアウト付きのクライアント）
+}
+
+// NewTranslatorFromConfig 設定ファイルの翻訳サービスの設定からTranslatorを作成する（clientがnilの場合はタイムアウト付きのクライアントを使う）
+// 翻訳サービスが設定されていない場合はnilを返す
+func NewTranslatorFromConfig(setting *config.Translate, client *http.Client) (Translator, error) {
+	if setting == nil {
+		return nil, nil
+	}
+
+	providerSetting := &ProviderSetting{APIKey: setting.APIKey, URL: setting.URL, Client: client}
+	switch strings.ToLower(setting.Provider) {
+	case ProviderDeepL:
+		if setting.APIKey == "" {
+			return nil, errors.Wrapf(ErrAPIKeyRequired, "provider: %s", setting.Provider)
+		}
+		return NewDeepLTranslator(providerSetting), nil
+	case ProviderGoogle:
+		if setting.APIKey == "" {
+			return nil, errors.Wrapf(ErrAPIKeyRequired, "provider: %s", setting.Provider)
+		}
+		return NewGoogleTranslator(providerSetting), nil
+	case ProviderLibreTranslate:
+		if setting.URL == "" {
+			return nil, errors.Wrapf(ErrURLRequired, "provider: %s", setting.Provider)
+		}
+		return NewLibreTranslator(providerSetting), nil
+	default:
+		return nil, errors.Wrapf(ErrUnknownProvider, "provider: %s", setting.Provider)
+	}
+}
+
+// AutoParams 自動翻訳のリクエスト構造体
+type AutoParams struct {
+	Translator Translator // 翻訳サービス
+	Text       string     // 翻訳する文章
+	Target     string     // 翻訳先の言語（ISO 639-1）
+}
+
+// Auto 原文の言語を判定して翻訳先の言語に翻訳する
+// 原文が翻訳先の言語で書かれていた場合は、日本語は英語に、それ以外は日本語に翻訳し直す
+func Auto(ctx context.Context, params *AutoParams) (*Result, error) {
+	if params == nil || params.Translator == nil {
+		return nil, lib.ErrParamsNil
+	}
+	text := strings.TrimSpace(params.Text)
+	if text == "" {
+		return nil, ErrNoText
+	}
+
+	target := normalizeLanguage(params.Target)
+	result, err := params.Translator.Translate(ctx, &Request{Text: text, Target: target})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Translate")
+	}
+	if result.Source != target {
+		return result, nil
+	}
+
+	alternative := string(i18n.LocaleJa)
+	if target == string(i18n.LocaleJa) {
+		alternative = string(i18n.LocaleEn)
+	}
+	result, err = params.Translator.Translate(ctx, &Request{Text: text, Source: target, Target: alternative})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to Translate")
+	}
+	return result, nil
+}
+
+// ParseTranslateCommandResult translateコマンドの解析結果
+type ParseTranslateCommandResult struct {
+	Text        string // 翻訳する文章（空の場合は返信先の投稿を翻訳する）
+	IsTranslate bool   // translateコマンドか
+}
+
+// ParseTranslateCommand メンションを除去したテキストがtranslateコマンドか解析する
+// 改行を残すため、先頭のメンションとコマンド名より後ろの文章はそのまま返す
+func ParseTranslateCommand(text string) ParseTranslateCommandResult {
+	if !lib.ParseCommand(text, "translate").Matched {
+		return ParseTranslateCommandResult{}
+	}
+
+	rest := text
+	for {
+		rest = strings.TrimLeftFunc(rest, isSeparator)
+		end := strings.IndexFunc(rest, isSeparator)
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+		if !strings.HasPrefix(word, "@") {
+			break
+		}
+	}
+	return ParseTranslateCommandResult{Text: strings.TrimSpace(rest), IsTranslate: true}
+}
+
+// isSeparator 単語の区切りとして扱う文字か（空白とゼロ幅スペースなどの不可視文字）
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == '\u200b' || r == '\u2060' || r == '\ufeff' || r == '\u180e'
+}
+
+// CommandErrorKey translateコマンドの実行に失敗した場合に返信するメッセージのキーを返す
+func CommandErrorKey(err error) i18n.Key {
+	if errors.Is(err, ErrNoText) {
+		return i18n.KeyErrorTranslateNoText
+	}
+	return i18n.KeyErrorTranslateCommand
+}
+
+// newTranslatorClient 翻訳サービス用のHTTPクライアントを返す
+func newTranslatorClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: defaultTranslatorTimeout}
+}
+
+// normalizeLanguage 言語コードを小文字のISO 639-1に揃える（en-USはen）
+func normalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); 0 <= i {
+		language = language[:i]
+	}
+	return language
+}
@@ -0,0 +1,256 @@
+package translate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/config"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/translate"
+)
+
+// fakeTranslator 原文の言語を固定で返し、受け取ったリクエストを記録する翻訳サービス
+type fakeTranslator struct {
+	source   string
+	err      error
+	requests []translate.Request
+}
+
+func (f *fakeTranslator) Translate(_ context.Context, req *translate.Request) (*translate.Result, error) {
+	f.requests = append(f.requests, *req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &translate.Result{Text: "[" + req.Target + "]" + req.Text, Source: f.source, Target: req.Target}, nil
+}
+
+func TestNewTranslatorFromConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		setting       *config.Translate
+		expectNil     bool
+		expectedError error
+	}{
+		{
+			name:      "設定なし",
+			setting:   nil,
+			expectNil: true,
+		},
+		{
+			name:    "DeepL",
+			setting: &config.Translate{Provider: "deepl", APIKey: "key:fx"},
+		},
+		{
+			name:    "Google（大文字小文字を区別しない）",
+			setting: &config.Translate{Provider: "Google", APIKey: "key"},
+		},
+		{
+			name:    "LibreTranslate（APIキーは任意）",
+			setting: &config.Translate{Provider: "libretranslate", URL: "https://libretranslate.example.com"},
+		},
+		{
+			name:          "DeepLのAPIキーなし",
+			setting:       &config.Translate{Provider: "deepl"},
+			expectedError: translate.ErrAPIKeyRequired,
+		},
+		{
+			name:          "GoogleのAPIキーなし",
+			setting:       &config.Translate{Provider: "google"},
+			expectedError: translate.ErrAPIKeyRequired,
+		},
+		{
+			name:          "LibreTranslateのURLなし",
+			setting:       &config.Translate{Provider: "libretranslate"},
+			expectedError: translate.ErrURLRequired,
+		},
+		{
+			name:          "存在しない翻訳サービス",
+			setting:       &config.Translate{Provider: "bing", APIKey: "key"},
+			expectedError: translate.ErrUnknownProvider,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			translator, err := translate.NewTranslatorFromConfig(tt.setting, nil)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("NewTranslatorFromConfig() error = %v, want %v", err, tt.expectedError)
+			}
+			if tt.expectedError != nil {
+				return
+			}
+			if (translator == nil) != tt.expectNil {
+				t.Errorf("NewTranslatorFromConfig() = %v, want nil: %v", translator, tt.expectNil)
+			}
+		})
+	}
+}
+
+func TestAuto(t *testing.T) {
+	tests := []struct {
+		name             string
+		source           string
+		text             string
+		target           string
+		err              error
+		expected         *translate.Result
+		expectedRequests []translate.Request
+		expectedError    error
+	}{
+		{
+			name:     "送信者の言語に翻訳する",
+			source:   "en",
+			text:     " hello ",
+			target:   "ja",
+			expected: &translate.Result{Text: "[ja]hello", Source: "en", Target: "ja"},
+			expectedRequests: []translate.Request{
+				{Text: "hello", Target: "ja"},
+			},
+		},
+		{
+			name:     "原文が日本語の場合は英語に翻訳し直す",
+			source:   "ja",
+			text:     "こんにちは",
+			target:   "ja",
+			expected: &translate.Result{Text: "[en]こんにちは", Source: "ja", Target: "en"},
+			expectedRequests: []translate.Request{
+				{Text: "こんにちは", Target: "ja"},
+				{Text: "こんにちは", Source: "ja", Target: "en"},
+			},
+		},
+		{
+			name:     "原文が英語の英語話者には日本語に翻訳する",
+			source:   "en",
+			text:     "hello",
+			target:   "en-US",
+			expected: &translate.Result{Text: "[ja]hello", Source: "en", Target: "ja"},
+			expectedRequests: []translate.Request{
+				{Text: "hello", Target: "en"},
+				{Text: "hello", Source: "en", Target: "ja"},
+			},
+		},
+		{
+			name:          "文章なし",
+			text:          " \n ",
+			target:        "ja",
+			expectedError: translate.ErrNoText,
+		},
+		{
+			name:          "翻訳サービスのエラー",
+			text:          "hello",
+			target:        "ja",
+			err:           translate.ErrNoTranslation,
+			expectedError: translate.ErrNoTranslation,
+			expectedRequests: []translate.Request{
+				{Text: "hello", Target: "ja"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			translator := &fakeTranslator{source: tt.source, err: tt.err}
+			result, err := translate.Auto(t.Context(), &translate.AutoParams{Translator: translator, Text: tt.text, Target: tt.target})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Auto() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("Auto() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRequests, translator.requests); diff != "" {
+				t.Errorf("requests mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAutoNilParams(t *testing.T) {
+	t.Parallel()
+	if _, err := translate.Auto(t.Context(), nil); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("Auto(nil) error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
+
+func TestParseTranslateCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected translate.ParseTranslateCommandResult
+	}{
+		{
+			name:     "メンション付き",
+			text:     "@hato translate Hello, world!",
+			expected: translate.ParseTranslateCommandResult{Text: "Hello, world!", IsTranslate: true},
+		},
+		{
+			name:     "改行を残す",
+			text:     "@hato@example.com translate\nfirst line\n  second line\n",
+			expected: translate.ParseTranslateCommandResult{Text: "first line\n  second line", IsTranslate: true},
+		},
+		{
+			name:     "ボット名にコマンド名を含む",
+			text:     "@translate_bot translate bonjour",
+			expected: translate.ParseTranslateCommandResult{Text: "bonjour", IsTranslate: true},
+		},
+		{
+			name:     "ハッシュタグ",
+			text:     "#translate こんにちは",
+			expected: translate.ParseTranslateCommandResult{Text: "こんにちは", IsTranslate: true},
+		},
+		{
+			name:     "文章なし（返信先を翻訳する）",
+			text:     "@hato translate",
+			expected: translate.ParseTranslateCommandResult{IsTranslate: true},
+		},
+		{
+			name:     "ゼロ幅スペースで区切ったメンション",
+			text:     "@hato\u200btranslate hola",
+			expected: translate.ParseTranslateCommandResult{Text: "hola", IsTranslate: true},
+		},
+		{
+			name:     "別のコマンド",
+			text:     "@hato amesh 東京",
+			expected: translate.ParseTranslateCommandResult{},
+		},
+		{
+			name:     "コマンド名で始まる別の単語",
+			text:     "@hato translation please",
+			expected: translate.ParseTranslateCommandResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, translate.ParseTranslateCommand(tt.text)); diff != "" {
+				t.Errorf("ParseTranslateCommand(%q) mismatch (-want +got):\n%s", tt.text, diff)
+			}
+		})
+	}
+}
+
+func TestCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{name: "文章なし", err: errors.Wrap(translate.ErrNoText, "Failed to translate.Auto"), expected: i18n.KeyErrorTranslateNoText},
+		{name: "その他のエラー", err: translate.ErrNoTranslation, expected: i18n.KeyErrorTranslateCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := translate.CommandErrorKey(tt.err); got != tt.expected {
+				t.Errorf("CommandErrorKey() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// revisionLength バージョンに付けるコミットハッシュの長さ
+const revisionLength = 7
+
+// BuildVersion ビルド情報を含むバージョンを返す
+// VCSの情報付きでビルドした場合は「1.0+0123abc」のようにコミットハッシュを付け、未コミットの変更がある場合は「.dirty」を付ける
+var BuildVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	return buildVersion(info.Settings)
+})
+
+// buildVersion ビルド設定からバージョンを作成する
+func buildVersion(settings []debug.BuildSetting) string {
+	revision, modified := "", false
+	for _, setting := range settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return Version
+	}
+
+	version := Version + "+" + revision[:min(revisionLength, len(revision))]
+	if modified {
+		version += ".dirty"
+	}
+	return version
+}
@@ -0,0 +1,239 @@
+package wikipedia
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+)
+
+var (
+	// ErrNoTerm 調べる語句が指定されていないことを表すエラー
+	ErrNoTerm = errors.New("no term to look up")
+	// ErrNotFound 語句に一致する記事がないことを表すエラー
+	ErrNotFound = errors.New("no wikipedia article found")
+)
+
+// DefaultBaseURL 日本語版WikipediaのURL
+const DefaultBaseURL = "https://ja.wikipedia.org"
+
+const (
+	// maxExtractRunes 返信に含める記事の要約の最大文字数（ノートの文字数制限に収めるため）
+	maxExtractRunes = 200
+	// maxCandidates 曖昧さ回避のページで返信に含める候補の最大数
+	maxCandidates = 5
+	// ellipsis 要約を省略した場合に末尾に付ける文字
+	ellipsis = "…"
+)
+
+// typeDisambiguation 曖昧さ回避のページを表すsummary APIのtype
+const typeDisambiguation = "disambiguation"
+
+// defaultClient クライアント未指定時に使うHTTPクライアント
+// Wikipediaが不調な場合はサーキットブレーカーで即座に失敗させる
+var defaultClient = &http.Client{
+	Transport: httpclient.DefaultTransport,
+	Timeout:   30 * time.Second,
+}
+
+// Article 語句を調べた結果の記事
+type Article struct {
+	Title          string   // 記事名
+	Extract        string   // 記事の要約（maxExtractRunes文字を超える場合は省略する）
+	URL            string   // 記事のURL
+	Disambiguation bool     // 曖昧さ回避のページか
+	Candidates     []string // 曖昧さ回避のページの場合の候補の記事名（最大maxCandidates件）
+}
+
+// LookupWithClientParams 語句の検索のリクエスト構造体
+type LookupWithClientParams struct {
+	Client  *http.Client // HTTPクライアント
+	BaseURL string       // WikipediaのURL（空の場合はDefaultBaseURL）
+	Term    string       // 調べる語句
+}
+
+// ParseWikipediaCommandResult wikiコマンドの解析結果を表す構造体
+type ParseWikipediaCommandResult struct {
+	Term        string
+	IsWikipedia bool
+}
+
+// summaryResponse summary APIのレスポンス
+type summaryResponse struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Extract     string `json:"extract"`
+	ContentURLs struct {
+		Desktop struct {
+			Page string `json:"page"`
+		} `json:"desktop"`
+	} `json:"content_urls"`
+}
+
+// ParseWikipediaCommand 「what is 語句」または「wiki 語句」を解析する
+// 語句の末尾の疑問符は取り除く
+func ParseWikipediaCommand(text string) ParseWikipediaCommandResult {
+	if parsed := lib.ParseCommand(text, "wiki"); parsed.Matched {
+		return ParseWikipediaCommandResult{Term: trimTerm(parsed.Args), IsWikipedia: true}
+	}
+
+	for _, command := range []string{"what", "What"} {
+		parsed := lib.ParseCommand(text, command)
+		if !parsed.Matched {
+			continue
+		}
+		is, term, _ := strings.Cut(parsed.Args, " ")
+		if strings.EqualFold(is, "is") {
+			return ParseWikipediaCommandResult{Term: trimTerm(term), IsWikipedia: true}
+		}
+	}
+	return ParseWikipediaCommandResult{}
+}
+
+// trimTerm 語句の前後の空白と末尾の疑問符を取り除く
+func trimTerm(term string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(term), "?？"))
+}
+
+// LookupWithClient HTTPクライアントを指定して語句をWikipediaで調べる
+// opensearch APIで語句に最も近い記事名を探し、summary APIで要約を取得する
+func LookupWithClient(ctx context.Context, params *LookupWithClientParams) (*Article, error) {
+	if params == nil || params.Client == nil {
+		return nil, lib.ErrParamsNil
+	}
+	term := trimTerm(params.Term)
+	if term == "" {
+		return nil, ErrNoTerm
+	}
+	baseURL := params.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	titles, err := search(ctx, &searchParams{client: params.Client, baseURL: baseURL, term: term})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to search")
+	}
+	if len(titles) == 0 {
+		return nil, errors.Wrapf(ErrNotFound, "term: %s", term)
+	}
+
+	var summary summaryResponse
+	summaryURL := baseURL + "/api/rest_v1/page/summary/" + url.PathEscape(strings.ReplaceAll(titles[0], " ", "_"))
+	if err := getJSON(ctx, params.Client, summaryURL, &summary); err != nil {
+		return nil, errors.Wrap(err, "Failed to getJSON")
+	}
+
+	article := &Article{
+		Title:   summary.Title,
+		Extract: truncate(strings.TrimSpace(summary.Extract), maxExtractRunes),
+		URL:     summary.ContentURLs.Desktop.Page,
+	}
+	if summary.Type == typeDisambiguation {
+		article.Disambiguation = true
+		// 曖昧さ回避のページ自身を除いた検索結果を候補にする
+		article.Candidates = titles[1:min(len(titles), 1+maxCandidates)]
+	}
+	return article, nil
+}
+
+// Lookup 語句をWikipediaで調べる
+func Lookup(ctx context.Context, term string) (*Article, error) {
+	return LookupWithClient(ctx, &LookupWithClientParams{Client: defaultClient, Term: term})
+}
+
+// FillTemplateData 記事の内容を返信テンプレートの変数に設定する
+func (a *Article) FillTemplateData(data *i18n.TemplateData) {
+	data.Title = a.Title
+	data.Extract = a.Extract
+	data.URL = a.URL
+	data.Candidates = strings.Join(a.Candidates, "、")
+	if data.Locale == i18n.LocaleEn {
+		data.Candidates = strings.Join(a.Candidates, ", ")
+	}
+}
+
+// CommandErrorKey wikiコマンドの実行に失敗した場合に返信するメッセージのキーを返す
+func CommandErrorKey(err error) i18n.Key {
+	if errors.Is(err, ErrNoTerm) {
+		return i18n.KeyErrorWikipediaNoTerm
+	}
+	if errors.Is(err, ErrNotFound) {
+		return i18n.KeyErrorWikipediaNotFound
+	}
+	return i18n.KeyErrorWikipediaCommand
+}
+
+// searchParams opensearch APIの検索のリクエスト構造体
+type searchParams struct {
+	client  *http.Client
+	baseURL string
+	term    string
+}
+
+// search opensearch APIで語句に近い記事名を関連度の高い順に取得する
+func search(ctx context.Context, params *searchParams) ([]string, error) {
+	query := url.Values{
+		"action":    {"opensearch"},
+		"search":    {params.term},
+		"limit":     {strconv.Itoa(1 + maxCandidates)}, // 曖昧さ回避のページ自身と候補
+		"namespace": {"0"},
+		"redirects": {"resolve"},
+		"format":    {"json"},
+	}
+
+	// レスポンスは[検索語, [記事名...], [説明...], [URL...]]の配列
+	var response []json.RawMessage
+	if err := getJSON(ctx, params.client, params.baseURL+"/w/api.php?"+query.Encode(), &response); err != nil {
+		return nil, errors.Wrap(err, "Failed to getJSON")
+	}
+	if len(response) < 2 {
+		return nil, nil
+	}
+	var titles []string
+	if err := json.Unmarshal(response[1], &titles); err != nil {
+		return nil, errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return titles, nil
+}
+
+// getJSON URLのJSONを取得して読み込む
+func getJSON(ctx context.Context, client *http.Client, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "Failed to http.NewRequestWithContext")
+	}
+
+	body, err := httpclient.ExecuteAndReadBody(client, req, httpclient.DefaultMaxResponseBytes)
+	if err != nil {
+		return errors.Wrap(err, "Failed to httpclient.ExecuteAndReadBody")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrap(err, "Failed to json.Unmarshal")
+	}
+	return nil
+}
+
+// truncate 文字列をlimit文字以内に収める
+// 省略する場合は、limit文字以内の最後の句点で区切れればそこまで、区切れなければ末尾に省略記号を付ける
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	head := string(runes[:limit])
+	if i := strings.LastIndex(head, "。"); 0 < i {
+		return head[:i+len("。")]
+	}
+	return string(runes[:limit-1]) + ellipsis
+}
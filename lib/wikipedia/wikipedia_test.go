@@ -0,0 +1,268 @@
+package wikipedia_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"hato-bot-go/lib"
+	"hato-bot-go/lib/httpclient"
+	"hato-bot-go/lib/i18n"
+	"hato-bot-go/lib/wikipedia"
+)
+
+// newWikipediaTransport opensearch APIとsummary APIに応答するMockTransportを作成する
+func newWikipediaTransport(search, summary httpclient.MockResponse) *httpclient.MockTransport {
+	return httpclient.NewMockTransport(&httpclient.MockTransportParams{
+		Routes: []httpclient.MockRoute{
+			{Pattern: "/w/api.php", Responses: []httpclient.MockResponse{search}},
+			{Pattern: "/api/rest_v1/page/summary/", Responses: []httpclient.MockResponse{summary}},
+		},
+	})
+}
+
+func TestParseWikipediaCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected wikipedia.ParseWikipediaCommandResult
+	}{
+		{
+			name:     "what is",
+			text:     "@hato what is 鳩?",
+			expected: wikipedia.ParseWikipediaCommandResult{Term: "鳩", IsWikipedia: true},
+		},
+		{
+			name:     "大文字のWhat is",
+			text:     "@hato What IS Go (programming language)？",
+			expected: wikipedia.ParseWikipediaCommandResult{Term: "Go (programming language)", IsWikipedia: true},
+		},
+		{
+			name:     "wiki",
+			text:     "@hato wiki 東京タワー",
+			expected: wikipedia.ParseWikipediaCommandResult{Term: "東京タワー", IsWikipedia: true},
+		},
+		{
+			name:     "語句なし",
+			text:     "@hato wiki",
+			expected: wikipedia.ParseWikipediaCommandResult{IsWikipedia: true},
+		},
+		{
+			name:     "isが続かないwhat",
+			text:     "@hato what time is it",
+			expected: wikipedia.ParseWikipediaCommandResult{},
+		},
+		{
+			name:     "別のコマンド",
+			text:     "@hato amesh 東京",
+			expected: wikipedia.ParseWikipediaCommandResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, wikipedia.ParseWikipediaCommand(tt.text)); diff != "" {
+				t.Errorf("ParseWikipediaCommand(%q) mismatch (-want +got):\n%s", tt.text, diff)
+			}
+		})
+	}
+}
+
+func TestLookupWithClient(t *testing.T) {
+	longExtract := strings.Repeat("あ", 150) + "。" + strings.Repeat("い", 100) + "。"
+	longExtractWithoutPeriod := strings.Repeat("う", 250)
+
+	tests := []struct {
+		name            string
+		term            string
+		search          httpclient.MockResponse
+		summary         httpclient.MockResponse
+		expected        *wikipedia.Article
+		expectedSummary string
+		expectedError   error
+	}{
+		{
+			name:   "記事の要約",
+			term:   "鳩",
+			search: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `["鳩",["ハト","鳩山"],["",""],["",""]]`},
+			summary: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{
+				"type": "standard",
+				"title": "ハト",
+				"extract": "ハトは、ハト目ハト科に属する鳥類の総称である。",
+				"content_urls": {"desktop": {"page": "https://ja.wikipedia.org/wiki/%E3%83%8F%E3%83%88"}}
+			}`},
+			expected: &wikipedia.Article{
+				Title:   "ハト",
+				Extract: "ハトは、ハト目ハト科に属する鳥類の総称である。",
+				URL:     "https://ja.wikipedia.org/wiki/%E3%83%8F%E3%83%88",
+			},
+			expectedSummary: "/api/rest_v1/page/summary/" + url.PathEscape("ハト"),
+		},
+		{
+			name:   "曖昧さ回避のページは候補を返す",
+			term:   "Go",
+			search: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `["Go",["GO","Go (プログラミング言語)","囲碁"],[],[]]`},
+			summary: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{
+				"type": "disambiguation",
+				"title": "GO",
+				"extract": "GO、Go、goは、以下の意味がある。",
+				"content_urls": {"desktop": {"page": "https://ja.wikipedia.org/wiki/GO"}}
+			}`},
+			expected: &wikipedia.Article{
+				Title:          "GO",
+				Extract:        "GO、Go、goは、以下の意味がある。",
+				URL:            "https://ja.wikipedia.org/wiki/GO",
+				Disambiguation: true,
+				Candidates:     []string{"Go (プログラミング言語)", "囲碁"},
+			},
+			expectedSummary: "/api/rest_v1/page/summary/GO",
+		},
+		{
+			name:   "記事名の空白はアンダースコアにする",
+			term:   "Go programming",
+			search: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `["Go programming",["Go (プログラミング言語)"],[],[]]`},
+			summary: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{
+				"type": "standard",
+				"title": "Go (プログラミング言語)",
+				"extract": "Goはプログラミング言語の一つである。"
+			}`},
+			expected: &wikipedia.Article{
+				Title:   "Go (プログラミング言語)",
+				Extract: "Goはプログラミング言語の一つである。",
+			},
+			expectedSummary: "/api/rest_v1/page/summary/" + url.PathEscape("Go_(プログラミング言語)"),
+		},
+		{
+			name:    "長い要約は句点で区切る",
+			term:    "長文",
+			search:  httpclient.MockResponse{StatusCode: http.StatusOK, Body: `["長文",["長文"],[],[]]`},
+			summary: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"type":"standard","title":"長文","extract":"` + longExtract + `"}`},
+			expected: &wikipedia.Article{
+				Title:   "長文",
+				Extract: strings.Repeat("あ", 150) + "。",
+			},
+			expectedSummary: "/api/rest_v1/page/summary/" + url.PathEscape("長文"),
+		},
+		{
+			name:    "句点のない長い要約は省略記号を付ける",
+			term:    "長文",
+			search:  httpclient.MockResponse{StatusCode: http.StatusOK, Body: `["長文",["長文"],[],[]]`},
+			summary: httpclient.MockResponse{StatusCode: http.StatusOK, Body: `{"type":"standard","title":"長文","extract":"` + longExtractWithoutPeriod + `"}`},
+			expected: &wikipedia.Article{
+				Title:   "長文",
+				Extract: strings.Repeat("う", 199) + "…",
+			},
+			expectedSummary: "/api/rest_v1/page/summary/" + url.PathEscape("長文"),
+		},
+		{
+			name:          "記事が見つからない",
+			term:          "存在しない語句",
+			search:        httpclient.MockResponse{StatusCode: http.StatusOK, Body: `["存在しない語句",[],[],[]]`},
+			expectedError: wikipedia.ErrNotFound,
+		},
+		{
+			name:          "語句なし",
+			term:          " ？ ",
+			expectedError: wikipedia.ErrNoTerm,
+		},
+		{
+			name:          "検索APIのエラー",
+			term:          "鳩",
+			search:        httpclient.MockResponse{StatusCode: http.StatusServiceUnavailable, Body: `{}`},
+			expectedError: httpclient.ErrHTTPRequestError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			transport := newWikipediaTransport(tt.search, tt.summary)
+
+			article, err := wikipedia.LookupWithClient(t.Context(), &wikipedia.LookupWithClientParams{
+				Client: transport.Client(),
+				Term:   tt.term,
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("LookupWithClient() error = %v, want %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expected, article); diff != "" {
+				t.Errorf("LookupWithClient() mismatch (-want +got):\n%s", diff)
+			}
+			if tt.expectedSummary == "" {
+				return
+			}
+
+			requests := transport.RequestsTo("/api/rest_v1/page/summary/")
+			if len(requests) != 1 {
+				t.Fatalf("summary requests = %d, want 1", len(requests))
+			}
+			if !strings.HasSuffix(requests[0].URL, tt.expectedSummary) {
+				t.Errorf("summary URL = %q, want suffix %q", requests[0].URL, tt.expectedSummary)
+			}
+		})
+	}
+}
+
+func TestLookupWithClientNilParams(t *testing.T) {
+	t.Parallel()
+	if _, err := wikipedia.LookupWithClient(t.Context(), nil); !errors.Is(err, lib.ErrParamsNil) {
+		t.Errorf("LookupWithClient(nil) error = %v, want %v", err, lib.ErrParamsNil)
+	}
+}
+
+func TestArticleFillTemplateData(t *testing.T) {
+	tests := []struct {
+		name               string
+		locale             i18n.Locale
+		expectedCandidates string
+	}{
+		{name: "日本語", locale: i18n.LocaleJa, expectedCandidates: "囲碁、碁盤"},
+		{name: "英語", locale: i18n.LocaleEn, expectedCandidates: "囲碁, 碁盤"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			article := &wikipedia.Article{Title: "碁", Extract: "要約", URL: "https://example.com", Candidates: []string{"囲碁", "碁盤"}}
+			data := &i18n.TemplateData{Locale: tt.locale}
+			article.FillTemplateData(data)
+
+			expected := &i18n.TemplateData{
+				Locale:     tt.locale,
+				Title:      "碁",
+				Extract:    "要約",
+				URL:        "https://example.com",
+				Candidates: tt.expectedCandidates,
+			}
+			if diff := cmp.Diff(expected, data); diff != "" {
+				t.Errorf("FillTemplateData() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommandErrorKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected i18n.Key
+	}{
+		{name: "語句なし", err: wikipedia.ErrNoTerm, expected: i18n.KeyErrorWikipediaNoTerm},
+		{name: "記事なし", err: errors.Wrap(wikipedia.ErrNotFound, "Failed to lookup"), expected: i18n.KeyErrorWikipediaNotFound},
+		{name: "その他のエラー", err: httpclient.ErrHTTPRequestError, expected: i18n.KeyErrorWikipediaCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := wikipedia.CommandErrorKey(tt.err); got != tt.expected {
+				t.Errorf("CommandErrorKey() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}